@@ -5,11 +5,13 @@
 package dep
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
 )
 
 // Analyzer implements gps.ProjectAnalyzer.
@@ -23,10 +25,12 @@ func (a Analyzer) HasDepMetadata(path string) bool {
 }
 
 // DeriveManifestAndLock reads and returns the manifest at path/ManifestName or nil if one is not found.
+// Without a manifest, the requirements in path/GoModName, if there is one, are
+// made into constraints, as manifestFromGoMod does.
 // The Lock is always nil for now.
 func (a Analyzer) DeriveManifestAndLock(path string, n gps.ProjectRoot) (gps.Manifest, gps.Lock, error) {
 	if !a.HasDepMetadata(path) {
-		return nil, nil, nil
+		return deriveGoModManifest(path)
 	}
 
 	f, err := os.Open(filepath.Join(path, ManifestName))
@@ -44,6 +48,23 @@ func (a Analyzer) DeriveManifestAndLock(path string, n gps.ProjectRoot) (gps.Man
 	return m, nil, nil
 }
 
+// deriveGoModManifest returns the manifest made from the go.mod file in the
+// directory path, or nil if there is none.
+func deriveGoModManifest(path string) (gps.Manifest, gps.Lock, error) {
+	data, err := ioutil.ReadFile(filepath.Join(path, GoModName))
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := parseGoMod(data)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not parse the go.mod file of %s", path)
+	}
+	return manifestFromGoMod(f), nil, nil
+}
+
 // Info returns Analyzer's name and version info.
 func (a Analyzer) Info() gps.ProjectAnalyzerInfo {
 	return gps.ProjectAnalyzerInfo{
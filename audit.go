@@ -0,0 +1,196 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// AuditLogVersion is the version of the layout of the records written to
+// audit logs. It is only incremented by changes that are not backwards
+// compatible; fields may be added without it changing.
+const AuditLogVersion = 1
+
+// The kinds of change recorded for each project in an AuditRecord.
+const (
+	AuditAdded    = "added"
+	AuditRemoved  = "removed"
+	AuditModified = "modified"
+)
+
+// AuditRecord describes a change made to a project's lock. Audit logs hold
+// one record per line, encoded as JSON.
+type AuditRecord struct {
+	Version  int            `json:"version"`
+	Time     time.Time      `json:"time"`
+	User     string         `json:"user,omitempty"`
+	Command  string         `json:"command,omitempty"`
+	Inputs   AuditHash      `json:"inputs-digest"`
+	Projects []AuditProject `json:"projects"`
+}
+
+// AuditHash holds the inputs digests of the locks before and after a change.
+// Either is empty if there was no such lock, or it had no digest.
+type AuditHash struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// AuditProject describes the change made to a single locked project. Before
+// is nil for added projects, and After for removed ones.
+type AuditProject struct {
+	Name   gps.ProjectRoot `json:"name"`
+	Change string          `json:"change"`
+	Before *AuditVersion   `json:"before,omitempty"`
+	After  *AuditVersion   `json:"after,omitempty"`
+}
+
+// AuditVersion is what a project was locked to.
+type AuditVersion struct {
+	Source   string   `json:"source,omitempty"`
+	Version  string   `json:"version,omitempty"`
+	Branch   string   `json:"branch,omitempty"`
+	Revision string   `json:"revision"`
+	Packages []string `json:"packages"`
+}
+
+// NewAuditRecord describes the changes made by going from the lock old to
+// new, either of which may be nil. It returns false if there are none.
+func NewAuditRecord(old, new *Lock) (AuditRecord, bool) {
+	// Avoid handing typed nil pointers to DiffLocks, which only defaults
+	// untyped nil locks.
+	var l1, l2 gps.Lock
+	if old != nil {
+		l1 = old
+	}
+	if new != nil {
+		l2 = new
+	}
+	diff := gps.DiffLocks(l1, l2)
+	if diff == nil {
+		return AuditRecord{}, false
+	}
+
+	rec := AuditRecord{
+		Version:  AuditLogVersion,
+		Projects: []AuditProject{},
+	}
+	if old != nil {
+		rec.Inputs.Before = hex.EncodeToString(old.SolveMeta.InputsDigest)
+	}
+	if new != nil {
+		rec.Inputs.After = hex.EncodeToString(new.SolveMeta.InputsDigest)
+	}
+
+	for _, d := range diff.Add {
+		rec.Projects = append(rec.Projects, AuditProject{
+			Name:   d.Name,
+			Change: AuditAdded,
+			After:  auditVersion(new, d.Name),
+		})
+	}
+	for _, d := range diff.Remove {
+		rec.Projects = append(rec.Projects, AuditProject{
+			Name:   d.Name,
+			Change: AuditRemoved,
+			Before: auditVersion(old, d.Name),
+		})
+	}
+	for _, d := range diff.Modify {
+		rec.Projects = append(rec.Projects, AuditProject{
+			Name:   d.Name,
+			Change: AuditModified,
+			Before: auditVersion(old, d.Name),
+			After:  auditVersion(new, d.Name),
+		})
+	}
+	return rec, true
+}
+
+// auditVersion returns what the project pr is locked to in l, or nil if l
+// does not lock it.
+func auditVersion(l *Lock, pr gps.ProjectRoot) *AuditVersion {
+	if l == nil {
+		return nil
+	}
+	for _, lp := range l.P {
+		if lp.Ident().ProjectRoot != pr {
+			continue
+		}
+		av := &AuditVersion{
+			Source:   lp.Ident().Source,
+			Packages: lp.Packages(),
+		}
+		av.Revision, av.Branch, av.Version = gps.VersionComponentStrings(lp.Version())
+		return av
+	}
+	return nil
+}
+
+// AppendAuditRecord appends rec to the audit log at path, creating it if it
+// does not exist.
+func AppendAuditRecord(path string, rec AuditRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode audit record")
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return errors.Wrapf(err, "could not open audit log %s", path)
+	}
+	// The record is written at once, so that records appended concurrently
+	// are not interleaved.
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "could not write to audit log %s", path)
+	}
+	return errors.Wrapf(f.Close(), "could not write to audit log %s", path)
+}
+
+// AuditLockChanges records the changes between old and the lock currently on
+// disk in p's root to the audit log named in p's manifest, if any.
+func (c *Ctx) AuditLockChanges(p *Project, old *Lock) error {
+	if p.Manifest == nil || p.Manifest.AuditLog == "" {
+		return nil
+	}
+
+	cur, err := p.lockOnDisk()
+	if err != nil {
+		return err
+	}
+	rec, changed := NewAuditRecord(old, cur)
+	if !changed {
+		return nil
+	}
+	rec.Time = time.Now().UTC()
+	rec.User = auditUser()
+	rec.Command = c.Command
+
+	path := p.Manifest.AuditLog
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.AbsRoot, path)
+	}
+	return AppendAuditRecord(path, rec)
+}
+
+// auditUser returns the name of the user running dep, if it can be found.
+func auditUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestNewAuditRecord(t *testing.T) {
+	old := &Lock{
+		SolveMeta: SolveMeta{InputsDigest: []byte{0x01, 0x02}},
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewBranch("master").Pair("c3d595a33a77ff3f841fd8ca1bc8cd0278a227df"), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/qux"}, gps.NewVersion("v2.0.0").Pair("ff3f841fd8ca1bc8cd0278a227dfc3d595a33a77"), []string{"."}),
+		},
+	}
+	new := &Lock{
+		SolveMeta: SolveMeta{InputsDigest: []byte{0x03, 0x04}},
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar", Source: "github.com/fork/bar"}, gps.NewVersion("v1.1.0").Pair("a33a77ff3f841fd8ca1bc8cd0278a227dfc3d595"), []string{".", "sub"}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/new"}, gps.Revision("8ca1bc8cd0278a227dfc3d595a33a77ff3f841fd"), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/qux"}, gps.NewVersion("v2.0.0").Pair("ff3f841fd8ca1bc8cd0278a227dfc3d595a33a77"), []string{"."}),
+		},
+	}
+
+	rec, changed := NewAuditRecord(old, new)
+	if !changed {
+		t.Fatal("expected the locks to differ")
+	}
+	want := AuditRecord{
+		Version: AuditLogVersion,
+		Inputs:  AuditHash{Before: "0102", After: "0304"},
+		Projects: []AuditProject{
+			{
+				Name:   "github.com/foo/new",
+				Change: AuditAdded,
+				After:  &AuditVersion{Revision: "8ca1bc8cd0278a227dfc3d595a33a77ff3f841fd", Packages: []string{"."}},
+			},
+			{
+				Name:   "github.com/foo/baz",
+				Change: AuditRemoved,
+				Before: &AuditVersion{Branch: "master", Revision: "c3d595a33a77ff3f841fd8ca1bc8cd0278a227df", Packages: []string{"."}},
+			},
+			{
+				Name:   "github.com/foo/bar",
+				Change: AuditModified,
+				Before: &AuditVersion{Version: "v1.0.0", Revision: "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0", Packages: []string{"."}},
+				After:  &AuditVersion{Source: "github.com/fork/bar", Version: "v1.1.0", Revision: "a33a77ff3f841fd8ca1bc8cd0278a227dfc3d595", Packages: []string{".", "sub"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(rec, want) {
+		t.Fatalf("unexpected audit record:\n\t(GOT) %+v\n\t(WNT) %+v", rec, want)
+	}
+
+	if _, changed := NewAuditRecord(new, new); changed {
+		t.Fatal("expected identical locks not to yield an audit record")
+	}
+
+	rec, changed = NewAuditRecord(nil, old)
+	if !changed {
+		t.Fatal("expected a lock to differ from no lock")
+	}
+	if rec.Inputs.Before != "" || len(rec.Projects) != 3 || rec.Projects[0].Change != AuditAdded {
+		t.Fatalf("unexpected audit record for a new lock: %+v", rec)
+	}
+}
+
+func TestAuditLockChanges(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("proj")
+	root := h.Path("proj")
+
+	old := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."}),
+		},
+	}
+	cur := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.1.0").Pair("a33a77ff3f841fd8ca1bc8cd0278a227dfc3d595"), []string{"."}),
+		},
+	}
+	tl, err := cur.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, LockName), tl, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &Ctx{Command: "dep ensure -update"}
+	p := &Project{AbsRoot: root, Manifest: NewManifest()}
+
+	// Without an audit log in the manifest, nothing is recorded.
+	if err := ctx.AuditLockChanges(p, old); err != nil {
+		t.Fatal(err)
+	}
+	h.MustNotExist(filepath.Join(root, "deps.log"))
+
+	p.Manifest.AuditLog = "deps.log"
+	for i := 0; i < 2; i++ {
+		if err := ctx.AuditLockChanges(p, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The lock on disk is unchanged from cur, so nothing is recorded.
+	if err := ctx.AuditLockChanges(p, cur); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(root, "deps.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var recs []AuditRecord
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatalf("could not decode audit record %q: %s", s.Text(), err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 audit records, got %d", len(recs))
+	}
+	rec := recs[1]
+	if rec.Version != AuditLogVersion || rec.Command != "dep ensure -update" || rec.Time.IsZero() {
+		t.Fatalf("unexpected audit record: %+v", rec)
+	}
+	if len(rec.Projects) != 1 || rec.Projects[0].Before.Version != "v1.0.0" || rec.Projects[0].After.Version != "v1.1.0" {
+		t.Fatalf("unexpected projects in audit record: %+v", rec.Projects)
+	}
+}
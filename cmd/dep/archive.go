@@ -0,0 +1,81 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const archiveShortHelp = `Write the vendor tree of the project to a tar archive`
+const archiveLongHelp = `
+Write a tar archive holding the vendor tree of the project, as dep ensure would
+write it for Gopkg.lock, pruned as Gopkg.toml says, with its entries under
+vendor/. The archive is written to the file named by -o, or to standard output.
+
+The archive is reproducible: for the same Gopkg.lock and prune options, it is
+the same, byte for byte, whenever and on whichever machine it is written. Its
+entries are sorted by name, and carry no modification times or owners; files
+are 0644, or 0755 if they are executable, and directories are 0755. This makes
+it suitable for checksumming, and for caching in build systems.
+`
+
+type archiveCommand struct {
+	output string
+}
+
+func (cmd *archiveCommand) Name() string      { return "archive" }
+func (cmd *archiveCommand) Args() string      { return "[-o file]" }
+func (cmd *archiveCommand) ShortHelp() string { return archiveShortHelp }
+func (cmd *archiveCommand) LongHelp() string  { return archiveLongHelp }
+func (cmd *archiveCommand) Hidden() bool      { return false }
+
+func (cmd *archiveCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.output, "o", "", "the file to write the archive to (default: standard output)")
+}
+
+func (cmd *archiveCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.New("dep archive takes no arguments")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("there is no %s to archive the vendor tree of", dep.LockName)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	if cmd.output == "" {
+		return errors.Wrap(gps.WriteDepTreeArchive(os.Stdout, p.Lock, sm, p.Manifest.PruneOptions), "unable to write the archive")
+	}
+
+	f, err := os.Create(cmd.output)
+	if err != nil {
+		return errors.Wrap(err, "unable to create the archive")
+	}
+	if err := gps.WriteDepTreeArchive(f, p.Lock, sm, p.Manifest.PruneOptions); err != nil {
+		f.Close()
+		os.Remove(cmd.output)
+		return errors.Wrap(err, "unable to write the archive")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "unable to write the archive")
+	}
+	ctx.Out.Printf("Archived the vendor tree of %d projects to %s\n", len(p.Lock.Projects()), cmd.output)
+	return nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const bazelShortHelp = `Write Bazel go_repository rules for Gopkg.lock`
+const bazelLongHelp = `
+Write a go_repository rule, as defined by Gazelle, for every project in
+Gopkg.lock, so that Bazel builds the project with the same dependencies as dep.
+
+Projects locked to semver tags are fetched as modules, by their version and
+the sum that go.sum would record for it, which is computed from the locked
+version in the source cache. Other projects are fetched by their locked
+commit. Projects locked to an alternate source are fetched from it: as a
+replacement module, or from its remote, which is taken to be a git
+repository.
+
+Projects whose non-Go files are pruned from vendor/ are given the
+build_file_proto_mode "disable", so that Gazelle builds them from their
+generated .pb.go files, as dep does, rather than from their .proto files.
+
+The rules are written to standard output, unless -workspace or -macro is
+given. -workspace names a WORKSPACE file, in which the rules are written in
+place of those written to it before, between the lines
+
+  # BEGIN dep go_repository rules
+  # END dep go_repository rules
+
+or appended to it the first time, so that running dep bazel -workspace after
+every change to Gopkg.lock keeps the two in lockstep. The file must load
+go_repository from @bazel_gazelle//:deps.bzl before the rules.
+
+-macro names a file, relative to the project root, and a macro, as
+"file.bzl%macro", as Gazelle's update-repos -to_macro does. The file is
+written in full, in the form update-repos gives it, defining only the macro,
+which declares the rules. WORKSPACE loads the macro from the file, and calls
+it. If Gopkg.toml names a macro in its bazel-macro field, dep ensure writes it
+whenever it writes Gopkg.lock.
+`
+
+// The lines around the rules dep bazel writes to a WORKSPACE file.
+const (
+	bazelBeginMarker = "# BEGIN dep go_repository rules"
+	bazelEndMarker   = "# END dep go_repository rules"
+)
+
+type bazelCommand struct {
+	workspace string
+	macro     string
+}
+
+func (cmd *bazelCommand) Name() string      { return "bazel" }
+func (cmd *bazelCommand) Args() string      { return "[-workspace file | -macro file.bzl%macro]" }
+func (cmd *bazelCommand) ShortHelp() string { return bazelShortHelp }
+func (cmd *bazelCommand) LongHelp() string  { return bazelLongHelp }
+func (cmd *bazelCommand) Hidden() bool      { return false }
+
+func (cmd *bazelCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.workspace, "workspace", "", "WORKSPACE file to write the rules to")
+	fs.StringVar(&cmd.macro, "macro", "", "file and macro to write the rules to, as file.bzl%macro")
+}
+
+func (cmd *bazelCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep bazel takes no arguments")
+	}
+	if cmd.workspace != "" && cmd.macro != "" {
+		return errors.New("cannot pass both -workspace and -macro")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	if cmd.macro != "" {
+		m := dep.Manifest{BazelMacro: cmd.macro}
+		file, macro := m.BazelMacroFile()
+		if file == "" || macro == "" {
+			return errors.Errorf("-macro must be of the form file.bzl%%macro, not %q", cmd.macro)
+		}
+		return writeBazelMacro(ctx, p.Lock, p.Manifest.PruneOptions, sm, filepath.Join(p.AbsRoot, file), macro)
+	}
+
+	repos, err := lockedGoRepositories(ctx, p.Lock, p.Manifest.PruneOptions, sm)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, r := range repos {
+		r.format(&buf, "")
+	}
+	if cmd.workspace == "" {
+		ctx.Out.Print(buf.String())
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(cmd.workspace)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not read %s", cmd.workspace)
+	}
+	data, err = replaceMarked(data, bazelBeginMarker, bazelEndMarker, buf.Bytes())
+	if err != nil {
+		return errors.Wrapf(err, "could not update %s", cmd.workspace)
+	}
+	return errors.Wrapf(ioutil.WriteFile(cmd.workspace, data, 0666), "could not write %s", cmd.workspace)
+}
+
+// lockedGoRepositories returns the go_repository rules of the projects in l,
+// as goRepositories does, warning of those that have none.
+func lockedGoRepositories(ctx *dep.Ctx, l *dep.Lock, prune gps.CascadingPruneOptions, sm gps.SourceManager) ([]goRepository, error) {
+	repos, skipped, err := goRepositories(l, prune, sm)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range skipped {
+		ctx.Logger().Warnf("%s is locked to a local directory, which go_repository cannot fetch from", pr)
+	}
+	return repos, nil
+}
+
+// writeBazelMacro writes the file at path, defining the macro, which declares
+// the go_repository rules of the projects in l, as Gazelle's update-repos
+// would write it.
+func writeBazelMacro(ctx *dep.Ctx, l *dep.Lock, prune gps.CascadingPruneOptions, sm gps.SourceManager, path, macro string) error {
+	repos, err := lockedGoRepositories(ctx, l, prune, sm)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "load(\"@bazel_gazelle//:deps.bzl\", \"go_repository\")\n\ndef %s():\n", macro)
+	if len(repos) == 0 {
+		buf.WriteString("    pass\n")
+	}
+	for _, r := range repos {
+		r.format(&buf, "    ")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, buf.Bytes(), 0666), "could not write %s", path)
+}
+
+// goRepository is a go_repository rule.
+type goRepository struct {
+	name, importpath string
+
+	// The mode of Gazelle's handling of .proto files, if not the default.
+	protoMode string
+
+	// A module, at a version, and the sum of its contents, replaced by
+	// another module if replace is not empty.
+	version, sum, replace string
+
+	// Or a commit, in the repository at remote, of the kind vcs, if remote
+	// is not empty.
+	commit, remote, vcs string
+}
+
+// goRepositories returns the go_repository rules of the projects in l, and
+// the projects locked to local directories, which have none. The sums of
+// modules are computed from the source cache of sm. Projects that prune
+// prunes the non-Go files of do not have rules made for their .proto files.
+func goRepositories(l *dep.Lock, prune gps.CascadingPruneOptions, sm gps.SourceManager) ([]goRepository, []gps.ProjectRoot, error) {
+	var (
+		repos   []goRepository
+		skipped []gps.ProjectRoot
+		sources []moduleSource
+		modules []int // the index in repos of each of sources
+	)
+	for _, lp := range l.Projects() {
+		id := lp.Ident()
+		r := goRepository{
+			name:       bazelRepoName(string(id.ProjectRoot)),
+			importpath: string(id.ProjectRoot),
+		}
+		if prune.PruneOptionsFor(id.ProjectRoot)&gps.PruneNonGoFiles != 0 {
+			r.protoMode = "disable"
+		}
+
+		path := string(id.ProjectRoot)
+		if id.Source != "" && id.Source != path {
+			src, local := sourceModulePath(id.Source)
+			if local {
+				skipped = append(skipped, id.ProjectRoot)
+				continue
+			}
+			path = src
+		}
+
+		version, canonical := moduleVersion(id.ProjectRoot, lp.Version())
+		if canonical {
+			r.version = version
+			if path != r.importpath {
+				r.replace = path
+			}
+			sources = append(sources, moduleSource{path: path, version: version, id: id, v: lp.Version()})
+			modules = append(modules, len(repos))
+		} else {
+			r.commit, _, _ = gps.VersionComponentStrings(lp.Version())
+			if path != r.importpath {
+				r.remote, r.vcs = sourceLocation(sm, id), "git"
+			}
+		}
+		repos = append(repos, r)
+	}
+
+	hashes, err := hashModules(sm, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, h := range hashes {
+		repos[modules[i]].sum = h.sum
+	}
+	return repos, skipped, nil
+}
+
+// format writes the rule to buf, with each of its lines indented by indent.
+// Its attributes are in the order Gazelle writes them in.
+func (r goRepository) format(buf *bytes.Buffer, indent string) {
+	fmt.Fprintf(buf, "%sgo_repository(\n", indent)
+	for _, attr := range [][2]string{
+		{"name", r.name},
+		{"build_file_proto_mode", r.protoMode},
+		{"commit", r.commit},
+		{"importpath", r.importpath},
+		{"remote", r.remote},
+		{"replace", r.replace},
+		{"sum", r.sum},
+		{"vcs", r.vcs},
+		{"version", r.version},
+	} {
+		if attr[1] != "" {
+			fmt.Fprintf(buf, "%s    %s = %q,\n", indent, attr[0], attr[1])
+		}
+	}
+	fmt.Fprintf(buf, "%s)\n", indent)
+}
+
+// bazelRepoName returns the name Gazelle gives the repository of the Go
+// import path importpath: its host's labels in reverse, then the rest of its
+// elements, joined by underscores, such as com_github_pkg_errors.
+func bazelRepoName(importpath string) string {
+	elems := strings.Split(strings.ToLower(importpath), "/")
+	labels := strings.Split(elems[0], ".")
+	parts := make([]string, 0, len(labels)+len(elems)-1)
+	for i := len(labels) - 1; i >= 0; i-- {
+		parts = append(parts, labels[i])
+	}
+	parts = append(parts, elems[1:]...)
+	return strings.NewReplacer("-", "_", ".", "_").Replace(strings.Join(parts, "_"))
+}
+
+// replaceMarked returns data with the lines between the lines begin and end
+// replaced with text, or, if data has no such lines, with text between them
+// appended to it.
+func replaceMarked(data []byte, begin, end string, text []byte) ([]byte, error) {
+	var block bytes.Buffer
+	block.WriteString(begin + "\n")
+	block.Write(text)
+	block.WriteString(end + "\n")
+
+	lines := strings.SplitAfter(string(data), "\n")
+	first, last := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case begin:
+			if first >= 0 {
+				return nil, errors.Errorf("%q appears more than once", begin)
+			}
+			first = i
+		case end:
+			if first < 0 || last >= 0 {
+				return nil, errors.Errorf("%q does not follow %q once", end, begin)
+			}
+			last = i
+		}
+	}
+
+	switch {
+	case first < 0 && last < 0:
+		var out bytes.Buffer
+		out.Write(data)
+		if len(data) > 0 {
+			if data[len(data)-1] != '\n' {
+				out.WriteString("\n")
+			}
+			out.WriteString("\n")
+		}
+		out.Write(block.Bytes())
+		return out.Bytes(), nil
+	case first < 0 || last < 0:
+		return nil, errors.Errorf("%q is not followed by %q", begin, end)
+	}
+	return []byte(strings.Join(lines[:first], "") + block.String() + strings.Join(lines[last+1:], "")), nil
+}
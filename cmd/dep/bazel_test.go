@@ -0,0 +1,199 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestBazelRepoName(t *testing.T) {
+	for importpath, want := range map[string]string{
+		"github.com/pkg/errors":          "com_github_pkg_errors",
+		"gopkg.in/yaml.v2":               "in_gopkg_yaml_v2",
+		"golang.org/x/net":               "org_golang_x_net",
+		"github.com/Sirupsen/logrus":     "com_github_sirupsen_logrus",
+		"go.example-corp.com/my-project": "com_example_corp_go_my_project",
+	} {
+		if got := bazelRepoName(importpath); got != want {
+			t.Errorf("unexpected repository name of %s:\n\t(GOT): %s\n\t(WNT): %s", importpath, got, want)
+		}
+	}
+}
+
+func TestGoRepositories(t *testing.T) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	l := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/branch"}, gps.NewBranch("master").Pair(rev), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/fork", Source: "https://github.com/fork/fork.git"}, rev, []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/local", Source: "/src/local"}, rev, []string{"."}),
+		},
+	}
+
+	// Only the rules of projects whose non-Go files, including their .proto
+	// files, are pruned are given a build_file_proto_mode.
+	prune := gps.CascadingPruneOptions{
+		DefaultOptions:    gps.PruneNonGoFiles,
+		PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{"github.com/foo/branch": {NonGoFiles: 2}},
+	}
+
+	repos, skipped, err := goRepositories(l, prune, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/local"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("unexpected skipped projects:\n\t(GOT): %v\n\t(WNT): %v", skipped, want)
+	}
+
+	var buf bytes.Buffer
+	for _, r := range repos {
+		r.format(&buf, "")
+	}
+	want := `go_repository(
+    name = "com_github_foo_branch",
+    commit = "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65",
+    importpath = "github.com/foo/branch",
+)
+go_repository(
+    name = "com_github_foo_fork",
+    build_file_proto_mode = "disable",
+    commit = "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65",
+    importpath = "github.com/foo/fork",
+    remote = "https://github.com/fork/fork.git",
+    vcs = "git",
+)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected rules:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	// Modules are written with their version and sum.
+	buf.Reset()
+	goRepository{
+		name:       "com_github_pkg_errors",
+		importpath: "github.com/pkg/errors",
+		version:    "v0.8.0",
+		sum:        "h1:WdK/asTD0HN+q6hsWO3/vpuAkAr+tw6aNJNDFFf0+qw=",
+		replace:    "github.com/fork/errors",
+	}.format(&buf, "    ")
+	want = `    go_repository(
+        name = "com_github_pkg_errors",
+        importpath = "github.com/pkg/errors",
+        replace = "github.com/fork/errors",
+        sum = "h1:WdK/asTD0HN+q6hsWO3/vpuAkAr+tw6aNJNDFFf0+qw=",
+        version = "v0.8.0",
+    )
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected rule:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+}
+
+func TestReplaceMarked(t *testing.T) {
+	const begin, end = "# BEGIN", "# END"
+	cases := []struct {
+		name, data, want string
+		err              bool
+	}{
+		{
+			name: "empty",
+			want: "# BEGIN\nrules\n# END\n",
+		},
+		{
+			name: "appended",
+			data: "load(\"x\")",
+			want: "load(\"x\")\n\n# BEGIN\nrules\n# END\n",
+		},
+		{
+			name: "replaced",
+			data: "before\n# BEGIN\nold\nrules\n  # END\nafter\n",
+			want: "before\n# BEGIN\nrules\n# END\nafter\n",
+		},
+		{
+			name: "no end",
+			data: "# BEGIN\nold\n",
+			err:  true,
+		},
+		{
+			name: "end first",
+			data: "# END\n# BEGIN\n",
+			err:  true,
+		},
+		{
+			name: "twice",
+			data: "# BEGIN\n# END\n# BEGIN\n# END\n",
+			err:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := replaceMarked([]byte(c.data), begin, end, []byte("rules\n"))
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != c.want {
+				t.Fatalf("unexpected result:\n\t(GOT): %q\n\t(WNT): %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWriteBazelMacro(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("bazel")
+	path := filepath.Join(h.Path("bazel"), "deps.bzl")
+	read := func() string {
+		data, err := ioutil.ReadFile(path)
+		h.Must(err)
+		return string(data)
+	}
+
+	ctx := &dep.Ctx{Err: log.New(ioutil.Discard, "", 0)}
+	if err := writeBazelMacro(ctx, &dep.Lock{}, gps.CascadingPruneOptions{}, nil, path, "go_dependencies"); err != nil {
+		t.Fatal(err)
+	}
+	want := "load(\"@bazel_gazelle//:deps.bzl\", \"go_repository\")\n\ndef go_dependencies():\n    pass\n"
+	if got := read(); got != want {
+		t.Fatalf("unexpected macro file:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	l := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65"), []string{"."}),
+		},
+	}
+	if err := writeBazelMacro(ctx, l, gps.CascadingPruneOptions{}, nil, path, "go_dependencies"); err != nil {
+		t.Fatal(err)
+	}
+	want = `load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+def go_dependencies():
+    go_repository(
+        name = "com_github_foo_bar",
+        commit = "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65",
+        importpath = "github.com/foo/bar",
+    )
+`
+	if got := read(); got != want {
+		t.Fatalf("unexpected macro file:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+}
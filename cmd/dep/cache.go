@@ -0,0 +1,259 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const cacheShortHelp = `Manage the source cache`
+const cacheLongHelp = `
+Manage the cache of the sources dep fetches projects from, in $DEPCACHEDIR, or
+$GOPATH/pkg/dep by default.
+
+Subcommands:
+
+  gc                      Remove the sources that are no longer needed
+  export [-lock file] <bundle>
+                          Write the sources a lock needs to a bundle
+  import [-replace] <bundle>
+                          Add the sources in a bundle to the cache
+  key                     Print a CI cache key for vendor/ and the cache
+
+dep cache gc removes the sources that the cache-gc policy of the user's
+configuration says are no longer needed: those unused for longer than its
+max-age, and then, least recently used first, as many as it takes to bring the
+cache under its max-size. The sources of the projects in the keep-locks most
+recently used locks are kept. The flags override the policy for this run.
+
+The cache is also garbage collected automatically, as often as the policy's
+interval says, whenever dep releases it.
+
+dep cache export writes a bundle, a tar archive, holding the sources of the
+projects in Gopkg.lock, or the lock named by -lock, with their locked
+revisions. dep cache import adds the sources in a bundle to the cache of
+another machine, keeping those already there unless -replace is given, so
+that dep can solve and vendor the lock there without reaching the network.
+
+dep cache key prints a digest of everything that determines the contents of
+vendor/: the source, revision and packages of every project in Gopkg.lock, the
+prune options each is written with, and the version of dep. It changes exactly
+when vendor/ would, and not with changes to Gopkg.toml that leave the lock as it
+is, so it can be used as the key under which CI systems cache vendor/ and the
+source cache.
+`
+
+type cacheCommand struct {
+	flags     *flag.FlagSet
+	dryRun    bool
+	maxSize   string
+	maxAge    time.Duration
+	keepLocks int
+	lock      string
+	replace   bool
+}
+
+func (cmd *cacheCommand) Name() string      { return "cache" }
+func (cmd *cacheCommand) Args() string      { return "gc|export|import|key [flags] [bundle]" }
+func (cmd *cacheCommand) ShortHelp() string { return cacheShortHelp }
+func (cmd *cacheCommand) LongHelp() string  { return cacheLongHelp }
+func (cmd *cacheCommand) Hidden() bool      { return false }
+
+func (cmd *cacheCommand) Register(fs *flag.FlagSet) {
+	cmd.flags = fs
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the sources that would be removed")
+	fs.StringVar(&cmd.maxSize, "max-size", "", "how much space the sources may take up, such as 10GB")
+	fs.DurationVar(&cmd.maxAge, "max-age", 0, "how long a source may go unused before it is removed")
+	fs.IntVar(&cmd.keepLocks, "keep-locks", -1, "how many of the most recently used locks have their sources kept")
+	fs.StringVar(&cmd.lock, "lock", "", "the lock whose sources to export (default: the project's "+dep.LockName+")")
+	fs.BoolVar(&cmd.replace, "replace", false, "replace the sources already in the cache with those imported")
+}
+
+func (cmd *cacheCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 {
+		return errors.New("dep cache requires a subcommand: gc, export, import or key")
+	}
+	sub := args[0]
+
+	// The flags may also follow the subcommand, as in "dep cache gc -dry-run".
+	if err := cmd.flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	args = cmd.flags.Args()
+
+	switch sub {
+	case "gc":
+		if len(args) != 0 {
+			return errors.New("dep cache gc takes no arguments")
+		}
+		return cmd.runGC(ctx)
+	case "export", "import":
+		if len(args) != 1 {
+			return errors.Errorf("dep cache %s takes the path of the bundle", sub)
+		}
+		if sub == "export" {
+			return cmd.runExport(ctx, args[0])
+		}
+		return cmd.runImport(ctx, args[0])
+	case "key":
+		if len(args) != 0 {
+			return errors.New("dep cache key takes no arguments")
+		}
+		return cmd.runKey(ctx)
+	default:
+		return errors.Errorf("dep cache: unknown subcommand %q", sub)
+	}
+}
+
+func (cmd *cacheCommand) runGC(ctx *dep.Ctx) error {
+	policy := ctx.Config.CacheGCPolicy()
+	if cmd.maxSize != "" {
+		n, err := dep.ParseSize(cmd.maxSize)
+		if err != nil {
+			return errors.Wrap(err, "-max-size")
+		}
+		policy.MaxSize = n
+	}
+	if cmd.maxAge != 0 {
+		policy.MaxAge = cmd.maxAge
+	}
+	if cmd.keepLocks >= 0 {
+		policy.KeepLocks = cmd.keepLocks
+	}
+	if policy.MaxSize <= 0 && policy.MaxAge <= 0 {
+		return errors.New("the cache-gc policy sets neither max-size nor max-age, so there is nothing to remove")
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	res, err := sm.CollectGarbage(policy, cmd.dryRun)
+	verb := "Removed"
+	if cmd.dryRun {
+		verb = "Would remove"
+	}
+	if cmd.dryRun || ctx.Verbose {
+		for _, cs := range res.Removed {
+			ctx.Out.Printf("%s %s (%s, last used %s)\n", verb, cs.Name, formatBytes(cs.Size), cs.LastUsed.Format("2006-01-02"))
+		}
+	}
+	ctx.Out.Printf("%s %d sources, freeing %s; %s remain\n", verb, len(res.Removed), formatBytes(res.Freed), formatBytes(res.Remaining))
+	if res.RemovedTrees > 0 {
+		ctx.Out.Printf("%s %d unused vendor trees\n", verb, res.RemovedTrees)
+	}
+	return errors.Wrap(err, "failed to garbage collect the source cache")
+}
+
+func (cmd *cacheCommand) runExport(ctx *dep.Ctx, bundle string) error {
+	lockPath := cmd.lock
+	if lockPath == "" {
+		p, err := ctx.LoadProject()
+		if err != nil {
+			return err
+		}
+		lockPath = filepath.Join(p.AbsRoot, dep.LockName)
+	}
+	l, err := dep.ReadLockFile(lockPath)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return errors.Errorf("there is no lock at %s to export the sources of", lockPath)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	f, err := os.Create(bundle)
+	if err != nil {
+		return errors.Wrap(err, "unable to create the bundle")
+	}
+	if err := sm.ExportBundle(context.TODO(), l, f); err != nil {
+		f.Close()
+		os.Remove(bundle)
+		return errors.Wrap(err, "unable to export the bundle")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "unable to write the bundle")
+	}
+	ctx.Out.Printf("Exported the sources of %d projects to %s\n", len(l.Projects()), bundle)
+	return nil
+}
+
+func (cmd *cacheCommand) runImport(ctx *dep.Ctx, bundle string) error {
+	f, err := os.Open(bundle)
+	if err != nil {
+		return errors.Wrap(err, "unable to open the bundle")
+	}
+	defer f.Close()
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	res, err := sm.ImportBundle(f, cmd.replace)
+	if err != nil {
+		return errors.Wrap(err, "unable to import the bundle")
+	}
+	if ctx.Verbose {
+		for _, root := range res.Skipped {
+			ctx.Out.Printf("Kept the source of %s already in the cache\n", root)
+		}
+	}
+	ctx.Out.Printf("Imported the sources of %d projects; %d were already in the cache\n", len(res.Imported), len(res.Skipped))
+	return nil
+}
+
+func (cmd *cacheCommand) runKey(ctx *dep.Ctx) error {
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+	ctx.Out.Println(hex.EncodeToString(cacheKey(p.Lock, p.Manifest.PruneOptions, version)))
+	return nil
+}
+
+// cacheKey returns the digest of what determines the contents of a vendor
+// directory written from l by the given version of dep, with the prune options
+// prune: the source, revision and packages of each project, and the options
+// it is pruned with.
+func cacheKey(l *dep.Lock, prune gps.CascadingPruneOptions, version string) []byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "dep %s\n", version)
+	for _, lp := range l.Projects() {
+		id := lp.Ident()
+		rev, _, _ := gps.VersionComponentStrings(lp.Version())
+		fmt.Fprintf(h, "%s %s %s %s\n", id.ProjectRoot, id.Source, rev, prune.PruneOptionsFor(id.ProjectRoot))
+		for _, pkg := range lp.Packages() {
+			fmt.Fprintf(h, "\t%s\n", pkg)
+		}
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestCacheKey(t *testing.T) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	lock := func(source string, v gps.Version, pkgs ...string) *dep.Lock {
+		return &dep.Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar", Source: source}, v, pkgs),
+			},
+		}
+	}
+	prune := gps.CascadingPruneOptions{DefaultOptions: gps.PruneNestedVendorDirs}
+
+	key := cacheKey(lock("", gps.NewVersion("v1.0.0").Pair(rev), "."), prune, "v0.5.0")
+	if !bytes.Equal(key, cacheKey(lock("", gps.NewVersion("v1.0.0").Pair(rev), "."), prune, "v0.5.0")) {
+		t.Fatal("the keys of the same lock differ")
+	}
+	// A tag moved to the same revision writes the same vendor/.
+	if !bytes.Equal(key, cacheKey(lock("", gps.NewVersion("v1.0.1").Pair(rev), "."), prune, "v0.5.0")) {
+		t.Error("the key depends on the name of the locked version, not just its revision")
+	}
+
+	other := gps.Revision("2f2a5bd28c0c4d8a8b4b1e7e6d9ad3b0f6c1e2a4")
+	pruneUnused := gps.CascadingPruneOptions{
+		DefaultOptions: gps.PruneNestedVendorDirs,
+		PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{
+			"github.com/foo/bar": {UnusedPackages: 1},
+		},
+	}
+	cases := []struct {
+		name  string
+		l     *dep.Lock
+		prune gps.CascadingPruneOptions
+		ver   string
+	}{
+		{"revision", lock("", gps.NewVersion("v1.0.0").Pair(other), "."), prune, "v0.5.0"},
+		{"source", lock("https://github.com/fork/bar.git", gps.NewVersion("v1.0.0").Pair(rev), "."), prune, "v0.5.0"},
+		{"packages", lock("", gps.NewVersion("v1.0.0").Pair(rev), ".", "sub"), prune, "v0.5.0"},
+		{"prune options", lock("", gps.NewVersion("v1.0.0").Pair(rev), "."), pruneUnused, "v0.5.0"},
+		{"dep version", lock("", gps.NewVersion("v1.0.0").Pair(rev), "."), prune, "v0.5.1"},
+	}
+	for _, c := range cases {
+		if bytes.Equal(key, cacheKey(c.l, c.prune, c.ver)) {
+			t.Errorf("the key did not change with the %s", c.name)
+		}
+	}
+}
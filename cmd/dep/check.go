@@ -0,0 +1,405 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const checkShortHelp = `Check the manifest, lock and vendor/ for problems`
+const checkLongHelp = `
+Check Gopkg.toml for problems, Gopkg.lock against Gopkg.toml and the project's
+imports, and vendor/, if it exists, against the digests recorded in
+Gopkg.lock, and report them.
+
+Errors, such as malformed values, prevent dep from using the manifest at all.
+Warnings point out rules that are likely mistakes: unknown fields, versions
+that are not valid semver ranges, constraints on projects that are not
+imported, and overrides that overlap each other or a constraint.
+
+A Gopkg.lock solved from other constraints or imports than the project now has
+is an error, as "dep ensure" would change it. This is checked without fetching
+any sources.
+
+Projects whose constraints pin a digest, or that Gopkg.toml requires to be
+signed, are checked to be locked to versions that have that digest or are
+signed as required, fetching their sources. Versions that fail are errors.
+
+Vendored projects that are missing, or that have been modified since dep
+wrote them out, are errors. Paths in vendor/ that Gopkg.lock does not account
+for, and projects that Gopkg.lock has no digest for, are warnings.
+
+With -fix, each missing or modified project is written out again from the
+source cache. The modified contents are first moved into .quarantine/ in the
+project's root, so that they can be inspected, and the files that differed
+are reported.
+
+With -go-sum, Gopkg.lock is also checked against the go.sum of a build of the
+project with Go modules, for teams building with both while they migrate.
+Each locked project is hashed as the go command hashes modules, from the
+source cache, and compared with the hashes go.sum records for its version, or,
+for projects locked to a revision, for the pseudo-version of that revision:
+
+  hashes that differ                      error, the builds use different code
+  other versions of the module in go.sum  error, the modules build selects one
+  no versions of the module in go.sum     warning, the modules build may not
+                                          need it
+
+Projects locked to local directories are not checked.
+
+dep check exits non-zero if there are errors. With -strict, it also exits
+non-zero if there are warnings, which makes it suitable for use in CI.
+`
+
+type checkCommand struct {
+	strict bool
+	fix    bool
+	goSum  string
+}
+
+func (cmd *checkCommand) Name() string      { return "check" }
+func (cmd *checkCommand) Args() string      { return "[-strict] [-fix] [-go-sum file]" }
+func (cmd *checkCommand) ShortHelp() string { return checkShortHelp }
+func (cmd *checkCommand) LongHelp() string  { return checkLongHelp }
+func (cmd *checkCommand) Hidden() bool      { return false }
+
+func (cmd *checkCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.strict, "strict", false, "exit non-zero on warnings, as well as on errors")
+	fs.BoolVar(&cmd.fix, "fix", false, "write out missing and modified vendored projects again, quarantining the modified ones")
+	fs.StringVar(&cmd.goSum, "go-sum", "", "check Gopkg.lock against the go.sum of a modules build")
+}
+
+func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep check takes no arguments")
+	}
+
+	// The manifest's warnings are reported below, along with everything else
+	// that is checked, so keep loading the project from printing them.
+	qctx := *ctx
+	qctx.Err = log.New(ioutil.Discard, "", 0)
+	var p *dep.Project
+	var err error
+	if cmd.fix {
+		// Fixing vendor/ changes the project, so is kept apart from other
+		// dep processes doing so.
+		var unlock func()
+		p, unlock, err = qctx.LoadLockedProject()
+		if err == nil {
+			defer unlock()
+		}
+	} else {
+		p, err = qctx.LoadProject()
+	}
+	ctx.GOPATH = qctx.GOPATH
+	if err != nil {
+		return err
+	}
+
+	// Sources are only needed to fix vendor/, so the source manager, and the
+	// lock on the cache, are only taken if they are.
+	sm, err := ctx.LazySourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	digest, directDeps, err := p.InputsDigest(sm)
+	if err != nil {
+		return errors.Wrap(err, "could not determine the project's direct dependencies")
+	}
+
+	mf, err := os.Open(filepath.Join(p.AbsRoot, dep.ManifestName))
+	if err != nil {
+		return err
+	}
+	defer mf.Close()
+
+	mv, err := dep.ValidateManifest(mf, directDeps)
+	if err != nil {
+		return errors.Wrapf(err, "error while parsing %s", dep.ManifestName)
+	}
+
+	for _, issue := range mv.Errors {
+		ctx.Out.Printf("%s: error: %s\n", dep.ManifestName, issue)
+	}
+	for _, issue := range mv.Warnings {
+		ctx.Out.Printf("%s: warning: %s\n", dep.ManifestName, issue)
+	}
+
+	stale := p.Lock != nil && !bytes.Equal(p.Lock.InputsDigest(), digest)
+	if stale {
+		ctx.Out.Printf("%s: error: out of sync with %s or the project's imports, run \"dep ensure\" to update it\n", dep.LockName, dep.ManifestName)
+	}
+
+	// The pinned digests and required signatures are not among the inputs
+	// that the lock is in sync with, so are checked on their own.
+	var perr error
+	if p.Lock != nil {
+		if perr = checkLock(ctx, p, sm, p.Lock, false); perr != nil {
+			ctx.Out.Printf("%s: error: %s\n", dep.LockName, perr)
+		}
+	}
+
+	verrs, vwarns, err := cmd.checkVendor(ctx, p, sm)
+	if err != nil {
+		return err
+	}
+
+	var serrs, swarns int
+	if cmd.goSum != "" {
+		if serrs, swarns, err = cmd.checkGoSum(ctx, p, sm); err != nil {
+			return err
+		}
+	}
+
+	if len(mv.Errors) > 0 {
+		return errors.Errorf("%s has %d error(s)", dep.ManifestName, len(mv.Errors))
+	}
+	if stale {
+		return errors.Errorf("%s is out of sync", dep.LockName)
+	}
+	if perr != nil {
+		return errors.Errorf("%s fails the checks of %s", dep.LockName, dep.ManifestName)
+	}
+	if verrs > 0 {
+		return errors.Errorf("vendor/ has %d error(s)", verrs)
+	}
+	if serrs > 0 {
+		return errors.Errorf("%s diverges from %s in %d module(s)", dep.LockName, cmd.goSum, serrs)
+	}
+	if cmd.strict && len(mv.Warnings) > 0 {
+		return errors.Errorf("%s has %d warning(s)", dep.ManifestName, len(mv.Warnings))
+	}
+	if cmd.strict && vwarns > 0 {
+		return errors.Errorf("vendor/ has %d warning(s)", vwarns)
+	}
+	if cmd.strict && swarns > 0 {
+		return errors.Errorf("%s has %d warning(s) against %s", dep.LockName, swarns, cmd.goSum)
+	}
+	if ctx.Verbose && len(mv.Warnings) == 0 && vwarns == 0 && swarns == 0 {
+		ctx.Out.Printf("%s has no problems\n", dep.ManifestName)
+	}
+	return nil
+}
+
+// checkVendor checks p's vendor directory, if it has one, against the digests
+// in p's lock, reports the problems it finds, and returns how many errors and
+// warnings there were. With -fix, the projects it can fix are not counted.
+func (cmd *checkCommand) checkVendor(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) (int, int, error) {
+	if p.Lock == nil {
+		return 0, 0, nil
+	}
+	if _, err := os.Stat(filepath.Join(p.AbsRoot, "vendor")); os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+
+	status, err := p.VerifyVendor()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if cmd.fix {
+		fixes, err := p.FixVendor(sm, status)
+		for _, fix := range fixes {
+			reportVendorFix(ctx, fix)
+			status[string(fix.ProjectRoot)] = pkgtree.NoMismatch
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	paths := make([]string, 0, len(status))
+	for path := range status {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs, warns int
+	for _, path := range paths {
+		switch st := status[path]; st {
+		case pkgtree.NotInTree, pkgtree.DigestMismatchInLock:
+			ctx.Out.Printf("vendor/%s: error: %s\n", path, st)
+			errs++
+		case pkgtree.NotInLock, pkgtree.EmptyDigestInLock:
+			ctx.Out.Printf("vendor/%s: warning: %s\n", path, st)
+			warns++
+		}
+	}
+	return errs, warns, nil
+}
+
+// checkGoSum checks p's lock against the go.sum named by -go-sum, reports the
+// divergences it finds, and returns how many errors and warnings there were.
+func (cmd *checkCommand) checkGoSum(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) (int, int, error) {
+	if p.Lock == nil {
+		return 0, 0, nil
+	}
+	path := cmd.goSum
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.WorkingDir, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "could not read %s", path)
+	}
+	sums, err := parseGoSum(data)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "could not parse %s", path)
+	}
+
+	sources, errs, warns := goSumSources(p.Lock, sums)
+	hashes, err := hashModules(sm, sources)
+	if err != nil {
+		return 0, 0, err
+	}
+	errs = append(errs, compareGoSum(hashes, sums)...)
+	sort.Strings(errs)
+
+	for _, issue := range errs {
+		ctx.Out.Printf("%s: error: %s\n", cmd.goSum, issue)
+	}
+	for _, issue := range warns {
+		ctx.Out.Printf("%s: warning: %s\n", cmd.goSum, issue)
+	}
+	return len(errs), len(warns), nil
+}
+
+// goSumHashes maps the modules in a go.sum file, by path, to the hashes it
+// records for each of their versions, and for their go.mod files at each, as
+// the version suffixed with "/go.mod".
+type goSumHashes map[string]map[string]string
+
+// parseGoSum parses the go.sum file data.
+func parseGoSum(data []byte) (goSumHashes, error) {
+	sums := make(goSumHashes)
+	for i, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 3 {
+			return nil, errors.Errorf("line %d: malformed entry %q", i+1, line)
+		}
+		if sums[f[0]] == nil {
+			sums[f[0]] = make(map[string]string)
+		}
+		sums[f[0]][f[1]] = f[2]
+	}
+	return sums, nil
+}
+
+// goSumSources returns the versions of modules that the projects in l are
+// locked to, whose hashes are to be compared with those in sums, along with
+// the errors and warnings about projects that sums has no hashes for.
+// Projects locked to revisions are compared at the pseudo-versions of their
+// revisions that sums has.
+func goSumSources(l *dep.Lock, sums goSumHashes) ([]moduleSource, []string, []string) {
+	var (
+		sources     []moduleSource
+		errs, warns []string
+	)
+	for _, lp := range l.Projects() {
+		id := lp.Ident()
+		path := string(id.ProjectRoot)
+		if id.Source != "" && id.Source != path {
+			src, local := sourceModulePath(id.Source)
+			if local {
+				continue
+			}
+			path = src
+		}
+
+		versions := sums[path]
+		version, canonical := moduleVersion(id.ProjectRoot, lp.Version())
+		if !canonical && len(version) >= 12 {
+			// A pseudo-version ends with the first twelve characters of
+			// its revision.
+			for v := range versions {
+				if v = strings.TrimSuffix(v, "/go.mod"); strings.HasSuffix(v, "-"+version[:12]) {
+					version = v
+					break
+				}
+			}
+		}
+		_, has := versions[version]
+		_, hasMod := versions[version+"/go.mod"]
+		switch {
+		case has || hasMod:
+			sources = append(sources, moduleSource{path: path, version: version, id: id, v: lp.Version()})
+		case len(versions) > 0:
+			errs = append(errs, fmt.Sprintf("%s: locked at %s, but go.sum only has %s", path, version, strings.Join(goSumVersions(versions), ", ")))
+		default:
+			warns = append(warns, fmt.Sprintf("%s: locked at %s, but not in go.sum", path, version))
+		}
+	}
+	return sources, errs, warns
+}
+
+// goSumVersions returns the versions that versions, the hashes of a module in
+// a go.sum file, are recorded for, in order.
+func goSumVersions(versions map[string]string) []string {
+	var vs []string
+	for v := range versions {
+		if !strings.HasSuffix(v, "/go.mod") {
+			vs = append(vs, v)
+		} else if _, has := versions[strings.TrimSuffix(v, "/go.mod")]; !has {
+			vs = append(vs, strings.TrimSuffix(v, "/go.mod"))
+		}
+	}
+	sort.Strings(vs)
+	return vs
+}
+
+// compareGoSum returns the errors about hashes that differ from those that
+// sums records for the same versions of modules.
+func compareGoSum(hashes []moduleHash, sums goSumHashes) []string {
+	var errs []string
+	for _, h := range hashes {
+		for _, c := range []struct{ version, hash string }{
+			{h.version, h.sum},
+			{h.version + "/go.mod", h.modSum},
+		} {
+			if want, has := sums[h.path][c.version]; has && want != c.hash {
+				errs = append(errs, fmt.Sprintf("%s %s: go.sum has %s, but the locked revision hashes to %s", h.path, c.version, want, c.hash))
+			}
+		}
+	}
+	return errs
+}
+
+// reportVendorFix reports a vendored project that was written out again, and
+// the files in which its altered contents differed: added (+), removed (-)
+// and modified (M).
+func reportVendorFix(ctx *dep.Ctx, fix dep.VendorFix) {
+	if fix.Quarantined == "" {
+		ctx.Out.Printf("vendor/%s: fixed: written out again\n", fix.ProjectRoot)
+		return
+	}
+	ctx.Out.Printf("vendor/%s: fixed: modified contents moved to %s\n", fix.ProjectRoot, fix.Quarantined)
+	for _, f := range []struct {
+		mark  string
+		paths []string
+	}{{"+", fix.Added}, {"-", fix.Removed}, {"M", fix.Modified}} {
+		for _, path := range f.paths {
+			ctx.Out.Printf("\t%s %s\n", f.mark, path)
+		}
+	}
+}
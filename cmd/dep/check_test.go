@@ -0,0 +1,93 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestParseGoSum(t *testing.T) {
+	sums, err := parseGoSum([]byte(`github.com/foo/bar v1.2.0 h1:abc=
+github.com/foo/bar v1.2.0/go.mod h1:def=
+
+github.com/foo/baz v0.1.0/go.mod h1:ghi=
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := goSumHashes{
+		"github.com/foo/bar": {"v1.2.0": "h1:abc=", "v1.2.0/go.mod": "h1:def="},
+		"github.com/foo/baz": {"v0.1.0/go.mod": "h1:ghi="},
+	}
+	if !reflect.DeepEqual(sums, want) {
+		t.Errorf("unexpected hashes:\n\t(GOT): %v\n\t(WNT): %v", sums, want)
+	}
+
+	if _, err := parseGoSum([]byte("github.com/foo/bar v1.2.0\n")); err == nil {
+		t.Error("expected a malformed go.sum to be an error")
+	}
+}
+
+func TestGoSumSources(t *testing.T) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	l := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.2.0").Pair(rev), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewBranch("master").Pair(rev), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/old"}, gps.NewVersion("v0.3.0").Pair(rev), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/qux"}, gps.NewVersion("v1.0.0").Pair(rev), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/local", Source: "./local"}, rev, nil),
+		},
+	}
+	sums := goSumHashes{
+		"github.com/foo/bar": {"v1.2.0": "h1:a=", "v1.2.0/go.mod": "h1:b="},
+		"github.com/foo/baz": {"v0.0.0-20180102150405-8a6d0e6c2c5f/go.mod": "h1:c="},
+		"github.com/foo/old": {"v0.4.0": "h1:d=", "v0.4.0/go.mod": "h1:e=", "v0.2.0/go.mod": "h1:f="},
+	}
+
+	sources, errs, warns := goSumSources(l, sums)
+	var got []string
+	for _, src := range sources {
+		got = append(got, src.path+" "+src.version)
+	}
+	want := []string{
+		"github.com/foo/bar v1.2.0",
+		"github.com/foo/baz v0.0.0-20180102150405-8a6d0e6c2c5f",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected modules to hash:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+	wantErrs := []string{"github.com/foo/old: locked at v0.3.0, but go.sum only has v0.2.0, v0.4.0"}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Errorf("unexpected errors:\n\t(GOT): %v\n\t(WNT): %v", errs, wantErrs)
+	}
+	wantWarns := []string{"github.com/foo/qux: locked at v1.0.0, but not in go.sum"}
+	if !reflect.DeepEqual(warns, wantWarns) {
+		t.Errorf("unexpected warnings:\n\t(GOT): %v\n\t(WNT): %v", warns, wantWarns)
+	}
+}
+
+func TestCompareGoSum(t *testing.T) {
+	hashes := []moduleHash{
+		{moduleSource: moduleSource{path: "github.com/foo/bar", version: "v1.2.0"}, sum: "h1:a=", modSum: "h1:b="},
+		{moduleSource: moduleSource{path: "github.com/foo/baz", version: "v0.1.0"}, sum: "h1:c=", modSum: "h1:d="},
+	}
+	sums := goSumHashes{
+		"github.com/foo/bar": {"v1.2.0": "h1:a=", "v1.2.0/go.mod": "h1:b="},
+		// Only the go.mod hash of a module the build needs no packages of is
+		// recorded.
+		"github.com/foo/baz": {"v0.1.0/go.mod": "h1:x="},
+	}
+
+	errs := compareGoSum(hashes, sums)
+	want := []string{"github.com/foo/baz v0.1.0/go.mod: go.sum has h1:x=, but the locked revision hashes to h1:d="}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("unexpected errors:\n\t(GOT): %v\n\t(WNT): %v", errs, want)
+	}
+}
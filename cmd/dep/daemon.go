@@ -0,0 +1,146 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"expvar"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/metrics"
+	"github.com/pkg/errors"
+)
+
+const daemonShortHelp = `Serve the source cache to other dep commands`
+const daemonLongHelp = `
+Run a source manager daemon: a long-running process that holds the source
+cache, along with the import paths it has deduced and the versions it has
+listed, and serves them over a unix socket until it is interrupted or
+terminated.
+
+dep commands run with $DEPDAEMON set to the path of the socket make their calls
+to the daemon, instead of each locking the cache and discovering the same
+sources, versions and vanity import paths afresh. This speeds up running dep
+repeatedly, such as over the many projects of a monorepo, and lets several dep
+commands run at once without waiting for the cache's lock. Commands that cannot
+reach the daemon manage the cache themselves, as they do without it.
+
+The socket is $DEPCACHEDIR/dep.sock, or $GOPATH/pkg/dep/dep.sock by default.
+The daemon holds the cache's lock for as long as it runs, and uses the
+configuration it was started with.
+
+With -debug-addr, the daemon also serves the metrics of the work it has done,
+such as its source cache hits and misses, over HTTP on that address: through
+expvar at /debug/vars, and in the Prometheus text format at /metrics.
+`
+
+type daemonCommand struct {
+	socket    string
+	debugAddr string
+}
+
+func (cmd *daemonCommand) Name() string      { return "daemon" }
+func (cmd *daemonCommand) Args() string      { return "[-socket path] [-debug-addr addr]" }
+func (cmd *daemonCommand) ShortHelp() string { return daemonShortHelp }
+func (cmd *daemonCommand) LongHelp() string  { return daemonLongHelp }
+func (cmd *daemonCommand) Hidden() bool      { return false }
+
+func (cmd *daemonCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.socket, "socket", "", "the path of the unix socket to listen on (default: dep.sock in the cache directory)")
+	fs.StringVar(&cmd.debugAddr, "debug-addr", "", "serve the daemon's metrics over HTTP on this address")
+}
+
+func (cmd *daemonCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep daemon takes no arguments")
+	}
+
+	// The daemon manages the cache itself.
+	ctx.Daemon = ""
+
+	sock := cmd.socket
+	if sock == "" {
+		cachedir := ctx.Cachedir
+		if cachedir == "" {
+			cachedir = filepath.Join(ctx.GOPATH, "pkg", "dep")
+		}
+		sock = filepath.Join(cachedir, "dep.sock")
+	}
+	sock, err := filepath.Abs(sock)
+	if err != nil {
+		return err
+	}
+	if conn, err := net.Dial("unix", sock); err == nil {
+		conn.Close()
+		return errors.Errorf("a daemon is already listening on %s", sock)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	defer sm.Release()
+
+	// A socket left behind by a daemon that did not exit cleanly is in the
+	// way; nothing is listening on it.
+	os.Remove(sock)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return errors.Wrap(err, "unable to listen for dep commands")
+	}
+
+	if cmd.debugAddr != "" {
+		dl, err := net.Listen("tcp", cmd.debugAddr)
+		if err != nil {
+			l.Close()
+			return errors.Wrap(err, "unable to listen for metrics requests")
+		}
+		defer dl.Close()
+		go http.Serve(dl, metricsHandler())
+		ctx.Out.Printf("Serving metrics on http://%s/metrics\n", dl.Addr())
+	}
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigch)
+	stopped := make(chan struct{})
+	go func() {
+		<-sigch
+		close(stopped)
+		l.Close()
+	}()
+
+	ctx.Out.Printf("Serving the source cache in %s on %s; to use it, set:\n", sm.Cachedir(), sock)
+	ctx.Out.Printf("  export DEPDAEMON=%s\n", sock)
+	err = gps.ServeSourceManager(l, sm, dep.Analyzer{})
+	select {
+	case <-stopped:
+		// Stopped by a signal, as the daemon is meant to be.
+		return nil
+	default:
+		l.Close()
+		return errors.Wrap(err, "the daemon stopped listening")
+	}
+}
+
+// metricsHandler serves the metrics dep records through expvar, at
+// /debug/vars, and in the Prometheus text format, at /metrics.
+func metricsHandler() http.Handler {
+	metrics.Default.PublishExpvar("dep")
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.Default.WriteText(w)
+	})
+	return mux
+}
@@ -11,11 +11,29 @@
 //
 // Commands:
 //
-//   init     Initialize a new project with manifest and lock files
-//   status   Report the status of the project's dependencies
-//   ensure   Ensure a dependency is safely vendored in the project
-//   prune    Prune the vendor tree of unused packages
-//   version  Show the dep version information
+//   init              Initialize a new project with manifest and lock files
+//   status            Report the status of the project's dependencies
+//   explain           Explain which constraint governs an import
+//   list              List the project's dependencies as go list -m does
+//   ensure            Ensure a dependency is safely vendored in the project
+//   check             Check the manifest, lock and vendor/ for problems
+//   prune             Prune the vendor tree of unused packages
+//   lock              Sign Gopkg.lock, or verify its signature
+//   sbom              Write a software bill of materials for the project
+//   graph             Write the dependency graph as DOT, JSON or GraphML
+//   report            Write an HTML report of the dependencies and their licenses
+//   stamp             Write a Go file stamping the build with the locked versions
+//   export            Write go.mod and go.sum, or legacy tool, files from Gopkg.lock
+//   bazel             Write Bazel go_repository rules for Gopkg.lock
+//   archive           Write the vendor tree of the project to a tar archive
+//   merge-lock        Merge conflicting versions of Gopkg.lock
+//   migrate-manifest  Upgrade Gopkg.toml to the current layout
+//   fmt               Rewrite Gopkg.lock in its canonical form
+//   cache             Manage the source cache
+//   hook              Install a git hook that runs dep check
+//   daemon            Serve the source cache to other dep commands
+//   serve             Answer queries about the project over JSON-RPC
+//   version           Show the dep version information
 //
 // Examples:
 //   dep init                               set up a new project
@@ -82,14 +100,68 @@
 //   TODO    Another column description
 //   FOOBAR  Another column description
 //
+// With -lock-only, the status is reported from Gopkg.toml and Gopkg.lock alone:
+// the root project's packages are not analyzed, so whether the lock is still in
+// sync with its imports is not checked, and the constraints dependencies place on
+// one another are shown only for those named in Gopkg.toml. On a large project
+// this is much faster.
+//
 // Status returns exit code zero if all dependencies are in a "good state".
 //
 //
+// Explain which constraint governs an import
+//
+// Usage:
+//
+//  explain [-json] <import-path>
+//
+// Explain how the version of the project providing an import path is chosen:
+// the project's root, the constraint that governs it and the stanza of
+// Gopkg.toml it comes from, the override that replaces it, if any, the
+// constraints the project's dependents place on it, the version it is locked
+// to, and the latest version the constraint allows.
+//
+// Without an override, a project must satisfy both the [[constraint]] in
+// Gopkg.toml, which applies only if the project is imported directly, and the
+// constraints of the dependencies that import it. An [[override]] replaces all
+// of them.
+//
+// With -json, the explanation is written as a JSON object.
+//
+//
+// List the project's dependencies as go list -m does
+//
+// Usage:
+//
+//  list -m [-json | -f format] [all | <module>...]
+//
+// List the project, and its dependencies in Gopkg.lock, as modules, in the
+// forms "go list -m" writes them, so that scripts written for Go modules work
+// on dep projects unchanged.
+//
+// With no arguments, only the project itself, the main module, is listed. With
+// "all", every locked project is listed after it; otherwise, the modules named
+// are. Each is listed by its path and version, and the module it is replaced
+// with, if any, as "dep export" would write them to go.mod:
+//
+//   example.com/app
+//   github.com/foo/bar v1.2.0
+//   github.com/foo/fork v0.3.0 => github.com/fork/fork v0.3.0
+//
+// dep does not record the times of revisions, so projects locked to a branch or
+// bare revision are listed at a pseudo-version with the zero time, such as
+// v0.0.0-00010101000000-8a6d0e6c2c5f.
+//
+// With -json, each module is written as a JSON object with the fields Path,
+// Version, Replace, Main, Indirect and Dir, as go list -m -json writes them. With
+// -f, each is written with the given Go template, applied to the same fields.
+//
+//
 // Ensure a dependency is safely vendored in the project
 //
 // Usage:
 //
-//  ensure [-update | -add] [-no-vendor | -vendor-only] [-dry-run] [<spec>...]
+//  ensure [-update [-group <groups>] | -add] [-no-vendor | -vendor-only [-from-lock-stdin]] [-materialize] [-dry-run] [-no-hooks] [-v] [<spec>...]
 //
 // Project spec:
 //
@@ -107,7 +179,16 @@
 // unnecessary. If that determination is made, ensure may skip some steps. Flags
 // may be passed to bypass these checks; -vendor-only will allow an out-of-date
 // Gopkg.lock to populate vendor/, and -no-vendor will update Gopkg.lock (if
-// needed), but never touch vendor/.
+// needed), but never touch vendor/. With -from-lock-stdin, -vendor-only reads
+// the lock from standard input instead of Gopkg.lock.
+//
+// Commands listed in the [hooks] table of Gopkg.toml are run before (pre-ensure)
+// and after (post-ensure) ensure does its work. Pass -no-hooks, or set
+// DEPNOHOOKS, to skip them.
+//
+// If DEPVENDORSYMLINKS is set, the projects in vendor/ are symlinks to pruned,
+// read-only trees in the cache, rather than copies. -materialize writes them out
+// as copies again, as they should be before vendor/ is committed or released.
 //
 // The effect of passing project spec arguments varies slightly depending on the
 // combination of flags that are passed.
@@ -122,6 +203,51 @@
 // For more detailed usage examples, see dep ensure -examples.
 //
 //
+// Check the manifest, lock and vendor/ for problems
+//
+// Usage:
+//
+//  check [-strict] [-fix] [-go-sum file]
+//
+// Check Gopkg.toml for problems, Gopkg.lock against Gopkg.toml and the project's
+// imports, and vendor/, if it exists, against the digests recorded in
+// Gopkg.lock, and report them.
+//
+// Errors, such as malformed values, prevent dep from using the manifest at all.
+// Warnings point out rules that are likely mistakes: unknown fields, versions
+// that are not valid semver ranges, constraints on projects that are not
+// imported, and overrides that overlap each other or a constraint.
+//
+// A Gopkg.lock solved from other constraints or imports than the project now has
+// is an error, as "dep ensure" would change it. This is checked without fetching
+// any sources.
+//
+// Vendored projects that are missing, or that have been modified since dep
+// wrote them out, are errors. Paths in vendor/ that Gopkg.lock does not account
+// for, and projects that Gopkg.lock has no digest for, are warnings.
+//
+// With -fix, each missing or modified project is written out again from the
+// source cache. The modified contents are first moved into .quarantine/ in the
+// project's root, so that they can be inspected, and the files that differed
+// are reported.
+//
+// With -go-sum, Gopkg.lock is also checked against the go.sum of a build of the
+// project with Go modules, for teams building with both while they migrate.
+// Each locked project is hashed as the go command hashes modules, from the
+// source cache, and compared with the hashes go.sum records for its version, or,
+// for projects locked to a revision, for the pseudo-version of that revision:
+//
+//   hashes that differ                      error, the builds use different code
+//   other versions of the module in go.sum  error, the modules build selects one
+//   no versions of the module in go.sum     warning, the modules build may not
+//                                           need it
+//
+// Projects locked to local directories are not checked.
+//
+// dep check exits non-zero if there are errors. With -strict, it also exits
+// non-zero if there are warnings, which makes it suitable for use in CI.
+//
+//
 // Prune the vendor tree of unused packages
 //
 // Usage:
@@ -134,6 +260,422 @@
 // such, it may be removed and/or moved out into a separate project later on.
 //
 //
+// Sign Gopkg.lock, or verify its signature
+//
+// Usage:
+//
+//  lock [-tool gpg|minisign] [-key key] sign|verify
+//
+// Produce or check a detached signature over Gopkg.lock.
+//
+//   sign     Sign Gopkg.lock, writing the signature next to it
+//   verify   Verify the signature next to Gopkg.lock
+//
+// The signature is made over the canonical form of Gopkg.lock - the lock as dep
+// would write it, without its leading comment - with either gpg or minisign.
+// gpg signatures are written to Gopkg.lock.asc, and minisign signatures to
+// Gopkg.lock.minisig.
+//
+// The -key flag selects the key to use: a gpg key to sign with, or that the
+// signature must have been made by, or a minisign secret key file to sign with
+// or public key file to verify with. When it is omitted, the tool's default key
+// is used to sign; gpg then accepts a signature by any key in the keyring, so
+// pass -key to pin the signer when verifying. The signer is pinned by the full
+// fingerprint of its key, or by the whole of its primary user ID or the email
+// address in it; key IDs are not accepted.
+//
+// To have dep ensure refuse to run against a lock that was altered after it was
+// signed, verify the signature in a pre-ensure hook:
+//
+//   [hooks]
+//     pre-ensure = ["dep lock verify"]
+//
+//
+// Write a software bill of materials for the project
+//
+// Usage:
+//
+//  sbom [-format spdx|cyclonedx|github]
+//
+// Write a software bill of materials, listing every project in Gopkg.lock, to
+// standard output.
+//
+// Each project is listed with the version and revision it is locked to, the URL
+// of its source, its licenses, the SHA-256 digest of its vendored contents as
+// recorded in Gopkg.lock, and the other projects whose packages its packages
+// import. Licenses and imports are found in each project in vendor/, or, for
+// projects that are not vendored, in its locked version in the source cache.
+//
+// The -format flag selects the format of the bill of materials:
+//
+//   spdx        An SPDX 2.3 document, in JSON
+//   cyclonedx   A CycloneDX 1.4 BOM, in JSON
+//   github      A snapshot for GitHub's dependency submission API
+//
+// A GitHub snapshot is of the commit named by $GITHUB_SHA and $GITHUB_REF, as set
+// in GitHub Actions, or else of the commit checked out in the project's git
+// repository. It is correlated with other snapshots by $GITHUB_WORKFLOW,
+// $GITHUB_JOB and the path of Gopkg.lock in the repository, and identified by
+// $GITHUB_RUN_ID.
+//
+//
+// Write the dependency graph as DOT, JSON or GraphML
+//
+// Usage:
+//
+//  graph [-format dot|json|graphml] [-packages]
+//
+// Write the graph of the project and the projects in Gopkg.lock: a node for
+// each project, with its locked version, and an edge from each project to each
+// project it imports, with the number of imports of the packages of one by the
+// packages of the other. With -packages, the graph is of the locked packages
+// instead, with an edge for each import. Only the packages of dependencies that
+// are vendored are included, while all of the project's own packages, and their
+// tests, are.
+//
+// The -format flag sets the format written:
+//
+//   dot       GraphViz DOT, as dep status -dot writes (the default)
+//   json      a JSON object, whose schema is described in docs/daily-dep.md
+//   graphml   GraphML, with the nodes' versions and edges' import counts
+//             as data
+//
+// The package trees of the locked projects are read from the source cache,
+// fetching the sources that are not in it.
+//
+//
+// Write an HTML report of the dependencies and their licenses
+//
+// Usage:
+//
+//  report -html
+//
+// Write a report of the project's dependencies, as locked in Gopkg.lock, as a
+// self-contained HTML page, for attaching to releases to comply with the
+// dependencies' licenses. The report lists each dependency, with its version, its
+// revision, its source, and the licenses detected in it, and gives the text of
+// its license files and other legal files, such as NOTICE and AUTHORS files: the
+// files that pruning keeps, at the root of its tree.
+//
+// The trees are read from vendor/, or from the source cache, fetching the
+// sources that are not in it, for the dependencies that are not vendored.
+//
+// -html is required; it is the only format of the report.
+//
+//
+// Write a Go file stamping the build with the locked versions
+//
+// Usage:
+//
+//  stamp [-o file] [-package name] [-check]
+//
+// Write a Go source file declaring the versions of the project's dependencies,
+// as locked in Gopkg.lock, as constants, so that the binaries built from it can
+// report exactly which revisions of their dependencies they were built from:
+//
+//   DepLockDigest   the SHA-256 digest of Gopkg.lock in canonical form, which
+//                   changes with the version of any dependency
+//   DepVersions     a line for each locked project, of its root, its version,
+//                   or else its branch or revision, and its revision
+//
+// The file is written to depstamp.go in the current directory, or to the path
+// given with -o. It is in the package of the Go files already in its directory,
+// or else the package named for its directory, unless -package names another.
+// It is only rewritten when the lock changes, and is conveniently kept up to
+// date with a go:generate directive, or a post-ensure hook:
+//
+//   //go:generate dep stamp
+//
+// With -check, the file is not written; instead, dep stamp exits non-zero if it
+// is out of date, which makes it suitable for use in CI.
+//
+//
+// Write go.mod and go.sum, or legacy tool, files from Gopkg.lock
+//
+// Usage:
+//
+//  export [-format modules|glide|godep] [-dir dir] [-force]
+//
+// Write a go.mod and a go.sum file for the project, from the projects in
+// Gopkg.lock and the overrides in Gopkg.toml, so that the project can be built
+// with Go modules as it is with dep.
+//
+// Every locked project is required at its locked version: semver tags as they
+// are, with +incompatible where the go command needs it, and branches and bare
+// revisions as the locked revision, which the go command turns into a
+// pseudo-version when "go mod tidy" is run. Projects that the project's own
+// packages do not import are marked // indirect.
+//
+// Projects locked to an alternate source are replaced with that source, at the
+// same version. Overridden projects are replaced with themselves at their
+// locked version, so that other modules cannot raise them.
+//
+// go.sum is filled in with the hashes of the locked versions of the projects,
+// as found in the source cache, and fetched into it if they are not there. Only
+// projects locked to semver tags can be listed in go.sum, as the go command
+// names the others by pseudo-versions.
+//
+// With -format, the project is exported for an older tool instead:
+//
+//   modules   go.mod and go.sum, as above
+//   glide     glide.yaml and glide.lock
+//   godep     Godeps/Godeps.json
+//
+// glide.yaml lists the projects the project's packages import, with their
+// constraints or overrides from Gopkg.toml, and the ignored packages; glide.lock,
+// and Godeps.json, the locked revision of every project in Gopkg.lock. godep has
+// no notion of alternate sources, so projects locked to one are fetched from
+// their import path.
+//
+// The files are written to the project's root, unless -dir is given. Existing
+// files are only replaced with -force.
+//
+//
+// Write Bazel go_repository rules for Gopkg.lock
+//
+// Usage:
+//
+//  bazel [-workspace file | -macro file.bzl%macro]
+//
+// Write a go_repository rule, as defined by Gazelle, for every project in
+// Gopkg.lock, so that Bazel builds the project with the same dependencies as dep.
+//
+// Projects locked to semver tags are fetched as modules, by their version and
+// the sum that go.sum would record for it, which is computed from the locked
+// version in the source cache. Other projects are fetched by their locked
+// commit. Projects locked to an alternate source are fetched from it: as a
+// replacement module, or from its remote, which is taken to be a git
+// repository.
+//
+// Projects whose non-Go files are pruned from vendor/ are given the
+// build_file_proto_mode "disable", so that Gazelle builds them from their
+// generated .pb.go files, as dep does, rather than from their .proto files.
+//
+// The rules are written to standard output, unless -workspace or -macro is
+// given. -workspace names a WORKSPACE file, in which the rules are written in
+// place of those written to it before, between the lines
+//
+//   # BEGIN dep go_repository rules
+//   # END dep go_repository rules
+//
+// or appended to it the first time, so that running dep bazel -workspace after
+// every change to Gopkg.lock keeps the two in lockstep. The file must load
+// go_repository from @bazel_gazelle//:deps.bzl before the rules.
+//
+// -macro names a file, relative to the project root, and a macro, as
+// "file.bzl%macro", as Gazelle's update-repos -to_macro does. The file is
+// written in full, in the form update-repos gives it, defining only the macro,
+// which declares the rules. WORKSPACE loads the macro from the file, and calls
+// it. If Gopkg.toml names a macro in its bazel-macro field, dep ensure writes it
+// whenever it writes Gopkg.lock.
+//
+//
+// Write the vendor tree of the project to a tar archive
+//
+// Usage:
+//
+//  archive [-o file]
+//
+// Write a tar archive holding the vendor tree of the project, as dep ensure would
+// write it for Gopkg.lock, pruned as Gopkg.toml says, with its entries under
+// vendor/. The archive is written to the file named by -o, or to standard output.
+//
+// The archive is reproducible: for the same Gopkg.lock and prune options, it is
+// the same, byte for byte, whenever and on whichever machine it is written. Its
+// entries are sorted by name, and carry no modification times or owners; files
+// are 0644, or 0755 if they are executable, and directories are 0755. This makes
+// it suitable for checksumming, and for caching in build systems.
+//
+//
+// Merge conflicting versions of Gopkg.lock
+//
+// Usage:
+//
+//  merge-lock <base> <ours> <theirs> [path]
+//
+// Perform a three-way merge of Gopkg.lock, as a git merge driver.
+//
+// <base>, <ours> and <theirs> are the common ancestor and the two versions of
+// Gopkg.lock to merge. The result is written to <ours>. [path] is the path of
+// Gopkg.lock in the working tree, which is used to find the project; it defaults
+// to the Gopkg.lock in the current directory.
+//
+// Projects that were changed on only one side are taken from that side. Projects
+// that were changed differently on both sides are solved for again, with every
+// other project kept at its merged version. If nothing was disputed, and the
+// merge matches one side, no solve is needed.
+//
+// To use it, declare the driver in your git config:
+//
+//   [merge "dep-lock"]
+//     name = dep Gopkg.lock merge driver
+//     driver = dep merge-lock %O %A %B %P
+//
+// and assign it to Gopkg.lock in .gitattributes:
+//
+//   Gopkg.lock merge=dep-lock
+//
+// After a merge, run dep ensure to bring vendor/ in line with the merged lock.
+//
+//
+// Upgrade Gopkg.toml to the current layout
+//
+// Usage:
+//
+//  migrate-manifest [-dry-run]
+//
+// Upgrade Gopkg.toml from an older layout to the one this version of dep uses,
+// and record the layout's version in its schema-version field.
+//
+// dep reads manifests with an older layout by upgrading them as they are read,
+// and warns that they should be migrated. A manifest with a newer schema-version
+// than dep supports is rejected, rather than misread.
+//
+// The manifest is edited in place, so that its comments and formatting are kept.
+// With -dry-run, the upgraded manifest is printed instead.
+//
+//
+// Rewrite Gopkg.lock in its canonical form
+//
+// Usage:
+//
+//  fmt [-check]
+//
+// Rewrite Gopkg.lock in the canonical form that dep writes it in: projects
+// sorted by name, the packages of each project sorted and listed one per line,
+// and the fields of each project, its digest included, in a fixed order.
+//
+// A lock in canonical form changes by as few lines as possible as dependencies
+// change, which keeps diffs reviewable and merge conflicts rare. Locks that were
+// edited by hand, or merged by a tool other than dep merge-lock, may not be; dep
+// fmt puts them back in canonical form without changing their contents, so a
+// signature made with dep lock sign remains valid.
+//
+// With -check, Gopkg.lock is not rewritten; instead, dep fmt exits non-zero if
+// it is not in canonical form, which makes it suitable for use in CI.
+//
+//
+// Manage the source cache
+//
+// Usage:
+//
+//  cache gc|export|import|key [flags] [bundle]
+//
+// Manage the cache of the sources dep fetches projects from, in $DEPCACHEDIR, or
+// $GOPATH/pkg/dep by default.
+//
+// Subcommands:
+//
+//   gc                      Remove the sources that are no longer needed
+//   export [-lock file] <bundle>
+//                           Write the sources a lock needs to a bundle
+//   import [-replace] <bundle>
+//                           Add the sources in a bundle to the cache
+//   key                     Print a CI cache key for vendor/ and the cache
+//
+// dep cache gc removes the sources that the cache-gc policy of the user's
+// configuration says are no longer needed: those unused for longer than its
+// max-age, and then, least recently used first, as many as it takes to bring the
+// cache under its max-size. The sources of the projects in the keep-locks most
+// recently used locks are kept. The flags override the policy for this run.
+//
+// The cache is also garbage collected automatically, as often as the policy's
+// interval says, whenever dep releases it.
+//
+// dep cache export writes a bundle, a tar archive, holding the sources of the
+// projects in Gopkg.lock, or the lock named by -lock, with their locked
+// revisions. dep cache import adds the sources in a bundle to the cache of
+// another machine, keeping those already there unless -replace is given, so
+// that dep can solve and vendor the lock there without reaching the network.
+//
+// dep cache key prints a digest of everything that determines the contents of
+// vendor/: the source, revision and packages of every project in Gopkg.lock, the
+// prune options each is written with, and the version of dep. It changes exactly
+// when vendor/ would, and not with changes to Gopkg.toml that leave the lock as it
+// is, so it can be used as the key under which CI systems cache vendor/ and the
+// source cache.
+//
+//
+// Install a git hook that runs dep check
+//
+// Usage:
+//
+//  hook install [-pre-push] [-block errors|warnings|none] [-force]
+//
+// Install a git hook in the project's repository that runs "dep check" before
+// each commit, or, with -pre-push, before each push, and stops it if Gopkg.lock
+// or vendor/ is out of sync with Gopkg.toml and the project's imports.
+//
+// dep check fetches no sources, and the pre-commit hook only runs it when the
+// commit changes Go files, Gopkg.toml, Gopkg.lock or vendor/, so the hook adds
+// little to commits that do not.
+//
+// The -block flag sets what stops the commit or push:
+//
+//   errors     problems that dep check reports as errors (the default)
+//   warnings   warnings too, as with dep check -strict
+//   none       nothing; problems are reported, and the commit goes ahead
+//
+// A hook of the same name that dep did not install is only replaced with -force.
+// The hook can be skipped for a single commit or push with git's --no-verify.
+//
+//
+// Serve the source cache to other dep commands
+//
+// Usage:
+//
+//  daemon [-socket path] [-debug-addr addr]
+//
+// Run a source manager daemon: a long-running process that holds the source
+// cache, along with the import paths it has deduced and the versions it has
+// listed, and serves them over a unix socket until it is interrupted or
+// terminated.
+//
+// dep commands run with $DEPDAEMON set to the path of the socket make their calls
+// to the daemon, instead of each locking the cache and discovering the same
+// sources, versions and vanity import paths afresh. This speeds up running dep
+// repeatedly, such as over the many projects of a monorepo, and lets several dep
+// commands run at once without waiting for the cache's lock. Commands that cannot
+// reach the daemon manage the cache themselves, as they do without it.
+//
+// The socket is $DEPCACHEDIR/dep.sock, or $GOPATH/pkg/dep/dep.sock by default.
+// The daemon holds the cache's lock for as long as it runs, and uses the
+// configuration it was started with.
+//
+// With -debug-addr, the daemon also serves the metrics of the work it has done,
+// such as its source cache hits and misses, over HTTP on that address: through
+// expvar at /debug/vars, and in the Prometheus text format at /metrics.
+//
+//
+// Answer queries about the project over JSON-RPC
+//
+// Usage:
+//
+//  serve [-socket path]
+//
+// Serve queries about the project, for editors and other tools, over JSON-RPC
+// 2.0: one request or response per line, on standard input and output, or, with
+// -socket, on each connection to a unix socket, until it is closed.
+//
+// The project is loaded afresh for every request, so answers follow changes to
+// Gopkg.toml, Gopkg.lock and the project's code, while the source cache is held
+// by dep serve between requests, and only once a request needs it.
+//
+// The methods are:
+//
+//   status      The locked projects, with their versions and constraints.
+//   constraint  The project of the import path named by "import", and the
+//               constraint or override in Gopkg.toml governing it.
+//   stale       Whether Gopkg.lock is out of sync with Gopkg.toml and the
+//               project's imports, so that dep ensure would change it.
+//   why         The shortest chain of imports from a package of the project to
+//               the package or project named by "import".
+//
+// For example:
+//
+//   {"jsonrpc": "2.0", "id": 1, "method": "why", "params": {"import": "golang.org/x/net/context"}}
+//
+//
 // Show the dep version information
 //
 // Usage:
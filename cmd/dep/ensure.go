@@ -10,7 +10,6 @@ import (
 	"flag"
 	"fmt"
 	"go/build"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -21,6 +20,7 @@ import (
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/paths"
 	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/events"
 	"github.com/pkg/errors"
 )
 
@@ -42,7 +42,16 @@ Ensure has fast techniques to determine that some of these steps may be
 unnecessary. If that determination is made, ensure may skip some steps. Flags
 may be passed to bypass these checks; -vendor-only will allow an out-of-date
 Gopkg.lock to populate vendor/, and -no-vendor will update Gopkg.lock (if
-needed), but never touch vendor/.
+needed), but never touch vendor/. With -from-lock-stdin, -vendor-only reads
+the lock from standard input instead of Gopkg.lock.
+
+Commands listed in the [hooks] table of Gopkg.toml are run before (pre-ensure)
+and after (post-ensure) ensure does its work. Pass -no-hooks, or set
+DEPNOHOOKS, to skip them.
+
+If DEPVENDORSYMLINKS is set, the projects in vendor/ are symlinks to pruned,
+read-only trees in the cache, rather than copies. -materialize writes them out
+as copies again, as they should be before vendor/ is committed or released.
 
 The effect of passing project spec arguments varies slightly depending on the
 combination of flags that are passed.
@@ -70,6 +79,12 @@ dep ensure -vendor-only
     the lock is in sync with imports and Gopkg.toml. (This may be useful for
     e.g. strategically layering a Docker images)
 
+dep ensure -vendor-only -from-lock-stdin < Gopkg.lock
+
+    As above, reading the lock from standard input rather than from the project
+    root, which then only needs Gopkg.toml. Neither the project's Go code nor
+    its Gopkg.lock is read, or changed.
+
 dep ensure -add github.com/pkg/foo github.com/pkg/foo/bar
 
     Introduce one or more dependencies, at their newest version, ensuring that
@@ -99,6 +114,11 @@ dep ensure -update github.com/pkg/foo github.com/pkg/bar
     ignoring any versions recorded in Gopkg.lock. Write the results to
     Gopkg.lock and vendor/.
 
+dep ensure -update -group aws,observability
+
+    As above, updating the dependencies whose [[constraint]] in Gopkg.toml
+    places them in any of the named groups, e.g. with group = ["aws"].
+
 dep ensure -update
 
     Update all dependencies to the latest versions allowed by Gopkg.toml,
@@ -110,6 +130,16 @@ dep ensure -update -no-vendor
 
     As above, but only modify Gopkg.lock; leave vendor/ unchanged.
 
+DEPVENDORSYMLINKS=1 dep ensure -vendor-only
+
+    Replace the projects in vendor/ with symlinks to pruned trees in the cache,
+    which are shared by every project on the machine that uses them.
+
+dep ensure -materialize
+
+    Replace any symlinks in vendor/ with copies of the projects they point to,
+    as they should be before vendor/ is committed.
+
 dep ensure -no-vendor -dry-run
 
     This fails with a non zero exit code if Gopkg.lock is not up to date with
@@ -125,7 +155,7 @@ var (
 
 func (cmd *ensureCommand) Name() string { return "ensure" }
 func (cmd *ensureCommand) Args() string {
-	return "[-update | -add] [-no-vendor | -vendor-only] [-dry-run] [-v] [<spec>...]"
+	return "[-update [-group <groups>] | -add] [-no-vendor | -vendor-only [-from-lock-stdin]] [-materialize] [-dry-run] [-no-hooks] [-v] [<spec>...]"
 }
 func (cmd *ensureCommand) ShortHelp() string { return ensureShortHelp }
 func (cmd *ensureCommand) LongHelp() string  { return ensureLongHelp }
@@ -136,17 +166,25 @@ func (cmd *ensureCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.update, "update", false, "update the named dependencies (or all, if none are named) in Gopkg.lock to the latest allowed by Gopkg.toml")
 	fs.BoolVar(&cmd.add, "add", false, "add new dependencies, or populate Gopkg.toml with constraints for existing dependencies")
 	fs.BoolVar(&cmd.vendorOnly, "vendor-only", false, "populate vendor/ from Gopkg.lock without updating it first")
+	fs.BoolVar(&cmd.fromLockStdin, "from-lock-stdin", false, "with -vendor-only, read the lock from standard input rather than Gopkg.lock")
 	fs.BoolVar(&cmd.noVendor, "no-vendor", false, "update Gopkg.lock (if needed), but do not update vendor/")
 	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
+	fs.BoolVar(&cmd.noHooks, "no-hooks", false, "do not run the hooks declared in Gopkg.toml")
+	fs.StringVar(&cmd.groups, "group", "", "with -update, also update the dependencies in these comma-separated groups")
+	fs.BoolVar(&cmd.materialize, "materialize", false, "write vendor/ as copies of the projects in it, even if DEPVENDORSYMLINKS is set")
 }
 
 type ensureCommand struct {
-	examples   bool
-	update     bool
-	add        bool
-	noVendor   bool
-	vendorOnly bool
-	dryRun     bool
+	examples      bool
+	update        bool
+	add           bool
+	noVendor      bool
+	vendorOnly    bool
+	fromLockStdin bool
+	dryRun        bool
+	noHooks       bool
+	groups        string
+	materialize   bool
 }
 
 func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
@@ -159,10 +197,24 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
-	p, err := ctx.LoadProject()
+	if cmd.noHooks || cmd.dryRun {
+		ctx.DisableHooks = true
+	}
+
+	// The lock given on standard input stands in for Gopkg.lock, which is
+	// neither read, so that a broken one does not stop it being used, nor
+	// written.
+	ctx.IgnoreLock = cmd.fromLockStdin
+	p, unlock, err := ctx.LoadLockedProject()
 	if err != nil {
 		return err
 	}
+	defer unlock()
+	if cmd.fromLockStdin {
+		if p.Lock, err = dep.ReadLock(os.Stdin); err != nil {
+			return errors.Wrap(err, "error while parsing the lock on standard input")
+		}
+	}
 
 	sm, err := ctx.SourceManager()
 	if err != nil {
@@ -171,17 +223,41 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 	sm.UseDefaultSignalHandling()
 	defer sm.Release()
 
+	// Keep the sources of the locked projects from being garbage collected.
+	if p.Lock != nil {
+		sm.RecordLock(p.AbsRoot, p.Lock)
+	}
+
 	if err := dep.ValidateProjectRoots(ctx, p.Manifest, sm); err != nil {
 		return err
 	}
 
+	if err := ctx.RunHooks(p, dep.HookPreEnsure, dep.HookSummary{}); err != nil {
+		return err
+	}
+	oldLock := p.Lock
+
 	params := p.MakeParams()
 	if ctx.Verbose {
-		params.TraceLogger = ctx.Err
+		params.TraceLogger = ctx.Logger()
 	}
 
 	if cmd.vendorOnly {
-		return cmd.runVendorOnly(ctx, args, p, sm, params)
+		if err := cmd.runVendorOnly(ctx, args, p, sm, params); err != nil {
+			return err
+		}
+		if cmd.fromLockStdin {
+			// Gopkg.lock was neither read nor written, so there are no
+			// changes to it to audit, or to inform the hooks of.
+			return ctx.RunHooks(p, dep.HookPostEnsure, dep.HookSummary{})
+		}
+		if err := ctx.AuditLockChanges(p, oldLock); err != nil {
+			return err
+		}
+		if err := cmd.updateBazelMacro(ctx, p, sm); err != nil {
+			return err
+		}
+		return runPostEnsureHooks(ctx, p, oldLock)
 	}
 
 	params.RootPackageTree, err = p.ParseRootPackageTree()
@@ -197,26 +273,70 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 		}
 	}
 	if ineffs := p.FindIneffectualConstraints(sm); len(ineffs) > 0 {
-		ctx.Err.Printf("Warning: the following project(s) have [[constraint]] stanzas in %s:\n\n", dep.ManifestName)
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "the following project(s) have [[constraint]] stanzas in %s:\n\n", dep.ManifestName)
 		for _, ineff := range ineffs {
-			ctx.Err.Println("  ✗ ", ineff)
+			fmt.Fprintln(&buf, "  ✗ ", ineff)
+			events.Warn(fmt.Sprintf("%s has a [[constraint]] in %s, but is not a direct dependency of the project, so it has no effect", ineff, dep.ManifestName))
 		}
 		// TODO(sdboyer) lazy wording, it does not mention ignores at all
-		ctx.Err.Printf("\nHowever, these projects are not direct dependencies of the current project:\n")
-		ctx.Err.Printf("they are not imported in any .go files, nor are they in the 'required' list in\n")
-		ctx.Err.Printf("%s. Dep only applies [[constraint]] rules to direct dependencies, so\n", dep.ManifestName)
-		ctx.Err.Printf("these rules will have no effect.\n\n")
-		ctx.Err.Printf("Either import/require packages from these projects so that they become direct\n")
-		ctx.Err.Printf("dependencies, or convert each [[constraint]] to an [[override]] to enforce rules\n")
-		ctx.Err.Printf("on these projects, if they happen to be transitive dependencies.\n\n")
+		fmt.Fprintf(&buf, "\nHowever, these projects are not direct dependencies of the current project:\n")
+		fmt.Fprintf(&buf, "they are not imported in any .go files, nor are they in the 'required' list in\n")
+		fmt.Fprintf(&buf, "%s. Dep only applies [[constraint]] rules to direct dependencies, so\n", dep.ManifestName)
+		fmt.Fprintf(&buf, "these rules will have no effect.\n\n")
+		fmt.Fprintf(&buf, "Either import/require packages from these projects so that they become direct\n")
+		fmt.Fprintf(&buf, "dependencies, or convert each [[constraint]] to an [[override]] to enforce rules\n")
+		fmt.Fprintf(&buf, "on these projects, if they happen to be transitive dependencies.\n")
+		ctx.Logger().Warnf("%s", buf.String())
 	}
 
 	if cmd.add {
-		return cmd.runAdd(ctx, args, p, sm, params)
+		err = cmd.runAdd(ctx, args, p, sm, params)
 	} else if cmd.update {
-		return cmd.runUpdate(ctx, args, p, sm, params)
+		err = cmd.runUpdate(ctx, args, p, sm, params)
+	} else {
+		err = cmd.runDefault(ctx, args, p, sm, params)
 	}
-	return cmd.runDefault(ctx, args, p, sm, params)
+	if err != nil {
+		return err
+	}
+	if err := ctx.AuditLockChanges(p, oldLock); err != nil {
+		return err
+	}
+	if err := cmd.updateBazelMacro(ctx, p, sm); err != nil {
+		return err
+	}
+	return runPostEnsureHooks(ctx, p, oldLock)
+}
+
+// updateBazelMacro writes the Bazel macro named in the bazel-macro field of the
+// project's manifest, if any, declaring the projects in the lock just written.
+func (cmd *ensureCommand) updateBazelMacro(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) error {
+	file, macro := p.Manifest.BazelMacroFile()
+	if file == "" || cmd.dryRun {
+		return nil
+	}
+
+	l, err := dep.ReadLockFile(filepath.Join(p.AbsRoot, dep.LockName))
+	if err != nil {
+		return err
+	}
+	return writeBazelMacro(ctx, l, p.Manifest.PruneOptions, sm, filepath.Join(p.AbsRoot, file), macro)
+}
+
+// runPostEnsureHooks runs the project's post-ensure hooks, informing them of
+// the changes made to Gopkg.lock relative to oldLock.
+func runPostEnsureHooks(ctx *dep.Ctx, p *dep.Project, oldLock *dep.Lock) error {
+	hooks := p.Manifest.Hooks
+	if ctx.DisableHooks || len(hooks.PostEnsure)+len(hooks.PostEnsureWebhooks) == 0 {
+		return nil
+	}
+
+	summary, err := p.LockChangesSince(oldLock)
+	if err != nil {
+		return err
+	}
+	return ctx.RunHooks(p, dep.HookPostEnsure, summary)
 }
 
 func (cmd *ensureCommand) validateFlags() error {
@@ -224,6 +344,23 @@ func (cmd *ensureCommand) validateFlags() error {
 		return errors.New("cannot pass both -add and -update")
 	}
 
+	if cmd.fromLockStdin && !cmd.vendorOnly {
+		return errors.New("-from-lock-stdin supplies the lock vendor/ is populated from; it can only be passed with -vendor-only")
+	}
+
+	if cmd.groups != "" && !cmd.update {
+		return errors.New("-group selects the dependencies to update; it can only be passed with -update")
+	}
+
+	if cmd.materialize {
+		if cmd.add {
+			return errors.New("-materialize rewrites vendor/; it cannot be passed with -add")
+		}
+		if cmd.noVendor {
+			return errors.New("-materialize rewrites vendor/; it cannot be passed with -no-vendor")
+		}
+	}
+
 	if cmd.vendorOnly {
 		if cmd.update {
 			return errors.New("-vendor-only makes -update a no-op; cannot pass them together")
@@ -243,9 +380,20 @@ func (cmd *ensureCommand) vendorBehavior() dep.VendorBehavior {
 	if cmd.noVendor {
 		return dep.VendorNever
 	}
+	if cmd.materialize {
+		return dep.VendorAlways
+	}
 	return dep.VendorOnChanged
 }
 
+// setUpVendor makes sw write the projects in vendor/ from their pruned trees
+// in the cache, as symlinks to them if ctx asks for that and -materialize was
+// not passed, and take their digests from p's memo.
+func (cmd *ensureCommand) setUpVendor(ctx *dep.Ctx, p *dep.Project, sw *dep.SafeWriter) {
+	sw.UseTreeStore(ctx.TreeStore(), ctx.SymlinkVendor && !cmd.materialize)
+	sw.UseDigestMemo(p.DigestMemo)
+}
+
 func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
 	// Bare ensure doesn't take any args.
 	if len(args) != 0 {
@@ -267,55 +415,127 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 			ctx.Out.Printf("%s was already in sync with imports and %s\n", dep.LockName, dep.ManifestName)
 		}
 
+		// The digests and signatures that the manifest pins projects to are
+		// not among the inputs, so the lock is checked against them anyway.
+		if err := checkLock(ctx, p, sm, p.Lock, false); err != nil {
+			return err
+		}
+
 		if cmd.noVendor {
 			// The user said not to touch vendor/, so definitely nothing to do.
 			return nil
 		}
 
-		// TODO(sdboyer) The desired behavior at this point is to determine
-		// whether it's necessary to write out vendor, or if it's already
-		// consistent with the lock. However, we haven't yet determined what
-		// that "verification" is supposed to look like (#121); in the meantime,
-		// we unconditionally write out vendor/ so that `dep ensure`'s behavior
-		// is maximally compatible with what it will eventually become.
+		// Only write out vendor/ if it has drifted from the digests recorded
+		// in the lock, or if the lock has no digests to check it against.
+		inSync, err := vendorInSync(ctx, p)
+		if err != nil {
+			return err
+		}
+		// -materialize rewrites vendor/ regardless, as the digests are the
+		// same whether projects are vendored as symlinks or copies.
+		if inSync && !cmd.materialize {
+			if ctx.Verbose {
+				ctx.Out.Printf("vendor/ was already in sync with %s\n", dep.LockName)
+			}
+			return nil
+		}
+
 		sw, err := dep.NewSafeWriter(nil, p.Lock, p.Lock, dep.VendorAlways, p.Manifest.PruneOptions)
 		if err != nil {
 			return err
 		}
+		// The lock is in sync, but records the digests of what is vendored,
+		// which are updated, and the lock upgraded, along with vendor/.
+		sw.RecordDigests()
+		sw.ExcludeFromVendor(p.PlatformExcluded())
+		cmd.setUpVendor(ctx, p, sw)
 
 		if cmd.dryRun {
 			return sw.PrintPreparedActions(ctx.Out, ctx.Verbose)
 		}
 
-		var logger *log.Logger
-		if ctx.Verbose {
-			logger = ctx.Err
-		}
-		return errors.WithMessage(sw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor")
+		return errors.WithMessage(sw.Write(p.AbsRoot, sm, true, ctx.Logger()), "grouped write of manifest, lock and vendor")
 	}
 
 	if cmd.noVendor && cmd.dryRun {
 		return errors.New("Gopkg.lock was not up to date")
 	}
 
+	// Fetch the sources the solver will most likely need in parallel, ahead
+	// of it; it reports any that cannot be fetched itself.
+	fetchSources(ctx, sm, projectSources(p))
+
 	solution, err := solver.Solve(context.TODO())
 	if err != nil {
 		return handleAllTheFailuresOfTheWorld(err)
 	}
 
-	sw, err := dep.NewSafeWriter(nil, p.Lock, dep.LockFromSolution(solution), cmd.vendorBehavior(), p.Manifest.PruneOptions)
+	l := dep.LockFromSolution(solution)
+	sw, err := dep.NewSafeWriter(nil, p.Lock, l, cmd.vendorBehavior(), p.Manifest.PruneOptions)
 	if err != nil {
 		return err
 	}
+	sw.ExcludeFromVendor(p.PlatformExcluded())
+	cmd.setUpVendor(ctx, p, sw)
+	if err := checkLock(ctx, p, sm, l, true); err != nil {
+		return err
+	}
 	if cmd.dryRun {
 		return sw.PrintPreparedActions(ctx.Out, ctx.Verbose)
 	}
 
-	var logger *log.Logger
-	if ctx.Verbose {
-		logger = ctx.Err
+	return errors.Wrap(sw.Write(p.AbsRoot, sm, false, ctx.Logger()), "grouped write of manifest, lock and vendor")
+}
+
+// checkLock runs the checks p's manifest asks for on l, the lock about to be
+// written or vendored from: the signatures and digests pinned to projects are
+// verified, and if l was solved, rather than taken as it is, projects that may
+// be typosquats are warned about and the license policy is enforced.
+func checkLock(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, l *dep.Lock, solved bool) error {
+	if err := ctx.VerifyLockedSignatures(p, sm, l); err != nil {
+		return err
 	}
-	return errors.Wrap(sw.Write(p.AbsRoot, sm, false, logger), "grouped write of manifest, lock and vendor")
+	if err := ctx.VerifyLockedDigests(p, sm, l); err != nil {
+		return err
+	}
+	if !solved {
+		return nil
+	}
+	ctx.WarnSuspiciousProjects(p, sm, l)
+	return ctx.EnforceLicensePolicy(p, sm, l)
+}
+
+// vendorInSync reports whether the contents of p's vendor directory match the
+// digests recorded in p's lock, and were pruned as p's manifest now says they
+// should be. In verbose mode, the projects that do not match are logged.
+func vendorInSync(ctx *dep.Ctx, p *dep.Project) (bool, error) {
+	status, err := p.VerifyVendorMemoized()
+	if err != nil {
+		return false, err
+	}
+
+	drifted := make([]string, 0, len(status))
+	for path, st := range status {
+		if st != pkgtree.NoMismatch {
+			drifted = append(drifted, path)
+			continue
+		}
+		pr := gps.ProjectRoot(path)
+		if po, has := p.Lock.PruneOpts[pr]; has && po != p.Manifest.PruneOptions.PruneOptionsFor(pr) {
+			drifted = append(drifted, path)
+		}
+	}
+	sort.Strings(drifted)
+
+	for _, path := range drifted {
+		if st := status[path]; st != pkgtree.NoMismatch {
+			ctx.Logger().Debugf("vendor/%s: %s", path, st)
+		} else {
+			ctx.Logger().Debugf("vendor/%s: pruned with other options", path)
+		}
+	}
+	return len(drifted) == 0, nil
 }
 
 func (cmd *ensureCommand) runVendorOnly(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -332,16 +552,20 @@ func (cmd *ensureCommand) runVendorOnly(ctx *dep.Ctx, args []string, p *dep.Proj
 	if err != nil {
 		return err
 	}
+	if cmd.fromLockStdin {
+		sw.KeepLock()
+	}
+	sw.ExcludeFromVendor(p.PlatformExcluded())
+	cmd.setUpVendor(ctx, p, sw)
+	if err := checkLock(ctx, p, sm, p.Lock, false); err != nil {
+		return err
+	}
 
 	if cmd.dryRun {
 		return sw.PrintPreparedActions(ctx.Out, ctx.Verbose)
 	}
 
-	var logger *log.Logger
-	if ctx.Verbose {
-		logger = ctx.Err
-	}
-	return errors.WithMessage(sw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor")
+	return errors.WithMessage(sw.Write(p.AbsRoot, sm, true, ctx.Logger()), "grouped write of manifest, lock and vendor")
 }
 
 func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -368,6 +592,21 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 	// "pending" changes, or the -update that caused the problem?).
 	if !bytes.Equal(p.Lock.InputsDigest(), solver.HashInputs()) {
 		ctx.Out.Printf("Warning: %s is out of sync with %s or the project's imports.", dep.LockName, dep.ManifestName)
+		events.Warn(fmt.Sprintf("%s is out of sync with %s or the project's imports.", dep.LockName, dep.ManifestName))
+	}
+
+	// Groups name the dependencies to update, as though they were passed as
+	// arguments.
+	if cmd.groups != "" {
+		for _, group := range strings.Split(cmd.groups, ",") {
+			members := p.Manifest.GroupMembers(strings.TrimSpace(group))
+			if len(members) == 0 {
+				return errors.Errorf("no [[constraint]] in %s is in group %q", dep.ManifestName, group)
+			}
+			for _, pr := range members {
+				args = append(args, string(pr))
+			}
+		}
 	}
 
 	// When -update is specified without args, allow every dependency to change
@@ -380,6 +619,10 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 		return err
 	}
 
+	// Fetch the sources the solver will most likely need in parallel, ahead
+	// of it; it reports any that cannot be fetched itself.
+	fetchSources(ctx, sm, projectSources(p))
+
 	// Re-prepare a solver now that our params are complete.
 	solver, err = gps.Prepare(params, sm)
 	if err != nil {
@@ -393,19 +636,21 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 		return handleAllTheFailuresOfTheWorld(err)
 	}
 
-	sw, err := dep.NewSafeWriter(nil, p.Lock, dep.LockFromSolution(solution), cmd.vendorBehavior(), p.Manifest.PruneOptions)
+	l := dep.LockFromSolution(solution)
+	sw, err := dep.NewSafeWriter(nil, p.Lock, l, cmd.vendorBehavior(), p.Manifest.PruneOptions)
 	if err != nil {
 		return err
 	}
+	sw.ExcludeFromVendor(p.PlatformExcluded())
+	cmd.setUpVendor(ctx, p, sw)
+	if err := checkLock(ctx, p, sm, l, true); err != nil {
+		return err
+	}
 	if cmd.dryRun {
 		return sw.PrintPreparedActions(ctx.Out, ctx.Verbose)
 	}
 
-	var logger *log.Logger
-	if ctx.Verbose {
-		logger = ctx.Err
-	}
-	return errors.Wrap(sw.Write(p.AbsRoot, sm, false, logger), "grouped write of manifest, lock and vendor")
+	return errors.Wrap(sw.Write(p.AbsRoot, sm, false, ctx.Logger()), "grouped write of manifest, lock and vendor")
 }
 
 func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -432,6 +677,7 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 	// "pending" changes, or the -add that caused the problem?).
 	if p.Lock != nil && !bytes.Equal(p.Lock.InputsDigest(), solver.HashInputs()) {
 		ctx.Out.Printf("Warning: %s is out of sync with %s or the project's imports.", dep.LockName, dep.ManifestName)
+		events.Warn(fmt.Sprintf("%s is out of sync with %s or the project's imports.", dep.LockName, dep.ManifestName))
 	}
 
 	rm, _ := params.RootPackageTree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
@@ -641,6 +887,10 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 		}
 	}
 
+	// Fetch the sources the solver will most likely need in parallel, ahead
+	// of it; it reports any that cannot be fetched itself.
+	fetchSources(ctx, sm, projectSources(p))
+
 	// Re-prepare a solver now that our params are complete.
 	solver, err = gps.Prepare(params, sm)
 	if err != nil {
@@ -692,20 +942,22 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 	}
 	sort.Strings(reqlist)
 
-	sw, err := dep.NewSafeWriter(nil, p.Lock, dep.LockFromSolution(solution), dep.VendorOnChanged, p.Manifest.PruneOptions)
+	l := dep.LockFromSolution(solution)
+	sw, err := dep.NewSafeWriter(nil, p.Lock, l, dep.VendorOnChanged, p.Manifest.PruneOptions)
 	if err != nil {
 		return err
 	}
+	sw.ExcludeFromVendor(p.PlatformExcluded())
+	cmd.setUpVendor(ctx, p, sw)
+	if err := checkLock(ctx, p, sm, l, true); err != nil {
+		return err
+	}
 
 	if cmd.dryRun {
 		return sw.PrintPreparedActions(ctx.Out, ctx.Verbose)
 	}
 
-	var logger *log.Logger
-	if ctx.Verbose {
-		logger = ctx.Err
-	}
-	if err := errors.Wrap(sw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor"); err != nil {
+	if err := errors.Wrap(sw.Write(p.AbsRoot, sm, true, ctx.Logger()), "grouped write of manifest, lock and vendor"); err != nil {
 		return err
 	}
 
@@ -45,6 +45,29 @@ func TestInvalidEnsureFlagCombinations(t *testing.T) {
 	}
 	ec.noVendor = false
 
+	ec.vendorOnly, ec.fromLockStdin = false, true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-from-lock-stdin without -vendor-only should fail validation")
+	}
+	ec.fromLockStdin = false
+
+	ec.groups = "aws"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-group without -update should fail validation")
+	}
+	ec.groups = ""
+
+	ec.materialize, ec.add = true, true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-materialize with -add should fail validation")
+	}
+
+	ec.add, ec.noVendor = false, true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-materialize with -no-vendor should fail validation")
+	}
+	ec.materialize, ec.noVendor = false, false
+
 	// Also verify that the plain ensure path takes no args. This is a shady
 	// test, as lots of other things COULD return errors, and we don't check
 	// anything other than the error being non-nil. For now, it works well
@@ -0,0 +1,323 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const explainShortHelp = `Explain which constraint governs an import`
+const explainLongHelp = `
+Explain how the version of the project providing an import path is chosen:
+the project's root, the constraint that governs it and the stanza of
+Gopkg.toml it comes from, the override that replaces it, if any, the
+constraints the project's dependents place on it, the version it is locked
+to, and the latest version the constraint allows.
+
+Without an override, a project must satisfy both the [[constraint]] in
+Gopkg.toml, which applies only if the project is imported directly, and the
+constraints of the dependencies that import it. An [[override]] replaces all
+of them.
+
+With -json, the explanation is written as a JSON object.
+`
+
+type explainCommand struct {
+	json bool
+}
+
+func (cmd *explainCommand) Name() string      { return "explain" }
+func (cmd *explainCommand) Args() string      { return "[-json] <import-path>" }
+func (cmd *explainCommand) ShortHelp() string { return explainShortHelp }
+func (cmd *explainCommand) LongHelp() string  { return explainLongHelp }
+func (cmd *explainCommand) Hidden() bool      { return false }
+
+func (cmd *explainCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+func (cmd *explainCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 {
+		return errors.New("dep explain takes the import path to explain")
+	}
+	ip := args[0]
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	manifest, err := ioutil.ReadFile(filepath.Join(p.AbsRoot, dep.ManifestName))
+	if err != nil {
+		return errors.Wrapf(err, "could not read %s", dep.ManifestName)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	id := gps.ProjectIdentifier{}
+	lp, locked := lockedProjectOf(p.Lock, ip)
+	if locked {
+		id = lp.Ident()
+	} else {
+		pr, err := sm.DeduceProjectRoot(ip)
+		if err != nil {
+			return errors.Wrapf(err, "could not determine the project of %s", ip)
+		}
+		id = gps.ProjectIdentifier{ProjectRoot: pr}
+		if pp, has := p.Manifest.Constraints[pr]; has {
+			id.Source = pp.Source
+		}
+	}
+
+	var dependents []projectConstraint
+	if p.Lock != nil {
+		cc, errs := collectConstraintsOf(ctx, p, sm, map[gps.ProjectRoot]bool{id.ProjectRoot: true})
+		for _, err := range errs {
+			ctx.Logger().Warnf("%v", err)
+		}
+		for _, pc := range cc[string(id.ProjectRoot)] {
+			// The manifest's own constraint is explained from its stanza.
+			if pc.Project != "root" {
+				dependents = append(dependents, pc)
+			}
+		}
+	}
+
+	_, directDeps, err := p.GetDirectDependencyNames(sm)
+	if err != nil {
+		return errors.Wrap(err, "could not determine the project's direct dependencies")
+	}
+	versions, err := sm.ListVersions(id)
+	if err != nil {
+		return errors.Wrapf(err, "could not list the versions of %s", id.ProjectRoot)
+	}
+
+	e := explainImport(p, manifest, ip, id.ProjectRoot, directDeps[id.ProjectRoot], dependents, versions)
+	var buf bytes.Buffer
+	if cmd.json {
+		if err := json.NewEncoder(&buf).Encode(e); err != nil {
+			return err
+		}
+	} else {
+		e.format(&buf)
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// explanation is how the version of the project providing an import path is
+// chosen.
+type explanation struct {
+	Import      string `json:"import"`
+	ProjectRoot string `json:"projectRoot"`
+
+	// Constraint is the constraint the project's version must satisfy, and
+	// GovernedBy where it comes from: "override", "constraint", "dependents",
+	// "constraint and dependents", or "none", if the project is
+	// unconstrained.
+	Constraint string `json:"constraint"`
+	GovernedBy string `json:"governedBy"`
+
+	Override   *explainedStanza     `json:"override,omitempty"`
+	Declared   *explainedStanza     `json:"declared,omitempty"`
+	Dependents []explainedDependent `json:"dependents,omitempty"`
+	Locked     *explainedVersion    `json:"locked,omitempty"`
+	Latest     *explainedVersion    `json:"latest,omitempty"`
+}
+
+// explainedStanza is an [[override]] or [[constraint]] stanza of Gopkg.toml.
+// Line is 0, and Text empty, if it could not be found in the file.
+type explainedStanza struct {
+	Constraint string `json:"constraint"`
+	Line       int    `json:"line,omitempty"`
+	Text       string `json:"text,omitempty"`
+}
+
+// explainedDependent is a constraint a dependency places on the project.
+type explainedDependent struct {
+	Project    string `json:"project"`
+	Constraint string `json:"constraint"`
+}
+
+type explainedVersion struct {
+	Version  string `json:"version,omitempty"`
+	Revision string `json:"revision"`
+}
+
+// explainImport explains the version of the project pr, which provides the
+// package at the import path ip, in the project p, whose Gopkg.toml holds
+// manifest. direct is whether p imports pr itself, dependents are the
+// constraints p's dependencies place on pr, and versions the versions of pr.
+func explainImport(p *dep.Project, manifest []byte, ip string, pr gps.ProjectRoot, direct bool, dependents []projectConstraint, versions []gps.PairedVersion) explanation {
+	e := explanation{Import: ip, ProjectRoot: string(pr)}
+
+	stanza := func(table string, c gps.Constraint) *explainedStanza {
+		line, text := manifestStanza(manifest, table, pr)
+		return &explainedStanza{Constraint: c.String(), Line: line, Text: text}
+	}
+	if pp, has := p.Manifest.Ovr[pr]; has && pp.Constraint != nil {
+		e.Override = stanza("override", pp.Constraint)
+	}
+	if pp, has := p.Manifest.Constraints[pr]; has && pp.Constraint != nil {
+		e.Declared = stanza("constraint", pp.Constraint)
+	}
+	for _, pc := range dependents {
+		e.Dependents = append(e.Dependents, explainedDependent{Project: string(pc.Project), Constraint: pc.Constraint.String()})
+	}
+
+	c := gps.Any()
+	switch {
+	case e.Override != nil:
+		c, e.GovernedBy = p.Manifest.Ovr[pr].Constraint, "override"
+	default:
+		var by []string
+		// The root's constraint only applies to the projects it imports.
+		if e.Declared != nil && direct {
+			c = c.Intersect(p.Manifest.Constraints[pr].Constraint)
+			by = append(by, "constraint")
+		}
+		if len(dependents) > 0 {
+			for _, pc := range dependents {
+				c = c.Intersect(pc.Constraint)
+			}
+			by = append(by, "dependents")
+		}
+		e.GovernedBy = strings.Join(by, " and ")
+		if e.GovernedBy == "" {
+			e.GovernedBy = "none"
+		}
+	}
+	e.Constraint = c.String()
+
+	if lp, has := lockedProjectOf(p.Lock, ip); has {
+		rev, _, _ := gps.VersionComponentStrings(lp.Version())
+		e.Locked = &explainedVersion{Revision: rev}
+		if lp.Version().Type() != gps.IsRevision {
+			e.Locked.Version = formatVersion(lp.Version())
+		}
+	}
+
+	gps.SortPairedForUpgrade(versions)
+	for _, v := range versions {
+		if c.Matches(v) {
+			e.Latest = &explainedVersion{Version: formatVersion(v.Unpair()), Revision: string(v.Revision())}
+			break
+		}
+	}
+	return e
+}
+
+// format writes the explanation e for people to read.
+func (e explanation) format(w io.Writer) {
+	version := func(v *explainedVersion) string {
+		switch {
+		case v == nil:
+			return "none"
+		case v.Version == "":
+			return formatVersion(gps.Revision(v.Revision))
+		}
+		return fmt.Sprintf("%s (%s)", v.Version, formatVersion(gps.Revision(v.Revision)))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Import:\t%s\n", e.Import)
+	fmt.Fprintf(tw, "Project:\t%s\n", e.ProjectRoot)
+	fmt.Fprintf(tw, "Constraint:\t%s, governed by %s\n", e.Constraint, e.GovernedBy)
+	fmt.Fprintf(tw, "Locked:\t%s\n", version(e.Locked))
+	fmt.Fprintf(tw, "Latest allowed:\t%s\n", version(e.Latest))
+	tw.Flush()
+
+	stanza := func(table string, s *explainedStanza, role string) {
+		if s == nil {
+			return
+		}
+		if s.Line == 0 {
+			fmt.Fprintf(w, "\n[[%s]] in %s (%s): %s\n", table, dep.ManifestName, role, s.Constraint)
+			return
+		}
+		fmt.Fprintf(w, "\n[[%s]] at %s:%d (%s):\n", table, dep.ManifestName, s.Line, role)
+		for _, line := range strings.Split(s.Text, "\n") {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	stanza("override", e.Override, "governing")
+	switch {
+	case e.Override != nil:
+		stanza("constraint", e.Declared, "overridden")
+	case strings.Contains(e.GovernedBy, "constraint"):
+		stanza("constraint", e.Declared, "governing")
+	default:
+		stanza("constraint", e.Declared, "not applied, as the project is not imported directly")
+	}
+
+	if len(e.Dependents) > 0 {
+		role := "governing"
+		if e.Override != nil {
+			role = "overridden"
+		}
+		fmt.Fprintf(w, "\nConstraints of dependents (%s):\n", role)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, d := range e.Dependents {
+			fmt.Fprintf(tw, "  %s\t%s\n", d.Project, d.Constraint)
+		}
+		tw.Flush()
+	}
+}
+
+// stanzaHeader matches the header of a TOML table, or array of tables.
+var stanzaHeader = regexp.MustCompile(`^\s*\[\[?\s*([A-Za-z0-9_.-]+)\s*\]\]?\s*(#.*)?$`)
+
+// manifestStanza returns the line number, and the text, of the [[table]]
+// stanza of the manifest whose name is pr, or 0 and an empty string if there
+// is none.
+func manifestStanza(manifest []byte, table string, pr gps.ProjectRoot) (int, string) {
+	name := regexp.MustCompile(`^\s*name\s*=\s*"` + regexp.QuoteMeta(string(pr)) + `"\s*(#.*)?$`)
+	lines := strings.Split(string(manifest), "\n")
+
+	start, match := -1, false
+	end := func(i int) (int, string) {
+		// Blank lines and comments separate the stanza from the next.
+		for i > start && (strings.TrimSpace(lines[i-1]) == "" || strings.HasPrefix(strings.TrimSpace(lines[i-1]), "#")) {
+			i--
+		}
+		return start + 1, strings.Join(lines[start:i], "\n")
+	}
+	for i, line := range lines {
+		if m := stanzaHeader.FindStringSubmatch(line); m != nil {
+			if match {
+				return end(i)
+			}
+			start = -1
+			if m[1] == table && strings.Contains(line, "[[") {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && name.MatchString(line) {
+			match = true
+		}
+	}
+	if match {
+		return end(len(lines))
+	}
+	return 0, ""
+}
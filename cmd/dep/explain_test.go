@@ -0,0 +1,138 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+const explainManifest = `required = ["github.com/foo/tool"]
+
+# The API changed in 1.3.
+[[constraint]]
+  name = "github.com/foo/bar"
+  version = "1.2.0"
+
+[[constraint]]
+  name = "github.com/foo/barbell"
+  branch = "master"
+
+[[override]]
+  name = "github.com/foo/over" # pinned for the release
+  version = "=v1.0.0"
+
+[prune]
+  go-tests = true
+`
+
+func TestManifestStanza(t *testing.T) {
+	cases := []struct {
+		table string
+		pr    gps.ProjectRoot
+		line  int
+		text  string
+	}{
+		{"constraint", "github.com/foo/bar", 4, "[[constraint]]\n  name = \"github.com/foo/bar\"\n  version = \"1.2.0\""},
+		{"constraint", "github.com/foo/barbell", 8, "[[constraint]]\n  name = \"github.com/foo/barbell\"\n  branch = \"master\""},
+		{"override", "github.com/foo/over", 12, "[[override]]\n  name = \"github.com/foo/over\" # pinned for the release\n  version = \"=v1.0.0\""},
+		{"constraint", "github.com/foo/over", 0, ""},
+		{"override", "github.com/foo/bar", 0, ""},
+	}
+	for _, c := range cases {
+		line, text := manifestStanza([]byte(explainManifest), c.table, c.pr)
+		if line != c.line || text != c.text {
+			t.Errorf("unexpected [[%s]] of %s:\n\t(GOT): %d %q\n\t(WNT): %d %q", c.table, c.pr, line, text, c.line, c.text)
+		}
+	}
+}
+
+func TestExplainImport(t *testing.T) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	newer := gps.Revision("2f2a5bd28c0c4d8a8b4b1e7e6d9ad3b0f6c1e2a4")
+	c, err := gps.NewSemverConstraint("^1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err := gps.NewSemverConstraint("<1.4.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := dep.NewManifest()
+	m.Constraints["github.com/foo/bar"] = gps.ProjectProperties{Constraint: c}
+	m.Ovr["github.com/foo/over"] = gps.ProjectProperties{Constraint: gps.NewVersion("v1.0.0")}
+	p := &dep.Project{
+		Manifest: m,
+		Lock: &dep.Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.2.0").Pair(rev), []string{".", "sub"}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/over"}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+			},
+		},
+	}
+	versions := func() []gps.PairedVersion {
+		return []gps.PairedVersion{
+			gps.NewVersion("v1.2.0").Pair(rev),
+			gps.NewVersion("v1.3.1").Pair(newer),
+			gps.NewVersion("v1.5.0").Pair(newer),
+			gps.NewVersion("v1.0.0").Pair(rev),
+			gps.NewBranch("master").Pair(newer),
+		}
+	}
+	dependents := []projectConstraint{{"github.com/other/dependent", dc}}
+
+	got := explainImport(p, []byte(explainManifest), "github.com/foo/bar/sub", "github.com/foo/bar", true, dependents, versions())
+	want := explanation{
+		Import:      "github.com/foo/bar/sub",
+		ProjectRoot: "github.com/foo/bar",
+		Constraint:  c.Intersect(dc).String(),
+		GovernedBy:  "constraint and dependents",
+		Declared: &explainedStanza{
+			Constraint: c.String(),
+			Line:       4,
+			Text:       "[[constraint]]\n  name = \"github.com/foo/bar\"\n  version = \"1.2.0\"",
+		},
+		Dependents: []explainedDependent{{"github.com/other/dependent", dc.String()}},
+		Locked:     &explainedVersion{Version: "v1.2.0", Revision: string(rev)},
+		Latest:     &explainedVersion{Version: "v1.3.1", Revision: string(newer)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected explanation:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	// The root's constraint does not apply to projects it does not import.
+	got = explainImport(p, []byte(explainManifest), "github.com/foo/bar", "github.com/foo/bar", false, nil, versions())
+	if got.GovernedBy != "none" || got.Latest.Version != "v1.5.0" {
+		t.Errorf("unexpected explanation of a transitive project: %+v", got)
+	}
+	var buf bytes.Buffer
+	got.format(&buf)
+	if !strings.Contains(buf.String(), "(not applied, as the project is not imported directly)") {
+		t.Errorf("the explanation does not say the constraint is not applied:\n%s", buf.String())
+	}
+
+	// Overrides replace all other constraints.
+	got = explainImport(p, []byte(explainManifest), "github.com/foo/over", "github.com/foo/over", true, dependents, versions())
+	if got.GovernedBy != "override" || got.Constraint != "v1.0.0" || got.Override == nil || got.Override.Line != 12 || got.Latest.Version != "v1.0.0" {
+		t.Errorf("unexpected explanation of an overridden project: %+v", got)
+	}
+	buf.Reset()
+	got.format(&buf)
+	for _, w := range []string{
+		"Constraint:      v1.0.0, governed by override\n",
+		"Locked:          v1.0.0 (8a6d0e6)\n",
+		"[[override]] at Gopkg.toml:12 (governing):\n  [[override]]\n",
+		"Constraints of dependents (overridden):\n  github.com/other/dependent  <1.4.0\n",
+	} {
+		if !strings.Contains(buf.String(), w) {
+			t.Errorf("the explanation does not contain %q:\n%s", w, buf.String())
+		}
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const exportShortHelp = `Write go.mod and go.sum, or legacy tool, files from Gopkg.lock`
+const exportLongHelp = `
+Write a go.mod and a go.sum file for the project, from the projects in
+Gopkg.lock and the overrides in Gopkg.toml, so that the project can be built
+with Go modules as it is with dep.
+
+Every locked project is required at its locked version: semver tags as they
+are, with +incompatible where the go command needs it, and branches and bare
+revisions as the locked revision, which the go command turns into a
+pseudo-version when "go mod tidy" is run. Projects that the project's own
+packages do not import are marked // indirect.
+
+Projects locked to an alternate source are replaced with that source, at the
+same version. Overridden projects are replaced with themselves at their
+locked version, so that other modules cannot raise them.
+
+go.sum is filled in with the hashes of the locked versions of the projects,
+as found in the source cache, and fetched into it if they are not there. Only
+projects locked to semver tags can be listed in go.sum, as the go command
+names the others by pseudo-versions.
+
+With -format, the project is exported for an older tool instead:
+
+  modules   go.mod and go.sum, as above
+  glide     glide.yaml and glide.lock
+  godep     Godeps/Godeps.json
+
+glide.yaml lists the projects the project's packages import, with their
+constraints or overrides from Gopkg.toml, and the ignored packages; glide.lock,
+and Godeps.json, the locked revision of every project in Gopkg.lock. godep has
+no notion of alternate sources, so projects locked to one are fetched from
+their import path.
+
+The files are written to the project's root, unless -dir is given. Existing
+files are only replaced with -force.
+`
+
+// Export formats.
+const (
+	exportFormatModules = "modules"
+	exportFormatGlide   = "glide"
+	exportFormatGodep   = "godep"
+)
+
+type exportCommand struct {
+	dir    string
+	format string
+	force  bool
+}
+
+func (cmd *exportCommand) Name() string      { return "export" }
+func (cmd *exportCommand) Args() string      { return "[-format modules|glide|godep] [-dir dir] [-force]" }
+func (cmd *exportCommand) ShortHelp() string { return exportShortHelp }
+func (cmd *exportCommand) LongHelp() string  { return exportLongHelp }
+func (cmd *exportCommand) Hidden() bool      { return false }
+
+func (cmd *exportCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.dir, "dir", "", "directory to write the files to, instead of the project's root")
+	fs.StringVar(&cmd.format, "format", exportFormatModules, "the tool to export for: modules, glide or godep")
+	fs.BoolVar(&cmd.force, "force", false, "replace existing files")
+}
+
+func (cmd *exportCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep export takes no arguments")
+	}
+	var names []string
+	switch cmd.format {
+	case exportFormatModules:
+		names = []string{"go.mod", "go.sum"}
+	case exportFormatGlide:
+		names = []string{"glide.yaml", "glide.lock"}
+	case exportFormatGodep:
+		names = []string{filepath.Join("Godeps", "Godeps.json")}
+	default:
+		return errors.Errorf("unsupported export format %q, must be %q, %q or %q", cmd.format, exportFormatModules, exportFormatGlide, exportFormatGodep)
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+
+	dir := cmd.dir
+	if dir == "" {
+		dir = p.AbsRoot
+	}
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+		if _, err := os.Stat(paths[i]); err == nil && !cmd.force {
+			return errors.Errorf("%s already exists, use -force to replace it", paths[i])
+		}
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	_, direct, err := p.GetDirectDependencyNames(sm)
+	if err != nil {
+		return err
+	}
+
+	var files [][]byte
+	switch cmd.format {
+	case exportFormatModules:
+		mod, sources := newGoModule(p, direct)
+		hashes, err := hashModules(sm, sources)
+		if err != nil {
+			return err
+		}
+		for _, req := range mod.requires {
+			if !req.canonical {
+				ctx.Err.Printf("%s is locked to a revision, run \"go mod tidy\" to give it a pseudo-version\n", req.path)
+			}
+		}
+		files = [][]byte{mod.format(), goSum(hashes)}
+	case exportFormatGlide:
+		y, l, err := newGlideFiles(p, direct, time.Now())
+		if err != nil {
+			return err
+		}
+		files = [][]byte{y, l}
+	case exportFormatGodep:
+		g, unsourced, err := newGodepsJSON(p)
+		if err != nil {
+			return err
+		}
+		for _, pr := range unsourced {
+			ctx.Err.Printf("%s is locked to an alternate source, which godep will not fetch it from\n", pr)
+		}
+		files = [][]byte{g}
+	}
+
+	for i, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return errors.Wrapf(err, "could not create %s", filepath.Dir(path))
+		}
+		if err := ioutil.WriteFile(path, files[i], 0666); err != nil {
+			return errors.Wrapf(err, "could not write %s", path)
+		}
+	}
+	return nil
+}
+
+// goModule is the content of a go.mod file.
+type goModule struct {
+	path     string
+	requires []modRequire
+	replaces []modReplace
+}
+
+// modRequire is a requirement of a module, at a version.
+type modRequire struct {
+	path, version string
+	canonical     bool // whether version is a canonical semver version
+	indirect      bool
+}
+
+// modReplace replaces the module old with the module new, at version, or
+// with the directory new if version is empty.
+type modReplace struct {
+	old, new, version string
+}
+
+// moduleSource is a version of a module, whose contents are those of the
+// version v of the project id.
+type moduleSource struct {
+	path, version string
+	id            gps.ProjectIdentifier
+	v             gps.Version
+}
+
+// newGoModule returns the go.mod of the project p, requiring the projects in
+// its lock, and the versions of modules whose hashes go in its go.sum. direct
+// holds the projects p imports.
+func newGoModule(p *dep.Project, direct map[gps.ProjectRoot]bool) (goModule, []moduleSource) {
+	mod := goModule{path: string(p.ImportRoot)}
+	var sources []moduleSource
+	for _, lp := range p.Lock.Projects() {
+		id := lp.Ident()
+		version, canonical := moduleVersion(id.ProjectRoot, lp.Version())
+		mod.requires = append(mod.requires, modRequire{
+			path:      string(id.ProjectRoot),
+			version:   version,
+			canonical: canonical,
+			indirect:  !direct[id.ProjectRoot],
+		})
+
+		path := string(id.ProjectRoot)
+		if id.Source != "" && id.Source != path {
+			src, local := sourceModulePath(id.Source)
+			if local {
+				mod.replaces = append(mod.replaces, modReplace{old: path, new: src})
+				continue
+			}
+			mod.replaces = append(mod.replaces, modReplace{old: path, new: src, version: version})
+			path = src
+		} else if _, has := p.Manifest.Ovr[id.ProjectRoot]; has {
+			mod.replaces = append(mod.replaces, modReplace{old: path, new: path, version: version})
+		}
+
+		if canonical {
+			sources = append(sources, moduleSource{path: path, version: version, id: id, v: lp.Version()})
+		}
+	}
+	return mod, sources
+}
+
+// format returns the text of the go.mod file.
+func (m goModule) format() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Generated by dep export from %s.\n\nmodule %s\n", dep.LockName, m.path)
+	if len(m.requires) > 0 {
+		buf.WriteString("\nrequire (\n")
+		for _, req := range m.requires {
+			fmt.Fprintf(&buf, "\t%s %s", req.path, req.version)
+			if req.indirect {
+				buf.WriteString(" // indirect")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(")\n")
+	}
+	if len(m.replaces) > 0 {
+		buf.WriteString("\nreplace (\n")
+		for _, rep := range m.replaces {
+			fmt.Fprintf(&buf, "\t%s => %s", rep.old, rep.new)
+			if rep.version != "" {
+				fmt.Fprintf(&buf, " %s", rep.version)
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(")\n")
+	}
+	return buf.Bytes()
+}
+
+// moduleHash holds the hashes that go.sum records for a version of a module.
+type moduleHash struct {
+	moduleSource
+	sum, modSum string
+}
+
+// hashModules returns the hashes of the versions of modules in sources, which
+// are exported from sm to be hashed.
+func hashModules(sm gps.SourceManager, sources []moduleSource) ([]moduleHash, error) {
+	td, err := ioutil.TempDir("", "dep-export")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create temp dir to export projects to")
+	}
+	defer os.RemoveAll(td)
+
+	hashes := make([]moduleHash, len(sources))
+	for i, src := range sources {
+		dir := filepath.Join(td, fmt.Sprint(i))
+		if err := sm.ExportProject(context.TODO(), src.id, src.v, dir); err != nil {
+			return nil, errors.Wrapf(err, "could not export %s", src.id.ProjectRoot)
+		}
+		sum, modSum, err := gps.HashModule(dir, src.path, src.version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not hash %s", src.id.ProjectRoot)
+		}
+		hashes[i] = moduleHash{moduleSource: src, sum: sum, modSum: modSum}
+	}
+	return hashes, nil
+}
+
+// goSum returns the go.sum file listing hashes.
+func goSum(hashes []moduleHash) []byte {
+	var lines []string
+	for _, h := range hashes {
+		lines = append(lines,
+			fmt.Sprintf("%s %s %s\n", h.path, h.version, h.sum),
+			fmt.Sprintf("%s %s/go.mod %s\n", h.path, h.version, h.modSum),
+		)
+	}
+	sort.Strings(lines)
+	return []byte(strings.Join(lines, ""))
+}
+
+// majorSuffix matches the major version suffixes of module paths, such as the
+// "/v2" of "github.com/foo/bar/v2", or the ".v2" of "gopkg.in/yaml.v2".
+var majorSuffix = regexp.MustCompile(`(?:^gopkg\.in/.*\.|/)v([0-9]+)(?:-unstable)?$`)
+
+// moduleVersion returns the version by which the go command names the version
+// v of the project pr, and whether it is a canonical semver version. Versions
+// other than semver tags are named by their revision, which is not.
+func moduleVersion(pr gps.ProjectRoot, v gps.Version) (string, bool) {
+	if v.Type() == gps.IsSemver {
+		if sv, err := semver.NewVersion(v.String()); err == nil {
+			mv := fmt.Sprintf("v%d.%d.%d", sv.Major(), sv.Minor(), sv.Patch())
+			if sv.Prerelease() != "" {
+				mv += "-" + sv.Prerelease()
+			}
+			// Major versions from 2 on belong to paths with their suffix,
+			// and are incompatible with paths without one.
+			if m := majorSuffix.FindStringSubmatch(string(pr)); sv.Major() >= 2 && (m == nil || m[1] != fmt.Sprint(sv.Major())) {
+				mv += "+incompatible"
+			}
+			return mv, true
+		}
+	}
+	if rev, _, _ := gps.VersionComponentStrings(v); rev != "" {
+		return rev, false
+	}
+	return v.String(), false
+}
+
+// sourceModulePath returns the module path of the source URL src, or, if src
+// is a local directory, its path, and true.
+func sourceModulePath(src string) (string, bool) {
+	if filepath.IsAbs(src) || strings.HasPrefix(src, ".") {
+		return src, true
+	}
+
+	path := src
+	if strings.Contains(src, "://") {
+		u, err := url.Parse(src)
+		if err == nil {
+			if u.Scheme == "file" {
+				return u.Path, true
+			}
+			path = u.Host + u.Path
+		}
+	} else if at, colon := strings.Index(src, "@"), strings.Index(src, ":"); at >= 0 && colon > at {
+		// An scp-like address, such as git@github.com:foo/bar.git.
+		path = src[at+1:colon] + "/" + src[colon+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git"), false
+}
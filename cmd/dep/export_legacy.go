@@ -0,0 +1,168 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"runtime"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// glideYaml is the content of a glide.yaml file.
+type glideYaml struct {
+	Name    string         `yaml:"package"`
+	Ignores []string       `yaml:"ignore,omitempty"`
+	Imports []glidePackage `yaml:"import"`
+}
+
+type glidePackage struct {
+	Name        string   `yaml:"package"`
+	Version     string   `yaml:"version,omitempty"`
+	Repository  string   `yaml:"repo,omitempty"`
+	Subpackages []string `yaml:"subpackages,omitempty"`
+}
+
+// glideLock is the content of a glide.lock file.
+type glideLock struct {
+	Hash        string               `yaml:"hash"`
+	Updated     time.Time            `yaml:"updated"`
+	Imports     []glideLockedPackage `yaml:"imports"`
+	TestImports []glideLockedPackage `yaml:"testImports"`
+}
+
+type glideLockedPackage struct {
+	Name        string   `yaml:"name"`
+	Version     string   `yaml:"version"`
+	Repository  string   `yaml:"repo,omitempty"`
+	Subpackages []string `yaml:"subpackages,omitempty"`
+}
+
+// newGlideFiles returns the glide.yaml and glide.lock files of the project p,
+// recorded as updated at updated. glide.yaml lists the projects in direct,
+// which p imports, with their constraints, or overrides, from its manifest;
+// glide.lock lists every project in its lock, at its locked revision.
+func newGlideFiles(p *dep.Project, direct map[gps.ProjectRoot]bool, updated time.Time) ([]byte, []byte, error) {
+	y := glideYaml{
+		Name:    string(p.ImportRoot),
+		Ignores: p.Manifest.Ignored,
+		Imports: []glidePackage{},
+	}
+	l := glideLock{
+		Updated:     updated.UTC(),
+		Imports:     []glideLockedPackage{},
+		TestImports: []glideLockedPackage{},
+	}
+	for _, lp := range p.Lock.Projects() {
+		id := lp.Ident()
+		subpackages := glideSubpackages(lp)
+		rev, _, _ := gps.VersionComponentStrings(lp.Version())
+		l.Imports = append(l.Imports, glideLockedPackage{
+			Name:        string(id.ProjectRoot),
+			Version:     rev,
+			Repository:  id.Source,
+			Subpackages: subpackages,
+		})
+
+		if !direct[id.ProjectRoot] {
+			continue
+		}
+		pkg := glidePackage{
+			Name:        string(id.ProjectRoot),
+			Repository:  id.Source,
+			Subpackages: subpackages,
+		}
+		pp, has := p.Manifest.Ovr[id.ProjectRoot]
+		if !has {
+			pp = p.Manifest.Constraints[id.ProjectRoot]
+		}
+		if pp.Constraint != nil && !gps.IsAny(pp.Constraint) {
+			pkg.Version = pp.Constraint.String()
+		}
+		y.Imports = append(y.Imports, pkg)
+	}
+
+	yb, err := yaml.Marshal(y)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not marshal glide.yaml")
+	}
+	// glide records the hash of glide.yaml in glide.lock, to tell when the
+	// lock is out of date.
+	sum := sha256.Sum256(yb)
+	l.Hash = hex.EncodeToString(sum[:])
+	lb, err := yaml.Marshal(l)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not marshal glide.lock")
+	}
+	return yb, lb, nil
+}
+
+// glideSubpackages returns the packages of lp, other than its root package,
+// which glide lists as subpackages.
+func glideSubpackages(lp gps.LockedProject) []string {
+	var subpackages []string
+	for _, pkg := range lp.Packages() {
+		if pkg != "." {
+			subpackages = append(subpackages, pkg)
+		}
+	}
+	return subpackages
+}
+
+// godepJSON is the content of a Godeps/Godeps.json file.
+type godepJSON struct {
+	ImportPath   string
+	GoVersion    string
+	GodepVersion string
+	Packages     []string
+	Deps         []godepPackage
+}
+
+type godepPackage struct {
+	ImportPath string
+	Comment    string `json:",omitempty"`
+	Rev        string
+}
+
+// newGodepsJSON returns the Godeps/Godeps.json file of the project p, listing
+// every package of every project in its lock at its locked revision, and the
+// projects that godep cannot fetch from where dep does, as they are locked to
+// an alternate source.
+func newGodepsJSON(p *dep.Project) ([]byte, []gps.ProjectRoot, error) {
+	g := godepJSON{
+		ImportPath:   string(p.ImportRoot),
+		GoVersion:    runtime.Version(),
+		GodepVersion: "v80",
+		Packages:     []string{"./..."},
+		Deps:         []godepPackage{},
+	}
+	var unsourced []gps.ProjectRoot
+	for _, lp := range p.Lock.Projects() {
+		id := lp.Ident()
+		if id.Source != "" && id.Source != string(id.ProjectRoot) {
+			unsourced = append(unsourced, id.ProjectRoot)
+		}
+		rev, _, ver := gps.VersionComponentStrings(lp.Version())
+		for _, pkg := range lp.Packages() {
+			ip := string(id.ProjectRoot)
+			if pkg != "." {
+				ip += "/" + pkg
+			}
+			g.Deps = append(g.Deps, godepPackage{ImportPath: ip, Comment: ver, Rev: rev})
+		}
+	}
+
+	b, err := json.MarshalIndent(g, "", "\t")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not marshal Godeps.json")
+	}
+	return append(b, '\n'), unsourced, nil
+}
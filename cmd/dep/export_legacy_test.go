@@ -0,0 +1,142 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func legacyExportProject(t *testing.T) *dep.Project {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	c, err := gps.NewSemverConstraint("^1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := dep.NewManifest()
+	m.Ignored = []string{"github.com/foo/ignored"}
+	m.Constraints["github.com/foo/bar"] = gps.ProjectProperties{Constraint: c}
+	m.Constraints["github.com/foo/over"] = gps.ProjectProperties{Constraint: gps.NewBranch("master")}
+	m.Ovr["github.com/foo/over"] = gps.ProjectProperties{Constraint: gps.NewVersion("v1.0.0")}
+	return &dep.Project{
+		ImportRoot: "example.com/app",
+		Manifest:   m,
+		Lock: &dep.Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.2.0").Pair(rev), []string{".", "sub"}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/branch"}, gps.NewBranch("master").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/fork", Source: "https://github.com/fork/fork.git"}, rev, []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/over"}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+			},
+		},
+	}
+}
+
+func TestNewGlideFiles(t *testing.T) {
+	p := legacyExportProject(t)
+	direct := map[gps.ProjectRoot]bool{"github.com/foo/bar": true, "github.com/foo/fork": true, "github.com/foo/over": true}
+
+	y, l, err := newGlideFiles(p, direct, time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantYaml := `package: example.com/app
+ignore:
+- github.com/foo/ignored
+import:
+- package: github.com/foo/bar
+  version: ^1.2.0
+  subpackages:
+  - sub
+- package: github.com/foo/fork
+  repo: https://github.com/fork/fork.git
+- package: github.com/foo/over
+  version: v1.0.0
+`
+	if got := string(y); got != wantYaml {
+		t.Errorf("unexpected glide.yaml:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, wantYaml)
+	}
+
+	wantLock := `updated: 2018-06-01T12:00:00Z
+imports:
+- name: github.com/foo/bar
+  version: 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65
+  subpackages:
+  - sub
+- name: github.com/foo/branch
+  version: 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65
+- name: github.com/foo/fork
+  version: 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65
+  repo: https://github.com/fork/fork.git
+- name: github.com/foo/over
+  version: 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65
+testImports: []
+`
+	// The hash of glide.yaml comes first.
+	gotLock := string(l)
+	if !strings.HasPrefix(gotLock, "hash: ") || strings.Index(gotLock, "\n") != len("hash: ")+64 {
+		t.Fatalf("glide.lock does not start with the hash of glide.yaml:\n%s", gotLock)
+	}
+	if gotLock = gotLock[strings.Index(gotLock, "\n")+1:]; gotLock != wantLock {
+		t.Errorf("unexpected glide.lock:\n\t(GOT):\n%s\n\t(WNT):\n%s", gotLock, wantLock)
+	}
+}
+
+func TestNewGodepsJSON(t *testing.T) {
+	p := legacyExportProject(t)
+
+	b, unsourced, err := newGodepsJSON(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+	"ImportPath": "example.com/app",
+	"GoVersion": "` + runtime.Version() + `",
+	"GodepVersion": "v80",
+	"Packages": [
+		"./..."
+	],
+	"Deps": [
+		{
+			"ImportPath": "github.com/foo/bar",
+			"Comment": "v1.2.0",
+			"Rev": "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65"
+		},
+		{
+			"ImportPath": "github.com/foo/bar/sub",
+			"Comment": "v1.2.0",
+			"Rev": "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65"
+		},
+		{
+			"ImportPath": "github.com/foo/branch",
+			"Rev": "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65"
+		},
+		{
+			"ImportPath": "github.com/foo/fork",
+			"Rev": "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65"
+		},
+		{
+			"ImportPath": "github.com/foo/over",
+			"Comment": "v1.0.0",
+			"Rev": "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65"
+		}
+	]
+}
+`
+	if got := string(b); got != want {
+		t.Errorf("unexpected Godeps.json:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/fork"}; !reflect.DeepEqual(unsourced, want) {
+		t.Errorf("unexpected projects with alternate sources:\n\t(GOT): %v\n\t(WNT): %v", unsourced, want)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestModuleVersion(t *testing.T) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	cases := []struct {
+		pr        gps.ProjectRoot
+		v         gps.Version
+		want      string
+		canonical bool
+	}{
+		{"github.com/foo/bar", gps.NewVersion("v1.2.3").Pair(rev), "v1.2.3", true},
+		{"github.com/foo/bar", gps.NewVersion("1.2").Pair(rev), "v1.2.0", true},
+		{"github.com/foo/bar", gps.NewVersion("v1.0.0-rc.1+build.5").Pair(rev), "v1.0.0-rc.1", true},
+		{"github.com/foo/bar", gps.NewVersion("v2.1.0").Pair(rev), "v2.1.0+incompatible", true},
+		{"github.com/foo/bar/v2", gps.NewVersion("v2.1.0").Pair(rev), "v2.1.0", true},
+		{"github.com/foo/bar/v3", gps.NewVersion("v2.1.0").Pair(rev), "v2.1.0+incompatible", true},
+		{"gopkg.in/yaml.v2", gps.NewVersion("v2.2.1").Pair(rev), "v2.2.1", true},
+		{"github.com/foo/bar", gps.NewBranch("master").Pair(rev), string(rev), false},
+		{"github.com/foo/bar", gps.NewVersion("release-1").Pair(rev), string(rev), false},
+		{"github.com/foo/bar", rev, string(rev), false},
+	}
+
+	for _, c := range cases {
+		got, canonical := moduleVersion(c.pr, c.v)
+		if got != c.want || canonical != c.canonical {
+			t.Errorf("unexpected module version of %s at %s:\n\t(GOT): %s %v\n\t(WNT): %s %v", c.pr, c.v, got, canonical, c.want, c.canonical)
+		}
+	}
+}
+
+func TestSourceModulePath(t *testing.T) {
+	cases := []struct {
+		src   string
+		want  string
+		local bool
+	}{
+		{"github.com/fork/bar", "github.com/fork/bar", false},
+		{"https://github.com/fork/bar.git", "github.com/fork/bar", false},
+		{"ssh://git@github.com/fork/bar.git", "github.com/fork/bar", false},
+		{"git@github.com:fork/bar.git", "github.com/fork/bar", false},
+		{"file:///src/bar", "/src/bar", true},
+		{"/src/bar", "/src/bar", true},
+		{"../bar", "../bar", true},
+	}
+
+	for _, c := range cases {
+		got, local := sourceModulePath(c.src)
+		if got != c.want || local != c.local {
+			t.Errorf("unexpected module path of %s:\n\t(GOT): %s %v\n\t(WNT): %s %v", c.src, got, local, c.want, c.local)
+		}
+	}
+}
+
+func TestNewGoModule(t *testing.T) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	m := dep.NewManifest()
+	m.Ovr["github.com/foo/over"] = gps.ProjectProperties{Constraint: gps.NewVersion("v1.0.0")}
+	p := &dep.Project{
+		ImportRoot: "example.com/app",
+		Manifest:   m,
+		Lock: &dep.Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.2.0").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/branch"}, gps.NewBranch("master").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/fork", Source: "https://github.com/fork/fork.git"}, gps.NewVersion("v0.3.0").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/local", Source: "/src/local"}, gps.NewVersion("v0.1.0").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/over"}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+			},
+		},
+	}
+	direct := map[gps.ProjectRoot]bool{"github.com/foo/bar": true, "github.com/foo/fork": true}
+
+	mod, sources := newGoModule(p, direct)
+	want := `// Generated by dep export from Gopkg.lock.
+
+module example.com/app
+
+require (
+	github.com/foo/bar v1.2.0
+	github.com/foo/branch 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65 // indirect
+	github.com/foo/fork v0.3.0
+	github.com/foo/local v0.1.0 // indirect
+	github.com/foo/over v1.0.0 // indirect
+)
+
+replace (
+	github.com/foo/fork => github.com/fork/fork v0.3.0
+	github.com/foo/local => /src/local
+	github.com/foo/over => github.com/foo/over v1.0.0
+)
+`
+	if got := string(mod.format()); got != want {
+		t.Errorf("unexpected go.mod:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	// Only modules at semver versions, and not replaced by directories, are
+	// listed in go.sum, by the path they are fetched from.
+	var got []string
+	for _, src := range sources {
+		got = append(got, src.path+"@"+src.version)
+	}
+	wantSources := []string{"github.com/foo/bar@v1.2.0", "github.com/fork/fork@v0.3.0", "github.com/foo/over@v1.0.0"}
+	if !reflect.DeepEqual(got, wantSources) {
+		t.Errorf("unexpected go.sum modules:\n\t(GOT): %v\n\t(WNT): %v", got, wantSources)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// defaultFetchJobs is how many sources are fetched at once, unless
+// $DEPFETCHJOBS says otherwise.
+const defaultFetchJobs = 8
+
+// progressInterval is how often the progress of fetches is redrawn.
+const progressInterval = 200 * time.Millisecond
+
+// fetchSources brings the local copies of the sources of the projects in ids
+// up to date ahead of solving, running up to ctx.FetchJobs fetches at once, so
+// that the solver, which visits projects one at a time, finds them on hand.
+// While it runs, the fetches in progress are displayed on ctx.Progress, if it
+// is set.
+//
+// The first error encountered is returned once every fetch has finished.
+func fetchSources(ctx *dep.Ctx, sm gps.SourceManager, ids []gps.ProjectIdentifier) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	jobs := ctx.FetchJobs
+	if jobs <= 0 {
+		jobs = defaultFetchJobs
+	}
+	if jobs > len(ids) {
+		jobs = len(ids)
+	}
+
+	var (
+		mu    sync.Mutex
+		done  int
+		first error
+		wg    sync.WaitGroup
+	)
+	work := make(chan gps.ProjectIdentifier)
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				err := sm.SyncSourceFor(id)
+				mu.Lock()
+				done++
+				if err != nil && first == nil {
+					first = errors.Wrapf(err, "unable to fetch %s", id.ProjectRoot)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var pd *progressDisplay
+	if osm, ok := sm.(*gps.SourceMgr); ok && ctx.Progress != nil {
+		pd = &progressDisplay{w: ctx.Progress, sm: osm, total: len(ids), start: time.Now()}
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			t := time.NewTicker(progressInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					mu.Lock()
+					n := done
+					mu.Unlock()
+					pd.draw(n)
+				case <-stop:
+					return
+				}
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+			pd.finish(first)
+		}()
+	}
+
+	for _, id := range ids {
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+	return first
+}
+
+// progressDisplay draws the fetches in progress on a terminal, redrawing them
+// in place.
+type progressDisplay struct {
+	w     io.Writer
+	sm    *gps.SourceMgr
+	total int
+	start time.Time
+	lines int // The number of lines last drawn.
+}
+
+// draw redraws the display, with done of the fetches finished.
+func (pd *progressDisplay) draw(done int) {
+	var buf bytes.Buffer
+	pd.clear(&buf)
+
+	fmt.Fprintf(&buf, "Fetching sources: %d/%d done\n", done, pd.total)
+	pd.lines = 1
+	now := time.Now()
+	for _, op := range pd.sm.Operations() {
+		fmt.Fprintf(&buf, "  %s: %s", trimScheme(op.Name), strings.ToLower(op.Phase))
+		if op.Bytes > 0 {
+			fmt.Fprintf(&buf, ", %s", formatBytes(op.Bytes))
+		}
+		fmt.Fprintf(&buf, " (%s)\n", now.Sub(op.Started).Truncate(time.Second))
+		pd.lines++
+	}
+	pd.w.Write(buf.Bytes())
+}
+
+// finish clears the display, leaving a summary in its place.
+func (pd *progressDisplay) finish(err error) {
+	var buf bytes.Buffer
+	pd.clear(&buf)
+	pd.lines = 0
+	if err != nil {
+		fmt.Fprintf(&buf, "Fetching sources failed after %s\n", time.Since(pd.start).Truncate(time.Second))
+	} else {
+		fmt.Fprintf(&buf, "Fetched %d sources in %s\n", pd.total, time.Since(pd.start).Truncate(time.Second))
+	}
+	pd.w.Write(buf.Bytes())
+}
+
+// clear erases the lines last drawn.
+func (pd *progressDisplay) clear(buf *bytes.Buffer) {
+	if pd.lines > 0 {
+		// Move up to the first line drawn, and erase to the end of the screen.
+		fmt.Fprintf(buf, "\x1b[%dA\x1b[J", pd.lines)
+	}
+}
+
+// trimScheme returns the URL u without its scheme, and the user of ssh URLs,
+// which are of no interest while watching progress.
+func trimScheme(u string) string {
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	}
+	if i := strings.Index(u, "@"); i >= 0 && i < strings.IndexAny(u+"/", "/:") {
+		u = u[i+1:]
+	}
+	return u
+}
+
+// formatBytes formats n as a number of bytes, in the largest unit it reaches.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// projectSources returns the identifiers of the projects that solving p will
+// most likely need: those in its lock, and those it constrains.
+func projectSources(p *dep.Project) []gps.ProjectIdentifier {
+	seen := make(map[gps.ProjectIdentifier]bool)
+	var ids []gps.ProjectIdentifier
+	add := func(id gps.ProjectIdentifier) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if p.Lock != nil {
+		for _, lp := range p.Lock.Projects() {
+			add(lp.Ident())
+		}
+	}
+	for pr, pp := range p.Manifest.DependencyConstraints() {
+		add(gps.ProjectIdentifier{ProjectRoot: pr, Source: pp.Source})
+	}
+	return ids
+}
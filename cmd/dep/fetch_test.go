@@ -0,0 +1,39 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestTrimScheme(t *testing.T) {
+	cases := map[string]string{
+		"https://github.com/golang/dep":     "github.com/golang/dep",
+		"ssh://git@github.com/golang/dep":   "github.com/golang/dep",
+		"git@github.com:golang/dep.git":     "github.com:golang/dep.git",
+		"https://example.com/a@b":           "example.com/a@b",
+		"github.com/golang/dep":             "github.com/golang/dep",
+		"http://user@example.com:8080/repo": "example.com:8080/repo",
+	}
+	for in, want := range cases {
+		if got := trimScheme(in); got != want {
+			t.Errorf("trimScheme(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{
+		0:           "0 B",
+		1023:        "1023 B",
+		1024:        "1.0 KiB",
+		1536:        "1.5 KiB",
+		5 << 20:     "5.0 MiB",
+		3 << 30 / 2: "1.5 GiB",
+	}
+	for in, want := range cases {
+		if got := formatBytes(in); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
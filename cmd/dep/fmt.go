@@ -0,0 +1,80 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const fmtShortHelp = `Rewrite Gopkg.lock in its canonical form`
+const fmtLongHelp = `
+Rewrite Gopkg.lock in the canonical form that dep writes it in: projects
+sorted by name, the packages of each project sorted and listed one per line,
+and the fields of each project, its digest included, in a fixed order.
+
+A lock in canonical form changes by as few lines as possible as dependencies
+change, which keeps diffs reviewable and merge conflicts rare. Locks that were
+edited by hand, or merged by a tool other than dep merge-lock, may not be; dep
+fmt puts them back in canonical form without changing their contents, so a
+signature made with dep lock sign remains valid.
+
+With -check, Gopkg.lock is not rewritten; instead, dep fmt exits non-zero if
+it is not in canonical form, which makes it suitable for use in CI.
+`
+
+type fmtCommand struct {
+	check bool
+}
+
+func (cmd *fmtCommand) Name() string      { return "fmt" }
+func (cmd *fmtCommand) Args() string      { return "[-check]" }
+func (cmd *fmtCommand) ShortHelp() string { return fmtShortHelp }
+func (cmd *fmtCommand) LongHelp() string  { return fmtLongHelp }
+func (cmd *fmtCommand) Hidden() bool      { return false }
+
+func (cmd *fmtCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.check, "check", false, "report whether Gopkg.lock is in canonical form, without rewriting it")
+}
+
+func (cmd *fmtCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep fmt takes no arguments")
+	}
+
+	p, unlock, err := ctx.LoadLockedProject()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if p.Lock == nil {
+		return errors.Errorf("no %s to format", dep.LockName)
+	}
+
+	lpath := filepath.Join(p.AbsRoot, dep.LockName)
+	data, err := ioutil.ReadFile(lpath)
+	if err != nil {
+		return errors.Wrapf(err, "could not read %s", lpath)
+	}
+
+	formatted, err := dep.FormatLock(data)
+	if err != nil {
+		return errors.Wrapf(err, "could not format %s", dep.LockName)
+	}
+	if bytes.Equal(data, formatted) {
+		ctx.Logger().Debugf("%s is already in canonical form", dep.LockName)
+		return nil
+	}
+
+	if cmd.check {
+		return errors.Errorf("%s is not in canonical form; run dep fmt to rewrite it", dep.LockName)
+	}
+	return errors.Wrapf(ioutil.WriteFile(lpath, formatted, 0666), "could not write %s", lpath)
+}
@@ -0,0 +1,369 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const graphShortHelp = `Write the dependency graph as DOT, JSON or GraphML`
+const graphLongHelp = `
+Write the graph of the project and the projects in Gopkg.lock: a node for
+each project, with its locked version, and an edge from each project to each
+project it imports, with the number of imports of the packages of one by the
+packages of the other. With -packages, the graph is of the locked packages
+instead, with an edge for each import. Only the packages of dependencies that
+are vendored are included, while all of the project's own packages, and their
+tests, are.
+
+The -format flag sets the format written:
+
+  dot       GraphViz DOT, as dep status -dot writes (the default)
+  json      a JSON object, whose schema is described in docs/daily-dep.md
+  graphml   GraphML, with the nodes' versions and edges' import counts
+            as data
+
+The package trees of the locked projects are read from the source cache,
+fetching the sources that are not in it.
+`
+
+// The formats dep graph writes.
+const (
+	graphFormatDOT     = "dot"
+	graphFormatJSON    = "json"
+	graphFormatGraphML = "graphml"
+)
+
+// graphSchemaVersion is the version of the schema of the JSON graph. It is
+// increased with every change that could break its readers.
+const graphSchemaVersion = 1
+
+type graphCommand struct {
+	format   string
+	packages bool
+}
+
+func (cmd *graphCommand) Name() string      { return "graph" }
+func (cmd *graphCommand) Args() string      { return "[-format dot|json|graphml] [-packages]" }
+func (cmd *graphCommand) ShortHelp() string { return graphShortHelp }
+func (cmd *graphCommand) LongHelp() string  { return graphLongHelp }
+func (cmd *graphCommand) Hidden() bool      { return false }
+
+func (cmd *graphCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.format, "format", graphFormatDOT, "the format to write: dot, json or graphml")
+	fs.BoolVar(&cmd.packages, "packages", false, "write the graph of packages, rather than of projects")
+}
+
+func (cmd *graphCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.New("dep graph takes no arguments")
+	}
+	var write func(io.Writer, depGraph) error
+	switch cmd.format {
+	case graphFormatDOT:
+		write = writeGraphDOT
+	case graphFormatJSON:
+		write = writeGraphJSON
+	case graphFormatGraphML:
+		write = writeGraphML
+	default:
+		return errors.Errorf("-format must be %q, %q or %q, not %q", graphFormatDOT, graphFormatJSON, graphFormatGraphML, cmd.format)
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in project root %s, run \"dep ensure\" to create one", dep.LockName, p.AbsRoot)
+	}
+	rootTree, err := p.ParseRootPackageTree()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	trees := make(map[gps.ProjectRoot]pkgtree.PackageTree, len(p.Lock.P))
+	for _, lp := range p.Lock.Projects() {
+		ptree, err := sm.ListPackages(lp.Ident(), lp.Version())
+		if err != nil {
+			return errors.Wrapf(err, "could not list the packages of %s", lp.Ident().ProjectRoot)
+		}
+		trees[lp.Ident().ProjectRoot] = ptree
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, buildGraph(p, rootTree, trees, cmd.packages)); err != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// depGraph is the graph of the projects, or packages, of a project and its
+// dependencies.
+type depGraph struct {
+	Version  int         `json:"version"`
+	Packages bool        `json:"packages"`
+	Nodes    []graphNode `json:"nodes"`
+	Edges    []graphEdge `json:"edges"`
+}
+
+// graphNode is a project, or a package, with the version its project is
+// locked to. The root project, which is not locked, has no version.
+type graphNode struct {
+	ID       string `json:"id"`
+	Project  string `json:"project"`
+	Root     bool   `json:"root,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// graphEdge is the imports of the packages of the node To by those of From.
+type graphEdge struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Imports int    `json:"imports"`
+}
+
+// buildGraph returns the graph of the project p, whose package tree is
+// rootTree, and the projects in its lock, whose package trees are trees. With
+// packages, the graph is of the packages, rather than of the projects.
+func buildGraph(p *dep.Project, rootTree pkgtree.PackageTree, trees map[gps.ProjectRoot]pkgtree.PackageTree, packages bool) depGraph {
+	g := depGraph{Version: graphSchemaVersion, Packages: packages, Nodes: []graphNode{}, Edges: []graphEdge{}}
+	root := string(p.ImportRoot)
+
+	nodes := make(map[string]bool)
+	// nodeOf returns the node an imported package belongs to, if it has one.
+	nodeOf := func(ip string) (string, bool) {
+		if packages {
+			return ip, nodes[ip]
+		}
+		if ip == root || isPathPrefix(ip, root) {
+			return root, true
+		}
+		if lp, has := lockedProjectOf(p.Lock, ip); has {
+			return string(lp.Ident().ProjectRoot), true
+		}
+		return "", false
+	}
+
+	type pkg struct {
+		node    string
+		imports []string
+	}
+	var pkgs []pkg
+	addNode := func(n graphNode, ips []string, imports func(ip string) []string) {
+		if !packages {
+			g.Nodes = append(g.Nodes, n)
+			nodes[n.ID] = true
+		}
+		for _, ip := range ips {
+			if packages {
+				pn := n
+				pn.ID = ip
+				g.Nodes = append(g.Nodes, pn)
+				nodes[ip] = true
+			}
+			pkgs = append(pkgs, pkg{node: ip, imports: imports(ip)})
+		}
+	}
+
+	var rootPkgs []string
+	for ip, poe := range rootTree.Packages {
+		if poe.Err == nil {
+			rootPkgs = append(rootPkgs, ip)
+		}
+	}
+	sort.Strings(rootPkgs)
+	addNode(graphNode{ID: root, Project: root, Root: true}, rootPkgs, func(ip string) []string {
+		pp := rootTree.Packages[ip].P
+		return append(append([]string(nil), pp.Imports...), pp.TestImports...)
+	})
+
+	for _, lp := range p.Lock.Projects() {
+		pr := lp.Ident().ProjectRoot
+		rev, branch, version := gps.VersionComponentStrings(lp.Version())
+		var ips []string
+		for _, sub := range lp.Packages() {
+			ips = append(ips, path.Join(string(pr), sub))
+		}
+		tree := trees[pr]
+		addNode(graphNode{ID: string(pr), Project: string(pr), Version: version, Branch: branch, Revision: rev}, ips, func(ip string) []string {
+			if poe, has := tree.Packages[ip]; has && poe.Err == nil {
+				return poe.P.Imports
+			}
+			return nil
+		})
+	}
+
+	counts := make(map[[2]string]int)
+	for _, pkg := range pkgs {
+		from := pkg.node
+		if !packages {
+			from, _ = nodeOf(pkg.node)
+		}
+		seen := make(map[string]bool)
+		for _, ip := range pkg.imports {
+			to, has := nodeOf(ip)
+			if !has || to == from || seen[ip] {
+				continue
+			}
+			seen[ip] = true
+			counts[[2]string{from, to}]++
+		}
+	}
+	for e, n := range counts {
+		g.Edges = append(g.Edges, graphEdge{From: e[0], To: e[1], Imports: n})
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// label returns the version of the node n, for people to read.
+func (n graphNode) label() string {
+	switch {
+	case n.Version != "":
+		return n.Version
+	case n.Branch != "":
+		return "branch " + n.Branch
+	case len(n.Revision) > 7:
+		return n.Revision[:7]
+	}
+	return n.Revision
+}
+
+// writeGraphDOT writes g to w in GraphViz's DOT language.
+func writeGraphDOT(w io.Writer, g depGraph) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph {\n\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		label := n.ID
+		if v := n.label(); v != "" {
+			label += "\n" + v
+		}
+		fmt.Fprintf(&buf, "\t%s [label=%s];\n", strconv.Quote(n.ID), strconv.Quote(label))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "\t%s -> %s [label=\"%d\"];\n", strconv.Quote(e.From), strconv.Quote(e.To), e.Imports)
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeGraphJSON writes g to w as a JSON object.
+func writeGraphJSON(w io.Writer, g depGraph) error {
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// The elements of a GraphML document.
+type (
+	graphML struct {
+		XMLName xml.Name     `xml:"graphml"`
+		XMLNS   string       `xml:"xmlns,attr"`
+		Keys    []graphMLKey `xml:"key"`
+		Graph   graphMLGraph `xml:"graph"`
+	}
+	graphMLKey struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+	graphMLGraph struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	}
+	graphMLNode struct {
+		ID   string        `xml:"id,attr"`
+		Data []graphMLData `xml:"data"`
+	}
+	graphMLEdge struct {
+		Source string        `xml:"source,attr"`
+		Target string        `xml:"target,attr"`
+		Data   []graphMLData `xml:"data"`
+	}
+	graphMLData struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+)
+
+// writeGraphML writes g to w as a GraphML document.
+func writeGraphML(w io.Writer, g depGraph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "project", For: "node", Name: "project", Type: "string"},
+			{ID: "root", For: "node", Name: "root", Type: "boolean"},
+			{ID: "version", For: "node", Name: "version", Type: "string"},
+			{ID: "branch", For: "node", Name: "branch", Type: "string"},
+			{ID: "revision", For: "node", Name: "revision", Type: "string"},
+			{ID: "imports", For: "edge", Name: "imports", Type: "int"},
+		},
+		Graph: graphMLGraph{ID: "dependencies", EdgeDefault: "directed"},
+	}
+	for _, n := range g.Nodes {
+		gn := graphMLNode{ID: n.ID, Data: []graphMLData{{Key: "project", Value: n.Project}}}
+		for _, d := range []graphMLData{
+			{Key: "root", Value: strconv.FormatBool(n.Root)},
+			{Key: "version", Value: n.Version},
+			{Key: "branch", Value: n.Branch},
+			{Key: "revision", Value: n.Revision},
+		} {
+			if d.Value != "" && d.Value != "false" {
+				gn.Data = append(gn.Data, d)
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.From,
+			Target: e.To,
+			Data:   []graphMLData{{Key: "imports", Value: strconv.Itoa(e.Imports)}},
+		})
+	}
+
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, b)
+	return err
+}
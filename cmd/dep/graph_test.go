@@ -0,0 +1,131 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/test"
+)
+
+// graphFixture returns a project, and the package trees of it and its
+// dependencies, for building graphs of.
+func graphFixture() (*dep.Project, pkgtree.PackageTree, map[gps.ProjectRoot]pkgtree.PackageTree) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	p := &dep.Project{
+		ImportRoot: "example.com/app",
+		Lock: &dep.Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.2.0").Pair(rev), []string{".", "sub"}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewBranch("master").Pair(rev), []string{"."}),
+			},
+		},
+	}
+	tree := func(pkgs ...pkgtree.Package) pkgtree.PackageTree {
+		t := pkgtree.PackageTree{Packages: make(map[string]pkgtree.PackageOrErr)}
+		for _, pkg := range pkgs {
+			t.Packages[pkg.ImportPath] = pkgtree.PackageOrErr{P: pkg}
+		}
+		return t
+	}
+
+	root := tree(
+		pkgtree.Package{ImportPath: "example.com/app", Imports: []string{"fmt", "example.com/app/util", "github.com/foo/bar", "github.com/foo/bar/sub"}},
+		pkgtree.Package{ImportPath: "example.com/app/util", Imports: []string{"github.com/foo/bar"}, TestImports: []string{"github.com/foo/baz"}},
+	)
+	trees := map[gps.ProjectRoot]pkgtree.PackageTree{
+		"github.com/foo/bar": tree(
+			pkgtree.Package{ImportPath: "github.com/foo/bar", Imports: []string{"github.com/foo/bar/sub", "github.com/foo/baz"}},
+			pkgtree.Package{ImportPath: "github.com/foo/bar/sub", Imports: []string{"strings"}},
+			// Unused packages are not vendored, and not in the graph.
+			pkgtree.Package{ImportPath: "github.com/foo/bar/unused", Imports: []string{"github.com/foo/qux"}},
+		),
+		"github.com/foo/baz": tree(pkgtree.Package{ImportPath: "github.com/foo/baz"}),
+	}
+	return p, root, trees
+}
+
+func TestBuildGraph(t *testing.T) {
+	p, root, trees := graphFixture()
+
+	g := buildGraph(p, root, trees, false)
+	wantNodes := []string{"example.com/app", "github.com/foo/bar", "github.com/foo/baz"}
+	wantEdges := []graphEdge{
+		{From: "example.com/app", To: "github.com/foo/bar", Imports: 3},
+		{From: "example.com/app", To: "github.com/foo/baz", Imports: 1},
+		{From: "github.com/foo/bar", To: "github.com/foo/baz", Imports: 1},
+	}
+	checkGraph(t, g, wantNodes, wantEdges)
+
+	g = buildGraph(p, root, trees, true)
+	wantNodes = []string{"example.com/app", "example.com/app/util", "github.com/foo/bar", "github.com/foo/bar/sub", "github.com/foo/baz"}
+	wantEdges = []graphEdge{
+		{From: "example.com/app", To: "example.com/app/util", Imports: 1},
+		{From: "example.com/app", To: "github.com/foo/bar", Imports: 1},
+		{From: "example.com/app", To: "github.com/foo/bar/sub", Imports: 1},
+		{From: "example.com/app/util", To: "github.com/foo/bar", Imports: 1},
+		{From: "example.com/app/util", To: "github.com/foo/baz", Imports: 1},
+		{From: "github.com/foo/bar", To: "github.com/foo/bar/sub", Imports: 1},
+		{From: "github.com/foo/bar", To: "github.com/foo/baz", Imports: 1},
+	}
+	checkGraph(t, g, wantNodes, wantEdges)
+	if n := g.Nodes[3]; n.Project != "github.com/foo/bar" || n.Version != "v1.2.0" {
+		t.Errorf("the package node of github.com/foo/bar/sub lacks its project's version: %+v", n)
+	}
+}
+
+func checkGraph(t *testing.T, g depGraph, wantNodes []string, wantEdges []graphEdge) {
+	t.Helper()
+	var nodes []string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("unexpected nodes in the graph of packages %v:\n\t(GOT): %v\n\t(WNT): %v", g.Packages, nodes, wantNodes)
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("unexpected edges in the graph of packages %v:\n\t(GOT): %v\n\t(WNT): %v", g.Packages, g.Edges, wantEdges)
+	}
+}
+
+func TestWriteGraph(t *testing.T) {
+	h := test.NewHelper(t)
+	h.Parallel()
+	defer h.Cleanup()
+
+	p, root, trees := graphFixture()
+	g := buildGraph(p, root, trees, false)
+
+	cases := []struct {
+		golden string
+		write  func(io.Writer, depGraph) error
+	}{
+		{"graph/projects.dot", writeGraphDOT},
+		{"graph/projects.json", writeGraphJSON},
+		{"graph/projects.graphml", writeGraphML},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := c.write(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+		want := h.GetTestFileString(c.golden)
+		if buf.String() != want {
+			if *test.UpdateGolden {
+				if err := h.WriteTestFile(c.golden, buf.String()); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			t.Errorf("unexpected %s:\n\t(GOT):\n%s\n\t(WNT):\n%s", c.golden, buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const hookShortHelp = `Install a git hook that runs dep check`
+const hookLongHelp = `
+Install a git hook in the project's repository that runs "dep check" before
+each commit, or, with -pre-push, before each push, and stops it if Gopkg.lock
+or vendor/ is out of sync with Gopkg.toml and the project's imports.
+
+dep check fetches no sources, and the pre-commit hook only runs it when the
+commit changes Go files, Gopkg.toml, Gopkg.lock or vendor/, so the hook adds
+little to commits that do not.
+
+The -block flag sets what stops the commit or push:
+
+  errors     problems that dep check reports as errors (the default)
+  warnings   warnings too, as with dep check -strict
+  none       nothing; problems are reported, and the commit goes ahead
+
+A hook of the same name that dep did not install is only replaced with -force.
+The hook can be skipped for a single commit or push with git's --no-verify.
+`
+
+// The problems a hook blocks on.
+const (
+	hookBlockErrors   = "errors"
+	hookBlockWarnings = "warnings"
+	hookBlockNone     = "none"
+)
+
+// hookMarker marks the hooks dep installs, which it may replace.
+const hookMarker = "# Installed by dep hook install."
+
+type hookCommand struct {
+	flags   *flag.FlagSet
+	prePush bool
+	block   string
+	force   bool
+}
+
+func (cmd *hookCommand) Name() string { return "hook" }
+func (cmd *hookCommand) Args() string {
+	return "install [-pre-push] [-block errors|warnings|none] [-force]"
+}
+func (cmd *hookCommand) ShortHelp() string { return hookShortHelp }
+func (cmd *hookCommand) LongHelp() string  { return hookLongHelp }
+func (cmd *hookCommand) Hidden() bool      { return false }
+
+func (cmd *hookCommand) Register(fs *flag.FlagSet) {
+	cmd.flags = fs
+	fs.BoolVar(&cmd.prePush, "pre-push", false, "install a pre-push hook, instead of a pre-commit hook")
+	fs.StringVar(&cmd.block, "block", hookBlockErrors, "what stops the commit or push: errors, warnings or none")
+	fs.BoolVar(&cmd.force, "force", false, "replace a hook that dep did not install")
+}
+
+func (cmd *hookCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 || args[0] != "install" {
+		return errors.New("dep hook requires a subcommand: install")
+	}
+	// The flags may also follow the subcommand, as in "dep hook install -pre-push".
+	if err := cmd.flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if cmd.flags.NArg() != 0 {
+		return errors.New("dep hook install takes no arguments")
+	}
+	switch cmd.block {
+	case hookBlockErrors, hookBlockWarnings, hookBlockNone:
+	default:
+		return errors.Errorf("-block must be %q, %q or %q, not %q", hookBlockErrors, hookBlockWarnings, hookBlockNone, cmd.block)
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	git := func(args ...string) (string, error) {
+		c := exec.Command("git", args...)
+		c.Dir = p.AbsRoot
+		out, err := c.Output()
+		if err != nil {
+			return "", errors.Errorf("%s is not in a git repository", p.AbsRoot)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	prefix, err := git("rev-parse", "--show-prefix")
+	if err != nil {
+		return err
+	}
+	hooksDir, err := git("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(p.AbsRoot, hooksDir)
+	}
+
+	name := "pre-commit"
+	if cmd.prePush {
+		name = "pre-push"
+	}
+	hookPath := filepath.Join(hooksDir, name)
+	if err := installHook(hookPath, hookScript(name, prefix, cmd.block), cmd.force); err != nil {
+		return err
+	}
+	ctx.Out.Printf("Installed a %s hook running dep check in %s\n", name, hookPath)
+	return nil
+}
+
+// hookScript returns the named git hook, which runs dep check on the project
+// at the path prefix in its repository, and fails as block says.
+func hookScript(name, prefix, block string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "#!/bin/sh\n%s\n# Checks that Gopkg.lock and vendor/ are in sync with Gopkg.toml and the imports.\n\n", hookMarker)
+
+	dir := strings.TrimSuffix(prefix, "/")
+	if dir == "" {
+		dir = "."
+	}
+	fmt.Fprintf(&buf, "cd \"$(git rev-parse --show-toplevel)\"/%s || exit 1\n", shellQuote(dir))
+	if name == "pre-commit" {
+		// Commits that change none of what dep check looks at cannot bring
+		// the project out of sync.
+		fmt.Fprintf(&buf, "git diff --cached --quiet -- '*.go' %s %s vendor && exit 0\n", dep.ManifestName, dep.LockName)
+	}
+
+	check := "dep check"
+	if block == hookBlockWarnings {
+		check += " -strict"
+	}
+	if block == hookBlockNone {
+		fmt.Fprintf(&buf, "%s\nexit 0\n", check)
+		return buf.Bytes()
+	}
+	verb := "commit"
+	if name == "pre-push" {
+		verb = "push"
+	}
+	fmt.Fprintf(&buf, "%s || {\n\techo \"%s: dep check failed; fix the problems above, or %s with --no-verify to skip the check\" >&2\n\texit 1\n}\n", check, name, verb)
+	return buf.Bytes()
+}
+
+// installHook writes script to the git hook at hookPath, replacing a hook that
+// is there only if dep installed it, or force is set.
+func installHook(hookPath string, script []byte, force bool) error {
+	if old, err := ioutil.ReadFile(hookPath); err == nil && !force && !bytes.Contains(old, []byte(hookMarker)) {
+		return errors.Errorf("%s already exists, and was not installed by dep; use -force to replace it", hookPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0777); err != nil {
+		return errors.Wrapf(err, "could not create %s", filepath.Dir(hookPath))
+	}
+	if err := ioutil.WriteFile(hookPath, script, 0777); err != nil {
+		return errors.Wrapf(err, "could not write %s", hookPath)
+	}
+	// WriteFile only sets the mode of files it creates.
+	return errors.Wrapf(os.Chmod(hookPath, 0777), "could not make %s executable", hookPath)
+}
+
+// shellQuote quotes s for the POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestHookScript(t *testing.T) {
+	cases := []struct {
+		name, prefix, block string
+		want, notWant       []string
+	}{
+		{
+			name: "pre-commit", block: hookBlockErrors,
+			want:    []string{hookMarker, `/'.' || exit 1`, "git diff --cached --quiet", "dep check || {", "commit with --no-verify", "exit 1"},
+			notWant: []string{"-strict"},
+		},
+		{
+			name: "pre-push", prefix: "go/app/", block: hookBlockWarnings,
+			want:    []string{`/'go/app' || exit 1`, "dep check -strict || {", "push with --no-verify"},
+			notWant: []string{"git diff"},
+		},
+		{
+			name: "pre-commit", block: hookBlockNone,
+			want:    []string{"dep check\nexit 0\n"},
+			notWant: []string{"exit 1\n}"},
+		},
+	}
+	for _, c := range cases {
+		got := string(hookScript(c.name, c.prefix, c.block))
+		if !strings.HasPrefix(got, "#!/bin/sh\n") {
+			t.Errorf("%s hook blocking on %s does not start with #!/bin/sh:\n%s", c.name, c.block, got)
+		}
+		for _, w := range c.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s hook blocking on %s does not contain %q:\n%s", c.name, c.block, w, got)
+			}
+		}
+		for _, nw := range c.notWant {
+			if strings.Contains(got, nw) {
+				t.Errorf("%s hook blocking on %s contains %q:\n%s", c.name, c.block, nw, got)
+			}
+		}
+	}
+}
+
+func TestPreCommitHookSkipsUnrelatedCommits(t *testing.T) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("repo/app")
+	repo := h.Path("repo")
+	h.RunGit(repo, "init")
+
+	hook := filepath.Join(h.Path("."), "pre-commit")
+	if err := ioutil.WriteFile(hook, hookScript("pre-commit", "app/", hookBlockErrors), 0666); err != nil {
+		t.Fatal(err)
+	}
+	// With no dep on the PATH, the hook fails whenever it runs dep check.
+	runHook := func() error {
+		c := exec.Command("sh", hook)
+		c.Dir = repo
+		c.Env = append(os.Environ(), "PATH="+filepath.Dir(git))
+		return c.Run()
+	}
+
+	h.TempFile("repo/app/README.md", "app")
+	h.RunGit(repo, "add", "app/README.md")
+	if err := runHook(); err != nil {
+		t.Fatalf("the hook ran dep check on a commit changing no Go files: %v", err)
+	}
+
+	h.TempFile("repo/app/main.go", "package main")
+	h.RunGit(repo, "add", "app/main.go")
+	if err := runHook(); err == nil {
+		t.Fatal("the hook did not run dep check on a commit changing Go files")
+	}
+}
+
+func TestInstallHook(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("hooks")
+	hookPath := filepath.Join(h.Path("hooks"), "pre-commit")
+
+	ours := hookScript("pre-commit", "", hookBlockErrors)
+	if err := installHook(hookPath, ours, false); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(hookPath); err != nil || fi.Mode()&0100 == 0 {
+		t.Fatalf("the hook was not written executable: %v %v", fi, err)
+	}
+	// dep replaces its own hooks.
+	if err := installHook(hookPath, hookScript("pre-commit", "", hookBlockWarnings), false); err != nil {
+		t.Fatal(err)
+	}
+
+	h.TempFile("hooks/pre-commit", "#!/bin/sh\nmake lint\n")
+	if err := installHook(hookPath, ours, false); err == nil {
+		t.Fatal("expected an error replacing a hook dep did not install")
+	}
+	if err := installHook(hookPath, ours, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(hookPath); string(got) != string(ours) {
+		t.Fatalf("the hook was not replaced with -force:\n%s", got)
+	}
+}
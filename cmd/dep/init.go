@@ -7,13 +7,16 @@ package main
 import (
 	"context"
 	"flag"
-	"log"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
 	"github.com/golang/dep/internal/fs"
 	"github.com/pkg/errors"
 )
@@ -86,6 +89,12 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		}
 	}
 
+	unlock, err := ctx.LockProject(root)
+	if err != nil {
+		return errors.Wrap(err, "init failed")
+	}
+	defer unlock()
+
 	p, err := cmd.establishProjectAt(root, ctx)
 	if err != nil {
 		return err
@@ -116,6 +125,12 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	if err != nil {
 		return errors.Wrap(err, "init failed: unable to prepare an initial manifest and lock for the solver")
 	}
+	reasons := make(constraintReasons)
+	if rootAnalyzer.importedFrom != "" {
+		reasons.note(p.Manifest, func(gps.ProjectRoot) string {
+			return fmt.Sprintf("Imported from %s configuration.", rootAnalyzer.importedFrom)
+		})
+	}
 
 	// Set default prune options for go-tests and unused-packages
 	p.Manifest.PruneOptions.DefaultOptions = gps.PruneNestedVendorDirs | gps.PruneGoTestFiles | gps.PruneUnusedPackages
@@ -126,6 +141,16 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		if err != nil {
 			return errors.Wrap(err, "init failed: unable to scan the GOPATH for dependencies")
 		}
+		reasons.note(p.Manifest, func(pr gps.ProjectRoot) string {
+			v, has := gs.pd.ondisk[pr]
+			if !has {
+				return ""
+			}
+			if pv, ok := v.(gps.PairedVersion); ok {
+				v = pv.Unpair()
+			}
+			return fmt.Sprintf("Found in GOPATH at %s.", v)
+		})
 	}
 
 	rootAnalyzer.skipTools = importDuringSolve()
@@ -140,7 +165,7 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	}
 
 	if ctx.Verbose {
-		params.TraceLogger = ctx.Err
+		params.TraceLogger = ctx.Logger()
 	}
 
 	if err := ctx.ValidateParams(sm, params); err != nil {
@@ -160,6 +185,17 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	p.Lock = dep.LockFromSolution(soln)
 
 	rootAnalyzer.FinalizeRootManifestAndLock(p.Manifest, p.Lock, copyLock)
+	solved := make(map[gps.ProjectRoot]gps.Version)
+	for _, lp := range p.Lock.P {
+		solved[lp.Ident().ProjectRoot] = lp.Version()
+	}
+	reasons.note(p.Manifest, func(pr gps.ProjectRoot) string {
+		if v, has := solved[pr]; has {
+			return solvedReason(v)
+		}
+		return ""
+	})
+	annotateConstraints(p.Manifest, ptree, reasons)
 
 	// Run gps.Prepare with appropriate constraint solutions from solve run
 	// to generate the final lock memo.
@@ -183,14 +219,13 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	if err != nil {
 		return errors.Wrap(err, "init failed: unable to create a SafeWriter")
 	}
+	sw.UseTreeStore(ctx.TreeStore(), ctx.SymlinkVendor)
+	sw.UseDigestMemo(p.DigestMemo)
 
-	var logger *log.Logger
-	if ctx.Verbose {
-		logger = ctx.Err
-	}
-	if err := sw.Write(root, sm, !cmd.noExamples, logger); err != nil {
+	if err := sw.Write(root, sm, !cmd.noExamples, ctx.Logger()); err != nil {
 		return errors.Wrap(err, "init failed: unable to write the manifest, lock and vendor directory to disk")
 	}
+	sm.RecordLock(root, p.Lock)
 
 	return nil
 }
@@ -242,3 +277,79 @@ func (cmd *initCommand) establishProjectAt(root string, ctx *dep.Ctx) (*dep.Proj
 
 	return p, nil
 }
+
+// constraintReasons records why each of the constraints dep init adds to the
+// manifest was chosen, so that the initial manifest can explain itself.
+type constraintReasons map[gps.ProjectRoot]string
+
+// note records reason for every constraint in m that does not have one yet.
+// Constraints for which reason returns "" are skipped.
+func (cr constraintReasons) note(m *dep.Manifest, reason func(gps.ProjectRoot) string) {
+	for pr := range m.Constraints {
+		if _, has := cr[pr]; has {
+			continue
+		}
+		if r := reason(pr); r != "" {
+			cr[pr] = r
+		}
+	}
+}
+
+// solvedReason describes the version v that the solver chose for a project
+// that had no constraint before solving.
+func solvedReason(v gps.Version) string {
+	if pv, ok := v.(gps.PairedVersion); ok {
+		v = pv.Unpair()
+	}
+	switch v.Type() {
+	case gps.IsSemver:
+		return fmt.Sprintf("Selected by the solver when dep init ran: release %s.", v)
+	case gps.IsVersion:
+		return fmt.Sprintf("Selected by the solver when dep init ran: tag %s.", v)
+	case gps.IsBranch:
+		return fmt.Sprintf("Selected by the solver when dep init ran: branch %s.", v)
+	}
+	return ""
+}
+
+// maxImportersListed is the number of importing packages named in the comment
+// of an initial constraint, before the rest are counted.
+const maxImportersListed = 3
+
+// annotateConstraints sets the comments of the constraints in m, from their
+// reasons, and from the packages in ptree that import each project.
+func annotateConstraints(m *dep.Manifest, ptree pkgtree.PackageTree, reasons constraintReasons) {
+	importers := make(map[gps.ProjectRoot][]string)
+	for ip, poe := range ptree.Packages {
+		if poe.Err != nil {
+			continue
+		}
+		for pr := range m.Constraints {
+			for _, imp := range poe.P.Imports {
+				if imp == string(pr) || strings.HasPrefix(imp, string(pr)+"/") {
+					importers[pr] = append(importers[pr], ip)
+					break
+				}
+			}
+		}
+	}
+
+	m.ConstraintComments = make(map[gps.ProjectRoot][]string, len(m.Constraints))
+	for pr := range m.Constraints {
+		var comment []string
+		if r, has := reasons[pr]; has {
+			comment = append(comment, r)
+		}
+		if pkgs := importers[pr]; len(pkgs) > 0 {
+			sort.Strings(pkgs)
+			imported := "Imported by " + strings.Join(pkgs, ", ")
+			if len(pkgs) > maxImportersListed {
+				imported = fmt.Sprintf("Imported by %s, and %d more", strings.Join(pkgs[:maxImportersListed], ", "), len(pkgs)-maxImportersListed)
+			}
+			comment = append(comment, imported+".")
+		}
+		if len(comment) > 0 {
+			m.ConstraintComments[pr] = comment
+		}
+	}
+}
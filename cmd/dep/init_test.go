@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+func TestSolvedReason(t *testing.T) {
+	rev := gps.Revision("3f4c3bea144e112a69bbe5d8d01c1b09a544253f")
+	cases := []struct {
+		v    gps.Version
+		want string
+	}{
+		{gps.NewVersion("v1.2.0").Pair(rev), "Selected by the solver when dep init ran: release v1.2.0."},
+		{gps.NewVersion("stable"), "Selected by the solver when dep init ran: tag stable."},
+		{gps.NewBranch("master").Pair(rev), "Selected by the solver when dep init ran: branch master."},
+		{rev, ""},
+	}
+
+	for _, c := range cases {
+		if got := solvedReason(c.v); got != c.want {
+			t.Errorf("solvedReason(%s) = %q, want %q", c.v, got, c.want)
+		}
+	}
+}
+
+func TestAnnotateConstraints(t *testing.T) {
+	m := dep.NewManifest()
+	for _, pr := range []gps.ProjectRoot{"github.com/a/dep", "github.com/b/dep", "github.com/c/dep"} {
+		m.Constraints[pr] = gps.ProjectProperties{Constraint: gps.NewBranch("master")}
+	}
+
+	pkg := func(ip string, imports ...string) pkgtree.PackageOrErr {
+		return pkgtree.PackageOrErr{P: pkgtree.Package{ImportPath: ip, Imports: imports}}
+	}
+	ptree := pkgtree.PackageTree{
+		ImportRoot: "github.com/root",
+		Packages: map[string]pkgtree.PackageOrErr{
+			"github.com/root":   pkg("github.com/root", "github.com/a/dep", "github.com/b/dep/sub"),
+			"github.com/root/1": pkg("github.com/root/1", "github.com/b/dep"),
+			"github.com/root/2": pkg("github.com/root/2", "github.com/b/dep", "github.com/a/depot"),
+			"github.com/root/3": pkg("github.com/root/3", "github.com/b/dep"),
+		},
+	}
+	reasons := constraintReasons{
+		"github.com/a/dep": "Found in GOPATH at v1.0.0.",
+	}
+	reasons.note(m, func(pr gps.ProjectRoot) string {
+		if pr == "github.com/c/dep" {
+			return ""
+		}
+		return "Imported from glide configuration."
+	})
+
+	annotateConstraints(m, ptree, reasons)
+
+	want := map[gps.ProjectRoot][]string{
+		"github.com/a/dep": {
+			"Found in GOPATH at v1.0.0.",
+			"Imported by github.com/root.",
+		},
+		"github.com/b/dep": {
+			"Imported from glide configuration.",
+			"Imported by github.com/root, github.com/root/1, github.com/root/2, and 1 more.",
+		},
+	}
+	if !reflect.DeepEqual(m.ConstraintComments, want) {
+		t.Errorf("unexpected comments:\n\t(GOT): %v\n\t(WNT): %v", m.ConstraintComments, want)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const listShortHelp = `List the project's dependencies as go list -m does`
+const listLongHelp = `
+List the project, and its dependencies in Gopkg.lock, as modules, in the
+forms "go list -m" writes them, so that scripts written for Go modules work
+on dep projects unchanged.
+
+With no arguments, only the project itself, the main module, is listed. With
+"all", every locked project is listed after it; otherwise, the modules named
+are. Each is listed by its path and version, and the module it is replaced
+with, if any, as "dep export" would write them to go.mod:
+
+  example.com/app
+  github.com/foo/bar v1.2.0
+  github.com/foo/fork v0.3.0 => github.com/fork/fork v0.3.0
+
+dep does not record the times of revisions, so projects locked to a branch or
+bare revision are listed at a pseudo-version with the zero time, such as
+v0.0.0-00010101000000-8a6d0e6c2c5f.
+
+With -json, each module is written as a JSON object with the fields Path,
+Version, Replace, Main, Indirect and Dir, as go list -m -json writes them. With
+-f, each is written with the given Go template, applied to the same fields.
+`
+
+type listCommand struct {
+	modules bool
+	json    bool
+	format  string
+}
+
+func (cmd *listCommand) Name() string      { return "list" }
+func (cmd *listCommand) Args() string      { return "-m [-json | -f format] [all | <module>...]" }
+func (cmd *listCommand) ShortHelp() string { return listShortHelp }
+func (cmd *listCommand) LongHelp() string  { return listLongHelp }
+func (cmd *listCommand) Hidden() bool      { return false }
+
+func (cmd *listCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.modules, "m", false, "list modules; dep list only lists modules")
+	fs.BoolVar(&cmd.json, "json", false, "write each module as a JSON object")
+	fs.StringVar(&cmd.format, "f", "", "write each module with this Go template")
+}
+
+// listedModule is a module, as go list -m -json describes it.
+type listedModule struct {
+	Path     string
+	Version  string        `json:",omitempty"`
+	Replace  *listedModule `json:",omitempty"`
+	Main     bool          `json:",omitempty"`
+	Indirect bool          `json:",omitempty"`
+	Dir      string        `json:",omitempty"`
+}
+
+// String returns the module as go list -m writes it.
+func (m listedModule) String() string {
+	s := m.Path
+	if m.Version != "" {
+		s += " " + m.Version
+	}
+	if m.Replace != nil {
+		s += " => " + m.Replace.Path
+		if m.Replace.Version != "" {
+			s += " " + m.Replace.Version
+		}
+	}
+	return s
+}
+
+func (cmd *listCommand) Run(ctx *dep.Ctx, args []string) error {
+	if !cmd.modules {
+		return errors.New("dep list only lists modules, and must be given -m")
+	}
+	if cmd.json && cmd.format != "" {
+		return errors.New("-json and -f cannot be given together")
+	}
+	var tmpl *template.Template
+	if cmd.format != "" {
+		var err error
+		if tmpl, err = template.New("list").Parse(cmd.format); err != nil {
+			return errors.Wrap(err, "-f")
+		}
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	// Direct dependencies are found from the project's imports; no sources
+	// are needed.
+	sm, err := ctx.LazySourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	var direct map[gps.ProjectRoot]bool
+	if p.Lock != nil {
+		if _, direct, err = p.GetDirectDependencyNames(sm); err != nil {
+			return err
+		}
+	}
+	modules, err := selectModules(listModules(p, direct), args)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, m := range modules {
+		if err := cmd.write(&buf, tmpl, m); err != nil {
+			return err
+		}
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// write writes the module m to w as the flags have it.
+func (cmd *listCommand) write(w io.Writer, tmpl *template.Template, m listedModule) error {
+	switch {
+	case cmd.json:
+		b, err := json.MarshalIndent(m, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case tmpl != nil:
+		if err := tmpl.Execute(w, m); err != nil {
+			return errors.Wrap(err, "-f")
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	}
+	_, err := fmt.Fprintln(w, m)
+	return err
+}
+
+// listModules returns the main module p, followed by the projects in its lock,
+// as modules. direct holds the projects p imports.
+func listModules(p *dep.Project, direct map[gps.ProjectRoot]bool) []listedModule {
+	modules := []listedModule{{Path: string(p.ImportRoot), Main: true, Dir: p.AbsRoot}}
+	if p.Lock == nil {
+		return modules
+	}
+
+	mod, _ := newGoModule(p, direct)
+	replaces := make(map[string]modReplace, len(mod.replaces))
+	for _, rep := range mod.replaces {
+		replaces[rep.old] = rep
+	}
+	for _, req := range mod.requires {
+		version := req.version
+		if !req.canonical {
+			version = zeroPseudoVersion(version)
+		}
+		m := listedModule{Path: req.path, Version: version, Indirect: req.indirect}
+		if rep, has := replaces[req.path]; has {
+			m.Replace = &listedModule{Path: rep.new}
+			if rep.version != "" {
+				m.Replace.Version = version
+			} else {
+				m.Replace.Dir = rep.new
+			}
+		}
+		modules = append(modules, m)
+	}
+	return modules
+}
+
+// selectModules returns the modules that args name, as go list -m selects
+// them: the main module if there are no args, all of them with "all", and
+// otherwise those with the paths given.
+func selectModules(modules []listedModule, args []string) ([]listedModule, error) {
+	if len(args) == 0 {
+		return modules[:1], nil
+	}
+	if len(args) == 1 && args[0] == "all" {
+		return modules, nil
+	}
+
+	var selected []listedModule
+	for _, arg := range args {
+		found := false
+		for _, m := range modules {
+			if m.Path == arg {
+				selected, found = append(selected, m), true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("module %s: not a known dependency", arg)
+		}
+	}
+	return selected, nil
+}
+
+// zeroPseudoVersion returns the pseudo-version of the revision rev, without
+// the time of the revision, which dep does not know.
+func zeroPseudoVersion(rev string) string {
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	return "v0.0.0-00010101000000-" + rev
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestListModules(t *testing.T) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	m := dep.NewManifest()
+	m.Ovr["github.com/foo/over"] = gps.ProjectProperties{Constraint: gps.NewVersion("v1.0.0")}
+	p := &dep.Project{
+		AbsRoot:    "/go/src/example.com/app",
+		ImportRoot: "example.com/app",
+		Manifest:   m,
+		Lock: &dep.Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.2.0").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/branch"}, gps.NewBranch("master").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/fork", Source: "https://github.com/fork/fork.git"}, gps.NewVersion("v0.3.0").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/local", Source: "/src/local"}, gps.NewVersion("v0.1.0").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/over"}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+			},
+		},
+	}
+	direct := map[gps.ProjectRoot]bool{"github.com/foo/bar": true, "github.com/foo/fork": true}
+	modules := listModules(p, direct)
+
+	var buf bytes.Buffer
+	cmd := &listCommand{modules: true}
+	for _, m := range modules {
+		if err := cmd.write(&buf, nil, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `example.com/app
+github.com/foo/bar v1.2.0
+github.com/foo/branch v0.0.0-00010101000000-8a6d0e6c2c5f
+github.com/foo/fork v0.3.0 => github.com/fork/fork v0.3.0
+github.com/foo/local v0.1.0 => /src/local
+github.com/foo/over v1.0.0 => github.com/foo/over v1.0.0
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected list:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	buf.Reset()
+	cmd = &listCommand{modules: true, json: true}
+	if err := cmd.write(&buf, nil, modules[3]); err != nil {
+		t.Fatal(err)
+	}
+	want = `{
+	"Path": "github.com/foo/fork",
+	"Version": "v0.3.0",
+	"Replace": {
+		"Path": "github.com/fork/fork",
+		"Version": "v0.3.0"
+	}
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected JSON:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	buf.Reset()
+	cmd = &listCommand{modules: true, format: "{{.Path}} {{.Main}} {{.Indirect}}"}
+	tmpl := template.Must(template.New("list").Parse(cmd.format))
+	for _, m := range modules[:3] {
+		if err := cmd.write(&buf, tmpl, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want = "example.com/app true false\ngithub.com/foo/bar false false\ngithub.com/foo/branch false true\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected templated list:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+}
+
+func TestSelectModules(t *testing.T) {
+	modules := []listedModule{
+		{Path: "example.com/app", Main: true},
+		{Path: "github.com/foo/bar", Version: "v1.2.0"},
+		{Path: "github.com/foo/baz", Version: "v0.1.0"},
+	}
+	paths := func(mm []listedModule) string {
+		var s []string
+		for _, m := range mm {
+			s = append(s, m.Path)
+		}
+		return strings.Join(s, " ")
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "example.com/app"},
+		{[]string{"all"}, "example.com/app github.com/foo/bar github.com/foo/baz"},
+		{[]string{"github.com/foo/baz", "example.com/app"}, "github.com/foo/baz example.com/app"},
+	}
+	for _, c := range cases {
+		got, err := selectModules(modules, c.args)
+		if err != nil {
+			t.Errorf("selecting %v: %v", c.args, err)
+			continue
+		}
+		if paths(got) != c.want {
+			t.Errorf("unexpected modules selected by %v:\n\t(GOT): %s\n\t(WNT): %s", c.args, paths(got), c.want)
+		}
+	}
+
+	if _, err := selectModules(modules, []string{"github.com/foo/qux"}); err == nil {
+		t.Error("expected an error selecting a module that is not a dependency")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const lockShortHelp = `Sign Gopkg.lock, or verify its signature`
+const lockLongHelp = `
+Produce or check a detached signature over Gopkg.lock.
+
+  sign     Sign Gopkg.lock, writing the signature next to it
+  verify   Verify the signature next to Gopkg.lock
+
+The signature is made over the canonical form of Gopkg.lock - the lock as dep
+would write it, without its leading comment - with either gpg or minisign.
+gpg signatures are written to Gopkg.lock.asc, and minisign signatures to
+Gopkg.lock.minisig.
+
+The -key flag selects the key to use: a gpg key to sign with, or that the
+signature must have been made by, or a minisign secret key file to sign with
+or public key file to verify with. When it is omitted, the tool's default key
+is used to sign; gpg then accepts a signature by any key in the keyring, so
+pass -key to pin the signer when verifying. The signer is pinned by the full
+fingerprint of its key, or by the whole of its primary user ID or the email
+address in it; key IDs are not accepted.
+
+To have dep ensure refuse to run against a lock that was altered after it was
+signed, verify the signature in a pre-ensure hook:
+
+  [hooks]
+    pre-ensure = ["dep lock verify"]
+`
+
+type lockCommand struct {
+	tool string
+	key  string
+}
+
+func (cmd *lockCommand) Name() string      { return "lock" }
+func (cmd *lockCommand) Args() string      { return "[-tool gpg|minisign] [-key key] sign|verify" }
+func (cmd *lockCommand) ShortHelp() string { return lockShortHelp }
+func (cmd *lockCommand) LongHelp() string  { return lockLongHelp }
+func (cmd *lockCommand) Hidden() bool      { return false }
+
+func (cmd *lockCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.tool, "tool", dep.SignatureGPG, "signing tool: gpg or minisign")
+	fs.StringVar(&cmd.key, "key", "", "key to sign with, or that must have made the signature, instead of the tool's default")
+}
+
+func (cmd *lockCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 || (args[0] != "sign" && args[0] != "verify") {
+		return errors.New("dep lock takes exactly one of sign or verify")
+	}
+	if _, err := dep.LockSignatureName(cmd.tool); err != nil {
+		return err
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+
+	if args[0] == "sign" {
+		sigPath, err := ctx.SignLock(p, cmd.tool, cmd.key)
+		if err != nil {
+			return err
+		}
+		ctx.Out.Printf("Wrote the signature of %s to %s\n", dep.LockName, sigPath)
+		return nil
+	}
+
+	if err := ctx.VerifyLockSignature(p, cmd.tool, cmd.key); err != nil {
+		return err
+	}
+	ctx.Out.Printf("The signature of %s is valid\n", dep.LockName)
+	return nil
+}
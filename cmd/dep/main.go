@@ -16,12 +16,17 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/golang/dep"
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/internal/events"
 	"github.com/golang/dep/internal/fs"
+	"github.com/golang/dep/internal/metrics"
 )
 
 var (
@@ -46,6 +51,10 @@ func main() {
 	flag.IntVar(&p.memProfileRate, "memprofilerate", 0, "Enable more precise memory profiles by setting runtime.MemProfileRate.")
 	flag.StringVar(&p.mutexProfile, "mutexprofile", "", "Writes a mutex profile to the specified file before exiting.")
 	flag.IntVar(&p.mutexProfileFraction, "mutexprofilefraction", 0, "Enable more precise mutex profiles by runtime.SetMutexProfileFraction.")
+	flag.StringVar(&p.blockProfile, "blockprofile", "", "Writes a goroutine blocking profile to the specified file before exiting.")
+	flag.IntVar(&p.blockProfileRate, "blockprofilerate", 0, "Control the detail of the blocking profile by runtime.SetBlockProfileRate.")
+	flag.StringVar(&p.trace, "trace", "", "Writes an execution trace to the specified file before exiting.")
+	metricsFile := flag.String("metrics", "", "Writes the metrics of the run to the specified file in the Prometheus text format before exiting.")
 	flag.Parse()
 
 	wd, err := os.Getwd()
@@ -72,6 +81,12 @@ func main() {
 		fmt.Fprintf(os.Stderr, "failed to finish the profile: %v\n", err)
 		os.Exit(1)
 	}
+	if *metricsFile != "" {
+		if err := metrics.Default.WriteTextfile(*metricsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write the metrics: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	os.Exit(exit)
 }
 
@@ -89,8 +104,26 @@ func (c *Config) Run() int {
 	commands := [...]command{
 		&initCommand{},
 		&statusCommand{},
+		&explainCommand{},
+		&listCommand{},
 		&ensureCommand{},
+		&checkCommand{},
 		&pruneCommand{},
+		&lockCommand{},
+		&sbomCommand{},
+		&graphCommand{},
+		&reportCommand{},
+		&stampCommand{},
+		&exportCommand{},
+		&bazelCommand{},
+		&archiveCommand{},
+		&mergeLockCommand{},
+		&migrateManifestCommand{},
+		&fmtCommand{},
+		&cacheCommand{},
+		&hookCommand{},
+		&daemonCommand{},
+		&serveCommand{},
 		&hashinCommand{},
 		&versionCommand{},
 	}
@@ -185,6 +218,9 @@ func (c *Config) Run() int {
 			flags := flag.NewFlagSet(cmdName, flag.ContinueOnError)
 			flags.SetOutput(c.Stderr)
 			verbose := flags.Bool("v", false, "enable verbose logging")
+			eventsFormat := flags.String("events", "", "stream the events of the run to -events-fd, in this format: ndjson")
+			eventsFD := flags.Int("events-fd", 3, "the file descriptor to stream events to")
+			logFormat := flags.String("log-format", "text", "the format of diagnostic messages: text or json")
 
 			// Register the subcommand flags in there, too.
 			cmd.Register(flags)
@@ -227,14 +263,86 @@ func (c *Config) Run() int {
 				}
 			}
 
+			fetchJobs := defaultFetchJobs
+			if env := getEnv(c.Env, "DEPFETCHJOBS"); env != "" {
+				n, err := strconv.Atoi(env)
+				if err != nil || n < 1 {
+					errLogger.Printf("dep: $DEPFETCHJOBS must be a positive number, not %q\n", env)
+					return errorExitCode
+				}
+				fetchJobs = n
+			}
+
+			if env := getEnv(c.Env, "DEPFSJOBS"); env != "" {
+				n, err := strconv.Atoi(env)
+				if err != nil || n < 1 {
+					errLogger.Printf("dep: $DEPFSJOBS must be a positive number, not %q\n", env)
+					return errorExitCode
+				}
+				fs.SetJobs(n)
+			}
+
+			// Fetches are only displayed live on a terminal, where they can
+			// be redrawn in place.
+			var progress io.Writer
+			if f, ok := c.Stderr.(*os.File); ok && getEnv(c.Env, "DEPNOPROGRESS") == "" {
+				if fi, err := f.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+					progress = c.Stderr
+				}
+			}
+
+			if *eventsFormat != "" {
+				stream, err := openEvents(*eventsFormat, *eventsFD)
+				if err != nil {
+					errLogger.Printf("dep: %v\n", err)
+					return errorExitCode
+				}
+				events.SetDefault(stream)
+				defer events.SetDefault(nil)
+			}
+
+			// Diagnostics are colored only on a terminal, and are at the
+			// debug level only with -v.
+			var logOpts logging.Options
+			switch *logFormat {
+			case "text":
+				logOpts.Color = logging.ColorEnabled(c.Stderr)
+			case "json":
+				logOpts.JSON = true
+			default:
+				errLogger.Printf("dep: -log-format must be text or json, not %q\n", *logFormat)
+				return errorExitCode
+			}
+			if !*verbose {
+				logOpts.Level = logging.Info
+			}
+
+			config, err := dep.LoadConfig(getEnv(c.Env, "DEPCONFIG"))
+			if err != nil {
+				errLogger.Printf("dep: failed to load configuration: %v\n", err)
+				return errorExitCode
+			}
+
 			// Set up dep context.
 			ctx := &dep.Ctx{
 				Out:            outLogger,
 				Err:            errLogger,
+				Log:            logging.New(c.Stderr, logOpts),
 				Verbose:        *verbose,
 				DisableLocking: getEnv(c.Env, "DEPNOLOCK") != "",
+				DisableHooks:   getEnv(c.Env, "DEPNOHOOKS") != "",
 				Cachedir:       cachedir,
+				SymlinkVendor:  getEnv(c.Env, "DEPVENDORSYMLINKS") != "",
 				CacheAge:       cacheAge,
+				ShallowClones:  getEnv(c.Env, "DEPSHALLOWCLONE") != "",
+				PartialClones:  getEnv(c.Env, "DEPPARTIALCLONE") != "",
+				VersionAPI:     getEnv(c.Env, "DEPVERSIONAPI") != "",
+				RestrictedVCS:  getEnv(c.Env, "DEPRESTRICTVCS") != "",
+				Config:         config,
+				FetchJobs:      fetchJobs,
+				Progress:       progress,
+				Daemon:         getEnv(c.Env, "DEPDAEMON"),
+				Command:        strings.Join(append([]string{"dep"}, c.Args[1:]...), " "),
 			}
 
 			GOPATHS := filepath.SplitList(getEnv(c.Env, "GOPATH"))
@@ -256,6 +364,22 @@ func (c *Config) Run() int {
 	return errorExitCode
 }
 
+// openEvents returns the stream of events, in format, to the file descriptor
+// fd, which the process must have been started with open.
+func openEvents(format string, fd int) (*events.Stream, error) {
+	if format != "ndjson" {
+		return nil, fmt.Errorf("-events must be ndjson, not %q", format)
+	}
+	f := os.NewFile(uintptr(fd), "events")
+	if f == nil {
+		return nil, fmt.Errorf("-events-fd %d is not a file descriptor", fd)
+	}
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("-events-fd %d is not open for the events to be written to", fd)
+	}
+	return events.NewStream(f), nil
+}
+
 func resetUsage(logger *log.Logger, fs *flag.FlagSet, name, args, longHelp string) {
 	var (
 		hasFlags   bool
@@ -371,56 +495,121 @@ type profile struct {
 	mutexProfile         string
 	mutexProfileFraction int
 
-	// TODO(jbd): Add block profile and -trace.
+	blockProfile     string
+	blockProfileRate int
 
-	f *os.File // file to write the profiling output to
+	trace string
+
+	// The files to write each profile to, if it was asked for.
+	cpuf, memf, mutexf, blockf, tracef *os.File
 }
 
+// start creates the files of the profiles that were asked for, and starts
+// those that are recorded as the program runs. Any number of profiles may be
+// asked for at once.
 func (p *profile) start() error {
-	switch {
-	case p.cpuProfile != "":
-		if err := p.createOutput(p.cpuProfile); err != nil {
+	for _, out := range []struct {
+		name string
+		f    **os.File
+	}{
+		{p.cpuProfile, &p.cpuf},
+		{p.memProfile, &p.memf},
+		{p.mutexProfile, &p.mutexf},
+		{p.blockProfile, &p.blockf},
+		{p.trace, &p.tracef},
+	} {
+		if out.name == "" {
+			continue
+		}
+		f, err := os.Create(out.name)
+		if err != nil {
+			p.close()
 			return err
 		}
-		return pprof.StartCPUProfile(p.f)
-	case p.memProfile != "":
-		if p.memProfileRate > 0 {
-			runtime.MemProfileRate = p.memProfileRate
+		*out.f = f
+	}
+
+	if p.memf != nil && p.memProfileRate > 0 {
+		runtime.MemProfileRate = p.memProfileRate
+	}
+	if p.mutexf != nil {
+		fraction := p.mutexProfileFraction
+		if fraction <= 0 {
+			fraction = 1
+		}
+		runtime.SetMutexProfileFraction(fraction)
+	}
+	if p.blockf != nil {
+		rate := p.blockProfileRate
+		if rate <= 0 {
+			rate = 1
 		}
-		return p.createOutput(p.memProfile)
-	case p.mutexProfile != "":
-		if p.mutexProfileFraction > 0 {
-			runtime.SetMutexProfileFraction(p.mutexProfileFraction)
+		runtime.SetBlockProfileRate(rate)
+	}
+	if p.cpuf != nil {
+		if err := pprof.StartCPUProfile(p.cpuf); err != nil {
+			p.close()
+			return err
+		}
+	}
+	if p.tracef != nil {
+		if err := trace.Start(p.tracef); err != nil {
+			if p.cpuf != nil {
+				pprof.StopCPUProfile()
+			}
+			p.close()
+			return err
 		}
-		return p.createOutput(p.mutexProfile)
 	}
 	return nil
 }
 
+// finish stops the profiles that were started, writes out those that are
+// taken at exit, and closes their files.
 func (p *profile) finish() error {
-	if p.f == nil {
-		return nil
+	if p.tracef != nil {
+		trace.Stop()
 	}
-	switch {
-	case p.cpuProfile != "":
+	if p.cpuf != nil {
 		pprof.StopCPUProfile()
-	case p.memProfile != "":
-		if err := pprof.WriteHeapProfile(p.f); err != nil {
-			return err
-		}
-	case p.mutexProfile != "":
-		if err := pprof.Lookup("mutex").WriteTo(p.f, 2); err != nil {
-			return err
+	}
+
+	var err error
+	if p.memf != nil {
+		// Get up-to-date statistics, as the heap profile is only updated
+		// by garbage collections.
+		runtime.GC()
+		err = pprof.WriteHeapProfile(p.memf)
+	}
+	for _, prof := range []struct {
+		name string
+		f    *os.File
+	}{
+		{"mutex", p.mutexf},
+		{"block", p.blockf},
+	} {
+		if prof.f != nil && err == nil {
+			err = pprof.Lookup(prof.name).WriteTo(prof.f, 0)
 		}
 	}
-	return p.f.Close()
+
+	if cerr := p.close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
-func (p *profile) createOutput(name string) error {
-	f, err := os.Create(name)
-	if err != nil {
-		return err
+// close closes the files of the profiles, returning the first error.
+func (p *profile) close() error {
+	var err error
+	for _, f := range []*os.File{p.cpuf, p.memf, p.mutexf, p.blockf, p.tracef} {
+		if f == nil {
+			continue
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
 	}
-	p.f = f
-	return nil
+	p.cpuf, p.memf, p.mutexf, p.blockf, p.tracef = nil, nil, nil, nil, nil
+	return err
 }
@@ -0,0 +1,124 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const mergeLockShortHelp = `Merge conflicting versions of Gopkg.lock`
+const mergeLockLongHelp = `
+Perform a three-way merge of Gopkg.lock, as a git merge driver.
+
+<base>, <ours> and <theirs> are the common ancestor and the two versions of
+Gopkg.lock to merge. The result is written to <ours>. [path] is the path of
+Gopkg.lock in the working tree, which is used to find the project; it defaults
+to the Gopkg.lock in the current directory.
+
+Projects that were changed on only one side are taken from that side. Projects
+that were changed differently on both sides are solved for again, with every
+other project kept at its merged version. If nothing was disputed, and the
+merge matches one side, no solve is needed.
+
+To use it, declare the driver in your git config:
+
+  [merge "dep-lock"]
+    name = dep Gopkg.lock merge driver
+    driver = dep merge-lock %O %A %B %P
+
+and assign it to Gopkg.lock in .gitattributes:
+
+  Gopkg.lock merge=dep-lock
+
+After a merge, run dep ensure to bring vendor/ in line with the merged lock.
+`
+
+type mergeLockCommand struct{}
+
+func (cmd *mergeLockCommand) Name() string      { return "merge-lock" }
+func (cmd *mergeLockCommand) Args() string      { return "<base> <ours> <theirs> [path]" }
+func (cmd *mergeLockCommand) ShortHelp() string { return mergeLockShortHelp }
+func (cmd *mergeLockCommand) LongHelp() string  { return mergeLockLongHelp }
+func (cmd *mergeLockCommand) Hidden() bool      { return false }
+
+func (cmd *mergeLockCommand) Register(fs *flag.FlagSet) {}
+
+func (cmd *mergeLockCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 3 && len(args) != 4 {
+		return errors.New("dep merge-lock takes the base, ours and theirs versions of the lock, and optionally its path")
+	}
+
+	var locks [3]*dep.Lock
+	for i, path := range args[:3] {
+		l, err := dep.ReadLockFile(path)
+		if err != nil {
+			return err
+		}
+		locks[i] = l
+	}
+	base, ours, theirs := locks[0], locks[1], locks[2]
+	if ours == nil || theirs == nil {
+		return errors.New("both sides of the merge must have a lock")
+	}
+	oursPath := args[1]
+
+	lm := dep.MergeLocks(base, ours, theirs)
+	if l := lm.Trivial(); l != nil {
+		return dep.WriteLockFile(oursPath, l)
+	}
+
+	for _, pr := range lm.Disputed {
+		ctx.Logger().Infof("merge-lock: %s was changed on both sides, solving for it again", pr)
+	}
+
+	// The project is found from the directory holding the lock in the working
+	// tree, since git runs the driver from the top of the repository.
+	if len(args) == 4 {
+		wd := filepath.Dir(args[3])
+		if !filepath.IsAbs(wd) {
+			wd = filepath.Join(ctx.WorkingDir, wd)
+		}
+		ctx.WorkingDir = wd
+	}
+	p, unlock, err := ctx.LoadLockedProject()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	params := p.MakeParams()
+	params.Lock = lm.Lock
+	if ctx.Verbose {
+		params.TraceLogger = ctx.Logger()
+	}
+	params.RootPackageTree, err = p.ParseRootPackageTree()
+	if err != nil {
+		return err
+	}
+
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return errors.Wrap(err, "prepare solver")
+	}
+	solution, err := solver.Solve(context.TODO())
+	if err != nil {
+		return handleAllTheFailuresOfTheWorld(err)
+	}
+
+	return dep.WriteLockFile(oursPath, lm.Resolve(solution))
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const migrateManifestShortHelp = `Upgrade Gopkg.toml to the current layout`
+const migrateManifestLongHelp = `
+Upgrade Gopkg.toml from an older layout to the one this version of dep uses,
+and record the layout's version in its schema-version field.
+
+dep reads manifests with an older layout by upgrading them as they are read,
+and warns that they should be migrated. A manifest with a newer schema-version
+than dep supports is rejected, rather than misread.
+
+The manifest is edited in place, so that its comments and formatting are kept.
+With -dry-run, the upgraded manifest is printed instead.
+`
+
+type migrateManifestCommand struct {
+	dryRun bool
+}
+
+func (cmd *migrateManifestCommand) Name() string      { return "migrate-manifest" }
+func (cmd *migrateManifestCommand) Args() string      { return "[-dry-run]" }
+func (cmd *migrateManifestCommand) ShortHelp() string { return migrateManifestShortHelp }
+func (cmd *migrateManifestCommand) LongHelp() string  { return migrateManifestLongHelp }
+func (cmd *migrateManifestCommand) Hidden() bool      { return false }
+
+func (cmd *migrateManifestCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
+}
+
+func (cmd *migrateManifestCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep migrate-manifest takes no arguments")
+	}
+
+	// Loading the project would warn that the manifest should be migrated,
+	// which is what is being done.
+	qctx := *ctx
+	qctx.Err = log.New(ioutil.Discard, "", 0)
+	p, unlock, err := qctx.LoadLockedProject()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	mpath := filepath.Join(p.AbsRoot, dep.ManifestName)
+	data, err := ioutil.ReadFile(mpath)
+	if err != nil {
+		return errors.Wrapf(err, "could not read %s", mpath)
+	}
+
+	data, changes, err := dep.MigrateManifest(data)
+	if err != nil {
+		return errors.Wrapf(err, "could not migrate %s", dep.ManifestName)
+	}
+	if len(changes) == 0 {
+		ctx.Err.Printf("%s is already at schema version %d\n", dep.ManifestName, dep.CurrentManifestSchemaVersion)
+		return nil
+	}
+
+	for _, change := range changes {
+		ctx.Err.Printf("%s: %s\n", dep.ManifestName, change)
+	}
+	if cmd.dryRun {
+		ctx.Out.Printf("%s", data)
+		return nil
+	}
+	return errors.Wrapf(ioutil.WriteFile(mpath, data, 0666), "could not write %s", mpath)
+}
@@ -8,7 +8,6 @@ import (
 	"bytes"
 	"flag"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -16,7 +15,7 @@ import (
 
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
-	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/internal/fs"
 	"github.com/pkg/errors"
 )
@@ -48,10 +47,11 @@ func (cmd *pruneCommand) Run(ctx *dep.Ctx, args []string) error {
 	ctx.Err.Printf("\nNow is the time to update your Gopkg.toml and remove `dep prune` from any scripts.\n")
 	ctx.Err.Printf("\nFor more information, see: https://golang.github.io/dep/docs/Gopkg.toml.html#prune\n")
 
-	p, err := ctx.LoadProject()
+	p, unlock, err := ctx.LoadLockedProject()
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
 	sm, err := ctx.SourceManager()
 	if err != nil {
@@ -60,51 +60,32 @@ func (cmd *pruneCommand) Run(ctx *dep.Ctx, args []string) error {
 	sm.UseDefaultSignalHandling()
 	defer sm.Release()
 
-	// While the network churns on ListVersions() requests, statically analyze
-	// code from the current project.
-	ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
-	if err != nil {
-		return errors.Wrap(err, "analysis of local packages failed: %v")
-	}
-
-	// Set up a solver in order to check the InputHash.
-	params := p.MakeParams()
-	params.RootPackageTree = ptree
-
-	if ctx.Verbose {
-		params.TraceLogger = ctx.Err
-	}
-
-	s, err := gps.Prepare(params, sm)
-	if err != nil {
-		return errors.Wrap(err, "could not set up solver for input hashing")
-	}
-
 	if p.Lock == nil {
 		return errors.Errorf("Gopkg.lock must exist for prune to know what files are safe to remove.")
 	}
 
-	if !bytes.Equal(s.HashInputs(), p.Lock.SolveMeta.InputsDigest) {
+	digest, _, err := p.InputsDigest(sm)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, p.Lock.SolveMeta.InputsDigest) {
 		return errors.Errorf("Gopkg.lock is out of sync; run dep ensure before pruning.")
 	}
 
-	pruneLogger := ctx.Err
-	if !ctx.Verbose {
-		pruneLogger = log.New(ioutil.Discard, "", 0)
-	}
-	return pruneProject(p, sm, pruneLogger)
+	return pruneProject(p, sm, ctx.Logger())
 }
 
-// pruneProject removes unused packages from a project.
-func pruneProject(p *dep.Project, sm gps.SourceManager, logger *log.Logger) error {
+// pruneProject removes unused packages from a project, logging what it does
+// at the debug level.
+func pruneProject(p *dep.Project, sm gps.SourceManager, logger logging.Logger) error {
 	td, err := ioutil.TempDir(os.TempDir(), "dep")
 	if err != nil {
 		return errors.Wrap(err, "error while creating temp dir for writing manifest/lock/vendor")
 	}
-	defer os.RemoveAll(td)
+	defer fs.RemoveAll(td)
 
 	onWrite := func(progress gps.WriteProgress) {
-		logger.Println(progress)
+		logger.Debugf("%s", progress)
 	}
 	if err := gps.WriteDepTree(td, p.Lock, sm, gps.CascadingPruneOptions{DefaultOptions: gps.PruneNestedVendorDirs}, onWrite); err != nil {
 		return err
@@ -124,12 +105,12 @@ func pruneProject(p *dep.Project, sm gps.SourceManager, logger *log.Logger) erro
 	}
 
 	if len(toDelete) > 0 {
-		logger.Println("Calculated the following directories to prune:")
+		logger.Debugf("Calculated the following directories to prune:")
 		for _, d := range toDelete {
-			logger.Printf("  %s\n", d)
+			logger.Debugf("  %s", d)
 		}
 	} else {
-		logger.Println("No directories found to prune")
+		logger.Debugf("No directories found to prune")
 	}
 
 	if err := deleteDirs(toDelete); err != nil {
@@ -137,16 +118,22 @@ func pruneProject(p *dep.Project, sm gps.SourceManager, logger *log.Logger) erro
 	}
 
 	vpath := filepath.Join(p.AbsRoot, "vendor")
-	vendorbak := vpath + ".orig"
+	var vendorbak string
 	var failerr error
 	if _, err := os.Stat(vpath); err == nil {
 		// Move out the old vendor dir. just do it into an adjacent dir, to
 		// try to mitigate the possibility of a pointless cross-filesystem
 		// move with a temp directory.
+		vendorbak = vpath + ".orig"
 		if _, err := os.Stat(vendorbak); err == nil {
 			// If the adjacent dir already exists, bite the bullet and move
-			// to a proper tempdir.
-			vendorbak = filepath.Join(td, "vendor.orig")
+			// to a proper tempdir. Not td, as that becomes the new vendor.
+			bakdir, err := ioutil.TempDir(os.TempDir(), "dep")
+			if err != nil {
+				return errors.Wrap(err, "error while creating temp dir for the old vendor")
+			}
+			defer os.Remove(bakdir)
+			vendorbak = filepath.Join(bakdir, "vendor.orig")
 		}
 		failerr = fs.RenameWithFallback(vpath, vendorbak)
 		if failerr != nil {
@@ -160,17 +147,21 @@ func pruneProject(p *dep.Project, sm gps.SourceManager, logger *log.Logger) erro
 		goto fail
 	}
 
-	os.RemoveAll(vendorbak)
+	fs.RemoveAll(vendorbak)
 
 	return nil
 
 fail:
-	fs.RenameWithFallback(vendorbak, vpath)
+	// The move out of the old vendor dir may have failed partway, leaving it
+	// whole only at vendorbak; restore it from there, over whatever is left.
+	if err := fs.RestoreWithFallback(vendorbak, vpath); err != nil {
+		return errors.Wrapf(failerr, "vendor could not be restored, and is left at %s (%v)", vendorbak, err)
+	}
 	return failerr
 }
 
-func calculatePrune(vendorDir string, keep []string, logger *log.Logger) ([]string, error) {
-	logger.Println("Calculating prune. Checking the following packages:")
+func calculatePrune(vendorDir string, keep []string, logger logging.Logger) ([]string, error) {
+	logger.Debugf("Calculating prune. Checking the following packages:")
 	sort.Strings(keep)
 	var toDelete []string
 	err := filepath.Walk(vendorDir, func(path string, info os.FileInfo, err error) error {
@@ -185,31 +176,34 @@ func calculatePrune(vendorDir string, keep []string, logger *log.Logger) ([]stri
 		}
 
 		name := strings.TrimPrefix(path, vendorDir+string(filepath.Separator))
-		logger.Printf("  %s", name)
+		logger.Debugf("  %s", name)
 		i := sort.Search(len(keep), func(i int) bool {
 			return name <= keep[i]
 		})
 		if i >= len(keep) || !strings.HasPrefix(keep[i], name) {
+			// Nothing beneath it is kept either, and it is deleted whole.
 			toDelete = append(toDelete, path)
+			return filepath.SkipDir
 		}
 		return nil
 	})
 	return toDelete, err
 }
 
+// deleteDirs removes each of the directories in toDelete, with all they hold.
+// Those beneath another are gone with it, so are skipped.
 func deleteDirs(toDelete []string) error {
-	// sort by length so we delete sub dirs first
-	sort.Sort(byLen(toDelete))
+	// In walk order, the directories beneath each follow it.
+	sort.Slice(toDelete, func(i, j int) bool { return fs.WalkOrderLess(toDelete[i], toDelete[j]) })
+	var last string
 	for _, path := range toDelete {
-		if err := os.RemoveAll(path); err != nil {
+		if last != "" && strings.HasPrefix(path, last+string(filepath.Separator)) {
+			continue
+		}
+		if err := fs.RemoveAll(path); err != nil {
 			return err
 		}
+		last = path
 	}
 	return nil
 }
-
-type byLen []string
-
-func (a byLen) Len() int           { return len(a) }
-func (a byLen) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byLen) Less(i, j int) bool { return len(a[i]) > len(a[j]) }
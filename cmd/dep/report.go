@@ -0,0 +1,244 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/licenses"
+	"github.com/pkg/errors"
+)
+
+const reportShortHelp = `Write an HTML report of the dependencies and their licenses`
+const reportLongHelp = `
+Write a report of the project's dependencies, as locked in Gopkg.lock, as a
+self-contained HTML page, for attaching to releases to comply with the
+dependencies' licenses. The report lists each dependency, with its version, its
+revision, its source, and the licenses detected in it, and gives the text of
+its license files and other legal files, such as NOTICE and AUTHORS files: the
+files that pruning keeps, at the root of its tree.
+
+The trees are read from vendor/, or from the source cache, fetching the
+sources that are not in it, for the dependencies that are not vendored.
+
+-html is required; it is the only format of the report.
+`
+
+type reportCommand struct {
+	html bool
+}
+
+func (cmd *reportCommand) Name() string      { return "report" }
+func (cmd *reportCommand) Args() string      { return "-html" }
+func (cmd *reportCommand) ShortHelp() string { return reportShortHelp }
+func (cmd *reportCommand) LongHelp() string  { return reportLongHelp }
+func (cmd *reportCommand) Hidden() bool      { return false }
+
+func (cmd *reportCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.html, "html", false, "write the report as an HTML page")
+}
+
+func (cmd *reportCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.New("dep report takes no arguments")
+	}
+	if !cmd.html {
+		return errors.New("dep report only writes HTML reports, and must be given -html")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in project root %s, run \"dep ensure\" to create one", dep.LockName, p.AbsRoot)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	r, err := newReport(p, sm)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeReportHTML(&buf, r); err != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// report is the report of a project's dependencies.
+type report struct {
+	Project     string
+	Licenses    []string
+	Generated   time.Time
+	ToolVersion string
+	Deps        []reportDep
+}
+
+// reportDep is a dependency, as reported.
+type reportDep struct {
+	Project  string
+	Version  string
+	Revision string
+	Source   string // The URL of its source, if it can be browsed.
+	Licenses []string
+	Files    []reportFile
+}
+
+// reportFile is a license, or other legal, file of a dependency.
+type reportFile struct {
+	Name string
+	Text string
+}
+
+// newReport returns the report of the dependencies of p, as locked.
+func newReport(p *dep.Project, sm gps.SourceManager) (report, error) {
+	rootLicenses, err := licenses.Detect(p.AbsRoot)
+	if err != nil {
+		return report{}, errors.Wrap(err, "could not detect the licenses of the project")
+	}
+	r := report{
+		Project:     string(p.ImportRoot),
+		Licenses:    rootLicenses,
+		Generated:   time.Now(),
+		ToolVersion: version,
+	}
+
+	td, err := ioutil.TempDir("", "dep-report")
+	if err != nil {
+		return r, errors.Wrap(err, "could not create temp dir to export projects to")
+	}
+	defer os.RemoveAll(td)
+
+	for _, lp := range p.Lock.Projects() {
+		id := lp.Ident()
+		rev, _, _ := gps.VersionComponentStrings(lp.Version())
+		d := reportDep{
+			Project:  string(id.ProjectRoot),
+			Revision: rev,
+			Source:   browsableSource(sourceLocation(sm, id)),
+		}
+		if lp.Version().Type() != gps.IsRevision {
+			d.Version = formatVersion(lp.Version())
+		}
+
+		dir, err := lockedProjectDir(p, sm, lp, td)
+		if err != nil {
+			return r, err
+		}
+		if d.Licenses, err = licenses.Detect(dir); err != nil {
+			return r, errors.Wrapf(err, "could not detect the licenses of %s", id.ProjectRoot)
+		}
+		if d.Files, err = legalFiles(dir); err != nil {
+			return r, errors.Wrapf(err, "could not read the legal files of %s", id.ProjectRoot)
+		}
+		r.Deps = append(r.Deps, d)
+	}
+	return r, nil
+}
+
+// legalFiles returns the license, and other legal, files at the root of the
+// tree in dir, sorted by name.
+func legalFiles(dir string) ([]reportFile, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []reportFile
+	for _, fi := range infos {
+		if !fi.Mode().IsRegular() || !gps.IsLegalFile(fi.Name()) {
+			continue
+		}
+		text, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, reportFile{Name: fi.Name(), Text: string(text)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// browsableSource returns the web page of the source at the URL src, or an
+// empty string if it has none.
+func browsableSource(src string) string {
+	if !strings.HasPrefix(src, "https://") && !strings.HasPrefix(src, "http://") {
+		return ""
+	}
+	return strings.TrimSuffix(src, ".git")
+}
+
+// writeReportHTML writes r to w as a self-contained HTML page.
+func writeReportHTML(w io.Writer, r report) error {
+	return reportTemplate.Execute(w, r)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"short": func(rev string) string {
+		if len(rev) > 7 {
+			return rev[:7]
+		}
+		return rev
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Dependencies of {{.Project}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; padding: 0 1em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: .4em .6em; text-align: left; vertical-align: top; }
+code, pre { font-family: monospace; }
+pre { background: #f6f6f6; padding: 1em; overflow-x: auto; white-space: pre-wrap; }
+section { margin-top: 2em; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>Dependencies of {{.Project}}</h1>
+<p class="meta">{{.Project}} is licensed under {{join .Licenses ", "}}. Generated by dep {{.ToolVersion}} on {{.Generated.UTC.Format "2006-01-02 15:04:05 MST"}} from Gopkg.lock.</p>
+<table>
+<thead><tr><th>Dependency</th><th>Version</th><th>Revision</th><th>Licenses</th></tr></thead>
+<tbody>
+{{- range $i, $d := .Deps}}
+<tr><td><a href="#dep-{{$i}}">{{$d.Project}}</a></td><td>{{$d.Version}}</td><td><code>{{short $d.Revision}}</code></td><td>{{join $d.Licenses ", "}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- range $i, $d := .Deps}}
+<section id="dep-{{$i}}">
+<h2>{{$d.Project}}</h2>
+<p>{{if $d.Version}}Version {{$d.Version}}, revision{{else}}Revision{{end}} <code>{{$d.Revision}}</code>.{{if $d.Source}} Source: <a href="{{$d.Source}}">{{$d.Source}}</a>.{{end}}</p>
+{{- range $d.Files}}
+<h3>{{.Name}}</h3>
+<pre>{{.Text}}</pre>
+{{- else}}
+<p>No license or legal files were found.</p>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
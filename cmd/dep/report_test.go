@@ -0,0 +1,84 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestLegalFiles(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempFile("proj/LICENSE", "MIT License")
+	h.TempFile("proj/NOTICE.txt", "Copyright the authors")
+	h.TempFile("proj/license.go", "package proj")
+	h.TempFile("proj/README.md", "proj")
+	h.TempFile("proj/sub/COPYING", "nested")
+
+	files, err := legalFiles(h.Path("proj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []reportFile{{Name: "LICENSE", Text: "MIT License"}, {Name: "NOTICE.txt", Text: "Copyright the authors"}}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("unexpected legal files:\n\t(GOT): %v\n\t(WNT): %v", files, want)
+	}
+}
+
+func TestWriteReportHTML(t *testing.T) {
+	r := report{
+		Project:     "example.com/app",
+		Licenses:    []string{"Apache-2.0"},
+		Generated:   time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC),
+		ToolVersion: "v0.5.0",
+		Deps: []reportDep{
+			{
+				Project:  "github.com/foo/bar",
+				Version:  "v1.2.0",
+				Revision: "8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65",
+				Source:   browsableSource("https://github.com/foo/bar.git"),
+				Licenses: []string{"MIT"},
+				Files:    []reportFile{{Name: "LICENSE", Text: "Copyright <c> Foo & Bar"}},
+			},
+			{
+				Project:  "example.org/baz",
+				Revision: "2f2a5bd28c0c4d8a8b4b1e7e6d9ad3b0f6c1e2a4",
+				Source:   browsableSource("ssh://git@example.org/baz"),
+				Licenses: []string{"NONE"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeReportHTML(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"example.com/app is licensed under Apache-2.0. Generated by dep v0.5.0 on 2018-07-01 12:00:00 UTC",
+		`<tr><td><a href="#dep-0">github.com/foo/bar</a></td><td>v1.2.0</td><td><code>8a6d0e6</code></td><td>MIT</td></tr>`,
+		`Version v1.2.0, revision <code>8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65</code>. Source: <a href="https://github.com/foo/bar">https://github.com/foo/bar</a>.`,
+		"<pre>Copyright &lt;c&gt; Foo &amp; Bar</pre>",
+		`<section id="dep-1">`,
+		"Revision <code>2f2a5bd28c0c4d8a8b4b1e7e6d9ad3b0f6c1e2a4</code>.</p>",
+		"No license or legal files were found.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the report does not contain %q:\n%s", want, got)
+		}
+	}
+	// The report is self-contained.
+	for _, external := range []string{"<link", "<script", "src="} {
+		if strings.Contains(got, external) {
+			t.Errorf("the report refers to external resources with %q", external)
+		}
+	}
+}
@@ -5,7 +5,6 @@
 package main
 
 import (
-	"context"
 	"io/ioutil"
 	"log"
 
@@ -13,7 +12,6 @@ import (
 	"github.com/golang/dep/gps"
 	fb "github.com/golang/dep/internal/feedback"
 	"github.com/golang/dep/internal/importers"
-	"golang.org/x/sync/errgroup"
 )
 
 // rootAnalyzer supplies manifest/lock data from both dep and external tool's
@@ -26,6 +24,10 @@ type rootAnalyzer struct {
 	ctx        *dep.Ctx
 	sm         gps.SourceManager
 	directDeps map[gps.ProjectRoot]bool
+
+	// The name of the tool whose configuration the root project's was
+	// imported from, if any.
+	importedFrom string
 }
 
 func newRootAnalyzer(skipTools bool, ctx *dep.Ctx, directDeps map[gps.ProjectRoot]bool, sm gps.SourceManager) *rootAnalyzer {
@@ -61,41 +63,18 @@ func (a *rootAnalyzer) InitializeRootManifestAndLock(dir string, pr gps.ProjectR
 
 func (a *rootAnalyzer) cacheDeps(pr gps.ProjectRoot) error {
 	logger := a.ctx.Err
-	g, _ := errgroup.WithContext(context.TODO())
-	concurrency := 4
-
-	syncDep := func(pr gps.ProjectRoot, sm gps.SourceManager) error {
-		if err := sm.SyncSourceFor(gps.ProjectIdentifier{ProjectRoot: pr}); err != nil {
-			logger.Printf("Unable to cache %s - %s", pr, err)
-			return err
-		}
-		return nil
-	}
-
-	deps := make(chan gps.ProjectRoot)
 
-	for i := 0; i < concurrency; i++ {
-		g.Go(func() error {
-			for d := range deps {
-				err := syncDep(gps.ProjectRoot(d), a.sm)
-				if err != nil {
-					return err
-				}
-			}
-			return nil
-		})
-	}
-
-	g.Go(func() error {
-		defer close(deps)
-		for pr := range a.directDeps {
+	ids := make([]gps.ProjectIdentifier, 0, len(a.directDeps))
+	for pr := range a.directDeps {
+		// The fetches are displayed as they run instead, if they can be.
+		if a.ctx.Progress == nil {
 			logger.Printf("Caching package %q", pr)
-			deps <- pr
 		}
-		return nil
-	})
+		ids = append(ids, gps.ProjectIdentifier{ProjectRoot: pr})
+	}
 
-	if err := g.Wait(); err != nil {
+	if err := fetchSources(a.ctx, a.sm, ids); err != nil {
+		logger.Printf("Unable to cache deps - %s", err)
 		return err
 	}
 	logger.Printf("Successfully cached all deps.")
@@ -120,6 +99,9 @@ func (a *rootAnalyzer) importManifestAndLock(dir string, pr gps.ProjectRoot, sup
 				break
 			}
 			a.removeTransitiveDependencies(m)
+			if !suppressLogs {
+				a.importedFrom = i.Name()
+			}
 			return m, l
 		}
 	}
@@ -0,0 +1,322 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/paths"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/licenses"
+	"github.com/golang/dep/internal/sbom"
+	"github.com/pkg/errors"
+)
+
+const sbomShortHelp = `Write a software bill of materials for the project`
+const sbomLongHelp = `
+Write a software bill of materials, listing every project in Gopkg.lock, to
+standard output.
+
+Each project is listed with the version and revision it is locked to, the URL
+of its source, its licenses, the SHA-256 digest of its vendored contents as
+recorded in Gopkg.lock, and the other projects whose packages its packages
+import. Licenses and imports are found in each project in vendor/, or, for
+projects that are not vendored, in its locked version in the source cache.
+
+The -format flag selects the format of the bill of materials:
+
+  spdx        An SPDX 2.3 document, in JSON
+  cyclonedx   A CycloneDX 1.4 BOM, in JSON
+  github      A snapshot for GitHub's dependency submission API
+
+A GitHub snapshot is of the commit named by $GITHUB_SHA and $GITHUB_REF, as set
+in GitHub Actions, or else of the commit checked out in the project's git
+repository. It is correlated with other snapshots by $GITHUB_WORKFLOW,
+$GITHUB_JOB and the path of Gopkg.lock in the repository, and identified by
+$GITHUB_RUN_ID.
+`
+
+// SBOM formats.
+const (
+	sbomFormatSPDX      = "spdx"
+	sbomFormatCycloneDX = "cyclonedx"
+	sbomFormatGitHub    = "github"
+)
+
+type sbomCommand struct {
+	format string
+}
+
+func (cmd *sbomCommand) Name() string      { return "sbom" }
+func (cmd *sbomCommand) Args() string      { return "[-format spdx|cyclonedx|github]" }
+func (cmd *sbomCommand) ShortHelp() string { return sbomShortHelp }
+func (cmd *sbomCommand) LongHelp() string  { return sbomLongHelp }
+func (cmd *sbomCommand) Hidden() bool      { return false }
+
+func (cmd *sbomCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.format, "format", sbomFormatSPDX, "format of the bill of materials: spdx, cyclonedx or github")
+}
+
+func (cmd *sbomCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep sbom takes no arguments")
+	}
+	var write func(io.Writer, sbom.Document) error
+	switch cmd.format {
+	case sbomFormatSPDX:
+		write = sbom.WriteSPDX
+	case sbomFormatCycloneDX:
+		write = sbom.WriteCycloneDX
+	case sbomFormatGitHub:
+		// The snapshot is of the repository the project is in, which is
+		// only known once it is loaded.
+	default:
+		return errors.Errorf("unsupported SBOM format %q, must be %q, %q or %q", cmd.format, sbomFormatSPDX, sbomFormatCycloneDX, sbomFormatGitHub)
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+	if cmd.format == sbomFormatGitHub {
+		job, err := githubJob(p.AbsRoot)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer, doc sbom.Document) error {
+			return sbom.WriteGitHubSnapshot(w, doc, job)
+		}
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	doc, err := newSBOM(p, sm)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, doc); err != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// newSBOM returns the bill of materials of p, as locked.
+func newSBOM(p *dep.Project, sm gps.SourceManager) (sbom.Document, error) {
+	canonical, err := dep.CanonicalLock(p.Lock)
+	if err != nil {
+		return sbom.Document{}, err
+	}
+	sum := sha256.Sum256(canonical)
+
+	rootLicenses, err := licenses.Detect(p.AbsRoot)
+	if err != nil {
+		return sbom.Document{}, errors.Wrap(err, "could not detect the licenses of the project")
+	}
+	ptree, err := p.ParseRootPackageTree()
+	if err != nil {
+		return sbom.Document{}, err
+	}
+	rm, _ := ptree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
+
+	doc := sbom.Document{
+		Root: sbom.Package{
+			Name:      string(p.ImportRoot),
+			Licenses:  rootLicenses,
+			DependsOn: lockedImports(p.Lock, rm.FlattenFn(paths.IsStandardImportPath)),
+		},
+		Created:     time.Now(),
+		Tool:        "dep",
+		ToolVersion: version,
+		ID:          hex.EncodeToString(sum[:]),
+	}
+
+	td, err := ioutil.TempDir("", "dep-sbom")
+	if err != nil {
+		return doc, errors.Wrap(err, "could not create temp dir to export projects to")
+	}
+	defer os.RemoveAll(td)
+
+	for _, lp := range p.Lock.P {
+		id := lp.Ident()
+		rev, _, ver := gps.VersionComponentStrings(lp.Version())
+		pkg := sbom.Package{
+			Name:             string(id.ProjectRoot),
+			Version:          ver,
+			Revision:         rev,
+			DownloadLocation: sourceLocation(sm, id),
+			SHA256:           p.Lock.Digests[id.ProjectRoot],
+		}
+
+		dir, err := lockedProjectDir(p, sm, lp, td)
+		if err != nil {
+			return doc, err
+		}
+		if pkg.Licenses, err = licenses.Detect(dir); err != nil {
+			return doc, errors.Wrapf(err, "could not detect the licenses of %s", id.ProjectRoot)
+		}
+		if pkg.DependsOn, err = projectImports(p.Lock, lp, dir); err != nil {
+			return doc, errors.Wrapf(err, "could not list the imports of %s", id.ProjectRoot)
+		}
+
+		doc.Packages = append(doc.Packages, pkg)
+	}
+	return doc, nil
+}
+
+// lockedProjectDir returns the directory holding the tree of the locked
+// project lp: its directory in p's vendor/, if it is there, or else the
+// directory in td it is exported to from the source cache.
+func lockedProjectDir(p *dep.Project, sm gps.SourceManager, lp gps.LockedProject, td string) (string, error) {
+	id := lp.Ident()
+	dir := filepath.Join(p.AbsRoot, "vendor", string(id.ProjectRoot))
+	if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+		return dir, nil
+	}
+	dir = filepath.Join(td, string(id.ProjectRoot))
+	if err := sm.ExportProject(context.TODO(), id, lp.Version(), dir); err != nil {
+		return "", errors.Wrapf(err, "could not export %s", id.ProjectRoot)
+	}
+	return dir, nil
+}
+
+// projectImports returns the roots of the projects in l that the packages of
+// lp that l lists import. lp's packages are read from dir.
+func projectImports(l *dep.Lock, lp gps.LockedProject, dir string) ([]string, error) {
+	pr := string(lp.Ident().ProjectRoot)
+	ptree, err := pkgtree.ListPackages(dir, pr)
+	if err != nil {
+		return nil, err
+	}
+	rm, _ := ptree.ToReachMap(true, false, false, nil)
+
+	var imports []string
+	for _, pkg := range lp.Packages() {
+		ip := pr
+		if pkg != "." {
+			ip = pr + "/" + pkg
+		}
+		for _, ext := range rm[ip].External {
+			if !paths.IsStandardImportPath(ext) {
+				imports = append(imports, ext)
+			}
+		}
+	}
+	return lockedImports(l, imports), nil
+}
+
+// lockedImports returns the sorted roots of the projects in l that provide
+// the packages imports.
+func lockedImports(l *dep.Lock, imports []string) []string {
+	roots := make(map[string]bool)
+	for _, ip := range imports {
+		var root string
+		for _, lp := range l.P {
+			pr := string(lp.Ident().ProjectRoot)
+			if (ip == pr || strings.HasPrefix(ip, pr+"/")) && len(pr) > len(root) {
+				root = pr
+			}
+		}
+		if root != "" {
+			roots[root] = true
+		}
+	}
+
+	list := make([]string, 0, len(roots))
+	for root := range roots {
+		list = append(list, root)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// sourceLocation returns the URL of the source of the project id, preferring
+// HTTPS, or an empty string if it cannot be deduced.
+func sourceLocation(sm gps.SourceManager, id gps.ProjectIdentifier) string {
+	src := id.Source
+	if src == "" {
+		src = string(id.ProjectRoot)
+	}
+	if strings.Contains(src, "://") {
+		return src
+	}
+
+	urls, err := sm.SourceURLsForPath(src)
+	if err != nil || len(urls) == 0 {
+		return ""
+	}
+	for _, u := range urls {
+		if u.Scheme == "https" {
+			return u.String()
+		}
+	}
+	return urls[0].String()
+}
+
+// githubJob returns the commit, and the job, that a dependency snapshot of the
+// project in root is taken of, and by: from the variables GitHub Actions sets,
+// or else from the git repository root is in.
+func githubJob(root string) (sbom.GitHubJob, error) {
+	git := func(args ...string) string {
+		c := exec.Command("git", args...)
+		c.Dir = root
+		out, err := c.Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	job := sbom.GitHubJob{
+		SHA:      os.Getenv("GITHUB_SHA"),
+		Ref:      os.Getenv("GITHUB_REF"),
+		ID:       os.Getenv("GITHUB_RUN_ID"),
+		Manifest: path.Join(git("rev-parse", "--show-prefix"), dep.LockName),
+	}
+	if job.SHA == "" {
+		job.SHA = git("rev-parse", "HEAD")
+	}
+	if job.Ref == "" {
+		job.Ref = git("symbolic-ref", "HEAD")
+	}
+	if job.SHA == "" || job.Ref == "" {
+		return job, errors.Errorf("could not determine the commit to snapshot: set $GITHUB_SHA and $GITHUB_REF, or check out a branch of %s with git", root)
+	}
+
+	correlator := []string{"dep"}
+	if wf := os.Getenv("GITHUB_WORKFLOW"); wf != "" {
+		correlator = []string{wf, os.Getenv("GITHUB_JOB")}
+	}
+	job.Correlator = strings.Join(append(correlator, job.Manifest), "_")
+	if job.ID == "" {
+		job.ID = job.SHA
+	}
+	return job, nil
+}
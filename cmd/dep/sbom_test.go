@@ -0,0 +1,83 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/sbom"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestLockedImports(t *testing.T) {
+	l := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0"), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar/v2"}, gps.NewVersion("v2.0.0"), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewVersion("v1.0.0"), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/qux"}, gps.NewVersion("v1.0.0"), []string{"."}),
+		},
+	}
+
+	got := lockedImports(l, []string{
+		"github.com/foo/baz/sub",
+		"github.com/foo/bar/v2/pkg",
+		"github.com/foo/bar",
+		"github.com/foo/barbell",
+		"github.com/foo/baz",
+	})
+	want := []string{"github.com/foo/bar", "github.com/foo/bar/v2", "github.com/foo/baz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected locked imports:\n\t(GOT) %v\n\t(WNT) %v", got, want)
+	}
+}
+
+func TestGitHubJob(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("project")
+
+	env := map[string]string{
+		"GITHUB_SHA":      "ce587453ced02b1526dfb4cb910479d431683101",
+		"GITHUB_REF":      "refs/heads/master",
+		"GITHUB_RUN_ID":   "4021",
+		"GITHUB_WORKFLOW": "build",
+		"GITHUB_JOB":      "deps",
+	}
+	for k, v := range env {
+		old, had := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if had {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	got, err := githubJob(h.Path("project"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sbom.GitHubJob{
+		SHA:        "ce587453ced02b1526dfb4cb910479d431683101",
+		Ref:        "refs/heads/master",
+		Correlator: "build_deps_Gopkg.lock",
+		ID:         "4021",
+		Manifest:   "Gopkg.lock",
+	}
+	if got != want {
+		t.Fatalf("unexpected job:\n\t(GOT) %+v\n\t(WNT) %+v", got, want)
+	}
+
+	os.Unsetenv("GITHUB_SHA")
+	os.Unsetenv("GITHUB_REF")
+	if _, err := githubJob(h.Path("project")); err == nil {
+		t.Fatal("expected an error outside a git repository, without $GITHUB_SHA")
+	}
+}
@@ -0,0 +1,468 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/paths"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const serveShortHelp = `Answer queries about the project over JSON-RPC`
+const serveLongHelp = `
+Serve queries about the project, for editors and other tools, over JSON-RPC
+2.0: one request or response per line, on standard input and output, or, with
+-socket, on each connection to a unix socket, until it is closed.
+
+The project is loaded afresh for every request, so answers follow changes to
+Gopkg.toml, Gopkg.lock and the project's code, while the source cache is held
+by dep serve between requests, and only once a request needs it.
+
+The methods are:
+
+  status      The locked projects, with their versions and constraints.
+  constraint  The project of the import path named by "import", and the
+              constraint or override in Gopkg.toml governing it.
+  stale       Whether Gopkg.lock is out of sync with Gopkg.toml and the
+              project's imports, so that dep ensure would change it.
+  why         The shortest chain of imports from a package of the project to
+              the package or project named by "import".
+
+For example:
+
+  {"jsonrpc": "2.0", "id": 1, "method": "why", "params": {"import": "golang.org/x/net/context"}}
+`
+
+// JSON-RPC error codes, as the specification defines them.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+type serveCommand struct {
+	socket string
+}
+
+func (cmd *serveCommand) Name() string      { return "serve" }
+func (cmd *serveCommand) Args() string      { return "[-socket path]" }
+func (cmd *serveCommand) ShortHelp() string { return serveShortHelp }
+func (cmd *serveCommand) LongHelp() string  { return serveLongHelp }
+func (cmd *serveCommand) Hidden() bool      { return false }
+
+func (cmd *serveCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.socket, "socket", "", "serve on this unix socket, rather than on standard input and output")
+}
+
+func (cmd *serveCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep serve takes no arguments")
+	}
+
+	if cmd.socket == "" {
+		// Standard output carries the responses, so everything dep would
+		// print goes to standard error.
+		qctx := *ctx
+		qctx.Out = ctx.Err
+		ctx = &qctx
+	}
+
+	// Fail now, rather than on every request, if there is no project.
+	if _, err := ctx.LoadProject(); err != nil {
+		return err
+	}
+
+	sm, err := ctx.LazySourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	methods := projectMethods(ctx, sm)
+	if cmd.socket == "" {
+		return serveRPC(os.Stdin, os.Stdout, methods)
+	}
+
+	os.Remove(cmd.socket)
+	l, err := net.Listen("unix", cmd.socket)
+	if err != nil {
+		return errors.Wrap(err, "unable to listen for requests")
+	}
+	defer os.Remove(cmd.socket)
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigch)
+	stopped := make(chan struct{})
+	go func() {
+		<-sigch
+		close(stopped)
+		l.Close()
+	}()
+
+	ctx.Out.Printf("Serving queries about the project on %s\n", cmd.socket)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-stopped:
+				return nil
+			default:
+				l.Close()
+				return errors.Wrap(err, "dep serve stopped listening")
+			}
+		}
+		go func() {
+			defer conn.Close()
+			serveRPC(conn, conn, methods)
+		}()
+	}
+}
+
+// rpcMethod answers a request with the params given to the method it is
+// registered as.
+type rpcMethod func(params json.RawMessage) (interface{}, error)
+
+// rpcRequest is a JSON-RPC request, or a notification if it has no ID.
+type rpcRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params"`
+}
+
+// rpcResponse is a JSON-RPC response, holding either a result or an error.
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcParamsError is returned by methods given params they cannot use.
+type rpcParamsError struct{ error }
+
+// serveRPC answers the requests read from r, one per line, by calling the
+// methods they name, and writes the responses to w, until r is exhausted.
+// Notifications are answered by nothing.
+func serveRPC(r io.Reader, w io.Writer, methods map[string]rpcMethod) error {
+	enc := json.NewEncoder(w)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+
+		var req rpcRequest
+		resp := rpcResponse{JSONRPC: "2.0"}
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+		} else if resp.ID = req.ID; req.JSONRPC != "2.0" || req.Method == "" {
+			resp.Error = &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+		} else if m, has := methods[req.Method]; !has {
+			resp.Error = &rpcError{Code: rpcMethodNotFound, Message: "no such method: " + req.Method}
+		} else if result, err := m(req.Params); err != nil {
+			resp.Error = &rpcError{Code: rpcServerError, Message: err.Error()}
+			if _, ok := err.(rpcParamsError); ok {
+				resp.Error.Code = rpcInvalidParams
+			}
+		} else {
+			resp.Result = result
+		}
+
+		if req.ID == nil && resp.Error == nil {
+			continue
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// importParams are the params of the methods that take an import path.
+type importParams struct {
+	Import string `json:"import"`
+}
+
+func (p *importParams) decode(params json.RawMessage) error {
+	if err := json.Unmarshal(params, p); err != nil {
+		return rpcParamsError{err}
+	}
+	if p.Import == "" {
+		return rpcParamsError{errors.New("no import path given")}
+	}
+	return nil
+}
+
+// servedProject is a locked project, as the status method lists it.
+type servedProject struct {
+	Project    string   `json:"project"`
+	Source     string   `json:"source,omitempty"`
+	Constraint string   `json:"constraint,omitempty"`
+	Override   bool     `json:"override,omitempty"`
+	Version    string   `json:"version,omitempty"`
+	Branch     string   `json:"branch,omitempty"`
+	Revision   string   `json:"revision"`
+	Packages   []string `json:"packages"`
+}
+
+// servedConstraint is the answer of the constraint method.
+type servedConstraint struct {
+	Import     string `json:"import"`
+	Project    string `json:"project"`
+	Constraint string `json:"constraint,omitempty"`
+	Override   bool   `json:"override,omitempty"`
+	Locked     bool   `json:"locked"`
+	Version    string `json:"version,omitempty"`
+	Revision   string `json:"revision,omitempty"`
+}
+
+// servedStaleness is the answer of the stale method.
+type servedStaleness struct {
+	Stale        bool   `json:"stale"`
+	LockDigest   string `json:"lockDigest,omitempty"`
+	InputsDigest string `json:"inputsDigest"`
+}
+
+// servedChain is the answer of the why method. Chain is empty if the project
+// does not import the package or project.
+type servedChain struct {
+	Import string   `json:"import"`
+	Chain  []string `json:"chain"`
+}
+
+// projectMethods returns the methods dep serve answers requests with, about
+// the project found from ctx, with sources from sm.
+func projectMethods(ctx *dep.Ctx, sm gps.SourceManager) map[string]rpcMethod {
+	// The manifest's warnings were printed when dep serve started, and are
+	// not repeated for every request.
+	load := func() (*dep.Project, error) {
+		qctx := *ctx
+		qctx.Err = log.New(ioutil.Discard, "", 0)
+		return qctx.LoadProject()
+	}
+
+	return map[string]rpcMethod{
+		"status": func(json.RawMessage) (interface{}, error) {
+			p, err := load()
+			if err != nil {
+				return nil, err
+			}
+			projects := []servedProject{}
+			if p.Lock == nil {
+				return projects, nil
+			}
+			for _, lp := range p.Lock.Projects() {
+				pr := lp.Ident().ProjectRoot
+				sp := servedProject{
+					Project:  string(pr),
+					Source:   lp.Ident().Source,
+					Packages: lp.Packages(),
+				}
+				sp.Revision, sp.Branch, sp.Version = gps.VersionComponentStrings(lp.Version())
+				sp.Constraint, sp.Override = manifestConstraint(p.Manifest, pr)
+				projects = append(projects, sp)
+			}
+			sort.Slice(projects, func(i, j int) bool { return projects[i].Project < projects[j].Project })
+			return projects, nil
+		},
+		"constraint": func(params json.RawMessage) (interface{}, error) {
+			var ip importParams
+			if err := ip.decode(params); err != nil {
+				return nil, err
+			}
+			p, err := load()
+			if err != nil {
+				return nil, err
+			}
+
+			sc := servedConstraint{Import: ip.Import}
+			if lp, has := lockedProjectOf(p.Lock, ip.Import); has {
+				sc.Project, sc.Locked = string(lp.Ident().ProjectRoot), true
+				sc.Revision, _, _ = gps.VersionComponentStrings(lp.Version())
+				if _, ok := lp.Version().(gps.Revision); !ok {
+					sc.Version = lp.Version().String()
+				}
+			} else {
+				pr, err := sm.DeduceProjectRoot(ip.Import)
+				if err != nil {
+					return nil, err
+				}
+				sc.Project = string(pr)
+			}
+			sc.Constraint, sc.Override = manifestConstraint(p.Manifest, gps.ProjectRoot(sc.Project))
+			return sc, nil
+		},
+		"stale": func(json.RawMessage) (interface{}, error) {
+			p, err := load()
+			if err != nil {
+				return nil, err
+			}
+			digest, _, err := p.InputsDigest(sm)
+			if err != nil {
+				return nil, err
+			}
+			st := servedStaleness{Stale: true, InputsDigest: hex.EncodeToString(digest)}
+			if p.Lock != nil {
+				st.LockDigest = hex.EncodeToString(p.Lock.SolveMeta.InputsDigest)
+				st.Stale = st.LockDigest != st.InputsDigest
+			}
+			return st, nil
+		},
+		"why": func(params json.RawMessage) (interface{}, error) {
+			var ip importParams
+			if err := ip.decode(params); err != nil {
+				return nil, err
+			}
+			p, err := load()
+			if err != nil {
+				return nil, err
+			}
+			ptree, err := p.ParseRootPackageTree()
+			if err != nil {
+				return nil, err
+			}
+			chain, err := importChain(ptree, p.Lock, ip.Import, func(lp gps.LockedProject) (pkgtree.PackageTree, error) {
+				return sm.ListPackages(lp.Ident(), lp.Version())
+			})
+			if err != nil {
+				return nil, err
+			}
+			return servedChain{Import: ip.Import, Chain: chain}, nil
+		},
+	}
+}
+
+// manifestConstraint returns the constraint m places on pr, from an override,
+// if it has one, or else from a constraint.
+func manifestConstraint(m *dep.Manifest, pr gps.ProjectRoot) (string, bool) {
+	if pp, has := m.Ovr[pr]; has && pp.Constraint != nil {
+		return pp.Constraint.String(), true
+	}
+	if pp, has := m.Constraints[pr]; has && pp.Constraint != nil {
+		return pp.Constraint.String(), false
+	}
+	return "", false
+}
+
+// lockedProjectOf returns the project in l that the package at the import
+// path ip is in, if there is one.
+func lockedProjectOf(l *dep.Lock, ip string) (gps.LockedProject, bool) {
+	var found gps.LockedProject
+	var has bool
+	if l == nil {
+		return found, false
+	}
+	for _, lp := range l.Projects() {
+		pr := string(lp.Ident().ProjectRoot)
+		if ip != pr && !strings.HasPrefix(ip, pr+"/") {
+			continue
+		}
+		if !has || len(pr) > len(found.Ident().ProjectRoot) {
+			found, has = lp, true
+		}
+	}
+	return found, has
+}
+
+// importChain returns the shortest chain of imports from a package of ptree,
+// or its tests, to the package at the import path target, or to any package
+// of the project target, if it is a project root, or nil if there is none.
+// The packages of the projects in l are listed by list, when they are first
+// imported.
+func importChain(ptree pkgtree.PackageTree, l *dep.Lock, target string, list func(gps.LockedProject) (pkgtree.PackageTree, error)) ([]string, error) {
+	trees := make(map[gps.ProjectRoot]pkgtree.PackageTree)
+	imports := func(ip string) ([]string, error) {
+		if poe, has := ptree.Packages[ip]; has {
+			if poe.Err != nil {
+				return nil, nil
+			}
+			return append(poe.P.Imports, poe.P.TestImports...), nil
+		}
+
+		lp, has := lockedProjectOf(l, ip)
+		if !has {
+			return nil, nil
+		}
+		pr := lp.Ident().ProjectRoot
+		t, has := trees[pr]
+		if !has {
+			var err error
+			if t, err = list(lp); err != nil {
+				return nil, errors.Wrapf(err, "could not list the packages of %s", pr)
+			}
+			trees[pr] = t
+		}
+		if poe, has := t.Packages[ip]; has && poe.Err == nil {
+			return poe.P.Imports, nil
+		}
+		return nil, nil
+	}
+
+	roots := make([]string, 0, len(ptree.Packages))
+	for ip := range ptree.Packages {
+		roots = append(roots, ip)
+	}
+	sort.Strings(roots)
+
+	// A breadth-first search, from every package of the project at once,
+	// finds the shortest chain.
+	from := make(map[string]string, len(roots))
+	queue := roots
+	for _, ip := range roots {
+		from[ip] = ""
+	}
+	for len(queue) > 0 {
+		ip := queue[0]
+		queue = queue[1:]
+		if _, own := ptree.Packages[ip]; ip == target || !own && strings.HasPrefix(ip, target+"/") {
+			var chain []string
+			for ; ip != ""; ip = from[ip] {
+				chain = append([]string{ip}, chain...)
+			}
+			return chain, nil
+		}
+
+		imps, err := imports(ip)
+		if err != nil {
+			return nil, err
+		}
+		for _, imp := range imps {
+			if _, seen := from[imp]; seen || paths.IsStandardImportPath(imp) {
+				continue
+			}
+			from[imp] = ip
+			queue = append(queue, imp)
+		}
+	}
+	return nil, nil
+}
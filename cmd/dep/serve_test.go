@@ -0,0 +1,137 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+func TestServeRPC(t *testing.T) {
+	methods := map[string]rpcMethod{
+		"echo": func(params json.RawMessage) (interface{}, error) {
+			var ip importParams
+			if err := ip.decode(params); err != nil {
+				return nil, err
+			}
+			return ip, nil
+		},
+		"fail": func(json.RawMessage) (interface{}, error) {
+			return nil, errors.New("failed")
+		},
+	}
+
+	in := strings.Join([]string{
+		`{"jsonrpc": "2.0", "id": 1, "method": "echo", "params": {"import": "github.com/foo/bar"}}`,
+		``,
+		`{"jsonrpc": "2.0", "id": "two", "method": "echo", "params": {}}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "fail"}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "missing"}`,
+		`{"jsonrpc": "2.0", "method": "echo", "params": {"import": "github.com/foo/bar"}}`,
+		`{"jsonrpc": "2.0", "method": "fail"}`,
+		`{"id": 5, "method": "echo"}`,
+		`{"jsonrpc": "2.0", "id": 6,`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := serveRPC(strings.NewReader(in), &out, methods); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"result":{"import":"github.com/foo/bar"}}`,
+		`{"jsonrpc":"2.0","id":"two","error":{"code":-32602,"message":"no import path given"}}`,
+		`{"jsonrpc":"2.0","id":3,"error":{"code":-32000,"message":"failed"}}`,
+		`{"jsonrpc":"2.0","id":4,"error":{"code":-32601,"message":"no such method: missing"}}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32000,"message":"failed"}}`,
+		`{"jsonrpc":"2.0","id":5,"error":{"code":-32600,"message":"not a JSON-RPC 2.0 request"}}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`,
+	}, "\n") + "\n"
+	if got := out.String(); got != want {
+		t.Fatalf("unexpected responses:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+}
+
+func TestImportChain(t *testing.T) {
+	pkg := func(ip string, imports ...string) pkgtree.PackageOrErr {
+		return pkgtree.PackageOrErr{P: pkgtree.Package{ImportPath: ip, Imports: imports}}
+	}
+	ptree := pkgtree.PackageTree{
+		ImportRoot: "example.com/app",
+		Packages: map[string]pkgtree.PackageOrErr{
+			"example.com/app":     pkg("example.com/app", "fmt", "example.com/app/api"),
+			"example.com/app/api": pkg("example.com/app/api", "github.com/foo/bar/client"),
+			"example.com/app/tools": {P: pkgtree.Package{
+				ImportPath:  "example.com/app/tools",
+				TestImports: []string{"github.com/foo/testing"},
+			}},
+		},
+	}
+
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	l := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, rev, []string{"client", "internal"}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, rev, []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/testing"}, rev, []string{"."}),
+		},
+	}
+	trees := map[gps.ProjectRoot]pkgtree.PackageTree{
+		"github.com/foo/bar": {
+			ImportRoot: "github.com/foo/bar",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"github.com/foo/bar/client":   pkg("github.com/foo/bar/client", "net/http", "github.com/foo/bar/internal"),
+				"github.com/foo/bar/internal": pkg("github.com/foo/bar/internal", "github.com/foo/baz"),
+			},
+		},
+		"github.com/foo/baz": {
+			ImportRoot: "github.com/foo/baz",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"github.com/foo/baz": pkg("github.com/foo/baz"),
+			},
+		},
+	}
+	var listed []gps.ProjectRoot
+	list := func(lp gps.LockedProject) (pkgtree.PackageTree, error) {
+		listed = append(listed, lp.Ident().ProjectRoot)
+		return trees[lp.Ident().ProjectRoot], nil
+	}
+
+	cases := []struct {
+		target string
+		want   []string
+	}{
+		{"github.com/foo/baz", []string{"example.com/app/api", "github.com/foo/bar/client", "github.com/foo/bar/internal", "github.com/foo/baz"}},
+		{"github.com/foo/bar", []string{"example.com/app/api", "github.com/foo/bar/client"}},
+		{"github.com/foo/bar/internal", []string{"example.com/app/api", "github.com/foo/bar/client", "github.com/foo/bar/internal"}},
+		{"github.com/foo/testing", []string{"example.com/app/tools", "github.com/foo/testing"}},
+		{"example.com/app/api", []string{"example.com/app/api"}},
+		{"github.com/foo/missing", nil},
+	}
+	for _, c := range cases {
+		listed = nil
+		got, err := importChain(ptree, l, c.target, list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("unexpected chain to %s:\n\t(GOT): %v\n\t(WNT): %v", c.target, got, c.want)
+		}
+		for i, pr := range listed {
+			for _, other := range listed[:i] {
+				if pr == other {
+					t.Errorf("the packages of %s were listed more than once", pr)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const stampShortHelp = `Write a Go file stamping the build with the locked versions`
+const stampLongHelp = `
+Write a Go source file declaring the versions of the project's dependencies,
+as locked in Gopkg.lock, as constants, so that the binaries built from it can
+report exactly which revisions of their dependencies they were built from:
+
+  DepLockDigest   the SHA-256 digest of Gopkg.lock in canonical form, which
+                  changes with the version of any dependency
+  DepVersions     a line for each locked project, of its root, its version,
+                  or else its branch or revision, and its revision
+
+The file is written to depstamp.go in the current directory, or to the path
+given with -o. It is in the package of the Go files already in its directory,
+or else the package named for its directory, unless -package names another.
+It is only rewritten when the lock changes, and is conveniently kept up to
+date with a go:generate directive, or a post-ensure hook:
+
+  //go:generate dep stamp
+
+With -check, the file is not written; instead, dep stamp exits non-zero if it
+is out of date, which makes it suitable for use in CI.
+`
+
+type stampCommand struct {
+	output string
+	pkg    string
+	check  bool
+}
+
+func (cmd *stampCommand) Name() string      { return "stamp" }
+func (cmd *stampCommand) Args() string      { return "[-o file] [-package name] [-check]" }
+func (cmd *stampCommand) ShortHelp() string { return stampShortHelp }
+func (cmd *stampCommand) LongHelp() string  { return stampLongHelp }
+func (cmd *stampCommand) Hidden() bool      { return false }
+
+func (cmd *stampCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.output, "o", "depstamp.go", "the file to write")
+	fs.StringVar(&cmd.pkg, "package", "", "the package of the file, if not that of its directory")
+	fs.BoolVar(&cmd.check, "check", false, "report whether the file is up to date, without writing it")
+}
+
+func (cmd *stampCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 0 {
+		return errors.New("dep stamp takes no arguments")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in project root %s, run \"dep ensure\" to create one", dep.LockName, p.AbsRoot)
+	}
+
+	path := cmd.output
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.WorkingDir, path)
+	}
+	pkg := cmd.pkg
+	if pkg == "" {
+		if pkg, err = stampPackage(path); err != nil {
+			return err
+		}
+	}
+
+	src, err := stampFile(p.Lock, pkg)
+	if err != nil {
+		return err
+	}
+	if old, err := ioutil.ReadFile(path); err == nil && bytes.Equal(old, src) {
+		if ctx.Verbose {
+			ctx.Err.Printf("%s is up to date\n", path)
+		}
+		return nil
+	}
+	if cmd.check {
+		return errors.Errorf("%s is out of date with %s; run dep stamp to rewrite it", path, dep.LockName)
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, src, 0666), "could not write %s", path)
+}
+
+// stampFile returns the Go source of the stamp of the versions locked in l,
+// in the package pkg.
+func stampFile(l *dep.Lock, pkg string) ([]byte, error) {
+	canonical, err := dep.CanonicalLock(l)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+
+	var versions bytes.Buffer
+	for _, lp := range l.Projects() {
+		rev, branch, ver := gps.VersionComponentStrings(lp.Version())
+		v := ver
+		if v == "" {
+			v = branch
+		}
+		if v == "" {
+			v = rev
+		}
+		fmt.Fprintf(&versions, "%s %s %s\n", lp.Ident().ProjectRoot, v, rev)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by dep stamp from %s. DO NOT EDIT.\n\n", dep.LockName)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "// DepLockDigest is the SHA-256 digest of %s, in canonical form, that the\n// dependencies were vendored from.\n", dep.LockName)
+	fmt.Fprintf(&buf, "const DepLockDigest = %q\n\n", hex.EncodeToString(sum[:]))
+	buf.WriteString("// DepVersions lists the locked dependencies, a line for each, of its project\n// root, its version, or else its branch or revision, and its revision.\n")
+	// A raw string reads, and diffs, line by line; project roots and versions
+	// hold no backquotes.
+	fmt.Fprintf(&buf, "const DepVersions = `%s`\n", versions.String())
+
+	src, err := format.Source(buf.Bytes())
+	return src, errors.Wrap(err, "could not format the stamp")
+}
+
+// stampPackage returns the package of the Go file at path: that of the other
+// Go files in its directory, if there are any, or else its directory's name.
+func stampPackage(path string) (string, error) {
+	dir := filepath.Dir(path)
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return fi.Name() != filepath.Base(path) && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.PackageClauseOnly)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read the package in %s", dir)
+	}
+	switch len(pkgs) {
+	case 0:
+	case 1:
+		for name := range pkgs {
+			return name, nil
+		}
+	default:
+		return "", errors.Errorf("%s holds several packages; use -package to name the stamp's", dir)
+	}
+
+	// Without other files, the package is named after its directory, as is
+	// customary.
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, filepath.Base(dir))
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name, nil
+}
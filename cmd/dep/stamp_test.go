@@ -0,0 +1,95 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestStampFile(t *testing.T) {
+	rev := gps.Revision("8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65")
+	lock := func(v gps.Version) *dep.Lock {
+		return &dep.Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, v, []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewBranch("master").Pair(rev), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/qux"}, rev, []string{"."}),
+			},
+		}
+	}
+
+	src, err := stampFile(lock(gps.NewVersion("v1.2.0").Pair(rev)), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(src)
+	for _, want := range []string{
+		"// Code generated by dep stamp from Gopkg.lock. DO NOT EDIT.\n\npackage main\n",
+		"const DepLockDigest = \"",
+		"const DepVersions = `github.com/foo/bar v1.2.0 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65\n" +
+			"github.com/foo/baz master 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65\n" +
+			"github.com/foo/qux 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65 8a6d0e6c2c5ff9c8e5b3fbe4ba4f9b43d73dbd65\n`\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the stamp does not contain %q:\n%s", want, got)
+		}
+	}
+
+	again, err := stampFile(lock(gps.NewVersion("v1.2.0").Pair(rev)), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != got {
+		t.Error("the stamps of the same lock differ")
+	}
+	other, err := stampFile(lock(gps.NewVersion("v1.2.1").Pair(rev)), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := func(src string) string {
+		return src[strings.Index(src, "DepLockDigest = "):strings.Index(src, "// DepVersions")]
+	}
+	if digest(string(other)) == digest(got) {
+		t.Error("the lock digest did not change with a locked version")
+	}
+}
+
+func TestStampPackage(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempFile("cmd/app/main.go", "package main")
+	h.TempFile("cmd/app/main_test.go", "package main_test")
+	h.TempFile("cmd/app/depstamp.go", "package old")
+	h.TempFile("lib/lib.go", "package lib")
+	h.TempFile("lib/other.go", "package other")
+	h.TempDir("go-util.v2")
+
+	cases := []struct {
+		path, want string
+		err        bool
+	}{
+		{path: filepath.Join(h.Path("cmd/app"), "depstamp.go"), want: "main"},
+		{path: filepath.Join(h.Path("lib"), "depstamp.go"), err: true},
+		{path: filepath.Join(h.Path("go-util.v2"), "depstamp.go"), want: "go_util_v2"},
+	}
+	for _, c := range cases {
+		got, err := stampPackage(c.path)
+		if c.err {
+			if err == nil {
+				t.Errorf("expected an error for the package of %s, got %s", c.path, got)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("unexpected package of %s: %s %v, wanted %s", c.path, got, err, c.want)
+		}
+	}
+}
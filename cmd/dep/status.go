@@ -12,8 +12,6 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"os"
 	"sort"
 	"strings"
@@ -24,10 +22,12 @@ import (
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/paths"
+	"github.com/golang/dep/internal/events"
+	"github.com/golang/dep/internal/osv"
 	"github.com/pkg/errors"
 )
 
-const availableTemplateVariables = "ProjectRoot, Constraint, Version, Revision, Latest, and PackageCount."
+const availableTemplateVariables = "ProjectRoot, Constraint, Version, Revision, Latest, PackageCount, Sigstore, and Vulns."
 const availableDefaultTemplateVariables = `.Projects[]{
 	    .ProjectRoot,.Source,.Constraint,.PackageCount,.Packages[],
 	    .Locked{.Branch,.Revision,.Version},.Latest{.Revision,.Version}
@@ -48,10 +48,29 @@ With no arguments, print the status of each dependency of the project.
   LATEST      Latest VCS revision available
   PKGS USED   Number of packages from this project that are actually used
 
+With -sigstore, the Sigstore signatures of the locked versions of the
+dependencies named in [[sigstore]] stanzas of Gopkg.toml are verified, and
+reported in an additional column:
+
+  SIGSTORE    "verified", "invalid", or "-" for dependencies without a stanza
+
+With -vulns, the known vulnerabilities of the locked version of each
+dependency are looked up in the OSV database (https://osv.dev), and reported
+in an additional column:
+
+  VULNS       CVE and GHSA identifiers of the vulnerabilities, "-" if there
+              are none, or "unknown" if they could not be looked up
+
 You may use the -f flag to create a custom format for the output of the
 dep status command. The available fields you can utilize are as follows:
 ` + availableTemplateVariables + `
 
+With -lock-only, the status is reported from Gopkg.toml and Gopkg.lock alone:
+the root project's packages are not analyzed, so whether the lock is still in
+sync with its imports is not checked, and the constraints dependencies place on
+one another are shown only for those named in Gopkg.toml. On a large project
+this is much faster.
+
 Status returns exit code zero if all dependencies are in a "good state".
 `
 
@@ -128,8 +147,11 @@ func (cmd *statusCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.dot, "dot", false, "output the dependency graph in GraphViz format")
 	fs.BoolVar(&cmd.old, "old", false, "only show out-of-date dependencies")
 	fs.BoolVar(&cmd.missing, "missing", false, "only show missing dependencies")
+	fs.BoolVar(&cmd.lockOnly, "lock-only", false, "report from Gopkg.toml and Gopkg.lock without analyzing the project's packages")
 	fs.StringVar(&cmd.outFilePath, "out", "", "path to a file to which to write the output. Blank value will be ignored")
 	fs.BoolVar(&cmd.detail, "detail", false, "include more detail in the chosen format")
+	fs.BoolVar(&cmd.sigstore, "sigstore", false, "verify and report the Sigstore signatures of dependencies")
+	fs.BoolVar(&cmd.vulns, "vulns", false, "look up and report the known vulnerabilities of dependencies")
 }
 
 type statusCommand struct {
@@ -141,8 +163,11 @@ type statusCommand struct {
 	dot         bool
 	old         bool
 	missing     bool
+	lockOnly    bool
 	outFilePath string
 	detail      bool
+	sigstore    bool
+	vulns       bool
 }
 
 type outputter interface {
@@ -164,10 +189,21 @@ type oldOutputter interface {
 	OldFooter() error
 }
 
-type tableOutput struct{ w *tabwriter.Writer }
+type tableOutput struct {
+	w        *tabwriter.Writer
+	sigstore bool // Report the verification of Sigstore signatures.
+	vulns    bool // Report known vulnerabilities.
+}
 
 func (out *tableOutput) BasicHeader() error {
-	_, err := fmt.Fprintf(out.w, "PROJECT\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED\n")
+	header := "PROJECT\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED"
+	if out.sigstore {
+		header += "\tSIGSTORE"
+	}
+	if out.vulns {
+		header += "\tVULNS"
+	}
+	_, err := fmt.Fprintln(out.w, header)
 	return err
 }
 
@@ -177,7 +213,7 @@ func (out *tableOutput) BasicFooter() error {
 
 func (out *tableOutput) BasicLine(bs *BasicStatus) error {
 	_, err := fmt.Fprintf(out.w,
-		"%s\t%s\t%s\t%s\t%s\t%d\t\n",
+		"%s\t%s\t%s\t%s\t%s\t%d\t",
 		bs.ProjectRoot,
 		bs.getConsolidatedConstraint(),
 		formatVersion(bs.Version),
@@ -185,11 +221,27 @@ func (out *tableOutput) BasicLine(bs *BasicStatus) error {
 		bs.getConsolidatedLatest(shortRev),
 		bs.PackageCount,
 	)
+	if err == nil && out.sigstore {
+		_, err = fmt.Fprintf(out.w, "%s\t", bs.getSigstore())
+	}
+	if err == nil && out.vulns {
+		_, err = fmt.Fprintf(out.w, "%s\t", bs.getVulns())
+	}
+	if err == nil {
+		_, err = fmt.Fprintln(out.w)
+	}
 	return err
 }
 
 func (out *tableOutput) DetailHeader(metadata *dep.SolveMeta) error {
-	_, err := fmt.Fprintf(out.w, "PROJECT\tSOURCE\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED\n")
+	header := "PROJECT\tSOURCE\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED"
+	if out.sigstore {
+		header += "\tSIGSTORE"
+	}
+	if out.vulns {
+		header += "\tVULNS"
+	}
+	_, err := fmt.Fprintln(out.w, header)
 	return err
 }
 
@@ -199,7 +251,7 @@ func (out *tableOutput) DetailFooter(metadata *dep.SolveMeta) error {
 
 func (out *tableOutput) DetailLine(ds *DetailStatus) error {
 	_, err := fmt.Fprintf(out.w,
-		"%s\t%s\t%s\t%s\t%s\t%s\t[%s]\t\n",
+		"%s\t%s\t%s\t%s\t%s\t%s\t[%s]\t",
 		ds.ProjectRoot,
 		ds.Source,
 		ds.getConsolidatedConstraint(),
@@ -208,6 +260,15 @@ func (out *tableOutput) DetailLine(ds *DetailStatus) error {
 		ds.getConsolidatedLatest(shortRev),
 		strings.Join(ds.Packages, ", "),
 	)
+	if err == nil && out.sigstore {
+		_, err = fmt.Fprintf(out.w, "%s\t", ds.getSigstore())
+	}
+	if err == nil && out.vulns {
+		_, err = fmt.Fprintf(out.w, "%s\t", ds.getVulns())
+	}
+	if err == nil {
+		_, err = fmt.Fprintln(out.w)
+	}
 	return err
 }
 
@@ -339,7 +400,7 @@ func (out *dotOutput) BasicHeader() error {
 
 func (out *dotOutput) BasicFooter() error {
 	gvo := out.g.output()
-	_, err := fmt.Fprintf(out.w, gvo.String())
+	_, err := fmt.Fprint(out.w, gvo.String())
 	return err
 }
 
@@ -380,6 +441,8 @@ func (out *templateOutput) BasicLine(bs *BasicStatus) error {
 		Revision:     bs.Revision.String(),
 		Latest:       bs.getConsolidatedLatest(shortRev),
 		PackageCount: bs.PackageCount,
+		Sigstore:     bs.Sigstore,
+		Vulns:        bs.Vulns,
 	}
 	return out.tmpl.Execute(out.w, data)
 }
@@ -408,6 +471,8 @@ func (out *templateOutput) DetailLine(ds *DetailStatus) error {
 		PackageCount: ds.PackageCount,
 		Source:       ds.Source,
 		Packages:     ds.Packages,
+		Sigstore:     ds.Sigstore,
+		Vulns:        ds.Vulns,
 	}
 
 	out.detail = append(out.detail, data)
@@ -442,13 +507,21 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
-	sm, err := ctx.SourceManager()
+	// Sources are not needed to report only what is locked, where the inputs
+	// digest is memoized, so the source manager, and the lock on the cache,
+	// are only taken if they are.
+	sm, err := ctx.LazySourceManager()
 	if err != nil {
 		return err
 	}
 	sm.UseDefaultSignalHandling()
 	defer sm.Release()
 
+	// Keep the sources of the locked projects from being garbage collected.
+	if p.Lock != nil {
+		sm.RecordLock(p.AbsRoot, p.Lock)
+	}
+
 	if err := dep.ValidateProjectRoots(ctx, p.Manifest, sm); err != nil {
 		return err
 	}
@@ -488,7 +561,9 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		}
 	default:
 		out = &tableOutput{
-			w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0),
+			w:        tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0),
+			sigstore: cmd.sigstore,
+			vulns:    cmd.vulns,
 		}
 	}
 
@@ -565,6 +640,16 @@ func (cmd *statusCommand) validateFlags() error {
 		opModes = append(opModes, "-detail")
 	}
 
+	if cmd.sigstore && (cmd.old || cmd.dot) {
+		return errors.New("-sigstore cannot be passed with -old or -dot")
+	}
+	if cmd.vulns && (cmd.old || cmd.dot) {
+		return errors.New("-vulns cannot be passed with -old or -dot")
+	}
+	if cmd.lockOnly && (cmd.old || cmd.dot || cmd.missing) {
+		return errors.New("-lock-only cannot be passed with -old, -dot or -missing")
+	}
+
 	// Check if any other flags are passed with -dot.
 	if cmd.dot {
 		if cmd.template != "" {
@@ -662,11 +747,8 @@ func (cmd *statusCommand) runOld(ctx *dep.Ctx, out oldOutputter, p *dep.Project,
 		// Locks aren't a part of the input hash check, so we can omit it.
 	}
 
-	logger := ctx.Err
 	if ctx.Verbose {
-		params.TraceLogger = ctx.Err
-	} else {
-		logger = log.New(ioutil.Discard, "", 0)
+		params.TraceLogger = ctx.Logger()
 	}
 
 	// Check update for all the projects.
@@ -677,7 +759,7 @@ func (cmd *statusCommand) runOld(ctx *dep.Ctx, out oldOutputter, p *dep.Project,
 		return errors.Wrap(err, "fastpath solver prepare")
 	}
 
-	logger.Println("Solving dependency graph to determine which dependencies can be updated.")
+	ctx.Logger().Debugf("Solving dependency graph to determine which dependencies can be updated.")
 	solution, err := solver.Solve(context.TODO())
 	if err != nil {
 		return errors.Wrap(err, "runOld")
@@ -740,6 +822,8 @@ type rawStatus struct {
 	Revision     string
 	Latest       string
 	PackageCount int
+	Sigstore     string   `json:"Sigstore,omitempty"`
+	Vulns        []string `json:"Vulns,omitempty"`
 }
 
 // rawDetail is is additional information used for the status when the
@@ -763,6 +847,8 @@ type rawDetailProject struct {
 	Source       string `json:"Source,omitempty"`
 	Constraint   string
 	PackageCount int
+	Sigstore     string   `json:"Sigstore,omitempty"`
+	Vulns        []string `json:"Vulns,omitempty"`
 }
 
 type rawDetailMetadata struct {
@@ -797,8 +883,14 @@ type BasicStatus struct {
 	Revision     gps.Revision
 	Latest       gps.Version
 	PackageCount int
-	hasOverride  bool
-	hasError     bool
+	// Sigstore is the outcome of verifying the Sigstore signature of the
+	// locked version, "verified" or "invalid", if it was verified.
+	Sigstore string
+	// Vulns holds the identifiers of the known vulnerabilities of the locked
+	// version, if they were looked up; it is nil if the lookup failed.
+	Vulns       []string
+	hasOverride bool
+	hasError    bool
 }
 
 // DetailStatus contains all information reported about a single dependency
@@ -853,6 +945,23 @@ func (bs *BasicStatus) getConsolidatedLatest(revSize uint8) string {
 	return latest
 }
 
+func (bs *BasicStatus) getSigstore() string {
+	if bs.Sigstore == "" {
+		return "-"
+	}
+	return bs.Sigstore
+}
+
+func (bs *BasicStatus) getVulns() string {
+	switch {
+	case bs.Vulns == nil:
+		return "unknown"
+	case len(bs.Vulns) == 0:
+		return "-"
+	}
+	return strings.Join(bs.Vulns, ", ")
+}
+
 func (bs *BasicStatus) marshalJSON() *rawStatus {
 	return &rawStatus{
 		ProjectRoot:  bs.ProjectRoot,
@@ -861,6 +970,8 @@ func (bs *BasicStatus) marshalJSON() *rawStatus {
 		Revision:     string(bs.Revision),
 		Latest:       bs.getConsolidatedLatest(longRev),
 		PackageCount: bs.PackageCount,
+		Sigstore:     bs.Sigstore,
+		Vulns:        bs.Vulns,
 	}
 }
 
@@ -875,6 +986,8 @@ func (ds *DetailStatus) marshalJSON() *rawDetailProject {
 		Source:       ds.Source,
 		Packages:     ds.Packages,
 		PackageCount: ds.PackageCount,
+		Sigstore:     ds.Sigstore,
+		Vulns:        ds.Vulns,
 	}
 }
 
@@ -885,41 +998,37 @@ type MissingStatus struct {
 }
 
 func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Project, sm gps.SourceManager) (hasMissingPkgs bool, errCount int, err error) {
-	// While the network churns on ListVersions() requests, statically analyze
-	// code from the current project.
-	ptree, err := p.ParseRootPackageTree()
-	if err != nil {
-		return false, 0, err
-	}
-
-	// Set up a solver in order to check the InputHash.
-	params := gps.SolveParameters{
-		ProjectAnalyzer: dep.Analyzer{},
-		RootDir:         p.AbsRoot,
-		RootPackageTree: ptree,
-		Manifest:        p.Manifest,
-		// Locks aren't a part of the input hash check, so we can omit it.
-	}
-
-	logger := ctx.Err
-	if ctx.Verbose {
-		params.TraceLogger = ctx.Err
+	logger := ctx.Logger()
+
+	// With -lock-only, the lock is taken to be in sync with the project, and
+	// its packages are never analyzed.
+	inSync := true
+	var directDeps map[gps.ProjectRoot]bool
+	if cmd.lockOnly {
+		directDeps, err = manifestDependencyNames(p, sm)
 	} else {
-		logger = log.New(ioutil.Discard, "", 0)
-	}
-
-	if err := ctx.ValidateParams(sm, params); err != nil {
-		return false, 0, err
-	}
-
-	s, err := gps.Prepare(params, sm)
-	if err != nil {
-		return false, 0, errors.Wrapf(err, "could not set up solver for input hashing")
+		// The digest of the inputs, and the direct dependencies, are
+		// memoized while the project is unchanged; otherwise its code is
+		// statically analyzed while the network churns on ListVersions()
+		// requests.
+		var digest []byte
+		digest, directDeps, err = p.InputsDigest(sm)
+		if err != nil {
+			return false, 0, err
+		}
+		inSync = bytes.Equal(digest, p.Lock.SolveMeta.InputsDigest)
 	}
 
 	// Errors while collecting constraints should not fail the whole status run.
 	// It should count the error and tell the user about incomplete results.
-	cm, ccerrs := collectConstraints(ctx, p, sm)
+	var ccerrs []error
+	var cm constraintsCollection
+	if err != nil {
+		cm = make(constraintsCollection)
+		ccerrs = []error{errors.Wrap(err, "failed to get direct dependencies")}
+	} else {
+		cm, ccerrs = collectConstraintsOf(ctx, p, sm, directDeps)
+	}
 	if len(ccerrs) > 0 {
 		errCount += len(ccerrs)
 	}
@@ -932,12 +1041,27 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 		return slp[i].Ident().Less(slp[j].Ident())
 	})
 
-	if bytes.Equal(s.HashInputs(), p.Lock.SolveMeta.InputsDigest) {
+	if inSync {
 		// If these are equal, we're guaranteed that the lock is a transitively
 		// complete picture of all deps. That eliminates the need for at least
 		// some checks.
 
-		logger.Println("Checking upstream projects:")
+		// Known vulnerabilities are looked up for all projects at once. If the
+		// lookup fails, they are reported as unknown.
+		var vulns map[gps.ProjectRoot][]string
+		var vulnsKnown bool
+		if cmd.vulns {
+			logger.Debugf("Looking up known vulnerabilities")
+			c := osv.Client{}
+			var verr error
+			if vulns, verr = c.Lookup(context.TODO(), slp); verr != nil {
+				ctx.Logger().Warnf("%s", verr)
+				events.Warn(verr.Error())
+			}
+			vulnsKnown = verr == nil
+		}
+
+		logger.Debugf("Checking upstream projects:")
 
 		// DetailStatus channel to collect all the DetailStatus.
 		dsCh := make(chan *DetailStatus, len(slp))
@@ -945,12 +1069,13 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 		// Error channels to collect different errors.
 		errListPkgCh := make(chan error, len(slp))
 		errListVerCh := make(chan error, len(slp))
+		errSigstoreCh := make(chan error, len(slp))
 
 		var wg sync.WaitGroup
 
 		for i, proj := range slp {
 			wg.Add(1)
-			logger.Printf("(%d/%d) %s\n", i+1, len(slp), proj.Ident().ProjectRoot)
+			logger.Debugf("(%d/%d) %s", i+1, len(slp), proj.Ident().ProjectRoot)
 
 			go func(proj gps.LockedProject) {
 				bs := BasicStatus{
@@ -1043,6 +1168,19 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 					}
 				}
 
+				if vulnsKnown {
+					bs.Vulns = append([]string{}, vulns[proj.Ident().ProjectRoot]...)
+				}
+
+				if cmd.sigstore {
+					if verified, err := p.VerifySigstore(sm, proj); err != nil {
+						bs.Sigstore = "invalid"
+						errSigstoreCh <- err
+					} else if verified {
+						bs.Sigstore = "verified"
+					}
+				}
+
 				ds := DetailStatus{
 					BasicStatus: bs,
 				}
@@ -1062,9 +1200,7 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 		close(dsCh)
 		close(errListPkgCh)
 		close(errListVerCh)
-
-		// Newline after printing the status progress output.
-		logger.Println()
+		close(errSigstoreCh)
 
 		// List Packages errors. This would happen only for dot output.
 		if len(errListPkgCh) > 0 {
@@ -1096,6 +1232,15 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 			}
 		}
 
+		// Sigstore verification errors. The projects are reported as invalid
+		// rather than failing the whole status run.
+		if ctx.Verbose && len(errSigstoreCh) > 0 {
+			for err := range errSigstoreCh {
+				ctx.Err.Println(err.Error())
+			}
+			ctx.Err.Println()
+		}
+
 		if cmd.detail {
 			// A map of ProjectRoot and *DetailStatus. This is used in maintain the
 			// order of DetailStatus in output by collecting all the DetailStatus and
@@ -1131,6 +1276,10 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 	//
 	// It's possible for digests to not match, but still have a correct
 	// lock.
+	ptree, err := p.ParseRootPackageTree()
+	if err != nil {
+		return false, 0, err
+	}
 	rm, _ := ptree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
 
 	external := rm.FlattenFn(paths.IsStandardImportPath)
@@ -1296,24 +1445,53 @@ type constraintsCollection map[string][]projectConstraint
 // constraints from the root project. It returns constraintsCollection and
 // a slice of errors encountered while collecting the constraints, if any.
 func collectConstraints(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) (constraintsCollection, []error) {
-	logger := ctx.Err
-	if !ctx.Verbose {
-		logger = log.New(ioutil.Discard, "", 0)
-	}
-
-	logger.Println("Collecting project constraints:")
-
-	var mutex sync.Mutex
-	constraintCollection := make(constraintsCollection)
-
 	// Collect the complete set of direct project dependencies, incorporating
 	// requireds and ignores appropriately.
 	_, directDeps, err := p.GetDirectDependencyNames(sm)
 	if err != nil {
 		// Return empty collection, not nil, if we fail here.
-		return constraintCollection, []error{errors.Wrap(err, "failed to get direct dependencies")}
+		return make(constraintsCollection), []error{errors.Wrap(err, "failed to get direct dependencies")}
+	}
+
+	return collectConstraintsOf(ctx, p, sm, directDeps)
+}
+
+// manifestDependencyNames returns the set of the projects that the manifest of
+// the root project names in constraints or overrides, or whose packages it
+// requires. It stands in for the direct dependencies of the project when its
+// packages are not to be analyzed.
+func manifestDependencyNames(p *dep.Project, sm gps.SourceManager) (map[gps.ProjectRoot]bool, error) {
+	deps := make(map[gps.ProjectRoot]bool)
+	if p.Manifest == nil {
+		return deps, nil
 	}
 
+	for pr := range p.Manifest.Constraints {
+		deps[pr] = true
+	}
+	for pr := range p.Manifest.Ovr {
+		deps[pr] = true
+	}
+	for ip := range p.Manifest.RequiredPackages() {
+		pr, err := sm.DeduceProjectRoot(ip)
+		if err != nil {
+			return nil, err
+		}
+		deps[pr] = true
+	}
+	return deps, nil
+}
+
+// collectConstraintsOf is collectConstraints with the direct dependencies of
+// the root project already known.
+func collectConstraintsOf(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, directDeps map[gps.ProjectRoot]bool) (constraintsCollection, []error) {
+	logger := ctx.Logger()
+
+	logger.Debugf("Collecting project constraints:")
+
+	var mutex sync.Mutex
+	constraintCollection := make(constraintsCollection)
+
 	// Create a root analyzer.
 	rootAnalyzer := newRootAnalyzer(true, ctx, directDeps, sm)
 
@@ -1327,7 +1505,7 @@ func collectConstraints(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) (con
 	// Iterate through the locked projects and collect constraints of all the projects.
 	for i, proj := range lp {
 		wg.Add(1)
-		logger.Printf("(%d/%d) %s\n", i+1, len(lp), proj.Ident().ProjectRoot)
+		logger.Debugf("(%d/%d) %s", i+1, len(lp), proj.Ident().ProjectRoot)
 
 		go func(proj gps.LockedProject) {
 			defer wg.Done()
@@ -1372,7 +1550,7 @@ func collectConstraints(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) (con
 	if len(errCh) > 0 {
 		for e := range errCh {
 			errs = append(errs, e)
-			logger.Println(e.Error())
+			logger.Debugf("%s", e)
 		}
 	}
 
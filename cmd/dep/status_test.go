@@ -199,6 +199,78 @@ func TestBasicLine(t *testing.T) {
 	}
 }
 
+func TestBasicLineSigstore(t *testing.T) {
+	statuses := []BasicStatus{
+		{ProjectRoot: "github.com/foo/bar", Sigstore: "verified"},
+		{ProjectRoot: "github.com/foo/baz"},
+	}
+
+	var buf bytes.Buffer
+	tableout := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0), sigstore: true}
+	tableout.BasicHeader()
+	for i := range statuses {
+		tableout.BasicLine(&statuses[i])
+	}
+	tableout.BasicFooter()
+
+	for _, want := range []string{
+		"PKGS USED  SIGSTORE\n",
+		"github.com/foo/bar                                         0          verified  \n",
+		"github.com/foo/baz                                         0          -         \n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Did not find expected Table status: \n\t(GOT) %q \n\t(WNT) %q", buf.String(), want)
+		}
+	}
+
+	buf.Reset()
+	jsonout := &jsonOutput{w: &buf}
+	jsonout.BasicHeader()
+	for i := range statuses {
+		jsonout.BasicLine(&statuses[i])
+	}
+	jsonout.BasicFooter()
+	if got := strings.Count(buf.String(), `"Sigstore":`); got != 1 {
+		t.Errorf("expected only the verified project to report Sigstore in JSON, got %s", buf.String())
+	}
+}
+
+func TestBasicLineVulns(t *testing.T) {
+	statuses := []BasicStatus{
+		{ProjectRoot: "github.com/foo/bar", Vulns: []string{"CVE-2021-1234", "GHSA-aaaa-bbbb-cccc"}},
+		{ProjectRoot: "github.com/foo/baz", Vulns: []string{}},
+		{ProjectRoot: "github.com/foo/qux"},
+	}
+
+	var buf bytes.Buffer
+	tableout := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0), vulns: true}
+	tableout.BasicHeader()
+	for i := range statuses {
+		tableout.BasicLine(&statuses[i])
+	}
+	tableout.BasicFooter()
+
+	for _, want := range []string{
+		"PKGS USED  VULNS\n",
+		"0          CVE-2021-1234, GHSA-aaaa-bbbb-cccc  \n",
+		"github.com/foo/baz                                         0          -",
+		"github.com/foo/qux                                         0          unknown",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Did not find expected Table status: \n\t(GOT) %q \n\t(WNT) %q", buf.String(), want)
+		}
+	}
+
+	buf.Reset()
+	jsonout := &jsonOutput{w: &buf}
+	jsonout.BasicHeader()
+	jsonout.BasicLine(&statuses[0])
+	jsonout.BasicFooter()
+	if want := `"Vulns":["CVE-2021-1234","GHSA-aaaa-bbbb-cccc"]`; !strings.Contains(buf.String(), want) {
+		t.Errorf("Did not find expected JSON status: \n\t(GOT) %v \n\t(WNT) %v", buf.String(), want)
+	}
+}
+
 func TestDetailLine(t *testing.T) {
 	project := dep.Project{}
 	aSemverConstraint, _ := gps.NewSemverConstraint("1.2.3")
@@ -840,6 +912,31 @@ func TestValidateFlags(t *testing.T) {
 			cmd:     statusCommand{old: true, template: "foo"},
 			wantErr: nil,
 		},
+		{
+			name:    "-sigstore with -json",
+			cmd:     statusCommand{sigstore: true, json: true},
+			wantErr: nil,
+		},
+		{
+			name:    "-vulns with -dot",
+			cmd:     statusCommand{vulns: true, dot: true},
+			wantErr: errors.New("-vulns cannot be passed with -old or -dot"),
+		},
+		{
+			name:    "-sigstore with -old",
+			cmd:     statusCommand{sigstore: true, old: true},
+			wantErr: errors.New("-sigstore cannot be passed with -old or -dot"),
+		},
+		{
+			name:    "-lock-only with -json",
+			cmd:     statusCommand{lockOnly: true, json: true},
+			wantErr: nil,
+		},
+		{
+			name:    "-lock-only with -missing",
+			cmd:     statusCommand{lockOnly: true, missing: true},
+			wantErr: errors.New("-lock-only cannot be passed with -old, -dot or -missing"),
+		},
 	}
 
 	for _, tc := range testCases {
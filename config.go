@@ -0,0 +1,906 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// ConfigName is the name of the file, in the .dep directory of the user's home
+// directory, from which the user's configuration is read by default.
+const ConfigName = "config.toml"
+
+// defaultTokenUsername is the username sent along with a token, when none is
+// configured. Hosts that authenticate with tokens generally ignore it.
+const defaultTokenUsername = "oauth2"
+
+// Config is the user's configuration of dep. Unlike the manifest, it belongs
+// to the machine dep runs on rather than to a project, so it holds settings,
+// such as how to authenticate to private hosts, that differ between the users
+// of a project.
+type Config struct {
+	// Auth maps hosts to the way dep authenticates to them over HTTPS.
+	Auth map[string]HostAuth
+
+	// Proxy maps host patterns to the URL of the proxy through which to
+	// reach matching hosts over HTTP(S), or to "direct". Patterns are host
+	// names, optionally with a port; domains, starting with a dot, which
+	// match all of their subdomains; and "*", which matches every host.
+	Proxy map[string]string
+
+	// ModuleProxy maps host patterns, as in Proxy, to the URL of the module
+	// proxy from which to fetch the projects on matching hosts, or to
+	// "direct", to fetch them from their repositories. Module proxies are
+	// servers speaking the protocol of the go command's GOPROXY.
+	ModuleProxy map[string]string
+
+	// Mirror maps import path patterns to the URLs of the repositories from
+	// which to fetch the projects matching them, as described by
+	// gps.SourceManagerConfig.Mirrors.
+	Mirror map[string]string
+
+	// Host maps hosts, or domains starting with a dot, to the way the roots
+	// and sources of the import paths on them are deduced, as described by
+	// gps.HostMatcher.
+	Host map[string]HostDeduction
+
+	// MetadataTTL, if set, is how long go-get metadata is cached before it
+	// is fetched again, in place of DefaultMetadataTTL. Zero disables the
+	// cache.
+	MetadataTTL *time.Duration
+
+	// Metadata maps import path prefixes to the go-get metadata used for the
+	// import paths under them, in place of that served by their hosts.
+	Metadata map[string]MetadataOverride
+
+	// Retry, if set, is how network operations are retried, and how long
+	// they may take, in place of DefaultRetryPolicy.
+	Retry *gps.RetryPolicy
+
+	// CacheGC, if set, is how the source cache is garbage collected, in
+	// place of DefaultCacheGCPolicy.
+	CacheGC *gps.CacheGCPolicy
+
+	// ChecksumDB, if set, is the checksum database against which the
+	// contents of projects fetched from module proxies are checked.
+	ChecksumDB *gps.ChecksumDB
+}
+
+// DefaultChecksumDB is the checksum database used when the configuration has a
+// checksum-db table that does not name one: the go command's default,
+// sum.golang.org.
+var DefaultChecksumDB = gps.ChecksumDB{
+	URL: "https://sum.golang.org",
+	Key: "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8",
+}
+
+// DefaultRetryPolicy is how network operations are retried, unless the
+// configuration says otherwise: up to three attempts, a second and then two
+// seconds apart, each allowed two minutes, except for clones and fetches. VCS
+// commands that write no output for ten minutes are taken to have hung.
+var DefaultRetryPolicy = gps.RetryPolicy{
+	Attempts:     3,
+	Backoff:      time.Second,
+	MaxBackoff:   30 * time.Second,
+	Timeout:      2 * time.Minute,
+	StallTimeout: 10 * time.Minute,
+}
+
+// DefaultCacheGCPolicy is how the source cache is garbage collected, unless
+// the configuration says otherwise: once a day, the sources unused for ninety
+// days are removed, except those of the projects in the ten most recently used
+// locks. The cache may grow without limit.
+var DefaultCacheGCPolicy = gps.CacheGCPolicy{
+	MaxAge:    90 * 24 * time.Hour,
+	KeepLocks: 10,
+	Interval:  24 * time.Hour,
+}
+
+// DefaultMetadataTTL is how long go-get metadata is cached, unless the
+// configuration says otherwise.
+const DefaultMetadataTTL = 24 * time.Hour
+
+// MetadataOverride is the go-get metadata of the import paths under a prefix:
+// the type of their repository, and its URL.
+type MetadataOverride struct {
+	VCS  string `toml:"vcs"`
+	Repo string `toml:"repo"`
+}
+
+// HostDeduction is how the roots and sources of the import paths on a host are
+// deduced: Root is a regular expression matching their roots, and Sources are
+// the URLs of their git repositories, in which ${name} is replaced by the
+// submatch of Root with that name.
+type HostDeduction struct {
+	Root    string   `toml:"root"`
+	Sources []string `toml:"sources"`
+}
+
+// directProxy is the proxy that reaches hosts without going through a proxy.
+const directProxy = "direct"
+
+// HostAuth describes how to authenticate to a host. Exactly one of TokenEnv,
+// PasswordEnv and Netrc must be set. Secrets are never stored in the
+// configuration itself.
+type HostAuth struct {
+	// Username is the username to authenticate as. It is required with
+	// PasswordEnv, and optional with TokenEnv.
+	Username string `toml:"username"`
+	// TokenEnv names the environment variable holding an access token.
+	TokenEnv string `toml:"token-env"`
+	// PasswordEnv names the environment variable holding the password of
+	// Username.
+	PasswordEnv string `toml:"password-env"`
+	// Netrc, when set, takes the credentials from the host's entry in the
+	// user's netrc file.
+	Netrc bool `toml:"netrc"`
+
+	// Tokens are sent with basic authentication, as the password of
+	// Username, unless one of Bearer and Header is set.
+	//
+	// Bearer, when set, sends the token as a bearer token, in the
+	// Authorization header.
+	Bearer bool `toml:"bearer"`
+	// Header names the header in which to send the token as it is, such as
+	// the X-JFrog-Art-Api header of Artifactory.
+	Header string `toml:"header"`
+}
+
+type rawConfig struct {
+	Auth        map[string]HostAuth `toml:"auth"`
+	Proxy       map[string]string   `toml:"proxy"`
+	ModuleProxy map[string]string   `toml:"module-proxy"`
+	Mirror      map[string]string   `toml:"mirror"`
+	MetadataTTL string              `toml:"metadata-ttl"`
+
+	Metadata map[string]MetadataOverride `toml:"metadata"`
+	Host     map[string]HostDeduction    `toml:"host"`
+}
+
+// DefaultConfigPath returns the path from which the user's configuration is
+// read when no other is given: ~/.dep/config.toml.
+func DefaultConfigPath() string {
+	home := homeDir()
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".dep", ConfigName)
+}
+
+// LoadConfig reads the configuration in the file at path. If path is empty,
+// the configuration is read from DefaultConfigPath, and an empty configuration
+// is returned if that file does not exist.
+func LoadConfig(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultConfigPath()
+	}
+	if path == "" {
+		return &Config{}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return &Config{}, nil
+		}
+		return nil, errors.Wrapf(err, "could not open %s", path)
+	}
+	defer f.Close()
+
+	c, err := ReadConfig(f)
+	return c, errors.Wrapf(err, "error while parsing %s", path)
+}
+
+// ReadConfig reads a configuration from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	tree, err := toml.LoadReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the configuration as TOML")
+	}
+
+	for _, key := range tree.Keys() {
+		switch key {
+		case "auth", "proxy", "module-proxy", "mirror", "host", "metadata-ttl", "metadata", "retry", "cache-gc", "checksum-db":
+		default:
+			return nil, errors.Errorf("unknown field %q", key)
+		}
+	}
+	if auth, ok := tree.Get("auth").(*toml.Tree); ok {
+		for _, host := range auth.Keys() {
+			ht, ok := auth.GetPath([]string{host}).(*toml.Tree)
+			if !ok {
+				return nil, errors.Errorf("auth for %s must be a TOML table", host)
+			}
+			for _, key := range ht.Keys() {
+				switch key {
+				case "username", "token-env", "password-env", "netrc", "bearer", "header":
+				default:
+					return nil, errors.Errorf("unknown field %q in auth for %s", key, host)
+				}
+			}
+		}
+	} else if tree.Has("auth") {
+		return nil, errors.New("auth must be a TOML table")
+	}
+	for _, key := range []string{"proxy", "module-proxy", "mirror"} {
+		if proxy, ok := tree.Get(key).(*toml.Tree); ok {
+			for _, pattern := range proxy.Keys() {
+				if _, ok := proxy.GetPath([]string{pattern}).(string); !ok {
+					return nil, errors.Errorf("%s for %s must be a string", key, pattern)
+				}
+			}
+		} else if tree.Has(key) {
+			return nil, errors.Errorf("%s must be a TOML table", key)
+		}
+	}
+
+	if md, ok := tree.Get("metadata").(*toml.Tree); ok {
+		for _, prefix := range md.Keys() {
+			pt, ok := md.GetPath([]string{prefix}).(*toml.Tree)
+			if !ok {
+				return nil, errors.Errorf("metadata for %s must be a TOML table", prefix)
+			}
+			for _, key := range pt.Keys() {
+				switch key {
+				case "vcs", "repo":
+				default:
+					return nil, errors.Errorf("unknown field %q in metadata for %s", key, prefix)
+				}
+			}
+		}
+	} else if tree.Has("metadata") {
+		return nil, errors.New("metadata must be a TOML table")
+	}
+	if ht, ok := tree.Get("host").(*toml.Tree); ok {
+		for _, host := range ht.Keys() {
+			t, ok := ht.GetPath([]string{host}).(*toml.Tree)
+			if !ok {
+				return nil, errors.Errorf("host for %s must be a TOML table", host)
+			}
+			for _, key := range t.Keys() {
+				switch key {
+				case "root", "sources":
+				default:
+					return nil, errors.Errorf("unknown field %q in host for %s", key, host)
+				}
+			}
+		}
+	} else if tree.Has("host") {
+		return nil, errors.New("host must be a TOML table")
+	}
+	if tree.Has("metadata-ttl") {
+		if _, ok := tree.Get("metadata-ttl").(string); !ok {
+			return nil, errors.New("metadata-ttl must be a string")
+		}
+	}
+
+	raw := rawConfig{}
+	if err := tree.Unmarshal(&raw); err != nil {
+		return nil, errors.Wrap(err, "unable to read the configuration")
+	}
+
+	for host, ha := range raw.Auth {
+		n := 0
+		for _, set := range []bool{ha.TokenEnv != "", ha.PasswordEnv != "", ha.Netrc} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			return nil, errors.Errorf("auth for %s must set exactly one of token-env, password-env and netrc", host)
+		}
+		if ha.PasswordEnv != "" && ha.Username == "" {
+			return nil, errors.Errorf("auth for %s sets password-env without a username", host)
+		}
+		if (ha.Bearer || ha.Header != "") && ha.TokenEnv == "" {
+			return nil, errors.Errorf("auth for %s sets bearer or header without token-env", host)
+		}
+		if ha.Bearer && ha.Header != "" {
+			return nil, errors.Errorf("auth for %s sets both bearer and header", host)
+		}
+		if ha.Header != "" && !validHeaderName(ha.Header) {
+			return nil, errors.Errorf("auth for %s: %q is not a valid header name", host, ha.Header)
+		}
+	}
+
+	for pattern, proxy := range raw.Proxy {
+		if _, err := parseProxy(proxy); err != nil {
+			return nil, errors.Wrapf(err, "proxy for %s", pattern)
+		}
+	}
+
+	for pattern, proxy := range raw.ModuleProxy {
+		if _, err := parseModuleProxy(proxy); err != nil {
+			return nil, errors.Wrapf(err, "module-proxy for %s", pattern)
+		}
+	}
+
+	for prefix, mo := range raw.Metadata {
+		switch mo.VCS {
+		case "git", "bzr", "hg", "fossil":
+		default:
+			return nil, errors.Errorf("metadata for %s: vcs must be one of git, bzr, hg and fossil", prefix)
+		}
+		if u, err := url.Parse(mo.Repo); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, errors.Errorf("metadata for %s: repo must be the URL of a repository", prefix)
+		}
+	}
+
+	for host, hd := range raw.Host {
+		if _, err := regexp.Compile(hd.Root); err != nil || hd.Root == "" {
+			return nil, errors.Errorf("host for %s: root must be a regular expression matching the roots of its import paths", host)
+		}
+		if len(hd.Sources) == 0 {
+			return nil, errors.Errorf("host for %s: sources must list the URLs of its repositories", host)
+		}
+	}
+
+	c := &Config{
+		Auth:        raw.Auth,
+		Proxy:       raw.Proxy,
+		ModuleProxy: raw.ModuleProxy,
+		Mirror:      raw.Mirror,
+		Host:        raw.Host,
+		Metadata:    raw.Metadata,
+	}
+	if raw.MetadataTTL != "" {
+		ttl, err := time.ParseDuration(raw.MetadataTTL)
+		if err != nil || ttl < 0 {
+			return nil, errors.Errorf("metadata-ttl: %q is not a valid duration", raw.MetadataTTL)
+		}
+		c.MetadataTTL = &ttl
+	}
+	if rt, ok := tree.Get("retry").(*toml.Tree); ok {
+		p, err := parseRetry(rt)
+		if err != nil {
+			return nil, errors.Wrap(err, "retry")
+		}
+		c.Retry = &p
+	} else if tree.Has("retry") {
+		return nil, errors.New("retry must be a TOML table")
+	}
+	if gt, ok := tree.Get("cache-gc").(*toml.Tree); ok {
+		p, err := parseCacheGC(gt)
+		if err != nil {
+			return nil, errors.Wrap(err, "cache-gc")
+		}
+		c.CacheGC = &p
+	} else if tree.Has("cache-gc") {
+		return nil, errors.New("cache-gc must be a TOML table")
+	}
+	if dt, ok := tree.Get("checksum-db").(*toml.Tree); ok {
+		db, err := parseChecksumDB(dt)
+		if err != nil {
+			return nil, errors.Wrap(err, "checksum-db")
+		}
+		c.ChecksumDB = &db
+	} else if tree.Has("checksum-db") {
+		return nil, errors.New("checksum-db must be a TOML table")
+	}
+	return c, nil
+}
+
+// parseChecksumDB reads a checksum database from the checksum-db table. A
+// table that names no database names DefaultChecksumDB.
+func parseChecksumDB(t *toml.Tree) (gps.ChecksumDB, error) {
+	var db gps.ChecksumDB
+	for _, key := range t.Keys() {
+		str, ok := t.Get(key).(string)
+		switch key {
+		case "url", "key", "on-mismatch":
+			if !ok {
+				return db, errors.Errorf("%s must be a string", key)
+			}
+		default:
+			return db, errors.Errorf("unknown field %q", key)
+		}
+		switch key {
+		case "url":
+			if u, err := url.Parse(str); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return db, errors.Errorf("url: %q is not an HTTP(S) URL", str)
+			}
+			db.URL = str
+		case "key":
+			db.Key = str
+		case "on-mismatch":
+			switch str {
+			case "warn":
+			case "fail":
+				db.Fail = true
+			default:
+				return db, errors.Errorf("on-mismatch must be one of warn and fail, not %q", str)
+			}
+		}
+	}
+
+	switch {
+	case db.URL == "" && db.Key == "":
+		db.URL, db.Key = DefaultChecksumDB.URL, DefaultChecksumDB.Key
+	case db.URL == "" || db.Key == "":
+		return db, errors.New("url and key must be set together")
+	}
+	return db, nil
+}
+
+// parseRetry reads a retry policy from the retry table, taking the fields it
+// does not set from DefaultRetryPolicy.
+func parseRetry(t *toml.Tree) (gps.RetryPolicy, error) {
+	p := DefaultRetryPolicy
+	for _, key := range t.Keys() {
+		if key == "attempts" {
+			n, ok := t.Get(key).(int64)
+			if !ok || n < 1 {
+				return p, errors.New("attempts must be a positive integer")
+			}
+			p.Attempts = int(n)
+			continue
+		}
+
+		var d *time.Duration
+		switch key {
+		case "backoff":
+			d = &p.Backoff
+		case "max-backoff":
+			d = &p.MaxBackoff
+		case "timeout":
+			d = &p.Timeout
+		case "fetch-timeout":
+			d = &p.FetchTimeout
+		case "command-timeout":
+			d = &p.CommandTimeout
+		case "stall-timeout":
+			d = &p.StallTimeout
+		default:
+			return p, errors.Errorf("unknown field %q", key)
+		}
+		str, _ := t.Get(key).(string)
+		v, err := time.ParseDuration(str)
+		if err != nil || v < 0 {
+			return p, errors.Errorf("%s: %q is not a valid duration", key, t.Get(key))
+		}
+		*d = v
+	}
+	return p, nil
+}
+
+// parseCacheGC reads a garbage collection policy from the cache-gc table,
+// taking the fields it does not set from DefaultCacheGCPolicy.
+func parseCacheGC(t *toml.Tree) (gps.CacheGCPolicy, error) {
+	p := DefaultCacheGCPolicy
+	for _, key := range t.Keys() {
+		switch key {
+		case "keep-locks":
+			n, ok := t.Get(key).(int64)
+			if !ok || n < 0 {
+				return p, errors.New("keep-locks must be a non-negative integer")
+			}
+			p.KeepLocks = int(n)
+		case "max-size":
+			str, _ := t.Get(key).(string)
+			n, err := ParseSize(str)
+			if err != nil {
+				return p, errors.Errorf("max-size: %q is not a valid size", t.Get(key))
+			}
+			p.MaxSize = n
+		case "max-age", "interval":
+			str, _ := t.Get(key).(string)
+			v, err := time.ParseDuration(str)
+			if err != nil || v < 0 {
+				return p, errors.Errorf("%s: %q is not a valid duration", key, t.Get(key))
+			}
+			if key == "max-age" {
+				p.MaxAge = v
+			} else {
+				p.Interval = v
+			}
+		default:
+			return p, errors.Errorf("unknown field %q", key)
+		}
+	}
+	return p, nil
+}
+
+// sizeUnits are the units of the sizes read by ParseSize, by their suffixes.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size in bytes, such as "512MB" or "10GB". The units, KB,
+// MB, GB and TB, are powers of 1024; a number without a unit is in bytes. "0"
+// means no limit.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(s), u.suffix) {
+			s, unit = strings.TrimSpace(s[:len(s)-len(u.suffix)]), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("%q is not a valid size", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// CacheGCPolicy returns how the source cache is garbage collected, as
+// required by gps.SourceManagerConfig.
+func (c *Config) CacheGCPolicy() gps.CacheGCPolicy {
+	if c == nil || c.CacheGC == nil {
+		return DefaultCacheGCPolicy
+	}
+	return *c.CacheGC
+}
+
+// ChecksumDBConfig returns the checksum database against which the contents of
+// projects are checked, if there is one, as required by
+// gps.SourceManagerConfig.
+func (c *Config) ChecksumDBConfig() *gps.ChecksumDB {
+	if c == nil {
+		return nil
+	}
+	return c.ChecksumDB
+}
+
+// RetryPolicy returns how network operations are retried, as required by
+// gps.SourceManagerConfig.
+func (c *Config) RetryPolicy() gps.RetryPolicy {
+	if c == nil || c.Retry == nil {
+		return DefaultRetryPolicy
+	}
+	return *c.Retry
+}
+
+// MetadataCacheAge returns how long go-get metadata is cached, as required by
+// gps.SourceManagerConfig.
+func (c *Config) MetadataCacheAge() time.Duration {
+	if c == nil || c.MetadataTTL == nil {
+		return DefaultMetadataTTL
+	}
+	return *c.MetadataTTL
+}
+
+// MetadataOverrides returns the overrides of go-get metadata, as required by
+// gps.SourceManagerConfig.
+func (c *Config) MetadataOverrides() map[string]gps.GoImport {
+	if c == nil || len(c.Metadata) == 0 {
+		return nil
+	}
+	overrides := make(map[string]gps.GoImport, len(c.Metadata))
+	for prefix, mo := range c.Metadata {
+		overrides[prefix] = gps.GoImport{Root: prefix, VCS: mo.VCS, RepoRoot: mo.Repo}
+	}
+	return overrides
+}
+
+// parseProxy parses the URL of a proxy, returning nil for direct.
+func parseProxy(proxy string) (*url.URL, error) {
+	if proxy == directProxy {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, errors.Errorf("%q is not a valid URL", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.Errorf("%q must be %q, or a URL with the http, https or socks5 scheme", proxy, directProxy)
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("%q has no host", proxy)
+	}
+	return u, nil
+}
+
+// parseModuleProxy parses the URL of a module proxy, returning nil for direct.
+func parseModuleProxy(proxy string) (*url.URL, error) {
+	if proxy == directProxy {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, errors.Errorf("%q is not a valid URL", proxy)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, errors.Errorf("%q must be %q, or a URL with the http or https scheme", proxy, directProxy)
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("%q has no host", proxy)
+	}
+	return u, nil
+}
+
+// ProxyFunc returns a function selecting the proxy for a host, as required by
+// gps.SourceManagerConfig, or nil if c configures no proxies. Hosts are
+// matched against the patterns as described by matchHostFunc; those that
+// match none are left to the proxies set in the environment.
+func (c *Config) ProxyFunc() func(host string) (*url.URL, bool) {
+	if c == nil || len(c.Proxy) == 0 {
+		return nil
+	}
+	// Proxies were validated as the configuration was read.
+	return matchHostFunc(c.Proxy, parseProxy)
+}
+
+// ModuleProxyFunc returns a function selecting the module proxy for a host,
+// as required by gps.SourceManagerConfig, or nil if c configures no module
+// proxies. Hosts are matched against the patterns as described by
+// matchHostFunc; the projects on those that match none are fetched from their
+// repositories.
+func (c *Config) ModuleProxyFunc() func(host string) (*url.URL, bool) {
+	if c == nil || len(c.ModuleProxy) == 0 {
+		return nil
+	}
+	// Module proxies were validated as the configuration was read.
+	return matchHostFunc(c.ModuleProxy, parseModuleProxy)
+}
+
+// Mirrors returns the mirrors of import paths, as required by
+// gps.SourceManagerConfig.
+func (c *Config) Mirrors() map[string]string {
+	if c == nil {
+		return nil
+	}
+	return c.Mirror
+}
+
+// HostMatchers returns the ways the import paths on hosts are deduced, as
+// required by gps.SourceManagerConfig.
+func (c *Config) HostMatchers() ([]gps.HostMatcher, error) {
+	if c == nil || len(c.Host) == 0 {
+		return nil, nil
+	}
+	hosts := make([]string, 0, len(c.Host))
+	for host := range c.Host {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	hms := make([]gps.HostMatcher, 0, len(hosts))
+	for _, host := range hosts {
+		hd := c.Host[host]
+		re, err := regexp.Compile(hd.Root)
+		if err != nil {
+			return nil, errors.Wrapf(err, "host for %s", host)
+		}
+		hms = append(hms, gps.HostMatcher{Host: host, Root: re, Sources: hd.Sources})
+	}
+	return hms, nil
+}
+
+// matchHostFunc returns a function matching hosts against the patterns in
+// urls, returning the parsed URL of the pattern matched, if any.
+//
+// A host is matched first against the patterns naming it, with its port, and
+// then without. After those come the domain patterns, the longest first, and
+// finally "*".
+func matchHostFunc(urls map[string]string, parse func(string) (*url.URL, error)) func(host string) (*url.URL, bool) {
+	parsed := make(map[string]*url.URL, len(urls))
+	var domains []string
+	for pattern, u := range urls {
+		parsed[pattern], _ = parse(u)
+		if strings.HasPrefix(pattern, ".") {
+			domains = append(domains, pattern)
+		}
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		return len(domains[i]) > len(domains[j])
+	})
+
+	return func(host string) (*url.URL, bool) {
+		if u, has := parsed[host]; has {
+			return u, true
+		}
+		name := host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			name = h
+			if u, has := parsed[name]; has {
+				return u, true
+			}
+		}
+		for _, d := range domains {
+			if strings.HasSuffix(name, d) || name == d[1:] {
+				return parsed[d], true
+			}
+		}
+		u, has := parsed["*"]
+		return u, has
+	}
+}
+
+// Credentials resolves the credentials for each host in c.Auth, reading
+// secrets from the environment and the user's netrc file.
+func (c *Config) Credentials() (map[string]gps.Credentials, error) {
+	if c == nil || len(c.Auth) == 0 {
+		return nil, nil
+	}
+
+	hosts := make([]string, 0, len(c.Auth))
+	for host := range c.Auth {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var netrc map[string]netrcEntry
+	creds := make(map[string]gps.Credentials, len(c.Auth))
+	for _, host := range hosts {
+		ha := c.Auth[host]
+		switch {
+		case ha.TokenEnv != "":
+			token := os.Getenv(ha.TokenEnv)
+			if token == "" {
+				return nil, errors.Errorf("auth for %s takes a token from $%s, which is not set", host, ha.TokenEnv)
+			}
+			switch {
+			case ha.Bearer:
+				creds[host] = gps.Credentials{Header: "Authorization", Password: "Bearer " + token}
+			case ha.Header != "":
+				creds[host] = gps.Credentials{Header: ha.Header, Password: token}
+			default:
+				user := ha.Username
+				if user == "" {
+					user = defaultTokenUsername
+				}
+				creds[host] = gps.Credentials{Username: user, Password: token}
+			}
+		case ha.PasswordEnv != "":
+			pass := os.Getenv(ha.PasswordEnv)
+			if pass == "" {
+				return nil, errors.Errorf("auth for %s takes a password from $%s, which is not set", host, ha.PasswordEnv)
+			}
+			creds[host] = gps.Credentials{Username: ha.Username, Password: pass}
+		case ha.Netrc:
+			if netrc == nil {
+				var err error
+				if netrc, err = loadNetrc(); err != nil {
+					return nil, err
+				}
+			}
+			e, has := netrc[host]
+			if !has {
+				e, has = netrc[""]
+			}
+			if !has {
+				return nil, errors.Errorf("auth for %s takes its credentials from netrc, which has no entry for it", host)
+			}
+			creds[host] = gps.Credentials{Username: e.login, Password: e.password}
+		}
+	}
+	return creds, nil
+}
+
+// validHeaderName reports whether name is a valid HTTP header name.
+func validHeaderName(name string) bool {
+	for _, r := range name {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// netrcEntry holds the credentials of a machine in a netrc file.
+type netrcEntry struct {
+	login, password string
+}
+
+// netrcPath returns the path of the user's netrc file: $NETRC, or .netrc in
+// the user's home directory (_netrc on Windows).
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home := homeDir()
+	if home == "" {
+		return ""
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(home, name)
+}
+
+// loadNetrc reads the user's netrc file.
+func loadNetrc() (map[string]netrcEntry, error) {
+	path := netrcPath()
+	if path == "" {
+		return nil, errors.New("could not find a netrc file, as the home directory is unknown")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read netrc file %s", path)
+	}
+	return parseNetrc(data), nil
+}
+
+// parseNetrc parses the entries of a netrc file, keyed by machine name. The
+// default entry, if any, is keyed by the empty string. Macro definitions are
+// skipped.
+func parseNetrc(data []byte) map[string]netrcEntry {
+	entries := make(map[string]netrcEntry)
+
+	var machine string
+	var e netrcEntry
+	var inEntry bool
+	flush := func() {
+		if inEntry {
+			if _, has := entries[machine]; !has {
+				entries[machine] = e
+			}
+		}
+		machine, e, inEntry = "", netrcEntry{}, false
+	}
+
+	// Line ends are kept as tokens, to find the empty lines ending macros.
+	var tokens []string
+	for _, line := range strings.Split(string(data), "\n") {
+		tokens = append(tokens, strings.Fields(line)...)
+		tokens = append(tokens, "\n")
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		next := func() string {
+			for i+1 < len(tokens) {
+				i++
+				if tokens[i] != "\n" {
+					return tokens[i]
+				}
+			}
+			return ""
+		}
+
+		switch tokens[i] {
+		case "machine":
+			flush()
+			machine, inEntry = next(), true
+		case "default":
+			flush()
+			inEntry = true
+		case "login":
+			e.login = next()
+		case "password":
+			e.password = next()
+		case "account":
+			next()
+		case "macdef":
+			flush()
+			// A macro runs until the next empty line.
+			for i+1 < len(tokens) && !(tokens[i] == "\n" && tokens[i+1] == "\n") {
+				i++
+			}
+		}
+	}
+	flush()
+	return entries
+}
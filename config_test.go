@@ -0,0 +1,480 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestReadConfig(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`
+[auth."git.example.com"]
+  token-env = "GHE_TOKEN"
+
+[auth."gitlab.example.com:8443"]
+  netrc = true
+
+[auth."code.example.com"]
+  username = "ci"
+  password-env = "CODE_PASSWORD"
+
+[auth."athens.example.com"]
+  token-env = "ATHENS_TOKEN"
+  bearer = true
+
+[auth."artifactory.example.com"]
+  token-env = "ARTIFACTORY_API_KEY"
+  header = "X-JFrog-Art-Api"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]HostAuth{
+		"git.example.com":         {TokenEnv: "GHE_TOKEN"},
+		"gitlab.example.com:8443": {Netrc: true},
+		"code.example.com":        {Username: "ci", PasswordEnv: "CODE_PASSWORD"},
+		"athens.example.com":      {TokenEnv: "ATHENS_TOKEN", Bearer: true},
+		"artifactory.example.com": {TokenEnv: "ARTIFACTORY_API_KEY", Header: "X-JFrog-Art-Api"},
+	}
+	if !reflect.DeepEqual(c.Auth, want) {
+		t.Errorf("unexpected auth:\n\t(GOT): %+v\n\t(WNT): %+v", c.Auth, want)
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	cases := map[string]string{
+		`mirrors = 1`: `unknown field "mirrors"`,
+		`auth = 1`:    "auth must be a TOML table",
+		`[auth]
+  "git.example.com" = 1`: "auth for git.example.com must be a TOML table",
+		`[auth."git.example.com"]
+  token = "secret"`: `unknown field "token" in auth for git.example.com`,
+		`[auth."git.example.com"]
+  username = "ci"`: "must set exactly one of token-env, password-env and netrc",
+		`[auth."git.example.com"]
+  token-env = "A"
+  netrc = true`: "must set exactly one of token-env, password-env and netrc",
+		`[auth."git.example.com"]
+  password-env = "A"`: "sets password-env without a username",
+		`[auth."git.example.com"]
+  netrc = true
+  bearer = true`: "sets bearer or header without token-env",
+		`[auth."git.example.com"]
+  token-env = "A"
+  bearer = true
+  header = "X-Token"`: "sets both bearer and header",
+		`[auth."git.example.com"]
+  token-env = "A"
+  header = "X Token"`: `"X Token" is not a valid header name`,
+		`proxy = "http://proxy.example.com"`: "proxy must be a TOML table",
+		`[proxy]
+  "*" = 1`: "proxy for * must be a string",
+		`[proxy]
+  "*" = "ftp://proxy.example.com"`: "proxy for *: \"ftp://proxy.example.com\" must be \"direct\"",
+		`[proxy]
+  "*" = "http://"`: "has no host",
+		`module-proxy = "https://proxy.golang.org"`: "module-proxy must be a TOML table",
+		`[module-proxy]
+  "*" = "socks5://proxy.example.com"`: "module-proxy for *: \"socks5://proxy.example.com\" must be \"direct\"",
+		`metadata-ttl = 24`:   "metadata-ttl must be a string",
+		`metadata-ttl = "1d"`: `metadata-ttl: "1d" is not a valid duration`,
+		`metadata = 1`:        "metadata must be a TOML table",
+		`[metadata."go.example.com"]
+  repo = "https://git.example.com/lib"
+  branch = "master"`: `unknown field "branch" in metadata for go.example.com`,
+		`[metadata."go.example.com"]
+  vcs = "svn"
+  repo = "https://git.example.com/lib"`: "vcs must be one of git, bzr, hg and fossil",
+		`[metadata."go.example.com"]
+  vcs = "git"
+  repo = "git.example.com/lib"`: "repo must be the URL of a repository",
+		`host = 1`: "host must be a TOML table",
+		`[host."git.example.com"]
+  root = "^git\\.example\\.com/[^/]+/[^/]+/[^/]+"
+  vcs = "git"`: `unknown field "vcs" in host for git.example.com`,
+		`[host."git.example.com"]
+  root = "^git.example.com/(["
+  sources = ["https://${0}"]`: "root must be a regular expression",
+		`[host."git.example.com"]
+  root = "^git\\.example\\.com/[^/]+/[^/]+/[^/]+"`: "sources must list the URLs of its repositories",
+		`retry = 3`:                              "retry must be a TOML table",
+		`mirror = "https://github.com/golang/*"`: "mirror must be a TOML table",
+		`[retry]
+  attempts = 0`: "retry: attempts must be a positive integer",
+		`[retry]
+  backoff = "soon"`: `retry: backoff: "soon" is not a valid duration`,
+		`[retry]
+  jitter = true`: `retry: unknown field "jitter"`,
+		`cache-gc = "10GB"`: "cache-gc must be a TOML table",
+		`[cache-gc]
+  max-size = "lots"`: `cache-gc: max-size: "lots" is not a valid size`,
+		`[cache-gc]
+  keep-locks = -1`: "cache-gc: keep-locks must be a non-negative integer",
+		`checksum-db = "https://sum.golang.org"`: "checksum-db must be a TOML table",
+		`[checksum-db]
+  url = "https://sum.example.com"`: "checksum-db: url and key must be set together",
+		`[checksum-db]
+  on-mismatch = "ignore"`: "checksum-db: on-mismatch must be one of warn and fail",
+		`[checksum-db]
+  url = "sum.example.com"
+  key = "sum.example.com+01234567+AAAA"`: `checksum-db: url: "sum.example.com" is not an HTTP(S) URL`,
+	}
+
+	for in, want := range cases {
+		_, err := ReadConfig(strings.NewReader(in))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("unexpected error for %q:\n\t(GOT): %v\n\t(WNT): %s", in, err, want)
+		}
+	}
+}
+
+func TestLoadConfigMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := LoadConfig(filepath.Join(dir, ConfigName)); err == nil {
+		t.Error("expected an error loading an explicitly named configuration that does not exist")
+	}
+}
+
+func TestConfigCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	netrc := filepath.Join(dir, "netrc")
+	err = ioutil.WriteFile(netrc, []byte("machine gitlab.example.com login bot password s3cret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"NETRC":               netrc,
+		"DEP_TEST_TOKEN":      "t0ken",
+		"DEP_TEST_PASSWORD":   "passw0rd",
+		"DEP_TEST_UNSET_AUTH": "",
+	}
+	for k, v := range env {
+		old, had := os.LookupEnv(k)
+		os.Setenv(k, v)
+		defer func(k string) {
+			if had {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		}(k)
+	}
+
+	c := &Config{Auth: map[string]HostAuth{
+		"git.example.com":    {TokenEnv: "DEP_TEST_TOKEN"},
+		"ghe.example.com":    {Username: "me", TokenEnv: "DEP_TEST_TOKEN"},
+		"code.example.com":   {Username: "ci", PasswordEnv: "DEP_TEST_PASSWORD"},
+		"gitlab.example.com": {Netrc: true},
+		"athens.example.com": {TokenEnv: "DEP_TEST_TOKEN", Bearer: true},
+		"jfrog.example.com":  {TokenEnv: "DEP_TEST_TOKEN", Header: "X-JFrog-Art-Api"},
+	}}
+	creds, err := c.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]gps.Credentials{
+		"git.example.com":    {Username: defaultTokenUsername, Password: "t0ken"},
+		"ghe.example.com":    {Username: "me", Password: "t0ken"},
+		"code.example.com":   {Username: "ci", Password: "passw0rd"},
+		"gitlab.example.com": {Username: "bot", Password: "s3cret"},
+		"athens.example.com": {Header: "Authorization", Password: "Bearer t0ken"},
+		"jfrog.example.com":  {Header: "X-JFrog-Art-Api", Password: "t0ken"},
+	}
+	if !reflect.DeepEqual(creds, want) {
+		t.Errorf("unexpected credentials:\n\t(GOT): %+v\n\t(WNT): %+v", creds, want)
+	}
+
+	for _, ha := range []HostAuth{
+		{TokenEnv: "DEP_TEST_UNSET_AUTH"},
+		{Netrc: true},
+	} {
+		c := &Config{Auth: map[string]HostAuth{"other.example.com": ha}}
+		if _, err := c.Credentials(); err == nil {
+			t.Errorf("expected an error resolving credentials for %+v", ha)
+		}
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	data := `machine a.example.com
+  login alice
+  password apass
+
+macdef init
+  machine b.example.com login mallory password evil
+
+machine b.example.com login bob account x password bpass
+default login anon password none
+`
+	want := map[string]netrcEntry{
+		"a.example.com": {login: "alice", password: "apass"},
+		"b.example.com": {login: "bob", password: "bpass"},
+		"":              {login: "anon", password: "none"},
+	}
+	if got := parseNetrc([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected netrc entries:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
+
+func TestConfigProxyFunc(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`
+[proxy]
+  "*" = "http://proxy.example.com:3128"
+  ".corp.example.com" = "direct"
+  ".eu.corp.example.com" = "socks5://eu-proxy.example.com:1080"
+  "git.corp.example.com:8443" = "https://git-proxy.example.com"
+  "github.com" = "direct"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := c.ProxyFunc()
+
+	cases := map[string]string{
+		"golang.org":                "http://proxy.example.com:3128",
+		"github.com":                "direct",
+		"github.com:443":            "direct",
+		"corp.example.com":          "direct",
+		"git.corp.example.com":      "direct",
+		"git.corp.example.com:8443": "https://git-proxy.example.com",
+		"git.eu.corp.example.com":   "socks5://eu-proxy.example.com:1080",
+		"notcorp.example.com":       "http://proxy.example.com:3128",
+	}
+	for host, want := range cases {
+		u, has := proxy(host)
+		got := "direct"
+		if u != nil {
+			got = u.String()
+		}
+		if !has || got != want {
+			t.Errorf("unexpected proxy for %s:\n\t(GOT): %s (%v)\n\t(WNT): %s", host, got, has, want)
+		}
+	}
+
+	c.Proxy = map[string]string{"github.com": "direct"}
+	if _, has := c.ProxyFunc()("golang.org"); has {
+		t.Error("hosts matching no pattern should be left to the environment")
+	}
+	if (&Config{}).ProxyFunc() != nil {
+		t.Error("expected no proxy function without proxies")
+	}
+}
+
+func TestConfigModuleProxyFunc(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`
+[module-proxy]
+  "*" = "https://proxy.golang.org"
+  ".corp.example.com" = "direct"
+  "github.com" = "https://athens.corp.example.com/"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := c.ModuleProxyFunc()
+
+	cases := map[string]string{
+		"golang.org":           "https://proxy.golang.org",
+		"github.com":           "https://athens.corp.example.com/",
+		"git.corp.example.com": "direct",
+	}
+	for host, want := range cases {
+		u, has := proxy(host)
+		got := "direct"
+		if u != nil {
+			got = u.String()
+		}
+		if !has || got != want {
+			t.Errorf("unexpected module proxy for %s:\n\t(GOT): %s (%v)\n\t(WNT): %s", host, got, has, want)
+		}
+	}
+
+	if (&Config{}).ModuleProxyFunc() != nil {
+		t.Error("expected no module proxy function without module proxies")
+	}
+}
+
+func TestConfigMetadata(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`
+metadata-ttl = "1h30m"
+
+[metadata."go.example.com/lib"]
+  vcs = "git"
+  repo = "https://git.example.com/lib"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.MetadataCacheAge(), 90*time.Minute; got != want {
+		t.Errorf("unexpected metadata cache age: %s, want %s", got, want)
+	}
+	want := map[string]gps.GoImport{
+		"go.example.com/lib": {Root: "go.example.com/lib", VCS: "git", RepoRoot: "https://git.example.com/lib"},
+	}
+	if got := c.MetadataOverrides(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected metadata overrides:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	c, err = ReadConfig(strings.NewReader(`metadata-ttl = "0"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.MetadataCacheAge(); got != 0 {
+		t.Errorf("expected a metadata-ttl of 0 to disable the cache, got %s", got)
+	}
+
+	var none *Config
+	if got := none.MetadataCacheAge(); got != DefaultMetadataTTL {
+		t.Errorf("expected the default metadata cache age without a config, got %s", got)
+	}
+	if got := none.MetadataOverrides(); got != nil {
+		t.Errorf("expected no metadata overrides without a config, got %v", got)
+	}
+}
+
+func TestConfigHostMatchers(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`
+[host.".corp.example.com"]
+  root = '^(?P<host>[a-z]+\.corp\.example\.com)/(?P<group>[^/]+)/(?P<sub>[^/]+)/(?P<repo>[^/]+)'
+  sources = ["https://${host}/${group}/${sub}/${repo}.git", "ssh://git@${host}/${group}/${sub}/${repo}.git"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hms, err := c.HostMatchers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hms) != 1 {
+		t.Fatalf("expected a single host matcher, got %v", hms)
+	}
+	hm := hms[0]
+	if hm.Host != ".corp.example.com" || len(hm.Sources) != 2 {
+		t.Errorf("unexpected host matcher: %+v", hm)
+	}
+	if got := hm.Root.FindString("code.corp.example.com/team/services/api/cmd"); got != "code.corp.example.com/team/services/api" {
+		t.Errorf("unexpected root matched: %q", got)
+	}
+
+	var none *Config
+	if hms, err := none.HostMatchers(); hms != nil || err != nil {
+		t.Errorf("expected no host matchers without a config, got %v, %v", hms, err)
+	}
+}
+
+func TestConfigRetryPolicy(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`
+[retry]
+  attempts = 5
+  timeout = "30s"
+  fetch-timeout = "20m"
+  command-timeout = "1h"
+  stall-timeout = "0"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultRetryPolicy
+	want.Attempts = 5
+	want.Timeout = 30 * time.Second
+	want.FetchTimeout = 20 * time.Minute
+	want.CommandTimeout = time.Hour
+	want.StallTimeout = 0
+	if got := c.RetryPolicy(); got != want {
+		t.Errorf("unexpected retry policy:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	var none *Config
+	if got := none.RetryPolicy(); got != DefaultRetryPolicy {
+		t.Errorf("expected the default retry policy without a config, got %+v", got)
+	}
+}
+
+func TestConfigCacheGCPolicy(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`
+[cache-gc]
+  max-size = "10GB"
+  max-age = "720h"
+  keep-locks = 3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultCacheGCPolicy
+	want.MaxSize = 10 << 30
+	want.MaxAge = 720 * time.Hour
+	want.KeepLocks = 3
+	if got := c.CacheGCPolicy(); got != want {
+		t.Errorf("unexpected cache gc policy:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	var none *Config
+	if got := none.CacheGCPolicy(); got != DefaultCacheGCPolicy {
+		t.Errorf("expected the default cache gc policy without a config, got %+v", got)
+	}
+}
+
+func TestConfigChecksumDB(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`
+[checksum-db]
+  on-mismatch = "fail"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultChecksumDB
+	want.Fail = true
+	if got := c.ChecksumDBConfig(); got == nil || *got != want {
+		t.Errorf("unexpected checksum database:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	var none *Config
+	if got := none.ChecksumDBConfig(); got != nil {
+		t.Errorf("expected no checksum database without a config, got %+v", got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"0":      0,
+		"512":    512,
+		"2KB":    2 << 10,
+		"1.5 GB": 3 << 29,
+		"10gb":   10 << 30,
+	}
+	for in, want := range cases {
+		got, err := ParseSize(in)
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %v", in, err)
+		} else if got != want {
+			t.Errorf("unexpected size for %q: got %d, want %d", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "GB", "-1MB", "10PB"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("expected an error parsing %q", in)
+		}
+	}
+}
@@ -5,6 +5,9 @@
 package dep
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,7 +15,10 @@ import (
 	"time"
 
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/events"
 	"github.com/golang/dep/internal/fs"
+	"github.com/golang/dep/gps/logging"
 	"github.com/pkg/errors"
 )
 
@@ -35,15 +41,45 @@ import (
 //	}
 //
 type Ctx struct {
-	WorkingDir     string        // Where to execute.
-	GOPATH         string        // Selected Go path, containing WorkingDir.
-	GOPATHs        []string      // Other Go paths.
-	ExplicitRoot   string        // An explicitly-set path to use as the project root.
-	Out, Err       *log.Logger   // Required loggers.
-	Verbose        bool          // Enables more verbose logging.
-	DisableLocking bool          // When set, no lock file will be created to protect against simultaneous dep processes.
-	Cachedir       string        // Cache directory loaded from environment.
-	CacheAge       time.Duration // Maximum valid age of cached source data. <=0: Don't cache.
+	WorkingDir     string         // Where to execute.
+	GOPATH         string         // Selected Go path, containing WorkingDir.
+	GOPATHs        []string       // Other Go paths.
+	ExplicitRoot   string         // An explicitly-set path to use as the project root.
+	Out, Err       *log.Logger    // Required loggers.
+	Log            logging.Logger // Leveled logger for diagnostics. See Logger.
+	Verbose        bool           // Enables more verbose logging.
+	DisableLocking bool           // When set, no lock file will be created to protect against simultaneous dep processes.
+	DisableHooks   bool           // When set, hooks declared in the manifest are not run.
+	IgnoreLock     bool           // When set, LoadProject does not read the project's lock file.
+	Cachedir       string         // Cache directory loaded from environment.
+	SymlinkVendor  bool           // When set, the projects in vendor/ are symlinks to pruned trees in the cache, rather than copies.
+	CacheAge       time.Duration  // Maximum valid age of cached versions. <=0: Don't cache them.
+	ShallowClones  bool           // When set, git sources are cloned without their full history.
+	PartialClones  bool           // When set, git sources are cloned without the contents of their files.
+	VersionAPI     bool           // When set, the versions of git sources on GitHub and GitLab are listed through their APIs.
+	RestrictedVCS  bool           // When set, VCS commands are run in a restricted profile, for fetching untrusted sources.
+	Config         *Config        // The user's configuration, if any.
+	FetchJobs      int            // How many sources are fetched at once. <=0: The default.
+	Progress       io.Writer      // Where the progress of fetching sources is displayed, if anywhere; a terminal.
+	Daemon         string         // The unix socket of the source manager daemon to use, if any.
+	Command        string         // The command line dep was run with, as recorded in audit logs.
+}
+
+// Logger returns Log or, if it is not set, a logger writing plain text to Err,
+// of messages at the info level and above, and at the debug level too if
+// Verbose is set.
+func (c *Ctx) Logger() logging.Logger {
+	if c.Log != nil {
+		return c.Log
+	}
+	if c.Err == nil {
+		return logging.Discard
+	}
+	lvl := logging.Info
+	if c.Verbose {
+		lvl = logging.Debug
+	}
+	return logging.New(c.Err.Writer(), logging.Options{Level: lvl})
 }
 
 // SetPaths sets the WorkingDir and GOPATHs fields. If GOPATHs is empty, then
@@ -72,13 +108,7 @@ func (c *Ctx) SetPaths(wd string, GOPATHs ...string) error {
 // defaultGOPATH gets the default GOPATH that was added in 1.8
 // copied from go/build/build.go
 func defaultGOPATH() string {
-	env := "HOME"
-	if runtime.GOOS == "windows" {
-		env = "USERPROFILE"
-	} else if runtime.GOOS == "plan9" {
-		env = "home"
-	}
-	if home := os.Getenv(env); home != "" {
+	if home := homeDir(); home != "" {
 		def := filepath.Join(home, "go")
 		if def == runtime.GOROOT() {
 			// Don't set the default GOPATH to GOROOT,
@@ -90,25 +120,109 @@ func defaultGOPATH() string {
 	return ""
 }
 
+// homeDir returns the user's home directory, or the empty string if it is
+// unknown.
+func homeDir() string {
+	env := "HOME"
+	if runtime.GOOS == "windows" {
+		env = "USERPROFILE"
+	} else if runtime.GOOS == "plan9" {
+		env = "home"
+	}
+	return os.Getenv(env)
+}
+
 // SourceManager produces an instance of gps's built-in SourceManager
 // initialized to log to the receiver's logger.
 func (c *Ctx) SourceManager() (*gps.SourceMgr, error) {
-	cachedir := c.Cachedir
-	if cachedir == "" {
-		// When `DEPCACHEDIR` isn't set in the env, use the default - `$GOPATH/pkg/dep`.
-		cachedir = filepath.Join(c.GOPATH, "pkg", "dep")
+	config, err := c.sourceManagerConfig()
+	if err != nil {
+		return nil, err
+	}
+	return gps.NewSourceManager(config)
+}
+
+// LazySourceManager is like SourceManager, but the SourceManager it returns is
+// only created once it is first needed, for commands that may not need it.
+func (c *Ctx) LazySourceManager() (*gps.LazySourceMgr, error) {
+	config, err := c.sourceManagerConfig()
+	if err != nil {
+		return nil, err
+	}
+	return gps.NewLazySourceManager(config), nil
+}
+
+// sourceManagerConfig returns the configuration of the SourceManagers that
+// SourceManager and LazySourceManager produce.
+func (c *Ctx) sourceManagerConfig() (gps.SourceManagerConfig, error) {
+	cachedir := c.cachedir()
+	if c.Cachedir == "" {
 		// Create the default cachedir if it does not exist.
 		if err := os.MkdirAll(cachedir, 0777); err != nil {
-			return nil, errors.Wrap(err, "failed to create default cache directory")
+			return gps.SourceManagerConfig{}, errors.Wrap(err, "failed to create default cache directory")
 		}
 	}
 
-	return gps.NewSourceManager(gps.SourceManagerConfig{
+	creds, err := c.Config.Credentials()
+	if err != nil {
+		return gps.SourceManagerConfig{}, errors.Wrap(err, "failed to load credentials")
+	}
+	hosts, err := c.Config.HostMatchers()
+	if err != nil {
+		return gps.SourceManagerConfig{}, err
+	}
+
+	return gps.SourceManagerConfig{
 		CacheAge:       c.CacheAge,
 		Cachedir:       cachedir,
-		Logger:         c.Out,
+		Logger:         c.Logger(),
 		DisableLocking: c.DisableLocking,
-	})
+		ShallowClones:  c.ShallowClones,
+		PartialClones:  c.PartialClones,
+		VersionAPI:     c.VersionAPI,
+		Credentials:    creds,
+		Proxy:          c.Config.ProxyFunc(),
+		ModuleProxy:    c.Config.ModuleProxyFunc(),
+		Mirrors:        c.Config.Mirrors(),
+		HostMatchers:   hosts,
+
+		MetadataCacheAge:   c.Config.MetadataCacheAge(),
+		MetadataOverrides:  c.Config.MetadataOverrides(),
+		Retry:              c.Config.RetryPolicy(),
+		CacheGC:            c.Config.CacheGCPolicy(),
+		ChecksumDB:         c.Config.ChecksumDBConfig(),
+		Daemon:             c.Daemon,
+		RestrictedCommands: c.RestrictedVCS,
+	}, nil
+}
+
+// cachedir returns the cache directory, which is Cachedir if it is set.
+func (c *Ctx) cachedir() string {
+	if c.Cachedir == "" {
+		// When `DEPCACHEDIR` isn't set in the env, use the default - `$GOPATH/pkg/dep`.
+		return filepath.Join(c.GOPATH, "pkg", "dep")
+	}
+	return c.Cachedir
+}
+
+// TreeStore returns the store, in the cache directory, of the pruned project
+// trees that vendor/ is written from, or linked to when SymlinkVendor is set.
+func (c *Ctx) TreeStore() gps.TreeStore {
+	return gps.CacheTreeStore(c.cachedir())
+}
+
+// DigestMemo returns the memo, in the cache directory, of the digests of the
+// projects vendored in the project at root.
+func (c *Ctx) DigestMemo(root string) *pkgtree.DigestMemo {
+	sum := sha256.Sum256([]byte(root))
+	return pkgtree.NewDigestMemo(filepath.Join(c.cachedir(), "digests", hex.EncodeToString(sum[:16])+".json"))
+}
+
+// InputsMemo returns the memo, in the cache directory, of the inputs digest
+// and direct dependencies of the project at root.
+func (c *Ctx) InputsMemo(root string) *InputsMemo {
+	sum := sha256.Sum256([]byte(root))
+	return NewInputsMemo(filepath.Join(c.cachedir(), "inputs", hex.EncodeToString(sum[:16])+".json"))
 }
 
 // LoadProject starts from the current working directory and searches up the
@@ -163,14 +277,22 @@ func (c *Ctx) LoadProject() (*Project, error) {
 	defer mf.Close()
 
 	var warns []error
+	p.DigestMemo = c.DigestMemo(p.AbsRoot)
+	p.InputsMemo = c.InputsMemo(p.AbsRoot)
+
 	p.Manifest, warns, err = readManifest(mf)
 	for _, warn := range warns {
 		c.Err.Printf("dep: WARNING: %v\n", warn)
+		events.Warn(warn.Error())
 	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "error while parsing %s", mp)
 	}
 
+	if c.IgnoreLock {
+		return p, nil
+	}
+
 	lp := filepath.Join(p.AbsRoot, LockName)
 	lf, err := os.Open(lp)
 	if err != nil {
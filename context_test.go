@@ -294,14 +294,25 @@ func TestLoadProjectLockParseError(t *testing.T) {
 
 	ctx := &Ctx{
 		GOPATH:     tg.Path("."),
+		GOPATHs:    []string{tg.Path(".")},
 		WorkingDir: wd,
 		Out:        discardLogger(),
 		Err:        discardLogger(),
 	}
 
 	_, err = ctx.LoadProject()
-	if err == nil {
-		t.Fatal("should have returned 'Lock Syntax' error")
+	if err == nil || !strings.Contains(err.Error(), LockName) {
+		t.Fatalf("should have returned 'Lock Syntax' error, got %v", err)
+	}
+
+	// A lock that is not read cannot fail to parse.
+	ctx.IgnoreLock = true
+	p, err := ctx.LoadProject()
+	if err != nil {
+		t.Fatalf("unexpected error loading the project without its lock: %s", err)
+	}
+	if p.Lock != nil {
+		t.Fatal("expected the lock not to be read")
 	}
 }
 
@@ -0,0 +1,60 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// LockedDigest returns the hash of the contents of the project lp at the
+// version it is locked to, as gps.HashTree computes it, for comparison with
+// the digest fields of constraints. The contents are those of the project as
+// exported from its source, before any pruning.
+func LockedDigest(sm gps.SourceManager, lp gps.LockedProject) (string, error) {
+	td, err := ioutil.TempDir("", "dep-digest")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	to := filepath.Join(td, "src")
+	if err := sm.ExportProject(context.TODO(), lp.Ident(), lp.Version(), to); err != nil {
+		return "", errors.Wrapf(err, "failed to export %s", lp.Ident().ProjectRoot)
+	}
+	return gps.HashTree(to)
+}
+
+// VerifyLockedDigests checks that each project in l whose constraint in p's
+// manifest names a digest is locked to a revision whose contents have that
+// digest, so that versions re-tagged upstream are refused rather than silently
+// locked and vendored. It does nothing if no constraint names a digest.
+func (c *Ctx) VerifyLockedDigests(p *Project, sm gps.SourceManager, l *Lock) error {
+	if p.Manifest == nil || l == nil || len(p.Manifest.ConstraintDigests) == 0 {
+		return nil
+	}
+
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		want, has := p.Manifest.ConstraintDigests[pr]
+		if !has {
+			continue
+		}
+		c.Logger().Debugf("Verifying the digest of %s at %s", pr, lp.Version())
+		got, err := LockedDigest(sm, lp)
+		if err != nil {
+			return errors.Wrapf(err, "could not verify the digest of %s", pr)
+		}
+		if got != want {
+			return errors.Errorf("the contents of %s at %s have the digest %s, rather than %s as its constraint in %s requires; the version may have been changed upstream", pr, lp.Version(), got, want, ManifestName)
+		}
+	}
+	return nil
+}
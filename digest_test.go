@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+// exportSourceManager exports projects from fixed sets of files, keyed by
+// revision.
+type exportSourceManager struct {
+	gps.SourceManager
+	files map[gps.Revision]map[string]string
+}
+
+func (sm exportSourceManager) ExportProject(ctx context.Context, id gps.ProjectIdentifier, v gps.Version, to string) error {
+	rev, _, _ := gps.VersionComponentStrings(v)
+	for name, content := range sm.files[gps.Revision(rev)] {
+		fp := filepath.Join(to, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fp), 0777); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(fp, []byte(content), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestVerifyLockedDigests(t *testing.T) {
+	const rev1, rev2 = "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0", "a33a77ff3f841fd8ca1bc8cd0278a227dfc3d595"
+	sm := exportSourceManager{files: map[gps.Revision]map[string]string{
+		rev1: {"bar.go": "package bar\n", "sub/sub.go": "package sub\n"},
+		rev2: {"bar.go": "package bar // re-tagged\n", "sub/sub.go": "package sub\n"},
+	}}
+	mk := func(rev gps.Revision) *Lock {
+		return &Lock{P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewVersion("v1.0.0").Pair(rev2), []string{"."}),
+		}}
+	}
+
+	digest, err := LockedDigest(sm, mk(rev1).P[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(digest, "h1:") {
+		t.Fatalf("unexpected digest %q", digest)
+	}
+
+	ctx := &Ctx{}
+	p := &Project{Manifest: NewManifest()}
+	// Without digests in the manifest, nothing is exported or verified.
+	if err := ctx.VerifyLockedDigests(p, nil, mk(rev2)); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Manifest.ConstraintDigests["github.com/foo/bar"] = digest
+	if err := ctx.VerifyLockedDigests(p, sm, mk(rev1)); err != nil {
+		t.Fatalf("unexpected error for a matching digest: %s", err)
+	}
+	err = ctx.VerifyLockedDigests(p, sm, mk(rev2))
+	if err == nil {
+		t.Fatal("expected an error for contents that do not match the digest")
+	}
+	if !strings.Contains(err.Error(), "github.com/foo/bar at v1.0.0") || !strings.Contains(err.Error(), digest) {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
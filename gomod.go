@@ -0,0 +1,203 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// GoModName is the name of the file in which Go modules declare their
+// requirements.
+const GoModName = "go.mod"
+
+// goModFile holds the directives of a go.mod file that dep has a use for.
+type goModFile struct {
+	Module  string
+	Require []goModRequire
+	Replace []goModReplace
+	Exclude []goModRequire
+}
+
+// goModRequire is a module, at a version, named by a require or an exclude
+// directive.
+type goModRequire struct {
+	Path, Version string
+	Indirect      bool
+}
+
+// goModReplace is a replace directive, replacing the module Old, at
+// OldVersion or at every version if that is empty, with the module New, at
+// NewVersion, or with the directory New if that is empty.
+type goModReplace struct {
+	Old, OldVersion string
+	New, NewVersion string
+}
+
+// parseGoMod parses the go.mod file data. Directives that dep has no use for,
+// such as go and toolchain, are skipped.
+func parseGoMod(data []byte) (*goModFile, error) {
+	f := &goModFile{}
+	var block string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		comment := ""
+		if i := commentIndex(line); i >= 0 {
+			line, comment = line[:i], strings.TrimSpace(line[i+2:])
+		}
+		args, err := goModFields(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d", GoModName, n)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		verb := block
+		switch {
+		case block != "" && len(args) == 1 && args[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(args) == 2 && args[1] == "(":
+			block = args[0]
+			continue
+		case block == "":
+			verb, args = args[0], args[1:]
+		}
+
+		switch verb {
+		case "module":
+			if len(args) != 1 {
+				return nil, errors.Errorf("%s:%d: usage: module module/path", GoModName, n)
+			}
+			f.Module = args[0]
+		case "require", "exclude":
+			if len(args) != 2 {
+				return nil, errors.Errorf("%s:%d: usage: %s module/path v1.2.3", GoModName, n, verb)
+			}
+			r := goModRequire{Path: args[0], Version: args[1], Indirect: comment == "indirect" || strings.HasPrefix(comment, "indirect;")}
+			if verb == "require" {
+				f.Require = append(f.Require, r)
+			} else {
+				f.Exclude = append(f.Exclude, r)
+			}
+		case "replace":
+			arrow := 1
+			if len(args) > 1 && args[1] != "=>" {
+				arrow = 2
+			}
+			if len(args) < arrow+2 || len(args) > arrow+3 || args[arrow] != "=>" {
+				return nil, errors.Errorf("%s:%d: usage: replace module/path [v1.2.3] => other/module v1.4.5 or directory", GoModName, n)
+			}
+			r := goModReplace{Old: args[0], New: args[arrow+1]}
+			if arrow == 2 {
+				r.OldVersion = args[1]
+			}
+			if len(args) == arrow+3 {
+				r.NewVersion = args[arrow+2]
+			}
+			f.Replace = append(f.Replace, r)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// commentIndex returns the index of the "//" starting the comment on line,
+// or -1 if it has none.
+func commentIndex(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '/' && strings.HasPrefix(line[i:], "//"):
+			return i
+		}
+	}
+	return -1
+}
+
+// goModFields splits line into its fields, unquoting those that are quoted.
+func goModFields(line string) ([]string, error) {
+	var fields []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] != '"' && line[0] != '`' {
+			i := strings.IndexAny(line, " \t")
+			if i < 0 {
+				i = len(line)
+			}
+			fields = append(fields, line[:i])
+			line = line[i:]
+			continue
+		}
+
+		q, err := strconv.QuotedPrefix(line)
+		if err != nil {
+			return nil, errors.Errorf("malformed quoted string in %q", line)
+		}
+		s, err := strconv.Unquote(q)
+		if err != nil {
+			return nil, errors.Errorf("malformed quoted string %s", q)
+		}
+		fields = append(fields, s)
+		line = line[len(q):]
+	}
+	return fields, nil
+}
+
+// pseudoVersion matches the pseudo-versions by which the go command names
+// revisions that are not tagged, such as v0.0.0-20180101000000-0123456789ab.
+var pseudoVersion = regexp.MustCompile(`-(?:[0-9A-Za-z-]+\.)?(?:0\.)?[0-9]{14}-[0-9a-f]{12}(?:\+incompatible)?$`)
+
+// moduleMajorSuffix matches the major version suffixes of module paths, such
+// as the "/v2" of "github.com/foo/bar/v2". The ".v2" of gopkg.in paths is part
+// of the name of their repository, and is not matched.
+var moduleMajorSuffix = regexp.MustCompile(`/v[0-9]+$`)
+
+// manifestFromGoMod returns a manifest constraining the projects that the
+// module of the go.mod file f requires to the versions it requires, or later
+// ones with the same major version, as minimal version selection would allow.
+//
+// Modules required at pseudo-versions are not constrained, as those name
+// revisions that may not be on any branch dep knows of. Replace and exclude
+// directives are left out, as the go command also ignores them in the go.mod
+// files of all modules other than the one it builds.
+func manifestFromGoMod(f *goModFile) *Manifest {
+	m := NewManifest()
+	for _, r := range f.Require {
+		if pseudoVersion.MatchString(r.Version) {
+			continue
+		}
+		c, err := gps.NewSemverConstraintIC("^" + strings.TrimSuffix(r.Version, "+incompatible"))
+		if err != nil {
+			continue
+		}
+
+		// The project holding a module with a major version suffix is that
+		// of the path without it, where it is not in a directory of its own.
+		pr := gps.ProjectRoot(moduleMajorSuffix.ReplaceAllString(r.Path, ""))
+		if _, has := m.Constraints[pr]; has {
+			continue
+		}
+		m.Constraints[pr] = gps.ProjectProperties{Constraint: c}
+	}
+	return m
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+const testGoMod = `// The module's own comment.
+module "github.com/foo/lib"
+
+go 1.12
+
+require github.com/pkg/errors v0.8.0
+
+require (
+	github.com/foo/bar/v2 v2.1.0
+	github.com/foo/bar v1.0.0 // indirect
+	github.com/foo/old v3.0.1+incompatible
+	github.com/foo/untagged v0.0.0-20180101000000-0123456789ab // indirect
+	gopkg.in/yaml.v2 v2.2.1
+)
+
+exclude github.com/foo/old v3.0.0+incompatible
+
+replace (
+	github.com/pkg/errors => github.com/fork/errors v0.8.1
+	github.com/foo/old v3.0.1+incompatible => ../old
+)
+`
+
+func TestParseGoMod(t *testing.T) {
+	f, err := parseGoMod([]byte(testGoMod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &goModFile{
+		Module: "github.com/foo/lib",
+		Require: []goModRequire{
+			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
+			{Path: "github.com/foo/bar/v2", Version: "v2.1.0"},
+			{Path: "github.com/foo/bar", Version: "v1.0.0", Indirect: true},
+			{Path: "github.com/foo/old", Version: "v3.0.1+incompatible"},
+			{Path: "github.com/foo/untagged", Version: "v0.0.0-20180101000000-0123456789ab", Indirect: true},
+			{Path: "gopkg.in/yaml.v2", Version: "v2.2.1"},
+		},
+		Exclude: []goModRequire{
+			{Path: "github.com/foo/old", Version: "v3.0.0+incompatible"},
+		},
+		Replace: []goModReplace{
+			{Old: "github.com/pkg/errors", New: "github.com/fork/errors", NewVersion: "v0.8.1"},
+			{Old: "github.com/foo/old", OldVersion: "v3.0.1+incompatible", New: "../old"},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Fatalf("unexpected go.mod:\n\t(GOT): %+v\n\t(WNT): %+v", f, want)
+	}
+
+	for _, bad := range []string{
+		"require github.com/foo/bar\n",
+		"require (\n\tgithub.com/foo/bar v1.0.0 extra\n)\n",
+		"replace github.com/foo/bar v1.0.0\n",
+		"module \"github.com/foo/bar\n",
+	} {
+		if _, err := parseGoMod([]byte(bad)); err == nil {
+			t.Errorf("expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestManifestFromGoMod(t *testing.T) {
+	f, err := parseGoMod([]byte(testGoMod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := manifestFromGoMod(f)
+
+	want := map[gps.ProjectRoot]string{
+		"github.com/pkg/errors": "^0.8.0",
+		"github.com/foo/bar":    "^2.1.0",
+		"github.com/foo/old":    "^3.0.1",
+		"gopkg.in/yaml.v2":      "^2.2.1",
+	}
+	got := make(map[gps.ProjectRoot]string)
+	for pr, pp := range m.Constraints {
+		got[pr] = pp.Constraint.String()
+		if pp.Source != "" {
+			t.Errorf("expected no source for %s, got %s", pr, pp.Source)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected constraints:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+}
+
+func TestAnalyzerDeriveManifestFromGoMod(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("dep")
+	h.TempFile(filepath.Join("dep", GoModName), testGoMod)
+
+	m, l, err := Analyzer{}.DeriveManifestAndLock(h.Path("dep"), "github.com/foo/lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != nil {
+		t.Fatalf("expected lock to be nil, got: %#v", l)
+	}
+	if c := m.DependencyConstraints()["github.com/pkg/errors"].Constraint; c == nil || c.String() != "^0.8.0" {
+		t.Fatalf("expected the requirements in go.mod to be constraints, got %v", c)
+	}
+
+	// A manifest takes precedence over go.mod.
+	h.TempCopy(filepath.Join("dep", ManifestName), filepath.Join("analyzer", ManifestName))
+	if m, _, err = (Analyzer{}).DeriveManifestAndLock(h.Path("dep"), "github.com/foo/lib"); err != nil {
+		t.Fatal(err)
+	}
+	if c := m.DependencyConstraints()["github.com/pkg/errors"].Constraint; c == nil || c.String() == "^0.8.0" {
+		t.Fatalf("expected the manifest, rather than go.mod, to be read, got %v", c)
+	}
+
+	h.Must(os.Remove(filepath.Join(h.Path("dep"), ManifestName)))
+	h.TempFile(filepath.Join("dep", GoModName), "require github.com/foo/bar\n")
+	if _, _, err := (Analyzer{}).DeriveManifestAndLock(h.Path("dep"), "github.com/foo/lib"); err == nil {
+		t.Fatal("expected an error for a malformed go.mod")
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// archiveType is the source type of release archives.
+const archiveType = "archive"
+
+var (
+	// archiveExtRegex matches the path of an archive, capturing its
+	// extension.
+	archiveExtRegex = regexp.MustCompile(`\.(tar\.gz|tgz|zip)$`)
+	// archiveVersionRegex matches the version in the file name of an archive,
+	// as in foo-1.2.3.tar.gz or foo_v2.0.0-rc.1.zip.
+	archiveVersionRegex = regexp.MustCompile(`[-_](v?[0-9]+(?:\.[0-9]+)*(?:-[0-9A-Za-z.]+)?)\.(?:tar\.gz|tgz|zip)$`)
+	// archiveDigestRegex matches the fragment of an archive's URL, which
+	// holds the SHA-256 digest of the archive.
+	archiveDigestRegex = regexp.MustCompile(`^sha256=([0-9a-f]{64})$`)
+)
+
+// isArchiveURL reports whether u names a release archive.
+func isArchiveURL(u *url.URL) bool {
+	return u.Host != "" && archiveExtRegex.MatchString(u.Path)
+}
+
+// deduceArchiveSource returns the source for the archive at u, which must be
+// an HTTPS URL whose fragment gives the archive's digest, as in
+// https://example.com/foo-1.2.3.tar.gz#sha256=<hex>.
+func deduceArchiveSource(u *url.URL) (maybeSources, error) {
+	if u.Scheme != "https" {
+		return nil, errors.Errorf("archive %s must be fetched over https", u)
+	}
+	if !archiveDigestRegex.MatchString(u.Fragment) {
+		return nil, errors.Errorf("archive %s must give its digest as a #sha256=<hex> fragment", u)
+	}
+	return maybeSources{maybeArchiveSource{url: u}}, nil
+}
+
+type maybeArchiveSource struct {
+	url *url.URL
+}
+
+func (m maybeArchiveSource) try(ctx context.Context, cachedir string) (source, error) {
+	u := *m.url
+	u.Fragment = ""
+	return &archiveSource{
+		url:    u.String(),
+		name:   path.Base(u.Path),
+		digest: archiveDigestRegex.FindStringSubmatch(m.url.Fragment)[1],
+		path:   sourceCachePath(cachedir, m.url.String()),
+	}, nil
+}
+
+func (m maybeArchiveSource) URL() *url.URL {
+	return m.url
+}
+
+func (m maybeArchiveSource) String() string {
+	return fmt.Sprintf("%T: %s", m, ufmt(m.url))
+}
+
+// archiveSource is a release archive, downloaded over HTTPS. As the archive
+// is pinned by its digest, it never changes, and holds a single version: the
+// one in its file name, or failing that the file name itself. The version is
+// paired with the archive's digest, which serves as its revision.
+//
+// The archive is extracted into the source's cache directory, leaving out the
+// single top-level directory that release archives are often wrapped in.
+type archiveSource struct {
+	url    string
+	name   string // The file name of the archive.
+	digest string // The hex-encoded SHA-256 digest of the archive.
+	path   string // The directory into which the archive is extracted.
+	remote *remoteConfig
+}
+
+func (s *archiveSource) setRemoteConfig(rc *remoteConfig) {
+	s.remote = rc
+}
+
+func (s *archiveSource) sourceType() string {
+	return archiveType
+}
+
+func (s *archiveSource) upstreamURL() string {
+	return s.url
+}
+
+func (s *archiveSource) existsLocally(ctx context.Context) bool {
+	fi, err := os.Stat(s.path)
+	return err == nil && fi.IsDir()
+}
+
+func (s *archiveSource) existsUpstream(ctx context.Context) bool {
+	resp, err := s.get(ctx, "HEAD")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+func (*archiveSource) existsCallsListVersions() bool {
+	return false
+}
+
+func (*archiveSource) listVersionsRequiresLocal() bool {
+	return false
+}
+
+// get sends a request for the archive, failing unless it succeeds.
+func (s *archiveSource) get(ctx context.Context, method string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", s.url)
+	}
+	s.remote.authorize(req)
+
+	resp, err := s.remote.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed HTTP request to URL %q", s.url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("unable to fetch %s: %s", s.url, resp.Status)
+	}
+	return resp, nil
+}
+
+// initLocal downloads the archive, checks its digest and extracts it.
+func (s *archiveSource) initLocal(ctx context.Context) error {
+	f, err := ioutil.TempFile("", "dep-archive")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary file")
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	resp, err := s.get(ctx, "GET")
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return errors.Wrapf(err, "unable to download %s", s.url)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != s.digest {
+		return errors.Errorf("archive %s has digest sha256=%s, not the expected sha256=%s", s.url, got, s.digest)
+	}
+
+	// Extract to a temporary directory beside the final one, so that a
+	// failure does not leave a partial tree behind.
+	tmp := s.path + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractArchive(f, archiveExtRegex.FindStringSubmatch(s.name)[1], tmp); err != nil {
+		return errors.Wrapf(err, "unable to extract %s", s.url)
+	}
+
+	root, err := archiveRoot(tmp)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0777); err != nil {
+		return err
+	}
+	// The extracted tree never changes, so it is sealed, letting
+	// exportRevisionTo hard link to its files.
+	if err := sealTree(root, s.path); err != nil {
+		return err
+	}
+	return fs.RenameWithFallback(root, s.path)
+}
+
+// updateLocal does nothing, as the archive never changes.
+func (s *archiveSource) updateLocal(ctx context.Context) error {
+	return nil
+}
+
+func (s *archiveSource) maybeClean(ctx context.Context) error {
+	return nil
+}
+
+// version returns the archive's single version.
+func (s *archiveSource) version() PairedVersion {
+	name := s.name
+	if m := archiveVersionRegex.FindStringSubmatch(name); m != nil {
+		name = m[1]
+	} else {
+		name = archiveExtRegex.ReplaceAllString(name, "")
+	}
+	return NewVersion(name).Pair(Revision(s.digest)).(PairedVersion)
+}
+
+func (s *archiveSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	return []PairedVersion{s.version()}, nil
+}
+
+// checkRevision returns an error unless r is the archive's revision.
+func (s *archiveSource) checkRevision(r Revision) error {
+	if string(r) != s.digest {
+		return errors.Errorf("archive %s has the single revision %s, not %s", s.url, s.digest, r)
+	}
+	return nil
+}
+
+func (s *archiveSource) revisionPresentIn(ctx context.Context, r Revision) (bool, error) {
+	return string(r) == s.digest, nil
+}
+
+func (s *archiveSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	if err := s.checkRevision(r); err != nil {
+		return "", err
+	}
+	return r, nil
+}
+
+func (s *archiveSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	if err := s.checkRevision(r); err != nil {
+		return nil, nil, err
+	}
+
+	m, l, err := an.DeriveManifestAndLock(s.path, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+
+	return prepManifest(m), l, nil
+}
+
+func (s *archiveSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	if err := s.checkRevision(r); err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return pkgtree.ListPackages(s.path, string(pr))
+}
+
+func (s *archiveSource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	if err := s.checkRevision(r); err != nil {
+		return err
+	}
+
+	// The archive is extracted again if its tree was changed through a file
+	// hard linked to it by an earlier export.
+	unchanged, err := treeUnchanged(s.path)
+	if err != nil {
+		return err
+	}
+	if !unchanged {
+		if err := removeTree(s.path); err != nil {
+			return err
+		}
+		if err := s.initLocal(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Only make the parent dir, as LinkDir will balk on trying to write to an
+	// empty but existing dir.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	return fs.LinkDir(s.path, to)
+}
+
+// extractArchive extracts the archive in f, of the type given by its
+// extension, into the directory dir. Only regular files and directories are
+// extracted; other entries, such as symlinks, are skipped.
+func extractArchive(f *os.File, ext, dir string) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if ext == "zip" {
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return err
+		}
+		for _, zf := range zr.File {
+			mode := zf.Mode()
+			if !mode.IsDir() && !mode.IsRegular() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			err = writeArchiveEntry(dir, zf.Name, mode, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeRegA:
+			if err := writeArchiveEntry(dir, hdr.Name, hdr.FileInfo().Mode(), tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeArchiveEntry writes the archive entry called name, a directory or a
+// regular file with the contents in r, under dir. Names that would escape
+// dir are rejected.
+func writeArchiveEntry(dir, name string, mode os.FileMode, r io.Reader) error {
+	name = strings.Replace(name, `\`, "/", -1)
+	clean := path.Clean(name)
+	if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return errors.Errorf("archive entry %q is outside of the archive's root", name)
+	}
+	if clean == "." {
+		return nil
+	}
+	target := filepath.Join(dir, filepath.FromSlash(clean))
+
+	if mode.IsDir() {
+		return os.MkdirAll(target, 0777)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// archiveRoot returns the root of the tree extracted into dir: its only
+// entry, if that is a directory, and otherwise dir itself.
+func archiveRoot(dir string) (string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(fis) == 1 && fis[0].IsDir() {
+		return filepath.Join(dir, fis[0].Name()), nil
+	}
+	return dir, nil
+}
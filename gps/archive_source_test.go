@@ -0,0 +1,240 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// archiveFiles are the contents of the archives served in tests, wrapped in
+// a top-level directory as release archives usually are.
+var archiveFiles = map[string]string{
+	"foo-1.2.3/foo.go":     "package foo\n",
+	"foo-1.2.3/bar/bar.go": "package bar\n",
+}
+
+// mkTarGz returns a gzipped tarball holding files, mapping names to contents,
+// with prefix put before each name. Contents starting with "->" make symlinks
+// to the rest of them. The tarball starts with a pax global header, as those
+// made by git archive do, which is not to be extracted.
+func mkTarGz(t *testing.T, prefix string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "abc"}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		hdr := &tar.Header{Name: prefix + name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if strings.HasPrefix(data, "->") {
+			hdr = &tar.Header{Name: prefix + name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: data[2:]}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func mkZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDeduceArchiveSource(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	dc := newDeductionCoordinator(nil)
+
+	in := "https://github.com/foo/bar/archive/foo-1.2.3.tar.gz#sha256=" + digest
+	pd, err := dc.deduceKnownPaths(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "github.com/foo/bar/archive/foo-1.2.3.tar.gz" {
+		t.Errorf("unexpected root %s", pd.root)
+	}
+	if len(pd.mb) != 1 {
+		t.Fatalf("expected a single source, got %s", pd.mb)
+	}
+	if _, ok := pd.mb[0].(maybeArchiveSource); !ok {
+		t.Errorf("expected an archive source, got %s", pd.mb[0])
+	}
+
+	for in, want := range map[string]string{
+		"http://example.com/foo-1.2.3.zip#sha256=" + digest: "must be fetched over https",
+		"https://example.com/foo-1.2.3.zip":                 "must give its digest",
+		"https://example.com/foo-1.2.3.tgz#md5=abc":         "must give its digest",
+	} {
+		if _, err := dc.deduceKnownPaths(in); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("unexpected error for %s:\n\t(GOT): %v\n\t(WNT): %s", in, err, want)
+		}
+	}
+}
+
+func TestArchiveSource(t *testing.T) {
+	archives := map[string][]byte{
+		"/dl/foo-1.2.3.tar.gz": mkTarGz(t, "", archiveFiles),
+		"/dl/foo-1.2.3.zip":    mkZip(t, archiveFiles),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, has := archives[r.URL.Path]
+		if !has {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer ts.Close()
+
+	for name, data := range archives {
+		t.Run(name, func(t *testing.T) {
+			cachedir, err := ioutil.TempDir("", "archive-source")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(cachedir)
+
+			ctx := context.Background()
+			digest := sha256Hex(data)
+			src, err := maybeArchiveSource{url: mkurl(ts.URL + name + "#sha256=" + digest)}.try(ctx, cachedir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !src.existsUpstream(ctx) {
+				t.Fatal("expected the archive to exist upstream")
+			}
+			if src.existsLocally(ctx) {
+				t.Fatal("expected the archive not to exist locally before it is fetched")
+			}
+			if err := src.initLocal(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			vlist, err := src.listVersions(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := NewVersion("1.2.3").Pair(Revision(digest))
+			if len(vlist) != 1 || !vlist[0].identical(want) {
+				t.Fatalf("expected the single version %s, got %s", want, vlist)
+			}
+
+			ptree, err := src.listPackages(ctx, "example.com/foo", Revision(digest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ip := range []string{"example.com/foo", "example.com/foo/bar"} {
+				if _, has := ptree.Packages[ip]; !has {
+					t.Errorf("expected package %s, got %v", ip, ptree.Packages)
+				}
+			}
+
+			to := filepath.Join(cachedir, "export")
+			if err := src.exportRevisionTo(ctx, Revision(digest), to); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(filepath.Join(to, "bar", "bar.go"))
+			if err != nil || string(got) != "package bar\n" {
+				t.Errorf("unexpected export of bar/bar.go: %q, %v", got, err)
+			}
+
+			if err := src.exportRevisionTo(ctx, Revision("deadbeef"), to); err == nil {
+				t.Error("expected an error exporting a revision other than the archive's")
+			}
+		})
+	}
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		cachedir, err := ioutil.TempDir("", "archive-source")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(cachedir)
+
+		ctx := context.Background()
+		u := mkurl(ts.URL + "/dl/foo-1.2.3.zip#sha256=" + strings.Repeat("0", 64))
+		src, err := maybeArchiveSource{url: u}.try(ctx, cachedir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := src.initLocal(ctx); err == nil || !strings.Contains(err.Error(), "not the expected sha256") {
+			t.Errorf("expected a digest mismatch, got %v", err)
+		}
+		if src.existsLocally(ctx) {
+			t.Error("expected nothing to be extracted from an archive with the wrong digest")
+		}
+	})
+}
+
+func TestArchiveVersion(t *testing.T) {
+	cases := map[string]string{
+		"foo-1.2.3.tar.gz":    "1.2.3",
+		"foo_v2.0.0-rc.1.zip": "v2.0.0-rc.1",
+		"v1.0.0.tgz":          "v1.0.0",
+		"snapshot.zip":        "snapshot",
+	}
+	for name, want := range cases {
+		s := &archiveSource{name: name, digest: "d"}
+		if got := s.version().String(); got != want {
+			t.Errorf("unexpected version for %s:\n\t(GOT): %s\n\t(WNT): %s", name, got, want)
+		}
+	}
+}
+
+func TestWriteArchiveEntryOutsideRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive-entry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"../evil.go", "/etc/evil.go", `..\evil.go`, "a/../../evil.go"} {
+		if err := writeArchiveEntry(dir, name, 0644, strings.NewReader("")); err == nil {
+			t.Errorf("expected an error writing %s", name)
+		}
+	}
+}
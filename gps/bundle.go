@@ -0,0 +1,351 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// bundleManifestName is the name of the entry, first in every bundle,
+// describing the sources in it.
+const bundleManifestName = "bundle.json"
+
+// bundledVersionsName is the name of the file, in the cache directory,
+// recording the versions of the sources imported from bundles, for when they
+// cannot be listed upstream.
+const bundledVersionsName = "bundled.json"
+
+// bundleManifest describes the sources in a bundle.
+type bundleManifest struct {
+	Projects []bundleProject `json:"projects"`
+}
+
+// bundleProject describes the source of a project in a bundle, and the
+// versions it had when the bundle was made.
+type bundleProject struct {
+	Root     string          `json:"root"`
+	Source   string          `json:"source,omitempty"`
+	VCS      string          `json:"vcs"`
+	URL      string          `json:"url"`
+	Dir      string          `json:"dir"`
+	Revision Revision        `json:"revision"`
+	Versions []bundleVersion `json:"versions"`
+}
+
+// bundleVersion is a paired version, as recorded in bundles.
+type bundleVersion struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"` // "branch", "default-branch" or "version".
+	Revision Revision `json:"revision"`
+}
+
+func newBundleVersion(pv PairedVersion) bundleVersion {
+	bv := bundleVersion{Name: pv.String(), Type: "version", Revision: pv.Revision()}
+	if bv2, ok := pv.Unpair().(branchVersion); ok {
+		bv.Type = "branch"
+		if bv2.isDefault {
+			bv.Type = "default-branch"
+		}
+	}
+	return bv
+}
+
+func (bv bundleVersion) paired() PairedVersion {
+	switch bv.Type {
+	case "branch":
+		return NewBranch(bv.Name).Pair(bv.Revision)
+	case "default-branch":
+		return newDefaultBranch(bv.Name).Pair(bv.Revision)
+	default:
+		return NewVersion(bv.Name).Pair(bv.Revision)
+	}
+}
+
+// BundleImportResult reports what importing a bundle did.
+type BundleImportResult struct {
+	// Imported are the roots of the projects whose sources were added to the
+	// cache.
+	Imported []string
+	// Skipped are the roots of the projects whose sources were already in
+	// the cache, and were kept.
+	Skipped []string
+}
+
+// ExportBundle writes to w a tar archive holding the sources of the projects
+// in l, each with its locked revision, along with their versions, so that
+// ImportBundle can fill the cache of another machine with exactly what it
+// needs to solve and vendor l without reaching the network.
+func (sm *SourceMgr) ExportBundle(ctx context.Context, l Lock, w io.Writer) error {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return ErrSourceManagerIsReleased
+	}
+	if sm.daemon != nil {
+		return sm.daemon.exportBundle(ctx, l, w)
+	}
+
+	var bm bundleManifest
+	dirs := make(map[string]string)
+	for _, lp := range l.Projects() {
+		id := lp.Ident()
+		rev, _, _ := VersionComponentStrings(lp.Version())
+		if rev == "" {
+			return errors.Errorf("%s is locked without a revision, so it cannot be bundled", id)
+		}
+
+		sg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
+		if err != nil {
+			return err
+		}
+		cp, ok := sg.src.(cachePather)
+		if !ok {
+			return errors.Errorf("the source of %s is not kept in the cache, so it cannot be bundled", id)
+		}
+		present, err := sg.revisionPresentIn(ctx, Revision(rev))
+		if err != nil {
+			return err
+		}
+		if !present {
+			if err := sg.syncLocal(ctx); err != nil {
+				return err
+			}
+		}
+		pvs, err := sg.listVersions(ctx)
+		if err != nil {
+			return err
+		}
+
+		bp := bundleProject{
+			Root:     string(id.ProjectRoot),
+			Source:   id.Source,
+			VCS:      sg.src.sourceType(),
+			URL:      sg.src.upstreamURL(),
+			Dir:      filepath.Base(cp.cachePath()),
+			Revision: Revision(rev),
+		}
+		for _, pv := range pvs {
+			bp.Versions = append(bp.Versions, newBundleVersion(pv))
+		}
+		bm.Projects = append(bm.Projects, bp)
+		dirs[bp.Dir] = cp.cachePath()
+	}
+
+	tw := tar.NewWriter(w)
+	data, err := json.MarshalIndent(bm, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: bundleManifestName, Mode: 0644, Size: int64(len(data)), ModTime: archiveModTime}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := addSourceToBundle(tw, dirs[name]); err != nil {
+			return errors.Wrapf(err, "failed to bundle %s", name)
+		}
+	}
+	return tw.Close()
+}
+
+// addSourceToBundle writes the source kept in dir, in the cache, along with
+// the files kept beside it, to tw, under sources/. As with the archives of
+// vendor trees, entries carry no modification times or owners, so that
+// bundles of the same sources are the same.
+func addSourceToBundle(tw *tar.Writer, dir string) error {
+	paths := []string{dir}
+	for _, suffix := range cachedSourceSuffixes {
+		if _, err := os.Stat(dir + suffix); err == nil {
+			paths = append(paths, dir+suffix)
+		}
+	}
+
+	for _, p := range paths {
+		// Symlinks are left out; the working trees they are found in are
+		// restored from the repositories as they are cleaned.
+		if err := addTreeToArchive(tw, p, path.Join("sources", filepath.Base(p)), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportBundle adds the sources in the bundle read from r, as written by
+// ExportBundle, to the cache, and records their versions and go-get metadata
+// for when they cannot be reached. Sources already in the cache are kept,
+// unless replace is true.
+func (sm *SourceMgr) ImportBundle(r io.Reader, replace bool) (BundleImportResult, error) {
+	var res BundleImportResult
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return res, ErrSourceManagerIsReleased
+	}
+	if sm.daemon != nil {
+		return sm.daemon.importBundle(sm.cachedir, r, replace)
+	}
+
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != bundleManifestName {
+		return res, errors.New("not a bundle: it does not start with " + bundleManifestName)
+	}
+	var bm bundleManifest
+	if err := json.NewDecoder(tr).Decode(&bm); err != nil {
+		return res, errors.Wrap(err, "unable to read the bundle's manifest")
+	}
+
+	// The sources are extracted beside the cache, and moved into place once
+	// they are complete.
+	sources := filepath.Join(sm.cachedir, "sources")
+	tmp, err := ioutil.TempDir(sm.cachedir, "bundle")
+	if err != nil {
+		return res, err
+	}
+	defer os.RemoveAll(tmp)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, errors.Wrap(err, "unable to read the bundle")
+		}
+		name := strings.TrimPrefix(hdr.Name, "sources/")
+		if name == hdr.Name {
+			return res, errors.Errorf("unexpected entry %q in the bundle", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeRegA:
+			if err := writeArchiveEntry(tmp, name, hdr.FileInfo().Mode(), tr); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	if err := os.MkdirAll(sources, 0777); err != nil {
+		return res, err
+	}
+	versions, err := loadBundledVersions(sm.cachedir)
+	if err != nil {
+		return res, err
+	}
+	kept := make(map[string]bool)
+	moved := make(map[string]bool)
+	for _, bp := range bm.Projects {
+		if bp.Dir == "" || bp.Dir != filepath.Base(bp.Dir) || strings.HasPrefix(bp.Dir, ".") {
+			return res, errors.Errorf("the bundle names an invalid source directory for %s: %q", bp.Root, bp.Dir)
+		}
+
+		if !kept[bp.Dir] && !moved[bp.Dir] {
+			if _, err := os.Stat(filepath.Join(sources, bp.Dir)); err == nil && !replace {
+				kept[bp.Dir] = true
+			} else if err := moveBundledSource(tmp, sources, bp.Dir); err != nil {
+				return res, err
+			} else {
+				moved[bp.Dir] = true
+			}
+		}
+		if kept[bp.Dir] {
+			res.Skipped = append(res.Skipped, bp.Root)
+		} else {
+			res.Imported = append(res.Imported, bp.Root)
+		}
+		versions[bp.Dir] = bp.Versions
+
+		// Projects whose sources cannot be deduced from their import paths
+		// alone need their go-get metadata.
+		if bp.Source == "" {
+			if _, err := sm.deduceCoord.deduceKnownPaths(bp.Root); err == errNoKnownPathMatch {
+				sm.deduceCoord.meta.store(GoImport{Root: bp.Root, VCS: bp.VCS, RepoRoot: bp.URL})
+			}
+		}
+	}
+	return res, saveBundledVersions(sm.cachedir, versions)
+}
+
+// moveBundledSource moves the source named name, extracted from a bundle into
+// dir, into the sources directory of the cache, in place of whatever was there.
+func moveBundledSource(dir, sources, name string) error {
+	if err := removeCachedSource(sources, name); err != nil {
+		return err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if cachedSourceName(fi.Name()) != name {
+			continue
+		}
+		if err := fs.RenameWithFallback(filepath.Join(dir, fi.Name()), filepath.Join(sources, fi.Name())); err != nil {
+			return errors.Wrapf(err, "unable to move %s into the cache", fi.Name())
+		}
+	}
+	return nil
+}
+
+// bundledVersions returns the versions of src, as recorded when it was
+// imported from a bundle, if it was.
+func bundledVersions(cachedir string, src source) ([]PairedVersion, bool) {
+	cp, ok := src.(cachePather)
+	if !ok {
+		return nil, false
+	}
+	versions, err := loadBundledVersions(cachedir)
+	if err != nil {
+		return nil, false
+	}
+	bvs, has := versions[filepath.Base(cp.cachePath())]
+	if !has {
+		return nil, false
+	}
+	pvs := make([]PairedVersion, 0, len(bvs))
+	for _, bv := range bvs {
+		pvs = append(pvs, bv.paired())
+	}
+	return pvs, true
+}
+
+// loadBundledVersions reads the versions of the sources imported from bundles,
+// by the names of their directories. A missing file holds none.
+func loadBundledVersions(cachedir string) (map[string][]bundleVersion, error) {
+	versions := make(map[string][]bundleVersion)
+	data, err := ioutil.ReadFile(filepath.Join(cachedir, bundledVersionsName))
+	if os.IsNotExist(err) {
+		return versions, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &versions)
+	}
+	return versions, errors.Wrapf(err, "unable to read %s", bundledVersionsName)
+}
+
+// saveBundledVersions writes the versions of the sources imported from
+// bundles.
+func saveBundledVersions(cachedir string, versions map[string][]bundleVersion) error {
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(ioutil.WriteFile(filepath.Join(cachedir, bundledVersionsName), data, 0666), "unable to write %s", bundledVersionsName)
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBundleVersionRoundTrip(t *testing.T) {
+	rev := Revision("c2a2d9a4ba62e1db8e4b3e4a7e3bcc2a0c83e5e1")
+	pvs := []PairedVersion{
+		NewBranch("dev").Pair(rev),
+		newDefaultBranch("master").Pair(rev),
+		NewVersion("v1.2.3").Pair(rev),
+		NewVersion("stable").Pair(rev),
+	}
+	for _, pv := range pvs {
+		got := newBundleVersion(pv).paired()
+		if !reflect.DeepEqual(got, pv) {
+			t.Errorf("unexpected version after a round trip through a bundle:\n\t(GOT): %#v\n\t(WNT): %#v", got, pv)
+		}
+	}
+}
+
+func TestImportBundle(t *testing.T) {
+	src, err := ioutil.TempDir("", "bundle-src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+
+	const dir = "https---example.com-foo-bar"
+	if err := os.MkdirAll(filepath.Join(src, dir, ".git"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, dir, ".git", "HEAD"), []byte("ref: refs/heads/master\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	rev := Revision("c2a2d9a4ba62e1db8e4b3e4a7e3bcc2a0c83e5e1")
+	bm := bundleManifest{Projects: []bundleProject{{
+		Root:     "example.com/foo/bar",
+		VCS:      "git",
+		URL:      "https://example.com/foo/bar",
+		Dir:      dir,
+		Revision: rev,
+		Versions: []bundleVersion{newBundleVersion(NewVersion("v1.0.0").Pair(rev))},
+	}}}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	data, err := json.Marshal(bm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: bundleManifestName, Mode: 0644, Size: int64(len(data))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := addSourceToBundle(tw, filepath.Join(src, dir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bundle := buf.Bytes()
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	res, err := sm.ImportBundle(bytes.NewReader(bundle), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/foo/bar"}; !reflect.DeepEqual(res.Imported, want) || len(res.Skipped) != 0 {
+		t.Errorf("expected %v to be imported, got %+v", want, res)
+	}
+	head, err := ioutil.ReadFile(filepath.Join(sm.cachedir, "sources", dir, ".git", "HEAD"))
+	if err != nil || string(head) != "ref: refs/heads/master\n" {
+		t.Errorf("expected the source to be extracted into the cache, got %q, %v", head, err)
+	}
+
+	pvs, has := bundledVersions(sm.cachedir, &archiveSource{path: filepath.Join(sm.cachedir, "sources", dir)})
+	if want := []PairedVersion{NewVersion("v1.0.0").Pair(rev)}; !has || !reflect.DeepEqual(pvs, want) {
+		t.Errorf("expected the bundled versions %v to be recorded, got %v", want, pvs)
+	}
+
+	res, err = sm.ImportBundle(bytes.NewReader(bundle), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Imported) != 0 || len(res.Skipped) != 1 {
+		t.Errorf("expected the source already in the cache to be kept, got %+v", res)
+	}
+
+	if _, err := sm.ImportBundle(bytes.NewReader([]byte("not a tar")), false); err == nil {
+		t.Error("expected an error importing something that is not a bundle")
+	}
+}
@@ -0,0 +1,497 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// cacheIndexName is the name of the file, in the cache directory, recording
+// when the sources in the cache were last used, and which projects recently
+// used which, for collecting the sources that are no longer needed.
+const cacheIndexName = "sources.json"
+
+// CacheGCPolicy controls which sources are removed from the cache directory
+// when it is garbage collected. Sources are removed if they have not been used
+// for longer than MaxAge, and then, least recently used first, until the
+// sources left take up no more than MaxSize. Sources used since the SourceMgr
+// was created, and the sources of the projects in the KeepLocks most recently
+// recorded locks, are never removed.
+//
+// The zero value removes nothing.
+type CacheGCPolicy struct {
+	// MaxSize is how many bytes the sources in the cache may take up.
+	// <=0: No limit.
+	MaxSize int64
+
+	// MaxAge is how long a source may go unused before it is removed.
+	// <=0: No limit.
+	MaxAge time.Duration
+
+	// KeepLocks is how many of the most recently recorded locks have the
+	// sources of their projects kept, however old or large they are.
+	KeepLocks int
+
+	// Interval is how often the cache is garbage collected automatically,
+	// when the SourceMgr is released. <=0: Only when CollectGarbage is
+	// called.
+	Interval time.Duration
+}
+
+func (p CacheGCPolicy) limited() bool {
+	return p.MaxSize > 0 || p.MaxAge > 0
+}
+
+// CachedSource describes a source in the cache directory.
+type CachedSource struct {
+	// Name is the name of the source's directory in the cache.
+	Name string
+	// Size is how many bytes the source takes up.
+	Size int64
+	// LastUsed is when the source was last used.
+	LastUsed time.Time
+}
+
+// CacheGCResult reports what garbage collecting the cache directory did.
+type CacheGCResult struct {
+	// Removed are the sources that were removed, least recently used first.
+	Removed []CachedSource
+	// Freed is how many bytes the removed sources took up.
+	Freed int64
+	// Remaining is how many bytes the sources left take up.
+	Remaining int64
+	// RemovedTrees is how many pruned trees were removed from the cache's
+	// TreeStore, for having gone unused for longer than MaxAge.
+	RemovedTrees int
+}
+
+// cacheIndex is the content of the cache index file.
+type cacheIndex struct {
+	Sources map[string]*cacheIndexSource `json:"sources"` // By the name of their directories.
+	Locks   map[string]*cacheIndexLock   `json:"locks"`   // By the roots of the projects they belong to.
+	LastGC  time.Time                    `json:"lastGC"`
+}
+
+// cacheIndexSource records when a source was last used, and by which names
+// projects were fetched from it.
+type cacheIndexSource struct {
+	Used  time.Time `json:"used"`
+	Names []string  `json:"names"`
+}
+
+// cacheIndexLock records the names of the sources of the projects in a lock,
+// and when it was recorded.
+type cacheIndexLock struct {
+	Used     time.Time `json:"used"`
+	Projects []string  `json:"projects"`
+}
+
+// cachePather is implemented by sources that are kept in the cache directory.
+type cachePather interface {
+	// cachePath returns the path of the source in the cache directory.
+	cachePath() string
+}
+
+func (bs *baseVCSSource) cachePath() string    { return bs.repo.LocalPath() }
+func (s *archiveSource) cachePath() string     { return s.path }
+func (s *moduleProxySource) cachePath() string { return s.path }
+
+// noteUse records that the source of sg was used as the source of the project
+// named name. The caller must hold sc.srcmut.
+func (sc *sourceCoordinator) noteUse(sg *sourceGateway, name string) {
+	cp, ok := sg.src.(cachePather)
+	if !ok {
+		return
+	}
+	dir := filepath.Base(cp.cachePath())
+	if sc.used == nil {
+		sc.used = make(map[string]map[string]bool)
+	}
+	if sc.used[dir] == nil {
+		sc.used[dir] = make(map[string]bool)
+	}
+	sc.used[dir][toFold(name)] = true
+}
+
+// usedSources returns the names of the directories of the sources used so
+// far, along with the names of the projects they were used for.
+func (sc *sourceCoordinator) usedSources() map[string][]string {
+	sc.srcmut.RLock()
+	defer sc.srcmut.RUnlock()
+	used := make(map[string][]string, len(sc.used))
+	for dir, names := range sc.used {
+		for name := range names {
+			used[dir] = append(used[dir], name)
+		}
+	}
+	return used
+}
+
+// RecordLock records that the project rooted at root, on disk, depends on the
+// projects in l, so that their sources are kept while l is among the most
+// recently recorded locks, as CacheGCPolicy.KeepLocks says.
+func (sm *SourceMgr) RecordLock(root string, l Lock) {
+	if l == nil {
+		return
+	}
+	projects := make([]string, 0, len(l.Projects()))
+	for _, lp := range l.Projects() {
+		projects = append(projects, toFold(lp.Ident().normalizedSource()))
+	}
+	sort.Strings(projects)
+
+	if sm.daemon != nil {
+		sm.daemon.recordLock(root, projects)
+		return
+	}
+	sm.recordLock(root, projects)
+}
+
+// recordLock records that the project rooted at root depends on projects, by
+// their normalized sources.
+func (sm *SourceMgr) recordLock(root string, projects []string) {
+	sm.lockmut.Lock()
+	if sm.locks == nil {
+		sm.locks = make(map[string][]string)
+	}
+	sm.locks[root] = projects
+	sm.lockmut.Unlock()
+}
+
+// CollectGarbage removes the sources in the cache directory that p says are
+// no longer needed, and reports what it removed. If dryRun is true, it only
+// reports what it would remove.
+func (sm *SourceMgr) CollectGarbage(p CacheGCPolicy, dryRun bool) (CacheGCResult, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return CacheGCResult{}, ErrSourceManagerIsReleased
+	}
+	if sm.daemon != nil {
+		return sm.daemon.collectGarbage(p, dryRun)
+	}
+
+	// Collecting the garbage explicitly, even only to report it, takes the
+	// place of collecting it automatically on release.
+	sm.gc = CacheGCPolicy{}
+
+	idx := sm.updateCacheIndex()
+	res, err := collectGarbage(filepath.Join(sm.cachedir, "sources"), idx, p, sm.sourcesInUse(), time.Now(), dryRun)
+	if err != nil {
+		return res, err
+	}
+	if p.MaxAge > 0 {
+		res.RemovedTrees, err = CacheTreeStore(sm.cachedir).removeUnused(time.Now().Add(-p.MaxAge), dryRun)
+		if err != nil {
+			return res, err
+		}
+	}
+	if dryRun {
+		return res, nil
+	}
+	idx.LastGC = time.Now()
+	for _, cs := range res.Removed {
+		delete(idx.Sources, cs.Name)
+	}
+	return res, saveCacheIndex(sm.cachedir, idx)
+}
+
+// sourcesInUse returns the names of the directories of the sources used since
+// sm was created.
+func (sm *SourceMgr) sourcesInUse() map[string]bool {
+	used := sm.srcCoord.usedSources()
+	inUse := make(map[string]bool, len(used))
+	for dir := range used {
+		inUse[dir] = true
+	}
+	return inUse
+}
+
+// maybeCollectGarbage records the use of the cache, and garbage collects it as
+// sm.gc says, if it is time to. Failures are logged.
+func (sm *SourceMgr) maybeCollectGarbage() {
+	idx := sm.updateCacheIndex()
+	if sm.gc.limited() && sm.gc.Interval > 0 && time.Since(idx.LastGC) >= sm.gc.Interval {
+		res, err := collectGarbage(filepath.Join(sm.cachedir, "sources"), idx, sm.gc, sm.sourcesInUse(), time.Now(), false)
+		if err != nil {
+			sm.srcCoord.logger.Warnf("%s", errors.Wrap(err, "failed to garbage collect the source cache"))
+		}
+		for _, cs := range res.Removed {
+			delete(idx.Sources, cs.Name)
+		}
+		idx.LastGC = time.Now()
+		if len(res.Removed) > 0 {
+			sm.srcCoord.logger.Infof("Removed %d unused sources from the cache, freeing %d bytes", len(res.Removed), res.Freed)
+		}
+		if sm.gc.MaxAge > 0 {
+			n, err := CacheTreeStore(sm.cachedir).removeUnused(time.Now().Add(-sm.gc.MaxAge), false)
+			if err != nil {
+				sm.srcCoord.logger.Warnf("%s", errors.Wrap(err, "failed to garbage collect the tree store"))
+			}
+			if n > 0 {
+				sm.srcCoord.logger.Infof("Removed %d unused vendor trees from the cache", n)
+			}
+		}
+	}
+	if err := saveCacheIndex(sm.cachedir, idx); err != nil {
+		sm.srcCoord.logger.Warnf("%s", errors.Wrap(err, "failed to record the use of the source cache"))
+	}
+}
+
+// updateCacheIndex loads the cache index, and records in it the sources used
+// and locks recorded since sm was created, as of now.
+func (sm *SourceMgr) updateCacheIndex() *cacheIndex {
+	idx, err := loadCacheIndex(sm.cachedir)
+	if err != nil {
+		sm.srcCoord.logger.Warnf("%s", errors.Wrap(err, "ignoring unreadable source cache index"))
+	}
+
+	now := time.Now()
+	for dir, names := range sm.srcCoord.usedSources() {
+		is := idx.Sources[dir]
+		if is == nil {
+			is = &cacheIndexSource{}
+			idx.Sources[dir] = is
+		}
+		is.Used = now
+		for _, name := range names {
+			if !contains(is.Names, name) {
+				is.Names = append(is.Names, name)
+			}
+		}
+		sort.Strings(is.Names)
+	}
+
+	sm.lockmut.Lock()
+	for root, projects := range sm.locks {
+		idx.Locks[root] = &cacheIndexLock{Used: now, Projects: projects}
+	}
+	sm.lockmut.Unlock()
+	return idx
+}
+
+// collectGarbage removes the sources in dir that p says are no longer needed,
+// as of now, going by idx, and reports what it removed, or would remove if
+// dryRun is true. The sources named in inUse are kept.
+func collectGarbage(dir string, idx *cacheIndex, p CacheGCPolicy, inUse map[string]bool, now time.Time, dryRun bool) (CacheGCResult, error) {
+	var res CacheGCResult
+	if !p.limited() {
+		return res, nil
+	}
+
+	entries, err := listCachedSources(dir, idx)
+	if err != nil {
+		return res, err
+	}
+
+	// Sources in use, and those of the projects in the most recent locks,
+	// are kept.
+	keepNames := make(map[string]bool)
+	locks := make([]*cacheIndexLock, 0, len(idx.Locks))
+	for _, l := range idx.Locks {
+		locks = append(locks, l)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Used.After(locks[j].Used) })
+	for i := 0; i < p.KeepLocks && i < len(locks); i++ {
+		for _, name := range locks[i].Projects {
+			keepNames[name] = true
+		}
+	}
+	kept := func(cs CachedSource) bool {
+		if inUse[cs.Name] {
+			return true
+		}
+		is := idx.Sources[cs.Name]
+		if is == nil {
+			return false
+		}
+		for _, name := range is.Names {
+			if keepNames[name] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var total int64
+	for _, cs := range entries {
+		total += cs.Size
+	}
+	remove := func(cs CachedSource) error {
+		if !dryRun {
+			if err := removeCachedSource(dir, cs.Name); err != nil {
+				return err
+			}
+		}
+		res.Removed = append(res.Removed, cs)
+		res.Freed += cs.Size
+		total -= cs.Size
+		return nil
+	}
+
+	// Least recently used first.
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].LastUsed.Equal(entries[j].LastUsed) {
+			return entries[i].LastUsed.Before(entries[j].LastUsed)
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	for _, cs := range entries {
+		if kept(cs) {
+			continue
+		}
+		if (p.MaxAge > 0 && now.Sub(cs.LastUsed) > p.MaxAge) || (p.MaxSize > 0 && total > p.MaxSize) {
+			if err := remove(cs); err != nil {
+				res.Remaining = total
+				return res, err
+			}
+		}
+	}
+	res.Remaining = total
+	return res, nil
+}
+
+// listCachedSources lists the sources in dir, with their sizes, and when they
+// were last used: as recorded in idx, or else when they were last modified.
+//
+// The files and directories that belong to a source besides its own directory
+// - the staging directory into which it is cloned, and the files beside it
+// that some kinds of sources keep - are counted as part of it.
+func listCachedSources(dir string, idx *cacheIndex) ([]CachedSource, error) {
+	byName := make(map[string]*CachedSource)
+	add := func(name, path string, fi os.FileInfo) {
+		cs := byName[name]
+		if cs == nil {
+			cs = &CachedSource{Name: name}
+			byName[name] = cs
+		}
+		if fi.IsDir() {
+			cs.Size += dirSize(path)
+		} else {
+			cs.Size += fi.Size()
+		}
+		if fi.ModTime().After(cs.LastUsed) {
+			cs.LastUsed = fi.ModTime()
+		}
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the source cache")
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() && name == stagingDirName {
+			staged, err := ioutil.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to list the source cache")
+			}
+			for _, sfi := range staged {
+				add(sfi.Name(), filepath.Join(dir, name, sfi.Name()), sfi)
+			}
+			continue
+		}
+		add(cachedSourceName(name), filepath.Join(dir, name), fi)
+	}
+
+	entries := make([]CachedSource, 0, len(byName))
+	for name, cs := range byName {
+		if is := idx.Sources[name]; is != nil && !is.Used.IsZero() {
+			cs.LastUsed = is.Used
+		}
+		entries = append(entries, *cs)
+	}
+	return entries, nil
+}
+
+// cachedSourceSuffixes are the suffixes of the files that sources keep beside
+// their directories: the repository files of fossil sources, the archives
+// being extracted, and the digests of those extracted.
+var cachedSourceSuffixes = []string{".fossil", ".tmp", ".digest"}
+
+// cachedSourceName returns the name of the source to which the file or
+// directory named name, in the source cache, belongs.
+func cachedSourceName(name string) string {
+	for _, suffix := range cachedSourceSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// removeCachedSource removes the source named name from dir, along with
+// everything that belongs to it.
+func removeCachedSource(dir, name string) error {
+	path := filepath.Join(dir, name)
+	paths := []string{path, stagingPath(path)}
+	for _, suffix := range cachedSourceSuffixes {
+		paths = append(paths, path+suffix)
+	}
+	for _, p := range paths {
+		if err := os.RemoveAll(p); err != nil {
+			return errors.Wrapf(err, "failed to remove %s from the source cache", name)
+		}
+	}
+	return nil
+}
+
+// loadCacheIndex reads the cache index in cachedir. A missing index is empty;
+// an unreadable one is reported, and treated as empty.
+func loadCacheIndex(cachedir string) (*cacheIndex, error) {
+	idx := &cacheIndex{
+		Sources: make(map[string]*cacheIndexSource),
+		Locks:   make(map[string]*cacheIndexLock),
+	}
+	data, err := ioutil.ReadFile(filepath.Join(cachedir, cacheIndexName))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, idx)
+	}
+	if err != nil {
+		return &cacheIndex{
+			Sources: make(map[string]*cacheIndexSource),
+			Locks:   make(map[string]*cacheIndexLock),
+		}, err
+	}
+	if idx.Sources == nil {
+		idx.Sources = make(map[string]*cacheIndexSource)
+	}
+	if idx.Locks == nil {
+		idx.Locks = make(map[string]*cacheIndexLock)
+	}
+	return idx, nil
+}
+
+// saveCacheIndex writes idx to the cache index in cachedir.
+func saveCacheIndex(cachedir string, idx *cacheIndex) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(cachedir, cacheIndexName)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return fs.RenameWithFallback(tmp.Name(), filepath.Join(cachedir, cacheIndexName))
+}
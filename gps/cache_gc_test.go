@@ -0,0 +1,135 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-gc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mkSource := func(path string, size int) {
+		if err := os.MkdirAll(path, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, "pack"), make([]byte, size), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkSource(filepath.Join(dir, "old"), 100)
+	mkSource(filepath.Join(dir, "locked"), 100)
+	mkSource(filepath.Join(dir, "big"), 1000)
+	mkSource(filepath.Join(dir, "recent"), 100)
+	mkSource(filepath.Join(dir, "inuse"), 100)
+	mkSource(filepath.Join(dir, stagingDirName, "old"), 50)
+	if err := ioutil.WriteFile(filepath.Join(dir, "old.fossil"), make([]byte, 10), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	day := 24 * time.Hour
+	idx := &cacheIndex{
+		Sources: map[string]*cacheIndexSource{
+			"old":    {Used: now.Add(-100 * day), Names: []string{"example.com/old"}},
+			"locked": {Used: now.Add(-100 * day), Names: []string{"example.com/locked"}},
+			"big":    {Used: now.Add(-2 * day), Names: []string{"example.com/big"}},
+			"recent": {Used: now.Add(-day), Names: []string{"example.com/recent"}},
+			"inuse":  {Used: now.Add(-200 * day), Names: []string{"example.com/inuse"}},
+		},
+		Locks: map[string]*cacheIndexLock{
+			"/src/a": {Used: now, Projects: []string{"example.com/locked"}},
+			"/src/b": {Used: now.Add(-day), Projects: []string{"example.com/big"}},
+		},
+	}
+	p := CacheGCPolicy{MaxAge: 30 * day, MaxSize: 500, KeepLocks: 1}
+	inUse := map[string]bool{"inuse": true}
+
+	names := func(res CacheGCResult) []string {
+		var names []string
+		for _, cs := range res.Removed {
+			names = append(names, cs.Name)
+		}
+		return names
+	}
+
+	res, err := collectGarbage(dir, idx, p, inUse, now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"old", "big"}; !reflect.DeepEqual(names(res), want) {
+		t.Fatalf("expected a dry run to remove %v, got %v", want, names(res))
+	}
+	if res.Freed != 1160 || res.Remaining != 300 {
+		t.Errorf("expected 1160 bytes freed and 300 remaining, got %d and %d", res.Freed, res.Remaining)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); err != nil {
+		t.Errorf("expected a dry run to leave the sources in place: %v", err)
+	}
+
+	if _, err := collectGarbage(dir, idx, p, inUse, now, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"old", "old.fossil", filepath.Join(stagingDirName, "old"), "big"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", path, err)
+		}
+	}
+	for _, path := range []string{"locked", "recent", "inuse"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("expected %s to be kept: %v", path, err)
+		}
+	}
+}
+
+func TestCollectGarbageUnlimited(t *testing.T) {
+	res, err := collectGarbage("/nonexistent", &cacheIndex{}, CacheGCPolicy{KeepLocks: 5}, nil, time.Now(), false)
+	if err != nil || len(res.Removed) != 0 {
+		t.Errorf("expected a policy without limits to remove nothing, got %v, %v", res.Removed, err)
+	}
+}
+
+func TestCacheIndexRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-gc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	idx, err := loadCacheIndex(dir)
+	if err != nil {
+		t.Fatalf("expected a missing index to load empty, got %v", err)
+	}
+	used := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	idx.Sources["src"] = &cacheIndexSource{Used: used, Names: []string{"example.com/src"}}
+	idx.Locks["/src/a"] = &cacheIndexLock{Used: used, Projects: []string{"example.com/src"}}
+	if err := saveCacheIndex(dir, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadCacheIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, idx) {
+		t.Errorf("unexpected index after a round trip:\n\t(GOT): %+v\n\t(WNT): %+v", got, idx)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, cacheIndexName), []byte("{"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := loadCacheIndex(dir); err == nil || len(got.Sources) != 0 {
+		t.Errorf("expected an unreadable index to be reported and treated as empty, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/internal/events"
+	"github.com/pkg/errors"
+)
+
+// ChecksumDB is a checksum database, such as sum.golang.org, that serves the
+// hashes of the contents of module versions as they were first observed, in
+// the same way as the go command's GOSUMDB. The contents of the versions of
+// projects fetched from module proxies are checked against it.
+type ChecksumDB struct {
+	// URL is the URL under which the database serves its lookups.
+	URL string
+
+	// Key is the key with which the database signs its tree heads, in the
+	// form of the go command's verifier keys: the database's name, the hash
+	// of the key, and the Ed25519 public key, joined by "+".
+	Key string
+
+	// Fail is true if versions whose contents differ from the database's,
+	// or that the database could not be asked about, are refused. Otherwise,
+	// they are used, and the problem is logged.
+	Fail bool
+}
+
+// checksumDB checks the hashes of module versions against a ChecksumDB.
+type checksumDB struct {
+	ChecksumDB
+	name    string
+	keyHash uint32
+	pub     ed25519.PublicKey
+	logger  logging.Logger
+}
+
+// newChecksumDB returns the checksumDB for db, which may be nil, logging the
+// problems that do not fail checks to logger.
+func newChecksumDB(db *ChecksumDB, logger logging.Logger) (*checksumDB, error) {
+	if db == nil {
+		return nil, nil
+	}
+	parts := strings.SplitN(db.Key, "+", 3)
+	if len(parts) != 3 || parts[0] == "" || len(parts[1]) != 8 {
+		return nil, errors.Errorf("malformed checksum database key %q", db.Key)
+	}
+	hash, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return nil, errors.Errorf("malformed checksum database key %q", db.Key)
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil || len(key) != 1+ed25519.PublicKeySize || key[0] != 1 {
+		return nil, errors.Errorf("checksum database key %q is not an Ed25519 key", db.Key)
+	}
+	if checksumKeyHash(parts[0], key) != uint32(hash) {
+		return nil, errors.Errorf("checksum database key %q does not match its hash", db.Key)
+	}
+	return &checksumDB{
+		ChecksumDB: *db,
+		name:       parts[0],
+		keyHash:    uint32(hash),
+		pub:        ed25519.PublicKey(key[1:]),
+		logger:     logger,
+	}, nil
+}
+
+// checksumKeyHash returns the hash identifying the key of the database name,
+// as encoded in its verifier key.
+func checksumKeyHash(name string, key []byte) uint32 {
+	h := sha256.New()
+	h.Write([]byte(name + "\n"))
+	h.Write(key)
+	return binary.BigEndian.Uint32(h.Sum(nil))
+}
+
+// check checks that sum, the hash of the contents of version of module, is
+// the one the database has. Problems are returned if the database fails
+// checks, and logged otherwise.
+func (db *checksumDB) check(ctx context.Context, rc *remoteConfig, module, version, sum string) error {
+	err := db.lookup(ctx, rc, module, version, sum)
+	if err == nil {
+		return nil
+	}
+	if db.Fail {
+		return err
+	}
+	if db.logger != nil {
+		db.logger.Warnf("%s", err)
+	}
+	events.Warn(err.Error())
+	return nil
+}
+
+// lookup asks the database for the hash of the contents of version of module,
+// verifies the signature on its answer, and compares the hash with sum.
+func (db *checksumDB) lookup(ctx context.Context, rc *remoteConfig, module, version, sum string) error {
+	u := strings.TrimSuffix(db.URL, "/") + "/lookup/" + escapeModulePath(module) + "@" + escapeModulePath(version)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	rc.authorize(req)
+	resp, err := rc.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "unable to check %s@%s against the checksum database", module, version)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unable to check %s@%s against the checksum database: %s", module, version, resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return errors.Wrapf(err, "unable to check %s@%s against the checksum database", module, version)
+	}
+
+	want, err := db.verifiedSum(body, module, version)
+	if err != nil {
+		return errors.Wrapf(err, "unable to check %s@%s against the checksum database", module, version)
+	}
+	if want != sum {
+		return errors.Errorf("the contents of %s@%s have hash %s, but the checksum database has %s", module, version, sum, want)
+	}
+	return nil
+}
+
+// verifiedSum returns the hash of the contents of version of module in the
+// answer of the database to a lookup, once the signature on the tree head the
+// answer ends with is verified.
+func (db *checksumDB) verifiedSum(body []byte, module, version string) (string, error) {
+	i := bytes.Index(body, []byte("\n\n"))
+	if i < 0 {
+		return "", errors.New("malformed lookup response")
+	}
+	records, note := body[:i+1], body[i+2:]
+	if err := db.verifyNote(note); err != nil {
+		return "", err
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(records))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 3 && f[0] == module && f[1] == version {
+			return f[2], nil
+		}
+	}
+	return "", errors.New("lookup response has no record of the version")
+}
+
+// verifyNote verifies that the signed note holds a signature by the database's
+// key on the text preceding the signatures.
+func (db *checksumDB) verifyNote(note []byte) error {
+	i := bytes.LastIndex(note, []byte("\n\n"))
+	if i < 0 {
+		return errors.New("malformed signed tree head")
+	}
+	text, sigs := note[:i+1], note[i+2:]
+
+	prefix := "— " + db.name + " "
+	for _, line := range strings.Split(string(sigs), "\n") {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(line[len(prefix):])
+		if err != nil || len(sig) != 4+ed25519.SignatureSize || binary.BigEndian.Uint32(sig) != db.keyHash {
+			continue
+		}
+		if ed25519.Verify(db.pub, text, sig[4:]) {
+			return nil
+		}
+		return errors.Errorf("invalid signature by %s on tree head", db.name)
+	}
+	return errors.Errorf("tree head is not signed by %s", db.name)
+}
+
+// hashModuleZip returns the hash of the contents of the module zip in f, of
+// size n, as the go command computes it for go.sum and checksum databases.
+func hashModuleZip(f *os.File, n int64) (string, error) {
+	zr, err := zip.NewReader(f, n)
+	if err != nil {
+		return "", err
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	names := make([]string, 0, len(zr.File))
+	for _, zf := range zr.File {
+		files[zf.Name] = zf
+		names = append(names, zf.Name)
+	}
+	return hash1(names, func(name string) (io.ReadCloser, error) {
+		return files[name].Open()
+	})
+}
+
+// HashTree returns the hash of the contents of the regular files in the tree
+// rooted at dir, in the form of the go command's "h1:" hashes. Symlinks and
+// other irregular files are left out.
+//
+// It is the hash the go command would give a module zip holding the tree at
+// its root, rather than under a directory named for the module and version,
+// so it depends only on the contents of the files and their paths in the
+// tree.
+func HashTree(dir string) (string, error) {
+	var names []string
+	err := filepath.Walk(dir, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fp)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hash1(names, func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	})
+}
+
+// HashModule returns the hashes that go.sum records for version of module,
+// whose contents are the tree rooted at dir: that of the module zip the go
+// command would make of the tree, and that of its go.mod file. Directories of
+// version control systems, packages in vendor directories, and directories
+// that are modules of their own are left out of the zip, as the go command
+// leaves them out. A tree without a go.mod file is given the one the go
+// command synthesizes for it.
+func HashModule(dir, module, version string) (sum, modSum string, err error) {
+	var names []string
+	err = filepath.Walk(dir, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fp)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			if fp == dir {
+				return nil
+			}
+			switch fi.Name() {
+			case ".bzr", ".git", ".hg", ".svn":
+				return filepath.SkipDir
+			}
+			if _, err := os.Lstat(filepath.Join(fp, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() || isVendoredPackageFile(rel) {
+			return nil
+		}
+		names = append(names, rel)
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix := module + "@" + version + "/"
+	zipNames := make([]string, len(names))
+	for i, name := range names {
+		zipNames[i] = prefix + name
+	}
+	sum, err = hash1(zipNames, func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, prefix))))
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	gomod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if os.IsNotExist(err) {
+		gomod, err = []byte(fmt.Sprintf("module %s\n", module)), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	modSum, err = hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(gomod)), nil
+	})
+	return sum, modSum, err
+}
+
+// isVendoredPackageFile reports whether the file at the slash-separated path
+// name is in a package in a vendor directory, which module zips leave out.
+// Files directly in a vendor directory, such as vendor/modules.txt, are kept.
+func isVendoredPackageFile(name string) bool {
+	var i int
+	if strings.HasPrefix(name, "vendor/") {
+		i = len("vendor/")
+	} else if j := strings.Index(name, "/vendor/"); j >= 0 {
+		i = j + len("/vendor/")
+	} else {
+		return false
+	}
+	return strings.Contains(name[i:], "/")
+}
+
+// hash1 returns the "h1:" hash of the files named by names, which open opens:
+// the SHA-256 of a summary listing the SHA-256 of each file and its name, in
+// the order of their names.
+func hash1(names []string, open func(name string) (io.ReadCloser, error)) (string, error) {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+
+	summary := sha256.New()
+	for _, name := range names {
+		if strings.Contains(name, "\n") {
+			return "", errors.Errorf("file name %q contains a newline", name)
+		}
+		rc, err := open(name)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps/logging"
+)
+
+// testChecksumDB serves signed lookups of the hashes in sums, keyed by module
+// and version, as a checksum database does.
+type testChecksumDB struct {
+	name string
+	priv ed25519.PrivateKey
+	key  string
+	sums map[string]string
+}
+
+func newTestChecksumDB(t *testing.T, name string) *testChecksumDB {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte{1}, pub...)
+	return &testChecksumDB{
+		name: name,
+		priv: priv,
+		key:  fmt.Sprintf("%s+%08x+%s", name, checksumKeyHash(name, data), base64.StdEncoding.EncodeToString(data)),
+		sums: make(map[string]string),
+	}
+}
+
+func (db *testChecksumDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mv := strings.TrimPrefix(r.URL.Path, "/lookup/")
+	i := strings.LastIndex(mv, "@")
+	sum, has := db.sums[mv]
+	if i < 0 || !has {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	text := "go.sum database tree\n42\nAq8Qjq5LqdmIJ0a9aIL/9AiYsc5mcgBT7G3IcYhH1EQ=\n"
+	sig := make([]byte, 4, 4+ed25519.SignatureSize)
+	binary.BigEndian.PutUint32(sig, checksumKeyHash(db.name, append([]byte{1}, db.priv.Public().(ed25519.PublicKey)...)))
+	sig = append(sig, ed25519.Sign(db.priv, []byte(text))...)
+	fmt.Fprintf(w, "41\n%s %s %s\n%s %s/go.mod h1:Ucx3WzwMHTJR1xrb9iu0sp8RJD2x1pOXLhOZ6QSa8Rw=\n\n%s\n— %s %s\n",
+		mv[:i], mv[i+1:], sum, mv[:i], mv[i+1:], text, db.name, base64.StdEncoding.EncodeToString(sig))
+}
+
+func TestNewChecksumDB(t *testing.T) {
+	db := newTestChecksumDB(t, "sum.example.com")
+	for _, key := range []string{db.key, "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8"} {
+		if _, err := newChecksumDB(&ChecksumDB{URL: "https://sum.example.com", Key: key}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parts := strings.SplitN(db.key, "+", 3)
+	for _, key := range []string{
+		"sum.example.com",
+		"sum.example.com+00000000+" + parts[2],
+		"sum.example.com+" + parts[1] + "+AAAA",
+		"other.example.com+" + parts[1] + "+" + parts[2],
+	} {
+		if _, err := newChecksumDB(&ChecksumDB{URL: "https://sum.example.com", Key: key}, nil); err == nil {
+			t.Errorf("expected an error for the key %q", key)
+		}
+	}
+}
+
+func TestModuleProxySourceChecksumDB(t *testing.T) {
+	p := &testModuleProxy{
+		module:   "github.com/example/lib",
+		versions: []string{"v1.0.0", "v1.1.0", "v1.2.0"},
+		files:    map[string]string{"lib.go": "package lib // VERSION\n"},
+	}
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	sumOf := func(v string) string {
+		resp, err := http.Get(proxy.URL + "/github.com/example/lib/@v/" + v + ".zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		f, err := ioutil.TempFile("", "module-zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		f.Write(data)
+		sum, err := hashModuleZip(f, int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+
+	tdb := newTestChecksumDB(t, "sum.example.com")
+	tdb.sums["github.com/example/lib@v1.0.0"] = sumOf("v1.0.0")
+	tdb.sums["github.com/example/lib@v1.1.0"] = "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	sumdb := httptest.NewServer(tdb)
+	defer sumdb.Close()
+
+	cachedir, err := ioutil.TempDir("", "checksum-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	var logged bytes.Buffer
+	for _, fail := range []bool{true, false} {
+		db, err := newChecksumDB(&ChecksumDB{URL: sumdb.URL, Key: tdb.key, Fail: fail}, logging.New(&logged, logging.Options{Level: logging.Debug}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := &moduleProxySource{
+			base:   proxy.URL + "/github.com/example/lib",
+			module: p.module,
+			path:   fmt.Sprintf("%s/%v", cachedir, fail),
+			remote: &remoteConfig{sumdb: db},
+			revs:   make(map[Revision]string),
+		}
+		ctx := context.Background()
+		if err := src.initLocal(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := src.dir(ctx, "v1.0.0"); err != nil {
+			t.Errorf("expected the contents matching the database to be used, got %s", err)
+		}
+
+		// The database has another hash for v1.1.0, and none for v1.2.0.
+		for _, v := range []Revision{"v1.1.0", "v1.2.0"} {
+			logged.Reset()
+			_, err := src.dir(ctx, v)
+			if fail && err == nil {
+				t.Errorf("expected %s to be refused", v)
+			}
+			if !fail && (err != nil || !strings.Contains(logged.String(), "Warning: ")) {
+				t.Errorf("expected %s to be used with a warning, got %v and %q", v, err, logged.String())
+			}
+		}
+	}
+
+	// Answers signed with another key are not trusted.
+	other := newTestChecksumDB(t, "sum.example.com")
+	db, err := newChecksumDB(&ChecksumDB{URL: sumdb.URL, Key: other.key, Fail: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.check(context.Background(), nil, p.module, "v1.0.0", tdb.sums["github.com/example/lib@v1.0.0"]); err == nil {
+		t.Error("expected an answer signed with another key to be refused")
+	}
+}
+
+func TestHashTree(t *testing.T) {
+	files := map[string]string{
+		"go.mod":     "module example.com/lib\n",
+		"lib.go":     "package lib\n",
+		"sub/sub.go": "package sub\n",
+	}
+
+	dir, err := ioutil.TempDir("", "hash-tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range files {
+		fp := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fp), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fp, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The hash of the tree is that of a module zip holding the same files.
+	data := mkZip(t, files)
+	f, err := ioutil.TempFile("", "module-zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	want, err := hashModuleZip(f, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := HashTree(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("unexpected hash of the tree:\n\t(GOT): %s\n\t(WNT): %s", got, want)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib // changed\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = HashTree(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got == want {
+		t.Fatal("expected the hash of the tree to change with its contents")
+	}
+}
+
+func TestHashModule(t *testing.T) {
+	files := map[string]string{
+		"errors.go":             "package errors\n",
+		"sub/sub.go":            "package sub\n",
+		"vendor/modules.txt":    "# nothing\n",
+		"vendor/a.com/b/b.go":   "package b\n",
+		".git/HEAD":             "ref: refs/heads/master\n",
+		"nested/go.mod":         "module github.com/pkg/errors/nested\n",
+		"nested/nested.go":      "package nested\n",
+		"sub/vendor/c.com/c.go": "package c\n",
+	}
+
+	dir, err := ioutil.TempDir("", "hash-module")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range files {
+		fp := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fp), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fp, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the files the go command would put in the module zip are hashed.
+	zipped := make(map[string]string)
+	for _, name := range []string{"errors.go", "sub/sub.go", "vendor/modules.txt"} {
+		zipped["github.com/pkg/errors@v0.8.0/"+name] = files[name]
+	}
+	data := mkZip(t, zipped)
+	f, err := ioutil.TempFile("", "module-zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	want, err := hashModuleZip(f, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, modSum, err := HashModule(dir, "github.com/pkg/errors", "v0.8.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != want {
+		t.Errorf("unexpected hash of the module:\n\t(GOT): %s\n\t(WNT): %s", sum, want)
+	}
+	// The hash of the go.mod the go command synthesizes for the module, as
+	// recorded in the go.sum files of its dependents.
+	if wantMod := "h1:bwawxfHBFNV+L2hUp1rHADufV3IMtnDRdf1r5NINEl0="; modSum != wantMod {
+		t.Errorf("unexpected hash of the go.mod:\n\t(GOT): %s\n\t(WNT): %s", modSum, wantMod)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/pkg/errors\n\nrequire a.com/b v1.0.0\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, got, err := HashModule(dir, "github.com/pkg/errors", "v0.8.0"); err != nil || got == modSum {
+		t.Errorf("expected the hash of the go.mod in the tree, got %s, %v", got, err)
+	}
+}
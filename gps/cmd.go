@@ -5,7 +5,16 @@
 package gps
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 func (c cmd) Args() []string {
@@ -31,3 +40,263 @@ func init() {
 		os.Unsetenv(e)
 	}
 }
+
+// commandLimits are the limits on how long the VCS commands run under a context
+// may take.
+type commandLimits struct {
+	// timeout is how long each command may run. <=0: No limit.
+	timeout time.Duration
+	// stall is how long each command may run without writing any output.
+	// <=0: No limit.
+	stall time.Duration
+}
+
+type commandLimitsKey struct{}
+
+// hungCommandGrace is how long a command that exceeded its commandLimits is
+// given to exit after being interrupted, before it is killed.
+var hungCommandGrace = 5 * time.Second
+
+// withCommandLimits returns a context under which VCS commands are limited as
+// p says.
+func withCommandLimits(ctx context.Context, p RetryPolicy) context.Context {
+	if p.CommandTimeout <= 0 && p.StallTimeout <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, commandLimitsKey{}, commandLimits{
+		timeout: p.CommandTimeout,
+		stall:   p.StallTimeout,
+	})
+}
+
+// commandLimitsFrom returns the limits on the VCS commands run under ctx.
+func commandLimitsFrom(ctx context.Context) commandLimits {
+	lim, _ := ctx.Value(commandLimitsKey{}).(commandLimits)
+	return lim
+}
+
+type restrictedKey struct{}
+
+// withRestrictedCommands returns a context under which VCS commands are run in
+// the restricted profile, if restricted is set.
+func withRestrictedCommands(ctx context.Context, restricted bool) context.Context {
+	if !restricted {
+		return ctx
+	}
+	return context.WithValue(ctx, restrictedKey{}, true)
+}
+
+// restrictedFrom reports whether the VCS commands run under ctx are run in the
+// restricted profile.
+func restrictedFrom(ctx context.Context) bool {
+	r, _ := ctx.Value(restrictedKey{}).(bool)
+	return r
+}
+
+// restrictedEnvVars are the only variables of a command's environment that
+// are passed on to it in the restricted profile, in upper case. Everything
+// else, such as variables that point VCS tools at other programs, libraries or
+// configuration, is left out.
+var restrictedEnvVars = map[string]bool{
+	"PATH":            true,
+	"HOME":            true,
+	"USER":            true,
+	"LOGNAME":         true,
+	"TMPDIR":          true,
+	"TEMP":            true,
+	"TMP":             true,
+	"SYSTEMROOT":      true,
+	"USERPROFILE":     true,
+	"PATHEXT":         true,
+	"LANG":            true,
+	"SSH_AUTH_SOCK":   true,
+	"GIT_SSH":         true,
+	"GIT_SSH_COMMAND": true,
+	"HTTP_PROXY":      true,
+	"HTTPS_PROXY":     true,
+	"NO_PROXY":        true,
+	"ALL_PROXY":       true,
+}
+
+// restrictedEnv is added to the environment of commands in the restricted
+// profile. Neither the system nor the user's git and hg configuration is read,
+// and nothing may prompt for input.
+var restrictedEnv = []string{
+	"GIT_CONFIG_NOSYSTEM=1",
+	"GIT_CONFIG_GLOBAL=" + os.DevNull,
+	"GIT_TERMINAL_PROMPT=0",
+	"GIT_ASKPASS=",
+	"SSH_ASKPASS=",
+	"GCM_INTERACTIVE=never",
+	"HGRCPATH=",
+	"HGPLAIN=1",
+	"BZR_PLUGIN_PATH=-site:-user",
+}
+
+// restrictedGitConfig is the configuration git is given in the restricted
+// profile, turning off what could run programs other than git itself: hooks,
+// filesystem monitors, credential helpers, and the ext:: transport. Local
+// repositories may only be cloned when named directly, not as submodules.
+var restrictedGitConfig = [][2]string{
+	{"core.fsmonitor", "false"},
+	{"core.hooksPath", os.DevNull},
+	{"core.askPass", ""},
+	{"credential.helper", ""},
+	{"protocol.ext.allow", "never"},
+	{"protocol.file.allow", "user"},
+}
+
+// restrictEnv returns env, or dep's environment if env is nil, as it is for
+// commands run in the restricted profile.
+//
+// The git configuration passed through GIT_CONFIG_* variables, such as the
+// credentials and proxies dep configures for remotes, is kept, and takes
+// precedence over restrictedGitConfig.
+func restrictEnv(env []string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+
+	var out []string
+	var count int
+	var hasSSH bool
+	keys, values := make(map[int]string), make(map[int]string)
+	for _, kv := range env {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			continue
+		}
+		k, v := kv[:i], kv[i+1:]
+		switch {
+		case k == "GIT_CONFIG_COUNT":
+			count, _ = strconv.Atoi(v)
+		case strings.HasPrefix(k, "GIT_CONFIG_KEY_"):
+			n, err := strconv.Atoi(strings.TrimPrefix(k, "GIT_CONFIG_KEY_"))
+			if err == nil {
+				keys[n] = v
+			}
+		case strings.HasPrefix(k, "GIT_CONFIG_VALUE_"):
+			n, err := strconv.Atoi(strings.TrimPrefix(k, "GIT_CONFIG_VALUE_"))
+			if err == nil {
+				values[n] = v
+			}
+		case restrictedEnvVars[strings.ToUpper(k)]:
+			out = append(out, kv)
+			hasSSH = hasSSH || k == "GIT_SSH" || k == "GIT_SSH_COMMAND"
+		}
+	}
+	out = append(out, restrictedEnv...)
+	if !hasSSH {
+		// The user's configuration is not read, so nor is any ssh command
+		// configured in it.
+		out = append(out, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+
+	config := append([][2]string(nil), restrictedGitConfig...)
+	for i := 0; i < count; i++ {
+		if k, has := keys[i]; has {
+			config = append(config, [2]string{k, values[i]})
+		}
+	}
+	for i, kv := range config {
+		out = append(out,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, kv[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, kv[1]),
+		)
+	}
+	return append(out, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(config)))
+}
+
+// commandTimeoutError is the error of a VCS command that was killed for
+// exceeding its commandLimits.
+type commandTimeoutError struct {
+	args    []string
+	limit   time.Duration
+	stalled bool // The command wrote no output for limit.
+}
+
+func (e *commandTimeoutError) Error() string {
+	if e.stalled {
+		return fmt.Sprintf("%s timed out after writing no output for %s", strings.Join(e.args, " "), e.limit)
+	}
+	return fmt.Sprintf("%s timed out after %s", strings.Join(e.args, " "), e.limit)
+}
+
+// watchdog enforces commandLimits on a running command. Output written
+// through it counts as activity, resetting the stall timer.
+type watchdog struct {
+	w       io.Writer
+	expired chan struct{} // Closed when a limit is exceeded.
+	once    sync.Once
+	err     *commandTimeoutError // Set before expired is closed.
+
+	stall          time.Duration
+	stallT, totalT *time.Timer
+}
+
+func newWatchdog(w io.Writer, args []string, lim commandLimits) *watchdog {
+	wd := &watchdog{
+		w:       w,
+		expired: make(chan struct{}),
+		stall:   lim.stall,
+	}
+	if lim.timeout > 0 {
+		wd.totalT = time.AfterFunc(lim.timeout, func() {
+			wd.expire(&commandTimeoutError{args: args, limit: lim.timeout})
+		})
+	}
+	if lim.stall > 0 {
+		wd.stallT = time.AfterFunc(lim.stall, func() {
+			wd.expire(&commandTimeoutError{args: args, limit: lim.stall, stalled: true})
+		})
+	}
+	return wd
+}
+
+func (wd *watchdog) expire(err *commandTimeoutError) {
+	wd.once.Do(func() {
+		wd.err = err
+		close(wd.expired)
+	})
+}
+
+func (wd *watchdog) Write(p []byte) (int, error) {
+	if wd.stallT != nil {
+		wd.stallT.Reset(wd.stall)
+	}
+	return wd.w.Write(p)
+}
+
+// stop stops the watchdog, returning the error of the limit the command
+// exceeded, if it exceeded one.
+func (wd *watchdog) stop() error {
+	for _, t := range []*time.Timer{wd.stallT, wd.totalT} {
+		if t != nil {
+			t.Stop()
+		}
+	}
+	select {
+	case <-wd.expired:
+		return wd.err
+	default:
+		return nil
+	}
+}
+
+// commandTimeout returns the error of the VCS command that exceeded its
+// commandLimits, if err is, or was caused by, one.
+func commandTimeout(err error) *commandTimeoutError {
+	for err != nil {
+		switch e := errors.Cause(err).(type) {
+		case *commandTimeoutError:
+			return e
+		case interface{ Original() error }:
+			// The errors of github.com/Masterminds/vcs hide the error of
+			// the command that failed.
+			err = e.Original()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
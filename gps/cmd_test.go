@@ -0,0 +1,202 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandLimits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are run with sh")
+	}
+
+	cases := []struct {
+		name   string
+		policy RetryPolicy
+		script string
+		// stalled is nil for commands that finish.
+		stalled *bool
+	}{
+		{
+			name:   "within limits",
+			policy: RetryPolicy{CommandTimeout: 10 * time.Second, StallTimeout: 2 * time.Second},
+			script: "for i in 1 2 3; do echo $i; sleep 0.1; done",
+		},
+		{
+			name:    "stalled",
+			policy:  RetryPolicy{StallTimeout: 200 * time.Millisecond},
+			script:  "echo start; sleep 30",
+			stalled: new(bool),
+		},
+		{
+			name:    "timed out",
+			policy:  RetryPolicy{CommandTimeout: 500 * time.Millisecond, StallTimeout: 2 * time.Second},
+			script:  "while true; do echo tick; sleep 0.1; done",
+			stalled: new(bool),
+		},
+		{
+			// The processes the command started are killed along with it,
+			// rather than being left to hold its output open.
+			name:    "stalled child",
+			policy:  RetryPolicy{StallTimeout: 200 * time.Millisecond},
+			script:  "sleep 30 & wait",
+			stalled: new(bool),
+		},
+	}
+	*cases[1].stalled, *cases[3].stalled = true, true
+
+	// sh leaves the commands it runs in the background to ignore SIGINT.
+	defer func(g time.Duration) { hungCommandGrace = g }(hungCommandGrace)
+	hungCommandGrace = 100 * time.Millisecond
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := withCommandLimits(context.Background(), c.policy)
+			start := time.Now()
+			_, err := commandContext(ctx, "sh", "-c", c.script).CombinedOutput()
+			if took := time.Since(start); took > 10*time.Second {
+				t.Fatalf("expected the command to be killed promptly, took %s", took)
+			}
+
+			if c.stalled == nil {
+				if err != nil {
+					t.Fatalf("expected the command to finish, got %v", err)
+				}
+				return
+			}
+			te := commandTimeout(newVcsRemoteErrorOr(err, []string{"sh"}, "", "unable to run"))
+			if te == nil {
+				t.Fatalf("expected the command to time out, got %v", err)
+			}
+			if te.stalled != *c.stalled {
+				t.Errorf("expected stalled to be %v, got %v", *c.stalled, te.stalled)
+			}
+		})
+	}
+}
+
+func TestSupervisorAttributesCommandTimeouts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are run with sh")
+	}
+
+	sup := newSupervisor(context.Background())
+	sup.policy = RetryPolicy{StallTimeout: 200 * time.Millisecond}
+	err := sup.do(context.Background(), "https://example.com/dead/repo", ctSourceFetch, func(ctx context.Context) error {
+		cmd := commandContext(ctx, "sh", "-c", "sleep 30")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return unwrapVcsErr(newVcsRemoteErrorOr(err, cmd.Args(), string(out), "unable to update repository"))
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected the fetch to time out")
+	}
+	for _, want := range []string{"https://example.com/dead/repo", "sh -c sleep 30 timed out after writing no output for 200ms"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to contain %q, got %q", want, err)
+		}
+	}
+}
+
+func TestRestrictEnv(t *testing.T) {
+	env := restrictEnv([]string{
+		"PATH=/usr/bin",
+		"HOME=/home/dep",
+		"https_proxy=http://proxy.example.com",
+		"LD_PRELOAD=/tmp/evil.so",
+		"GIT_EXEC_PATH=/tmp/git",
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://example.com/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic Zm9vOmJhcg==",
+	})
+
+	vars := make(map[string]string)
+	for _, kv := range env {
+		i := strings.IndexByte(kv, '=')
+		if _, has := vars[kv[:i]]; has {
+			t.Errorf("%s is set more than once", kv[:i])
+		}
+		vars[kv[:i]] = kv[i+1:]
+	}
+
+	for k, v := range map[string]string{
+		"PATH":                "/usr/bin",
+		"HOME":                "/home/dep",
+		"https_proxy":         "http://proxy.example.com",
+		"GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_CONFIG_GLOBAL":   os.DevNull,
+		"GIT_TERMINAL_PROMPT": "0",
+		"GIT_ASKPASS":         "",
+		"SSH_ASKPASS":         "",
+		"GCM_INTERACTIVE":     "never",
+		"HGRCPATH":            "",
+		"HGPLAIN":             "1",
+		"BZR_PLUGIN_PATH":     "-site:-user",
+		"GIT_SSH_COMMAND":     "ssh -o BatchMode=yes",
+		"GIT_CONFIG_KEY_0":    "core.fsmonitor",
+		"GIT_CONFIG_VALUE_0":  "false",
+		"GIT_CONFIG_KEY_1":    "core.hooksPath",
+		"GIT_CONFIG_VALUE_1":  os.DevNull,
+		"GIT_CONFIG_KEY_3":    "credential.helper",
+		"GIT_CONFIG_VALUE_3":  "",
+		"GIT_CONFIG_KEY_4":    "protocol.ext.allow",
+		"GIT_CONFIG_VALUE_4":  "never",
+		"GIT_CONFIG_KEY_6":    "http.https://example.com/.extraHeader",
+		"GIT_CONFIG_VALUE_6":  "Authorization: Basic Zm9vOmJhcg==",
+		"GIT_CONFIG_COUNT":    "7",
+	} {
+		if got, has := vars[k]; !has || got != v {
+			t.Errorf("unexpected value of %s: %q", k, got)
+		}
+	}
+	for _, k := range []string{"LD_PRELOAD", "GIT_EXEC_PATH"} {
+		if _, has := vars[k]; has {
+			t.Errorf("%s should have been left out", k)
+		}
+	}
+
+	env = restrictEnv([]string{"GIT_SSH_COMMAND=ssh -i key"})
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GIT_SSH_COMMAND=") && kv != "GIT_SSH_COMMAND=ssh -i key" {
+			t.Errorf("the ssh command the user chose should be kept, got %q", kv)
+		}
+	}
+}
+
+func TestRestrictedCommands(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	ctx := withRestrictedCommands(context.Background(), true)
+	cmd := commandContext(ctx, "git", "config", "--get", "core.hooksPath")
+	cmd.SetEnv(append(os.Environ(), "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=core.hooksPath", "GIT_CONFIG_VALUE_0=hooks"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	// Configuration dep passes on explicitly still takes precedence.
+	if got := strings.TrimSpace(string(out)); got != "hooks" {
+		t.Fatalf("unexpected core.hooksPath %q", got)
+	}
+
+	cmd = commandContext(ctx, "git", "config", "--get", "protocol.ext.allow")
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	if got := strings.TrimSpace(string(out)); got != "never" {
+		t.Fatalf("unexpected protocol.ext.allow %q", got)
+	}
+}
@@ -9,7 +9,6 @@ package gps
 import (
 	"bytes"
 	"context"
-	"os"
 	"os/exec"
 	"syscall"
 	"time"
@@ -41,7 +40,14 @@ func commandContext(ctx context.Context, name string, arg ...string) cmd {
 
 // CombinedOutput is like (*os/exec.Cmd).CombinedOutput except that it
 // terminates subprocesses gently (via os.Interrupt), but resorts to Kill if
-// the subprocess fails to exit after 1 minute.
+// the subprocess fails to exit after 1 minute. Subprocesses are also
+// terminated when they exceed the commandLimits of the caller's context, in
+// which case the error says which limit they exceeded. Under contexts with
+// the restricted profile, they are run with the environment restrictEnv says.
+//
+// The signals are sent to the subprocess's whole process group, so that the
+// processes it started, such as the helpers git runs to reach remotes, are
+// terminated along with it.
 func (c cmd) CombinedOutput() ([]byte, error) {
 	// Adapted from (*os/exec.Cmd).CombinedOutput
 	if c.Cmd.Stdout != nil {
@@ -50,33 +56,54 @@ func (c cmd) CombinedOutput() ([]byte, error) {
 	if c.Cmd.Stderr != nil {
 		return nil, errors.New("exec: Stderr already set")
 	}
+	if restrictedFrom(c.ctx) {
+		c.Cmd.Env = restrictEnv(c.Cmd.Env)
+	}
 	var b bytes.Buffer
-	c.Cmd.Stdout = &b
-	c.Cmd.Stderr = &b
+	wd := newWatchdog(&b, c.Cmd.Args, commandLimitsFrom(c.ctx))
+	c.Cmd.Stdout = wd
+	c.Cmd.Stderr = wd
 	if err := c.Cmd.Start(); err != nil {
+		wd.stop()
 		return nil, err
 	}
 
 	// Adapted from (*os/exec.Cmd).Start
+	//
+	// The grace period is read here rather than in the goroutine, which is
+	// waited for before returning, so that nothing it does outlives the call.
+	grace, hungGrace := time.Minute, hungCommandGrace
 	waitDone := make(chan struct{})
-	defer close(waitDone)
+	signalDone := make(chan struct{})
 	go func() {
+		defer close(signalDone)
 		select {
 		case <-c.ctx.Done():
-			if err := c.Cmd.Process.Signal(os.Interrupt); err != nil {
-				// If an error comes back from attempting to signal, proceed
-				// immediately to hard kill.
-				_ = c.Cmd.Process.Kill()
-			} else {
-				defer time.AfterFunc(time.Minute, func() {
-					_ = c.Cmd.Process.Kill()
-				}).Stop()
-				<-waitDone
-			}
+		case <-wd.expired:
+			// The command has hung, so is unlikely to exit gracefully.
+			grace = hungGrace
 		case <-waitDone:
+			return
+		}
+
+		pgid := -c.Cmd.Process.Pid
+		if err := syscall.Kill(pgid, syscall.SIGINT); err != nil {
+			// If an error comes back from attempting to signal, proceed
+			// immediately to hard kill.
+			_ = syscall.Kill(pgid, syscall.SIGKILL)
+		} else {
+			defer time.AfterFunc(grace, func() {
+				_ = syscall.Kill(pgid, syscall.SIGKILL)
+			}).Stop()
+			<-waitDone
 		}
 	}()
 
 	err := c.Cmd.Wait()
+	close(waitDone)
+	<-signalDone
+	if terr := wd.stop(); terr != nil && err != nil {
+		err = terr
+	}
 	return b.Bytes(), err
 }
@@ -5,14 +5,62 @@
 package gps
 
 import (
+	"bytes"
 	"context"
 	"os/exec"
+
+	"github.com/pkg/errors"
 )
 
 type cmd struct {
+	ctx context.Context
 	*exec.Cmd
 }
 
 func commandContext(ctx context.Context, name string, arg ...string) cmd {
-	return cmd{Cmd: exec.CommandContext(ctx, name, arg...)}
+	return cmd{ctx: ctx, Cmd: exec.CommandContext(ctx, name, arg...)}
+}
+
+// CombinedOutput is like (*os/exec.Cmd).CombinedOutput except that the
+// subprocess is also killed when it exceeds the commandLimits of the caller's
+// context, in which case the error says which limit it exceeded. Under
+// contexts with the restricted profile, it is run with the environment
+// restrictEnv says.
+func (c cmd) CombinedOutput() ([]byte, error) {
+	if c.Cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.Cmd.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	if restrictedFrom(c.ctx) {
+		c.Cmd.Env = restrictEnv(c.Cmd.Env)
+	}
+	var b bytes.Buffer
+	wd := newWatchdog(&b, c.Cmd.Args, commandLimitsFrom(c.ctx))
+	c.Cmd.Stdout = wd
+	c.Cmd.Stderr = wd
+	if err := c.Cmd.Start(); err != nil {
+		wd.stop()
+		return nil, err
+	}
+
+	waitDone := make(chan struct{})
+	killDone := make(chan struct{})
+	go func() {
+		defer close(killDone)
+		select {
+		case <-wd.expired:
+			_ = c.Cmd.Process.Kill()
+		case <-waitDone:
+		}
+	}()
+
+	err := c.Cmd.Wait()
+	close(waitDone)
+	<-killDone
+	if terr := wd.stop(); terr != nil && err != nil {
+		err = terr
+	}
+	return b.Bytes(), err
 }
@@ -383,25 +383,6 @@ func (m ProjectConstraints) asSortedSlice() []ProjectConstraint {
 	return pcs
 }
 
-// overrideAll treats the receiver ProjectConstraints map as a set of override
-// instructions, and applies overridden values to the ProjectConstraints.
-//
-// A slice of workingConstraint is returned, allowing differentiation between
-// values that were or were not overridden.
-func (m ProjectConstraints) overrideAll(pcm ProjectConstraints) (out []workingConstraint) {
-	out = make([]workingConstraint, len(pcm))
-	k := 0
-	for pr, pp := range pcm {
-		out[k] = m.override(pr, pp)
-		k++
-	}
-
-	sort.SliceStable(out, func(i, j int) bool {
-		return out[i].Ident.Less(out[j].Ident)
-	})
-	return
-}
-
 // override replaces a single ProjectConstraint with a workingConstraint,
 // overriding its values if a corresponding entry exists in the
 // ProjectConstraints map.
@@ -0,0 +1,648 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/dep/gps/internal/pb"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// daemonProtocol is the version of the protocol spoken between a source
+// manager daemon and its clients. They refuse to talk to one another unless
+// it matches.
+const daemonProtocol = 1
+
+// daemonDialTimeout is how long a client waits to connect to the daemon
+// before it gives up, and manages the cache itself.
+const daemonDialTimeout = 2 * time.Second
+
+// daemonCall is a call made by a client of the daemon. Calls are answered by
+// a daemonResult with the same Seq, in whatever order they complete.
+type daemonCall struct {
+	Seq  uint64
+	Args daemonArgs
+}
+
+// daemonResult answers the daemonCall with the same Seq.
+type daemonResult struct {
+	Seq   uint64
+	Reply daemonReply
+}
+
+// daemonArgs holds the arguments of every method of the daemon; each method
+// uses those it needs.
+type daemonArgs struct {
+	Method   string
+	Protocol int
+	ID       ProjectIdentifier
+	Version  daemonVersion
+	Revision Revision
+	Path     string // An import path, or a path on disk.
+	Analyzer ProjectAnalyzerInfo
+	Projects []string
+	Lock     *daemonLock
+	Policy   CacheGCPolicy
+	DryRun   bool
+	Replace  bool
+	Signer   SigstoreIdentity
+}
+
+// daemonReply holds the results of every method of the daemon; each method
+// sets those it returns.
+type daemonReply struct {
+	Err        string
+	Released   bool // The error was ErrSourceManagerIsReleased.
+	Cachedir   string
+	Bool       bool
+	Root       ProjectRoot
+	Path       string
+	URLs       []string
+	Versions   []daemonVersion
+	Constraint daemonConstraint
+	Manifest   []pb.ProjectProperties
+	Lock       *daemonLock
+	Tree       daemonTree
+	Operations []SourceOperation
+	GC         CacheGCResult
+	Bundle     BundleImportResult
+}
+
+// daemonVersion is a Version, as sent to and from the daemon. Paired versions
+// carry their revision alongside their unpaired version.
+type daemonVersion struct {
+	C        pb.Constraint
+	Revision Revision
+}
+
+func newDaemonVersion(v Version) daemonVersion {
+	var dv daemonVersion
+	if pv, ok := v.(versionPair); ok {
+		pv.v.copyTo(&dv.C)
+		dv.Revision = pv.r
+	} else {
+		v.copyTo(&dv.C)
+	}
+	return dv
+}
+
+func (dv daemonVersion) version() (Version, error) {
+	if dv.C.Type == pb.Constraint_Revision {
+		return Revision(dv.C.Value), nil
+	}
+	uv, err := unpairedVersionFromCache(&dv.C)
+	if err != nil || dv.Revision == "" {
+		return uv, err
+	}
+	return uv.Pair(dv.Revision), nil
+}
+
+// daemonConstraint is a Constraint, as sent from the daemon.
+type daemonConstraint struct {
+	Any bool
+	C   pb.Constraint
+}
+
+// daemonLock is a Lock, as sent to and from the daemon.
+type daemonLock struct {
+	Digest   []byte
+	Projects []pb.LockedProject
+}
+
+func newDaemonLock(l Lock) *daemonLock {
+	if l == nil {
+		return nil
+	}
+	dl := &daemonLock{Digest: l.InputsDigest()}
+	for _, lp := range l.Projects() {
+		var msg pb.LockedProject
+		lp.copyTo(&msg, new(pb.Constraint))
+		dl.Projects = append(dl.Projects, msg)
+	}
+	return dl
+}
+
+func (dl *daemonLock) lock() (Lock, error) {
+	if dl == nil {
+		return nil, nil
+	}
+	l := safeLock{h: dl.Digest}
+	for i := range dl.Projects {
+		lp, err := lockedProjectFromCache(&dl.Projects[i])
+		if err != nil {
+			return nil, err
+		}
+		l.p = append(l.p, lp)
+	}
+	return l, nil
+}
+
+// daemonTree is a pkgtree.PackageTree, as sent from the daemon. As in the
+// persistent cache, the errors of packages are kept only as their messages.
+type daemonTree struct {
+	ImportRoot string
+	Packages   map[string]daemonPackage
+}
+
+type daemonPackage struct {
+	P   pkgtree.Package
+	Err string
+}
+
+func newDaemonTree(ptree pkgtree.PackageTree) daemonTree {
+	dt := daemonTree{ImportRoot: ptree.ImportRoot, Packages: make(map[string]daemonPackage, len(ptree.Packages))}
+	for ip, poe := range ptree.Packages {
+		if poe.Err != nil {
+			dt.Packages[ip] = daemonPackage{Err: poe.Err.Error()}
+		} else {
+			dt.Packages[ip] = daemonPackage{P: poe.P}
+		}
+	}
+	return dt
+}
+
+func (dt daemonTree) packageTree() pkgtree.PackageTree {
+	ptree := pkgtree.PackageTree{ImportRoot: dt.ImportRoot, Packages: make(map[string]pkgtree.PackageOrErr, len(dt.Packages))}
+	for ip, dp := range dt.Packages {
+		if dp.Err != "" {
+			ptree.Packages[ip] = pkgtree.PackageOrErr{Err: errors.New(dp.Err)}
+		} else {
+			ptree.Packages[ip] = pkgtree.PackageOrErr{P: dp.P}
+		}
+	}
+	return ptree
+}
+
+// ServeSourceManager serves sm, as a source manager daemon, to the clients
+// connecting to l: SourceMgrs created with SourceManagerConfig.Daemon set to
+// the address of l. Clients share the sources, caches and deduced import
+// paths of sm, and the manifests and locks of projects are derived by an, so
+// their analyzer must match it. It returns when accepting a connection fails,
+// such as after l is closed.
+func ServeSourceManager(l net.Listener, sm *SourceMgr, an ProjectAnalyzer) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveDaemonConn(conn, sm, an)
+	}
+}
+
+// serveDaemonConn answers the calls made over conn until it is closed. Calls
+// still running when it is are canceled.
+func serveDaemonConn(conn net.Conn, sm *SourceMgr, an ProjectAnalyzer) {
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var encmu sync.Mutex
+	enc := gob.NewEncoder(conn)
+	dec := gob.NewDecoder(conn)
+	for {
+		var call daemonCall
+		if err := dec.Decode(&call); err != nil {
+			return
+		}
+		go func(call daemonCall) {
+			reply := handleDaemonCall(ctx, sm, an, call.Args)
+			encmu.Lock()
+			defer encmu.Unlock()
+			enc.Encode(daemonResult{Seq: call.Seq, Reply: reply})
+		}(call)
+	}
+}
+
+// handleDaemonCall calls the method of sm named by args, and returns its
+// results.
+func handleDaemonCall(ctx context.Context, sm *SourceMgr, an ProjectAnalyzer, args daemonArgs) daemonReply {
+	var reply daemonReply
+	var err error
+	switch args.Method {
+	case "hello":
+		if args.Protocol != daemonProtocol {
+			err = errors.Errorf("the daemon speaks protocol %d, not %d", daemonProtocol, args.Protocol)
+		}
+		reply.Cachedir = sm.Cachedir()
+	case "SourceExists":
+		reply.Bool, err = sm.SourceExists(args.ID)
+	case "SyncSourceFor":
+		err = sm.SyncSourceFor(args.ID)
+	case "ListVersions":
+		var pvs []PairedVersion
+		pvs, err = sm.ListVersions(args.ID)
+		for _, pv := range pvs {
+			reply.Versions = append(reply.Versions, newDaemonVersion(pv))
+		}
+	case "RevisionPresentIn":
+		reply.Bool, err = sm.RevisionPresentIn(args.ID, args.Revision)
+	case "ListPackages":
+		var v Version
+		var ptree pkgtree.PackageTree
+		if v, err = args.Version.version(); err == nil {
+			ptree, err = sm.ListPackages(args.ID, v)
+			reply.Tree = newDaemonTree(ptree)
+		}
+	case "GetManifestAndLock":
+		if ai := an.Info(); ai != args.Analyzer {
+			err = errors.Errorf("the daemon analyzes projects with %s, not %s", ai, args.Analyzer)
+			break
+		}
+		var v Version
+		var m Manifest
+		var l Lock
+		if v, err = args.Version.version(); err == nil {
+			m, l, err = sm.GetManifestAndLock(args.ID, v, an)
+		}
+		if err == nil {
+			for root, pp := range m.DependencyConstraints() {
+				var msgs projectPropertiesMsgs
+				msgs.copyFrom(root, pp)
+				reply.Manifest = append(reply.Manifest, msgs.pp)
+			}
+			reply.Lock = newDaemonLock(l)
+		}
+	case "ExportProject":
+		var v Version
+		if v, err = args.Version.version(); err == nil {
+			err = sm.ExportProject(ctx, args.ID, v, args.Path)
+		}
+	case "VerifySignature":
+		var v Version
+		if v, err = args.Version.version(); err == nil {
+			err = sm.VerifySignature(ctx, args.ID, v, args.Path)
+		}
+	case "VerifySigstore":
+		var v Version
+		if v, err = args.Version.version(); err == nil {
+			err = sm.VerifySigstore(ctx, args.ID, v, args.Signer)
+		}
+	case "DeduceProjectRoot":
+		reply.Root, err = sm.DeduceProjectRoot(args.Path)
+	case "VanityRepository":
+		reply.Path, reply.Bool, err = sm.VanityRepository(args.Path)
+	case "SourceURLsForPath":
+		var urls []*url.URL
+		urls, err = sm.SourceURLsForPath(args.Path)
+		for _, u := range urls {
+			reply.URLs = append(reply.URLs, u.String())
+		}
+	case "InferConstraint":
+		var c Constraint
+		if c, err = sm.InferConstraint(args.Path, args.ID); err == nil {
+			if IsAny(c) {
+				reply.Constraint.Any = true
+			} else {
+				c.copyTo(&reply.Constraint.C)
+			}
+		}
+	case "RecordLock":
+		sm.recordLock(args.Path, args.Projects)
+	case "Operations":
+		reply.Operations = sm.Operations()
+	case "CollectGarbage":
+		reply.GC, err = sm.CollectGarbage(args.Policy, args.DryRun)
+	case "ExportBundle":
+		reply.Path, err = exportBundleFile(ctx, sm, args.Lock)
+	case "ImportBundle":
+		var f *os.File
+		if f, err = os.Open(args.Path); err == nil {
+			reply.Bundle, err = sm.ImportBundle(f, args.Replace)
+			f.Close()
+		}
+	default:
+		err = errors.Errorf("unknown method %q", args.Method)
+	}
+
+	if err != nil {
+		reply.Err = err.Error()
+		reply.Released = err == ErrSourceManagerIsReleased
+	}
+	return reply
+}
+
+// exportBundleFile exports the bundle of the lock dl to a file in the cache
+// directory, for the client to copy where it was asked to, and returns its
+// path.
+func exportBundleFile(ctx context.Context, sm *SourceMgr, dl *daemonLock) (string, error) {
+	l, err := dl.lock()
+	if err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile(sm.Cachedir(), "bundle")
+	if err != nil {
+		return "", err
+	}
+	err = sm.ExportBundle(ctx, l, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// daemonClient makes calls to a source manager daemon, over a connection that
+// they may share.
+type daemonClient struct {
+	conn  net.Conn
+	encmu sync.Mutex // mutex protecting enc
+	enc   *gob.Encoder
+
+	mu      sync.Mutex // mutex protecting the fields below
+	seq     uint64
+	pending map[uint64]chan daemonReply
+	err     error // why the connection was lost, once it is
+}
+
+// dialDaemon connects to the daemon listening on the unix socket at addr,
+// which must manage the cache directory cachedir.
+func dialDaemon(addr, cachedir string) (*daemonClient, error) {
+	conn, err := net.DialTimeout("unix", addr, daemonDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &daemonClient{
+		conn:    conn,
+		enc:     gob.NewEncoder(conn),
+		pending: make(map[uint64]chan daemonReply),
+	}
+	go c.read()
+
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "hello", Protocol: daemonProtocol})
+	if err == nil && !sameDir(reply.Cachedir, cachedir) {
+		err = errors.Errorf("the daemon manages the cache in %s, not %s", reply.Cachedir, cachedir)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// sameDir reports whether the paths a and b name the same directory.
+func sameDir(a, b string) bool {
+	fa, erra := os.Stat(a)
+	fb, errb := os.Stat(b)
+	if erra != nil || errb != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return os.SameFile(fa, fb)
+}
+
+// read delivers the results read from the connection to the calls awaiting
+// them, until the connection is lost.
+func (c *daemonClient) read() {
+	dec := gob.NewDecoder(c.conn)
+	for {
+		var res daemonResult
+		if err := dec.Decode(&res); err != nil {
+			c.mu.Lock()
+			c.err = err
+			for seq, ch := range c.pending {
+				close(ch)
+				delete(c.pending, seq)
+			}
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Lock()
+		ch := c.pending[res.Seq]
+		delete(c.pending, res.Seq)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- res.Reply
+		}
+	}
+}
+
+// call makes the call described by args, and waits for its results, or for
+// ctx to be done.
+func (c *daemonClient) call(ctx context.Context, args daemonArgs) (daemonReply, error) {
+	ch := make(chan daemonReply, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return daemonReply{}, errors.Wrap(c.err, "lost the connection to the source manager daemon")
+	}
+	c.seq++
+	seq := c.seq
+	c.pending[seq] = ch
+	c.mu.Unlock()
+
+	c.encmu.Lock()
+	err := c.enc.Encode(daemonCall{Seq: seq, Args: args})
+	c.encmu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+		return daemonReply{}, errors.Wrap(err, "failed to call the source manager daemon")
+	}
+
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			c.mu.Lock()
+			err := c.err
+			c.mu.Unlock()
+			return daemonReply{}, errors.Wrap(err, "lost the connection to the source manager daemon")
+		}
+		if reply.Released {
+			return reply, ErrSourceManagerIsReleased
+		}
+		if reply.Err != "" {
+			return reply, errors.New(reply.Err)
+		}
+		return reply, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+		return daemonReply{}, ctx.Err()
+	}
+}
+
+func (c *daemonClient) close() error {
+	return c.conn.Close()
+}
+
+func (c *daemonClient) sourceExists(id ProjectIdentifier) (bool, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "SourceExists", ID: id})
+	return reply.Bool, err
+}
+
+func (c *daemonClient) syncSourceFor(id ProjectIdentifier) error {
+	_, err := c.call(context.TODO(), daemonArgs{Method: "SyncSourceFor", ID: id})
+	return err
+}
+
+func (c *daemonClient) listVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "ListVersions", ID: id})
+	if err != nil {
+		return nil, err
+	}
+	pvs := make([]PairedVersion, 0, len(reply.Versions))
+	for _, dv := range reply.Versions {
+		v, err := dv.version()
+		if err != nil {
+			return nil, err
+		}
+		pv, ok := v.(PairedVersion)
+		if !ok {
+			return nil, errors.Errorf("the daemon listed %s without its revision", v)
+		}
+		pvs = append(pvs, pv)
+	}
+	return pvs, nil
+}
+
+func (c *daemonClient) revisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "RevisionPresentIn", ID: id, Revision: r})
+	return reply.Bool, err
+}
+
+func (c *daemonClient) listPackages(id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "ListPackages", ID: id, Version: newDaemonVersion(v)})
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return reply.Tree.packageTree(), nil
+}
+
+func (c *daemonClient) getManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "GetManifestAndLock", ID: id, Version: newDaemonVersion(v), Analyzer: an.Info()})
+	if err != nil {
+		return nil, nil, err
+	}
+	m := SimpleManifest{Deps: make(ProjectConstraints, len(reply.Manifest))}
+	for i := range reply.Manifest {
+		root, pp, err := propertiesFromCache(&reply.Manifest[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		m.Deps[root] = pp
+	}
+	l, err := reply.Lock.lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, l, nil
+}
+
+func (c *daemonClient) exportProject(ctx context.Context, id ProjectIdentifier, v Version, to string) error {
+	_, err := c.call(ctx, daemonArgs{Method: "ExportProject", ID: id, Version: newDaemonVersion(v), Path: to})
+	return err
+}
+
+func (c *daemonClient) verifySignature(ctx context.Context, id ProjectIdentifier, v Version, keyring string) error {
+	_, err := c.call(ctx, daemonArgs{Method: "VerifySignature", ID: id, Version: newDaemonVersion(v), Path: keyring})
+	return err
+}
+
+func (c *daemonClient) verifySigstore(ctx context.Context, id ProjectIdentifier, v Version, signer SigstoreIdentity) error {
+	_, err := c.call(ctx, daemonArgs{Method: "VerifySigstore", ID: id, Version: newDaemonVersion(v), Signer: signer})
+	return err
+}
+
+func (c *daemonClient) deduceProjectRoot(ip string) (ProjectRoot, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "DeduceProjectRoot", Path: ip})
+	return reply.Root, err
+}
+
+func (c *daemonClient) vanityRepository(ip string) (string, bool, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "VanityRepository", Path: ip})
+	return reply.Path, reply.Bool, err
+}
+
+func (c *daemonClient) sourceURLsForPath(ip string) ([]*url.URL, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "SourceURLsForPath", Path: ip})
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]*url.URL, 0, len(reply.URLs))
+	for _, s := range reply.URLs {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+func (c *daemonClient) inferConstraint(s string, pi ProjectIdentifier) (Constraint, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "InferConstraint", Path: s, ID: pi})
+	if err != nil {
+		return nil, err
+	}
+	if reply.Constraint.Any {
+		return Any(), nil
+	}
+	return constraintFromCache(&reply.Constraint.C)
+}
+
+func (c *daemonClient) recordLock(root string, projects []string) {
+	c.call(context.TODO(), daemonArgs{Method: "RecordLock", Path: root, Projects: projects})
+}
+
+func (c *daemonClient) operations() []SourceOperation {
+	reply, _ := c.call(context.TODO(), daemonArgs{Method: "Operations"})
+	return reply.Operations
+}
+
+func (c *daemonClient) collectGarbage(p CacheGCPolicy, dryRun bool) (CacheGCResult, error) {
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "CollectGarbage", Policy: p, DryRun: dryRun})
+	return reply.GC, err
+}
+
+// exportBundle has the daemon export the bundle of l to a file in the cache
+// directory, and copies it to w.
+func (c *daemonClient) exportBundle(ctx context.Context, l Lock, w io.Writer) error {
+	reply, err := c.call(ctx, daemonArgs{Method: "ExportBundle", Lock: newDaemonLock(l)})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(reply.Path)
+	f, err := os.Open(reply.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// importBundle copies the bundle read from r to a file in the cache
+// directory, and has the daemon import it from there.
+func (c *daemonClient) importBundle(cachedir string, r io.Reader, replace bool) (BundleImportResult, error) {
+	f, err := ioutil.TempFile(cachedir, "bundle")
+	if err != nil {
+		return BundleImportResult{}, err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return BundleImportResult{}, err
+	}
+	reply, err := c.call(context.TODO(), daemonArgs{Method: "ImportBundle", Path: f.Name(), Replace: replace})
+	return reply.Bundle, err
+}
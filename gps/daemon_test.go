@@ -0,0 +1,163 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestDaemonVersionRoundTrip(t *testing.T) {
+	rev := Revision("c2a2d9a4ba62e1db8e4b3e4a7e3bcc2a0c83e5e1")
+	for _, v := range []Version{
+		rev,
+		NewBranch("dev"),
+		newDefaultBranch("master").Pair(rev),
+		NewVersion("v1.2.3").Pair(rev),
+		NewVersion("stable"),
+	} {
+		got, err := newDaemonVersion(v).version()
+		if err != nil {
+			t.Errorf("unexpected error sending %s to the daemon: %s", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("unexpected version after a round trip through the daemon:\n\t(GOT): %#v\n\t(WNT): %#v", got, v)
+		}
+	}
+}
+
+func TestSourceManagerDaemon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := filepath.Join(dir, "lib")
+	for name, data := range map[string]string{
+		"lib.go":     "package lib\n",
+		"sub/sub.go": "package sub\n",
+		".git/HEAD":  "ref: refs/heads/master\n",
+	} {
+		path := filepath.Join(lib, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+	sock := filepath.Join(dir, "dep.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go ServeSourceManager(l, sm, naiveAnalyzer{})
+
+	c, err := NewSourceManager(SourceManagerConfig{
+		Cachedir: sm.Cachedir(),
+		Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
+		Daemon:   sock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Release()
+	if c.daemon == nil {
+		t.Fatal("expected the SourceMgr to use the daemon")
+	}
+
+	id := ProjectIdentifier{ProjectRoot: "example.com/lib", Source: lib}
+	if exists, err := c.SourceExists(id); err != nil || !exists {
+		t.Fatalf("expected the source to exist, got %v, %v", exists, err)
+	}
+	vlist, err := c.ListVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 1 || vlist[0].String() != localBranch {
+		t.Fatalf("expected the single branch %s, got %s", localBranch, vlist)
+	}
+	rev := vlist[0].Revision()
+	if present, err := c.RevisionPresentIn(id, rev); err != nil || !present {
+		t.Errorf("expected %s to be present, got %v, %v", rev, present, err)
+	}
+
+	ptree, err := c.ListPackages(id, vlist[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := ptree.Packages["example.com/lib/sub"]; !has {
+		t.Errorf("expected package example.com/lib/sub, got %v", ptree.Packages)
+	}
+
+	m, lock, err := c.GetManifestAndLock(id, vlist[0], naiveAnalyzer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DependencyConstraints()) != 0 || lock != nil {
+		t.Errorf("expected an empty manifest and no lock, got %v, %v", m, lock)
+	}
+
+	to := filepath.Join(dir, "vendor", "example.com", "lib")
+	if err := c.ExportProject(context.Background(), id, vlist[0], to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "sub", "sub.go")); err != nil {
+		t.Errorf("expected sub/sub.go to be exported: %s", err)
+	}
+
+	root, err := c.DeduceProjectRoot("github.com/foo/bar/baz")
+	if err != nil || root != "github.com/foo/bar" {
+		t.Errorf("expected the root github.com/foo/bar, got %q, %v", root, err)
+	}
+
+	c.RecordLock("/go/src/example.com/app", safeLock{p: []LockedProject{NewLockedProject(id, rev, nil)}})
+	if locks := sm.locks; len(locks["/go/src/example.com/app"]) != 1 {
+		t.Errorf("expected the lock to be recorded by the daemon, got %v", locks)
+	}
+
+	c.Release()
+	if _, err := c.ListVersions(id); err != ErrSourceManagerIsReleased {
+		t.Errorf("expected the released client to refuse calls, got %v", err)
+	}
+	if _, err := sm.ListVersions(id); err != nil {
+		t.Errorf("expected the daemon to outlive its clients, got %v", err)
+	}
+}
+
+func TestSourceManagerDaemonUnreachable(t *testing.T) {
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	sm, err := NewSourceManager(SourceManagerConfig{
+		Cachedir: cpath,
+		Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
+		Daemon:   filepath.Join(cpath, "dep.sock"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+	if sm.daemon != nil {
+		t.Error("expected the SourceMgr to manage the cache itself without a daemon")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -30,6 +31,36 @@ var (
 
 const gopkgUnstableSuffix = "-unstable"
 
+// isTransportScheme reports whether scheme is one of the transports, ssh and
+// https, that may be forced upon projects.
+func isTransportScheme(scheme string) bool {
+	return scheme == "ssh" || scheme == "https"
+}
+
+// protocolSource returns the source of the project at root over the protocol
+// proto, which must be ssh or https.
+func protocolSource(root, proto string) string {
+	if proto == "ssh" {
+		return "ssh://git@" + root
+	}
+	return "https://" + root
+}
+
+// withTransport returns repo, reached over the transport of u instead of its
+// own, as the user of u, or git, for ssh.
+func withTransport(repo, u *url.URL) *url.URL {
+	r := *repo
+	r.Scheme = u.Scheme
+	r.User = nil
+	if u.Scheme == "ssh" {
+		r.User = u.User
+		if r.User == nil {
+			r.User = url.User("git")
+		}
+	}
+	return &r
+}
+
 func validateVCSScheme(scheme, typ string) bool {
 	// everything allows plain ssh
 	if scheme == "ssh" {
@@ -555,10 +586,15 @@ type deducer interface {
 }
 
 type deductionCoordinator struct {
-	suprvsr  *supervisor
-	mut      sync.RWMutex
-	rootxt   *radix.Tree
-	deducext *deducerTrie
+	suprvsr   *supervisor
+	mut       sync.RWMutex
+	rootxt    *radix.Tree
+	deducext  *deducerTrie
+	remote    *remoteConfig
+	meta      *metadataCache
+	mirrors   mirrorList
+	hosts     hostMatchers
+	redirects *redirectLog
 }
 
 func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
@@ -566,6 +602,7 @@ func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
 		suprvsr:  superv,
 		rootxt:   radix.New(),
 		deducext: pathDeducerTrie(),
+		hosts:    builtinHostMatchers,
 	}
 
 	return dc
@@ -604,7 +641,19 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 		panic(fmt.Sprintf("unexpected %T in deductionCoordinator.rootxt: %v", data, data))
 	}
 
-	// No match. Try known path deduction first.
+	// No match. Mirrors come first, as they stand in for both known paths and
+	// go get metadata.
+	if pd, has, err := dc.deduceMirror(path); has || err != nil {
+		if err != nil {
+			return pathDeduction{}, err
+		}
+		dc.mut.Lock()
+		dc.rootxt.Insert(pd.root, pd.mb)
+		dc.mut.Unlock()
+		return pd, nil
+	}
+
+	// Then, try known path deduction.
 	pd, err := dc.deduceKnownPaths(path)
 	if err == nil {
 		// Deduction worked; store it in the rootxt, send on retchan and
@@ -624,8 +673,11 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 	// The err indicates no known path matched. It's still possible that
 	// retrieving go get metadata might do the trick.
 	hmd := &httpMetadataDeducer{
-		basePath: path,
-		suprvsr:  dc.suprvsr,
+		basePath:  path,
+		suprvsr:   dc.suprvsr,
+		remote:    dc.remote,
+		meta:      dc.meta,
+		redirects: dc.redirects,
 		// The vanity deducer will call this func with a completed
 		// pathDeduction if it succeeds in finding one. We process it
 		// back through the action channel to ensure serialized
@@ -647,6 +699,15 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 	return hmd.deduce(ctx, path)
 }
 
+// deduceLocalSource returns the deduction for the local directory dir, named
+// by path.
+func deduceLocalSource(path, dir string, u *url.URL) pathDeduction {
+	return pathDeduction{
+		root: path,
+		mb:   maybeSources{maybeLocalSource{url: u, dir: dir}},
+	}
+}
+
 // pathDeduction represents the results of a successful import path deduction -
 // a root path, plus a maybeSource that can be used to attempt to connect to
 // the source.
@@ -658,12 +719,35 @@ type pathDeduction struct {
 var errNoKnownPathMatch = errors.New("no known path match")
 
 func (dc *deductionCoordinator) deduceKnownPaths(path string) (pathDeduction, error) {
+	// Absolute paths are checked for before normalizing, as those on Windows
+	// do not parse as URLs.
+	if dir, ok := localDirPath(path, nil); ok {
+		return deduceLocalSource(path, dir, &url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}), nil
+	}
+
 	u, path, err := normalizeURI(path)
 	if err != nil {
 		return pathDeduction{}, err
 	}
+	if dir, ok := localDirPath(path, u); ok {
+		return deduceLocalSource(path, dir, u), nil
+	}
 
-	// First, try the root path-based matches
+	// Release archives come first, as they may be hosted under the paths of
+	// repositories, as on github.com.
+	if isArchiveURL(u) {
+		mb, err := deduceArchiveSource(u)
+		if err != nil {
+			return pathDeduction{}, err
+		}
+
+		return pathDeduction{
+			root: path,
+			mb:   mb,
+		}, nil
+	}
+
+	// Next, try the root path-based matches
 	if _, mtch, has := dc.deducext.LongestPrefix(path); has {
 		root, err := mtch.deduceRoot(path)
 		if err != nil {
@@ -680,7 +764,12 @@ func (dc *deductionCoordinator) deduceKnownPaths(path string) (pathDeduction, er
 		}, nil
 	}
 
-	// Next, try the vcs extension-based (infix) matcher
+	// Then, the hosts whose roots are not at the usual depth.
+	if pd, has, err := dc.hosts.deduce(path, u); has || err != nil {
+		return pd, err
+	}
+
+	// Then, try the vcs extension-based (infix) matcher
 	exm := vcsExtensionDeducer{regexp: vcsExtensionRegex}
 	if root, err := exm.deduceRoot(path); err == nil {
 		mb, err := exm.deduceSource(path, u)
@@ -704,6 +793,9 @@ type httpMetadataDeducer struct {
 	basePath   string
 	returnFunc func(pathDeduction)
 	suprvsr    *supervisor
+	remote     *remoteConfig
+	meta       *metadataCache
+	redirects  *redirectLog
 }
 
 func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDeduction, error) {
@@ -718,20 +810,13 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 
 		pd := pathDeduction{}
 
-		// Make the HTTP call to attempt to retrieve go-get metadata
-		var root, vcs, reporoot string
-		err = hmd.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
-			root, vcs, reporoot, err = getMetadata(ctx, path, u.Scheme)
-			if err != nil {
-				err = errors.Wrapf(err, "unable to read metadata")
-			}
-			return err
-		})
+		gi, err := hmd.metadata(ctx, path, u.Scheme)
 		if err != nil {
 			err = errors.Wrapf(err, "unable to deduce repository and source type for %q", opath)
 			hmd.deduceErr = err
 			return
 		}
+		root, vcs, reporoot := gi.Root, gi.VCS, gi.RepoRoot
 		pd.root = root
 
 		// If we got something back at all, then it supersedes the actual input for
@@ -744,7 +829,12 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 		}
 
 		// If the input path specified a scheme, then try to honor it.
-		if u.Scheme != "" && repoURL.Scheme != u.Scheme {
+		if u.Scheme != "" && repoURL.Scheme != u.Scheme && vcs == "git" && isTransportScheme(u.Scheme) && isTransportScheme(repoURL.Scheme) {
+			// Git serves the same repositories over https and ssh, so the
+			// transport the input asked for is forced upon the repository
+			// named by the metadata.
+			repoURL = withTransport(repoURL, u)
+		} else if u.Scheme != "" && repoURL.Scheme != u.Scheme {
 			// If the input scheme was http, but the go-get metadata
 			// nevertheless indicated https should be used for the repo, then
 			// trust the metadata and use https.
@@ -764,6 +854,8 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 			pd.mb = maybeSources{maybeBzrSource{url: repoURL}}
 		case "hg":
 			pd.mb = maybeSources{maybeHgSource{url: repoURL}}
+		case "fossil":
+			pd.mb = maybeSources{maybeFossilSource{url: repoURL}}
 		default:
 			hmd.deduceErr = errors.Errorf("unsupported vcs type %s in go-get metadata from %s", vcs, path)
 			return
@@ -786,6 +878,46 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 	return hmd.deduced, hmd.deduceErr
 }
 
+// metadata returns the go-get metadata for path: its override, if it has
+// one, or its cached metadata, if that is fresh. Otherwise, the metadata is
+// fetched, falling back to the cached metadata, however old, if that fails.
+func (hmd *httpMetadataDeducer) metadata(ctx context.Context, path, scheme string) (GoImport, error) {
+	if gi, has := hmd.meta.override(path); has {
+		return gi, nil
+	}
+	cached, has, fresh := hmd.meta.lookup(path)
+	if fresh {
+		// Warn of the import path having moved, if it has, as fetching its
+		// metadata did when it was cached.
+		hmd.redirects.lookup(cached.Root)
+		return cached, nil
+	}
+
+	// Make the HTTP call to attempt to retrieve go-get metadata
+	var gi GoImport
+	var moved string
+	err := hmd.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
+		var err error
+		gi, moved, err = getMetadata(ctx, path, scheme, hmd.remote)
+		if err != nil {
+			err = errors.Wrapf(err, "unable to read metadata")
+		}
+		return err
+	})
+	if err != nil {
+		if has && ctx.Err() == nil {
+			hmd.meta.logger.Warnf("Using cached go-get metadata for %s, as fetching it failed: %s", path, err)
+			return cached, nil
+		}
+		return GoImport{}, err
+	}
+	if moved != "" {
+		hmd.redirects.record(gi.Root, moved, gi.RepoRoot)
+	}
+	hmd.meta.store(gi)
+	return gi, nil
+}
+
 // normalizeURI takes a path string - which can be a plain import path, or a
 // proper URI, or something SCP-shaped - performs basic validity checks, and
 // returns both a full URL and just the path portion.
@@ -823,70 +955,122 @@ func normalizeURI(p string) (*url.URL, string, error) {
 	return u, newpath, nil
 }
 
-// fetchMetadata fetches the remote metadata for path.
-func fetchMetadata(ctx context.Context, path, scheme string) (rc io.ReadCloser, err error) {
+// fetchMetadata fetches the remote metadata for path, reaching its host as
+// remote says to. If path redirects permanently to another import path, it
+// also returns that import path.
+func fetchMetadata(ctx context.Context, path, scheme string, remote *remoteConfig) (rc io.ReadCloser, moved string, err error) {
 	if scheme == "http" {
-		rc, err = doFetchMetadata(ctx, "http", path)
+		rc, moved, err = doFetchMetadata(ctx, "http", path, remote)
 		return
 	}
 
-	rc, err = doFetchMetadata(ctx, "https", path)
+	rc, moved, err = doFetchMetadata(ctx, "https", path, remote)
 	if err == nil {
 		return
 	}
 
-	rc, err = doFetchMetadata(ctx, "http", path)
+	rc, moved, err = doFetchMetadata(ctx, "http", path, remote)
 	return
 }
 
-func doFetchMetadata(ctx context.Context, scheme, path string) (io.ReadCloser, error) {
+func doFetchMetadata(ctx context.Context, scheme, path string, remote *remoteConfig) (io.ReadCloser, string, error) {
 	url := fmt.Sprintf("%s://%s?go-get=1", scheme, path)
 	switch scheme {
 	case "https", "http":
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
-			return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", url)
+			return nil, "", errors.Wrapf(err, "unable to build HTTP request for URL %q", url)
 		}
+		remote.authorize(req)
 
-		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		resp, err := remote.client().Do(req.WithContext(ctx))
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed HTTP request to URL %q", url)
+			return nil, "", errors.Wrapf(err, "failed HTTP request to URL %q", url)
 		}
 
-		return resp.Body, nil
+		return resp.Body, movedPath(resp, path), nil
 	default:
-		return nil, errors.Errorf("unknown remote protocol scheme: %q", scheme)
+		return nil, "", errors.Errorf("unknown remote protocol scheme: %q", scheme)
 	}
 }
 
+// movedPath returns the import path to which the request for the metadata of
+// path was redirected, if it was only redirected permanently, and elsewhere.
+func movedPath(resp *http.Response, path string) string {
+	if resp.Request == nil || resp.Request.Response == nil {
+		return ""
+	}
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		if code := req.Response.StatusCode; code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			return ""
+		}
+	}
+	moved := strings.TrimSuffix(resp.Request.URL.Host+resp.Request.URL.Path, "/")
+	if moved == path {
+		return ""
+	}
+	return moved
+}
+
 // getMetadata fetches and decodes remote metadata for path.
 //
 // scheme is optional. If it's http, only http will be attempted for fetching.
 // Any other scheme (including none) will first try https, then fall back to
 // http.
-func getMetadata(ctx context.Context, path, scheme string) (string, string, string, error) {
-	rc, err := fetchMetadata(ctx, path, scheme)
+//
+// If path redirects permanently to another import path, and the metadata
+// found there is for that import path, the root of path is taken to be the
+// same distance from path as the root found is from the other import path,
+// and that root is also returned.
+func getMetadata(ctx context.Context, path, scheme string, remote *remoteConfig) (GoImport, string, error) {
+	rc, moved, err := fetchMetadata(ctx, path, scheme, remote)
 	if err != nil {
-		return "", "", "", errors.Wrapf(err, "unable to fetch raw metadata")
+		return GoImport{}, "", errors.Wrapf(err, "unable to fetch raw metadata")
 	}
 	defer rc.Close()
 
 	imports, err := parseMetaGoImports(rc)
 	if err != nil {
-		return "", "", "", errors.Wrapf(err, "unable to parse go-import metadata")
+		return GoImport{}, "", errors.Wrapf(err, "unable to parse go-import metadata")
+	}
+	match, err := matchMetaImport(imports, path)
+	if err != nil {
+		return GoImport{}, "", err
 	}
+	if match != -1 {
+		return GoImport{Root: imports[match].Prefix, VCS: imports[match].VCS, RepoRoot: imports[match].RepoRoot}, "", nil
+	}
+	if moved == "" {
+		return GoImport{}, "", errors.Errorf("go-import metadata not found")
+	}
+
+	match, err = matchMetaImport(imports, moved)
+	if err != nil {
+		return GoImport{}, "", err
+	}
+	if match == -1 {
+		return GoImport{}, "", errors.Errorf("go-import metadata not found for %s, to which %s redirects", moved, path)
+	}
+	rest := strings.TrimPrefix(moved, imports[match].Prefix)
+	if !strings.HasSuffix(path, rest) || strings.TrimSuffix(path, rest) == "" {
+		return GoImport{}, "", errors.Errorf("%s redirects to %s, whose root %s does not correspond to a root of %s", path, moved, imports[match].Prefix, path)
+	}
+	gi := GoImport{Root: strings.TrimSuffix(path, rest), VCS: imports[match].VCS, RepoRoot: imports[match].RepoRoot}
+	return gi, imports[match].Prefix, nil
+}
+
+// matchMetaImport returns the index of the import in imports whose prefix
+// matches path, or -1 if none does.
+func matchMetaImport(imports []metaImport, path string) (int, error) {
 	match := -1
 	for i, im := range imports {
 		if !strings.HasPrefix(path, im.Prefix) {
 			continue
 		}
 		if match != -1 {
-			return "", "", "", errors.Errorf("multiple meta tags match import path %q", path)
+			return -1, errors.Errorf("multiple meta tags match import path %q", path)
 		}
 		match = i
 	}
-	if match == -1 {
-		return "", "", "", errors.Errorf("go-import metadata not found")
-	}
-	return imports[match].Prefix, imports[match].VCS, imports[match].RepoRoot, nil
+	return match, nil
 }
@@ -9,8 +9,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -679,3 +683,68 @@ func TestVanityDeductionSchemeMismatch(t *testing.T) {
 		t.Error("should have errored on scheme mismatch between input and go-get metadata")
 	}
 }
+
+func TestFetchMetadataCredentials(t *testing.T) {
+	var auth string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, "<html></html>")
+	})
+
+	for _, scheme := range []string{"https", "http"} {
+		var srv *httptest.Server
+		if scheme == "https" {
+			srv = httptest.NewTLSServer(handler)
+		} else {
+			srv = httptest.NewServer(handler)
+		}
+
+		client := http.DefaultClient
+		http.DefaultClient = srv.Client()
+
+		host := strings.TrimPrefix(srv.URL, scheme+"://")
+		remote := &remoteConfig{creds: map[string]Credentials{host: {Username: "bot", Password: "s3cret"}}}
+		auth = ""
+		rc, _, err := doFetchMetadata(context.Background(), scheme, host+"/org/repo", remote)
+		if err == nil {
+			ioutil.ReadAll(rc)
+			rc.Close()
+		}
+		http.DefaultClient = client
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := ""
+		if scheme == "https" {
+			want = "Basic Ym90OnMzY3JldA=="
+		}
+		if auth != want {
+			t.Errorf("unexpected Authorization header over %s:\n\t(GOT): %q\n\t(WNT): %q", scheme, auth, want)
+		}
+	}
+}
+
+func TestVanityDeductionForcedTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<meta name="go-import" content="%s/lib git https://git.example.com/lib">`, r.Host)
+	}))
+	defer ts.Close()
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+	ctx := context.Background()
+	hmd := &httpMetadataDeducer{
+		basePath:   host + "/lib",
+		suprvsr:    newSupervisor(ctx),
+		returnFunc: func(pathDeduction) {},
+	}
+	pd, err := hmd.deduce(ctx, "ssh://git@"+host+"/lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := maybeGitSource{url: mkurl("ssh://git@git.example.com/lib")}
+	if len(pd.mb) != 1 || pd.mb[0].String() != want.String() {
+		t.Errorf("unexpected sources:\n\t(GOT): %s\n\t(WNT): %s", pd.mb, want)
+	}
+}
@@ -9,13 +9,13 @@ package main
 import (
 	"go/build"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/logging"
 )
 
 // This is probably the simplest possible implementation of gps. It does the
@@ -36,7 +36,7 @@ func main() {
 	// Set up params, including tracing
 	params := gps.SolveParameters{
 		RootDir:         root,
-		TraceLogger:     log.New(os.Stdout, "", 0),
+		TraceLogger:     logging.New(os.Stdout, logging.Options{Level: logging.Debug}),
 		ProjectAnalyzer: NaiveAnalyzer{},
 	}
 	// Perform static analysis on the current project to find all of its imports.
@@ -7,8 +7,13 @@ package gps
 import (
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/golang/dep/internal/events"
+	"github.com/golang/dep/internal/fs"
+	depmetrics "github.com/golang/dep/internal/metrics"
 	"github.com/pkg/errors"
 )
 
@@ -26,7 +31,9 @@ type fsLink struct {
 	broken bool
 }
 
-// filesystemState represents the state of a file system.
+// filesystemState represents the state of a file system. When pruning, it is
+// derived once, and kept up to date as each pass removes nodes, so that later
+// passes neither act on nor stat what earlier ones removed.
 type filesystemState struct {
 	root  string
 	dirs  []string
@@ -34,6 +41,38 @@ type filesystemState struct {
 	links []fsLink
 }
 
+// forget drops the nodes at the relative paths in gone from s, along with
+// everything beneath those of them that are directories.
+func (s *filesystemState) forget(gone map[string]bool) {
+	if len(gone) == 0 {
+		return
+	}
+
+	dirs := s.dirs[:0]
+	for _, dir := range s.dirs {
+		if !s.isGone(gone, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	s.dirs = dirs
+
+	files := s.files[:0]
+	for _, file := range s.files {
+		if !s.isGone(gone, file) {
+			files = append(files, file)
+		}
+	}
+	s.files = files
+
+	links := s.links[:0]
+	for _, link := range s.links {
+		if !s.isGone(gone, link.path) {
+			links = append(links, link)
+		}
+	}
+	s.links = links
+}
+
 func (s filesystemState) setup() error {
 	for _, dir := range s.dirs {
 		p := filepath.Join(s.root, dir)
@@ -82,20 +121,19 @@ func (s filesystemState) setup() error {
 }
 
 // deriveFilesystemState returns a filesystemState based on the state of
-// the filesystem on root.
+// the filesystem on root. The tree is walked from several goroutines at once,
+// as fs.Walk does, but its nodes are listed in the order filepath.Walk would
+// visit them in.
 func deriveFilesystemState(root string) (filesystemState, error) {
-	fs := filesystemState{root: root}
+	state := filesystemState{root: root}
 
-	err := filepath.Walk(fs.root, func(path string, info os.FileInfo, err error) error {
+	var mu sync.Mutex
+	err := fs.Walk(state.root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if path == fs.root {
-			return nil
-		}
-
-		relPath, err := filepath.Rel(fs.root, path)
+		relPath, err := filepath.Rel(state.root, path)
 		if err != nil {
 			return err
 		}
@@ -112,18 +150,20 @@ func deriveFilesystemState(root string) (filesystemState, error) {
 				return err
 			}
 
-			fs.links = append(fs.links, l)
+			mu.Lock()
+			state.links = append(state.links, l)
+			mu.Unlock()
 
 			return nil
 		}
 
+		mu.Lock()
 		if info.IsDir() {
-			fs.dirs = append(fs.dirs, relPath)
-
-			return nil
+			state.dirs = append(state.dirs, relPath)
+		} else {
+			state.files = append(state.files, relPath)
 		}
-
-		fs.files = append(fs.files, relPath)
+		mu.Unlock()
 
 		return nil
 	})
@@ -132,5 +172,120 @@ func deriveFilesystemState(root string) (filesystemState, error) {
 		return filesystemState{}, err
 	}
 
-	return fs, nil
+	sort.Slice(state.dirs, func(i, j int) bool { return fs.WalkOrderLess(state.dirs[i], state.dirs[j]) })
+	sort.Slice(state.files, func(i, j int) bool { return fs.WalkOrderLess(state.files[i], state.files[j]) })
+	sort.Slice(state.links, func(i, j int) bool { return fs.WalkOrderLess(state.links[i].path, state.links[j].path) })
+
+	return state, nil
+}
+
+// isGone reports whether the node at the relative path, or any of the
+// directories it is beneath, is in gone.
+func (s *filesystemState) isGone(gone map[string]bool, path string) bool {
+	for {
+		if gone[path] {
+			return true
+		}
+		parent := filepath.Dir(path)
+		if parent == "." || parent == path {
+			return false
+		}
+		path = parent
+	}
+}
+
+// emitPruned emits a FilePruned event for each of the files at the relative
+// paths that is gone.
+func (s *filesystemState) emitPruned(paths []string, gone map[string]bool) {
+	if !events.Enabled() {
+		return
+	}
+	for _, path := range paths {
+		if s.isGone(gone, path) {
+			events.Emit(events.Event{Type: events.FilePruned, Path: filepath.Join(s.root, path)})
+		}
+	}
+}
+
+// removeFiles removes the files at the relative paths from disk, and from s,
+// counting those it removed as pruned. Files that are already gone are
+// skipped.
+//
+// Directories of s all of whose files are among paths are removed whole,
+// rather than file by file, as are the directories beneath them; those empty
+// directories would be removed after pruning in any case. The rest of the
+// files are removed in the order they were walked in.
+func (s *filesystemState) removeFiles(paths []string) error {
+	var removed int64
+	defer func() { depmetrics.Add(depmetrics.FilesPruned, removed) }()
+
+	gone := make(map[string]bool, len(paths))
+	defer s.forget(gone)
+	defer s.emitPruned(paths, gone)
+
+	removing := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		removing[path] = true
+	}
+
+	// How many files and links each directory holds, however deep, and how
+	// many of those files are being removed.
+	held := make(map[string]int, len(s.dirs))
+	toRemove := make(map[string]int, len(s.dirs))
+	count := func(path string, remove bool) {
+		for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+			held[dir]++
+			if remove {
+				toRemove[dir]++
+			}
+		}
+	}
+	for _, file := range s.files {
+		count(file, removing[file])
+	}
+	for _, link := range s.links {
+		count(link.path, false)
+	}
+
+	// s.dirs is in walk order, so directories come before those beneath them.
+	whole := make(map[string]bool)
+	var wholeDirs []string
+	for _, dir := range s.dirs {
+		if held[dir] > 0 && toRemove[dir] == held[dir] && !s.isGone(whole, dir) {
+			whole[dir] = true
+			wholeDirs = append(wholeDirs, dir)
+		}
+	}
+
+	err := fs.ForEach(len(wholeDirs), func(i int) error {
+		return fs.RemoveAll(filepath.Join(s.root, wholeDirs[i]))
+	})
+	if err != nil {
+		return err
+	}
+	for _, dir := range wholeDirs {
+		gone[dir] = true
+		removed += int64(toRemove[dir])
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !s.isGone(whole, path) {
+			sorted = append(sorted, path)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return fs.WalkOrderLess(sorted[i], sorted[j]) })
+
+	for _, path := range sorted {
+		if err := os.Remove(filepath.Join(s.root, path)); err != nil {
+			if os.IsNotExist(err) {
+				gone[path] = true
+				continue
+			}
+			return err
+		}
+		gone[path] = true
+		removed++
+	}
+	return nil
 }
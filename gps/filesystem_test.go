@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/golang/dep/internal/test"
@@ -90,6 +91,41 @@ func (tc fsTestCase) assert(t *testing.T) {
 	}
 }
 
+// assertState makes sure that got, a state kept up to date while pruning,
+// lists the same nodes as tc.after.
+func (tc fsTestCase) assertState(t *testing.T, got filesystemState) {
+	var gotLinks, wantLinks []string
+	for _, l := range got.links {
+		gotLinks = append(gotLinks, l.path)
+	}
+	for _, l := range tc.after.links {
+		wantLinks = append(wantLinks, l.path)
+	}
+
+	for _, nodes := range []struct {
+		kind      string
+		got, want []string
+	}{
+		{"directories", got.dirs, tc.after.dirs},
+		{"files", got.files, tc.after.files},
+		{"symlinks", gotLinks, wantLinks},
+	} {
+		if len(nodes.got) == 0 && len(nodes.want) == 0 {
+			continue
+		}
+		want := make([]string, len(nodes.want))
+		for i, path := range nodes.want {
+			want[i] = filepath.FromSlash(path)
+		}
+		sort.Strings(want)
+		gotSorted := append([]string(nil), nodes.got...)
+		sort.Strings(gotSorted)
+		if !reflect.DeepEqual(gotSorted, want) {
+			t.Errorf("expected the state to list the %s %v, got %v", nodes.kind, want, gotSorted)
+		}
+	}
+}
+
 // setup inflates fs onto the actual host file system at tc.before.root.
 // It doesn't delete existing files and should be used on empty roots only.
 func (tc fsTestCase) setup(t *testing.T) {
@@ -0,0 +1,141 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/golang/dep/internal/fs"
+)
+
+// revisionArchiver is an optional extension of source, for sources that can
+// export a revision from an archive of it downloaded from their host, without
+// retrieving the rest of the source.
+type revisionArchiver interface {
+	// exportRevisionArchive exports r to to, as exportRevisionTo does, and
+	// returns true, if the source's host serves an archive of r from which
+	// the same tree can be exported. Otherwise, it leaves to as it was.
+	exportRevisionArchive(ctx context.Context, r Revision, to string) bool
+}
+
+// exportRevisionArchive exports rev from a tarball of it downloaded through the
+// API of the repository's host. Trees that a tarball cannot reproduce exactly,
+// those with submodules, symlinks, or files that .gitattributes leaves out of
+// archives or rewrites in them, are left to be exported with git.
+func (s *gitSource) exportRevisionArchive(ctx context.Context, rev Revision, to string) bool {
+	gr, ok := s.repo.(*gitRepo)
+	if !ok || !gitHashRE.MatchString(string(rev)) {
+		return false
+	}
+	remote := gr.Remote()
+	if gr.moved != "" {
+		remote = gr.moved
+	}
+	api, base, host, has := gitHostAPIFor(remote)
+	if !has {
+		return false
+	}
+
+	f, err := ioutil.TempFile("", "dep-git-archive")
+	if err != nil {
+		return false
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	resp, err := doAPI(ctx, gr.remote, host, base+fmt.Sprintf(api.archive, rev))
+	if err != nil {
+		return false
+	}
+	_, err = io.Copy(f, resp.Body)
+	resp.Body.Close()
+	if err != nil || !gitArchiveExportable(f) {
+		return false
+	}
+
+	// Extract beside to, so that the tree can be renamed into place.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return false
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(to), ".dep-git-archive")
+	if err != nil {
+		return false
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractArchive(f, "tar.gz", tmp); err != nil {
+		return false
+	}
+	root, err := archiveRoot(tmp)
+	if err != nil {
+		return false
+	}
+
+	// to may have been created empty, as exportRevisionTo creates it.
+	if err := os.Remove(to); err != nil && !os.IsNotExist(err) {
+		return false
+	}
+	return fs.RenameWithFallback(root, to) == nil
+}
+
+// gitArchiveExportable reports whether the gzipped tarball in f, of a git
+// revision, holds the same tree as a checkout of the revision would.
+func gitArchiveExportable(f *os.File) bool {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return false
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+		default:
+			return false
+		}
+
+		switch path.Base(hdr.Name) {
+		case ".gitmodules":
+			return false
+		case ".gitattributes":
+			data, err := ioutil.ReadAll(tr)
+			if err != nil || bytes.Contains(data, []byte("export-ignore")) || bytes.Contains(data, []byte("export-subst")) {
+				return false
+			}
+		}
+	}
+}
+
+// revisionArchivable returns the revision of v, if v names one exactly.
+func revisionArchivable(v Version) (Revision, bool) {
+	switch tv := v.(type) {
+	case Revision:
+		return tv, true
+	case PairedVersion:
+		return tv.Revision(), true
+	}
+	return "", false
+}
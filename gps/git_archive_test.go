@@ -0,0 +1,93 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitArchiveExportable(t *testing.T) {
+	cases := []struct {
+		files map[string]string
+		ok    bool
+	}{
+		{map[string]string{"lib.go": "package lib\n", ".gitattributes": "*.go text\n"}, true},
+		{map[string]string{"lib.go": "package lib\n", ".gitmodules": "[submodule \"x\"]\n"}, false},
+		{map[string]string{"lib.go": "package lib\n", "link.go": "->lib.go"}, false},
+		{map[string]string{"lib.go": "package lib\n", "sub/.gitattributes": "testdata export-ignore\n"}, false},
+	}
+	for _, c := range cases {
+		f, err := ioutil.TempFile("", "tarball")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(mkTarGz(t, "owner-repo-abc/", c.files))
+		if ok := gitArchiveExportable(f); ok != c.ok {
+			t.Errorf("gitArchiveExportable(%v) = %v, want %v", c.files, ok, c.ok)
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func TestGitSourceExportRevisionArchive(t *testing.T) {
+	const rev = Revision("30605f6ac35fcb075ad0bfa9296f90a7d891523e")
+	tarball := mkTarGz(t, "owner-repo-abc/", map[string]string{"lib.go": "package lib\n", "sub/sub.go": "package sub\n"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/tarball/"+string(rev) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(tarball)
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	gitHostAPIs[host] = gitHostAPI{
+		project: func(path string) string { return srv.URL + "/repos/" + path },
+		archive: "/tarball/%s",
+	}
+	defer delete(gitHostAPIs, host)
+
+	dir, err := ioutil.TempDir("", "gitarchive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	u, _ := url.Parse(srv.URL + "/owner/repo")
+	src, err := maybeGitSource{url: u}.try(context.Background(), filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ra := src.(revisionArchiver)
+
+	to := filepath.Join(dir, "vendor", "example.com", "repo")
+	if !ra.exportRevisionArchive(context.Background(), rev, to) {
+		t.Fatal("expected the revision to be exported from its archive")
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(to, "sub", "sub.go")); err != nil || string(data) != "package sub\n" {
+		t.Errorf("unexpected contents of sub/sub.go: %q, %v", data, err)
+	}
+	if src.existsLocally(context.Background()) {
+		t.Error("expected the source not to be retrieved")
+	}
+
+	other := filepath.Join(dir, "vendor", "example.com", "other")
+	if ra.exportRevisionArchive(context.Background(), "4a54f5a6e7bcf6fc5b3d8a0b1b8d2e2c6c9e1f00", other) {
+		t.Error("expected a revision the host has no archive of not to be exported")
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be left at %s, got %v", other, err)
+	}
+}
@@ -20,6 +20,9 @@ const (
 	hhIgnores     = "-IGNORES-"
 	hhOverrides   = "-OVERRIDES-"
 	hhAnalyzer    = "-ANALYZER-"
+	hhSources     = "-SOURCES-"
+	hhProtocols   = "-PROTOCOLS-"
+	hhBanned      = "-BANNED-"
 )
 
 // HashInputs computes a hash digest of all data in SolveParams and the
@@ -105,6 +108,38 @@ func (s *solver) writeHashingInputs(w io.Writer) {
 		}
 	}
 
+	// Source prefixes change where projects come from, so they are inputs,
+	// too. They are only written out when present, so that hashes of inputs
+	// without them remain stable.
+	if s.rd.srcs != nil {
+		writeString(hhSources)
+		s.rd.srcs.Walk(func(pre string, src interface{}) bool {
+			writeString(pre)
+			writeString(src.(string))
+			return false
+		})
+	}
+
+	// As are the protocols forced upon projects.
+	if s.rd.protos != nil {
+		writeString(hhProtocols)
+		s.rd.protos.Walk(func(pre string, proto interface{}) bool {
+			writeString(pre)
+			writeString(proto.(string))
+			return false
+		})
+	}
+
+	// And the projects kept out of solutions. Reasons are not, as they have
+	// no bearing on solutions.
+	if s.rd.banned != nil {
+		writeString(hhBanned)
+		s.rd.banned.Walk(func(pre string, _ interface{}) bool {
+			writeString(pre)
+			return false
+		})
+	}
+
 	writeString(hhAnalyzer)
 	ai := s.rd.an.Info()
 	writeString(ai.Name)
@@ -684,3 +684,201 @@ func TestHashInputsIneffectualWildcardIgs(t *testing.T) {
 		})
 	}
 }
+
+// sourceMappedManifest is a root manifest that also declares source prefixes.
+type sourceMappedManifest struct {
+	simpleRootManifest
+	srcs map[string]string
+}
+
+func (m sourceMappedManifest) SourceMap() map[string]string {
+	return m.srcs
+}
+
+func TestHashInputsSources(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+
+	rm := sourceMappedManifest{
+		simpleRootManifest: fix.rootmanifest().(simpleRootManifest).dup(),
+		srcs: map[string]string{
+			"b": "mirror/b",
+			"a": "mirror/a",
+		},
+	}
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        rm,
+		ProjectAnalyzer: naiveAnalyzer{},
+		stdLibFn:        func(string) bool { return false },
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+
+	dig := s.HashInputs()
+	h := sha256.New()
+
+	elems := []string{
+		hhConstraints,
+		"a",
+		"mirror/a",
+		"sv-1.0.0",
+		"b",
+		"mirror/b",
+		"sv-1.0.0",
+		hhImportsReqs,
+		"a",
+		"b",
+		hhIgnores,
+		hhOverrides,
+		hhSources,
+		"a",
+		"mirror/a",
+		"b",
+		"mirror/b",
+		hhAnalyzer,
+		"naive-analyzer",
+		"1",
+	}
+	for _, v := range elems {
+		h.Write([]byte(v))
+	}
+	correct := h.Sum(nil)
+
+	if !bytes.Equal(dig, correct) {
+		t.Errorf("Hashes are not equal. Inputs:\n%s", diffHashingInputs(s, elems))
+	}
+}
+
+// protocolMappedManifest is a root manifest that also declares protocols.
+type protocolMappedManifest struct {
+	simpleRootManifest
+	protos map[string]string
+}
+
+func (m protocolMappedManifest) ProtocolMap() map[string]string {
+	return m.protos
+}
+
+func TestHashInputsProtocols(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+
+	rm := protocolMappedManifest{
+		simpleRootManifest: fix.rootmanifest().(simpleRootManifest).dup(),
+		protos: map[string]string{
+			"a": "ssh",
+		},
+	}
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        rm,
+		ProjectAnalyzer: naiveAnalyzer{},
+		stdLibFn:        func(string) bool { return false },
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+
+	dig := s.HashInputs()
+	h := sha256.New()
+
+	elems := []string{
+		hhConstraints,
+		"a",
+		"ssh://git@a",
+		"sv-1.0.0",
+		"b",
+		"sv-1.0.0",
+		hhImportsReqs,
+		"a",
+		"b",
+		hhIgnores,
+		hhOverrides,
+		hhProtocols,
+		"a",
+		"ssh",
+		hhAnalyzer,
+		"naive-analyzer",
+		"1",
+	}
+	for _, v := range elems {
+		h.Write([]byte(v))
+	}
+	correct := h.Sum(nil)
+
+	if !bytes.Equal(dig, correct) {
+		t.Errorf("Hashes are not equal. Inputs:\n%s", diffHashingInputs(s, elems))
+	}
+
+	rm.protos["b"] = "git"
+	params.Manifest = rm
+	if _, err := Prepare(params, newdepspecSM(fix.ds, nil)); err == nil {
+		t.Error("expected a protocol other than ssh or https to be rejected")
+	}
+}
+
+func TestHashInputsBanned(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+
+	rm := bannedManifest{
+		simpleRootManifest: fix.rootmanifest().(simpleRootManifest).dup(),
+		banned: map[string]string{
+			"c":   "",
+			"b/x": "deprecated",
+		},
+	}
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        rm,
+		ProjectAnalyzer: naiveAnalyzer{},
+		stdLibFn:        func(string) bool { return false },
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+
+	dig := s.HashInputs()
+	h := sha256.New()
+
+	elems := []string{
+		hhConstraints,
+		"a",
+		"sv-1.0.0",
+		"b",
+		"sv-1.0.0",
+		hhImportsReqs,
+		"a",
+		"b",
+		hhIgnores,
+		hhOverrides,
+		hhBanned,
+		"b/x",
+		"c",
+		hhAnalyzer,
+		"naive-analyzer",
+		"1",
+	}
+	for _, v := range elems {
+		h.Write([]byte(v))
+	}
+	correct := h.Sum(nil)
+
+	if !bytes.Equal(dig, correct) {
+		t.Errorf("Hashes are not equal. Inputs:\n%s", diffHashingInputs(s, elems))
+	}
+}
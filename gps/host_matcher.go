@@ -0,0 +1,134 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A HostMatcher deduces the roots and sources of the import paths on a host
+// whose repositories are not found at the host/owner/repo paths go get
+// assumes, such as Azure DevOps, whose import paths name an organization, a
+// project and a repository, as in dev.azure.com/org/project/_git/repo.
+type HostMatcher struct {
+	// Host is the host whose import paths are matched, or a domain, starting
+	// with a dot, whose subdomains' import paths are.
+	Host string
+
+	// Root matches the roots of the import paths on Host. It is matched
+	// against the start of each import path, and must end at the end of a
+	// path element. Import paths it does not match are deduced as they would
+	// be without it.
+	Root *regexp.Regexp
+
+	// Sources are the URLs of the git repositories from which the projects
+	// are fetched, in order of preference. Each ${name}, or ${n}, in them is
+	// replaced by the submatch of Root with that name, or number.
+	Sources []string
+}
+
+// builtinHostMatchers are the HostMatchers of the well-known hosts that need
+// them.
+var builtinHostMatchers = []HostMatcher{
+	{
+		Host: "dev.azure.com",
+		Root: regexp.MustCompile(`^dev\.azure\.com/(?P<org>[A-Za-z0-9_.\-]+)/(?P<project>[A-Za-z0-9_.\-%]+)/_git/(?P<repo>[A-Za-z0-9_.\-%]+)`),
+		Sources: []string{
+			"https://dev.azure.com/${org}/${project}/_git/${repo}",
+			"ssh://git@ssh.dev.azure.com/v3/${org}/${project}/${repo}",
+		},
+	},
+	{
+		Host: ".visualstudio.com",
+		Root: regexp.MustCompile(`^(?P<org>[A-Za-z0-9\-]+)\.visualstudio\.com/(?:DefaultCollection/)?(?P<project>[A-Za-z0-9_.\-%]+)/_git/(?P<repo>[A-Za-z0-9_.\-%]+)`),
+		Sources: []string{
+			"https://${org}.visualstudio.com/${project}/_git/${repo}",
+			"ssh://${org}@vs-ssh.visualstudio.com/v3/${org}/${project}/${repo}",
+		},
+	},
+	{
+		Host: ".amazonaws.com",
+		Root: regexp.MustCompile(`^git-codecommit\.(?P<region>[a-z0-9\-]+)\.amazonaws\.com/v1/repos/(?P<repo>[A-Za-z0-9_.\-]+)`),
+		Sources: []string{
+			"https://git-codecommit.${region}.amazonaws.com/v1/repos/${repo}",
+			"ssh://git-codecommit.${region}.amazonaws.com/v1/repos/${repo}",
+		},
+	},
+}
+
+// hostMatchers holds HostMatchers, those matching hosts exactly first, and
+// then those matching domains, the longest first.
+type hostMatchers []HostMatcher
+
+// newHostMatchers returns the HostMatchers hm, as
+// SourceManagerConfig.HostMatchers, followed by the built-in ones, which they
+// take the place of for the same hosts.
+func newHostMatchers(hm []HostMatcher) (hostMatchers, error) {
+	for _, m := range hm {
+		if m.Host == "" || strings.Contains(m.Host, "/") {
+			return nil, errors.Errorf("host matcher for %q must name a host or a domain", m.Host)
+		}
+		if m.Root == nil {
+			return nil, errors.Errorf("host matcher for %s has no root pattern", m.Host)
+		}
+		if len(m.Sources) == 0 {
+			return nil, errors.Errorf("host matcher for %s has no sources", m.Host)
+		}
+	}
+
+	hms := append(append(hostMatchers(nil), hm...), builtinHostMatchers...)
+	sort.SliceStable(hms, func(i, j int) bool {
+		di, dj := strings.HasPrefix(hms[i].Host, "."), strings.HasPrefix(hms[j].Host, ".")
+		if di != dj {
+			return dj
+		}
+		return di && len(hms[i].Host) > len(hms[j].Host)
+	})
+	return hms, nil
+}
+
+// deduce returns the deduction for path, whose URL is u, if one of hms matches
+// it.
+func (hms hostMatchers) deduce(path string, u *url.URL) (pathDeduction, bool, error) {
+	host := path
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		host = path[:i]
+	}
+
+	for _, m := range hms {
+		if host != m.Host && !(strings.HasPrefix(m.Host, ".") && strings.HasSuffix(host, m.Host)) {
+			continue
+		}
+		loc := m.Root.FindStringSubmatchIndex(path)
+		if loc == nil || loc[0] != 0 || (loc[1] != len(path) && path[loc[1]] != '/') {
+			continue
+		}
+		root := path[:loc[1]]
+
+		var mb maybeSources
+		for _, tmpl := range m.Sources {
+			src := string(m.Root.ExpandString(nil, tmpl, path, loc))
+			su, _, err := normalizeURI(src)
+			if err != nil {
+				return pathDeduction{}, false, errors.Wrapf(err, "invalid source for %s", root)
+			}
+			// An import path given with a scheme is only fetched with it.
+			if u.Scheme != "" && su.Scheme != u.Scheme {
+				continue
+			}
+			mb = append(mb, maybeGitSource{url: su})
+		}
+		if len(mb) == 0 {
+			return pathDeduction{}, false, errors.Errorf("%s is not a valid scheme for accessing %s", u.Scheme, root)
+		}
+		return pathDeduction{root: root, mb: mb}, true, nil
+	}
+	return pathDeduction{}, false, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewHostMatchersErrors(t *testing.T) {
+	root := regexp.MustCompile(`^git\.example\.com/[^/]+/[^/]+/[^/]+`)
+	cases := map[string]HostMatcher{
+		"must name a host":    {Host: "git.example.com/a", Root: root, Sources: []string{"https://${0}"}},
+		"has no root pattern": {Host: "git.example.com", Sources: []string{"https://${0}"}},
+		"has no sources":      {Host: "git.example.com", Root: root},
+	}
+	for want, m := range cases {
+		if _, err := newHostMatchers([]HostMatcher{m}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("unexpected error for %+v:\n\t(GOT): %v\n\t(WNT): %s", m, err, want)
+		}
+	}
+}
+
+func TestDeduceHostMatchers(t *testing.T) {
+	hms, err := newHostMatchers([]HostMatcher{
+		{
+			Host:    ".corp.example.com",
+			Root:    regexp.MustCompile(`^(?P<host>[a-z]+\.corp\.example\.com)/(?P<group>[^/]+)/(?P<sub>[^/]+)/(?P<repo>[^/]+)`),
+			Sources: []string{"https://${host}/${group}/${sub}/${repo}.git"},
+		},
+		{
+			Host:    "git-codecommit.us-east-1.amazonaws.com",
+			Root:    regexp.MustCompile(`^git-codecommit\.us-east-1\.amazonaws\.com/v1/repos/(?P<repo>[^/]+)`),
+			Sources: []string{"ssh://APKAEXAMPLE@git-codecommit.us-east-1.amazonaws.com/v1/repos/${repo}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := newDeductionCoordinator(newSupervisor(context.Background()))
+	dc.hosts = hms
+
+	cases := []struct {
+		in, root string
+		urls     []string
+	}{
+		{
+			"dev.azure.com/org/project/_git/repo/pkg/sub",
+			"dev.azure.com/org/project/_git/repo",
+			[]string{"https://dev.azure.com/org/project/_git/repo", "ssh://git@ssh.dev.azure.com/v3/org/project/repo"},
+		},
+		{
+			"https://dev.azure.com/org/project/_git/repo",
+			"dev.azure.com/org/project/_git/repo",
+			[]string{"https://dev.azure.com/org/project/_git/repo"},
+		},
+		{
+			"org.visualstudio.com/DefaultCollection/project/_git/repo",
+			"org.visualstudio.com/DefaultCollection/project/_git/repo",
+			[]string{"https://org.visualstudio.com/project/_git/repo", "ssh://org@vs-ssh.visualstudio.com/v3/org/project/repo"},
+		},
+		{
+			"git-codecommit.eu-west-1.amazonaws.com/v1/repos/lib/pkg",
+			"git-codecommit.eu-west-1.amazonaws.com/v1/repos/lib",
+			[]string{"https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/lib", "ssh://git-codecommit.eu-west-1.amazonaws.com/v1/repos/lib"},
+		},
+		{
+			"git-codecommit.us-east-1.amazonaws.com/v1/repos/lib",
+			"git-codecommit.us-east-1.amazonaws.com/v1/repos/lib",
+			[]string{"ssh://APKAEXAMPLE@git-codecommit.us-east-1.amazonaws.com/v1/repos/lib"},
+		},
+		{
+			"code.corp.example.com/team/services/api/cmd/api",
+			"code.corp.example.com/team/services/api",
+			[]string{"https://code.corp.example.com/team/services/api.git"},
+		},
+	}
+	for _, c := range cases {
+		pd, err := dc.deduceKnownPaths(c.in)
+		if err != nil {
+			t.Errorf("unexpected error deducing %s: %s", c.in, err)
+			continue
+		}
+		var urls []string
+		for _, mb := range pd.mb {
+			urls = append(urls, mb.(maybeGitSource).url.String())
+		}
+		if pd.root != c.root || !reflect.DeepEqual(urls, c.urls) {
+			t.Errorf("unexpected deduction for %s:\n\t(GOT): %s %s\n\t(WNT): %s %s", c.in, pd.root, urls, c.root, c.urls)
+		}
+	}
+
+	// Import paths the matchers do not match are left to other deduction.
+	for _, path := range []string{"dev.azure.com/org/project", "s3.amazonaws.com/bucket/key", "code.corp.example.com/team"} {
+		if _, err := dc.deduceKnownPaths(path); err != errNoKnownPathMatch {
+			t.Errorf("expected %s not to be matched, got %v", path, err)
+		}
+	}
+
+	if _, err := dc.deduceKnownPaths("git://dev.azure.com/org/project/_git/repo"); err == nil {
+		t.Error("expected an error for a scheme none of the sources use")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"sync"
+
+	"github.com/Masterminds/semver"
+)
+
+// stringInterner deduplicates equal strings, so that the many copies of the
+// same import paths and package names held in the package trees of different
+// revisions of a project share a single backing array. A nil *stringInterner
+// returns strings as they are.
+type stringInterner struct {
+	mu   sync.Mutex
+	strs map[string]string
+}
+
+func newStringInterner() *stringInterner {
+	return &stringInterner{strs: make(map[string]string)}
+}
+
+// intern returns a string equal to s, which is the same string for all equal
+// strings passed to in.
+func (in *stringInterner) intern(s string) string {
+	if in == nil || s == "" {
+		return s
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if is, has := in.strs[s]; has {
+		return is
+	}
+	in.strs[s] = s
+	return s
+}
+
+// internAll interns each of the strings in ss, in place.
+func (in *stringInterner) internAll(ss []string) {
+	if in == nil {
+		return
+	}
+	for i, s := range ss {
+		ss[i] = in.intern(s)
+	}
+}
+
+// semverPool holds the semVersions made by NewVersion, keyed by the string
+// they were made from. The same versions are named over and over by the tags
+// of sources, and by the manifests and locks of their revisions, so rather
+// than each being boxed anew for every mention, all share the same value.
+//
+// Like the version cache in the semver package, which NewVersion parses
+// through, it is never emptied; it only grows with the number of distinct
+// versions that are named.
+var semverPool = struct {
+	sync.RWMutex
+	m map[string]UnpairedVersion
+}{m: make(map[string]UnpairedVersion)}
+
+// pooledSemver returns the semVersion of sv, which was parsed from body,
+// from semverPool, adding it there if need be.
+func pooledSemver(body string, sv semver.Version) UnpairedVersion {
+	semverPool.RLock()
+	v, has := semverPool.m[body]
+	semverPool.RUnlock()
+	if has {
+		return v
+	}
+
+	v = semVersion{sv: sv}
+	semverPool.Lock()
+	if pv, has := semverPool.m[body]; has {
+		v = pv
+	} else {
+		semverPool.m[body] = v
+	}
+	semverPool.Unlock()
+	return v
+}
@@ -0,0 +1,283 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+// LazySourceMgr is a SourceManager that creates the SourceMgr it defers to
+// only when it is first needed, so that commands that may get by without
+// sources neither wait for the lock on the cache directory, nor pay for setting
+// up the SourceMgr, unless they do.
+//
+// The roots of import paths on the well-known hosts, such as github.com, are
+// deduced without creating the SourceMgr, unless mirrors are configured, as
+// they are deduced from the paths alone.
+type LazySourceMgr struct {
+	c     SourceManagerConfig
+	known *deducerTrie
+
+	mu       sync.Mutex
+	sm       *SourceMgr
+	err      error
+	signals  bool
+	released bool
+	locks    []recordedLock
+}
+
+// recordedLock is a lock recorded with a LazySourceMgr before its SourceMgr
+// was created.
+type recordedLock struct {
+	root string
+	l    Lock
+}
+
+var _ SourceManager = (*LazySourceMgr)(nil)
+
+// NewLazySourceManager returns a LazySourceMgr that creates its SourceMgr, when
+// it is first needed, as NewSourceManager does with c.
+func NewLazySourceManager(c SourceManagerConfig) *LazySourceMgr {
+	return &LazySourceMgr{c: c, known: pathDeducerTrie()}
+}
+
+// get returns the SourceMgr, creating it if it was not yet. If it could not
+// be created, the error is returned now and on every later call.
+func (l *LazySourceMgr) get() (*SourceMgr, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return nil, ErrSourceManagerIsReleased
+	}
+	if l.sm != nil || l.err != nil {
+		return l.sm, l.err
+	}
+
+	l.sm, l.err = NewSourceManager(l.c)
+	if l.err != nil {
+		return nil, l.err
+	}
+	if l.signals {
+		l.sm.UseDefaultSignalHandling()
+	}
+	for _, rl := range l.locks {
+		l.sm.RecordLock(rl.root, rl.l)
+	}
+	l.locks = nil
+	return l.sm, nil
+}
+
+// Created reports whether the SourceMgr has been created.
+func (l *LazySourceMgr) Created() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sm != nil
+}
+
+// Cachedir returns the location of the cache directory.
+func (l *LazySourceMgr) Cachedir() string {
+	return l.c.Cachedir
+}
+
+// UseDefaultSignalHandling sets up the SourceMgr, once it is created, to
+// handle signals as SourceMgr.UseDefaultSignalHandling does.
+func (l *LazySourceMgr) UseDefaultSignalHandling() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sm != nil {
+		l.sm.UseDefaultSignalHandling()
+		return
+	}
+	l.signals = true
+}
+
+// RecordLock records that the project rooted at root depends on the projects
+// in lk, as SourceMgr.RecordLock does. Until the SourceMgr is created, the
+// lock is held on to, and is recorded only if it is.
+func (l *LazySourceMgr) RecordLock(root string, lk Lock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sm != nil {
+		l.sm.RecordLock(root, lk)
+		return
+	}
+	l.locks = append(l.locks, recordedLock{root: root, l: lk})
+}
+
+// Release releases the SourceMgr, if it was created. Once released, the
+// SourceMgr is not created any more.
+func (l *LazySourceMgr) Release() {
+	l.mu.Lock()
+	sm := l.sm
+	l.released = true
+	l.mu.Unlock()
+	if sm != nil {
+		sm.Release()
+	}
+}
+
+// SourceExists checks if a repository exists, either upstream or in the
+// cache, for the provided ProjectIdentifier.
+func (l *LazySourceMgr) SourceExists(id ProjectIdentifier) (bool, error) {
+	sm, err := l.get()
+	if err != nil {
+		return false, err
+	}
+	return sm.SourceExists(id)
+}
+
+// SyncSourceFor will ensure that all local caches and information about a
+// source are up to date with any network-acccesible information.
+func (l *LazySourceMgr) SyncSourceFor(id ProjectIdentifier) error {
+	sm, err := l.get()
+	if err != nil {
+		return err
+	}
+	return sm.SyncSourceFor(id)
+}
+
+// ListVersions retrieves a list of the available versions for a given
+// repository name.
+func (l *LazySourceMgr) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	sm, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	return sm.ListVersions(id)
+}
+
+// RevisionPresentIn indicates whether the provided Revision is present in the
+// given repository.
+func (l *LazySourceMgr) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
+	sm, err := l.get()
+	if err != nil {
+		return false, err
+	}
+	return sm.RevisionPresentIn(id, r)
+}
+
+// ListPackages parses the tree of the Go packages at and below the
+// ProjectRoot of the given ProjectIdentifier, at the given version.
+func (l *LazySourceMgr) ListPackages(id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	sm, err := l.get()
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return sm.ListPackages(id, v)
+}
+
+// GetManifestAndLock returns manifest and lock information for the provided
+// ProjectIdentifier, at the provided Version.
+func (l *LazySourceMgr) GetManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	sm, err := l.get()
+	if err != nil {
+		return nil, nil, err
+	}
+	return sm.GetManifestAndLock(id, v, an)
+}
+
+// ExportProject writes out the tree of the provided ProjectIdentifier's
+// ProjectRoot, at the provided version, to the provided directory.
+func (l *LazySourceMgr) ExportProject(ctx context.Context, id ProjectIdentifier, v Version, to string) error {
+	sm, err := l.get()
+	if err != nil {
+		return err
+	}
+	return sm.ExportProject(ctx, id, v, to)
+}
+
+// VerifySignature verifies that the version v of the project id is signed by
+// one of the keys in the OpenPGP keyring at the path keyring, as
+// SourceMgr.VerifySignature does.
+func (l *LazySourceMgr) VerifySignature(ctx context.Context, id ProjectIdentifier, v Version, keyring string) error {
+	sm, err := l.get()
+	if err != nil {
+		return err
+	}
+	return sm.VerifySignature(ctx, id, v, keyring)
+}
+
+// VerifySigstore verifies the Sigstore signature of the version v of the
+// project id against signer, as SourceMgr.VerifySigstore does.
+func (l *LazySourceMgr) VerifySigstore(ctx context.Context, id ProjectIdentifier, v Version, signer SigstoreIdentity) error {
+	sm, err := l.get()
+	if err != nil {
+		return err
+	}
+	return sm.VerifySigstore(ctx, id, v, signer)
+}
+
+// DeduceProjectRoot takes an import path and deduces the corresponding
+// project/source root. Import paths on the well-known hosts are deduced
+// without creating the SourceMgr, where no mirrors are configured.
+func (l *LazySourceMgr) DeduceProjectRoot(ip string) (ProjectRoot, error) {
+	if !l.Created() {
+		if root, ok := l.deduceKnownRoot(ip); ok {
+			return root, nil
+		}
+	}
+	sm, err := l.get()
+	if err != nil {
+		return "", err
+	}
+	return sm.DeduceProjectRoot(ip)
+}
+
+// deduceKnownRoot deduces the root of the import path ip as the SourceMgr
+// would from the patterns of the well-known hosts, and reports whether it
+// could. Mirrors come before those patterns, so nothing is deduced where any
+// are configured.
+func (l *LazySourceMgr) deduceKnownRoot(ip string) (ProjectRoot, bool) {
+	if len(l.c.Mirrors) > 0 || !pathvld.MatchString(ip) {
+		return "", false
+	}
+	if _, ok := localDirPath(ip, nil); ok {
+		return "", false
+	}
+	u, path, err := normalizeURI(ip)
+	if err != nil {
+		return "", false
+	}
+	if _, ok := localDirPath(path, u); ok || isArchiveURL(u) {
+		return "", false
+	}
+
+	_, mtch, has := l.known.LongestPrefix(path)
+	if !has {
+		return "", false
+	}
+	root, err := mtch.deduceRoot(path)
+	if err != nil {
+		return "", false
+	}
+	if _, err := mtch.deduceSource(path, u); err != nil {
+		return "", false
+	}
+	return ProjectRoot(root), true
+}
+
+// SourceURLsForPath takes an import path and deduces the set of source URLs
+// that may refer to a canonical upstream source.
+func (l *LazySourceMgr) SourceURLsForPath(ip string) ([]*url.URL, error) {
+	sm, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	return sm.SourceURLsForPath(ip)
+}
+
+// InferConstraint tries to puzzle out what kind of version is given in a
+// string, as SourceMgr.InferConstraint does.
+func (l *LazySourceMgr) InferConstraint(s string, pi ProjectIdentifier) (Constraint, error) {
+	sm, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	return sm.InferConstraint(s, pi)
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLazySourceMgr(t *testing.T) {
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	l := NewLazySourceManager(SourceManagerConfig{Cachedir: cpath})
+	l.UseDefaultSignalHandling()
+	l.RecordLock("/go/src/example.com/app", nil)
+
+	for ip, want := range map[string]ProjectRoot{
+		"github.com/foo/bar":         "github.com/foo/bar",
+		"github.com/foo/bar/baz/qux": "github.com/foo/bar",
+		"gopkg.in/yaml.v2/internal":  "gopkg.in/yaml.v2",
+	} {
+		got, err := l.DeduceProjectRoot(ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("expected the root of %s to be %s, got %s", ip, want, got)
+		}
+	}
+	if l.Created() {
+		t.Fatal("expected the roots of paths on well-known hosts to be deduced without a SourceMgr")
+	}
+	if _, err := os.Stat(filepath.Join(cpath, "sm.lock")); !os.IsNotExist(err) {
+		t.Fatalf("expected the cache not to be locked, got %v", err)
+	}
+
+	// Anything else needs the SourceMgr.
+	if _, err := l.InferConstraint("", mkPI("github.com/foo/bar")); err != nil {
+		t.Fatal(err)
+	}
+	if !l.Created() {
+		t.Fatal("expected the SourceMgr to be created once it was needed")
+	}
+	if _, err := os.Stat(filepath.Join(cpath, "sm.lock")); err != nil {
+		t.Fatalf("expected the cache to be locked, got %v", err)
+	}
+
+	l.Release()
+	if _, err := os.Stat(filepath.Join(cpath, "sm.lock")); !os.IsNotExist(err) {
+		t.Fatalf("expected the cache to be unlocked on release, got %v", err)
+	}
+	if _, err := l.DeduceProjectRoot("github.com/foo/bar"); err != ErrSourceManagerIsReleased {
+		t.Fatalf("expected %v once released, got %v", ErrSourceManagerIsReleased, err)
+	}
+}
+
+func TestLazySourceMgrMirrors(t *testing.T) {
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	l := NewLazySourceManager(SourceManagerConfig{
+		Cachedir: cpath,
+		Mirrors:  map[string]string{"github.com/foo/*": "https://mirror.example.com/*.git"},
+	})
+	defer l.Release()
+
+	// Mirrors come before the well-known hosts, so the SourceMgr deduces
+	// roots wherever any are configured.
+	if _, err := l.DeduceProjectRoot("github.com/foo/bar"); err != nil {
+		t.Fatal(err)
+	}
+	if !l.Created() {
+		t.Fatal("expected the SourceMgr to be created to deduce roots with mirrors configured")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// localType is the source type of local directories.
+const localType = "local"
+
+// localBranch is the name of the single version of a local directory.
+const localBranch = "local"
+
+// localDirPath returns the directory named by a source, if it names one: an
+// absolute path, or a file URL.
+func localDirPath(p string, u *url.URL) (string, bool) {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p), true
+	}
+	if u != nil && u.Scheme == "file" && u.Host == "" && u.Path != "" {
+		return filepath.Clean(filepath.FromSlash(u.Path)), true
+	}
+	return "", false
+}
+
+type maybeLocalSource struct {
+	url *url.URL
+	dir string
+}
+
+func (m maybeLocalSource) try(ctx context.Context, cachedir string) (source, error) {
+	return &localSource{dir: m.dir}, nil
+}
+
+func (m maybeLocalSource) URL() *url.URL {
+	return m.url
+}
+
+func (m maybeLocalSource) String() string {
+	return fmt.Sprintf("%T: %s", m, m.dir)
+}
+
+// localSource is a directory on the local filesystem, such as the working copy
+// of a library that has not been published yet. It is used in place, rather
+// than being copied into the cache, and holds a single version: the default
+// branch, called "local". As the directory's contents may change at any time,
+// the branch is paired with a revision computed from the contents, in the same
+// way as the digests of vendored projects.
+//
+// Only the current contents of the directory are available, so they are used
+// for whichever revision is asked for.
+type localSource struct {
+	dir string
+}
+
+func (s *localSource) sourceType() string {
+	return localType
+}
+
+func (s *localSource) upstreamURL() string {
+	return s.dir
+}
+
+func (s *localSource) existsLocally(ctx context.Context) bool {
+	fi, err := os.Stat(s.dir)
+	return err == nil && fi.IsDir()
+}
+
+func (s *localSource) existsUpstream(ctx context.Context) bool {
+	return s.existsLocally(ctx)
+}
+
+func (*localSource) existsCallsListVersions() bool {
+	return false
+}
+
+func (*localSource) listVersionsRequiresLocal() bool {
+	return false
+}
+
+// initLocal does nothing, as the directory is used in place.
+func (s *localSource) initLocal(ctx context.Context) error {
+	if !s.existsLocally(ctx) {
+		return errors.Errorf("%s is not a directory", s.dir)
+	}
+	return nil
+}
+
+func (s *localSource) updateLocal(ctx context.Context) error {
+	return s.initLocal(ctx)
+}
+
+func (s *localSource) maybeClean(ctx context.Context) error {
+	return nil
+}
+
+// revision returns the revision of the directory's current contents.
+func (s *localSource) revision() (Revision, error) {
+	digest, err := pkgtree.DigestFromDirectory(s.dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to compute the digest of %s", s.dir)
+	}
+	return Revision(hex.EncodeToString(digest)), nil
+}
+
+func (s *localSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	rev, err := s.revision()
+	if err != nil {
+		return nil, err
+	}
+	return []PairedVersion{newDefaultBranch(localBranch).Pair(rev).(PairedVersion)}, nil
+}
+
+func (s *localSource) revisionPresentIn(ctx context.Context, r Revision) (bool, error) {
+	rev, err := s.revision()
+	if err != nil {
+		return false, err
+	}
+	return r == rev, nil
+}
+
+func (s *localSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	return s.revision()
+}
+
+func (s *localSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	m, l, err := an.DeriveManifestAndLock(s.dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+
+	return prepManifest(m), l, nil
+}
+
+func (s *localSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	return pkgtree.ListPackages(s.dir, string(pr))
+}
+
+// exportRevisionTo copies the directory's current contents to to, leaving out
+// the metadata of any VCS it is under.
+func (s *localSource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	// Only make the parent dir, as CopyDir will balk on trying to write to an
+	// empty but existing dir.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+
+	if err := fs.CopyDir(s.dir, to); err != nil {
+		return err
+	}
+	for _, vcsDir := range []string{".bzr", ".git", ".hg", ".svn"} {
+		if err := os.RemoveAll(filepath.Join(to, vcsDir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeduceLocalSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dc := newDeductionCoordinator(nil)
+	for _, in := range []string{dir, "file://" + filepath.ToSlash(dir)} {
+		pd, err := dc.deduceKnownPaths(in)
+		if err != nil {
+			t.Fatalf("unexpected error deducing %s: %s", in, err)
+		}
+		if pd.root != in {
+			t.Errorf("unexpected root for %s: %s", in, pd.root)
+		}
+		if len(pd.mb) != 1 {
+			t.Fatalf("expected a single source for %s, got %s", in, pd.mb)
+		}
+		mb, ok := pd.mb[0].(maybeLocalSource)
+		if !ok || mb.dir != filepath.Clean(dir) {
+			t.Errorf("expected a local source for %s, got %s", in, pd.mb[0])
+		}
+	}
+}
+
+func TestLocalSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := filepath.Join(dir, "lib")
+	for name, data := range map[string]string{
+		"lib.go":     "package lib\n",
+		"sub/sub.go": "package sub\n",
+		".git/HEAD":  "ref: refs/heads/master\n",
+	} {
+		path := filepath.Join(lib, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	src, err := maybeLocalSource{dir: lib}.try(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !src.existsUpstream(ctx) || src.initLocal(ctx) != nil {
+		t.Fatal("expected the directory to exist")
+	}
+
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 1 || vlist[0].String() != localBranch {
+		t.Fatalf("expected the single branch %s, got %s", localBranch, vlist)
+	}
+	if bv, ok := vlist[0].Unpair().(branchVersion); !ok || !bv.isDefault {
+		t.Errorf("expected %s to be the default branch, got %#v", localBranch, vlist[0].Unpair())
+	}
+	rev := vlist[0].Revision()
+
+	ptree, err := src.listPackages(ctx, "example.com/lib", rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := ptree.Packages["example.com/lib/sub"]; !has {
+		t.Errorf("expected package example.com/lib/sub, got %v", ptree.Packages)
+	}
+
+	to := filepath.Join(dir, "vendor", "example.com", "lib")
+	if err := src.exportRevisionTo(ctx, rev, to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "sub", "sub.go")); err != nil {
+		t.Errorf("expected sub/sub.go to be exported: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(to, ".git")); !os.IsNotExist(err) {
+		t.Error("expected .git not to be exported")
+	}
+
+	// Changing the contents changes the revision.
+	if err := ioutil.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib // changed\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	vlist, err = src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vlist[0].Revision() == rev {
+		t.Error("expected the revision to change with the directory's contents")
+	}
+	if present, _ := src.revisionPresentIn(ctx, rev); present {
+		t.Error("expected the old revision to no longer be present")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logging provides the leveled logger that the solver and the source
+// manager of gps, and dep, report what they do through. Programs using gps
+// pass a Logger of their own, such as one returned by New, or any type with
+// its methods.
+//
+// Messages are written as plain text, with warnings and errors labeled, and
+// the labels colored on terminals, or as newline-delimited JSON, one object
+// per message, for machines to consume.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Level is the severity of a message.
+type Level int
+
+// The levels of messages, from the least severe.
+const (
+	// Debug is detail only of interest when following what dep does, as
+	// with -v.
+	Debug Level = iota
+	// Info is what dep did that is of general interest.
+	Info
+	// Warn is a problem dep worked around, or one that is likely a mistake.
+	Warn
+	// Error is a failure, whether or not dep carried on.
+	Error
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level named s: debug, info, warn or error.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if s == name {
+			return Level(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, must be debug, info, warn or error", s)
+}
+
+// A Logger logs messages at levels. Its methods may be called concurrently.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	// Enabled reports whether messages at l are logged, for those logging
+	// many of them to skip preparing them when they are not.
+	Enabled(l Level) bool
+}
+
+// Options are the options of a Logger returned by New.
+type Options struct {
+	// Level is the least severe level of the messages logged.
+	Level Level
+	// JSON writes each message as a JSON object, with its time, level and
+	// text, rather than as plain text.
+	JSON bool
+	// Color colors the labels of warnings and errors, and dims debug
+	// messages, in plain text. See ColorEnabled.
+	Color bool
+}
+
+// New returns a Logger writing to w.
+func New(w io.Writer, opts Options) Logger {
+	return &logger{w: w, opts: opts}
+}
+
+// Discard is a Logger that logs nothing.
+var Discard Logger = discard{}
+
+type logger struct {
+	mu   sync.Mutex
+	w    io.Writer
+	opts Options
+}
+
+func (l *logger) Debugf(format string, args ...interface{}) { l.logf(Debug, format, args) }
+func (l *logger) Infof(format string, args ...interface{})  { l.logf(Info, format, args) }
+func (l *logger) Warnf(format string, args ...interface{})  { l.logf(Warn, format, args) }
+func (l *logger) Errorf(format string, args ...interface{}) { l.logf(Error, format, args) }
+
+func (l *logger) Enabled(lvl Level) bool { return lvl >= l.opts.Level }
+
+// The ANSI escapes that messages are colored with.
+const (
+	colorReset  = "\x1b[0m"
+	colorDim    = "\x1b[2m"
+	colorYellow = "\x1b[33m"
+	colorRed    = "\x1b[31m"
+)
+
+// jsonMessage is a message as written with Options.JSON.
+type jsonMessage struct {
+	Time  time.Time `json:"time"`
+	Level string    `json:"level"`
+	Msg   string    `json:"msg"`
+}
+
+func (l *logger) logf(lvl Level, format string, args []interface{}) {
+	if !l.Enabled(lvl) {
+		return
+	}
+	// As with log.Printf, a trailing newline is optional.
+	msg := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+
+	var buf bytes.Buffer
+	switch {
+	case l.opts.JSON:
+		// Encoding a string never fails.
+		json.NewEncoder(&buf).Encode(jsonMessage{Time: time.Now().UTC(), Level: lvl.String(), Msg: msg})
+	case lvl == Warn, lvl == Error:
+		label, color := "Warning:", colorYellow
+		if lvl == Error {
+			label, color = "Error:", colorRed
+		}
+		if l.opts.Color {
+			label = color + label + colorReset
+		}
+		fmt.Fprintf(&buf, "%s %s\n", label, msg)
+	case lvl == Debug && l.opts.Color:
+		fmt.Fprintf(&buf, "%s%s%s\n", colorDim, msg, colorReset)
+	default:
+		buf.WriteString(msg + "\n")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(buf.Bytes())
+}
+
+type discard struct{}
+
+func (discard) Debugf(string, ...interface{}) {}
+func (discard) Infof(string, ...interface{})  {}
+func (discard) Warnf(string, ...interface{})  {}
+func (discard) Errorf(string, ...interface{}) {}
+func (discard) Enabled(Level) bool            { return false }
+
+// ColorEnabled reports whether messages written to w should be colored: if it
+// is a terminal, and color is not turned off by setting $NO_COLOR, or by a
+// $TERM of "dumb".
+func ColorEnabled(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
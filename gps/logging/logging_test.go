@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Options{Level: Info})
+	l.Debugf("resolving %s", "github.com/foo/bar")
+	l.Infof("Removed %d unused sources\n", 2)
+	l.Warnf("failed to cache %s", "metadata")
+	l.Errorf("could not write %s", "vendor/")
+
+	want := "Removed 2 unused sources\nWarning: failed to cache metadata\nError: could not write vendor/\n"
+	if buf.String() != want {
+		t.Errorf("unexpected log:\n\t(GOT): %q\n\t(WNT): %q", buf.String(), want)
+	}
+	if l.Enabled(Debug) || !l.Enabled(Info) {
+		t.Error("expected only messages at info and above to be enabled")
+	}
+}
+
+func TestLoggerColor(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Options{Level: Debug, Color: true})
+	l.Debugf("trace")
+	l.Warnf("careful")
+
+	want := "\x1b[2mtrace\x1b[0m\n\x1b[33mWarning:\x1b[0m careful\n"
+	if buf.String() != want {
+		t.Errorf("unexpected log:\n\t(GOT): %q\n\t(WNT): %q", buf.String(), want)
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Options{Level: Debug, JSON: true})
+	l.Debugf("trace")
+	l.Warnf("failed to cache %s\n", "metadata")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a line for each message, got:\n%s", buf.String())
+	}
+	var msg jsonMessage
+	if err := json.Unmarshal([]byte(lines[1]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Level != "warn" || msg.Msg != "failed to cache metadata" || msg.Time.IsZero() {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{Debug, Info, Warn, Error} {
+		got, err := ParseLevel(l.String())
+		if err != nil || got != l {
+			t.Errorf("ParseLevel(%q) = %v, %v", l.String(), got, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected an unknown level to be an error")
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	if ColorEnabled(&bytes.Buffer{}) {
+		t.Error("expected a buffer not to be colored")
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path"
 	"path/filepath"
@@ -21,6 +20,7 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -48,7 +48,7 @@ func mkNaiveSM(t *testing.T) (*SourceMgr, func()) {
 
 	sm, err := NewSourceManager(SourceManagerConfig{
 		Cachedir: cpath,
-		Logger:   log.New(test.Writer{TB: t}, "", 0),
+		Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
 	})
 	if err != nil {
 		t.Fatalf("Unexpected error on SourceManager creation: %s", err)
@@ -69,7 +69,7 @@ func remakeNaiveSM(osm *SourceMgr, t *testing.T) (*SourceMgr, func()) {
 
 	sm, err := NewSourceManager(SourceManagerConfig{
 		Cachedir: cpath,
-		Logger:   log.New(test.Writer{TB: t}, "", 0),
+		Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
 	})
 	if err != nil {
 		t.Fatalf("unexpected error on SourceManager recreation: %s", err)
@@ -91,7 +91,7 @@ func TestSourceManagerInit(t *testing.T) {
 	}
 	cfg := SourceManagerConfig{
 		Cachedir: cpath,
-		Logger:   log.New(test.Writer{TB: t}, "", 0),
+		Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
 	}
 
 	sm, err := NewSourceManager(cfg)
@@ -153,7 +153,7 @@ func TestSourceInit(t *testing.T) {
 
 	sm, err := NewSourceManager(SourceManagerConfig{
 		Cachedir: cpath,
-		Logger:   log.New(test.Writer{TB: t}, "", 0),
+		Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
 	})
 	if err != nil {
 		t.Fatalf("Unexpected error on SourceManager creation: %s", err)
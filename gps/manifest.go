@@ -61,6 +61,43 @@ type RootManifest interface {
 	RequiredPackages() map[string]bool
 }
 
+// SourceMapper is an optional interface that a RootManifest may implement to
+// redirect where projects are fetched from, e.g. to an internal mirror, without
+// declaring a source for each of them.
+type SourceMapper interface {
+	// SourceMap returns a map of project root prefixes to the source from
+	// which projects under each prefix should be fetched. The remainder of a
+	// project's root, after the longest matching prefix, is appended to the
+	// source.
+	//
+	// Sources set explicitly, by a constraint or override, take precedence.
+	SourceMap() map[string]string
+}
+
+// ProtocolMapper is an optional interface that a RootManifest may implement to
+// force the transport over which projects are fetched, rather than leaving it
+// to be negotiated, or rewritten by git's insteadOf.
+type ProtocolMapper interface {
+	// ProtocolMap returns a map of hosts and project root prefixes to the
+	// protocol, "ssh" or "https", over which the projects under each are
+	// fetched. The longest matching prefix wins.
+	//
+	// Projects without an explicit source, or one from a SourceMapper, are
+	// given the source named by their root over the protocol, such as
+	// ssh://git@github.com/foo/bar, so that solutions record it.
+	ProtocolMap() map[string]string
+}
+
+// BanLister is an optional interface that a RootManifest may implement to keep
+// projects out of solutions altogether, whoever depends on them.
+type BanLister interface {
+	// BannedProjects returns a map of project roots, or prefixes of them, to
+	// the reasons the projects at or under each are banned. Solving fails,
+	// saying which imports bring it in, if a banned project cannot be kept
+	// out of the solution.
+	BannedProjects() map[string]string
+}
+
 // SimpleManifest is a helper for tools to enumerate manifest data. It's
 // generally intended for ephemeral manifests, such as those Analyzers create on
 // the fly for projects with no manifest metadata, or metadata through a foreign
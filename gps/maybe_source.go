@@ -62,7 +62,7 @@ func (m maybeGitSource) try(ctx context.Context, cachedir string) (source, error
 
 	return &gitSource{
 		baseVCSSource: baseVCSSource{
-			repo: &gitRepo{r},
+			repo: &gitRepo{GitRepo: r},
 		},
 	}, nil
 }
@@ -109,7 +109,7 @@ func (m maybeGopkginSource) try(ctx context.Context, cachedir string) (source, e
 	return &gopkginSource{
 		gitSource: gitSource{
 			baseVCSSource: baseVCSSource{
-				repo: &gitRepo{r},
+				repo: &gitRepo{GitRepo: r},
 			},
 		},
 		major:    m.major,
@@ -193,6 +193,28 @@ func (m maybeHgSource) String() string {
 	return fmt.Sprintf("%T: %s", m, ufmt(m.url))
 }
 
+type maybeFossilSource struct {
+	url *url.URL
+}
+
+func (m maybeFossilSource) try(ctx context.Context, cachedir string) (source, error) {
+	ustr := m.url.String()
+
+	return &fossilSource{
+		baseVCSSource: baseVCSSource{
+			repo: newFossilRepo(ustr, sourceCachePath(cachedir, ustr)),
+		},
+	}, nil
+}
+
+func (m maybeFossilSource) URL() *url.URL {
+	return m.url
+}
+
+func (m maybeFossilSource) String() string {
+	return fmt.Sprintf("%T: %s", m, ufmt(m.url))
+}
+
 // borrow from stdlib
 // more useful string for debugging than fmt's struct printer
 func ufmt(u *url.URL) string {
@@ -0,0 +1,167 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// metadataCacheName is the name of the file, in the cache directory, in which
+// go-get metadata is cached.
+const metadataCacheName = "metadata.json"
+
+// GoImport is the go-get metadata of an import path: the prefix of the
+// import path at which a repository is rooted, the type of the repository,
+// and the URL from which it is fetched, as in a go-import meta tag.
+type GoImport struct {
+	Root     string `json:"root"`
+	VCS      string `json:"vcs"`
+	RepoRoot string `json:"repo"`
+}
+
+// cachedGoImport is go-get metadata, as cached along with the time it was
+// fetched.
+type cachedGoImport struct {
+	GoImport
+	Fetched time.Time `json:"fetched"`
+}
+
+// metadataCache caches go-get metadata persistently, so that the hosts of
+// vanity import paths are not asked for it on every run, and so that solving
+// can continue when they are unreachable. It also holds the overrides that
+// take the place of the metadata of some import paths. A nil *metadataCache
+// caches nothing, and has no overrides.
+type metadataCache struct {
+	path      string        // The file the cache is kept in; empty if it is not kept.
+	maxAge    time.Duration // The age beyond which cached metadata is fetched again.
+	overrides map[string]GoImport
+	logger    logging.Logger
+
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]cachedGoImport
+}
+
+func newMetadataCache(c SourceManagerConfig) *metadataCache {
+	if c.MetadataCacheAge <= 0 && len(c.MetadataOverrides) == 0 {
+		return nil
+	}
+	mc := &metadataCache{
+		maxAge:    c.MetadataCacheAge,
+		overrides: make(map[string]GoImport, len(c.MetadataOverrides)),
+		logger:    c.Logger,
+	}
+	if c.MetadataCacheAge > 0 {
+		mc.path = filepath.Join(c.Cachedir, metadataCacheName)
+	}
+	for root, gi := range c.MetadataOverrides {
+		gi.Root = root
+		mc.overrides[root] = gi
+	}
+	return mc
+}
+
+// override returns the override for path, if there is one.
+func (mc *metadataCache) override(path string) (GoImport, bool) {
+	if mc == nil {
+		return GoImport{}, false
+	}
+	var match GoImport
+	var has bool
+	for root, gi := range mc.overrides {
+		if strings.HasPrefix(path, root) && isPathPrefixOrEqual(root, path) && len(root) > len(match.Root) {
+			match, has = gi, true
+		}
+	}
+	return match, has
+}
+
+// lookup returns the cached metadata for path, if there is any, and whether
+// it is fresh enough to be used without fetching it again.
+func (mc *metadataCache) lookup(path string) (GoImport, bool, bool) {
+	if mc == nil || mc.path == "" {
+		return GoImport{}, false, false
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.load()
+
+	var match cachedGoImport
+	var has bool
+	for root, ci := range mc.entries {
+		if strings.HasPrefix(path, root) && isPathPrefixOrEqual(root, path) && len(root) > len(match.Root) {
+			match, has = ci, true
+		}
+	}
+	return match.GoImport, has, has && time.Since(match.Fetched) < mc.maxAge
+}
+
+// store caches gi, as fetched just now.
+func (mc *metadataCache) store(gi GoImport) {
+	if mc == nil || mc.path == "" {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.load()
+
+	mc.entries[gi.Root] = cachedGoImport{GoImport: gi, Fetched: time.Now()}
+	if err := mc.save(); err != nil {
+		mc.logger.Warnf("%s", errors.Wrap(err, "failed to cache go-get metadata"))
+	}
+}
+
+// load reads the cache file, if it has not been read already. A missing or
+// unreadable file leaves the cache empty. The caller must hold mc.mu.
+func (mc *metadataCache) load() {
+	if mc.loaded {
+		return
+	}
+	mc.loaded = true
+	mc.entries = make(map[string]cachedGoImport)
+
+	data, err := ioutil.ReadFile(mc.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			mc.logger.Warnf("%s", errors.Wrap(err, "failed to read cached go-get metadata"))
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &mc.entries); err != nil {
+		mc.logger.Warnf("%s", errors.Wrapf(err, "ignoring corrupt go-get metadata cache %s", mc.path))
+		mc.entries = make(map[string]cachedGoImport)
+	}
+}
+
+// save writes the cache file. The caller must hold mc.mu.
+func (mc *metadataCache) save() error {
+	data, err := json.MarshalIndent(mc.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(mc.path), metadataCacheName)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return fs.RenameWithFallback(tmp.Name(), mc.path)
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps/logging"
+)
+
+func TestMetadataCacheOverride(t *testing.T) {
+	mc := newMetadataCache(SourceManagerConfig{
+		MetadataOverrides: map[string]GoImport{
+			"go.example.com":     {VCS: "git", RepoRoot: "https://git.example.com/all"},
+			"go.example.com/lib": {VCS: "hg", RepoRoot: "https://hg.example.com/lib"},
+		},
+	})
+
+	cases := map[string]GoImport{
+		"go.example.com/lib":     {Root: "go.example.com/lib", VCS: "hg", RepoRoot: "https://hg.example.com/lib"},
+		"go.example.com/lib/sub": {Root: "go.example.com/lib", VCS: "hg", RepoRoot: "https://hg.example.com/lib"},
+		"go.example.com/library": {Root: "go.example.com", VCS: "git", RepoRoot: "https://git.example.com/all"},
+	}
+	for path, want := range cases {
+		got, has := mc.override(path)
+		if !has || got != want {
+			t.Errorf("unexpected override for %s:\n\t(GOT): %+v\n\t(WNT): %+v", path, got, want)
+		}
+	}
+	if _, has := mc.override("example.com/lib"); has {
+		t.Error("expected no override for example.com/lib")
+	}
+
+	var none *metadataCache
+	if _, has := none.override("go.example.com/lib"); has {
+		t.Error("expected no override from a nil cache")
+	}
+}
+
+func TestMetadataCachePersistence(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "metadata-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	c := SourceManagerConfig{
+		Cachedir:         cachedir,
+		MetadataCacheAge: time.Hour,
+		Logger:           logging.Discard,
+	}
+	gi := GoImport{Root: "go.example.com/lib", VCS: "git", RepoRoot: "https://git.example.com/lib"}
+	newMetadataCache(c).store(gi)
+	if _, err := os.Stat(filepath.Join(cachedir, metadataCacheName)); err != nil {
+		t.Fatalf("expected the cache to be written: %s", err)
+	}
+
+	mc := newMetadataCache(c)
+	got, has, fresh := mc.lookup("go.example.com/lib/sub")
+	if !has || !fresh || got != gi {
+		t.Errorf("unexpected lookup: %+v, %v, %v", got, has, fresh)
+	}
+	if _, has, _ := mc.lookup("go.example.com/other"); has {
+		t.Error("expected nothing to be cached for go.example.com/other")
+	}
+
+	c.MetadataCacheAge = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, has, fresh := newMetadataCache(c).lookup("go.example.com/lib"); !has || fresh {
+		t.Errorf("expected the cached metadata to be stale, got %v, %v", has, fresh)
+	}
+
+	c.MetadataCacheAge = 0
+	if mc := newMetadataCache(c); mc != nil {
+		t.Errorf("expected no cache when the age is 0, got %+v", mc)
+	}
+}
+
+func TestHTTPMetadataDeducerCache(t *testing.T) {
+	var fail bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `<meta name="go-import" content="%s/lib git https://git.example.com/lib">`, r.Host)
+	}))
+	defer ts.Close()
+
+	cachedir, err := ioutil.TempDir("", "metadata-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	ctx := context.Background()
+	path := strings.TrimPrefix(ts.URL, "http://") + "/lib/sub"
+	want := GoImport{Root: strings.TrimPrefix(ts.URL, "http://") + "/lib", VCS: "git", RepoRoot: "https://git.example.com/lib"}
+	hmd := &httpMetadataDeducer{
+		suprvsr: newSupervisor(ctx),
+		meta: newMetadataCache(SourceManagerConfig{
+			Cachedir:         cachedir,
+			MetadataCacheAge: time.Nanosecond,
+			Logger:           logging.Discard,
+		}),
+	}
+
+	gi, err := hmd.metadata(ctx, path, "http")
+	if err != nil || gi != want {
+		t.Fatalf("unexpected metadata: %+v, %v", gi, err)
+	}
+
+	// The cached metadata is stale, but is used when fetching it fails.
+	fail = true
+	gi, err = hmd.metadata(ctx, path, "http")
+	if err != nil || gi != want {
+		t.Errorf("expected the cached metadata to be used, got %+v, %v", gi, err)
+	}
+
+	hmd.meta = nil
+	if _, err := hmd.metadata(ctx, path, "http"); err == nil {
+		t.Error("expected an error without a cache")
+	}
+}
@@ -7,10 +7,11 @@ package gps
 import (
 	"bytes"
 	"fmt"
-	"log"
 	"sort"
 	"text/tabwriter"
 	"time"
+
+	"github.com/golang/dep/gps/logging"
 )
 
 type metrics struct {
@@ -45,7 +46,7 @@ func (m *metrics) pop() {
 	m.last = time.Now()
 }
 
-func (m *metrics) dump(l *log.Logger) {
+func (m *metrics) dump(l logging.Logger) {
 	s := make(ndpairs, len(m.times))
 	k := 0
 	for n, d := range m.times {
@@ -68,8 +69,8 @@ func (m *metrics) dump(l *log.Logger) {
 	fmt.Fprintf(w, "\n\tTOTAL:\t%v\t\n", tot)
 	w.Flush()
 
-	l.Println("\nSolver wall times by segment:")
-	l.Println((&buf).String())
+	l.Debugf("\nSolver wall times by segment:")
+	l.Debugf("%s", buf.String())
 }
 
 type ndpair struct {
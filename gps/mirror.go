@@ -0,0 +1,113 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// mirror maps the import paths matching a pattern to the URL of the repository
+// from which the projects rooted at them are fetched.
+type mirror struct {
+	pattern []string // The elements of the pattern; "*" matches any one element.
+	wild    int      // The number of "*" elements in pattern.
+	url     string   // The URL, in which each "*" is replaced by an element matched by one in pattern.
+}
+
+// mirrorList holds mirrors, most specific first. A nil mirrorList holds none.
+type mirrorList []mirror
+
+// newMirrorList returns the mirrors of m, as SourceManagerConfig.Mirrors.
+func newMirrorList(m map[string]string) (mirrorList, error) {
+	var ml mirrorList
+	for pattern, u := range m {
+		elems := strings.Split(strings.Trim(pattern, "/"), "/")
+		var wild int
+		for i, e := range elems {
+			if e == "" {
+				return nil, errors.Errorf("mirror pattern %q has an empty path element", pattern)
+			}
+			if e == "*" {
+				if i == 0 {
+					return nil, errors.Errorf("mirror pattern %q must start with a host", pattern)
+				}
+				wild++
+			} else if strings.Contains(e, "*") {
+				return nil, errors.Errorf("mirror pattern %q may only use * as a whole path element", pattern)
+			}
+		}
+		if n := strings.Count(u, "*"); n != 0 && n != wild {
+			return nil, errors.Errorf("mirror URL %q for %q must use * as many times as its pattern, or not at all", u, pattern)
+		}
+		ml = append(ml, mirror{pattern: elems, wild: wild, url: u})
+	}
+
+	// Longer patterns are more specific; of those of the same length, those
+	// with fewer wildcards are.
+	sort.Slice(ml, func(i, j int) bool {
+		if len(ml[i].pattern) != len(ml[j].pattern) {
+			return len(ml[i].pattern) > len(ml[j].pattern)
+		}
+		if ml[i].wild != ml[j].wild {
+			return ml[i].wild < ml[j].wild
+		}
+		return strings.Join(ml[i].pattern, "/") < strings.Join(ml[j].pattern, "/")
+	})
+	return ml, nil
+}
+
+// match returns the root of the project containing path, and the URL of its
+// mirror, if path matches one of ml's patterns.
+func (ml mirrorList) match(path string) (string, string, bool) {
+	elems := strings.Split(path, "/")
+	for _, m := range ml {
+		if len(elems) < len(m.pattern) {
+			continue
+		}
+		u := m.url
+		matched := true
+		for i, p := range m.pattern {
+			if p == "*" {
+				u = strings.Replace(u, "*", elems[i], 1)
+			} else if p != elems[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return strings.Join(elems[:len(m.pattern)], "/"), u, true
+		}
+	}
+	return "", "", false
+}
+
+// deduceMirror returns the deduction for path, if it matches a mirror: the
+// root matched by the mirror's pattern, and the sources deduced from the
+// mirror's URL, which is taken to be a git repository if nothing else can be
+// told from it.
+func (dc *deductionCoordinator) deduceMirror(path string) (pathDeduction, bool, error) {
+	root, u, has := dc.mirrors.match(path)
+	if !has {
+		return pathDeduction{}, false, nil
+	}
+
+	pd, err := dc.deduceKnownPaths(u)
+	switch err {
+	case nil:
+	case errNoKnownPathMatch:
+		mu, _, err := normalizeURI(u)
+		if err != nil {
+			return pathDeduction{}, false, errors.Wrapf(err, "invalid mirror for %s", root)
+		}
+		pd.mb = maybeSources{maybeGitSource{url: mu}}
+	default:
+		return pathDeduction{}, false, errors.Wrapf(err, "invalid mirror for %s", root)
+	}
+	pd.root = root
+	return pd, true, nil
+}
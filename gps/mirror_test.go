@@ -0,0 +1,99 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNewMirrorListErrors(t *testing.T) {
+	cases := map[string]map[string]string{
+		"empty path element":      {"golang.org//x": "https://github.com/golang/x"},
+		"must start with a host":  {"*/x": "https://github.com/golang/x"},
+		"as a whole path element": {"golang.org/x/net*": "https://github.com/golang/net"},
+		"as many times":           {"golang.org/x/*": "https://github.com/*/*"},
+	}
+	for want, m := range cases {
+		if _, err := newMirrorList(m); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("unexpected error for %v:\n\t(GOT): %v\n\t(WNT): %s", m, err, want)
+		}
+	}
+}
+
+func TestMirrorListMatch(t *testing.T) {
+	ml, err := newMirrorList(map[string]string{
+		"golang.org/x/*":            "https://github.com/golang/*",
+		"golang.org/x/net":          "https://git.corp.example.com/net.git",
+		"github.com/*/*":            "https://mirror.corp.example.com/github/*/*.git",
+		"go.corp.example.com/a/b/c": "https://git.corp.example.com/abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path, root, url string
+	}{
+		{"golang.org/x/text/unicode", "golang.org/x/text", "https://github.com/golang/text"},
+		{"golang.org/x/net/context", "golang.org/x/net", "https://git.corp.example.com/net.git"},
+		{"github.com/sdboyer/gps/pkgtree", "github.com/sdboyer/gps", "https://mirror.corp.example.com/github/sdboyer/gps.git"},
+		{"go.corp.example.com/a/b/c", "go.corp.example.com/a/b/c", "https://git.corp.example.com/abc"},
+	}
+	for _, c := range cases {
+		root, u, has := ml.match(c.path)
+		if !has || root != c.root || u != c.url {
+			t.Errorf("unexpected match for %s: %s, %s, %v", c.path, root, u, has)
+		}
+	}
+
+	for _, path := range []string{"golang.org/x", "go.corp.example.com/a/b", "gopkg.in/yaml.v2"} {
+		if _, _, has := ml.match(path); has {
+			t.Errorf("expected %s not to match a mirror", path)
+		}
+	}
+}
+
+func TestDeduceMirror(t *testing.T) {
+	ml, err := newMirrorList(map[string]string{
+		"golang.org/x/*":        "https://github.com/golang/*",
+		"go.corp.example.com/*": "https://mirror.corp.example.com/*",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := newDeductionCoordinator(newSupervisor(context.Background()))
+	dc.mirrors = ml
+
+	cases := map[string]struct {
+		root string
+		mb   maybeSource
+	}{
+		"golang.org/x/net/context": {"golang.org/x/net", maybeGitSource{url: mkurl("https://github.com/golang/net")}},
+		"go.corp.example.com/lib":  {"go.corp.example.com/lib", maybeGitSource{url: mkurl("https://mirror.corp.example.com/lib")}},
+	}
+	for path, want := range cases {
+		pd, err := dc.deduceRootPath(context.Background(), path)
+		if err != nil {
+			t.Fatalf("unexpected error deducing %s: %s", path, err)
+		}
+		if pd.root != want.root {
+			t.Errorf("unexpected root for %s: %s, want %s", path, pd.root, want.root)
+		}
+		if len(pd.mb) != 1 || pd.mb[0].String() != want.mb.String() {
+			t.Errorf("unexpected sources for %s:\n\t(GOT): %s\n\t(WNT): %s", path, pd.mb, want.mb)
+		}
+	}
+
+	sc := &sourceCoordinator{
+		moduleProxy: func(host string) (*url.URL, bool) { return mkurl("https://proxy.example.com"), true },
+		mirrors:     ml,
+	}
+	if mb := sc.moduleProxySources("golang.org/x/net", "golang.org/x/net"); mb != nil {
+		t.Errorf("expected a mirrored project not to be fetched from a module proxy, got %s", mb)
+	}
+}
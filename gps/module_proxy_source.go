@@ -0,0 +1,471 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// moduleProxyType is the source type of projects fetched from a module proxy.
+const moduleProxyType = "goproxy"
+
+// incompatibleSuffix is the build metadata module proxies add to the versions
+// of projects at major version 2 and up that have no go.mod file, which is to
+// say those of most projects managed with dep.
+const incompatibleSuffix = "+incompatible"
+
+// moduleProxyDefaultBranch is the name of the default branch of projects with
+// no released versions, when the proxy does not say what it is called.
+const moduleProxyDefaultBranch = "master"
+
+// moduleProxyInfoWorkers is the number of version info requests made at once.
+const moduleProxyInfoWorkers = 8
+
+// escapeModulePath escapes a module path or version for use in the URLs of a
+// module proxy, which replaces each upper-case letter with an exclamation
+// mark followed by the letter's lower-case equivalent.
+func escapeModulePath(p string) string {
+	var buf bytes.Buffer
+	for _, r := range p {
+		if 'A' <= r && r <= 'Z' {
+			buf.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+type maybeModuleProxySource struct {
+	proxy  *url.URL
+	module string
+}
+
+func (m maybeModuleProxySource) try(ctx context.Context, cachedir string) (source, error) {
+	u := m.URL()
+	return &moduleProxySource{
+		base:   u.String(),
+		module: m.module,
+		path:   sourceCachePath(cachedir, u.String()),
+		revs:   make(map[Revision]string),
+	}, nil
+}
+
+// URL returns the URL under which the proxy serves the module.
+func (m maybeModuleProxySource) URL() *url.URL {
+	u := *m.proxy
+	escaped := escapeModulePath(m.module)
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + escaped
+	// The exclamation marks of escaped paths are kept as they are.
+	u.RawPath = strings.TrimSuffix(m.proxy.EscapedPath(), "/") + "/" + escaped
+	return &u
+}
+
+func (m maybeModuleProxySource) String() string {
+	return fmt.Sprintf("%T: %s", m, ufmt(m.URL()))
+}
+
+// moduleProxySource is a project fetched from a server implementing the
+// module proxy protocol of the go command, rather than from its repository.
+// The proxy lists the project's released versions, and serves the contents
+// of each as a zip file, which is extracted into the source's cache directory
+// as it is first needed.
+//
+// The revision paired with each version is the commit the proxy reports it was
+// made from, so that locks are interchangeable with those written when the
+// project is fetched from its repository. Proxies that do not report commits
+// get the proxy's own name for the version as its revision.
+//
+// Proxies do not list branches, so a project only has a branch, its default
+// branch, when it has no released versions.
+type moduleProxySource struct {
+	base   string // The URL under which the proxy serves the module.
+	module string
+	path   string // The directory holding the module's extracted versions.
+	remote *remoteConfig
+
+	mu   sync.Mutex
+	revs map[Revision]string // Maps revisions to the proxy's versions.
+}
+
+// moduleProxyInfo is the information a module proxy returns on a version.
+type moduleProxyInfo struct {
+	Version string
+	Time    time.Time
+	Origin  *struct {
+		VCS, URL, Ref, Hash string
+	}
+}
+
+// revision returns the revision of the version described by info.
+func (info *moduleProxyInfo) revision() Revision {
+	if info.Origin != nil && info.Origin.Hash != "" {
+		return Revision(info.Origin.Hash)
+	}
+	return Revision(info.Version)
+}
+
+func (s *moduleProxySource) setRemoteConfig(rc *remoteConfig) {
+	s.remote = rc
+}
+
+func (s *moduleProxySource) sourceType() string {
+	return moduleProxyType
+}
+
+func (s *moduleProxySource) upstreamURL() string {
+	return s.base
+}
+
+func (s *moduleProxySource) existsLocally(ctx context.Context) bool {
+	fi, err := os.Stat(s.path)
+	return err == nil && fi.IsDir()
+}
+
+func (s *moduleProxySource) existsUpstream(ctx context.Context) bool {
+	_, err := s.listVersions(ctx)
+	return err == nil
+}
+
+// existsCallsListVersions returns true, as the proxy is asked whether it has
+// the module by listing its versions.
+func (*moduleProxySource) existsCallsListVersions() bool {
+	return true
+}
+
+func (*moduleProxySource) listVersionsRequiresLocal() bool {
+	return false
+}
+
+// initLocal creates the source's cache directory. Versions are fetched as
+// they are needed.
+func (s *moduleProxySource) initLocal(ctx context.Context) error {
+	return os.MkdirAll(s.path, 0777)
+}
+
+// updateLocal does nothing, as the contents of versions never change.
+func (s *moduleProxySource) updateLocal(ctx context.Context) error {
+	return s.initLocal(ctx)
+}
+
+func (s *moduleProxySource) maybeClean(ctx context.Context) error {
+	return nil
+}
+
+// get fetches the file under the module's URL at p.
+func (s *moduleProxySource) get(ctx context.Context, p string) (io.ReadCloser, error) {
+	u := s.base + "/" + p
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	s.remote.authorize(req)
+
+	resp, err := s.remote.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed HTTP request to URL %q", u)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("unable to fetch %s: %s", u, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// info returns the proxy's information on version, which may also be a
+// revision or a branch, and records the revision it is paired with.
+func (s *moduleProxySource) info(ctx context.Context, version string) (*moduleProxyInfo, error) {
+	var p string
+	if version == "" {
+		p = "@latest"
+	} else {
+		p = "@v/" + escapeModulePath(version) + ".info"
+	}
+	rc, err := s.get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	info := &moduleProxyInfo{}
+	if err := json.NewDecoder(rc).Decode(info); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode the info on %s@%s", s.module, version)
+	}
+	if info.Version == "" {
+		return nil, errors.Errorf("the info on %s@%s has no version", s.module, version)
+	}
+
+	s.mu.Lock()
+	s.revs[info.revision()] = info.Version
+	s.mu.Unlock()
+	return info, nil
+}
+
+func (s *moduleProxySource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	rc, err := s.get(ctx, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	sc := bufio.NewScanner(rc)
+	for sc.Scan() {
+		if v := strings.TrimSpace(sc.Text()); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	rc.Close()
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "unable to read the versions of %s", s.module)
+	}
+
+	if len(versions) == 0 {
+		// With no released versions, the proxy's latest version is the head
+		// of the default branch.
+		info, err := s.info(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		branch := moduleProxyDefaultBranch
+		if info.Origin != nil && strings.HasPrefix(info.Origin.Ref, "refs/heads/") {
+			branch = strings.TrimPrefix(info.Origin.Ref, "refs/heads/")
+		}
+		return []PairedVersion{newDefaultBranch(branch).Pair(info.revision()).(PairedVersion)}, nil
+	}
+
+	infos := make([]*moduleProxyInfo, len(versions))
+	errs := make([]error, len(versions))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < moduleProxyInfoWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				infos[i], errs[i] = s.info(ctx, versions[i])
+			}
+		}()
+	}
+	for i := range versions {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	vlist := make([]PairedVersion, 0, len(versions))
+	for i, v := range versions {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		name := strings.TrimSuffix(v, incompatibleSuffix)
+		vlist = append(vlist, NewVersion(name).Pair(infos[i].revision()).(PairedVersion))
+	}
+	return vlist, nil
+}
+
+// version returns the proxy's version for the revision r.
+func (s *moduleProxySource) version(ctx context.Context, r Revision) (string, error) {
+	s.mu.Lock()
+	v, has := s.revs[r]
+	s.mu.Unlock()
+	if has {
+		return v, nil
+	}
+
+	info, err := s.info(ctx, string(r))
+	if err != nil {
+		return "", err
+	}
+	return info.Version, nil
+}
+
+func (s *moduleProxySource) revisionPresentIn(ctx context.Context, r Revision) (bool, error) {
+	_, err := s.version(ctx, r)
+	return err == nil, nil
+}
+
+func (s *moduleProxySource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	s.mu.Lock()
+	_, has := s.revs[r]
+	s.mu.Unlock()
+	if has {
+		return r, nil
+	}
+
+	info, err := s.info(ctx, string(r))
+	if err != nil {
+		return "", err
+	}
+	return info.revision(), nil
+}
+
+// dir returns the directory holding the contents of revision r, fetching them
+// from the proxy if they have not been already.
+func (s *moduleProxySource) dir(ctx context.Context, r Revision) (string, error) {
+	v, err := s.version(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(s.path, sanitizer.Replace(v))
+	if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+		return dir, nil
+	}
+
+	f, err := ioutil.TempFile("", "dep-module")
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create temporary file")
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	rc, err := s.get(ctx, "@v/"+escapeModulePath(v)+".zip")
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(f, rc)
+	rc.Close()
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to download %s@%s", s.module, v)
+	}
+	if s.remote.checksDB() {
+		sum, err := hashModuleZip(f, n)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to hash %s@%s", s.module, v)
+		}
+		if err := s.remote.checkSum(ctx, s.module, v, sum); err != nil {
+			return "", err
+		}
+	}
+
+	// Extract to a temporary directory of its own beside the final one, so
+	// that a failure does not leave a partial tree behind, and calls for the
+	// same version, from this process or another, do not trip over each
+	// other.
+	tmp, err := ioutil.TempDir(s.path, ".tmp")
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+	to := filepath.Join(tmp, "tree")
+	if err := extractModuleZip(f, n, s.module+"@"+v+"/", to); err != nil {
+		return "", errors.Wrapf(err, "unable to extract %s@%s", s.module, v)
+	}
+	// The extracted tree never changes, so it is sealed, letting
+	// exportRevisionTo hard link to its files.
+	if err := sealTree(to, dir); err != nil {
+		return "", err
+	}
+	if err := fs.RenameWithFallback(to, dir); err != nil {
+		// Another call may have put the same tree in place first.
+		if fi, serr := os.Stat(dir); serr == nil && fi.IsDir() {
+			return dir, nil
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// extractModuleZip extracts the module zip in f, of size n, into dir. Every
+// file in a module zip is under prefix, the module's path and version, which
+// is stripped.
+func extractModuleZip(f *os.File, n int64, prefix, dir string) error {
+	zr, err := zip.NewReader(f, n)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		if !strings.HasPrefix(zf.Name, prefix) {
+			return errors.Errorf("%s is not under %s", zf.Name, prefix)
+		}
+		mode := zf.Mode()
+		if !mode.IsDir() && !mode.IsRegular() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = writeArchiveEntry(dir, strings.TrimPrefix(zf.Name, prefix), mode, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *moduleProxySource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	dir, err := s.dir(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, l, err := an.DeriveManifestAndLock(dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+
+	return prepManifest(m), l, nil
+}
+
+func (s *moduleProxySource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	dir, err := s.dir(ctx, r)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return pkgtree.ListPackages(dir, string(pr))
+}
+
+func (s *moduleProxySource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	dir, err := s.dir(ctx, r)
+	if err != nil {
+		return err
+	}
+	// The tree is fetched again if it was changed through a file hard linked
+	// to it by an earlier export.
+	unchanged, err := treeUnchanged(dir)
+	if err != nil {
+		return err
+	}
+	if !unchanged {
+		if err := removeTree(dir); err != nil {
+			return err
+		}
+		if dir, err = s.dir(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	// Only make the parent dir, as LinkDir will balk on trying to write to an
+	// empty but existing dir.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	return fs.LinkDir(dir, to)
+}
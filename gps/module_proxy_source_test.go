@@ -0,0 +1,301 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEscapeModulePath(t *testing.T) {
+	cases := map[string]string{
+		"github.com/sdboyer/gps":        "github.com/sdboyer/gps",
+		"github.com/Sirupsen/logrus":    "github.com/!sirupsen/logrus",
+		"github.com/BurntSushi/toml":    "github.com/!burnt!sushi/toml",
+		"v2.0.0-RC1":                    "v2.0.0-!r!c1",
+		"v2.0.0+incompatible":           "v2.0.0+incompatible",
+		"example.com/UPPER/lower/Mixed": "example.com/!u!p!p!e!r/lower/!mixed",
+	}
+	for in, want := range cases {
+		if got := escapeModulePath(in); got != want {
+			t.Errorf("unexpected escaping of %s:\n\t(GOT): %s\n\t(WNT): %s", in, got, want)
+		}
+	}
+}
+
+// testModuleProxy serves the versions of a single module, with the given
+// commits, over the module proxy protocol. Versions without a commit are
+// served without their origin, as older proxies do.
+type testModuleProxy struct {
+	module   string
+	versions []string
+	commits  map[string]string
+	files    map[string]string // The files of every version.
+	latest   string            // The version @latest reports, if there are no versions.
+}
+
+func (p *testModuleProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := "/" + escapeModulePath(p.module) + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, prefix)
+
+	info := func(v string) {
+		if c, has := p.commits[v]; has {
+			fmt.Fprintf(w, `{"Version":%q,"Time":"2018-03-01T00:00:00Z","Origin":{"VCS":"git","Ref":"refs/heads/trunk","Hash":%q}}`, v, c)
+		} else {
+			fmt.Fprintf(w, `{"Version":%q,"Time":"2018-03-01T00:00:00Z"}`, v)
+		}
+	}
+
+	switch {
+	case rest == "@v/list":
+		for _, v := range p.versions {
+			fmt.Fprintln(w, v)
+		}
+	case rest == "@latest":
+		info(p.latest)
+	case strings.HasSuffix(rest, ".info"):
+		q := strings.TrimSuffix(strings.TrimPrefix(rest, "@v/"), ".info")
+		for v, c := range p.commits {
+			if q == escapeModulePath(v) || q == c {
+				info(v)
+				return
+			}
+		}
+		for _, v := range p.versions {
+			if q == escapeModulePath(v) {
+				info(v)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	case strings.HasSuffix(rest, ".zip"):
+		v := strings.TrimSuffix(strings.TrimPrefix(rest, "@v/"), ".zip")
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, data := range p.files {
+			fw, err := zw.Create(p.module + "@" + v + "/" + name)
+			if err != nil {
+				panic(err)
+			}
+			fw.Write([]byte(strings.Replace(data, "VERSION", v, -1)))
+		}
+		zw.Close()
+		w.Write(buf.Bytes())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestModuleProxySource(t *testing.T) {
+	p := &testModuleProxy{
+		module:   "github.com/Example/lib",
+		versions: []string{"v1.0.0", "v2.0.0+incompatible"},
+		commits: map[string]string{
+			"v1.0.0": "1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d",
+		},
+		files: map[string]string{
+			"lib.go":     "package lib // VERSION\n",
+			"sub/sub.go": "package sub\n",
+		},
+	}
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	cachedir, err := ioutil.TempDir("", "module-proxy-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	ctx := context.Background()
+	mb := maybeModuleProxySource{proxy: mkurl(ts.URL), module: p.module}
+	if got, want := mb.URL().String(), ts.URL+"/github.com/!example/lib"; got != want {
+		t.Errorf("unexpected URL:\n\t(GOT): %s\n\t(WNT): %s", got, want)
+	}
+	src, err := mb.try(ctx, cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Version{
+		NewVersion("v1.0.0").Pair(Revision("1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")),
+		NewVersion("v2.0.0").Pair(Revision("v2.0.0+incompatible")),
+	}
+	if len(vlist) != len(want) {
+		t.Fatalf("unexpected versions:\n\t(GOT): %s\n\t(WNT): %s", vlist, want)
+	}
+	for i := range want {
+		if !vlist[i].identical(want[i]) {
+			t.Errorf("unexpected version:\n\t(GOT): %#v\n\t(WNT): %#v", vlist[i], want[i])
+		}
+	}
+
+	for _, v := range vlist {
+		ptree, err := src.listPackages(ctx, "github.com/Example/lib", v.Revision())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, has := ptree.Packages["github.com/Example/lib/sub"]; !has {
+			t.Errorf("expected package github.com/Example/lib/sub at %s, got %v", v, ptree.Packages)
+		}
+	}
+
+	to := filepath.Join(cachedir, "export")
+	if err := src.exportRevisionTo(ctx, vlist[1].Revision(), to); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(to, "lib.go"))
+	if err != nil || string(data) != "package lib // v2.0.0+incompatible\n" {
+		t.Errorf("unexpected export of lib.go: %q, %v", data, err)
+	}
+
+	// Revisions from locks written by fetching the project from its
+	// repository are resolved by the proxy.
+	src, err = mb.try(ctx, cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if present, _ := src.revisionPresentIn(ctx, "1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d"); !present {
+		t.Error("expected the commit of v1.0.0 to be present")
+	}
+	if present, _ := src.revisionPresentIn(ctx, "deadbeef"); present {
+		t.Error("expected an unknown commit not to be present")
+	}
+}
+
+func TestModuleProxySourceConcurrentDir(t *testing.T) {
+	p := &testModuleProxy{
+		module:   "example.com/lib",
+		versions: []string{"v1.0.0"},
+		files: map[string]string{
+			"lib.go":     "package lib\n",
+			"sub/sub.go": "package sub\n",
+		},
+	}
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	cachedir, err := ioutil.TempDir("", "module-proxy-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	ctx := context.Background()
+	src, err := maybeModuleProxySource{proxy: mkurl(ts.URL), module: p.module}.try(ctx, cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every call extracts the zip for itself, as none finds the tree in
+	// place before starting; all of them must still end up with it.
+	const n = 8
+	var wg sync.WaitGroup
+	dirs := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dirs[i], errs[i] = src.(*moduleProxySource).dir(ctx, "v1.0.0")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("unexpected error from dir: %v", errs[i])
+		}
+		if dirs[i] != dirs[0] {
+			t.Errorf("expected every call to return %s, got %s", dirs[0], dirs[i])
+		}
+	}
+	for _, name := range []string{"lib.go", "sub/sub.go"} {
+		if _, err := os.Stat(filepath.Join(dirs[0], name)); err != nil {
+			t.Errorf("expected %s in the extracted tree: %v", name, err)
+		}
+	}
+}
+
+func TestModuleProxySourceNoVersions(t *testing.T) {
+	p := &testModuleProxy{
+		module:  "example.com/untagged",
+		latest:  "v0.0.0-20180301000000-2d3e4f5a6b7c",
+		commits: map[string]string{"v0.0.0-20180301000000-2d3e4f5a6b7c": "2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e"},
+	}
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	ctx := context.Background()
+	src, err := maybeModuleProxySource{proxy: mkurl(ts.URL), module: p.module}.try(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := newDefaultBranch("trunk").Pair(Revision("2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e"))
+	if len(vlist) != 1 || !vlist[0].identical(want) {
+		t.Errorf("expected the default branch %s, got %s", want, vlist)
+	}
+}
+
+func TestModuleProxySources(t *testing.T) {
+	proxy := mkurl("https://proxy.example.com")
+	sc := &sourceCoordinator{
+		moduleProxy: func(host string) (*url.URL, bool) {
+			switch host {
+			case "github.com":
+				return proxy, true
+			case "git.corp.example.com":
+				return nil, true
+			}
+			return nil, false
+		},
+	}
+
+	mb := sc.moduleProxySources("github.com/sdboyer/gps/pkgtree", "github.com/sdboyer/gps")
+	if len(mb) != 1 || mb[0].URL().String() != "https://proxy.example.com/github.com/sdboyer/gps" {
+		t.Errorf("expected github.com/sdboyer/gps to be fetched from the proxy, got %s", mb)
+	}
+
+	for name, root := range map[string]string{
+		"git.corp.example.com/lib":        "git.corp.example.com/lib",
+		"golang.org/x/net":                "golang.org/x/net",
+		"https://github.com/sdboyer/gps":  "github.com/sdboyer/gps",
+		"git@github.com:sdboyer/gps":      "github.com/sdboyer/gps",
+		"/home/me/src/github.com/foo/bar": "/home/me/src/github.com/foo/bar",
+	} {
+		if mb := sc.moduleProxySources(name, root); mb != nil {
+			t.Errorf("expected %s not to be fetched from a proxy, got %s", name, mb)
+		}
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"path/filepath"
 	"strconv"
 
+	"github.com/golang/dep/internal/fs"
+	"github.com/golang/dep/internal/metrics"
 	"github.com/pkg/errors"
 )
 
@@ -151,8 +153,57 @@ type dirWalkClosure struct {
 // While filepath.Walk could have been used, that standard library function
 // skips symbolic links, and for now, we want the hash to include the symbolic
 // link referents.
+//
+// If osDirname is itself a symbolic link, as a project vendored as a link to
+// a tree elsewhere is, the hash is that of the directory it refers to.
 func DigestFromDirectory(osDirname string) ([]byte, error) {
+	return digestDirectory(osDirname, "")
+}
+
+// CopyDigestFromDirectory copies the directory osDirname to osCopyDirname,
+// which must not exist, as fs.CopyDir does, and returns the hash of its
+// contents that DigestFromDirectory would. Each file is read once, for both the
+// copy and the hash, rather than once to write the copy and again to verify it.
+func CopyDigestFromDirectory(osDirname, osCopyDirname string) ([]byte, error) {
+	if _, err := os.Lstat(osCopyDirname); err == nil {
+		return nil, errors.Errorf("cannot copy to %s, as it already exists", osCopyDirname)
+	}
+
+	digest, err := digestDirectory(osDirname, filepath.Clean(osCopyDirname))
+	if errors.Cause(err) != errCopyUnsupported {
+		if err != nil {
+			fs.RemoveAll(osCopyDirname)
+		}
+		return digest, err
+	}
+
+	// Trees holding nodes that are copied but not hashed, or hashed but not
+	// copied, are copied and hashed apart.
+	if err := fs.RemoveAll(osCopyDirname); err != nil {
+		return nil, err
+	}
+	if err := fs.CopyDir(osDirname, osCopyDirname); err != nil {
+		return nil, err
+	}
+	return DigestFromDirectory(osCopyDirname)
+}
+
+// errCopyUnsupported is returned by digestDirectory where it cannot copy the
+// tree that it hashes in a single walk.
+var errCopyUnsupported = errors.New("cannot copy the tree while hashing it")
+
+// digestDirectory returns the hash of the contents of osDirname, as
+// DigestFromDirectory does. If osCopyDirname is not empty, the tree is copied
+// there as it is walked.
+func digestDirectory(osDirname, osCopyDirname string) ([]byte, error) {
 	osDirname = filepath.Clean(osDirname)
+	if fi, err := os.Lstat(osDirname); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		resolved, err := filepath.EvalSymlinks(osDirname)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot resolve symlink")
+		}
+		osDirname = resolved
+	}
 
 	// Create a single hash instance for the entire operation, rather than a new
 	// hash for each node we encounter.
@@ -174,8 +225,22 @@ func DigestFromDirectory(osDirname string) ([]byte, error) {
 			osRelative = osPathname[closure.someDirLen:]
 		}
 
+		var osCopyPathname string
+		if osCopyDirname != "" {
+			osCopyPathname = filepath.Join(osCopyDirname, osRelative)
+		}
+
 		switch filepath.Base(osRelative) {
 		case "vendor", ".bzr", ".git", ".hg", ".svn":
+			if osCopyDirname != "" {
+				// Skipping any other node skips its siblings too.
+				if !info.IsDir() {
+					return errCopyUnsupported
+				}
+				if err := fs.CopyDir(osPathname, osCopyPathname); err != nil {
+					return err
+				}
+			}
 			return filepath.SkipDir
 		}
 
@@ -225,7 +290,16 @@ func DigestFromDirectory(osDirname string) ([]byte, error) {
 		writeBytesWithNull(closure.someHash, closure.someModeBytes)      // and write to hash
 
 		if shouldSkip {
-			return nil // nothing more to do for some of the node types
+			if osCopyDirname == "" {
+				return nil // nothing more to do for some of the node types
+			}
+			if mt != os.ModeDir {
+				return errCopyUnsupported
+			}
+			if osRelative == "" {
+				return errors.Wrap(os.MkdirAll(osCopyPathname, info.Mode()), "cannot Mkdir")
+			}
+			return errors.Wrap(os.Mkdir(osCopyPathname, info.Mode()), "cannot Mkdir")
 		}
 
 		if mt == os.ModeSymlink { // okay to check for equivalence because we set to this value
@@ -234,7 +308,14 @@ func DigestFromDirectory(osDirname string) ([]byte, error) {
 				return errors.Wrap(err, "cannot Readlink")
 			}
 			writeBytesWithNull(closure.someHash, []byte(filepath.ToSlash(osRelative))) // write referent to hash
-			return nil                                                                 // proceed to next node in queue
+			if osCopyDirname != "" {
+				if err := os.Symlink(osRelative, osCopyPathname); err != nil {
+					// Such as where the user may not make symlinks on Windows,
+					// and they are copied as files instead.
+					return errCopyUnsupported
+				}
+			}
+			return nil // proceed to next node in queue
 		}
 
 		// If we get here, node is a regular file.
@@ -243,8 +324,19 @@ func DigestFromDirectory(osDirname string) ([]byte, error) {
 			return errors.Wrap(err, "cannot Open")
 		}
 
+		var rd io.Reader = fh
+		var out *os.File
+		if osCopyDirname != "" {
+			if out, err = os.Create(osCopyPathname); err != nil {
+				fh.Close()
+				return errors.Wrap(err, "cannot Create")
+			}
+			// The copy is written as the file is read for the hash.
+			rd = io.TeeReader(fh, out)
+		}
+
 		var bytesWritten int64
-		bytesWritten, err = io.CopyBuffer(closure.someHash, newLineEndingReader(fh), closure.someCopyBufer) // fast copy of file contents to hash
+		bytesWritten, err = io.CopyBuffer(closure.someHash, newLineEndingReader(rd), closure.someCopyBufer) // fast copy of file contents to hash
 		err = errors.Wrap(err, "cannot Copy")                                                               // errors.Wrap only wraps non-nil, so skip extra check
 		writeBytesWithNull(closure.someHash, []byte(strconv.FormatInt(bytesWritten, 10)))                   // 10: format file size as base 10 integer
 
@@ -253,6 +345,15 @@ func DigestFromDirectory(osDirname string) ([]byte, error) {
 		if er := fh.Close(); err == nil {
 			err = errors.Wrap(er, "cannot Close")
 		}
+		if out != nil {
+			if er := out.Close(); err == nil {
+				err = errors.Wrap(er, "cannot Close")
+			}
+			if err == nil {
+				err = errors.Wrap(os.Chmod(osCopyPathname, info.Mode()), "cannot Chmod")
+				metrics.Add(metrics.BytesCopied, info.Size())
+			}
+		}
 		return err
 	})
 	if err != nil {
@@ -326,6 +427,15 @@ type fsnode struct {
 // solidus, one particular dependency would be represented as
 // "github.com/alice/alice1".
 func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]VendorStatus, error) {
+	return verifyDepTree(osDirname, wantSums, func(_, osPathname string) ([]byte, error) {
+		return DigestFromDirectory(osPathname)
+	})
+}
+
+// verifyDepTree is VerifyDepTree, taking the digests of projects from digest,
+// which is passed the slash-separated path of each project beneath osDirname,
+// along with its full path.
+func verifyDepTree(osDirname string, wantSums map[string][]byte, digest func(slashPathname, osPathname string) ([]byte, error)) (map[string]VendorStatus, error) {
 	osDirname = filepath.Clean(osDirname)
 
 	// Ensure top level pathname is a directory
@@ -391,6 +501,16 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 		slashStatus[slashPathname] = NotInTree
 	}
 
+	// The projects found in the tree whose digests are to be compared with
+	// those expected of them. Computing a digest takes reading every file of
+	// a project, so the projects are digested from several goroutines at
+	// once.
+	type project struct {
+		slashPathname, osPathname string
+		expectedSum               []byte
+	}
+	var projects []project
+
 	for len(queue) > 0 {
 		// Pop node from the top of queue (depth first traversal, reverse
 		// lexicographical order inside a directory), clearing the value stored
@@ -401,19 +521,13 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 		osPathname := filepath.Join(osDirname, currentNode.osRelative)
 
 		if expectedSum, ok := wantSums[slashPathname]; ok {
-			ls := EmptyDigestInLock
 			if len(expectedSum) > 0 {
-				projectSum, err := DigestFromDirectory(osPathname)
-				if err != nil {
-					return nil, errors.Wrap(err, "cannot compute dependency hash")
-				}
-				if bytes.Equal(projectSum, expectedSum) {
-					ls = NoMismatch
-				} else {
-					ls = DigestMismatchInLock
-				}
+				// Its digest is computed once the walk is done, along
+				// with those of the other projects.
+				projects = append(projects, project{slashPathname, osPathname, expectedSum})
+			} else {
+				slashStatus[slashPathname] = EmptyDigestInLock
 			}
-			slashStatus[slashPathname] = ls
 
 			// Mark current nodes and all its parents as required.
 			for i := currentNode.myIndex; i != -1; i = nodes[i].parentIndex {
@@ -421,7 +535,7 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 			}
 
 			// Do not need to process this directory's contents because we
-			// already accounted for its contents while calculating its digest.
+			// account for its contents in its digest.
 			continue
 		}
 
@@ -453,6 +567,27 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 		}
 	}
 
+	statuses := make([]VendorStatus, len(projects))
+	err = fs.ForEach(len(projects), func(i int) error {
+		p := projects[i]
+		projectSum, err := digest(p.slashPathname, p.osPathname)
+		if err != nil {
+			return errors.Wrap(err, "cannot compute dependency hash")
+		}
+		if bytes.Equal(projectSum, p.expectedSum) {
+			statuses[i] = NoMismatch
+		} else {
+			statuses[i] = DigestMismatchInLock
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range projects {
+		slashStatus[p.slashPathname] = statuses[i]
+	}
+
 	// Ignoring first node in the list, walk nodes from last to first. Whenever
 	// the current node is not required, but its parent is required, then the
 	// current node ought to be marked as `NotInLock`.
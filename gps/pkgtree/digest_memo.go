@@ -0,0 +1,206 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkgtree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// digestMemoRacyWindow is how recently a file may have been modified for the
+// digest of its tree not to be memoized. A file modified again within the
+// granularity of its filesystem's timestamps may keep its modification time,
+// so the fingerprint of a tree is only trusted once this long has passed since
+// any of its files was modified.
+const digestMemoRacyWindow = 2 * time.Second
+
+// DigestMemo remembers the digests that DigestFromDirectory computed for
+// trees, along with fingerprints of the metadata of the nodes in them: their
+// names, modes, sizes and modification times. While the fingerprint of a tree
+// is unchanged, its digest is taken from the memo rather than computed again,
+// which only takes reading directories, rather than every file in them.
+//
+// Trees are memoized by keys of the caller's choosing, such as the roots of
+// the projects they are vendored as, so that a tree keeps its memoized digest
+// when it is moved. A nil *DigestMemo memoizes nothing.
+type DigestMemo struct {
+	path string
+
+	mu      sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]digestMemoEntry
+}
+
+// digestMemoEntry is the memoized digest of a tree, and the fingerprint of the
+// tree it was computed from, both in hex.
+type digestMemoEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Digest      string `json:"digest"`
+}
+
+// NewDigestMemo returns a DigestMemo kept in the file at path, which is read
+// when the memo is first used, and written by Save.
+func NewDigestMemo(path string) *DigestMemo {
+	return &DigestMemo{path: path}
+}
+
+// Digest returns the digest of the tree at osDirname, as DigestFromDirectory
+// computes it, from the memo if the tree was memoized under key and its
+// fingerprint has not changed since.
+func (m *DigestMemo) Digest(key, osDirname string) ([]byte, error) {
+	if m == nil {
+		return DigestFromDirectory(osDirname)
+	}
+
+	fp, racy, err := FingerprintDirectory(osDirname)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.load()
+	e, has := m.entries[key]
+	m.mu.Unlock()
+	if has && e.Fingerprint == fp {
+		if digest, err := hex.DecodeString(e.Digest); err == nil {
+			return digest, nil
+		}
+	}
+
+	digest, err := DigestFromDirectory(osDirname)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if racy {
+		if has {
+			delete(m.entries, key)
+			m.dirty = true
+		}
+	} else {
+		m.entries[key] = digestMemoEntry{Fingerprint: fp, Digest: hex.EncodeToString(digest)}
+		m.dirty = true
+	}
+	m.mu.Unlock()
+	return digest, nil
+}
+
+// VerifyDepTree is like the VerifyDepTree function, but takes the digests of
+// the projects in the tree from m where it can, keyed by the slash-separated
+// paths of the projects beneath osDirname.
+func (m *DigestMemo) VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]VendorStatus, error) {
+	return verifyDepTree(osDirname, wantSums, m.Digest)
+}
+
+// Save writes m to its file, if anything was memoized since it was read.
+func (m *DigestMemo) Save() error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(m.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0777); err != nil {
+		return errors.Wrap(err, "failed to create directory for digest memo")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(m.path), filepath.Base(m.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return err
+	}
+	m.dirty = false
+	return nil
+}
+
+// load reads m's file, if it was not read yet. A missing or unreadable file
+// leaves m empty, as everything in the memo can be computed again. The caller
+// must hold m.mu.
+func (m *DigestMemo) load() {
+	if m.loaded {
+		return
+	}
+	m.loaded = true
+	m.entries = make(map[string]digestMemoEntry)
+	if b, err := ioutil.ReadFile(m.path); err == nil {
+		if err := json.Unmarshal(b, &m.entries); err != nil {
+			m.entries = make(map[string]digestMemoEntry)
+		}
+	}
+}
+
+// FingerprintDirectory returns a hash of the metadata of the nodes in the tree
+// at osDirname that DigestFromDirectory hashes, and whether any of them was
+// modified too recently for the fingerprint to be trusted to change with them.
+// Walking the tree only takes reading its directories, so a fingerprint is
+// cheap to take, and anything derived from the contents of the tree can be
+// memoized along with it.
+func FingerprintDirectory(osDirname string) (string, bool, error) {
+	osDirname = filepath.Clean(osDirname)
+	h := sha256.New()
+	if fi, err := os.Lstat(osDirname); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		resolved, err := filepath.EvalSymlinks(osDirname)
+		if err != nil {
+			return "", false, errors.Wrap(err, "cannot resolve symlink")
+		}
+		osDirname = resolved
+		fmt.Fprintf(h, "-> %s\n", resolved)
+	}
+
+	recent := time.Now().Add(-digestMemoRacyWindow)
+	var racy bool
+	err := DirWalk(osDirname, func(osPathname string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(osDirname, osPathname)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			switch filepath.Base(rel) {
+			case "vendor", ".bzr", ".git", ".hg", ".svn":
+				return filepath.SkipDir
+			}
+		}
+		if info.ModTime().After(recent) {
+			racy = true
+		}
+		fmt.Fprintf(h, "%s %d %d %d\n", filepath.ToSlash(rel), info.Mode(), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), racy, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkgtree
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDigestMemo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digestmemo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vendor := filepath.Join(dir, "vendor")
+	proj := filepath.Join(vendor, "github.com", "foo", "bar")
+	file := filepath.Join(proj, "bar.go")
+	if err := os.MkdirAll(proj, 0777); err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string, mtime time.Time) {
+		if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{file, proj} {
+			if err := os.Chtimes(p, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	write("package bar\n", old)
+	want, err := DigestFromDirectory(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mpath := filepath.Join(dir, "memo", "digests.json")
+	memo := NewDigestMemo(mpath)
+	got, err := memo.Digest("github.com/foo/bar", proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected the digest computed by DigestFromDirectory, got %x", got)
+	}
+	if err := memo.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the file without changing its size or modification time
+	// leaves the fingerprint unchanged, so the memoized digest is used.
+	write("package baz\n", old)
+	memo = NewDigestMemo(mpath)
+	status, err := memo.VerifyDepTree(vendor, map[string][]byte{"github.com/foo/bar": want})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["github.com/foo/bar"] != NoMismatch {
+		t.Errorf("expected the memoized digest to be used, got %s", status["github.com/foo/bar"])
+	}
+
+	// Changing the modification time changes the fingerprint.
+	write("package baz\n", old.Add(time.Minute))
+	got, err = memo.Digest("github.com/foo/bar", proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got, want) {
+		t.Error("expected the digest to be computed again once the fingerprint changed")
+	}
+
+	// Trees modified too recently to trust their fingerprints are not
+	// memoized.
+	write("package bar\n", time.Now())
+	if _, err := memo.Digest("github.com/foo/bar", proj); err != nil {
+		t.Fatal(err)
+	}
+	memo.mu.Lock()
+	_, has := memo.entries["github.com/foo/bar"]
+	memo.mu.Unlock()
+	if has {
+		t.Error("expected a recently modified tree not to be memoized")
+	}
+
+	// A nil memo memoizes nothing, and computes digests as usual.
+	var nilMemo *DigestMemo
+	if got, err := nilMemo.Digest("github.com/foo/bar", proj); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("expected a nil memo to compute the digest, got %x, %v", got, err)
+	}
+	if err := nilMemo.Save(); err != nil {
+		t.Error(err)
+	}
+}
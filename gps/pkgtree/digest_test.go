@@ -7,8 +7,10 @@ package pkgtree
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -218,3 +220,104 @@ func BenchmarkVerifyDepTree(b *testing.B) {
 		}
 	}
 }
+
+func TestCopyDigestFromDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	files := map[string]string{
+		"a.go":              "package a\r\n",
+		"b/b.go":            "package b\n",
+		"b/c/README":        "\r\n\r\n",
+		"vendor/d/d.go":     "package d\n",
+		"b/c/.git/HEAD":     "ref: refs/heads/master\n",
+		"b/empty/.gitkeep":  "",
+		"b/c/large.txt":     string(bytes.Repeat([]byte("dep\r\n"), 10000)),
+		"b/c/executable.sh": "#!/bin/sh\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(src, "b/c/executable.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("b.go", filepath.Join(src, "b/link.go")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(t *testing.T, dst string) {
+		want, err := DigestFromDirectory(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CopyDigestFromDirectory(src, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("expected digest %x, got %x", want, got)
+		}
+		copied, err := DigestFromDirectory(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(copied, want) {
+			t.Errorf("expected the copy to have digest %x, got %x", want, copied)
+		}
+
+		for name, contents := range files {
+			path := filepath.Join(src, filepath.FromSlash(name))
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != contents {
+				t.Errorf("expected %s to be copied as it is", name)
+			}
+		}
+		sfi, err := os.Stat(filepath.Join(src, "b/c/executable.sh"))
+		if err == nil {
+			dfi, err := os.Stat(filepath.Join(dst, "b/c/executable.sh"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sfi.Mode() != dfi.Mode() {
+				t.Errorf("expected the mode %s to be copied, got %s", sfi.Mode(), dfi.Mode())
+			}
+		}
+
+		if _, err := CopyDigestFromDirectory(src, dst); err == nil {
+			t.Error("expected an error copying to an existing directory")
+		}
+	}
+
+	t.Run("copied while hashed", func(t *testing.T) {
+		check(t, filepath.Join(dir, "copy"))
+	})
+
+	// A file named as a VCS directory is neither hashed nor copied with the
+	// rest of the tree in one walk.
+	t.Run("copied and hashed apart", func(t *testing.T) {
+		files[".git"] = "gitdir: elsewhere\n"
+		defer delete(files, ".git")
+		if err := ioutil.WriteFile(filepath.Join(src, ".git"), []byte(files[".git"]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		check(t, filepath.Join(dir, "copy2"))
+	})
+}
@@ -14,12 +14,19 @@ import (
 	"go/token"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode"
+
+	"github.com/pkg/errors"
 )
 
+// analysisWorkers is the number of directories ListPackages parses at once.
+var analysisWorkers = runtime.GOMAXPROCS(0)
+
 // Package represents a Go package. It contains a subset of the information
 // go/build.Package does.
 type Package struct {
@@ -73,6 +80,44 @@ func ListPackages(fileRoot, importRoot string) (PackageTree, error) {
 		return PackageTree{}, err
 	}
 
+	// Directories are found by walking the tree in order, and handed to a
+	// fixed number of workers to be parsed. The queue between them is
+	// bounded, so that no more than a few directories' worth of work is held
+	// at once, however large the tree.
+	type queuedDir struct {
+		i  int
+		wp string
+	}
+	queue := make(chan queuedDir, analysisWorkers)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+		// The error of the first directory, in the order of the walk, that
+		// could not be analyzed, so that the same error is returned however
+		// the work was scheduled.
+		failedAt   int
+		analyzeErr error
+	)
+	for w := 0; w < analysisWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range queue {
+				ip, poe, err := listPackage(fileRoot, importRoot, d.wp)
+				mu.Lock()
+				if err != nil {
+					if analyzeErr == nil || d.i < failedAt {
+						failedAt, analyzeErr = d.i, err
+					}
+				} else {
+					ptree.Packages[ip] = poe
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var queued int
 	err = filepath.Walk(fileRoot, func(wp string, fi os.FileInfo, err error) error {
 		if err != nil && err != filepath.SkipDir {
 			if os.IsPermission(err) {
@@ -84,6 +129,13 @@ func ListPackages(fileRoot, importRoot string) (PackageTree, error) {
 			return nil
 		}
 
+		mu.Lock()
+		failed := analyzeErr != nil
+		mu.Unlock()
+		if failed {
+			return errStopWalk
+		}
+
 		// Skip dirs that are known to hold non-local/dependency code.
 		//
 		// We don't skip _*, or testdata dirs because, while it may be poor
@@ -126,86 +178,96 @@ func ListPackages(fileRoot, importRoot string) (PackageTree, error) {
 			f.Close()
 		}
 
-		// Compute the import path. Run the result through ToSlash(), so that
-		// windows file paths are normalized to slashes, as is expected of
-		// import paths.
-		ip := filepath.ToSlash(filepath.Join(importRoot, strings.TrimPrefix(wp, fileRoot)))
+		queue <- queuedDir{i: queued, wp: wp}
+		queued++
+		return nil
+	})
+	close(queue)
+	wg.Wait()
 
-		// Find all the imports, across all os/arch combos
-		p := &build.Package{
-			Dir:        wp,
-			ImportPath: ip,
-		}
-		err = fillPackage(p)
+	if analyzeErr != nil {
+		return PackageTree{}, analyzeErr
+	}
+	if err != nil {
+		return PackageTree{}, err
+	}
 
-		if err != nil {
-			switch err.(type) {
-			case gscan.ErrorList, *gscan.Error, *build.NoGoError, *ConflictingImportComments:
-				// Assorted cases in which we've encounter malformed or
-				// nonexistent Go source code.
-				ptree.Packages[ip] = PackageOrErr{
-					Err: err,
-				}
-				return nil
-			default:
-				return err
-			}
-		}
+	return ptree, nil
+}
 
-		pkg := Package{
-			ImportPath:  ip,
-			CommentPath: p.ImportComment,
-			Name:        p.Name,
-			Imports:     p.Imports,
-			TestImports: dedupeStrings(p.TestImports, p.XTestImports),
-		}
+// errStopWalk stops the walk of ListPackages once a directory could not be
+// analyzed.
+var errStopWalk = errors.New("stop walking")
+
+// listPackage analyzes the directory wp, within fileRoot, returning the import
+// path of the package in it and the package, or the reason it is not a valid
+// package. An error is returned only if the directory could not be analyzed at
+// all.
+func listPackage(fileRoot, importRoot, wp string) (string, PackageOrErr, error) {
+	// Compute the import path. Run the result through ToSlash(), so that
+	// windows file paths are normalized to slashes, as is expected of
+	// import paths.
+	ip := filepath.ToSlash(filepath.Join(importRoot, strings.TrimPrefix(wp, fileRoot)))
+
+	// Find all the imports, across all os/arch combos
+	p := &build.Package{
+		Dir:        wp,
+		ImportPath: ip,
+	}
+	err := fillPackage(p)
 
-		if pkg.CommentPath != "" && !strings.HasPrefix(pkg.CommentPath, importRoot) {
-			ptree.Packages[ip] = PackageOrErr{
-				Err: &NonCanonicalImportRoot{
-					ImportRoot: importRoot,
-					Canonical:  pkg.CommentPath,
-				},
-			}
-			return nil
+	if err != nil {
+		switch err.(type) {
+		case gscan.ErrorList, *gscan.Error, *build.NoGoError, *ConflictingImportComments:
+			// Assorted cases in which we've encounter malformed or
+			// nonexistent Go source code.
+			return ip, PackageOrErr{Err: err}, nil
+		default:
+			return "", PackageOrErr{}, err
 		}
+	}
 
-		// This area has some...fuzzy rules, but check all the imports for
-		// local/relative/dot-ness, and record an error for the package if we
-		// see any.
-		var lim []string
-		for _, imp := range append(pkg.Imports, pkg.TestImports...) {
-			if build.IsLocalImport(imp) {
-				// Do allow the single-dot, at least for now
-				if imp == "." {
-					continue
-				}
-				lim = append(lim, imp)
-			}
-		}
+	pkg := Package{
+		ImportPath:  ip,
+		CommentPath: p.ImportComment,
+		Name:        p.Name,
+		Imports:     p.Imports,
+		TestImports: dedupeStrings(p.TestImports, p.XTestImports),
+	}
 
-		if len(lim) > 0 {
-			ptree.Packages[ip] = PackageOrErr{
-				Err: &LocalImportsError{
-					Dir:          wp,
-					ImportPath:   ip,
-					LocalImports: lim,
-				},
-			}
-		} else {
-			ptree.Packages[ip] = PackageOrErr{
-				P: pkg,
+	if pkg.CommentPath != "" && !strings.HasPrefix(pkg.CommentPath, importRoot) {
+		return ip, PackageOrErr{
+			Err: &NonCanonicalImportRoot{
+				ImportRoot: importRoot,
+				Canonical:  pkg.CommentPath,
+			},
+		}, nil
+	}
+
+	// This area has some...fuzzy rules, but check all the imports for
+	// local/relative/dot-ness, and record an error for the package if we
+	// see any.
+	var lim []string
+	for _, imp := range append(pkg.Imports, pkg.TestImports...) {
+		if build.IsLocalImport(imp) {
+			// Do allow the single-dot, at least for now
+			if imp == "." {
+				continue
 			}
+			lim = append(lim, imp)
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		return PackageTree{}, err
 	}
 
-	return ptree, nil
+	if len(lim) > 0 {
+		return ip, PackageOrErr{
+			Err: &LocalImportsError{
+				Dir:          wp,
+				ImportPath:   ip,
+				LocalImports: lim,
+			},
+		}, nil
+	}
+	return ip, PackageOrErr{P: pkg}, nil
 }
 
 // fillPackage full of info. Assumes p.Dir is set at a minimum
@@ -255,7 +317,7 @@ func fillPackage(p *build.Package) error {
 			if ic != "" {
 				importComments = append(importComments, ic)
 			}
-			if c.Pos() > pf.Package { // +build comment must come before package
+			if c.Pos() > pf.Package { // build constraints must come before package
 				continue
 			}
 
@@ -1701,6 +1701,30 @@ func TestListPackagesNoPerms(t *testing.T) {
 	}
 }
 
+func TestListPackagesConcurrent(t *testing.T) {
+	srcdir := filepath.Join(getTestdataRootDir(t), "src")
+
+	defer func(n int) { analysisWorkers = n }(analysisWorkers)
+	analysisWorkers = 1
+	want, err := ListPackages(srcdir, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The packages listed do not depend on how many directories are parsed
+	// at once.
+	for _, n := range []int{2, 8, 64} {
+		analysisWorkers = n
+		got, err := ListPackages(srcdir, "src")
+		if err != nil {
+			t.Fatalf("with %d workers: %s", n, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("with %d workers, the packages differ from those listed by one", n)
+		}
+	}
+}
+
 func TestToReachMap(t *testing.T) {
 	// There's enough in the 'varied' test case to test most of what matters
 	vptree, err := ListPackages(filepath.Join(getTestdataRootDir(t), "src", "github.com", "example", "varied"), "github.com/example/varied")
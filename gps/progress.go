@@ -0,0 +1,71 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SourceOperation describes an operation a SourceMgr is running, such as
+// cloning a source, for display to the user.
+type SourceOperation struct {
+	// Name is what the operation works on, usually the URL of a source.
+	Name string
+	// Phase says what the operation is doing, such as "Initializing local
+	// source cache".
+	Phase string
+	// Started is when the operation started.
+	Started time.Time
+	// Bytes is the size of the local copy of the source so far, for
+	// operations that clone or fetch one into the cache, and 0 otherwise.
+	Bytes int64
+}
+
+// Operations returns the operations the SourceMgr is running, oldest first.
+func (sm *SourceMgr) Operations() []SourceOperation {
+	if sm.daemon != nil {
+		return sm.daemon.operations()
+	}
+	sm.suprvsr.mu.Lock()
+	ops := make([]SourceOperation, 0, len(sm.suprvsr.running))
+	var dirs []int
+	for ci, tc := range sm.suprvsr.running {
+		if ci.typ == ctSourceInit || ci.typ == ctSourceFetch {
+			dirs = append(dirs, len(ops))
+		}
+		ops = append(ops, SourceOperation{Name: ci.name, Phase: ci.typ.String(), Started: tc.start})
+	}
+	sm.suprvsr.mu.Unlock()
+
+	// Measure the local copies without holding up the calls being supervised.
+	for _, i := range dirs {
+		path := sourceCachePath(sm.cachedir, ops[i].Name)
+		ops[i].Bytes = dirSize(path) + dirSize(stagingPath(path))
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if !ops[i].Started.Equal(ops[j].Started) {
+			return ops[i].Started.Before(ops[j].Started)
+		}
+		return ops[i].Name < ops[j].Name
+	})
+	return ops
+}
+
+// dirSize returns the total size of the regular files under dir, which may not
+// exist yet.
+func dirSize(dir string) int64 {
+	var n int64
+	filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() {
+			n += fi.Size()
+		}
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceMgrOperations(t *testing.T) {
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	if ops := sm.Operations(); len(ops) != 0 {
+		t.Fatalf("expected no operations on a new SourceMgr, got %v", ops)
+	}
+
+	const u = "https://example.com/foo/bar"
+	dir := sourceCachePath(sm.cachedir, u)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "pack"), make([]byte, 1000), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := callInfo{name: u, typ: ctSourceFetch}
+	list := callInfo{name: u, typ: ctListVersions}
+	if _, err := sm.suprvsr.start(fetch); err != nil {
+		t.Fatal(err)
+	}
+	defer sm.suprvsr.done(fetch)
+	if _, err := sm.suprvsr.start(list); err != nil {
+		t.Fatal(err)
+	}
+	defer sm.suprvsr.done(list)
+
+	ops := sm.Operations()
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 operations, got %v", ops)
+	}
+	if ops[0].Started.After(ops[1].Started) {
+		t.Errorf("expected operations oldest first, got %v", ops)
+	}
+	for _, op := range ops {
+		if op.Name != u {
+			t.Errorf("expected operation on %s, got %s", u, op.Name)
+		}
+		switch op.Phase {
+		case ctSourceFetch.String():
+			if op.Bytes != 1000 {
+				t.Errorf("expected the fetch to have 1000 bytes so far, got %d", op.Bytes)
+			}
+		case ctListVersions.String():
+			if op.Bytes != 0 {
+				t.Errorf("expected no size for listing versions, got %d", op.Bytes)
+			}
+		default:
+			t.Errorf("unexpected operation %v", op)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/golang/dep/internal/fs"
+	depmetrics "github.com/golang/dep/internal/metrics"
 	"github.com/pkg/errors"
 )
 
@@ -30,6 +31,48 @@ const (
 	PruneGoTestFiles
 )
 
+// pruneOptionLetters are the letters standing for each of the PruneOptions in
+// their string form, in the order in which they are written.
+var pruneOptionLetters = []struct {
+	opt    PruneOptions
+	letter byte
+}{
+	{PruneNonGoFiles, 'N'},
+	{PruneUnusedPackages, 'U'},
+	{PruneGoTestFiles, 'T'},
+	{PruneNestedVendorDirs, 'V'},
+}
+
+// String returns the options as a string of letters, one for each option
+// set: N for non-Go files, U for unused packages, T for Go test files, and V
+// for nested vendor directories.
+func (po PruneOptions) String() string {
+	var buf []byte
+	for _, ol := range pruneOptionLetters {
+		if po&ol.opt != 0 {
+			buf = append(buf, ol.letter)
+		}
+	}
+	return string(buf)
+}
+
+// ParsePruneOptions parses the string form of PruneOptions, as returned by
+// PruneOptions.String.
+func ParsePruneOptions(s string) (PruneOptions, error) {
+	var po PruneOptions
+next:
+	for i := 0; i < len(s); i++ {
+		for _, ol := range pruneOptionLetters {
+			if s[i] == ol.letter {
+				po |= ol.opt
+				continue next
+			}
+		}
+		return 0, errors.Errorf("unknown prune option %q in %q", s[i], s)
+	}
+	return po, nil
+}
+
 // PruneOptionSet represents trinary distinctions for each of the types of
 // prune rules (as expressed via PruneOptions): nested vendor directories,
 // unused packages, non-go files, and go test files.
@@ -47,11 +90,21 @@ type PruneOptionSet struct {
 	GoTests        uint8
 }
 
+// PruneGroupSuffix marks the keys of CascadingPruneOptions.PerProjectOptions
+// that name groups of projects, rather than a single project. The options of
+// the group "github.com/aws/*" apply to every project under github.com/aws/.
+const PruneGroupSuffix = "/*"
+
 // CascadingPruneOptions is a set of rules for pruning a dependency tree.
 //
 // The DefaultOptions are the global default pruning rules, expressed as a
 // single PruneOptions bitfield. These global rules will cascade down to
 // individual project rules, unless superseded.
+//
+// PerProjectOptions may also hold the options of groups of projects, keyed by
+// a project root prefix followed by PruneGroupSuffix. Group rules cascade
+// between the global rules and those of the projects they contain, from the
+// shortest prefix to the longest.
 type CascadingPruneOptions struct {
 	DefaultOptions    PruneOptions
 	PerProjectOptions map[ProjectRoot]PruneOptionSet
@@ -60,15 +113,43 @@ type CascadingPruneOptions struct {
 // PruneOptionsFor returns the PruneOptions bits for the given project,
 // indicating which pruning rules should be applied to the project's code.
 //
-// It computes the cascade from default to project-specific options (if any) on
-// the fly.
+// It computes the cascade from default to group and project-specific options
+// (if any) on the fly.
 func (o CascadingPruneOptions) PruneOptionsFor(pr ProjectRoot) PruneOptions {
-	po, has := o.PerProjectOptions[pr]
-	if !has {
-		return o.DefaultOptions
+	ops := o.GroupPruneOptionsFor(pr)
+	if po, has := o.PerProjectOptions[pr]; has {
+		ops = po.apply(ops)
 	}
+	return ops
+}
+
+// GroupPruneOptionsFor returns the PruneOptions bits that the given project
+// inherits from the defaults and from the groups containing it, disregarding
+// any options set for the project itself.
+//
+// For a group, it returns the bits inherited from the groups containing it
+// when called with the group's prefix.
+func (o CascadingPruneOptions) GroupPruneOptionsFor(pr ProjectRoot) PruneOptions {
+	var groups []string
+	for name := range o.PerProjectOptions {
+		pre := strings.TrimSuffix(string(name), PruneGroupSuffix)
+		if pre != string(name) && strings.HasPrefix(string(pr), pre+"/") {
+			groups = append(groups, string(name))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return len(groups[i]) < len(groups[j])
+	})
 
 	ops := o.DefaultOptions
+	for _, name := range groups {
+		ops = o.PerProjectOptions[ProjectRoot(name)].apply(ops)
+	}
+	return ops
+}
+
+// apply returns ops with the rules set in po superseding its bits.
+func (po PruneOptionSet) apply(ops PruneOptions) PruneOptions {
 	if po.NestedVendor != 0 {
 		if po.NestedVendor == 1 {
 			ops |= PruneNestedVendorDirs
@@ -145,30 +226,30 @@ func PruneProject(baseDir string, lp LockedProject, options PruneOptions) error
 	}
 
 	if (options & PruneNestedVendorDirs) != 0 {
-		if err := pruneVendorDirs(fsState); err != nil {
+		if err := pruneVendorDirs(&fsState); err != nil {
 			return errors.Wrapf(err, "failed to prune nested vendor directories")
 		}
 	}
 
 	if (options & PruneUnusedPackages) != 0 {
-		if _, err := pruneUnusedPackages(lp, fsState); err != nil {
+		if _, err := pruneUnusedPackages(lp, &fsState); err != nil {
 			return errors.Wrap(err, "failed to prune unused packages")
 		}
 	}
 
 	if (options & PruneNonGoFiles) != 0 {
-		if err := pruneNonGoFiles(fsState); err != nil {
+		if err := pruneNonGoFiles(&fsState); err != nil {
 			return errors.Wrap(err, "failed to prune non-Go files")
 		}
 	}
 
 	if (options & PruneGoTestFiles) != 0 {
-		if err := pruneGoTestFiles(fsState); err != nil {
+		if err := pruneGoTestFiles(&fsState); err != nil {
 			return errors.Wrap(err, "failed to prune Go test files")
 		}
 	}
 
-	if err := deleteEmptyDirs(fsState); err != nil {
+	if err := deleteEmptyDirs(&fsState); err != nil {
 		return errors.Wrap(err, "could not delete empty dirs")
 	}
 
@@ -176,38 +257,57 @@ func PruneProject(baseDir string, lp LockedProject, options PruneOptions) error
 }
 
 // pruneVendorDirs deletes all nested vendor directories within baseDir.
-func pruneVendorDirs(fsState filesystemState) error {
+func pruneVendorDirs(fsState *filesystemState) error {
+	gone := make(map[string]bool)
 	for _, dir := range fsState.dirs {
-		if filepath.Base(dir) == "vendor" {
-			err := os.RemoveAll(filepath.Join(fsState.root, dir))
-			if err != nil && !os.IsNotExist(err) {
-				return err
+		if filepath.Base(dir) != "vendor" {
+			continue
+		}
+		// Directories come before those beneath them, so a vendor directory
+		// nested in another is already gone with it.
+		if fsState.isGone(gone, dir) {
+			continue
+		}
+
+		err := fs.RemoveAll(filepath.Join(fsState.root, dir))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		gone[dir] = true
+
+		var removed int64
+		prefix := dir + string(filepath.Separator)
+		for _, path := range fsState.files {
+			if strings.HasPrefix(path, prefix) {
+				removed++
 			}
 		}
+		depmetrics.Add(depmetrics.FilesPruned, removed)
 	}
 
 	for _, link := range fsState.links {
-		if filepath.Base(link.path) == "vendor" {
+		if filepath.Base(link.path) == "vendor" && !fsState.isGone(gone, link.path) {
 			err := os.Remove(filepath.Join(fsState.root, link.path))
 			if err != nil && !os.IsNotExist(err) {
 				return err
 			}
+			gone[link.path] = true
 		}
 	}
 
+	fsState.emitPruned(fsState.files, gone)
+	fsState.forget(gone)
 	return nil
 }
 
 // pruneUnusedPackages deletes unimported packages found in fsState.
 // Determining whether packages are imported or not is based on the passed LockedProject.
-func pruneUnusedPackages(lp LockedProject, fsState filesystemState) (map[string]interface{}, error) {
-	unusedPackages := calculateUnusedPackages(lp, fsState)
-	toDelete := collectUnusedPackagesFiles(fsState, unusedPackages)
+func pruneUnusedPackages(lp LockedProject, fsState *filesystemState) (map[string]interface{}, error) {
+	unusedPackages := calculateUnusedPackages(lp, *fsState)
+	toDelete := collectUnusedPackagesFiles(*fsState, unusedPackages)
 
-	for _, path := range toDelete {
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-			return nil, err
-		}
+	if err := fsState.removeFiles(toDelete); err != nil {
+		return nil, err
 	}
 
 	return unusedPackages, nil
@@ -239,7 +339,7 @@ func calculateUnusedPackages(lp LockedProject, fsState filesystemState) map[stri
 }
 
 // collectUnusedPackagesFiles returns a slice of all files in the unused
-// packages based on fsState.
+// packages based on fsState, relative to its root.
 func collectUnusedPackagesFiles(fsState filesystemState, unusedPackages map[string]interface{}) []string {
 	// TODO(ibrasho): is this useful?
 	files := make([]string, 0, len(unusedPackages))
@@ -253,7 +353,7 @@ func collectUnusedPackagesFiles(fsState filesystemState, unusedPackages map[stri
 		pkg := filepath.ToSlash(filepath.Dir(path))
 
 		if _, ok := unusedPackages[pkg]; ok {
-			files = append(files, filepath.Join(fsState.root, path))
+			files = append(files, path)
 		}
 	}
 
@@ -294,7 +394,7 @@ func isSourceFile(path string) bool {
 // pruneNonGoFiles delete all non-Go files existing in fsState.
 //
 // Files matching licenseFilePrefixes and legalFileSubstrings are not pruned.
-func pruneNonGoFiles(fsState filesystemState) error {
+func pruneNonGoFiles(fsState *filesystemState) error {
 	toDelete := make([]string, 0, len(fsState.files)/4)
 
 	for _, path := range fsState.files {
@@ -307,16 +407,17 @@ func pruneNonGoFiles(fsState filesystemState) error {
 			continue
 		}
 
-		toDelete = append(toDelete, filepath.Join(fsState.root, path))
+		toDelete = append(toDelete, path)
 	}
 
-	for _, path := range toDelete {
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-			return err
-		}
-	}
+	return fsState.removeFiles(toDelete)
+}
 
-	return nil
+// IsLegalFile reports whether the file named name is a license file, or
+// another legal declaration, such as a NOTICE or AUTHORS file, which pruning
+// never removes.
+func IsLegalFile(name string) bool {
+	return isPreservedFile(name)
 }
 
 // isPreservedFile checks if the file name indicates that the file should be
@@ -345,42 +446,79 @@ func isPreservedFile(name string) bool {
 }
 
 // pruneGoTestFiles deletes all Go test files (*_test.go) in fsState.
-func pruneGoTestFiles(fsState filesystemState) error {
+func pruneGoTestFiles(fsState *filesystemState) error {
 	toDelete := make([]string, 0, len(fsState.files)/2)
 
 	for _, path := range fsState.files {
 		if strings.HasSuffix(path, "_test.go") {
-			toDelete = append(toDelete, filepath.Join(fsState.root, path))
+			toDelete = append(toDelete, path)
 		}
 	}
 
-	for _, path := range toDelete {
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-			return err
-		}
-	}
-
-	return nil
+	return fsState.removeFiles(toDelete)
 }
 
-func deleteEmptyDirs(fsState filesystemState) error {
-	sort.Sort(sort.Reverse(sort.StringSlice(fsState.dirs)))
+// deleteEmptyDirs removes the directories of fsState that are empty, deepest
+// first, so that those left empty by the removal of their own are removed too.
+// Whether a directory is empty is told from fsState, rather than by reading
+// it. The directories at each depth are removed from several goroutines at
+// once.
+func deleteEmptyDirs(fsState *filesystemState) error {
+	// How many nodes each directory holds.
+	children := make(map[string]int, len(fsState.dirs))
+	hold := func(path string) {
+		if parent := filepath.Dir(path); parent != "." {
+			children[parent]++
+		}
+	}
 
+	byDepth := make(map[int][]string)
+	var maxDepth int
 	for _, dir := range fsState.dirs {
-		path := filepath.Join(fsState.root, dir)
+		hold(dir)
+		depth := strings.Count(dir, string(filepath.Separator))
+		byDepth[depth] = append(byDepth[depth], dir)
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	for _, file := range fsState.files {
+		hold(file)
+	}
+	for _, link := range fsState.links {
+		hold(link.path)
+	}
 
-		notEmpty, err := fs.IsNonEmptyDir(path)
+	gone := make(map[string]bool)
+	for depth := maxDepth; depth >= 0; depth-- {
+		var empty []string
+		for _, dir := range byDepth[depth] {
+			if children[dir] == 0 {
+				empty = append(empty, dir)
+			}
+		}
+
+		err := fs.ForEach(len(empty), func(i int) error {
+			err := os.Remove(filepath.Join(fsState.root, empty[i]))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		})
 		if err != nil {
+			fsState.forget(gone)
 			return err
 		}
 
-		if !notEmpty {
-			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-				return err
+		for _, dir := range empty {
+			gone[dir] = true
+			if parent := filepath.Dir(dir); parent != "." {
+				children[parent]--
 			}
 		}
 	}
 
+	fsState.forget(gone)
 	return nil
 }
 
@@ -5,10 +5,15 @@
 package gps
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/golang/dep/internal/events"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -89,6 +94,31 @@ func TestCascadingPruneOptions(t *testing.T) {
 				ProjectRoot("not/there"):             PruneNestedVendorDirs,
 			},
 		},
+		{
+			name: "project groups",
+			co: CascadingPruneOptions{
+				DefaultOptions: PruneNestedVendorDirs,
+				PerProjectOptions: map[ProjectRoot]PruneOptionSet{
+					ProjectRoot("github.com/*"): {
+						UnusedPackages: 1,
+					},
+					ProjectRoot("github.com/aws/*"): {
+						NonGoFiles: 1,
+						GoTests:    1,
+					},
+					ProjectRoot("github.com/aws/aws-sdk-go"): {
+						GoTests: 2,
+					},
+				},
+			},
+			results: map[ProjectRoot]PruneOptions{
+				ProjectRoot("github.com/aws/aws-sdk-go"): PruneNestedVendorDirs | PruneUnusedPackages | PruneNonGoFiles,
+				ProjectRoot("github.com/aws/other"):      PruneNestedVendorDirs | PruneUnusedPackages | PruneNonGoFiles | PruneGoTestFiles,
+				ProjectRoot("github.com/awsome/thing"):   PruneNestedVendorDirs | PruneUnusedPackages,
+				ProjectRoot("github.com/aws"):            PruneNestedVendorDirs | PruneUnusedPackages,
+				ProjectRoot("golang.org/x/net"):          PruneNestedVendorDirs,
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -103,6 +133,29 @@ func TestCascadingPruneOptions(t *testing.T) {
 	}
 }
 
+func TestPruneOptionsString(t *testing.T) {
+	cases := []struct {
+		po PruneOptions
+		s  string
+	}{
+		{0, ""},
+		{PruneNestedVendorDirs, "V"},
+		{PruneNonGoFiles | PruneUnusedPackages | PruneGoTestFiles, "NUT"},
+		{PruneNestedVendorDirs | PruneUnusedPackages | PruneNonGoFiles | PruneGoTestFiles, "NUTV"},
+	}
+	for _, c := range cases {
+		if got := c.po.String(); got != c.s {
+			t.Errorf("(%d).String() = %q, want %q", uint8(c.po), got, c.s)
+		}
+		if got, err := ParsePruneOptions(c.s); err != nil || got != c.po {
+			t.Errorf("ParsePruneOptions(%q) = %d, %v, want %d", c.s, uint8(got), err, uint8(c.po))
+		}
+	}
+	if _, err := ParsePruneOptions("NX"); err == nil {
+		t.Error("expected an error parsing an unknown prune option")
+	}
+}
+
 func TestPruneProject(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -234,12 +287,54 @@ func TestPruneUnusedPackages(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"unused-tree",
+			LockedProject{
+				pi: pi,
+				pkgs: []string{
+					".",
+					"pkg",
+				},
+			},
+			fsTestCase{
+				before: filesystemState{
+					dirs: []string{
+						"cmd",
+						"cmd/tool",
+						"cmd/tool/internal",
+						"cmd/tool/testdata",
+						"pkg",
+						"pkg/unused",
+					},
+					files: []string{
+						"main.go",
+						"cmd/tool/main.go",
+						"cmd/tool/internal/util.go",
+						"pkg/main.go",
+						"pkg/unused/main.go",
+						"pkg/unused/LICENSE",
+					},
+				},
+				after: filesystemState{
+					dirs: []string{
+						"pkg",
+						"pkg/unused",
+					},
+					files: []string{
+						"main.go",
+						"pkg/main.go",
+						"pkg/unused/LICENSE",
+					},
+				},
+			},
+			false,
+		},
 	}
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
-			h.TempDir(pr)
-			baseDir := h.Path(pr)
+			h.TempDir(filepath.Join(tc.name, pr))
+			baseDir := h.Path(filepath.Join(tc.name, pr))
 			tc.fs.before.root = baseDir
 			tc.fs.after.root = baseDir
 			tc.fs.setup(t)
@@ -249,7 +344,7 @@ func TestPruneUnusedPackages(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			_, err = pruneUnusedPackages(tc.lp, fs)
+			_, err = pruneUnusedPackages(tc.lp, &fs)
 			if tc.err && err == nil {
 				t.Fatalf("expected an error, got nil")
 			} else if !tc.err && err != nil {
@@ -257,6 +352,7 @@ func TestPruneUnusedPackages(t *testing.T) {
 			}
 
 			tc.fs.assert(t)
+			tc.fs.assertState(t, fs)
 		})
 	}
 }
@@ -344,7 +440,7 @@ func TestPruneNonGoFiles(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			err = pruneNonGoFiles(fs)
+			err = pruneNonGoFiles(&fs)
 			if tc.err && err == nil {
 				t.Errorf("expected an error, got nil")
 			} else if !tc.err && err != nil {
@@ -352,6 +448,7 @@ func TestPruneNonGoFiles(t *testing.T) {
 			}
 
 			tc.fs.assert(t)
+			tc.fs.assertState(t, fs)
 		})
 	}
 }
@@ -391,11 +488,8 @@ func TestPruneGoTestFiles(t *testing.T) {
 						"dir/main2_test.go",
 					},
 				},
-				after: filesystemState{
-					dirs: []string{
-						"dir",
-					},
-				},
+				// Directories left with no files are removed whole.
+				after: filesystemState{},
 			},
 			false,
 		},
@@ -441,7 +535,7 @@ func TestPruneGoTestFiles(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			err = pruneGoTestFiles(fs)
+			err = pruneGoTestFiles(&fs)
 			if tc.err && err == nil {
 				t.Fatalf("expected an error, got nil")
 			} else if !tc.err && err != nil {
@@ -449,10 +543,59 @@ func TestPruneGoTestFiles(t *testing.T) {
 			}
 
 			tc.fs.assert(t)
+			tc.fs.assertState(t, fs)
 		})
 	}
 }
 
+func TestPruneEmitsFilePruned(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir(".")
+	baseDir := h.Path(".")
+	fstc := fsTestCase{
+		before: filesystemState{
+			root: baseDir,
+			dirs: []string{"dir"},
+			files: []string{
+				"dir/main.go",
+				"dir/main_test.go",
+			},
+		},
+	}
+	fstc.setup(t)
+
+	fs, err := deriveFilesystemState(baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	events.SetDefault(events.NewStream(&buf))
+	defer events.SetDefault(nil)
+	if err := pruneGoTestFiles(&fs); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var e events.Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != events.FilePruned {
+			t.Errorf("unexpected %s event", e.Type)
+		}
+		got = append(got, e.Path)
+	}
+	want := filepath.Join(baseDir, "dir", "main_test.go")
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("expected a %s event for %s, got events for %v", events.FilePruned, want, got)
+	}
+}
+
 func TestPruneVendorDirs(t *testing.T) {
 	tests := []struct {
 		name string
@@ -474,6 +617,32 @@ func TestPruneVendorDirs(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "vendor directory in vendor directory",
+			test: fsTestCase{
+				before: filesystemState{
+					dirs: []string{
+						"package",
+						"package/vendor",
+						"package/vendor/dep",
+						"package/vendor/dep/vendor",
+					},
+					files: []string{
+						"package/main.go",
+						"package/vendor/dep/dep.go",
+						"package/vendor/dep/vendor/dep.go",
+					},
+				},
+				after: filesystemState{
+					dirs: []string{
+						"package",
+					},
+					files: []string{
+						"package/main.go",
+					},
+				},
+			},
+		},
 		{
 			name: "vendor file",
 			test: fsTestCase{
@@ -685,11 +854,12 @@ func pruneVendorDirsTestCase(tc fsTestCase) func(*testing.T) {
 			t.Fatalf("deriveFilesystemState failed: %s", err)
 		}
 
-		if err := pruneVendorDirs(fs); err != nil {
+		if err := pruneVendorDirs(&fs); err != nil {
 			t.Errorf("pruneVendorDirs err=%q", err)
 		}
 
 		tc.assert(t)
+		tc.assertState(t, fs)
 	}
 }
 
@@ -779,11 +949,12 @@ func TestDeleteEmptyDirs(t *testing.T) {
 				t.Fatal("unexpected error in fs setup: ", err)
 			}
 
-			if err := deleteEmptyDirs(tc.fs.before); err != nil {
+			if err := deleteEmptyDirs(&tc.fs.before); err != nil {
 				t.Fatal("unexpected error in deleteEmptyDirs: ", err)
 			}
 
 			tc.fs.assert(t)
+			tc.fs.assertState(t, tc.fs.before)
 		})
 	}
 }
@@ -0,0 +1,171 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/internal/events"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// redirectsName is the name of the file, in the cache directory, recording
+// the repositories and import paths found to have moved.
+const redirectsName = "redirects.json"
+
+// A redirect records that a repository, or the root of an import path, has
+// moved permanently, as its host said when it was last reached.
+type redirect struct {
+	To     string    `json:"to"`     // Where it moved to.
+	Source string    `json:"source"` // The source to set for it in manifests.
+	Seen   time.Time `json:"seen"`
+}
+
+// redirectLog records the repositories and import paths that have moved, so
+// that they are reached at their new locations from then on, and warns of
+// them, once per process, so that users can make the move explicit in their
+// manifests. A nil *redirectLog records nothing.
+type redirectLog struct {
+	path   string // The file the log is kept in.
+	logger logging.Logger
+
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]redirect
+	warned  map[string]bool
+}
+
+func newRedirectLog(cachedir string, logger logging.Logger) *redirectLog {
+	return &redirectLog{
+		path:   filepath.Join(cachedir, redirectsName),
+		logger: logger,
+		warned: make(map[string]bool),
+	}
+}
+
+// lookup returns where from, a repository URL or the root of an import path,
+// has moved to, if it has.
+func (rl *redirectLog) lookup(from string) (string, bool) {
+	if rl == nil {
+		return "", false
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.load()
+
+	rd, has := rl.entries[from]
+	if has {
+		rl.warn(from, rd)
+	}
+	return rd.To, has
+}
+
+// record records that from has moved to to, and that source should be set
+// for it in manifests.
+func (rl *redirectLog) record(from, to, source string) {
+	if rl == nil || from == to {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.load()
+
+	rd := redirect{To: to, Source: source, Seen: time.Now()}
+	rl.entries[from] = rd
+	rl.warn(from, rd)
+	if err := rl.save(); err != nil {
+		rl.logger.Warnf("%s", errors.Wrap(err, "failed to record a redirect"))
+	}
+}
+
+// warn warns that from has moved, unless it has already done so. The caller
+// must hold rl.mu.
+func (rl *redirectLog) warn(from string, rd redirect) {
+	if rl.warned[from] {
+		return
+	}
+	rl.warned[from] = true
+	msg := fmt.Sprintf("%s has moved to %s, and is fetched from there. To make the move explicit, set source = %q for it in the manifest.", from, rd.To, rd.Source)
+	rl.logger.Warnf("%s", msg)
+	events.Warn(msg)
+}
+
+// load reads the log file, if it has not been read already. A missing or
+// unreadable file leaves the log empty. The caller must hold rl.mu.
+func (rl *redirectLog) load() {
+	if rl.loaded {
+		return
+	}
+	rl.loaded = true
+	rl.entries = make(map[string]redirect)
+
+	data, err := ioutil.ReadFile(rl.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			rl.logger.Warnf("%s", errors.Wrap(err, "failed to read the recorded redirects"))
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &rl.entries); err != nil {
+		rl.logger.Warnf("%s", errors.Wrapf(err, "ignoring corrupt redirects file %s", rl.path))
+		rl.entries = make(map[string]redirect)
+	}
+}
+
+// save writes the log file. The caller must hold rl.mu.
+func (rl *redirectLog) save() error {
+	data, err := json.MarshalIndent(rl.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(rl.path), redirectsName)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return fs.RenameWithFallback(tmp.Name(), rl.path)
+}
+
+// gitRedirectPrefix begins the warning git writes when the remote it was asked
+// for redirects it elsewhere.
+const gitRedirectPrefix = "warning: redirecting to "
+
+// gitRedirect returns the URL to which git was redirected from the remote
+// from, according to its output out, if it was moved elsewhere. Redirects that
+// only change the scheme, as from http to https, are not moves.
+func gitRedirect(out []byte, from string) (string, bool) {
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, gitRedirectPrefix) {
+			continue
+		}
+		to := strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(line, gitRedirectPrefix)), "/")
+		return to, withoutScheme(to) != withoutScheme(strings.TrimSuffix(from, "/"))
+	}
+	return "", false
+}
+
+// withoutScheme returns the URL u without its scheme.
+func withoutScheme(u string) string {
+	if i := strings.Index(u, "://"); i >= 0 {
+		return u[i+3:]
+	}
+	return u
+}
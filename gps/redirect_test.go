@@ -0,0 +1,157 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestGitRedirect(t *testing.T) {
+	cases := []struct {
+		out, from, to string
+		moved         bool
+	}{
+		{"abc\tHEAD\n", "https://example.com/old/repo", "", false},
+		{"warning: redirecting to https://example.com/new/repo/\nabc\tHEAD\n", "https://example.com/old/repo", "https://example.com/new/repo", true},
+		{"warning: redirecting to https://example.com/old/repo/\n", "http://example.com/old/repo", "https://example.com/old/repo", false},
+	}
+	for _, c := range cases {
+		to, moved := gitRedirect([]byte(c.out), c.from)
+		if to != c.to || moved != c.moved {
+			t.Errorf("gitRedirect(%q, %q) = %q, %v; want %q, %v", c.out, c.from, to, moved, c.to, c.moved)
+		}
+	}
+}
+
+func TestRedirectLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redirects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logger := logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug})
+
+	rl := newRedirectLog(dir, logger)
+	if _, has := rl.lookup("https://example.com/old/repo"); has {
+		t.Fatal("expected nothing to have moved yet")
+	}
+	rl.record("https://example.com/old/repo", "https://example.com/new/repo", "https://example.com/new/repo")
+
+	// A later process finds the move recorded.
+	rl = newRedirectLog(dir, logger)
+	if to, has := rl.lookup("https://example.com/old/repo"); !has || to != "https://example.com/new/repo" {
+		t.Errorf("expected the move to be recorded, got %q, %v", to, has)
+	}
+
+	var nilrl *redirectLog
+	nilrl.record("a", "b", "b")
+	if _, has := nilrl.lookup("a"); has {
+		t.Error("expected a nil redirect log to record nothing")
+	}
+}
+
+func TestGetMetadataRedirected(t *testing.T) {
+	var host string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/old/"):
+			http.Redirect(w, r, strings.Replace(r.URL.String(), "/old/", "/new/", 1), http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, "/temp/"):
+			http.Redirect(w, r, strings.Replace(r.URL.String(), "/temp/", "/new/", 1), http.StatusFound)
+		default:
+			fmt.Fprintf(w, `<meta name="go-import" content="%s/new/pkg git https://git.example.com/new/pkg">`, host)
+		}
+	}))
+	defer srv.Close()
+	host = strings.TrimPrefix(srv.URL, "http://")
+
+	gi, moved, err := getMetadata(context.Background(), host+"/old/pkg/sub", "http", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GoImport{Root: host + "/old/pkg", VCS: "git", RepoRoot: "https://git.example.com/new/pkg"}
+	if gi != want || moved != host+"/new/pkg" {
+		t.Errorf("unexpected metadata for a moved import path:\n\t(GOT): %+v, %q\n\t(WNT): %+v, %q", gi, moved, want, host+"/new/pkg")
+	}
+
+	if _, _, err := getMetadata(context.Background(), host+"/temp/pkg", "http", nil); err == nil {
+		t.Error("expected metadata found through a temporary redirect not to be used for the import path")
+	}
+}
+
+func TestGitSourceFollowsRedirect(t *testing.T) {
+	requiresBins(t, "git")
+
+	dir, err := ioutil.TempDir("", "redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Serve a bare repository over git's dumb HTTP protocol, at its new
+	// location, redirecting requests for its old one.
+	upstream := filepath.Join(dir, "upstream")
+	gitTestUpstream(t, upstream)
+	bare := filepath.Join(dir, "www", "new", "repo.git")
+	runGit(t, dir, "clone", "--bare", upstream, bare)
+	runGit(t, bare, "update-server-info")
+	files := http.FileServer(http.Dir(filepath.Join(dir, "www")))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/old/") {
+			http.Redirect(w, r, strings.Replace(r.URL.String(), "/old/", "/new/", 1), http.StatusMovedPermanently)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	cachedir := filepath.Join(dir, "cache")
+	if err := os.MkdirAll(filepath.Join(cachedir, "sources"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(srv.URL + "/old/repo.git")
+	ctx := context.Background()
+	src, err := maybeGitSource{url: u}.try(ctx, cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug})
+	src.(redirectFollower).setRedirectLog(newRedirectLog(dir, logger))
+
+	if _, err := src.listVersions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if to, has := newRedirectLog(dir, logger).lookup(u.String()); !has || to != srv.URL+"/new/repo.git" {
+		t.Fatalf("expected the move to be recorded, got %q, %v", to, has)
+	}
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A source created later is reached at the new location from the start.
+	src, err = maybeGitSource{url: u}.try(ctx, cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(redirectFollower).setRedirectLog(newRedirectLog(dir, logger))
+	if moved := src.(*gitSource).repo.(*gitRepo).moved; moved != srv.URL+"/new/repo.git" {
+		t.Errorf("expected the source to be reached at its new location, got %q", moved)
+	}
+	if _, err := src.listVersions(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
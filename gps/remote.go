@@ -0,0 +1,171 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// remoteConfig holds the settings for reaching upstream hosts, shared by the
+// requests for go-get metadata and by the VCS commands run against sources. A
+// nil *remoteConfig reaches every host with the defaults.
+type remoteConfig struct {
+	// creds maps hosts to the credentials used to authenticate to them over
+	// HTTPS.
+	creds map[string]Credentials
+
+	// proxy, if set, selects the proxy for each host, as
+	// SourceManagerConfig.Proxy.
+	proxy func(host string) (*url.URL, bool)
+
+	// versionAPI is true if the versions of git sources on hosts with known
+	// APIs are listed through them, as SourceManagerConfig.VersionAPI.
+	versionAPI bool
+
+	// sumdb, if set, is the checksum database against which the contents of
+	// module versions are checked, as SourceManagerConfig.ChecksumDB.
+	sumdb *checksumDB
+
+	clientOnce sync.Once
+	httpClient *http.Client
+}
+
+func newRemoteConfig(c SourceManagerConfig, sumdb *checksumDB) *remoteConfig {
+	if len(c.Credentials) == 0 && c.Proxy == nil && !c.VersionAPI && sumdb == nil {
+		return nil
+	}
+	return &remoteConfig{
+		creds:      c.Credentials,
+		proxy:      c.Proxy,
+		versionAPI: c.VersionAPI,
+		sumdb:      sumdb,
+	}
+}
+
+// checksDB returns true if the contents of module versions are checked against
+// a checksum database.
+func (rc *remoteConfig) checksDB() bool {
+	return rc != nil && rc.sumdb != nil
+}
+
+// checkSum checks sum, the hash of the contents of version of module, against
+// the checksum database, if there is one.
+func (rc *remoteConfig) checkSum(ctx context.Context, module, version, sum string) error {
+	if !rc.checksDB() {
+		return nil
+	}
+	return rc.sumdb.check(ctx, rc, module, version, sum)
+}
+
+// useVersionAPI returns true if the versions of git sources are listed
+// through the APIs of their hosts, where those are known.
+func (rc *remoteConfig) useVersionAPI() bool {
+	return rc != nil && rc.versionAPI
+}
+
+// credentials returns the credentials for host, if there are any.
+func (rc *remoteConfig) credentials(host string) (Credentials, bool) {
+	if rc == nil {
+		return Credentials{}, false
+	}
+	c, has := rc.creds[host]
+	return c, has
+}
+
+// header returns the HTTP header, name and value, that carries c.
+func (c Credentials) header() (string, string) {
+	if c.Header != "" {
+		return c.Header, c.Password
+	}
+	return "Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+}
+
+// authorize adds the credentials for the host of req to it, if there are any
+// and req is made over HTTPS. Credentials are never sent in the clear.
+func (rc *remoteConfig) authorize(req *http.Request) {
+	if req.URL.Scheme != "https" {
+		return
+	}
+	if c, has := rc.credentials(req.URL.Host); has {
+		req.Header.Set(c.header())
+	}
+}
+
+// proxyFor returns the proxy through which to reach host, and whether one is
+// configured for it. A nil URL with true means host is reached directly.
+func (rc *remoteConfig) proxyFor(host string) (*url.URL, bool) {
+	if rc == nil || rc.proxy == nil {
+		return nil, false
+	}
+	return rc.proxy(host)
+}
+
+// client returns the HTTP client with which to make requests. It uses the
+// configured proxies, and those set in the environment for any other host.
+func (rc *remoteConfig) client() *http.Client {
+	if rc == nil || rc.proxy == nil {
+		return http.DefaultClient
+	}
+
+	rc.clientOnce.Do(func() {
+		// The settings, other than Proxy, match http.DefaultTransport.
+		rc.httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy: func(req *http.Request) (*url.URL, error) {
+					if u, has := rc.proxyFor(req.URL.Host); has {
+						return u, nil
+					}
+					return http.ProxyFromEnvironment(req)
+				},
+				DialContext: (&net.Dialer{
+					Timeout:   30 * time.Second,
+					KeepAlive: 30 * time.Second,
+				}).DialContext,
+				MaxIdleConns:          100,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ExpectContinueTimeout: 1 * time.Second,
+			},
+		}
+	})
+	return rc.httpClient
+}
+
+// gitConfig returns the git configuration, as key/value pairs, for commands
+// run against remote. Each setting is scoped to the remote's host, so that it
+// does not carry over to other hosts, such as those of submodules.
+func (rc *remoteConfig) gitConfig(remote string) [][2]string {
+	if rc == nil {
+		return nil
+	}
+	u, err := url.Parse(remote)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil
+	}
+	prefix := fmt.Sprintf("http.%s://%s/", u.Scheme, u.Host)
+
+	var config [][2]string
+	// Credentials are never sent in the clear.
+	if c, has := rc.credentials(u.Host); has && u.Scheme == "https" {
+		name, value := c.header()
+		config = append(config, [2]string{prefix + ".extraHeader", name + ": " + value})
+	}
+	if p, has := rc.proxyFor(u.Host); has {
+		// An empty proxy disables proxying, even through the environment.
+		var proxy string
+		if p != nil {
+			proxy = p.String()
+		}
+		config = append(config, [2]string{prefix + ".proxy", proxy})
+	}
+	return config
+}
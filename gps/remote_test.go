@@ -0,0 +1,126 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRemoteConfigGitConfig(t *testing.T) {
+	proxy, _ := url.Parse("http://proxy.example.com:3128")
+	rc := &remoteConfig{
+		creds: map[string]Credentials{
+			"git.example.com":  {Username: "bot", Password: "s3cret"},
+			"http.example.com": {Username: "bot", Password: "s3cret"},
+			"ghe.example.com":  {Header: "Authorization", Password: "Bearer t0ken"},
+		},
+		proxy: func(host string) (*url.URL, bool) {
+			switch host {
+			case "git.example.com", "http.example.com":
+				return proxy, true
+			case "internal.example.com":
+				return nil, true
+			}
+			return nil, false
+		},
+	}
+
+	cases := map[string][][2]string{
+		"https://git.example.com/org/repo": {
+			{"http.https://git.example.com/.extraHeader", "Authorization: Basic Ym90OnMzY3JldA=="},
+			{"http.https://git.example.com/.proxy", "http://proxy.example.com:3128"},
+		},
+		// Credentials are never sent in the clear.
+		"http://http.example.com/org/repo": {
+			{"http.http://http.example.com/.proxy", "http://proxy.example.com:3128"},
+		},
+		"https://internal.example.com/org/repo": {
+			{"http.https://internal.example.com/.proxy", ""},
+		},
+		"https://ghe.example.com/org/repo": {
+			{"http.https://ghe.example.com/.extraHeader", "Authorization: Bearer t0ken"},
+		},
+		"https://github.com/org/repo":            nil,
+		"ssh://git@git.example.com/org/repo.git": nil,
+	}
+	for remote, want := range cases {
+		if got := rc.gitConfig(remote); !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected git config for %s:\n\t(GOT): %q\n\t(WNT): %q", remote, got, want)
+		}
+	}
+
+	var nilrc *remoteConfig
+	if got := nilrc.gitConfig("https://git.example.com/org/repo"); got != nil {
+		t.Errorf("expected no git config without a remote config, got %q", got)
+	}
+}
+
+func TestRemoteConfigClientProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.Host)
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	rc := &remoteConfig{
+		proxy: func(host string) (*url.URL, bool) {
+			if host == "vanity.example.com" {
+				return proxyURL, true
+			}
+			return nil, false
+		},
+	}
+
+	body, _, err := doFetchMetadata(context.Background(), "http", "vanity.example.com/pkg", rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(body)
+	body.Close()
+
+	if want := []string{"vanity.example.com"}; !reflect.DeepEqual(proxied, want) {
+		t.Errorf("unexpected proxied requests:\n\t(GOT): %q\n\t(WNT): %q", proxied, want)
+	}
+}
+
+func TestRemoteConfigAuthorize(t *testing.T) {
+	rc := &remoteConfig{
+		creds: map[string]Credentials{
+			"basic.example.com":  {Username: "bot", Password: "s3cret"},
+			"bearer.example.com": {Header: "Authorization", Password: "Bearer t0ken"},
+			"jfrog.example.com":  {Header: "X-JFrog-Art-Api", Password: "k3y"},
+		},
+	}
+
+	cases := []struct {
+		url, header, value string
+	}{
+		{"https://basic.example.com/x", "Authorization", "Basic Ym90OnMzY3JldA=="},
+		{"https://bearer.example.com/x", "Authorization", "Bearer t0ken"},
+		{"https://jfrog.example.com/artifactory/api/go/go", "X-JFrog-Art-Api", "k3y"},
+		// Credentials are never sent in the clear.
+		{"http://jfrog.example.com/artifactory/api/go/go", "X-JFrog-Art-Api", ""},
+		{"https://other.example.com/x", "Authorization", ""},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.authorize(req)
+		if got := req.Header.Get(c.header); got != c.value {
+			t.Errorf("unexpected %s header for %s:\n\t(GOT): %q\n\t(WNT): %q", c.header, c.url, got, c.value)
+		}
+	}
+}
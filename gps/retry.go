@@ -0,0 +1,188 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy controls how network operations - fetching go-get metadata, and
+// checking for, listing the versions of, cloning and fetching sources - are
+// retried when they fail with transient network errors, such as timeouts or
+// dropped connections, and how long they may take.
+//
+// The zero value attempts every operation once, without time limits.
+//
+// Commands that exceed CommandTimeout or StallTimeout are killed, along with
+// the processes they started, and the operations running them fail with
+// errors naming the source they were run against.
+type RetryPolicy struct {
+	// Attempts is how many times an operation is attempted before giving up.
+	// <=1: Don't retry.
+	Attempts int
+
+	// Backoff is how long to wait before the first retry. The wait doubles
+	// with every retry after that, up to MaxBackoff.
+	Backoff time.Duration
+
+	// MaxBackoff is the longest wait between attempts. <=0: No limit.
+	MaxBackoff time.Duration
+
+	// Timeout is how long each attempt to fetch go-get metadata, check for a
+	// source or list its versions may take. <=0: No limit.
+	Timeout time.Duration
+
+	// FetchTimeout is how long each attempt to clone or fetch a source may
+	// take. <=0: No limit.
+	FetchTimeout time.Duration
+
+	// CommandTimeout is how long each git, hg, bzr or svn command run by an
+	// operation may take, whether or not the operation reaches the network.
+	// <=0: No limit.
+	CommandTimeout time.Duration
+
+	// StallTimeout is how long each such command may run without writing
+	// any output before it is taken to have hung and is killed. <=0: No
+	// limit.
+	StallTimeout time.Duration
+}
+
+// timeout returns how long each attempt of an operation of type typ may
+// take, and whether the operation is retried at all.
+func (p RetryPolicy) timeout(typ callType) (time.Duration, bool) {
+	switch typ {
+	case ctHTTPMetadata, ctSourcePing, ctListVersions:
+		return p.Timeout, true
+	case ctSourceInit, ctSourceFetch:
+		return p.FetchTimeout, true
+	default:
+		return 0, false
+	}
+}
+
+// backoff returns how long to wait before the given retry, counting from 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// retry runs f, an operation of type typ on name, retrying it if it fails
+// with a transient error, as sup.policy says.
+func (sup *supervisor) retry(ctx context.Context, name string, typ callType, f func(context.Context) error) error {
+	timeout, network := sup.policy.timeout(typ)
+	if !network {
+		return f(ctx)
+	}
+
+	attempts := sup.policy.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = attemptWithTimeout(ctx, timeout, f)
+		if err == nil || ctx.Err() != nil || !isTransientErr(err) {
+			return err
+		}
+		if attempt == attempts {
+			break
+		}
+
+		wait := sup.policy.backoff(attempt)
+		if sup.logger != nil {
+			sup.logger.Warnf("%s for %s failed with a transient error, retrying in %s: %s", typ, name, wait, err)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+
+	if attempts == 1 {
+		return errors.Wrap(err, "transient network error")
+	}
+	return errors.Wrapf(err, "transient network error, still failing after %d attempts", attempts)
+}
+
+// attemptWithTimeout runs f, cancelling it after timeout, if timeout is
+// positive.
+func attemptWithTimeout(ctx context.Context, timeout time.Duration, f func(context.Context) error) error {
+	if timeout <= 0 {
+		return f(ctx)
+	}
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := f(tctx)
+	if err != nil && tctx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return errors.Wrapf(err, "timed out after %s", timeout)
+	}
+	return err
+}
+
+// transientErrOutput are fragments of error messages, and of the output of
+// VCS commands, that report failures of the network or of remote servers that
+// are likely to pass, rather than problems with the request itself.
+var transientErrOutput = []string{
+	"timed out",
+	"timeout",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"no route to host",
+	"network is unreachable",
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"unexpected eof",
+	"early eof",
+	"the remote end hung up unexpectedly",
+	"unexpected disconnect",
+	"rpc failed",
+	"returned error: 429",
+	"returned error: 500",
+	"returned error: 502",
+	"returned error: 503",
+	"returned error: 504",
+	"429 too many requests",
+	"500 internal server error",
+	"502 bad gateway",
+	"503 service unavailable",
+	"504 gateway timeout",
+}
+
+// isTransientErr reports whether err is a network error that is likely to
+// pass if the operation is retried.
+func isTransientErr(err error) bool {
+	cause := errors.Cause(err)
+	if cause == context.DeadlineExceeded {
+		return true
+	}
+	if ne, ok := cause.(net.Error); ok && (ne.Timeout() || ne.Temporary()) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, f := range transientErrOutput {
+		if strings.Contains(msg, f) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/vcs"
+	"github.com/pkg/errors"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("unexpected backoff before retry %d: %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestIsTransientErr(t *testing.T) {
+	transient := []error{
+		context.DeadlineExceeded,
+		errors.Wrap(context.DeadlineExceeded, "failed to list versions"),
+		unwrapVcsErr(vcs.NewRemoteError("unable to update repository", errors.New("exit status 128"),
+			"fatal: unable to access 'https://github.com/golang/dep/': Could not resolve host: github.com")),
+		errors.New("unable to fetch https://proxy.example.com/x/@v/list: 503 Service Unavailable"),
+		errors.New("fatal: the remote end hung up unexpectedly"),
+	}
+	for _, err := range transient {
+		if !isTransientErr(err) {
+			t.Errorf("expected %q to be transient", err)
+		}
+	}
+
+	permanent := []error{
+		context.Canceled,
+		errors.New("source does not exist upstream: git: https://github.com/golang/nope"),
+		errors.New("unable to fetch https://proxy.example.com/x/@v/list: 404 Not Found"),
+		errors.New("authentication failed for github.com"),
+	}
+	for _, err := range permanent {
+		if isTransientErr(err) {
+			t.Errorf("expected %q to be permanent", err)
+		}
+	}
+}
+
+func TestSupervisorRetry(t *testing.T) {
+	ctx := context.Background()
+	sup := newSupervisor(ctx)
+	sup.policy = RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	// A transient error is retried until the operation succeeds.
+	var n int
+	err := sup.do(ctx, "example.com/foo", ctSourceFetch, func(ctx context.Context) error {
+		n++
+		if n < 3 {
+			return errors.New("connection reset by peer")
+		}
+		return nil
+	})
+	if err != nil || n != 3 {
+		t.Errorf("expected success on the third attempt, got %v after %d attempts", err, n)
+	}
+
+	// ...or the attempts run out.
+	n = 0
+	err = sup.do(ctx, "example.com/foo", ctHTTPMetadata, func(ctx context.Context) error {
+		n++
+		return errors.New("connection reset by peer")
+	})
+	if n != 3 || err == nil || !strings.Contains(err.Error(), "transient network error, still failing after 3 attempts") {
+		t.Errorf("unexpected error after %d attempts: %v", n, err)
+	}
+
+	// A permanent error is not retried.
+	n = 0
+	err = sup.do(ctx, "example.com/foo", ctSourceInit, func(ctx context.Context) error {
+		n++
+		return errors.New("repository not found")
+	})
+	if n != 1 || err == nil || err.Error() != "repository not found" {
+		t.Errorf("unexpected error after %d attempts: %v", n, err)
+	}
+
+	// Nor is an operation that does not reach the network.
+	n = 0
+	sup.do(ctx, "example.com/foo", ctListPackages, func(ctx context.Context) error {
+		n++
+		return errors.New("connection reset by peer")
+	})
+	if n != 1 {
+		t.Errorf("expected a local operation to be attempted once, got %d attempts", n)
+	}
+}
+
+func TestSupervisorRetryTimeout(t *testing.T) {
+	ctx := context.Background()
+	sup := newSupervisor(ctx)
+	sup.policy = RetryPolicy{Attempts: 2, Timeout: 10 * time.Millisecond, FetchTimeout: time.Hour}
+
+	var n int
+	err := sup.do(ctx, "example.com/foo", ctListVersions, func(ctx context.Context) error {
+		n++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if n != 2 || err == nil || !strings.Contains(err.Error(), "timed out after 10ms") {
+		t.Errorf("unexpected error after %d attempts: %v", n, err)
+	}
+}
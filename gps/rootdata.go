@@ -48,6 +48,18 @@ type rootdata struct {
 
 	// The ProjectAnalyzer to use for all GetManifestAndLock calls.
 	an ProjectAnalyzer
+
+	// Radix tree of the source prefixes declared by the root manifest, if it
+	// implements SourceMapper, mapped to their sources.
+	srcs *radix.Tree
+
+	// Radix tree of the hosts and project root prefixes declared by the root
+	// manifest, if it implements ProtocolMapper, mapped to their protocols.
+	protos *radix.Tree
+
+	// Radix tree of the project root prefixes banned by the root manifest, if
+	// it implements BanLister, mapped to the reasons they are banned.
+	banned *radix.Tree
 }
 
 // externalImportList returns a list of the unique imports from the root data.
@@ -99,7 +111,7 @@ func (rd rootdata) getApplicableConstraints(stdLibFn func(string) bool) []workin
 	}
 
 	// Now override them all to produce a consolidated workingConstraint slice
-	combined := rd.ovr.overrideAll(pc)
+	combined := rd.overrideAll(pc)
 
 	type wccount struct {
 		count int
@@ -138,7 +150,75 @@ func (rd rootdata) getApplicableConstraints(stdLibFn func(string) bool) []workin
 }
 
 func (rd rootdata) combineConstraints() []workingConstraint {
-	return rd.ovr.overrideAll(rd.rm.DependencyConstraints())
+	return rd.overrideAll(rd.rm.DependencyConstraints())
+}
+
+// override applies the root's overrides to a single project constraint, as
+// ProjectConstraints.override does, then maps its source per the root's
+// source prefixes, and failing that its protocols, if it still has none.
+func (rd rootdata) override(pr ProjectRoot, pp ProjectProperties) workingConstraint {
+	wc := rd.ovr.override(pr, pp)
+	if wc.Ident.Source != "" {
+		return wc
+	}
+
+	// The longest string prefix need not fall on a path boundary, so walk all
+	// of them, keeping the last (longest) one that does.
+	path := string(pr)
+	if rd.srcs != nil {
+		rd.srcs.WalkPath(path, func(pre string, src interface{}) bool {
+			if isPathPrefixOrEqual(pre, path) {
+				wc.Ident.Source = src.(string) + path[len(pre):]
+			}
+			return false
+		})
+	}
+	if wc.Ident.Source == "" && rd.protos != nil {
+		rd.protos.WalkPath(path, func(pre string, proto interface{}) bool {
+			if isPathPrefixOrEqual(pre, path) {
+				wc.Ident.Source = protocolSource(path, proto.(string))
+			}
+			return false
+		})
+	}
+	return wc
+}
+
+// bannedBy returns the banned project root prefix that pr falls under, and
+// the reason it is banned, if pr is banned.
+func (rd rootdata) bannedBy(pr ProjectRoot) (string, string, bool) {
+	if rd.banned == nil {
+		return "", "", false
+	}
+
+	var pre, reason string
+	var banned bool
+	path := string(pr)
+	rd.banned.WalkPath(path, func(p string, r interface{}) bool {
+		if isPathPrefixOrEqual(p, path) {
+			pre, reason, banned = p, r.(string), true
+			return true
+		}
+		return false
+	})
+	return pre, reason, banned
+}
+
+// overrideAll applies the root's overrides and source prefixes to every
+// constraint in pcm.
+//
+// A slice of workingConstraint is returned, allowing differentiation between
+// values that were or were not overridden.
+func (rd rootdata) overrideAll(pcm ProjectConstraints) []workingConstraint {
+	out := make([]workingConstraint, 0, len(pcm))
+	for pr, pp := range pcm {
+		out = append(out, rd.override(pr, pp))
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Ident.Less(out[j].Ident)
+	})
+	return out
 }
 
 // needVersionListFor indicates whether we need a version list for a given
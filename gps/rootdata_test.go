@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/armon/go-radix"
 	"github.com/golang/dep/gps/pkgtree"
 )
 
@@ -224,3 +225,70 @@ func TestGetApplicableConstraints(t *testing.T) {
 		})
 	}
 }
+
+func TestRootdataOverrideSources(t *testing.T) {
+	rd := rootdata{
+		ovr: ProjectConstraints{
+			"github.com/foo/ovr": ProjectProperties{
+				Source: "github.com/fork/ovr",
+			},
+		},
+		srcs: radix.New(),
+	}
+	rd.srcs.Insert("github.com/foo", "mirror.example.com/foo")
+	rd.srcs.Insert("github.com/foo/bar", "mirror.example.com/bar")
+	rd.srcs.Insert("github.com/foo/ovr", "mirror.example.com/ovr")
+
+	table := []struct {
+		pr   ProjectRoot
+		pp   ProjectProperties
+		want string
+	}{
+		{"github.com/foo/baz", ProjectProperties{}, "mirror.example.com/foo/baz"},
+		{"github.com/foo/bar", ProjectProperties{}, "mirror.example.com/bar"},
+		{"github.com/foo/bar/qux", ProjectProperties{}, "mirror.example.com/bar/qux"},
+		{"github.com/foo/barbaz", ProjectProperties{}, "mirror.example.com/foo/barbaz"},
+		{"github.com/other/baz", ProjectProperties{}, ""},
+		{"github.com/foo/baz", ProjectProperties{Source: "github.com/fork/baz"}, "github.com/fork/baz"},
+		{"github.com/foo/ovr", ProjectProperties{}, "github.com/fork/ovr"},
+	}
+
+	for _, c := range table {
+		got := rd.override(c.pr, c.pp).Ident.Source
+		if got != c.want {
+			t.Errorf("unexpected source for %s with %+v:\n\t(GOT): %q\n\t(WNT): %q", c.pr, c.pp, got, c.want)
+		}
+	}
+}
+
+func TestRootdataOverrideProtocols(t *testing.T) {
+	rd := rootdata{
+		ovr:    ProjectConstraints{},
+		srcs:   radix.New(),
+		protos: radix.New(),
+	}
+	rd.srcs.Insert("golang.org/x", "mirror.example.com/golang")
+	rd.protos.Insert("github.com", "https")
+	rd.protos.Insert("github.com/corp", "ssh")
+	rd.protos.Insert("golang.org", "ssh")
+
+	table := []struct {
+		pr   ProjectRoot
+		pp   ProjectProperties
+		want string
+	}{
+		{"github.com/foo/bar", ProjectProperties{}, "https://github.com/foo/bar"},
+		{"github.com/corp/lib", ProjectProperties{}, "ssh://git@github.com/corp/lib"},
+		{"github.com/corporate/lib", ProjectProperties{}, "https://github.com/corporate/lib"},
+		{"github.com/corp/lib", ProjectProperties{Source: "github.com/fork/lib"}, "github.com/fork/lib"},
+		{"golang.org/x/net", ProjectProperties{}, "mirror.example.com/golang/net"},
+		{"gitlab.com/foo/bar", ProjectProperties{}, ""},
+	}
+
+	for _, c := range table {
+		got := rd.override(c.pr, c.pp).Ident.Source
+		if got != c.want {
+			t.Errorf("unexpected source for %s with %+v:\n\t(GOT): %q\n\t(WNT): %q", c.pr, c.pp, got, c.want)
+		}
+	}
+}
@@ -4,6 +4,11 @@
 
 package gps
 
+import (
+	"github.com/golang/dep/internal/events"
+	depmetrics "github.com/golang/dep/internal/metrics"
+)
+
 // check performs constraint checks on the provided atom. The set of checks
 // differ slightly depending on whether the atom is pkgonly, or if it's the
 // entire project being added for the first time.
@@ -30,6 +35,17 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 	// If we're pkgonly, then base atom was already determined to be allowable,
 	// so we can skip the checkAtomAllowable step.
 	if !pkgonly {
+		depmetrics.Add(depmetrics.VersionsProbed, 1)
+		defer func() {
+			e := events.Event{Type: events.SolveAttempt, Project: string(pa.id.ProjectRoot), Version: pa.v.String()}
+			if err != nil {
+				e.Error = err.Error()
+			}
+			events.Emit(e)
+		}()
+		if err = s.checkAtomNotBanned(pa); err != nil {
+			return err
+		}
 		if err = s.checkAtomAllowable(pa); err != nil {
 			return err
 		}
@@ -51,6 +67,9 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 	// now, but won't be good enough when we get around to doing static
 	// analysis.
 	for _, dep := range deps {
+		if err = s.checkDepNotBanned(a, dep); err != nil {
+			return err
+		}
 		if err = s.checkIdentMatches(a, dep); err != nil {
 			return err
 		}
@@ -103,6 +122,66 @@ func (s *solver) checkAtomAllowable(pa atom) error {
 	return err
 }
 
+// checkAtomNotBanned ensures that an atom is not of a project banned by the
+// root manifest. Only the root's own dependencies on banned projects are caught
+// here; others are caught by checkDepNotBanned as they are introduced.
+func (s *solver) checkAtomNotBanned(pa atom) error {
+	pre, reason, banned := s.rd.bannedBy(pa.id.ProjectRoot)
+	if !banned {
+		return nil
+	}
+
+	deps := s.sel.getDependenciesOn(pa.id)
+	for _, dep := range deps {
+		s.fail(dep.depender.id)
+	}
+	return &bannedProjectFailure{
+		goal:   pa,
+		banned: pre,
+		reason: reason,
+		chains: s.importChains(deps),
+	}
+}
+
+// checkDepNotBanned ensures that the dependency of an atom is not on a
+// project banned by the root manifest.
+func (s *solver) checkDepNotBanned(a atomWithPackages, cdep completeDep) error {
+	pre, reason, banned := s.rd.bannedBy(cdep.workingConstraint.Ident.ProjectRoot)
+	if !banned {
+		return nil
+	}
+
+	dep := dependency{depender: a.a, dep: cdep}
+	return &bannedProjectFailure{
+		goal:   a.a,
+		banned: pre,
+		reason: reason,
+		chains: s.importChains([]dependency{dep}),
+	}
+}
+
+// importChains returns, for each of deps, the chain of dependencies through
+// which the root comes to depend on its depender, followed by the dependency
+// itself. Where a project has several dependers, the first is followed.
+func (s *solver) importChains(deps []dependency) [][]dependency {
+	chains := make([][]dependency, 0, len(deps))
+	for _, dep := range deps {
+		chain := []dependency{dep}
+		seen := map[ProjectRoot]bool{dep.depender.id.ProjectRoot: true}
+		for cur := dep.depender; cur.v != rootRev; {
+			dependers := s.sel.getDependenciesOn(cur.id)
+			if len(dependers) == 0 || seen[dependers[0].depender.id.ProjectRoot] {
+				break
+			}
+			cur = dependers[0].depender
+			seen[cur.id.ProjectRoot] = true
+			chain = append([]dependency{dependers[0]}, chain...)
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
 // checkRequiredPackagesExist ensures that all required packages enumerated by
 // existing dependencies on this atom are actually present in the atom.
 func (s *solver) checkRequiredPackagesExist(a atomWithPackages) error {
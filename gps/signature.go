@@ -0,0 +1,123 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// signatureVerifier is an optional extension of source, for sources whose
+// versions and revisions may be signed.
+type signatureVerifier interface {
+	// verifySignature verifies that v, at the revision r, is signed by one
+	// of the keys in the keyring at the absolute path keyring.
+	verifySignature(ctx context.Context, v Version, r Revision, keyring string) error
+}
+
+// VerifySignature verifies that the version v of the project id is signed by
+// one of the keys in the OpenPGP keyring at the path keyring: the tag, for
+// versions that are annotated tags, or otherwise the commit. Only projects in
+// git repositories can be verified.
+func (sm *SourceMgr) VerifySignature(ctx context.Context, id ProjectIdentifier, v Version, keyring string) error {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return ErrSourceManagerIsReleased
+	}
+	keyring, err := filepath.Abs(keyring)
+	if err != nil {
+		return err
+	}
+	if sm.daemon != nil {
+		return sm.daemon.verifySignature(ctx, id, v, keyring)
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
+	if err != nil {
+		return err
+	}
+	return srcg.verifySignature(ctx, v, keyring)
+}
+
+func (sg *sourceGateway) verifySignature(ctx context.Context, v Version, keyring string) error {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	sv, ok := sg.src.(signatureVerifier)
+	if !ok {
+		return errors.Errorf("the signatures of %s sources cannot be verified", sg.src.sourceType())
+	}
+	if err := sg.require(ctx, sourceExistsLocally); err != nil {
+		return err
+	}
+	r, err := sg.convertToRevision(ctx, v)
+	if err != nil {
+		return err
+	}
+	return sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctVerifySignature, func(ctx context.Context) error {
+		return sv.verifySignature(ctx, v, r, keyring)
+	})
+}
+
+// verifySignature verifies the signature on the tag of v, if it is an
+// annotated tag, or otherwise on the commit r, with gpg, trusting only the keys
+// in keyring. The keys are imported into a temporary home directory, so that
+// the user's own keys are never trusted.
+func (s *gitSource) verifySignature(ctx context.Context, v Version, r Revision, keyring string) error {
+	home, err := ioutil.TempDir("", "dep-gnupg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+	env := append(gitEnv(), "GNUPGHOME="+home)
+
+	cmd := commandContext(ctx, "gpg", "--batch", "--quiet", "--import", keyring)
+	cmd.SetEnv(env)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "unable to import keyring %s: %s", keyring, out)
+	}
+
+	git := func(args ...string) ([]byte, error) {
+		cmd := commandContext(ctx, "git", args...)
+		cmd.SetDir(s.repo.LocalPath())
+		cmd.SetEnv(env)
+		return cmd.CombinedOutput()
+	}
+
+	args := []string{"verify-commit", string(r)}
+	what := "commit " + string(r)
+	if tag, ok := s.annotatedTag(ctx, v); ok {
+		args = []string{"verify-tag", "refs/tags/" + tag}
+		what = "tag " + tag
+	}
+
+	if out, err := git(args...); err != nil {
+		return errors.Errorf("%s of %s has no valid signature by a key in %s: %s", what, s.upstreamURL(), keyring, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// annotatedTag returns the name of the tag of v, if v is a version whose tag
+// is annotated, and so may carry a signature of its own.
+func (s *gitSource) annotatedTag(ctx context.Context, v Version) (string, bool) {
+	if pv, ok := v.(PairedVersion); ok {
+		v = pv.Unpair()
+	}
+	uv, ok := v.(UnpairedVersion)
+	if !ok || (uv.Type() != IsVersion && uv.Type() != IsSemver) {
+		return "", false
+	}
+
+	cmd := commandContext(ctx, "git", "cat-file", "-t", "refs/tags/"+uv.String())
+	cmd.SetDir(s.repo.LocalPath())
+	cmd.SetEnv(gitEnv())
+	out, err := cmd.CombinedOutput()
+	return uv.String(), err == nil && strings.TrimSpace(string(out)) == "tag"
+}
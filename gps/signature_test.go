@@ -0,0 +1,114 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/vcs"
+)
+
+// genTestKey generates a key for name in the gpg home directory home, and
+// exports its public key to the keyring file keyring.
+func genTestKey(t *testing.T, home, name, keyring string) {
+	if err := os.MkdirAll(home, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"--batch", "--passphrase", "", "--quick-gen-key", name},
+		{"--batch", "--yes", "--output", keyring, "--export", name},
+	} {
+		cmd := exec.Command("gpg", args...)
+		cmd.Env = append(os.Environ(), "GNUPGHOME="+home)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("gpg %v failed: %s\n%s", args, err, out)
+		}
+	}
+}
+
+func TestGitSourceVerifySignature(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping gpg key generation in short mode")
+	}
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not available")
+	}
+	requiresBins(t, "git")
+
+	dir, err := ioutil.TempDir("", "git-signatures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer exec.Command("gpgconf", "--kill", "all").Run()
+
+	trusted := filepath.Join(dir, "trusted.gpg")
+	other := filepath.Join(dir, "other.gpg")
+	genTestKey(t, filepath.Join(dir, "gnupg"), "dep-test@example.com", trusted)
+	genTestKey(t, filepath.Join(dir, "gnupg-other"), "other@example.com", other)
+
+	// Sign with the trusted key.
+	defer os.Setenv("GNUPGHOME", os.Getenv("GNUPGHOME"))
+	os.Setenv("GNUPGHOME", filepath.Join(dir, "gnupg"))
+
+	upstream := filepath.Join(dir, "upstream")
+	if err := os.Mkdir(upstream, 0777); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, upstream, "init", "-q")
+	runGit(t, upstream, "config", "user.signingkey", "dep-test@example.com")
+	if err := ioutil.WriteFile(filepath.Join(upstream, "a.go"), []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, upstream, "add", "a.go")
+	runGit(t, upstream, "commit", "-q", "-m", "unsigned")
+	unsigned := Revision(runGit(t, upstream, "rev-parse", "HEAD"))
+	runGit(t, upstream, "tag", "-a", "-m", "unsigned", "v0.9.0")
+	runGit(t, upstream, "tag", "v0.9.1")
+	runGit(t, upstream, "tag", "-s", "-m", "signed", "v1.0.0")
+	runGit(t, upstream, "commit", "-q", "-S", "--allow-empty", "-m", "signed")
+	signed := Revision(runGit(t, upstream, "rev-parse", "HEAD"))
+
+	r, err := vcs.NewGitRepo("file://"+filepath.ToSlash(upstream), filepath.Join(dir, "sources", "clone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: &gitRepo{GitRepo: r}}}
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		v       Version
+		r       Revision
+		keyring string
+		valid   bool
+	}{
+		{"signed tag", NewVersion("v1.0.0").Pair(unsigned), unsigned, trusted, true},
+		{"signed tag by other key", NewVersion("v1.0.0").Pair(unsigned), unsigned, other, false},
+		{"unsigned tag", NewVersion("v0.9.0").Pair(unsigned), unsigned, trusted, false},
+		{"lightweight tag of unsigned commit", NewVersion("v0.9.1").Pair(unsigned), unsigned, trusted, false},
+		{"signed commit", NewBranch("master").Pair(signed), signed, trusted, true},
+		{"signed commit by other key", signed, signed, other, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := src.verifySignature(ctx, c.v, c.r, c.keyring)
+			if c.valid && err != nil {
+				t.Fatalf("expected a valid signature, got %s", err)
+			}
+			if !c.valid && err == nil {
+				t.Fatal("expected no valid signature")
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// SigstoreIdentity identifies who is expected to have signed a version with
+// Sigstore: the subject of the short-lived certificate its signature was made
+// with, and the OpenID Connect issuer that vouched for the subject.
+type SigstoreIdentity struct {
+	// Identity is the certificate's subject, such as an email address or the
+	// URI of a CI workflow.
+	Identity string
+
+	// Issuer is the URL of the OpenID Connect issuer, such as
+	// https://token.actions.githubusercontent.com.
+	Issuer string
+}
+
+// sigstoreVerifier is an optional extension of source, for sources whose
+// versions and revisions may be signed with Sigstore.
+type sigstoreVerifier interface {
+	// verifySigstore verifies that v, at the revision r, is signed with a
+	// certificate issued to signer, and that the signature was recorded in
+	// the Rekor transparency log.
+	verifySigstore(ctx context.Context, v Version, r Revision, signer SigstoreIdentity) error
+}
+
+// VerifySigstore verifies that the version v of the project id is signed with
+// Sigstore by signer: the tag, for versions that are annotated tags, or
+// otherwise the commit. Only projects in git repositories can be verified, and
+// the signatures are verified with gitsign.
+func (sm *SourceMgr) VerifySigstore(ctx context.Context, id ProjectIdentifier, v Version, signer SigstoreIdentity) error {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return ErrSourceManagerIsReleased
+	}
+	if signer.Identity == "" || signer.Issuer == "" {
+		return errors.New("a Sigstore signer needs both an identity and an issuer")
+	}
+	if sm.daemon != nil {
+		return sm.daemon.verifySigstore(ctx, id, v, signer)
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
+	if err != nil {
+		return err
+	}
+	return srcg.verifySigstore(ctx, v, signer)
+}
+
+func (sg *sourceGateway) verifySigstore(ctx context.Context, v Version, signer SigstoreIdentity) error {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	sv, ok := sg.src.(sigstoreVerifier)
+	if !ok {
+		return errors.Errorf("the Sigstore signatures of %s sources cannot be verified", sg.src.sourceType())
+	}
+	if err := sg.require(ctx, sourceExistsLocally); err != nil {
+		return err
+	}
+	r, err := sg.convertToRevision(ctx, v)
+	if err != nil {
+		return err
+	}
+	return sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctVerifySignature, func(ctx context.Context) error {
+		return sv.verifySigstore(ctx, v, r, signer)
+	})
+}
+
+// verifySigstore verifies the Sigstore signature on the tag of v, if it is an
+// annotated tag, or otherwise on the commit r, with gitsign, which also checks
+// that the signature was recorded in the Rekor transparency log.
+func (s *gitSource) verifySigstore(ctx context.Context, v Version, r Revision, signer SigstoreIdentity) error {
+	args := []string{"verify"}
+	what := "commit " + string(r)
+	ref := string(r)
+	if tag, ok := s.annotatedTag(ctx, v); ok {
+		args = []string{"verify-tag"}
+		what = "tag " + tag
+		ref = "refs/tags/" + tag
+	}
+	args = append(args,
+		"--certificate-identity="+signer.Identity,
+		"--certificate-oidc-issuer="+signer.Issuer,
+		ref,
+	)
+
+	cmd := commandContext(ctx, "gitsign", args...)
+	cmd.SetDir(s.repo.LocalPath())
+	cmd.SetEnv(gitEnv())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Errorf("%s of %s has no valid Sigstore signature by %s (issued by %s): %s", what, s.upstreamURL(), signer.Identity, signer.Issuer, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
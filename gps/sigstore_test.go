@@ -0,0 +1,104 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGitSourceVerifySigstore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in for gitsign is a shell script")
+	}
+	requiresBins(t, "git")
+
+	dir, err := ioutil.TempDir("", "git-sigstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// gitsign is stood in for by a script that records its arguments, and
+	// accepts only the signatures of maintainer@example.com.
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0777); err != nil {
+		t.Fatal(err)
+	}
+	args := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + args + "\ncase \"$*\" in *=maintainer@example.com*) exit 0;; esac\necho 'certificate identity mismatch'\nexit 1\n"
+	if err := ioutil.WriteFile(filepath.Join(bin, "gitsign"), []byte(script), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	src, head := newStagingTestSource(t, dir)
+	runGit(t, filepath.Join(dir, "upstream"), "tag", "-a", "-m", "release", "v2.0.0")
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first := Revision(runGit(t, src.repo.LocalPath(), "rev-parse", "v1.0.0"))
+
+	signer := SigstoreIdentity{Identity: "maintainer@example.com", Issuer: "https://accounts.google.com"}
+	cases := []struct {
+		name     string
+		v        Version
+		r        Revision
+		signer   SigstoreIdentity
+		wantArgs string
+		valid    bool
+	}{
+		{
+			name:     "annotated tag",
+			v:        NewVersion("v2.0.0").Pair(Revision(head)),
+			r:        Revision(head),
+			signer:   signer,
+			wantArgs: "verify-tag --certificate-identity=maintainer@example.com --certificate-oidc-issuer=https://accounts.google.com refs/tags/v2.0.0",
+			valid:    true,
+		},
+		{
+			name:     "lightweight tag",
+			v:        NewVersion("v1.0.0").Pair(first),
+			r:        first,
+			signer:   signer,
+			wantArgs: "verify --certificate-identity=maintainer@example.com --certificate-oidc-issuer=https://accounts.google.com " + string(first),
+			valid:    true,
+		},
+		{
+			name:     "other signer",
+			v:        NewBranch("master").Pair(Revision(head)),
+			r:        Revision(head),
+			signer:   SigstoreIdentity{Identity: "other@example.com", Issuer: "https://accounts.google.com"},
+			wantArgs: "verify --certificate-identity=other@example.com --certificate-oidc-issuer=https://accounts.google.com " + head,
+			valid:    false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := src.verifySigstore(ctx, c.v, c.r, c.signer)
+			if c.valid && err != nil {
+				t.Fatalf("expected a valid signature, got %s", err)
+			}
+			if !c.valid && (err == nil || !strings.Contains(err.Error(), "certificate identity mismatch")) {
+				t.Fatalf("expected gitsign to reject the signature, got %v", err)
+			}
+
+			got, err := ioutil.ReadFile(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(string(got)) != c.wantArgs {
+				t.Errorf("unexpected gitsign arguments:\n\t(GOT): %s\n\t(WNT): %s", strings.TrimSpace(string(got)), c.wantArgs)
+			}
+		})
+	}
+}
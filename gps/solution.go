@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/golang/dep/internal/events"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 )
@@ -53,6 +54,10 @@ type WriteProgress struct {
 	Total   int
 	LP      LockedProject
 	Failure bool
+	// Digest is the digest of the project as written, as
+	// pkgtree.DigestFromDirectory computes it, where it was computed while the
+	// project was written, and nil otherwise.
+	Digest []byte
 }
 
 func (p WriteProgress) String() string {
@@ -80,6 +85,32 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 		return fmt.Errorf("must provide non-nil Lock to WriteDepTree")
 	}
 
+	return writeDepTree(basedir, l, onWrite, func(ctx context.Context, p LockedProject, to string) ([]byte, error) {
+		return nil, exportPruned(ctx, sm, p, co.PruneOptionsFor(p.Ident().ProjectRoot), to)
+	})
+}
+
+// exportPruned exports the project p to the path to, and prunes it with po.
+func exportPruned(ctx context.Context, sm SourceManager, p LockedProject, po PruneOptions, to string) error {
+	ident := p.Ident()
+	projectRoot := string(ident.ProjectRoot)
+	if err := sm.ExportProject(ctx, ident, p.Version(), to); err != nil {
+		return errors.Wrapf(err, "failed to export %s", projectRoot)
+	}
+
+	err := PruneProject(to, p, po)
+	if err != nil {
+		return errors.Wrapf(err, "failed to prune %s", projectRoot)
+	}
+	return nil
+}
+
+// writeDepTree calls write concurrently for each project in l, with the path
+// beneath basedir that the project is to be written to, reporting progress to
+// onWrite as WriteDepTree does, along with the digest write returns, if any.
+// basedir is removed if any call fails.
+func writeDepTree(basedir string, l Lock, onWrite func(WriteProgress), write func(context.Context, LockedProject, string) ([]byte, error)) error {
+
 	if err := os.MkdirAll(basedir, 0777); err != nil {
 		return err
 	}
@@ -96,6 +127,7 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 		p := lps[i] // per-iteration copy
 
 		g.Go(func() error {
+			var digest []byte
 			err := func() error {
 				select {
 				case sem <- struct{}{}:
@@ -104,17 +136,10 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 					return ctx.Err()
 				}
 
-				ident := p.Ident()
-				projectRoot := string(ident.ProjectRoot)
-				to := filepath.FromSlash(filepath.Join(basedir, projectRoot))
-
-				if err := sm.ExportProject(ctx, ident, p.Version(), to); err != nil {
-					return errors.Wrapf(err, "failed to export %s", projectRoot)
-				}
-
-				err := PruneProject(to, p, co.PruneOptionsFor(ident.ProjectRoot))
-				if err != nil {
-					return errors.Wrapf(err, "failed to prune %s", projectRoot)
+				to := filepath.FromSlash(filepath.Join(basedir, string(p.Ident().ProjectRoot)))
+				var err error
+				if digest, err = write(ctx, p, to); err != nil {
+					return err
 				}
 
 				return ctx.Err()
@@ -124,6 +149,11 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 			case context.Canceled, context.DeadlineExceeded:
 				// Don't report "secondary" errors.
 			default:
+				e := events.Event{Type: events.ProjectWritten, Project: string(p.Ident().ProjectRoot), Version: p.Version().String()}
+				if err != nil {
+					e.Error = err.Error()
+				}
+				events.Emit(e)
 				if onWrite != nil {
 					// Increment and call atomically to prevent re-ordering.
 					cnt.Lock()
@@ -133,6 +163,7 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 						Total:   len(lps),
 						LP:      p,
 						Failure: err != nil,
+						Digest:  digest,
 					})
 					cnt.Unlock()
 				}
@@ -6,13 +6,13 @@ package gps
 
 import (
 	"io/ioutil"
-	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
 	"testing"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -136,7 +136,7 @@ func BenchmarkCreateVendorTree(b *testing.B) {
 	clean := true
 	sm, err := NewSourceManager(SourceManagerConfig{
 		Cachedir: path.Join(tmp, "cache"),
-		Logger:   log.New(test.Writer{TB: b}, "", 0),
+		Logger:   logging.New(test.Writer{TB: b}, logging.Options{Level: logging.Debug}),
 	})
 	if err != nil {
 		b.Fatalf("failed to create SourceManager: %q", err)
@@ -570,3 +570,47 @@ func (e *nonexistentRevisionFailure) traceString() string {
 		e.goal.dep.Ident,
 	)
 }
+
+// bannedProjectFailure indicates that the goal atom was rejected because it is
+// of, or depends on, a project that the root manifest bans.
+type bannedProjectFailure struct {
+	goal atom
+	// The banned project root prefix that the project falls under, and the
+	// reason it is banned, if any.
+	banned, reason string
+	// The chains of dependencies through which the root comes to depend on the
+	// banned project, each starting at the root.
+	chains [][]dependency
+}
+
+// project returns the root of the banned project.
+func (e *bannedProjectFailure) project() ProjectRoot {
+	if len(e.chains) == 0 {
+		return e.goal.id.ProjectRoot
+	}
+	chain := e.chains[0]
+	return chain[len(chain)-1].dep.Ident.ProjectRoot
+}
+
+func (e *bannedProjectFailure) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Could not introduce %s, as %s is banned", a2vs(e.goal), e.project())
+	if string(e.project()) != e.banned {
+		fmt.Fprintf(&buf, " along with everything under %s", e.banned)
+	}
+	if e.reason != "" {
+		fmt.Fprintf(&buf, ": %s", e.reason)
+	}
+	for _, chain := range e.chains {
+		links := make([]string, 0, len(chain))
+		for _, dep := range chain {
+			links = append(links, fmt.Sprintf("%s imports %s", a2vs(dep.depender), strings.Join(dep.dep.pl, ", ")))
+		}
+		fmt.Fprintf(&buf, "\n\t\t%s", strings.Join(links, " -> "))
+	}
+	return buf.String()
+}
+
+func (e *bannedProjectFailure) traceString() string {
+	return fmt.Sprintf("%s is banned by %s", e.project(), e.banned)
+}
@@ -8,11 +8,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -26,7 +27,7 @@ func fixSolve(params SolveParameters, sm SourceManager, t *testing.T) (Solution,
 	// Trace unconditionally; by passing the trace through t.Log(), the testing
 	// system will decide whether or not to actually show the output (based on
 	// -v, or selectively on test failure).
-	params.TraceLogger = log.New(test.Writer{TB: t}, "", 0)
+	params.TraceLogger = logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug})
 	// always return false, otherwise it would identify pretty much all of
 	// our fixtures as being stdlib and skip everything
 	params.stdLibFn = func(string) bool { return false }
@@ -257,3 +258,76 @@ func TestRootLockNoVersionPairMatching(t *testing.T) {
 
 	fixtureSolveSimpleChecks(fix, res, err, t)
 }
+
+// bannedManifest is a root manifest that also bans projects.
+type bannedManifest struct {
+	simpleRootManifest
+	banned map[string]string
+}
+
+func (m bannedManifest) BannedProjects() map[string]string {
+	return m.banned
+}
+
+func TestSolveBanned(t *testing.T) {
+	ds := []depspec{
+		mkDepspec("root 0.0.0", "a *"),
+		mkDepspec("a 1.0.0", "c 1.0.0"),
+		mkDepspec("a 2.0.0", "b 1.0.0"),
+		mkDepspec("b 1.0.0"),
+		mkDepspec("c 1.0.0", "b 1.0.0"),
+	}
+
+	solve := func(banned map[string]string) (Solution, error) {
+		// The solver's prefetching can outlive the solve, so each gets its
+		// own copy of the specs, rather than seeing them changed below.
+		ds := append([]depspec(nil), ds...)
+		fix := basicFixture{ds: ds}
+		params := SolveParameters{
+			RootDir:         string(ds[0].n),
+			RootPackageTree: fix.rootTree(),
+			Manifest: bannedManifest{
+				simpleRootManifest: fix.rootmanifest().(simpleRootManifest),
+				banned:             banned,
+			},
+			ProjectAnalyzer: naiveAnalyzer{},
+		}
+		return fixSolve(params, newdepspecSM(ds, nil), t)
+	}
+
+	// Every version of a, the root's only dependency, would bring in b: the
+	// latest directly, and the older one through c, which is tried last.
+	_, err := solve(map[string]string{"b": "no longer maintained"})
+	if err == nil {
+		t.Fatal("expected solving to fail with b banned")
+	}
+	for _, want := range []string{
+		"Could not introduce c@1.0.0, as b is banned: no longer maintained",
+		"(root) imports a -> a@1.0.0 imports c -> c@1.0.0 imports b",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to contain %q, got:\n%s", want, err)
+		}
+	}
+
+	// An older version of a brings in b's replacement, c.
+	ds[4] = mkDepspec("c 1.0.0")
+	soln, err := solve(map[string]string{"b": ""})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := make(map[ProjectRoot]string)
+	for _, lp := range soln.Projects() {
+		got[lp.Ident().ProjectRoot] = lp.Version().String()
+	}
+	if want := map[ProjectRoot]string{"a": "1.0.0", "c": "1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected solution:\n\t(GOT) %v\n\t(WNT) %v", got, want)
+	}
+
+	// The root's own dependencies can be banned, too.
+	_, err = solve(map[string]string{"a": "license changed"})
+	if err == nil || !strings.Contains(err.Error(), "Could not introduce a@2.0.0, as a is banned: license changed") ||
+		!strings.Contains(err.Error(), "(root) imports a") {
+		t.Fatalf("expected solving to fail with a banned, got %v", err)
+	}
+}
@@ -8,15 +8,17 @@ import (
 	"container/heap"
 	"context"
 	"fmt"
-	"log"
 	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/armon/go-radix"
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/gps/paths"
 	"github.com/golang/dep/gps/pkgtree"
+	depmetrics "github.com/golang/dep/internal/metrics"
 	"github.com/pkg/errors"
 )
 
@@ -93,9 +95,9 @@ type SolveParameters struct {
 	Downgrade bool
 
 	// TraceLogger is the logger to use for generating trace output. If set, the
-	// solver will generate informative trace output as it moves through the
-	// solving process.
-	TraceLogger *log.Logger
+	// solver will generate informative trace output, at the debug level, as it
+	// moves through the solving process.
+	TraceLogger logging.Logger
 
 	// stdLibFn is the function to use to recognize standard library import paths.
 	// Only overridden for tests. Defaults to paths.IsStandardImportPath if nil.
@@ -116,7 +118,7 @@ type solver struct {
 	attempts int
 
 	// Logger used exclusively for trace output, or nil to suppress.
-	tl *log.Logger
+	tl logging.Logger
 
 	// The function to use to recognize standard library import paths.
 	stdLibFn func(string) bool
@@ -206,6 +208,36 @@ func (params SolveParameters) toRootdata() (rootdata, error) {
 		rd.ovr = make(ProjectConstraints)
 	}
 
+	if sm, ok := params.Manifest.(SourceMapper); ok {
+		if srcs := sm.SourceMap(); len(srcs) > 0 {
+			rd.srcs = radix.New()
+			for pre, src := range srcs {
+				rd.srcs.Insert(pre, src)
+			}
+		}
+	}
+
+	if pm, ok := params.Manifest.(ProtocolMapper); ok {
+		if protos := pm.ProtocolMap(); len(protos) > 0 {
+			rd.protos = radix.New()
+			for pre, proto := range protos {
+				if !isTransportScheme(proto) {
+					return rootdata{}, badOptsFailure(fmt.Sprintf("protocol for %s must be ssh or https, not %q", pre, proto))
+				}
+				rd.protos.Insert(pre, proto)
+			}
+		}
+	}
+
+	if bl, ok := params.Manifest.(BanLister); ok {
+		if banned := bl.BannedProjects(); len(banned) > 0 {
+			rd.banned = radix.New()
+			for pre, reason := range banned {
+				rd.banned.Insert(pre, reason)
+			}
+		}
+	}
+
 	if rd.ir.Len() > 0 {
 		var both []string
 		for pkg := range params.Manifest.RequiredPackages() {
@@ -439,6 +471,7 @@ func (s *solver) Solve(ctx context.Context) (Solution, error) {
 
 	// Set up a metrics object
 	s.mtr = newMetrics()
+	defer depmetrics.Since(depmetrics.SolveSeconds, time.Now())
 
 	// Prime the queues with the root project
 	if err := s.selectRoot(); err != nil {
@@ -719,7 +752,7 @@ func (s *solver) getImportsAndConstraintsOf(a atomWithPackages) ([]string, []com
 	}
 	sort.Strings(reach)
 
-	deps := s.rd.ovr.overrideAll(m.DependencyConstraints())
+	deps := s.rd.overrideAll(m.DependencyConstraints())
 	cd, err := s.intersectConstraintsWithImports(deps, reach)
 	return pl, cd, err
 }
@@ -771,7 +804,7 @@ func (s *solver) intersectConstraintsWithImports(deps []workingConstraint, reach
 		}
 
 		// Make a new completeDep with an open constraint, respecting overrides
-		pd := s.rd.ovr.override(root, ProjectProperties{Constraint: Any()})
+		pd := s.rd.override(root, ProjectProperties{Constraint: Any()})
 
 		// Insert the pd into the trie so that further deps from this
 		// project get caught by the prefix search
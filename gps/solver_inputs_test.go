@@ -5,13 +5,12 @@
 package gps
 
 import (
-	"io/ioutil"
-	"log"
 	"math/rand"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/gps/pkgtree"
 	"github.com/golang/dep/internal/test"
 )
@@ -79,7 +78,7 @@ func TestBadSolveOpts(t *testing.T) {
 			},
 		},
 	}
-	params.TraceLogger = log.New(ioutil.Discard, "", 0)
+	params.TraceLogger = logging.Discard
 
 	params.Manifest = simpleRootManifest{
 		ovr: ProjectConstraints{
@@ -182,7 +181,7 @@ func TestValidateParams(t *testing.T) {
 	h.TempDir(cacheDir)
 	sm, err := NewSourceManager(SourceManagerConfig{
 		Cachedir: h.Path(cacheDir),
-		Logger:   log.New(test.Writer{TB: t}, "", 0),
+		Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
 	})
 	h.Must(err)
 	defer sm.Release()
@@ -8,10 +8,13 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"net/url"
+	"strings"
 	"sync"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/gps/pkgtree"
+	depmetrics "github.com/golang/dep/internal/metrics"
 	"github.com/pkg/errors"
 )
 
@@ -68,12 +71,29 @@ type sourceCoordinator struct {
 	protoSrcs  map[string][]chan srcReturn
 	cachedir   string
 	cache      sourceCache
-	logger     *log.Logger
+	logger     logging.Logger
+	clone      cloneOptions
+	remote     *remoteConfig
+
+	// moduleProxy, if set, selects the module proxy from which to fetch the
+	// projects on each host, as SourceManagerConfig.ModuleProxy.
+	moduleProxy func(host string) (*url.URL, bool)
+
+	// mirrors, as SourceManagerConfig.Mirrors, keep the projects they match
+	// from being fetched from module proxies.
+	mirrors mirrorList
+
+	// redirects records the sources that have moved.
+	redirects *redirectLog
+
+	// used maps the names of the directories of the sources used so far to
+	// the names of the projects they were used for. Guarded by srcmut.
+	used map[string]map[string]bool
 }
 
 // newSourceCoordinator returns a new sourceCoordinator.
 // Passing a nil sourceCache defaults to an in-memory cache.
-func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string, cache sourceCache, logger *log.Logger) *sourceCoordinator {
+func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string, cache sourceCache, logger logging.Logger) *sourceCoordinator {
 	if cache == nil {
 		cache = memoryCache{}
 	}
@@ -91,7 +111,7 @@ func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string,
 
 func (sc *sourceCoordinator) close() {
 	if err := sc.cache.close(); err != nil {
-		sc.logger.Println(errors.Wrap(err, "failed to close the source cache"))
+		sc.logger.Warnf("%s", errors.Wrap(err, "failed to close the source cache"))
 	}
 }
 
@@ -101,6 +121,9 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 	}
 
 	normalizedName := id.normalizedSource()
+	if repo, subdir := SplitSubdir(normalizedName); subdir != "" {
+		return sc.getSubdirGatewayFor(ctx, id, repo, subdir)
+	}
 
 	sc.srcmut.RLock()
 	if url, has := sc.nameToURL[normalizedName]; has {
@@ -190,6 +213,10 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 		return nil, err
 	}
 
+	if mb := sc.moduleProxySources(normalizedName, pd.root); mb != nil {
+		pd.mb = mb
+	}
+
 	// It'd be quite the feat - but not impossible - for a gateway
 	// corresponding to this normalizedName to have slid into the main
 	// sources map after the initial unlock, but before this goroutine got
@@ -223,14 +250,25 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 		}
 		if sg, has := sc.srcs[url]; has {
 			srcGate = sg
+			sc.noteUse(srcGate, normalizedName)
 			break
 		}
 		src, err := m.try(ctx, sc.cachedir)
 		if err == nil {
+			if cc, ok := src.(cloneConfigurer); ok {
+				cc.setCloneOptions(sc.clone)
+			}
+			if rc, ok := src.(remoteConfigurer); ok {
+				rc.setRemoteConfig(sc.remote)
+			}
+			if rf, ok := src.(redirectFollower); ok {
+				rf.setRedirectLog(sc.redirects)
+			}
 			cache := sc.cache.newSingleSourceCache(id)
 			srcGate, err = newSourceGateway(ctx, src, sc.supervisor, sc.cachedir, cache)
 			if err == nil {
 				sc.srcs[url] = srcGate
+				sc.noteUse(srcGate, normalizedName)
 				break
 			}
 		}
@@ -259,6 +297,25 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 	return srcGate, nil
 }
 
+// moduleProxySources returns the sources from which to fetch the project at
+// root from a module proxy, if one is configured for its host, and the project
+// was named by its import path rather than by a URL, and is not mirrored.
+// Otherwise, it returns nil.
+func (sc *sourceCoordinator) moduleProxySources(name, root string) maybeSources {
+	if sc.moduleProxy == nil || !pathvld.MatchString(name) {
+		return nil
+	}
+	if _, _, has := sc.mirrors.match(root); has {
+		return nil
+	}
+	host := strings.SplitN(root, "/", 2)[0]
+	proxy, has := sc.moduleProxy(host)
+	if !has || proxy == nil {
+		return nil
+	}
+	return maybeSources{maybeModuleProxySource{proxy: proxy, module: root}}
+}
+
 // sourceGateways manage all incoming calls for data from sources, serializing
 // and caching them as needed.
 type sourceGateway struct {
@@ -266,32 +323,31 @@ type sourceGateway struct {
 	srcState sourceState
 	src      source
 	cache    singleSourceCache
-	mu       sync.Mutex // global lock, serializes all behaviors
+	mu       *sync.Mutex // global lock, serializes all behaviors; shared by the projects rooted in the same repository
 	suprvsr  *supervisor
 }
 
 // newSourceGateway returns a new gateway for src. If the source exists locally,
 // the local state may be cleaned, otherwise we ping upstream.
 func newSourceGateway(ctx context.Context, src source, superv *supervisor, cachedir string, cache singleSourceCache) (*sourceGateway, error) {
-	var state sourceState
+	sg := &sourceGateway{
+		src:      src,
+		cachedir: cachedir,
+		cache:    cache,
+		mu:       &sync.Mutex{},
+		suprvsr:  superv,
+	}
+
 	local := src.existsLocally(ctx)
 	if local {
-		state |= sourceExistsLocally
+		sg.srcState |= sourceExistsLocally
 		if err := superv.do(ctx, src.upstreamURL(), ctValidateLocal, func(ctx context.Context) error {
 			return src.maybeClean(ctx)
-		}); err != nil {
+		}); err != nil && !sg.repairLocal(ctx) {
 			return nil, err
 		}
 	}
 
-	sg := &sourceGateway{
-		srcState: state,
-		src:      src,
-		cachedir: cachedir,
-		cache:    cache,
-		suprvsr:  superv,
-	}
-
 	if !local {
 		if err := sg.require(ctx, sourceExistsUpstream); err != nil {
 			return nil, err
@@ -326,6 +382,22 @@ func (sg *sourceGateway) exportVersionTo(ctx context.Context, v Version, to stri
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
 
+	// Pinned revisions of sources not yet retrieved are exported from
+	// archives of just those revisions, where their hosts serve them, rather
+	// than retrieving the whole source to export one revision.
+	if ra, ok := sg.src.(revisionArchiver); ok && sg.srcState&sourceExistsLocally == 0 && !sg.src.existsLocally(ctx) {
+		if r, has := revisionArchivable(v); has {
+			var archived bool
+			sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctExportTree, func(ctx context.Context) error {
+				archived = ra.exportRevisionArchive(ctx, r, to)
+				return nil
+			})
+			if archived {
+				return nil
+			}
+		}
+	}
+
 	err := sg.require(ctx, sourceExistsLocally)
 	if err != nil {
 		return err
@@ -353,6 +425,11 @@ func (sg *sourceGateway) exportVersionTo(ctx context.Context, v Version, to stri
 			})
 		}
 	}
+	if err != nil && sg.repairLocal(ctx) {
+		err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctExportTree, func(ctx context.Context) error {
+			return sg.src.exportRevisionTo(ctx, r, to)
+		})
+	}
 
 	return err
 }
@@ -368,8 +445,10 @@ func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot,
 
 	m, l, has := sg.cache.getManifestAndLock(r, an.Info())
 	if has {
+		depmetrics.Add(depmetrics.SourceCacheHits, 1)
 		return m, l, nil
 	}
+	depmetrics.Add(depmetrics.SourceCacheMisses, 1)
 
 	err = sg.require(ctx, sourceExistsLocally)
 	if err != nil {
@@ -401,6 +480,12 @@ func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot,
 			return err
 		})
 	}
+	if err != nil && sg.repairLocal(ctx) {
+		err = sg.suprvsr.do(ctx, label, ctGetManifestAndLock, func(ctx context.Context) error {
+			m, l, err = sg.src.getManifestAndLock(ctx, pr, r, an)
+			return err
+		})
+	}
 
 	if err != nil {
 		return nil, nil, err
@@ -421,8 +506,10 @@ func (sg *sourceGateway) listPackages(ctx context.Context, pr ProjectRoot, v Ver
 
 	ptree, has := sg.cache.getPackageTree(r, pr)
 	if has {
+		depmetrics.Add(depmetrics.SourceCacheHits, 1)
 		return ptree, nil
 	}
+	depmetrics.Add(depmetrics.SourceCacheMisses, 1)
 
 	err = sg.require(ctx, sourceExistsLocally)
 	if err != nil {
@@ -454,6 +541,12 @@ func (sg *sourceGateway) listPackages(ctx context.Context, pr ProjectRoot, v Ver
 			return err
 		})
 	}
+	if err != nil && sg.repairLocal(ctx) {
+		err = sg.suprvsr.do(ctx, label, ctListPackages, func(ctx context.Context) error {
+			ptree, err = sg.src.listPackages(ctx, pr, r)
+			return err
+		})
+	}
 
 	if err != nil {
 		return pkgtree.PackageTree{}, err
@@ -535,7 +628,7 @@ func (sg *sourceGateway) revisionPresentIn(ctx context.Context, r Revision) (boo
 		return true, nil
 	}
 
-	present, err := sg.src.revisionPresentIn(r)
+	present, err := sg.src.revisionPresentIn(ctx, r)
 	if err == nil && present {
 		sg.cache.markRevisionExists(r)
 	}
@@ -560,7 +653,7 @@ func (sg *sourceGateway) sourceExistsUpstream(ctx context.Context) (sourceState,
 	if sg.src.existsCallsListVersions() {
 		return sg.loadLatestVersionList(ctx)
 	}
-	err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourcePing, func(ctx context.Context) error {
+	err := sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctSourcePing, func(ctx context.Context) error {
 		if !sg.src.existsUpstream(ctx) {
 			return errors.Errorf("source does not exist upstream: %s: %s", sg.src.sourceType(), sg.src.upstreamURL())
 		}
@@ -571,7 +664,7 @@ func (sg *sourceGateway) sourceExistsUpstream(ctx context.Context) (sourceState,
 
 // initLocal initializes the source locally and returns the resulting sourceState.
 func (sg *sourceGateway) initLocal(ctx context.Context) (sourceState, error) {
-	if err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceInit, func(ctx context.Context) error {
+	if err := sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctSourceInit, func(ctx context.Context) error {
 		err := sg.src.initLocal(ctx)
 		return errors.Wrapf(err, "failed to fetch source for %s", sg.src.upstreamURL())
 	}); err != nil {
@@ -592,12 +685,18 @@ func (sg *sourceGateway) loadLatestVersionList(ctx context.Context) (sourceState
 		addlState |= as
 	}
 	var pvl []PairedVersion
-	if err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctListVersions, func(ctx context.Context) error {
+	if err := sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctListVersions, func(ctx context.Context) error {
 		var err error
 		pvl, err = sg.src.listVersions(ctx)
 		return errors.Wrapf(err, "failed to list versions for %s", sg.src.upstreamURL())
 	}); err != nil {
-		return addlState, err
+		// Sources imported from bundles may be used without reaching their
+		// upstream, as they were when they were bundled.
+		bpvl, has := bundledVersions(sg.cachedir, sg.src)
+		if !has || ctx.Err() != nil {
+			return addlState, err
+		}
+		pvl = bpvl
 	}
 	sg.cache.setVersionMap(pvl)
 	return addlState | sourceHasLatestVersionList, nil
@@ -628,9 +727,13 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (err e
 					addlState, err = sg.loadLatestVersionList(ctx)
 				}
 			case sourceHasLatestLocally:
-				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceFetch, func(ctx context.Context) error {
+				err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctSourceFetch, func(ctx context.Context) error {
 					return sg.src.updateLocal(ctx)
 				})
+				if err != nil && sg.repairLocal(ctx) {
+					// The source was retrieved afresh, so it is up to date.
+					err = nil
+				}
 				addlState = sourceExistsUpstream | sourceExistsLocally
 			}
 
@@ -663,7 +766,7 @@ type source interface {
 	listVersions(context.Context) ([]PairedVersion, error)
 	getManifestAndLock(context.Context, ProjectRoot, Revision, ProjectAnalyzer) (Manifest, Lock, error)
 	listPackages(context.Context, ProjectRoot, Revision) (pkgtree.PackageTree, error)
-	revisionPresentIn(Revision) (bool, error)
+	revisionPresentIn(context.Context, Revision) (bool, error)
 	disambiguateRevision(context.Context, Revision) (Revision, error)
 	exportRevisionTo(context.Context, Revision, string) error
 	sourceType() string
@@ -674,3 +777,28 @@ type source interface {
 	// requires the source to exist locally.
 	listVersionsRequiresLocal() bool
 }
+
+// cloneOptions control how much of a source initLocal retrieves. Whatever is
+// left out is fetched as it is needed.
+type cloneOptions struct {
+	shallow bool // Leave out all but the latest commit of each branch.
+	partial bool // Leave out the contents of files.
+}
+
+// cloneConfigurer is an optional extension of source, for sources that can be
+// retrieved without all of their history or contents.
+type cloneConfigurer interface {
+	setCloneOptions(cloneOptions)
+}
+
+// remoteConfigurer is an optional extension of source, for sources that can
+// reach their upstream with configured credentials and proxies.
+type remoteConfigurer interface {
+	setRemoteConfig(*remoteConfig)
+}
+
+// redirectFollower is an optional extension of source, for sources whose
+// upstream can move, and that follow it to where it moved.
+type redirectFollower interface {
+	setRedirectLog(*redirectLog)
+}
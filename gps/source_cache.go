@@ -73,10 +73,38 @@ type singleSourceCache interface {
 }
 
 // memoryCache is a sourceCache which creates singleSourceCacheMemory instances.
-type memoryCache struct{}
+//
+// The zero value keeps everything. When another cache keeps the package trees
+// of revisions, as the persistent cache does, the memoryCache in front of it
+// need only keep those in use: the solver tries version after version of a
+// project, and keeping the trees of all of them would take far more memory
+// than the few being looked at.
+type memoryCache struct {
+	// strs, if not nil, interns the strings of the package trees kept by
+	// the caches.
+	strs *stringInterner
+	// maxTrees, if positive, is the most package trees each cache keeps;
+	// those used least recently are dropped to make room for others.
+	maxTrees int
+}
+
+// memoryCacheTrees is the number of package trees kept per source by the
+// memoryCache in front of the persistent cache. The solver only ever has one
+// version of a project selected, so this leaves room for that, and for those
+// whose trees are looked at while others are tried.
+const memoryCacheTrees = 8
+
+// newTreeLimitedMemoryCache returns a memoryCache interning the strings of the
+// package trees of all of its caches, each of which keeps no more than
+// maxTrees of them.
+func newTreeLimitedMemoryCache(maxTrees int) memoryCache {
+	return memoryCache{strs: newStringInterner(), maxTrees: maxTrees}
+}
 
-func (memoryCache) newSingleSourceCache(ProjectIdentifier) singleSourceCache {
-	return newMemoryCache()
+func (c memoryCache) newSingleSourceCache(ProjectIdentifier) singleSourceCache {
+	mc := newMemoryCache().(*singleSourceCacheMemory)
+	mc.strs, mc.maxTrees = c.strs, c.maxTrees
+	return mc
 }
 
 func (memoryCache) close() error { return nil }
@@ -91,6 +119,13 @@ type singleSourceCacheMemory struct {
 	vList []PairedVersion
 	vMap  map[UnpairedVersion]Revision
 	rMap  map[Revision][]UnpairedVersion
+
+	// strs and maxTrees are as in memoryCache. If maxTrees is positive,
+	// treeUse holds the revisions in ptrees, in the order their trees were
+	// last used.
+	strs     *stringInterner
+	maxTrees int
+	treeUse  []Revision
 }
 
 func newMemoryCache() singleSourceCache {
@@ -141,14 +176,21 @@ func (c *singleSourceCacheMemory) getManifestAndLock(r Revision, pai ProjectAnal
 }
 
 func (c *singleSourceCacheMemory) setPackageTree(r Revision, ptree pkgtree.PackageTree) {
-	// Make a copy, with relative import paths.
+	// Make a copy, with relative import paths. The same imports and names
+	// recur throughout the trees of a project's revisions, so each is
+	// interned rather than kept over and over.
 	pkgs := pkgtree.CopyPackages(ptree.Packages, func(ip string, poe pkgtree.PackageOrErr) (string, pkgtree.PackageOrErr) {
 		poe.P.ImportPath = "" // Don't store this
-		return strings.TrimPrefix(ip, ptree.ImportRoot), poe
+		poe.P.Name = c.strs.intern(poe.P.Name)
+		poe.P.CommentPath = c.strs.intern(poe.P.CommentPath)
+		c.strs.internAll(poe.P.Imports)
+		c.strs.internAll(poe.P.TestImports)
+		return c.strs.intern(strings.TrimPrefix(ip, ptree.ImportRoot)), poe
 	})
 
 	c.mut.Lock()
 	c.ptrees[r] = pkgs
+	c.useTree(r)
 
 	// Ensure there's at least an entry in the rMap so that the rMap always has
 	// a complete picture of the revisions we know to exist
@@ -161,6 +203,9 @@ func (c *singleSourceCacheMemory) setPackageTree(r Revision, ptree pkgtree.Packa
 func (c *singleSourceCacheMemory) getPackageTree(r Revision, pr ProjectRoot) (pkgtree.PackageTree, bool) {
 	c.mut.Lock()
 	rptree, has := c.ptrees[r]
+	if has {
+		c.useTree(r)
+	}
 	c.mut.Unlock()
 
 	if !has {
@@ -182,6 +227,26 @@ func (c *singleSourceCacheMemory) getPackageTree(r Revision, pr ProjectRoot) (pk
 	}, true
 }
 
+// useTree records the use of the tree of r, dropping the tree used least
+// recently if c now keeps more than it may. The caller must hold c.mut.
+func (c *singleSourceCacheMemory) useTree(r Revision) {
+	if c.maxTrees <= 0 {
+		return
+	}
+
+	for i, ur := range c.treeUse {
+		if ur == r {
+			c.treeUse = append(c.treeUse[:i], c.treeUse[i+1:]...)
+			break
+		}
+	}
+	c.treeUse = append(c.treeUse, r)
+	if len(c.treeUse) > c.maxTrees {
+		delete(c.ptrees, c.treeUse[0])
+		c.treeUse = append(c.treeUse[:0], c.treeUse[1:]...)
+	}
+}
+
 func (c *singleSourceCacheMemory) setVersionMap(versionList []PairedVersion) {
 	c.mut.Lock()
 	c.vList = versionList
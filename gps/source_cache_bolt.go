@@ -5,8 +5,6 @@
 package gps
 
 import (
-	"fmt"
-	"log"
 	"os"
 	"path"
 	"path/filepath"
@@ -15,6 +13,7 @@ import (
 
 	"github.com/boltdb/bolt"
 	"github.com/golang/dep/gps/internal/pb"
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/gps/pkgtree"
 	"github.com/golang/protobuf/proto"
 	"github.com/jmank88/nuts"
@@ -28,12 +27,12 @@ const boltCacheFilename = "bolt-v1.db"
 // boltCache manages a bolt.DB cache and provides singleSourceCaches.
 type boltCache struct {
 	db     *bolt.DB
-	epoch  int64       // getters will not return values older than this unix timestamp
-	logger *log.Logger // info logging
+	epoch  int64          // getters will not return values older than this unix timestamp
+	logger logging.Logger // warns of cache failures
 }
 
 // newBoltCache returns a new boltCache backed by a BoltDB file under the cache directory.
-func newBoltCache(cd string, epoch int64, logger *log.Logger) (*boltCache, error) {
+func newBoltCache(cd string, epoch int64, logger logging.Logger) (*boltCache, error) {
 	path := filepath.Join(cd, boltCacheFilename)
 	dir := filepath.Dir(path)
 	if fi, err := os.Stat(dir); os.IsNotExist(err) {
@@ -148,7 +147,7 @@ func (s *singleSourceCacheBolt) setManifestAndLock(rev Revision, ai ProjectAnaly
 		return errors.Wrap(cachePutLock(lb, l), "failed to put lock")
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrapf(err, "failed to cache manifest/lock for revision %q, analyzer: %v", rev, ai))
+		s.logger.Warnf("%s", errors.Wrapf(err, "failed to cache manifest/lock for revision %q, analyzer: %v", rev, ai))
 	}
 }
 
@@ -186,7 +185,7 @@ func (s *singleSourceCacheBolt) getManifestAndLock(rev Revision, ai ProjectAnaly
 		return nil
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrapf(err, "failed to get cached manifest/lock for revision %q, analyzer: %v", rev, ai))
+		s.logger.Warnf("%s", errors.Wrapf(err, "failed to get cached manifest/lock for revision %q, analyzer: %v", rev, ai))
 	}
 	return
 }
@@ -222,7 +221,7 @@ func (s *singleSourceCacheBolt) setPackageTree(rev Revision, ptree pkgtree.Packa
 		return nil
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrapf(err, "failed to cache package tree for revision %q", rev))
+		s.logger.Warnf("%s", errors.Wrapf(err, "failed to cache package tree for revision %q", rev))
 	}
 }
 
@@ -260,7 +259,7 @@ func (s *singleSourceCacheBolt) getPackageTree(rev Revision, pr ProjectRoot) (pt
 		return nil
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrapf(err, "failed to get cached package tree for revision %q", rev))
+		s.logger.Warnf("%s", errors.Wrapf(err, "failed to get cached package tree for revision %q", rev))
 	}
 	return
 }
@@ -270,7 +269,7 @@ func (s *singleSourceCacheBolt) markRevisionExists(rev Revision) {
 		return nil
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrapf(err, "failed to mark revision %q in cache", rev))
+		s.logger.Warnf("%s", errors.Wrapf(err, "failed to mark revision %q in cache", rev))
 	}
 }
 
@@ -334,7 +333,7 @@ func (s *singleSourceCacheBolt) setVersionMap(pvs []PairedVersion) {
 		return nil
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrap(err, "failed to cache version map"))
+		s.logger.Warnf("%s", errors.Wrap(err, "failed to cache version map"))
 	}
 }
 
@@ -361,7 +360,7 @@ func (s *singleSourceCacheBolt) getVersionsFor(rev Revision) (uvs []UnpairedVers
 		})
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrapf(err, "failed to get cached versions for revision %q", rev))
+		s.logger.Warnf("%s", errors.Wrapf(err, "failed to get cached versions for revision %q", rev))
 		return nil, false
 	}
 	return
@@ -389,7 +388,7 @@ func (s *singleSourceCacheBolt) getAllVersions() (pvs []PairedVersion, ok bool)
 		})
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrap(err, "failed to get all cached versions"))
+		s.logger.Warnf("%s", errors.Wrap(err, "failed to get all cached versions"))
 		return nil, false
 	}
 	return
@@ -417,7 +416,7 @@ func (s *singleSourceCacheBolt) getRevisionFor(uv UnpairedVersion) (rev Revision
 		return nil
 	})
 	if err != nil {
-		s.logger.Println(errors.Wrapf(err, "failed to get cached revision for unpaired version: %v", uv))
+		s.logger.Warnf("%s", errors.Wrapf(err, "failed to get cached revision for unpaired version: %v", uv))
 	}
 	return
 }
@@ -431,7 +430,7 @@ func (s *singleSourceCacheBolt) toRevision(v Version) (rev Revision, ok bool) {
 	case UnpairedVersion:
 		return s.getRevisionFor(t)
 	default:
-		s.logger.Println(fmt.Sprintf("failed to get cached revision for version %v: unknown type %T", v, v))
+		s.logger.Warnf("failed to get cached revision for version %v: unknown type %T", v, v)
 		return "", false
 	}
 }
@@ -468,11 +467,11 @@ func (s *singleSourceCacheBolt) toUnpaired(v Version) (uv UnpairedVersion, ok bo
 			return nil
 		})
 		if err != nil {
-			s.logger.Println(errors.Wrapf(err, errMsg, v))
+			s.logger.Warnf("%s", errors.Wrapf(err, errMsg, v))
 		}
 		return
 	default:
-		s.logger.Println(fmt.Sprintf(errMsg, v))
+		s.logger.Warnf(errMsg, v)
 		return
 	}
 }
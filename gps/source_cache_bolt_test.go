@@ -6,11 +6,11 @@ package gps
 
 import (
 	"io/ioutil"
-	"log"
 	"path"
 	"testing"
 	"time"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/gps/pkgtree"
 	"github.com/golang/dep/internal/test"
 )
@@ -22,7 +22,7 @@ func TestBoltCacheTimeout(t *testing.T) {
 		t.Fatalf("Failed to create temp cache dir: %s", err)
 	}
 	pi := ProjectIdentifier{ProjectRoot: root}
-	logger := log.New(test.Writer{TB: t}, "", 0)
+	logger := logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug})
 
 	start := time.Now()
 	bc, err := newBoltCache(cpath, start.Unix(), logger)
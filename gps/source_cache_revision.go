@@ -0,0 +1,70 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+// revisionCache is a sourceCache that keeps only what is recorded of
+// revisions in disk, its backing cache: their package trees, and their
+// manifests and locks. As revisions never change, these never go stale, and
+// may be kept however old they are. The versions of sources, which may move
+// to other revisions at any time, are not kept, nor looked up.
+//
+// It is used in place of disk when the persistent cache is not to be trusted
+// with versions, so that the sources at revisions that were already analyzed
+// are never parsed again.
+type revisionCache struct {
+	disk sourceCache
+}
+
+func (c revisionCache) newSingleSourceCache(id ProjectIdentifier) singleSourceCache {
+	return singleSourceRevisionCache{c.disk.newSingleSourceCache(id)}
+}
+
+func (c revisionCache) close() error {
+	return c.disk.close()
+}
+
+// singleSourceRevisionCache passes the manifests, locks and package trees of
+// revisions to and from its singleSourceCache, and drops versions.
+type singleSourceRevisionCache struct {
+	singleSourceCache
+}
+
+func (singleSourceRevisionCache) markRevisionExists(Revision) {}
+
+func (singleSourceRevisionCache) setVersionMap([]PairedVersion) {}
+
+func (singleSourceRevisionCache) getVersionsFor(Revision) ([]UnpairedVersion, bool) {
+	return nil, false
+}
+
+func (singleSourceRevisionCache) getAllVersions() ([]PairedVersion, bool) {
+	return nil, false
+}
+
+func (singleSourceRevisionCache) getRevisionFor(UnpairedVersion) (Revision, bool) {
+	return "", false
+}
+
+func (singleSourceRevisionCache) toRevision(v Version) (Revision, bool) {
+	switch t := v.(type) {
+	case Revision:
+		return t, true
+	case PairedVersion:
+		return t.Revision(), true
+	default:
+		return "", false
+	}
+}
+
+func (singleSourceRevisionCache) toUnpaired(v Version) (UnpairedVersion, bool) {
+	switch t := v.(type) {
+	case UnpairedVersion:
+		return t, true
+	case PairedVersion:
+		return t.Unpair(), true
+	default:
+		return nil, false
+	}
+}
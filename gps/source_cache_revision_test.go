@@ -0,0 +1,76 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestRevisionCache(t *testing.T) {
+	const root = "example.com/test"
+	cpath, err := ioutil.TempDir("", "revisioncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+	pi := ProjectIdentifier{ProjectRoot: root}
+	logger := logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug})
+
+	rev := Revision("c2a2d9a4ba62e1db8e4b3e4a7e3bcc2a0c83e5e1")
+	ptree := pkgtree.PackageTree{
+		ImportRoot: root,
+		Packages: map[string]pkgtree.PackageOrErr{
+			root: {P: pkgtree.Package{ImportPath: root, Name: "test", Imports: []string{"sort"}}},
+		},
+	}
+
+	bc, err := newBoltCache(cpath, time.Now().Unix(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := revisionCache{bc}.newSingleSourceCache(pi)
+	c.setPackageTree(rev, ptree)
+	c.setVersionMap([]PairedVersion{NewVersion("v1.0.0").Pair(rev)})
+
+	if _, ok := c.getAllVersions(); ok {
+		t.Error("expected no versions from a revision cache")
+	}
+	if r, ok := c.toRevision(NewVersion("v1.0.0").Pair(rev)); !ok || r != rev {
+		t.Errorf("unexpected revision of a paired version: %q, %v", r, ok)
+	}
+	if _, ok := c.toRevision(NewVersion("v1.0.0")); ok {
+		t.Error("expected an unpaired version not to be converted to a revision")
+	}
+	if err := bc.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The package tree outlives the cache it was set in, and is found even by
+	// caches that trust versions no older than now; the versions were never
+	// kept.
+	bc, err = newBoltCache(cpath, time.Now().Add(time.Hour).Unix(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.close()
+	c = bc.newSingleSourceCache(pi)
+	got, ok := c.getPackageTree(rev, root)
+	if !ok {
+		t.Fatal("expected the package tree to be kept")
+	}
+	comparePackageTree(t, ptree, got)
+
+	bc.epoch = 0
+	if _, ok := c.getAllVersions(); ok {
+		t.Error("expected no versions to be kept")
+	}
+}
@@ -6,13 +6,13 @@ package gps
 
 import (
 	"io/ioutil"
-	"log"
 	"path"
 	"reflect"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/gps/pkgtree"
 	"github.com/golang/dep/internal/test"
 	"github.com/pkg/errors"
@@ -23,10 +23,15 @@ func Test_singleSourceCache(t *testing.T) {
 		return memoryCache{}
 	}
 	t.Run("mem", singleSourceCacheTest{newCache: newMem}.run)
+	t.Run("mem/limited", singleSourceCacheTest{
+		newCache: func(*testing.T, string) sourceCache {
+			return newTreeLimitedMemoryCache(memoryCacheTrees)
+		},
+	}.run)
 
 	epoch := time.Now().Unix()
 	newBolt := func(t *testing.T, cachedir string) sourceCache {
-		bc, err := newBoltCache(cachedir, epoch, log.New(test.Writer{TB: t}, "", 0))
+		bc, err := newBoltCache(cachedir, epoch, logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -36,7 +41,7 @@ func Test_singleSourceCache(t *testing.T) {
 	t.Run("bolt/reOpen", singleSourceCacheTest{newCache: newBolt, persistent: true}.run)
 
 	newMulti := func(t *testing.T, cachedir string) sourceCache {
-		bc, err := newBoltCache(cachedir, epoch, log.New(test.Writer{TB: t}, "", 0))
+		bc, err := newBoltCache(cachedir, epoch, logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -54,7 +59,7 @@ func Test_singleSourceCache(t *testing.T) {
 	t.Run("multi/reOpen/noMem", singleSourceCacheTest{
 		persistent: true,
 		newCache: func(t *testing.T, cachedir string) sourceCache {
-			bc, err := newBoltCache(cachedir, epoch, log.New(test.Writer{TB: t}, "", 0))
+			bc, err := newBoltCache(cachedir, epoch, logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -63,6 +68,43 @@ func Test_singleSourceCache(t *testing.T) {
 	}.run)
 }
 
+func Test_singleSourceCacheMemoryTreeLimit(t *testing.T) {
+	const root = "example.com/test"
+	c := newTreeLimitedMemoryCache(2).newSingleSourceCache(mkPI(root).normalize())
+
+	mkTree := func() pkgtree.PackageTree {
+		return pkgtree.PackageTree{
+			ImportRoot: root,
+			Packages: map[string]pkgtree.PackageOrErr{
+				root: {P: pkgtree.Package{
+					ImportPath: root,
+					Name:       "test",
+					Imports:    []string{"github.com/foo/bar", "sort"},
+				}},
+			},
+		}
+	}
+	revs := []Revision{"rev1", "rev2", "rev3"}
+	c.setPackageTree(revs[0], mkTree())
+	c.setPackageTree(revs[1], mkTree())
+	// Using the first tree leaves the second as the one used least recently.
+	if _, ok := c.getPackageTree(revs[0], root); !ok {
+		t.Fatalf("expected the tree of %s to be kept", revs[0])
+	}
+	c.setPackageTree(revs[2], mkTree())
+
+	for i, want := range []bool{true, false, true} {
+		got, ok := c.getPackageTree(revs[i], root)
+		if ok != want {
+			t.Errorf("expected the tree of %s to be kept: %v, got %v", revs[i], want, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(got, mkTree()) {
+			t.Errorf("expected the tree of %s to be returned intact:\n\t(GOT): %#v\n\t(WNT): %#v", revs[i], got, mkTree())
+		}
+	}
+}
+
 var testAnalyzerInfo = ProjectAnalyzerInfo{
 	Name:    "test-analyzer",
 	Version: 1,
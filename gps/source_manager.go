@@ -7,8 +7,6 @@ package gps
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/url"
 	"os"
 	"os/signal"
@@ -19,7 +17,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/events"
 	"github.com/golang/dep/internal/fs"
 	"github.com/nightlyone/lockfile"
 	"github.com/pkg/errors"
@@ -166,6 +166,10 @@ type SourceMgr struct {
 	qch         chan struct{}         // quit chan for signal handler
 	relonce     sync.Once             // once-er to ensure we only release once
 	releasing   int32                 // flag indicating release of sm has begun
+	gc          CacheGCPolicy         // how the cache is garbage collected on release
+	lockmut     sync.Mutex            // mutex protecting locks
+	locks       map[string][]string   // the locks recorded, by project root
+	daemon      *daemonClient         // the daemon calls are made to, if any
 }
 
 var _ SourceManager = &SourceMgr{}
@@ -177,10 +181,99 @@ var ErrSourceManagerIsReleased = fmt.Errorf("this SourceManager has been release
 
 // SourceManagerConfig holds configuration information for creating SourceMgrs.
 type SourceManagerConfig struct {
-	CacheAge       time.Duration // Maximum valid age of cached data. <=0: Don't cache.
-	Cachedir       string        // Where to store local instances of upstream sources.
-	Logger         *log.Logger   // Optional info/warn logger. Discards if nil.
-	DisableLocking bool          // True if the SourceManager should NOT use a lock file to protect the Cachedir from multiple processes.
+	CacheAge       time.Duration  // Maximum valid age of cached versions. <=0: Don't cache them.
+	Cachedir       string         // Where to store local instances of upstream sources.
+	Logger         logging.Logger // Optional leveled logger. Discards if nil.
+	DisableLocking bool           // True if the SourceManager should NOT use a lock file to protect the Cachedir from multiple processes.
+	ShallowClones  bool           // True if git sources should be cloned shallowly, fetching older history only as it is needed.
+	PartialClones  bool           // True if git sources should be cloned without file contents, fetching them only as they are needed.
+	VersionAPI     bool           // True if the versions of git sources on GitHub and GitLab should be listed through their APIs, rather than with git.
+
+	// RestrictedCommands, if set, runs VCS commands in a restricted profile,
+	// for fetching untrusted sources: with only a few variables of dep's
+	// environment, without the system's or the user's VCS configuration,
+	// and with git's hooks, filesystem monitors, credential helpers and
+	// ext:: transport turned off. Nothing is allowed to prompt for input.
+	RestrictedCommands bool
+
+	// Credentials maps hosts to the credentials used to authenticate to them
+	// over HTTPS, both when fetching go-get metadata and when cloning and
+	// fetching git sources.
+	Credentials map[string]Credentials
+
+	// Proxy, if set, returns the proxy through which to reach host, over
+	// HTTP(S), and whether one is configured for it. A nil URL with true
+	// means that host is reached directly. Hosts for which it returns false
+	// are reached through the proxies set in the environment, if any.
+	Proxy func(host string) (*url.URL, bool)
+
+	// ModuleProxy, if set, returns the module proxy from which to fetch the
+	// projects on host, instead of from their repositories, and whether one
+	// is configured for it. A nil URL with true means that the projects are
+	// fetched from their repositories. Module proxies are servers speaking
+	// the protocol of the go command's GOPROXY.
+	ModuleProxy func(host string) (*url.URL, bool)
+
+	// MetadataCacheAge is how long go-get metadata, fetched to deduce the
+	// roots and sources of vanity import paths, is cached in Cachedir before
+	// it is fetched again. Cached metadata, however old, is also used when
+	// fetching it fails, so that solving can continue while the host of a
+	// vanity import path is unreachable. <=0: Don't cache.
+	MetadataCacheAge time.Duration
+
+	// MetadataOverrides maps import path prefixes to the go-get metadata used
+	// for import paths under them, in place of that served by their hosts.
+	// The Root of each GoImport is ignored, and taken to be its key.
+	MetadataOverrides map[string]GoImport
+
+	// Mirrors maps import path patterns to the URLs of the repositories from
+	// which the projects matching them are fetched, in place of those their
+	// import paths would otherwise lead to. Patterns are import path
+	// prefixes, in which a "*" element matches any single path element; the
+	// elements matched by a pattern are the root of the project. Each "*"
+	// in a URL is replaced by the element matched by the corresponding "*"
+	// in its pattern. Mirrors are used without any network access to deduce
+	// them, and in place of module proxies.
+	Mirrors map[string]string
+
+	// HostMatchers deduce the roots and sources of the import paths on hosts
+	// whose repositories are not at the usual host/owner/repo paths, in
+	// addition to, and in place of, the built-in ones for Azure DevOps and AWS
+	// CodeCommit.
+	HostMatchers []HostMatcher
+
+	// Retry controls how network operations are retried, and how long they
+	// may take. The zero value attempts each of them once, without limit.
+	Retry RetryPolicy
+
+	// CacheGC controls how the sources in Cachedir are garbage collected
+	// when the SourceMgr is released. The zero value never removes them.
+	CacheGC CacheGCPolicy
+
+	// ChecksumDB, if set, is the checksum database against which the contents
+	// of the versions of projects fetched from module proxies are checked as
+	// they are downloaded.
+	ChecksumDB *ChecksumDB
+
+	// Daemon, if set, is the path of the unix socket on which a source
+	// manager daemon, started with ServeSourceManager, listens. If the daemon
+	// can be reached, and manages Cachedir, the SourceMgr makes its calls to
+	// the daemon instead of managing the cache itself, and the rest of the
+	// configuration is that of the daemon. Otherwise, the failure is logged,
+	// and the SourceMgr manages the cache itself.
+	Daemon string
+}
+
+// Credentials are a username and password, or token, used to authenticate to a
+// host over HTTPS.
+type Credentials struct {
+	Username, Password string
+
+	// Header, if set, names the HTTP header in which Password is sent as it
+	// is, rather than sending Username and Password with basic
+	// authentication. Tokens sent as "Authorization: Bearer <token>" have a
+	// Header of Authorization, and a Password of "Bearer <token>".
+	Header string
 }
 
 // NewSourceManager produces an instance of gps's built-in SourceManager.
@@ -192,15 +285,15 @@ type SourceManagerConfig struct {
 // solver can benefit from any caches that may have already been warmed.
 //
 // A cacheEpoch is calculated from now()-cacheAge, and older persistent cache data
-// is discarded. When cacheAge is <= 0, the persistent cache is
-// not used.
+// is discarded. When cacheAge is <= 0, the persistent cache holds only the
+// package trees, manifests and locks of revisions, which never go stale.
 //
 // gps's SourceManager is intended to be threadsafe (if it's not, please file a
 // bug!). It should be safe to reuse across concurrent solving runs, even on
 // unrelated projects.
 func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 	if c.Logger == nil {
-		c.Logger = log.New(ioutil.Discard, "", 0)
+		c.Logger = logging.Discard
 	}
 
 	err := fs.EnsureDir(filepath.Join(c.Cachedir, "sources"), 0777)
@@ -208,6 +301,35 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 		return nil, err
 	}
 
+	mirrors, err := newMirrorList(c.Mirrors)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := newHostMatchers(c.HostMatchers)
+	if err != nil {
+		return nil, err
+	}
+	sumdb, err := newChecksumDB(c.ChecksumDB, c.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Daemon != "" {
+		dc, err := dialDaemon(c.Daemon, c.Cachedir)
+		if err == nil {
+			ctx, cf := context.WithCancel(context.TODO())
+			return &SourceMgr{
+				cachedir:  c.Cachedir,
+				lf:        falseLocker{},
+				suprvsr:   newSupervisor(ctx),
+				cancelAll: cf,
+				qch:       make(chan struct{}),
+				daemon:    dc,
+			}, nil
+		}
+		c.Logger.Warnf("Not using the source manager daemon at %s: %s", c.Daemon, err)
+	}
+
 	// Fix for #820
 	//
 	// Consult https://godoc.org/github.com/nightlyone/lockfile for the lockfile
@@ -282,18 +404,30 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 
 	ctx, cf := context.WithCancel(context.TODO())
 	superv := newSupervisor(ctx)
+	superv.policy = c.Retry
+	superv.restricted = c.RestrictedCommands
+	superv.logger = c.Logger
 	deducer := newDeductionCoordinator(superv)
-
+	remote := newRemoteConfig(c, sumdb)
+	deducer.remote = remote
+	deducer.meta = newMetadataCache(c)
+	deducer.mirrors = mirrors
+	deducer.hosts = hosts
+	redirects := newRedirectLog(c.Cachedir, c.Logger)
+	deducer.redirects = redirects
+
+	// The BoltDB cache on disk always keeps the package trees, manifests and
+	// locks of revisions, which cannot change. Versions are only kept in it,
+	// and trusted for as long as CacheAge, if that is positive.
 	var sc sourceCache
-	if c.CacheAge > 0 {
-		// Try to open the BoltDB cache from disk.
-		epoch := time.Now().Add(-c.CacheAge).Unix()
-		boltCache, err := newBoltCache(c.Cachedir, epoch, c.Logger)
-		if err != nil {
-			c.Logger.Println(errors.Wrapf(err, "failed to open persistent cache %q", c.Cachedir))
-		} else {
-			sc = newMultiCache(memoryCache{}, boltCache)
-		}
+	epoch := time.Now().Add(-c.CacheAge).Unix()
+	boltCache, err := newBoltCache(c.Cachedir, epoch, c.Logger)
+	if err != nil {
+		c.Logger.Warnf("%s", errors.Wrapf(err, "failed to open persistent cache %q", c.Cachedir))
+	} else if c.CacheAge > 0 {
+		sc = newMultiCache(newTreeLimitedMemoryCache(memoryCacheTrees), boltCache)
+	} else {
+		sc = newMultiCache(newTreeLimitedMemoryCache(memoryCacheTrees), revisionCache{boltCache})
 	}
 
 	sm := &SourceMgr{
@@ -304,7 +438,13 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 		deduceCoord: deducer,
 		srcCoord:    newSourceCoordinator(superv, deducer, c.Cachedir, sc, c.Logger),
 		qch:         make(chan struct{}),
+		gc:          c.CacheGC,
 	}
+	sm.srcCoord.clone = cloneOptions{shallow: c.ShallowClones, partial: c.PartialClones}
+	sm.srcCoord.remote = remote
+	sm.srcCoord.moduleProxy = c.ModuleProxy
+	sm.srcCoord.mirrors = mirrors
+	sm.srcCoord.redirects = redirects
 
 	return sm, nil
 }
@@ -407,12 +547,21 @@ func (sm *SourceMgr) Release() {
 		sm.cancelAll()
 		sm.suprvsr.wait()
 
-		// Close the source coordinator.
-		sm.srcCoord.close()
+		if sm.daemon != nil {
+			// The daemon keeps the cache; only the connection to it is let go.
+			sm.daemon.close()
+		} else {
+			// Close the source coordinator.
+			sm.srcCoord.close()
 
-		// Close the file handle for the lock file and remove it from disk
-		sm.lf.Unlock()
-		os.Remove(filepath.Join(sm.cachedir, "sm.lock"))
+			// Record the use of the cache, and garbage collect it if it is
+			// time to, while it is still locked.
+			sm.maybeCollectGarbage()
+
+			// Close the file handle for the lock file and remove it from disk
+			sm.lf.Unlock()
+			os.Remove(filepath.Join(sm.cachedir, "sm.lock"))
+		}
 
 		// Close the qch, if non-nil, so the signal handlers run out. This will
 		// also deregister the sig channel, if any has been set up.
@@ -430,6 +579,9 @@ func (sm *SourceMgr) GetManifestAndLock(id ProjectIdentifier, v Version, an Proj
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return nil, nil, ErrSourceManagerIsReleased
 	}
+	if sm.daemon != nil {
+		return sm.daemon.getManifestAndLock(id, v, an)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -445,6 +597,9 @@ func (sm *SourceMgr) ListPackages(id ProjectIdentifier, v Version) (pkgtree.Pack
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return pkgtree.PackageTree{}, ErrSourceManagerIsReleased
 	}
+	if sm.daemon != nil {
+		return sm.daemon.listPackages(id, v)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -470,6 +625,9 @@ func (sm *SourceMgr) ListVersions(id ProjectIdentifier) ([]PairedVersion, error)
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return nil, ErrSourceManagerIsReleased
 	}
+	if sm.daemon != nil {
+		return sm.daemon.listVersions(id)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -486,6 +644,9 @@ func (sm *SourceMgr) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool,
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return false, ErrSourceManagerIsReleased
 	}
+	if sm.daemon != nil {
+		return sm.daemon.revisionPresentIn(id, r)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -502,6 +663,9 @@ func (sm *SourceMgr) SourceExists(id ProjectIdentifier) (bool, error) {
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return false, ErrSourceManagerIsReleased
 	}
+	if sm.daemon != nil {
+		return sm.daemon.sourceExists(id)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -526,6 +690,9 @@ func (sm *SourceMgr) SyncSourceFor(id ProjectIdentifier) error {
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return ErrSourceManagerIsReleased
 	}
+	if sm.daemon != nil {
+		return sm.daemon.syncSourceFor(id)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -541,6 +708,9 @@ func (sm *SourceMgr) ExportProject(ctx context.Context, id ProjectIdentifier, v
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return ErrSourceManagerIsReleased
 	}
+	if sm.daemon != nil {
+		return sm.daemon.exportProject(ctx, id, v, to)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
 	if err != nil {
@@ -561,6 +731,9 @@ func (sm *SourceMgr) DeduceProjectRoot(ip string) (ProjectRoot, error) {
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return "", ErrSourceManagerIsReleased
 	}
+	if sm.daemon != nil {
+		return sm.daemon.deduceProjectRoot(ip)
+	}
 
 	// TODO(sdboyer) refactor deduceRootPath() so that this validation can move
 	// back down below a cache point, rather than executing on every call.
@@ -579,6 +752,9 @@ func (sm *SourceMgr) InferConstraint(s string, pi ProjectIdentifier) (Constraint
 	if s == "" {
 		return Any(), nil
 	}
+	if sm.daemon != nil {
+		return sm.daemon.inferConstraint(s, pi)
+	}
 
 	// Lookup the string in the repository
 	var version PairedVersion
@@ -623,6 +799,9 @@ func (sm *SourceMgr) InferConstraint(s string, pi ProjectIdentifier) (Constraint
 // that may refer to a canonical upstream source.
 // In general, these URLs differ only by protocol (e.g. https vs. ssh), not path
 func (sm *SourceMgr) SourceURLsForPath(ip string) ([]*url.URL, error) {
+	if sm.daemon != nil {
+		return sm.daemon.sourceURLsForPath(ip)
+	}
 	deduced, err := sm.deduceCoord.deduceRootPath(context.TODO(), ip)
 	if err != nil {
 		return nil, err
@@ -661,6 +840,10 @@ type supervisor struct {
 	cond    sync.Cond  // Wraps mu so callers can wait until all calls end
 	running map[callInfo]timeCount
 	ran     map[callType]durCount
+	policy  RetryPolicy    // How network operations are retried.
+	logger  logging.Logger // Optional; reports retries and repairs.
+
+	restricted bool // Whether VCS commands are run in the restricted profile.
 }
 
 func newSupervisor(ctx context.Context) *supervisor {
@@ -688,10 +871,29 @@ func (sup *supervisor) do(inctx context.Context, name string, typ callType, f fu
 		return err
 	}
 
+	fetch := typ == ctSourceInit || typ == ctSourceFetch
+	start := time.Now()
+	if fetch {
+		events.Emit(events.Event{Type: events.FetchStarted, Source: name})
+	}
+
 	cctx, cancelFunc := constext.Cons(inctx, octx)
-	err = f(cctx)
+	cctx = withRestrictedCommands(withCommandLimits(cctx, sup.policy), sup.restricted)
+	err = sup.retry(cctx, name, typ, f)
 	sup.done(ci)
 	cancelFunc()
+	if fetch {
+		e := events.Event{Type: events.FetchFinished, Source: name, Seconds: time.Since(start).Seconds()}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		events.Emit(e)
+	}
+	if te := commandTimeout(err); te != nil {
+		// The errors of VCS commands name neither the source they were
+		// run against nor the command, so say which timed out.
+		return errors.Wrapf(err, "%s for %s: %s", typ, name, te)
+	}
 	return err
 }
 
@@ -774,6 +976,7 @@ const (
 	ctSourceFetch
 	ctExportTree
 	ctValidateLocal
+	ctVerifySignature
 )
 
 func (ct callType) String() string {
@@ -794,6 +997,10 @@ func (ct callType) String() string {
 		return "Fetching latest data into local source cache"
 	case ctExportTree:
 		return "Writing code tree out to disk"
+	case ctValidateLocal:
+		return "Validating local source cache"
+	case ctVerifySignature:
+		return "Verifying signatures"
 	default:
 		panic("unknown calltype")
 	}
@@ -5,10 +5,10 @@
 package gps
 
 import (
-	"log"
 	"reflect"
 	"testing"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -94,7 +94,7 @@ func TestSourceManager_InferConstraint(t *testing.T) {
 
 			sm, err := NewSourceManager(SourceManagerConfig{
 				Cachedir: h.Path(cacheDir),
-				Logger:   log.New(test.Writer{TB: t}, "", 0),
+				Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
 			})
 			h.Must(err)
 
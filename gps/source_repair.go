@@ -0,0 +1,91 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// quarantineDirName is the name of the directory, beside the local paths of
+// sources, into which those found to be corrupt are moved aside. They are
+// kept there for inspection until the cache is garbage collected.
+const quarantineDirName = ".quarantine"
+
+// repairLocal is called after an operation on the local copy of the source
+// failed. It checks whether the local copy is corrupt, and if it is, moves it
+// into quarantine and retrieves the source afresh. It reports whether it did
+// so, in which case the failed operation is worth trying again.
+//
+// The caller must hold sg.mu.
+func (sg *sourceGateway) repairLocal(ctx context.Context) bool {
+	ic, ok := sg.src.(integrityChecker)
+	if !ok || ctx.Err() != nil || !sg.src.existsLocally(ctx) {
+		return false
+	}
+	cp, ok := sg.src.(cachePather)
+	if !ok {
+		return false
+	}
+
+	var corrupt error
+	if err := sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctValidateLocal, func(ctx context.Context) error {
+		corrupt = ic.checkIntegrity(ctx)
+		return nil
+	}); err != nil || corrupt == nil || ctx.Err() != nil {
+		return false
+	}
+
+	to, err := quarantine(cp.cachePath())
+	if err != nil {
+		sg.logf(logging.Error, "The cached source for %s is corrupt, but could not be moved aside: %s", sg.src.upstreamURL(), err)
+		return false
+	}
+	sg.logf(logging.Warn, "The cached source for %s was corrupt, and was moved to %s to be fetched again: %s", sg.src.upstreamURL(), to, corrupt)
+
+	sg.srcState &^= sourceExistsLocally | sourceHasLatestLocally
+	if err := sg.require(ctx, sourceExistsLocally); err != nil {
+		sg.logf(logging.Error, "Failed to fetch %s again: %s", sg.src.upstreamURL(), err)
+		return false
+	}
+	return true
+}
+
+// quarantine moves the source at path into the quarantine directory beside
+// it, and returns where it was moved to.
+func quarantine(path string) (string, error) {
+	dir := filepath.Join(filepath.Dir(path), quarantineDirName)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", errors.Wrap(err, "unable to create quarantine directory")
+	}
+	to := filepath.Join(dir, filepath.Base(path)+"-"+time.Now().Format("20060102150405"))
+	if err := fs.RenameWithFallback(path, to); err != nil {
+		return "", err
+	}
+	return to, nil
+}
+
+// logf logs a message about the source at lvl, if the supervisor has a
+// logger.
+func (sg *sourceGateway) logf(lvl logging.Level, format string, args ...interface{}) {
+	l := sg.suprvsr.logger
+	if l == nil {
+		return
+	}
+	switch lvl {
+	case logging.Warn:
+		l.Warnf(format, args...)
+	case logging.Error:
+		l.Errorf(format, args...)
+	default:
+		l.Infof(format, args...)
+	}
+}
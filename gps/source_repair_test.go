@@ -0,0 +1,62 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceGatewayRepairsCorruptSource(t *testing.T) {
+	requiresBins(t, "git")
+
+	dir, err := ioutil.TempDir("", "repair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, head := newStagingTestSource(t, dir)
+	local := src.repo.LocalPath()
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.checkIntegrity(ctx); err != nil {
+		t.Fatalf("expected a fresh clone to pass its integrity check, got %v", err)
+	}
+
+	// Lose the repository's objects, as a disk failure or an interrupted
+	// write might.
+	objects := filepath.Join(local, ".git", "objects")
+	if err := os.RemoveAll(objects); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(objects, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.checkIntegrity(ctx); err == nil {
+		t.Fatal("expected a repository without objects to fail its integrity check")
+	}
+
+	sg, err := newSourceGateway(ctx, src, newSupervisor(ctx), dir, newMemoryCache())
+	if err != nil {
+		t.Fatalf("expected the corrupt source to be repaired, got %v", err)
+	}
+	to := filepath.Join(dir, "export")
+	if err := sg.exportVersionTo(ctx, Revision(head), to); err != nil {
+		t.Fatal(err)
+	}
+
+	quarantined, err := ioutil.ReadDir(filepath.Join(filepath.Dir(local), quarantineDirName))
+	if err != nil || len(quarantined) != 1 {
+		t.Fatalf("expected the corrupt source to be quarantined, got %v, %v", quarantined, err)
+	}
+	if err := src.checkIntegrity(ctx); err != nil {
+		t.Errorf("expected the source fetched again to pass its integrity check, got %v", err)
+	}
+}
@@ -8,11 +8,11 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/gps/pkgtree"
 	"github.com/golang/dep/internal/test"
 )
@@ -42,7 +42,7 @@ func testSourceGateway(t *testing.T) {
 		return func(t *testing.T) {
 			superv := newSupervisor(ctx)
 			deducer := newDeductionCoordinator(superv)
-			logger := log.New(test.Writer{TB: t}, "", 0)
+			logger := logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug})
 			sc := newSourceCoordinator(superv, deducer, cachedir, nil, logger)
 			defer sc.close()
 
@@ -0,0 +1,235 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// subdirSeparator separates the repository from the subdirectory in the
+// sources of projects rooted in subdirectories of repositories, as in
+// github.com/org/mono//libs/foo.
+const subdirSeparator = "//"
+
+// SplitSubdir splits source, as in ProjectIdentifier.Source, into the source
+// of the repository and the subdirectory of it in which the project is rooted,
+// if it names one.
+func SplitSubdir(source string) (repo, subdir string) {
+	var i int
+	if j := strings.Index(source, "://"); j >= 0 {
+		i = j + len("://")
+	}
+	if k := strings.Index(source[i:], subdirSeparator); k >= 0 {
+		return source[:i+k], strings.Trim(source[i+k+len(subdirSeparator):], "/")
+	}
+	return source, ""
+}
+
+// JoinSubdir returns the source of the project rooted in the subdirectory
+// subdir of the repository at repo. subdir must be a relative, slash-separated
+// path within the repository.
+func JoinSubdir(repo, subdir string) (string, error) {
+	if subdir == "" {
+		return repo, nil
+	}
+	clean := path.Clean(strings.Replace(subdir, `\`, "/", -1))
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.Errorf("subdir %q must be a directory within the repository", subdir)
+	}
+	if repo == "" {
+		return "", errors.Errorf("subdir %q requires the source of its repository", subdir)
+	}
+	if _, sub := SplitSubdir(repo); sub != "" {
+		return "", errors.Errorf("source %q already names a subdirectory", repo)
+	}
+	return strings.TrimSuffix(repo, "/") + subdirSeparator + clean, nil
+}
+
+// subdirSource is the source of a project rooted in a subdirectory of a
+// repository. It shares the repository's source, and the lock serializing the
+// use of it, with the repository's own sourceGateway, and with those of the
+// other projects rooted in the repository.
+type subdirSource struct {
+	src    source
+	subdir string
+}
+
+// getSubdirGatewayFor returns the sourceGateway for the project id, rooted in
+// the subdirectory subdir of the repository at repo.
+func (sc *sourceCoordinator) getSubdirGatewayFor(ctx context.Context, id ProjectIdentifier, repo, subdir string) (*sourceGateway, error) {
+	base, err := sc.getSourceGatewayFor(ctx, ProjectIdentifier{ProjectRoot: ProjectRoot(repo)})
+	if err != nil {
+		return nil, err
+	}
+	key := base.src.upstreamURL() + subdirSeparator + subdir
+
+	sc.srcmut.Lock()
+	defer sc.srcmut.Unlock()
+	if sg, has := sc.srcs[key]; has {
+		return sg, nil
+	}
+	sg := &sourceGateway{
+		cachedir: sc.cachedir,
+		src:      &subdirSource{src: base.src, subdir: subdir},
+		cache:    sc.cache.newSingleSourceCache(id),
+		mu:       base.mu,
+		suprvsr:  sc.supervisor,
+	}
+	if sg.src.existsLocally(ctx) {
+		sg.srcState |= sourceExistsLocally
+	}
+	sc.srcs[key] = sg
+	return sg, nil
+}
+
+func (s *subdirSource) existsLocally(ctx context.Context) bool {
+	return s.src.existsLocally(ctx)
+}
+
+func (s *subdirSource) existsUpstream(ctx context.Context) bool {
+	return s.src.existsUpstream(ctx)
+}
+
+func (s *subdirSource) upstreamURL() string {
+	return s.src.upstreamURL() + subdirSeparator + s.subdir
+}
+
+func (s *subdirSource) initLocal(ctx context.Context) error {
+	return s.src.initLocal(ctx)
+}
+
+func (s *subdirSource) updateLocal(ctx context.Context) error {
+	return s.src.updateLocal(ctx)
+}
+
+func (s *subdirSource) maybeClean(ctx context.Context) error {
+	return s.src.maybeClean(ctx)
+}
+
+func (s *subdirSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	return s.src.listVersions(ctx)
+}
+
+func (s *subdirSource) revisionPresentIn(ctx context.Context, r Revision) (bool, error) {
+	return s.src.revisionPresentIn(ctx, r)
+}
+
+func (s *subdirSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	return s.src.disambiguateRevision(ctx, r)
+}
+
+func (s *subdirSource) sourceType() string {
+	return s.src.sourceType()
+}
+
+func (s *subdirSource) existsCallsListVersions() bool {
+	return s.src.existsCallsListVersions()
+}
+
+func (s *subdirSource) listVersionsRequiresLocal() bool {
+	return s.src.listVersionsRequiresLocal()
+}
+
+func (s *subdirSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	return manifestAndLockIn(ctx, s, pr, r, an)
+}
+
+func (s *subdirSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	return packagesIn(ctx, s, pr, r)
+}
+
+// exportRevisionTo exports the subdirectory of the repository at r to to.
+func (s *subdirSource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(to), ".dep-subdir")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	repo := filepath.Join(tmp, "repo")
+	if err := s.src.exportRevisionTo(ctx, r, repo); err != nil {
+		return err
+	}
+	dir, err := s.dir(repo, r)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(to); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return fs.RenameWithFallback(dir, to)
+}
+
+// withTree calls f with the subdirectory of the tree of the repository at r,
+// as laid out by the repository's source, or, if it cannot lay trees out,
+// exported to a temporary directory.
+func (s *subdirSource) withTree(ctx context.Context, r Revision, f func(dir string) error) error {
+	if tv, ok := s.src.(treeVisitor); ok {
+		return tv.withTree(ctx, r, func(repo string) error {
+			dir, err := s.dir(repo, r)
+			if err != nil {
+				return err
+			}
+			return f(dir)
+		})
+	}
+
+	tmp, err := ioutil.TempDir("", "dep-subdir")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	repo := filepath.Join(tmp, "repo")
+	if err := s.src.exportRevisionTo(ctx, r, repo); err != nil {
+		return err
+	}
+	dir, err := s.dir(repo, r)
+	if err != nil {
+		return err
+	}
+	return f(dir)
+}
+
+// dir returns the subdirectory of the tree of the repository at r, rooted at
+// repo, failing if r has no such directory.
+func (s *subdirSource) dir(repo string, r Revision) (string, error) {
+	dir := filepath.Join(repo, filepath.FromSlash(s.subdir))
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return "", errors.Errorf("%s has no directory %s at %s", s.src.upstreamURL(), s.subdir, r)
+	}
+	return dir, nil
+}
+
+// verifySignature verifies the signatures of the repository, which cover the
+// subdirectory along with the rest of it.
+func (s *subdirSource) verifySignature(ctx context.Context, v Version, r Revision, keyring string) error {
+	sv, ok := s.src.(signatureVerifier)
+	if !ok {
+		return errors.Errorf("the signatures of %s sources cannot be verified", s.src.sourceType())
+	}
+	return sv.verifySignature(ctx, v, r, keyring)
+}
+
+// verifySigstore verifies the Sigstore signatures of the repository, which
+// cover the subdirectory along with the rest of it.
+func (s *subdirSource) verifySigstore(ctx context.Context, v Version, r Revision, signer SigstoreIdentity) error {
+	sv, ok := s.src.(sigstoreVerifier)
+	if !ok {
+		return errors.Errorf("the Sigstore signatures of %s sources cannot be verified", s.src.sourceType())
+	}
+	return sv.verifySigstore(ctx, v, r, signer)
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestSplitSubdir(t *testing.T) {
+	cases := []struct {
+		source, repo, subdir string
+	}{
+		{"github.com/org/mono", "github.com/org/mono", ""},
+		{"github.com/org/mono//libs/foo", "github.com/org/mono", "libs/foo"},
+		{"https://github.com/org/mono", "https://github.com/org/mono", ""},
+		{"https://github.com/org/mono//libs/foo/", "https://github.com/org/mono", "libs/foo"},
+		{"git@github.com:org/mono//libs/foo", "git@github.com:org/mono", "libs/foo"},
+		{"file:///src/mono//libs/foo", "file:///src/mono", "libs/foo"},
+	}
+	for _, c := range cases {
+		repo, subdir := SplitSubdir(c.source)
+		if repo != c.repo || subdir != c.subdir {
+			t.Errorf("SplitSubdir(%q) = %q, %q; want %q, %q", c.source, repo, subdir, c.repo, c.subdir)
+		}
+		if c.subdir == "" {
+			continue
+		}
+		if source, err := JoinSubdir(repo, subdir); err != nil || source != c.repo+"//"+c.subdir {
+			t.Errorf("JoinSubdir(%q, %q) = %q, %v", repo, subdir, source, err)
+		}
+	}
+
+	for _, c := range [][2]string{
+		{"github.com/org/mono", "../foo"},
+		{"github.com/org/mono", "/libs/foo"},
+		{"github.com/org/mono", "."},
+		{"", "libs/foo"},
+		{"github.com/org/mono//libs", "foo"},
+	} {
+		if _, err := JoinSubdir(c[0], c[1]); err == nil {
+			t.Errorf("expected an error joining %q and %q", c[0], c[1])
+		}
+	}
+}
+
+func TestSubdirSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mono := filepath.Join(dir, "mono")
+	if err := os.Mkdir(mono, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"main.go":                "package main\n",
+		"libs/foo/foo.go":        "package foo\n",
+		"libs/foo/sub/sub.go":    "package sub\n",
+		"libs/foo/internal/i.go": "package internal\n",
+		"libs/bar/bar.go":        "package bar\n",
+	} {
+		path := filepath.Join(mono, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	id := ProjectIdentifier{ProjectRoot: "example.com/foo", Source: "file://" + filepath.ToSlash(mono) + "//libs/foo"}
+	vlist, err := sm.ListVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoList, err := sm.ListVersions(ProjectIdentifier{ProjectRoot: ProjectRoot(mono)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 1 || len(repoList) != 1 || vlist[0] != repoList[0] {
+		t.Fatalf("expected the versions of the repository, got %s and %s", vlist, repoList)
+	}
+	v := vlist[0]
+
+	ptree, err := sm.ListPackages(id, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkgs []string
+	for ip := range ptree.Packages {
+		pkgs = append(pkgs, ip)
+	}
+	sort.Strings(pkgs)
+	want := []string{"example.com/foo", "example.com/foo/internal", "example.com/foo/sub"}
+	if len(pkgs) != len(want) || pkgs[0] != want[0] || pkgs[1] != want[1] || pkgs[2] != want[2] {
+		t.Errorf("unexpected packages:\n\t(GOT): %s\n\t(WNT): %s", pkgs, want)
+	}
+
+	// Another project in the same repository shares its source.
+	bar := ProjectIdentifier{ProjectRoot: "example.com/bar", Source: "file://" + filepath.ToSlash(mono) + "//libs/bar"}
+	if _, err := sm.ListPackages(bar, v); err != nil {
+		t.Fatal(err)
+	}
+
+	to := filepath.Join(dir, "vendor", "example.com", "foo")
+	if err := sm.ExportProject(context.Background(), id, v, to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "sub", "sub.go")); err != nil {
+		t.Errorf("expected sub/sub.go to be exported: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "libs")); !os.IsNotExist(err) {
+		t.Errorf("expected only the subdirectory to be exported, got %v", err)
+	}
+
+	missing := ProjectIdentifier{ProjectRoot: "example.com/baz", Source: "file://" + filepath.ToSlash(mono) + "//libs/baz"}
+	if _, err := sm.ListPackages(missing, v); err == nil {
+		t.Error("expected an error listing the packages of a missing subdirectory")
+	}
+}
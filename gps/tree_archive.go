@@ -0,0 +1,114 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// archiveModTime is the modification time given to every entry in the
+// archives dep writes, so that they depend only on the contents of the trees
+// they hold, and not on when or where those trees were written.
+var archiveModTime = time.Unix(0, 0).UTC()
+
+// WriteDepTreeArchive writes to w a tar archive of the vendor tree of l, as
+// WriteDepTree would write it, with its entries under vendor/.
+//
+// The archive is reproducible: for the same lock and prune options, it is the
+// same, byte for byte, whenever and on whichever platform it is written.
+// Entries are sorted by name, and none carries a modification time, owner or
+// permissions of its own; files are 0644, or 0755 if they were executable,
+// and directories are 0755.
+func WriteDepTreeArchive(w io.Writer, l Lock, sm SourceManager, co CascadingPruneOptions) error {
+	if l == nil {
+		return errors.New("must provide non-nil Lock to WriteDepTreeArchive")
+	}
+
+	td, err := ioutil.TempDir("", "dep-archive")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	vendor := filepath.Join(td, "vendor")
+	if err := WriteDepTree(vendor, l, sm, co, nil); err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := addTreeToArchive(tw, vendor, "vendor", true); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// addTreeToArchive writes dir, and everything in it, to tw, in the order of
+// their names, with dir as the entry name. Symlinks are written as they are
+// if links is true, and left out otherwise; other irregular files are always
+// left out.
+func addTreeToArchive(tw *tar.Writer, dir, name string, links bool) error {
+	return filepath.Walk(dir, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fp)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    path.Join(name, filepath.ToSlash(rel)),
+			ModTime: archiveModTime,
+		}
+
+		switch {
+		case fi.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			hdr.Mode = 0755
+		case fi.Mode()&os.ModeSymlink != 0:
+			if !links {
+				return nil
+			}
+			target, err := os.Readlink(fp)
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = filepath.ToSlash(target)
+			hdr.Mode = 0777
+		case fi.Mode().IsRegular():
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = fi.Size()
+			hdr.Mode = 0644
+			if fi.Mode()&0111 != 0 {
+				hdr.Mode = 0755
+			}
+		default:
+			return nil
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		f, err := os.Open(fp)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.CopyN(tw, f, hdr.Size)
+		return err
+	})
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// archiveSourceManager exports projects from a fixed set of files, with the
+// given permissions and modification time, as checkouts made at different
+// times, by users with different umasks, would have.
+type archiveSourceManager struct {
+	SourceManager
+	files map[ProjectRoot]map[string]string
+	perm  os.FileMode
+	mtime time.Time
+}
+
+func (sm archiveSourceManager) ExportProject(ctx context.Context, id ProjectIdentifier, v Version, to string) error {
+	for name, content := range sm.files[id.ProjectRoot] {
+		fp := filepath.Join(to, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fp), 0777); err != nil {
+			return err
+		}
+		perm := sm.perm
+		if filepath.Ext(name) == ".sh" {
+			perm |= 0100
+		}
+		if err := ioutil.WriteFile(fp, []byte(content), perm); err != nil {
+			return err
+		}
+		if err := os.Chmod(fp, perm); err != nil {
+			return err
+		}
+		if err := os.Chtimes(fp, sm.mtime, sm.mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWriteDepTreeArchiveReproducible(t *testing.T) {
+	files := map[ProjectRoot]map[string]string{
+		"github.com/foo/bar": {
+			"bar.go":         "package bar\n",
+			"bar_test.go":    "package bar\n",
+			"sub/sub.go":     "package sub\n",
+			"scripts/gen.sh": "#!/bin/sh\n",
+		},
+		"github.com/foo/baz": {
+			"baz.go":            "package baz\n",
+			"vendor/x/y/y.go":   "package y\n",
+			"testdata/data.txt": "data\n",
+		},
+	}
+	l := SimpleLock{
+		NewLockedProject(mkPI("github.com/foo/baz"), NewVersion("v1.0.0").Pair("c3d595a33a77ff3f841fd8ca1bc8cd0278a227df"), []string{"."}),
+		NewLockedProject(mkPI("github.com/foo/bar"), NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{".", "sub"}),
+	}
+	co := CascadingPruneOptions{DefaultOptions: PruneNestedVendorDirs | PruneGoTestFiles}
+
+	archive := func(perm os.FileMode, mtime time.Time) []byte {
+		var buf bytes.Buffer
+		sm := archiveSourceManager{files: files, perm: perm, mtime: mtime}
+		if err := WriteDepTreeArchive(&buf, l, sm, co); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	a := archive(0644, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := archive(0600, time.Date(2018, 6, 1, 12, 30, 0, 0, time.Local))
+	if !bytes.Equal(a, b) {
+		t.Fatal("expected archives of the same lock to be identical")
+	}
+
+	var names []string
+	modes := make(map[string]int64)
+	tr := tar.NewReader(bytes.NewReader(a))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hdr.ModTime.Equal(archiveModTime) || hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "" || hdr.Gname != "" {
+			t.Errorf("%s: unexpected header: %+v", hdr.Name, hdr)
+		}
+		names = append(names, hdr.Name)
+		modes[hdr.Name] = hdr.Mode
+	}
+
+	want := []string{
+		"vendor/",
+		"vendor/github.com/",
+		"vendor/github.com/foo/",
+		"vendor/github.com/foo/bar/",
+		"vendor/github.com/foo/bar/bar.go",
+		"vendor/github.com/foo/bar/scripts/",
+		"vendor/github.com/foo/bar/scripts/gen.sh",
+		"vendor/github.com/foo/bar/sub/",
+		"vendor/github.com/foo/bar/sub/sub.go",
+		"vendor/github.com/foo/baz/",
+		"vendor/github.com/foo/baz/baz.go",
+		"vendor/github.com/foo/baz/testdata/",
+		"vendor/github.com/foo/baz/testdata/data.txt",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected entries in the archive:\n\t(GOT): %q\n\t(WNT): %q", names, want)
+	}
+	if modes["vendor/github.com/foo/bar/bar.go"] != 0644 || modes["vendor/github.com/foo/bar/sub/"] != 0755 {
+		t.Errorf("unexpected modes in the archive: %v", modes)
+	}
+	// Windows has no executable bits for the exported files to carry.
+	if runtime.GOOS != "windows" && modes["vendor/github.com/foo/bar/scripts/gen.sh"] != 0755 {
+		t.Errorf("expected executable files to be 0755, got %o", modes["vendor/github.com/foo/bar/scripts/gen.sh"])
+	}
+}
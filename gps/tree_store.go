@@ -0,0 +1,312 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// TreeStore is a directory of project trees that were exported at a revision
+// and pruned, kept so that they need not be exported and pruned again. Each
+// tree is keyed by its project's root and source, the revision, the prune
+// options and, if unused packages were pruned, the packages that were kept.
+// Trees are made read-only once written, as they are shared by everything that
+// uses them.
+type TreeStore struct {
+	dir string
+}
+
+// NewTreeStore returns a TreeStore keeping its trees in dir, which is created
+// as needed.
+func NewTreeStore(dir string) TreeStore {
+	return TreeStore{dir: dir}
+}
+
+// CacheTreeStore returns the TreeStore in the cache directory cachedir, whose
+// trees SourceMgr garbage collects along with the sources there.
+func CacheTreeStore(cachedir string) TreeStore {
+	return NewTreeStore(filepath.Join(cachedir, "trees"))
+}
+
+// Tree returns the path to the tree of the project lp, pruned with the options
+// po, exporting and pruning it into the store first if it is not there yet.
+// lp must be locked to a revision, or to a version paired with one.
+func (ts TreeStore) Tree(ctx context.Context, sm SourceManager, lp LockedProject, po PruneOptions) (string, error) {
+	id := lp.Ident()
+	rev, _, _ := VersionComponentStrings(lp.Version())
+	if rev == "" {
+		return "", errors.Errorf("%s is not locked to a revision", id)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%d\n", id.ProjectRoot, id.normalizedSource(), rev, po)
+	if po&PruneUnusedPackages != 0 {
+		pkgs := append([]string(nil), lp.Packages()...)
+		sort.Strings(pkgs)
+		for _, pkg := range pkgs {
+			fmt.Fprintln(h, pkg)
+		}
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+	path := filepath.Join(ts.dir, key[:2], key)
+
+	if _, err := os.Stat(path); err == nil {
+		unchanged, err := treeUnchanged(path)
+		if err != nil {
+			return "", err
+		}
+		if unchanged {
+			// Record the use of the tree, so that it is not garbage
+			// collected while it is still in use.
+			now := time.Now()
+			os.Chtimes(path, now, now)
+			return path, nil
+		}
+		// The tree was changed through a file hard linked to it, and is
+		// written afresh.
+		if err := removeTree(path); err != nil {
+			return "", err
+		}
+	}
+
+	// Write the tree beside where it is to go, and only move it into place
+	// once it is complete, so that a failure never leaves a partial tree to
+	// be found later.
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(path), ".tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	to := filepath.Join(tmp, "tree")
+	if err := exportPruned(ctx, sm, lp, po, to); err != nil {
+		return "", err
+	}
+	if err := sealTree(to, path); err != nil {
+		return "", err
+	}
+	if err := os.Rename(to, path); err != nil {
+		// Another process may have put the same tree in place first.
+		if _, serr := os.Stat(path); serr == nil {
+			return path, nil
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// LinkDepTree is like WriteDepTree, but rather than writing out a copy of
+// each project in l beneath basedir, it makes each a symlink to the project's
+// pruned tree in ts, putting the tree there first if need be. Nothing may be
+// written through the symlinks, as the trees they point to are shared, so
+// projects nested in, or containing, other projects are still written out as
+// copies.
+func LinkDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOptions, ts TreeStore, onWrite func(WriteProgress)) error {
+	if l == nil {
+		return fmt.Errorf("must provide non-nil Lock to LinkDepTree")
+	}
+
+	nested := nestedRoots(l)
+	return writeDepTree(basedir, l, onWrite, func(ctx context.Context, p LockedProject, to string) ([]byte, error) {
+		pr := p.Ident().ProjectRoot
+		if nested[pr] {
+			return nil, exportPruned(ctx, sm, p, co.PruneOptionsFor(pr), to)
+		}
+		tree, err := ts.Tree(ctx, sm, p, co.PruneOptionsFor(pr))
+		if err != nil {
+			return nil, err
+		}
+		if tree, err = filepath.Abs(tree); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+			return nil, err
+		}
+		return nil, errors.Wrapf(os.Symlink(tree, to), "failed to link %s", pr)
+	})
+}
+
+// WriteDepTreeFrom is like WriteDepTree, but writes out each project in l from
+// its pruned tree in ts, putting the tree there first if need be, rather than
+// exporting and pruning it afresh. The files of the trees are cloned or hard
+// linked, as fs.LinkDir does, where the filesystem allows it, so the files
+// written out are read-only if they are hard linked. Projects nested in, or
+// containing, other projects are exported and pruned as WriteDepTree does.
+//
+// Where the files of a tree are to be copied, their digest is computed as they
+// are, and reported to onWrite.
+func WriteDepTreeFrom(basedir string, l Lock, sm SourceManager, co CascadingPruneOptions, ts TreeStore, onWrite func(WriteProgress)) error {
+	if l == nil {
+		return fmt.Errorf("must provide non-nil Lock to WriteDepTreeFrom")
+	}
+
+	nested := nestedRoots(l)
+	return writeDepTree(basedir, l, onWrite, func(ctx context.Context, p LockedProject, to string) ([]byte, error) {
+		pr := p.Ident().ProjectRoot
+		if nested[pr] {
+			return nil, exportPruned(ctx, sm, p, co.PruneOptionsFor(pr), to)
+		}
+		tree, err := ts.Tree(ctx, sm, p, co.PruneOptionsFor(pr))
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+			return nil, err
+		}
+		if fs.LinkDirCopies(tree, filepath.Dir(to)) {
+			digest, err := pkgtree.CopyDigestFromDirectory(tree, to)
+			return digest, errors.Wrapf(err, "failed to write %s", pr)
+		}
+		return nil, errors.Wrapf(fs.LinkDir(tree, to), "failed to write %s", pr)
+	})
+}
+
+// nestedRoots returns the roots of the projects in l that are nested in, or
+// contain, other projects in l.
+func nestedRoots(l Lock) map[ProjectRoot]bool {
+	lps := l.Projects()
+	roots := make(map[string]bool, len(lps))
+	for _, lp := range lps {
+		roots[string(lp.Ident().ProjectRoot)] = true
+	}
+	// Each root is looked up among the parent directories of every other,
+	// as sorting them would put roots such as foo/bar-baz between foo/bar
+	// and foo/bar/sub.
+	nested := make(map[ProjectRoot]bool)
+	for root := range roots {
+		for dir := path.Dir(root); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if roots[dir] {
+				nested[ProjectRoot(dir)] = true
+				nested[ProjectRoot(root)] = true
+			}
+		}
+	}
+	return nested
+}
+
+// removeUnused removes the trees in ts that were last used before before, and
+// returns how many it removed, or would remove if dryRun is true. Trees left
+// half-written by failed runs are removed along with them.
+func (ts TreeStore) removeUnused(before time.Time, dryRun bool) (int, error) {
+	shards, err := ioutil.ReadDir(ts.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var removed int
+	for _, shard := range shards {
+		if !shard.IsDir() {
+			continue
+		}
+		dir := filepath.Join(ts.dir, shard.Name())
+		trees, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return removed, err
+		}
+		for _, tree := range trees {
+			// The digests of the trees are removed along with them.
+			if !tree.IsDir() || !tree.ModTime().Before(before) {
+				continue
+			}
+			if !dryRun {
+				if err := removeTree(filepath.Join(dir, tree.Name())); err != nil {
+					return removed, errors.Wrapf(err, "failed to remove %s from the tree store", tree.Name())
+				}
+			}
+			if !strings.HasPrefix(tree.Name(), ".tmp") {
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+// sealTree makes the files of the tree at tmp read-only, so that fs.LinkDir
+// may hard link to them, and records the digest of the tree for when it has
+// been moved to dir, where it is to be shared. A file hard linked to is the
+// same file in every tree linked from the shared one, so a change made to it
+// through any of them, by first making it writable again, shows up in the
+// shared tree as well; treeUnchanged tells from the digest whether that has
+// happened.
+func sealTree(tmp, dir string) error {
+	digest, err := HashTree(tmp)
+	if err != nil {
+		return err
+	}
+	if err := fs.MakeReadOnly(tmp); err != nil {
+		return err
+	}
+
+	// The digest is recorded before the tree is moved into place, so that
+	// the tree is never found without one. Every tree moved to dir has the
+	// same digest, so one that is already there is left as it is.
+	f, err := os.OpenFile(treeDigestPath(dir), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if os.IsExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(digest); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// treeUnchanged reports whether the tree at dir still has the digest that
+// sealTree recorded for it. A tree without a recorded digest, as those sealed
+// by older versions of dep are, is taken to have changed.
+func treeUnchanged(dir string) (bool, error) {
+	want, err := ioutil.ReadFile(treeDigestPath(dir))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	got, err := HashTree(dir)
+	if err != nil {
+		return false, err
+	}
+	return got == string(want), nil
+}
+
+// removeTree removes the tree at dir, along with its recorded digest.
+func removeTree(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Remove(treeDigestPath(dir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// treeDigestPath returns the path of the file recording the digest of the
+// tree at dir, which is kept beside it.
+func treeDigestPath(dir string) string {
+	return dir + ".digest"
+}
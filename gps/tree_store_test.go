@@ -0,0 +1,373 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+// countingSourceManager counts the projects exported by its
+// archiveSourceManager.
+type countingSourceManager struct {
+	archiveSourceManager
+	exports *int32
+}
+
+func (sm countingSourceManager) ExportProject(ctx context.Context, id ProjectIdentifier, v Version, to string) error {
+	atomic.AddInt32(sm.exports, 1)
+	return sm.archiveSourceManager.ExportProject(ctx, id, v, to)
+}
+
+func TestLinkDepTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks may not be creatable on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "linkdeptree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var exports int32
+	sm := countingSourceManager{
+		archiveSourceManager: archiveSourceManager{
+			files: map[ProjectRoot]map[string]string{
+				"github.com/foo/bar": {"bar.go": "package bar\n", "bar_test.go": "package bar\n"},
+				"github.com/foo/baz": {"baz.go": "package baz\n"},
+				// Nested in github.com/foo/baz.
+				"github.com/foo/baz/qux": {"qux.go": "package qux\n"},
+			},
+			perm: 0644,
+		},
+		exports: &exports,
+	}
+	l := SimpleLock{
+		NewLockedProject(mkPI("github.com/foo/bar"), NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."}),
+		NewLockedProject(mkPI("github.com/foo/baz"), NewVersion("v1.0.0").Pair("c3d595a33a77ff3f841fd8ca1bc8cd0278a227df"), []string{"."}),
+		NewLockedProject(mkPI("github.com/foo/baz/qux"), NewVersion("v1.0.0").Pair("a33a77ff3f841fd8ca1bc8cd0278a227dfc3d595"), []string{"."}),
+	}
+	co := CascadingPruneOptions{DefaultOptions: PruneNestedVendorDirs | PruneGoTestFiles}
+	ts := NewTreeStore(filepath.Join(dir, "trees"))
+
+	copies := filepath.Join(dir, "copies")
+	if err := WriteDepTree(copies, l, sm, co, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, name := range []string{"links1", "links2"} {
+		links := filepath.Join(dir, name)
+		if err := LinkDepTree(links, l, sm, co, ts, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the project that is not nested in, and does not contain,
+		// another is linked; the others are written out as copies.
+		for pr, wantLink := range map[string]bool{"github.com/foo/bar": true, "github.com/foo/baz": false, "github.com/foo/baz/qux": false} {
+			fi, err := os.Lstat(filepath.Join(links, pr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isLink := fi.Mode()&os.ModeSymlink != 0; isLink != wantLink {
+				t.Errorf("%s: expected %s to be a symlink: %v, got %v", name, pr, wantLink, isLink)
+			}
+
+			// Linked or copied, the digests of the projects are the same.
+			want, err := pkgtree.DigestFromDirectory(filepath.Join(copies, pr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pkgtree.DigestFromDirectory(filepath.Join(links, pr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s: expected the digest of %s to match that of its copy", name, pr)
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(links, "github.com/foo/bar/bar_test.go")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected the linked tree to be pruned, got %v", name, err)
+		}
+		fi, err := os.Stat(filepath.Join(links, "github.com/foo/bar/bar.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode()&0222 != 0 {
+			t.Errorf("%s: expected the linked tree to be read-only, got mode %s", name, fi.Mode())
+		}
+
+		// The tree in the store is exported once, and reused thereafter;
+		// the copies of the nested projects are exported every time.
+		if want := int32(3 + 3 + 2*i); exports != want {
+			t.Errorf("%s: expected %d exports, got %d", name, want, exports)
+		}
+	}
+}
+
+func TestNestedRoots(t *testing.T) {
+	lp := func(root string) LockedProject {
+		return NewLockedProject(mkPI(root), NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."})
+	}
+	l := SimpleLock{
+		lp("github.com/foo/bar"),
+		// Sorts between github.com/foo/bar and github.com/foo/bar/sub.
+		lp("github.com/foo/bar-baz"),
+		lp("github.com/foo/bar/sub"),
+		lp("github.com/foo/qux"),
+		lp("github.com/foo/qux/a/b"),
+		lp("github.com/foo/quux"),
+	}
+
+	got := nestedRoots(l)
+	want := map[ProjectRoot]bool{
+		"github.com/foo/bar":     true,
+		"github.com/foo/bar/sub": true,
+		"github.com/foo/qux":     true,
+		"github.com/foo/qux/a/b": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected nested roots:\n\t(GOT) %v\n\t(WNT) %v", got, want)
+	}
+}
+
+func TestWriteDepTreeFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "writedeptreefrom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var exports int32
+	sm := countingSourceManager{
+		archiveSourceManager: archiveSourceManager{
+			files: map[ProjectRoot]map[string]string{
+				"github.com/foo/bar": {"bar.go": "package bar\n", "bar_test.go": "package bar\n", "sub/sub.go": "package sub\n"},
+				"github.com/foo/baz": {"baz.go": "package baz\n"},
+			},
+			perm: 0644,
+		},
+		exports: &exports,
+	}
+	l := SimpleLock{
+		NewLockedProject(mkPI("github.com/foo/bar"), NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."}),
+		NewLockedProject(mkPI("github.com/foo/baz"), NewVersion("v1.0.0").Pair("c3d595a33a77ff3f841fd8ca1bc8cd0278a227df"), []string{"."}),
+	}
+	co := CascadingPruneOptions{DefaultOptions: PruneNestedVendorDirs | PruneGoTestFiles | PruneUnusedPackages}
+	ts := NewTreeStore(filepath.Join(dir, "trees"))
+
+	copies := filepath.Join(dir, "copies")
+	if err := WriteDepTree(copies, l, sm, co, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, name := range []string{"vendor1", "vendor2"} {
+		vendor := filepath.Join(dir, name)
+		if err := WriteDepTreeFrom(vendor, l, sm, co, ts, nil); err != nil {
+			t.Fatal(err)
+		}
+		for _, lp := range l {
+			pr := string(lp.Ident().ProjectRoot)
+			fi, err := os.Lstat(filepath.Join(vendor, pr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fi.IsDir() {
+				t.Errorf("%s: expected %s to be a directory, got mode %s", name, pr, fi.Mode())
+			}
+			want, err := pkgtree.DigestFromDirectory(filepath.Join(copies, pr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pkgtree.DigestFromDirectory(filepath.Join(vendor, pr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s: expected %s to be written just as WriteDepTree writes it", name, pr)
+			}
+		}
+
+		// Each project is exported into the store once, however many
+		// vendor trees are written from it.
+		if want := int32(2 + 2); exports != want {
+			t.Errorf("%s: expected %d exports after writing %d vendor trees, got %d", name, want, i+1, exports)
+		}
+	}
+
+	// The packages kept by pruning unused packages are part of the key of a
+	// tree, so a lock keeping others gets a tree of its own.
+	l[0] = NewLockedProject(mkPI("github.com/foo/bar"), NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{".", "sub"})
+	vendor := filepath.Join(dir, "vendor3")
+	if err := WriteDepTreeFrom(vendor, l, sm, co, ts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(vendor, "github.com/foo/bar/sub/sub.go")); err != nil {
+		t.Errorf("expected the newly kept package to be written: %s", err)
+	}
+	if exports != 5 {
+		t.Errorf("expected 5 exports, got %d", exports)
+	}
+}
+
+// TestWriteDepTreeFromDigests writes trees from a store on another filesystem,
+// whose files are copied, and so hashed as they are.
+func TestWriteDepTreeFromDigests(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("tmpfs is only looked for on linux")
+	}
+	shm, err := ioutil.TempDir("/dev/shm", "dep")
+	if err != nil {
+		t.Skipf("no tmpfs to write to: %v", err)
+	}
+	defer os.RemoveAll(shm)
+	dir, err := ioutil.TempDir("", "writedeptreefrom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sm := archiveSourceManager{
+		files: map[ProjectRoot]map[string]string{
+			"github.com/foo/bar": {"bar.go": "package bar\r\n", "sub/sub.go": "package sub\n"},
+		},
+		perm: 0644,
+	}
+	l := SimpleLock{
+		NewLockedProject(mkPI("github.com/foo/bar"), NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."}),
+	}
+	ts := NewTreeStore(filepath.Join(shm, "trees"))
+
+	vendor := filepath.Join(dir, "vendor")
+	digests := make(map[ProjectRoot][]byte)
+	err = WriteDepTreeFrom(vendor, l, sm, CascadingPruneOptions{}, ts, func(progress WriteProgress) {
+		digests[progress.LP.Ident().ProjectRoot] = progress.Digest
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := pkgtree.DigestFromDirectory(filepath.Join(vendor, "github.com/foo/bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := digests["github.com/foo/bar"]; string(got) != string(want) {
+		t.Errorf("expected the digest %x to be reported, got %x", want, got)
+	}
+}
+
+func TestTreeStoreRemoveUnused(t *testing.T) {
+	dir, err := ioutil.TempDir("", "treestoregc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sm := archiveSourceManager{
+		files: map[ProjectRoot]map[string]string{
+			"github.com/foo/bar": {"bar.go": "package bar\n"},
+			"github.com/foo/baz": {"baz.go": "package baz\n"},
+		},
+		perm: 0644,
+	}
+	bar := NewLockedProject(mkPI("github.com/foo/bar"), NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."})
+	baz := NewLockedProject(mkPI("github.com/foo/baz"), NewVersion("v1.0.0").Pair("c3d595a33a77ff3f841fd8ca1bc8cd0278a227df"), []string{"."})
+	ts := CacheTreeStore(dir)
+
+	old := time.Now().Add(-48 * time.Hour)
+	var paths []string
+	for _, lp := range []LockedProject{bar, baz} {
+		path, err := ts.Tree(context.Background(), sm, lp, PruneNestedVendorDirs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	// Using a tree marks it used.
+	if _, err := ts.Tree(context.Background(), sm, baz, PruneNestedVendorDirs); err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Add(-24 * time.Hour)
+	if n, err := ts.removeUnused(before, true); err != nil || n != 1 {
+		t.Fatalf("expected 1 tree to be removed in a dry run, got %d, %v", n, err)
+	}
+	if _, err := os.Stat(paths[0]); err != nil {
+		t.Fatalf("expected a dry run to remove nothing: %s", err)
+	}
+	if n, err := ts.removeUnused(before, false); err != nil || n != 1 {
+		t.Fatalf("expected 1 tree to be removed, got %d, %v", n, err)
+	}
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("expected the unused tree to be removed, got %v", err)
+	}
+	if _, err := os.Stat(paths[1]); err != nil {
+		t.Errorf("expected the used tree to be kept: %s", err)
+	}
+}
+
+func TestTreeStoreChangedTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "treestorechanged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var exports int32
+	sm := countingSourceManager{
+		archiveSourceManager: archiveSourceManager{
+			files: map[ProjectRoot]map[string]string{
+				"github.com/foo/bar": {"bar.go": "package bar\n"},
+			},
+			perm: 0644,
+		},
+		exports: &exports,
+	}
+	lp := NewLockedProject(mkPI("github.com/foo/bar"), NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."})
+	ts := NewTreeStore(dir)
+
+	path, err := ts.Tree(context.Background(), sm, lp, PruneNestedVendorDirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.Tree(context.Background(), sm, lp, PruneNestedVendorDirs); err != nil {
+		t.Fatal(err)
+	}
+	if exports != 1 {
+		t.Fatalf("expected an unchanged tree to be reused, got %d exports", exports)
+	}
+
+	// A change made through a hard link to one of the tree's files is made
+	// to the tree itself.
+	file := filepath.Join(path, "bar.go")
+	if err := os.Chmod(file, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte("package evil\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.Tree(context.Background(), sm, lp, PruneNestedVendorDirs); err != nil {
+		t.Fatal(err)
+	}
+	if exports != 2 {
+		t.Errorf("expected a changed tree to be exported again, got %d exports", exports)
+	}
+	if data, err := ioutil.ReadFile(file); err != nil || string(data) != "package bar\n" {
+		t.Errorf("expected the changed tree to be replaced, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// VanityRepository returns the URL of the repository named by the go get
+// metadata served for the import path ip. It returns false, and no URL, if
+// the source of ip is found without such metadata: if ip is on a host whose
+// import paths map to repositories in a known way, names its VCS, or is
+// mirrored.
+//
+// The repositories of such vanity import paths are named by whoever serves
+// them, and so may change without the import paths changing.
+func (sm *SourceMgr) VanityRepository(ip string) (string, bool, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return "", false, ErrSourceManagerIsReleased
+	}
+	if sm.daemon != nil {
+		return sm.daemon.vanityRepository(ip)
+	}
+	if !pathvld.MatchString(ip) {
+		return "", false, errors.Errorf("%q is not a valid import path", ip)
+	}
+
+	dc := sm.deduceCoord
+	if _, has, err := dc.deduceMirror(ip); has || err != nil {
+		return "", false, err
+	}
+	if _, err := dc.deduceKnownPaths(ip); err != errNoKnownPathMatch {
+		return "", false, err
+	}
+
+	pd, err := dc.deduceRootPath(context.TODO(), ip)
+	if err != nil {
+		return "", true, err
+	}
+	urls := pd.mb.possibleURLs()
+	if len(urls) == 0 {
+		return "", true, errors.Errorf("go get metadata for %s names no repository", ip)
+	}
+	return urls[0].String(), true, nil
+}
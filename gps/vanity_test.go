@@ -0,0 +1,44 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "testing"
+
+func TestVanityRepository(t *testing.T) {
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	// Stand in for the go get metadata of a vanity import path, as if it had
+	// already been fetched.
+	sm.deduceCoord.rootxt.Insert("example.com/lib", maybeSources{
+		maybeGitSource{url: mkurl("https://git.example.com/lib")},
+	})
+
+	cases := []struct {
+		ip     string
+		repo   string
+		vanity bool
+	}{
+		{"example.com/lib", "https://git.example.com/lib", true},
+		{"example.com/lib/sub", "https://git.example.com/lib", true},
+		{"github.com/foo/bar", "", false},
+		{"gopkg.in/yaml.v2", "", false},
+		{"example.com/repo.git", "", false},
+	}
+	for _, c := range cases {
+		repo, vanity, err := sm.VanityRepository(c.ip)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.ip, err)
+			continue
+		}
+		if repo != c.repo || vanity != c.vanity {
+			t.Errorf("%s: unexpected repository:\n\t(GOT) %q, %v\n\t(WNT) %q, %v", c.ip, repo, vanity, c.repo, c.vanity)
+		}
+	}
+
+	if _, _, err := sm.VanityRepository("not a path"); err == nil {
+		t.Error("expected an error for an invalid import path")
+	}
+}
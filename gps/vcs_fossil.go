@@ -0,0 +1,365 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/vcs"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// fossilType is the vcs.Type of Fossil repositories, which
+// github.com/Masterminds/vcs does not support.
+const fossilType vcs.Type = "fossil"
+
+// fossilDefaultBranch is the branch that Fossil repositories start out with.
+const fossilDefaultBranch = "trunk"
+
+// fossilCheckoutFiles are the files in which Fossil records the state of a
+// checkout; they are not part of the repository's contents.
+var fossilCheckoutFiles = []string{".fslckout", "_FOSSIL_"}
+
+// fossilRepo is a Fossil repository, which, unlike those of the other VCSs,
+// is a single file. It is kept beside the directory holding its checkout,
+// which is the repository's local path, so that the checkout only holds the
+// repository's contents.
+type fossilRepo struct {
+	remote, local string
+}
+
+func newFossilRepo(remote, local string) *fossilRepo {
+	return &fossilRepo{remote: remote, local: local}
+}
+
+// repoFile returns the path of the repository file.
+func (r *fossilRepo) repoFile() string {
+	return r.local + ".fossil"
+}
+
+// cmd returns a fossil command to be run in the repository's checkout.
+func (r *fossilRepo) cmd(ctx context.Context, args ...string) cmd {
+	cmd := commandContext(ctx, "fossil", args...)
+	cmd.SetDir(r.local)
+	return cmd
+}
+
+// query runs a fossil command against the repository file, which does not
+// require a checkout.
+func (r *fossilRepo) query(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := commandContext(ctx, "fossil", append(args, "-R", r.repoFile())...)
+	cmd.SetDir(filepath.Dir(r.local))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to query repository")
+	}
+	return out, nil
+}
+
+// get clones the repository and opens a checkout of it. As the checkout
+// refers to the repository file by its path, they cannot be retrieved
+// elsewhere and moved into place; instead, whatever an interrupted or failed
+// call leaves behind is removed, by it or by the next one.
+func (r *fossilRepo) get(ctx context.Context) error {
+	if err := r.remove(); err != nil {
+		return errors.Wrap(err, "unable to clear out incomplete repository")
+	}
+	if err := r.cloneAndOpen(ctx); err != nil {
+		r.remove()
+		return err
+	}
+	return nil
+}
+
+func (r *fossilRepo) cloneAndOpen(ctx context.Context) error {
+	cmd := commandContext(ctx, "fossil", "clone", r.remote, r.repoFile())
+	cmd.SetDir(filepath.Dir(r.local))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to get repository")
+	}
+
+	if err := os.MkdirAll(r.local, 0777); err != nil {
+		return errors.Wrap(err, "unable to create checkout directory")
+	}
+	cmd = r.cmd(ctx, "open", r.repoFile())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to open repository")
+	}
+	return nil
+}
+
+// remove removes the repository file and the checkout, if they exist.
+func (r *fossilRepo) remove() error {
+	if err := os.Remove(r.repoFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(r.local)
+}
+
+func (r *fossilRepo) fetch(ctx context.Context) error {
+	cmd := commandContext(ctx, "fossil", "pull", r.remote, "-R", r.repoFile())
+	cmd.SetDir(filepath.Dir(r.local))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to update repository")
+	}
+	return nil
+}
+
+func (r *fossilRepo) updateVersion(ctx context.Context, version string) error {
+	cmd := r.cmd(ctx, "checkout", "--force", version)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to update checked out version")
+	}
+	return nil
+}
+
+// info returns the information fossil reports on the check-in named by rev,
+// which may be a hash, a prefix of one, a tag or a branch, in which case the
+// latest check-in on it is used. If rev is empty, the checked out check-in is
+// reported on.
+func (r *fossilRepo) info(ctx context.Context, rev string) (*vcs.CommitInfo, error) {
+	var out []byte
+	var err error
+	if rev == "" {
+		cmd := r.cmd(ctx, "info")
+		if out, err = cmd.CombinedOutput(); err != nil {
+			err = newVcsLocalErrorOr(err, cmd.Args(), string(out),
+				"unable to query checkout")
+		}
+	} else {
+		out, err = r.query(ctx, "info", rev)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ci := parseFossilInfo(out)
+	if ci.Commit == "" {
+		return nil, errors.Errorf("%s is not a check-in", rev)
+	}
+	return ci, nil
+}
+
+var (
+	fossilHashLine = regexp.MustCompile(`^(?:hash|uuid|checkout):\s+([0-9a-f]{40}|[0-9a-f]{64})\s+(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d)`)
+	fossilComment  = regexp.MustCompile(`^comment:\s+(.*?)\s*\(user: ([^)]*)\)$`)
+)
+
+// parseFossilInfo parses the output of fossil info on a check-in.
+func parseFossilInfo(out []byte) *vcs.CommitInfo {
+	ci := &vcs.CommitInfo{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if m := fossilHashLine.FindStringSubmatch(line); m != nil {
+			ci.Commit = m[1]
+			ci.Date, _ = time.Parse("2006-01-02 15:04:05", m[2])
+		} else if m := fossilComment.FindStringSubmatch(line); m != nil {
+			ci.Message, ci.Author = m[1], m[2]
+		}
+	}
+	return ci
+}
+
+// parseFossilList parses the output of fossil's branch and tag listings: one
+// name per line, with the current branch marked by an asterisk.
+func parseFossilList(out []byte) []string {
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+		if line != "" {
+			names = append(names, line)
+		}
+	}
+	return names
+}
+
+// The methods below implement vcs.Repo.
+
+func (r *fossilRepo) Vcs() vcs.Type     { return fossilType }
+func (r *fossilRepo) Remote() string    { return r.remote }
+func (r *fossilRepo) LocalPath() string { return r.local }
+
+func (r *fossilRepo) Get() error {
+	return r.get(context.Background())
+}
+
+func (r *fossilRepo) Init() error {
+	cmd := commandContext(context.Background(), "fossil", "init", r.repoFile())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to initialize repository")
+	}
+	if err := os.MkdirAll(r.local, 0777); err != nil {
+		return errors.Wrap(err, "unable to create checkout directory")
+	}
+	cmd = r.cmd(context.Background(), "open", r.repoFile())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to open repository")
+	}
+	return nil
+}
+
+func (r *fossilRepo) Update() error {
+	if err := r.fetch(context.Background()); err != nil {
+		return err
+	}
+	return r.updateVersion(context.Background(), fossilDefaultBranch)
+}
+
+func (r *fossilRepo) UpdateVersion(version string) error {
+	return r.updateVersion(context.Background(), version)
+}
+
+func (r *fossilRepo) Version() (string, error) {
+	ci, err := r.info(context.Background(), "")
+	if err != nil {
+		return "", err
+	}
+	return ci.Commit, nil
+}
+
+func (r *fossilRepo) Current() (string, error) {
+	out, err := r.RunFromDir("fossil", "branch", "current")
+	if err != nil {
+		return "", newVcsLocalErrorOr(err, nil, string(out),
+			"unable to find the current branch")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (r *fossilRepo) Date() (time.Time, error) {
+	ci, err := r.info(context.Background(), "")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ci.Date, nil
+}
+
+func (r *fossilRepo) CheckLocal() bool {
+	if _, err := os.Stat(r.repoFile()); err != nil {
+		return false
+	}
+	for _, f := range fossilCheckoutFiles {
+		if _, err := os.Stat(filepath.Join(r.local, f)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fossilRepo) Branches() ([]string, error) {
+	out, err := r.query(context.Background(), "branch", "list")
+	if err != nil {
+		return nil, err
+	}
+	return parseFossilList(out), nil
+}
+
+// Tags returns the repository's tags, leaving out branches, which Fossil
+// also records as tags.
+func (r *fossilRepo) Tags() ([]string, error) {
+	branches, err := r.Branches()
+	if err != nil {
+		return nil, err
+	}
+	isBranch := make(map[string]bool, len(branches))
+	for _, b := range branches {
+		isBranch[b] = true
+	}
+
+	out, err := r.query(context.Background(), "tag", "list")
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, t := range parseFossilList(out) {
+		if !isBranch[t] {
+			tags = append(tags, t)
+		}
+	}
+	return tags, nil
+}
+
+func (r *fossilRepo) IsReference(rev string) bool {
+	_, err := r.info(context.Background(), rev)
+	return err == nil
+}
+
+func (r *fossilRepo) IsDirty() bool {
+	out, err := r.RunFromDir("fossil", "changes")
+	return err != nil || len(bytes.TrimSpace(out)) != 0
+}
+
+func (r *fossilRepo) CommitInfo(rev string) (*vcs.CommitInfo, error) {
+	return r.info(context.Background(), rev)
+}
+
+func (r *fossilRepo) TagsFromCommit(rev string) ([]string, error) {
+	out, err := r.query(context.Background(), "tag", "list", rev)
+	if err != nil {
+		return nil, err
+	}
+	return parseFossilList(out), nil
+}
+
+// Ping reports whether the remote responds. Fossil has no command to check
+// on a remote without cloning it, so remotes served over HTTP(S) are asked
+// for their home page, and others are assumed to exist.
+func (r *fossilRepo) Ping() bool {
+	if !strings.HasPrefix(r.remote, "http://") && !strings.HasPrefix(r.remote, "https://") {
+		return true
+	}
+	resp, err := http.Get(r.remote)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < 400
+}
+
+func (r *fossilRepo) RunFromDir(cmd string, args ...string) ([]byte, error) {
+	return r.CmdFromDir(cmd, args...).CombinedOutput()
+}
+
+func (r *fossilRepo) CmdFromDir(cmd string, args ...string) *exec.Cmd {
+	c := exec.Command(cmd, args...)
+	c.Dir = r.local
+	return c
+}
+
+func (r *fossilRepo) ExportDir(dir string) error {
+	if err := fs.CopyDir(r.local, dir); err != nil {
+		return err
+	}
+	return removeFossilCheckoutFiles(dir)
+}
+
+// removeFossilCheckoutFiles removes the files recording the state of a
+// checkout from dir, a copy of one.
+func removeFossilCheckoutFiles(dir string) error {
+	for _, f := range fossilCheckoutFiles {
+		if err := os.Remove(filepath.Join(dir, f)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFossilInfo(t *testing.T) {
+	out := []byte(`hash:         8e6ba1e4ed6f1c31ddcdd0d1c1f3d3e1a0a5ef32bff0a1c6a27b79d1ef6d2c3f 2018-03-01 12:30:45 UTC
+parent:       0d4c0b0a1d2e3f405162738495a6b7c8d9e0f1a2b3c4d5e6f708192a3b4c5d6e 2018-02-28 09:00:00 UTC
+tags:         trunk, v1.0.0
+comment:      Fix the frobnicator (user: drh)
+`)
+	ci := parseFossilInfo(out)
+
+	if want := "8e6ba1e4ed6f1c31ddcdd0d1c1f3d3e1a0a5ef32bff0a1c6a27b79d1ef6d2c3f"; ci.Commit != want {
+		t.Errorf("unexpected commit:\n\t(GOT): %s\n\t(WNT): %s", ci.Commit, want)
+	}
+	if want := time.Date(2018, 3, 1, 12, 30, 45, 0, time.UTC); !ci.Date.Equal(want) {
+		t.Errorf("unexpected date:\n\t(GOT): %s\n\t(WNT): %s", ci.Date, want)
+	}
+	if ci.Author != "drh" || ci.Message != "Fix the frobnicator" {
+		t.Errorf("unexpected author and message: %q, %q", ci.Author, ci.Message)
+	}
+
+	// Older versions of fossil call the hash a uuid, and report on the
+	// checkout as such.
+	for _, prefix := range []string{"uuid:", "checkout:"} {
+		ci = parseFossilInfo([]byte(prefix + "     0d4c0b0a1d2e3f405162738495a6b7c8d9e0f1a2 2014-01-02 03:04:05 UTC\n"))
+		if ci.Commit != "0d4c0b0a1d2e3f405162738495a6b7c8d9e0f1a2" {
+			t.Errorf("expected a sha1 hash from a %s line, got %q", prefix, ci.Commit)
+		}
+	}
+}
+
+func TestParseFossilList(t *testing.T) {
+	got := parseFossilList([]byte("   feature\n * trunk\n\n   v1.0.0\n"))
+	want := []string{"feature", "trunk", "v1.0.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected names:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+}
+
+func TestFossilSource(t *testing.T) {
+	if _, err := exec.LookPath("fossil"); err != nil {
+		t.Skip("fossil is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "fossil-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fossil := func(dir string, args ...string) {
+		cmd := exec.Command("fossil", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("fossil %v failed: %s\n%s", args, err, out)
+		}
+	}
+
+	// Create an upstream with a tagged check-in.
+	upstream := filepath.Join(dir, "upstream.fossil")
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0777); err != nil {
+		t.Fatal(err)
+	}
+	fossil(dir, "init", upstream)
+	fossil(work, "open", upstream)
+	if err := ioutil.WriteFile(filepath.Join(work, "a.go"), []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	fossil(work, "add", "a.go")
+	fossil(work, "commit", "--no-warnings", "-m", "first")
+	fossil(work, "tag", "add", "v1.0.0", "trunk")
+
+	cachedir := filepath.Join(dir, "cache")
+	if err := os.Mkdir(cachedir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	src, err := maybeFossilSource{url: &url.URL{Path: upstream}}.try(ctx, cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.updateLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tag, trunk PairedVersion
+	for _, v := range vlist {
+		switch v.String() {
+		case "v1.0.0":
+			tag = v
+		case fossilDefaultBranch:
+			trunk = v
+		}
+	}
+	if tag == nil || trunk == nil {
+		t.Fatalf("expected the v1.0.0 tag and trunk branch, got %s", vlist)
+	}
+	if tag.Revision() != trunk.Revision() {
+		t.Errorf("expected v1.0.0 to tag the tip of trunk, got %s and %s", tag.Revision(), trunk.Revision())
+	}
+	if bv, ok := trunk.Unpair().(branchVersion); !ok || !bv.isDefault {
+		t.Errorf("expected trunk to be the default branch, got %#v", trunk.Unpair())
+	}
+
+	to := filepath.Join(dir, "export")
+	if err := src.exportRevisionTo(ctx, tag.Revision(), to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "a.go")); err != nil {
+		t.Errorf("expected a.go to be exported: %s", err)
+	}
+	for _, f := range fossilCheckoutFiles {
+		if _, err := os.Stat(filepath.Join(to, f)); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to be exported", f)
+		}
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/vcs"
@@ -33,11 +37,87 @@ type ensureCleaner interface {
 	ensureClean(context.Context) error
 }
 
+// deepener is an optional extension of ctxRepo, for repositories that may
+// have been retrieved without all of their history.
+type deepener interface {
+	// deepen ensures that the revision rev is present in the repository,
+	// fetching it from upstream if it is missing.
+	deepen(ctx context.Context, rev string) error
+}
+
+// stagedGetter is an optional extension of ctxRepo, for repositories that
+// can be retrieved into a directory other than their local path, and moved
+// into place once they are complete.
+type stagedGetter interface {
+	// getTo retrieves the repository from upstream into dir.
+	getTo(ctx context.Context, dir string) error
+}
+
+// resumer is an optional extension of stagedGetter, for repositories whose
+// retrieval can pick up where an interrupted one left off.
+type resumer interface {
+	// resume completes the retrieval of the repository into dir, which holds
+	// what an interrupted call to getTo left behind, reusing as much of it as
+	// it can. It fails if there is nothing in dir worth reusing.
+	resume(ctx context.Context, dir string) error
+}
+
+// integrityChecker is an optional extension of ctxRepo, and of source, for
+// local copies that can be checked for corruption.
+type integrityChecker interface {
+	// checkIntegrity returns an error if the local copy is corrupt or
+	// incomplete, so that it has to be retrieved afresh.
+	checkIntegrity(ctx context.Context) error
+}
+
 // original implementation of these methods come from
 // https://github.com/Masterminds/vcs
 
 type gitRepo struct {
 	*vcs.GitRepo
+
+	// clone controls how much of the repository get clones. Commits left
+	// out of a shallow clone are fetched by deepen as they are needed, and git
+	// fetches the files left out of a partial clone as they are checked out.
+	clone cloneOptions
+
+	// remote holds the credentials and proxy, if any, with which to reach
+	// the remote.
+	remote *remoteConfig
+
+	// moved, if set, is the URL to which the remote has moved. git is told
+	// to reach the remote there, leaving the local copy as it is.
+	moved string
+}
+
+// env returns the environment for git commands run against the repository's
+// remote. The remote's configuration is passed to git through the environment
+// rather than the command line, so that credentials are not exposed to other
+// users of the machine.
+func (r *gitRepo) env() []string {
+	env := gitEnv()
+	remote := r.Remote()
+	if r.moved != "" {
+		remote = r.moved
+	}
+	config := r.remote.gitConfig(remote)
+	if r.moved != "" {
+		config = append(config, [2]string{"url." + r.moved + ".insteadOf", r.Remote()})
+	}
+	if len(config) == 0 {
+		return env
+	}
+
+	// Respect any configuration the user passes in the same way.
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	for _, kv := range config {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, kv[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, kv[1]),
+		)
+		n++
+	}
+	return append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", n))
 }
 
 func newVcsRemoteErrorOr(err error, args []string, out, msg string) error {
@@ -47,6 +127,57 @@ func newVcsRemoteErrorOr(err error, args []string, out, msg string) error {
 	return vcs.NewRemoteError(msg, errors.Wrapf(err, "command failed: %v", args), out)
 }
 
+// gitAuthFailures are fragments of the output of git, and of the ssh it runs,
+// that report a remote refusing, or not being given, credentials.
+var gitAuthFailures = []string{
+	"Authentication failed",
+	"could not read Username",
+	"could not read Password",
+	"terminal prompts disabled",
+	"Permission denied (publickey",
+	"Host key verification failed",
+}
+
+// newGitRemoteErrorOr is newVcsRemoteErrorOr for git commands run against
+// remote. When the command failed to authenticate, the error says so, and how
+// dep expects to be given credentials.
+func newGitRemoteErrorOr(err error, args []string, out, msg, remote string) error {
+	for _, f := range gitAuthFailures {
+		if strings.Contains(out, f) {
+			msg = fmt.Sprintf("authentication failed for %s: dep never prompts for credentials, so ssh remotes need a key held by ssh-agent, and https remotes a git credential helper", remote)
+			break
+		}
+	}
+	return newVcsRemoteErrorOr(err, args, out, msg)
+}
+
+var (
+	gitSSHCommandOnce       sync.Once
+	gitSSHCommandConfigured bool
+)
+
+// gitEnv returns the environment for git commands that may contact a remote.
+// Such commands must never prompt, as dep would hang waiting on an answer that
+// cannot be given. Credentials instead come from ssh-agent, along with the
+// keys and host aliases in ~/.ssh/config, for ssh remotes, and from git
+// credential helpers, which git consults on its own, for https remotes.
+func gitEnv() []string {
+	env := []string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}
+
+	// Unless the user chose how git runs ssh, keep ssh from prompting for
+	// passphrases, passwords or unknown host keys.
+	gitSSHCommandOnce.Do(func() {
+		err := exec.Command("git", "config", "--get", "core.sshCommand").Run()
+		gitSSHCommandConfigured = err == nil
+	})
+	if os.Getenv("GIT_SSH_COMMAND") == "" && os.Getenv("GIT_SSH") == "" && !gitSSHCommandConfigured {
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+
+	// The user's environment comes last, so that it takes precedence.
+	return append(env, os.Environ()...)
+}
+
 func newVcsLocalErrorOr(err error, args []string, out, msg string) error {
 	if err == context.Canceled || err == context.DeadlineExceeded {
 		return err
@@ -55,46 +186,170 @@ func newVcsLocalErrorOr(err error, args []string, out, msg string) error {
 }
 
 func (r *gitRepo) get(ctx context.Context) error {
-	cmd := commandContext(
-		ctx,
-		"git",
-		"clone",
-		"--recursive",
-		"-v",
-		"--progress",
-		r.Remote(),
-		r.LocalPath(),
-	)
-	// Ensure no prompting for PWs
-	cmd.SetEnv(append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...))
+	return r.getTo(ctx, r.LocalPath())
+}
+
+func (r *gitRepo) getTo(ctx context.Context, dir string) error {
+	args := r.cloneArgs()
+	if len(args) > 0 {
+		err := r.cloneWith(ctx, dir, args...)
+		if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+			return err
+		}
+		// Not every remote, or version of git, can make a shallow or partial
+		// clone; fall back to a full one, clearing out whatever the failed
+		// attempt left behind.
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return r.cloneWith(ctx, dir)
+}
+
+// cloneArgs returns the arguments to git clone that leave out what
+// r.clone says to.
+func (r *gitRepo) cloneArgs() []string {
+	var args []string
+	if r.clone.shallow {
+		// Tags are left out too, as versions are listed from the remote, and
+		// the commits they point to are fetched as they are needed.
+		args = append(args, "--depth", "1", "--no-single-branch", "--no-tags")
+	}
+	if r.clone.partial {
+		// Versions are listed without the clone, so the contents of files
+		// are only needed when a revision is checked out or exported.
+		args = append(args, "--filter=blob:none")
+	}
+	return args
+}
+
+// cloneWith clones the repository into dir, passing args to git clone.
+//
+// Nothing is checked out. The trees of revisions are written out to temporary
+// directories as they are needed, rather than kept in a working tree beside the
+// repository's objects.
+func (r *gitRepo) cloneWith(ctx context.Context, dir string, args ...string) error {
+	args = append([]string{"clone", "--no-checkout", "-v", "--progress"}, args...)
+	cmd := commandContext(ctx, "git", append(args, r.Remote(), dir)...)
+	cmd.SetEnv(r.env())
 	if out, err := cmd.CombinedOutput(); err != nil {
-		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
-			"unable to get repository")
+		return newGitRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to get repository", r.Remote())
 	}
 
 	return nil
 }
 
+// resume completes a clone into dir that was cut short, by fetching whatever
+// it is missing from the remote. What the clone already fetched is not
+// fetched again.
+func (r *gitRepo) resume(ctx context.Context, dir string) error {
+	// Without a .git directory, git would look for a repository in the
+	// directories above dir.
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return errors.Errorf("no repository to resume in %s", dir)
+	}
+	cmd := commandContext(ctx, "git", "config", "--get", "remote.origin.url")
+	cmd.SetDir(dir)
+	if out, err := cmd.CombinedOutput(); err != nil || strings.TrimSpace(string(out)) != r.Remote() {
+		return errors.Errorf("no clone of %s to resume in %s", r.Remote(), dir)
+	}
+
+	args := []string{"fetch", "-v", "--progress", "--tags", "--prune"}
+	if r.clone.shallow {
+		args = []string{"fetch", "-v", "--progress", "--depth", "1", "--prune"}
+	}
+	if r.clone.partial {
+		args = append(args, "--filter=blob:none")
+	}
+	cmd = commandContext(ctx, "git", append(args, "origin")...)
+	cmd.SetDir(dir)
+	cmd.SetEnv(r.env())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newGitRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to resume getting repository", r.Remote())
+	}
+	return nil
+}
+
+// isShallow reports whether the repository is a shallow clone, which is
+// missing part of its history.
+func (r *gitRepo) isShallow() bool {
+	_, err := os.Stat(filepath.Join(r.LocalPath(), ".git", "shallow"))
+	return err == nil
+}
+
+// hasCommit reports whether the commit named by rev is present in the
+// repository. Unlike IsReference, it does not accept full hashes of commits
+// that are missing.
+func (r *gitRepo) hasCommit(ctx context.Context, rev string) bool {
+	cmd := commandContext(ctx, "git", "cat-file", "-e", rev+"^{commit}")
+	cmd.SetDir(r.LocalPath())
+	_, err := cmd.CombinedOutput()
+	return err == nil
+}
+
+// deepen fetches the commit named by rev into a shallow clone that is missing
+// it. Only that commit is fetched if the remote allows it; otherwise, the rest
+// of the repository's history is fetched, after which it is no longer shallow.
+func (r *gitRepo) deepen(ctx context.Context, rev string) error {
+	if !r.isShallow() || r.hasCommit(ctx, rev) {
+		return nil
+	}
+
+	env := r.env()
+	cmd := commandContext(ctx, "git", "fetch", "--depth", "1", r.RemoteLocation, rev)
+	cmd.SetDir(r.LocalPath())
+	cmd.SetEnv(env)
+	if _, err := cmd.CombinedOutput(); err == nil && r.hasCommit(ctx, rev) {
+		return nil
+	}
+
+	cmd = commandContext(ctx, "git", "fetch", "--progress", "--unshallow", "--tags", r.RemoteLocation)
+	cmd.SetDir(r.LocalPath())
+	cmd.SetEnv(env)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newGitRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to fetch the history of repository", r.Remote())
+	}
+	return nil
+}
+
+// checkIntegrity checks that every object reachable from the repository's
+// refs is present and can be read.
+func (r *gitRepo) checkIntegrity(ctx context.Context) error {
+	cmd := commandContext(ctx, "git", "fsck", "--connectivity-only", "--no-dangling", "--no-progress")
+	cmd.SetDir(r.LocalPath())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out), "repository failed its integrity check")
+	}
+	return nil
+}
+
 func (r *gitRepo) fetch(ctx context.Context) error {
-	cmd := commandContext(
-		ctx,
-		"git",
-		"fetch",
-		"--tags",
-		"--prune",
-		r.RemoteLocation,
-	)
+	// Progress is reported, though nothing reads it, so that a fetch that
+	// is still making progress is not taken to have stalled.
+	args := []string{"fetch", "--progress", "--tags", "--prune", r.RemoteLocation}
+	if r.isShallow() {
+		// Keep a shallow clone shallow, by fetching only the latest commit of
+		// each branch.
+		args = []string{"fetch", "--progress", "--depth", "1", "--prune", r.RemoteLocation}
+	}
+	cmd := commandContext(ctx, "git", args...)
 	cmd.SetDir(r.LocalPath())
-	// Ensure no prompting for PWs
-	cmd.SetEnv(append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...))
+	cmd.SetEnv(r.env())
 	if out, err := cmd.CombinedOutput(); err != nil {
-		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
-			"unable to update repository")
+		return newGitRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to update repository", r.Remote())
 	}
 	return nil
 }
 
 func (r *gitRepo) updateVersion(ctx context.Context, v string) error {
+	if err := r.deepen(ctx, v); err != nil {
+		return err
+	}
+
 	cmd := commandContext(ctx, "git", "checkout", v)
 	cmd.SetDir(r.LocalPath())
 	if out, err := cmd.CombinedOutput(); err != nil {
@@ -119,8 +374,7 @@ func (r *gitRepo) defendAgainstSubmodules(ctx context.Context) error {
 			"--recursive",
 		)
 		cmd.SetDir(r.LocalPath())
-		// Ensure no prompting for PWs
-		cmd.SetEnv(append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...))
+		cmd.SetEnv(r.env())
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return newVcsLocalErrorOr(err, cmd.Args(), string(out),
 				"unexpected error while defensively updating submodules")
@@ -159,7 +413,64 @@ func (r *gitRepo) defendAgainstSubmodules(ctx context.Context) error {
 	return nil
 }
 
+// hasWorkTree reports whether anything is checked out in the repository's
+// working tree. Repositories cloned without a checkout have no index until
+// something is.
+func (r *gitRepo) hasWorkTree() bool {
+	_, err := os.Stat(filepath.Join(r.LocalPath(), ".git", "index"))
+	return err == nil
+}
+
+// addWorktree checks rev, and its submodules, out in a new working tree at
+// dir, leaving the repository's own working tree as it is. The working tree is
+// removed with removeWorktree.
+func (r *gitRepo) addWorktree(ctx context.Context, dir, rev string) error {
+	if err := r.deepen(ctx, rev); err != nil {
+		return err
+	}
+
+	cmd := commandContext(ctx, "git", "worktree", "add", "--detach", "--force", dir, rev)
+	cmd.SetDir(r.LocalPath())
+	cmd.SetEnv(r.env())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to add working tree")
+	}
+
+	cmd = commandContext(ctx, "git", "submodule", "update", "--init", "--recursive")
+	cmd.SetDir(dir)
+	cmd.SetEnv(r.env())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		r.removeWorktree(dir)
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to update submodules in working tree")
+	}
+	return nil
+}
+
+// removeWorktree removes the working tree at dir, added by addWorktree, along
+// with git's record of it.
+func (r *gitRepo) removeWorktree(dir string) error {
+	err := os.RemoveAll(dir)
+	cmd := commandContext(context.Background(), "git", "worktree", "prune")
+	cmd.SetDir(r.LocalPath())
+	if out, perr := cmd.CombinedOutput(); perr != nil && err == nil {
+		err = newVcsLocalErrorOr(perr, cmd.Args(), string(out),
+			"unable to prune working trees")
+	}
+	return err
+}
+
 func (r *gitRepo) ensureClean(ctx context.Context) error {
+	if !r.hasWorkTree() {
+		// Nothing is checked out, so there is nothing to clean; as git
+		// status would, fail if the repository is too corrupt to read.
+		cmd := commandContext(ctx, "git", "rev-parse", "--verify", "--quiet", "HEAD^{tree}")
+		cmd.SetDir(r.LocalPath())
+		_, err := cmd.CombinedOutput()
+		return err
+	}
+
 	cmd := commandContext(
 		ctx,
 		"git",
@@ -229,7 +540,11 @@ type bzrRepo struct {
 }
 
 func (r *bzrRepo) get(ctx context.Context) error {
-	basePath := filepath.Dir(filepath.FromSlash(r.LocalPath()))
+	return r.getTo(ctx, r.LocalPath())
+}
+
+func (r *bzrRepo) getTo(ctx context.Context, dir string) error {
+	basePath := filepath.Dir(filepath.FromSlash(dir))
 	if _, err := os.Stat(basePath); os.IsNotExist(err) {
 		err = os.MkdirAll(basePath, 0755)
 		if err != nil {
@@ -237,7 +552,7 @@ func (r *bzrRepo) get(ctx context.Context) error {
 		}
 	}
 
-	cmd := commandContext(ctx, "bzr", "branch", r.Remote(), r.LocalPath())
+	cmd := commandContext(ctx, "bzr", "branch", r.Remote(), dir)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
 			"unable to get repository")
@@ -271,7 +586,11 @@ type hgRepo struct {
 }
 
 func (r *hgRepo) get(ctx context.Context) error {
-	cmd := commandContext(ctx, "hg", "clone", r.Remote(), r.LocalPath())
+	return r.getTo(ctx, r.LocalPath())
+}
+
+func (r *hgRepo) getTo(ctx context.Context, dir string) error {
+	cmd := commandContext(ctx, "hg", "clone", r.Remote(), dir)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
 			"unable to get repository")
@@ -280,6 +599,31 @@ func (r *hgRepo) get(ctx context.Context) error {
 	return nil
 }
 
+// resume completes a clone into dir that was cut short, by pulling whatever
+// it is missing from the remote, and checking out the result.
+func (r *hgRepo) resume(ctx context.Context, dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".hg")); err != nil {
+		return errors.Errorf("no repository to resume in %s", dir)
+	}
+	cmd := commandContext(ctx, "hg", "pull", "--update", r.Remote())
+	cmd.SetDir(dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to resume getting repository")
+	}
+	return nil
+}
+
+// checkIntegrity checks the repository's history and manifests.
+func (r *hgRepo) checkIntegrity(ctx context.Context) error {
+	cmd := commandContext(ctx, "hg", "verify", "--quiet")
+	cmd.SetDir(r.LocalPath())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out), "repository failed its integrity check")
+	}
+	return nil
+}
+
 func (r *hgRepo) fetch(ctx context.Context) error {
 	cmd := commandContext(ctx, "hg", "pull")
 	cmd.SetDir(r.LocalPath())
@@ -306,6 +650,10 @@ type svnRepo struct {
 }
 
 func (r *svnRepo) get(ctx context.Context) error {
+	return r.getTo(ctx, r.LocalPath())
+}
+
+func (r *svnRepo) getTo(ctx context.Context, dir string) error {
 	remote := r.Remote()
 	if strings.HasPrefix(remote, "/") {
 		remote = "file://" + remote
@@ -313,7 +661,7 @@ func (r *svnRepo) get(ctx context.Context) error {
 		remote = "file:///" + remote
 	}
 
-	cmd := commandContext(ctx, "svn", "checkout", remote, r.LocalPath())
+	cmd := commandContext(ctx, "svn", "checkout", remote, dir)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
 			"unable to get repository")
@@ -9,6 +9,10 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -48,6 +52,112 @@ func TestErrs(t *testing.T) {
 	}
 }
 
+func TestGitAuthErrors(t *testing.T) {
+	const remote = "git@example.com:org/private.git"
+	outs := map[string]bool{
+		"Permission denied (publickey).\nfatal: Could not read from remote repository.":                                  true,
+		"fatal: could not read Username for 'https://example.com': terminal prompts disabled":                            true,
+		"remote: Invalid username or password.\nfatal: Authentication failed for 'https://example.com/org/private.git/'": true,
+		"Host key verification failed.\nfatal: Could not read from remote repository.":                                   true,
+		"fatal: unable to access 'https://example.com/org/private.git/': Could not resolve host: example.com":            false,
+	}
+
+	for out, auth := range outs {
+		err := newGitRemoteErrorOr(errors.New("exit status 128"), nil, out, "unable to get repository", remote)
+		rerr, is := err.(*vcs.RemoteError)
+		if !is {
+			t.Fatalf("should have gotten remote error, got %T %v", err, err)
+		}
+		if got := strings.HasPrefix(rerr.Error(), "authentication failed for "+remote); got != auth {
+			t.Errorf("authentication failure reported as %v for %q, got %q", got, out, rerr.Error())
+		}
+		if rerr.Out() != out {
+			t.Errorf("output of git should be preserved, got %q", rerr.Out())
+		}
+	}
+
+	err := newGitRemoteErrorOr(context.Canceled, nil, "terminal prompts disabled", "", remote)
+	if err != context.Canceled {
+		t.Errorf("context errors should always pass through, got %s", err)
+	}
+}
+
+func TestGitEnv(t *testing.T) {
+	const sshCommand = "ssh -i /path/to/key"
+	old, had := os.LookupEnv("GIT_SSH_COMMAND")
+	os.Setenv("GIT_SSH_COMMAND", sshCommand)
+	defer func() {
+		if had {
+			os.Setenv("GIT_SSH_COMMAND", old)
+		} else {
+			os.Unsetenv("GIT_SSH_COMMAND")
+		}
+	}()
+
+	var prompt bool
+	var ssh []string
+	for _, kv := range gitEnv() {
+		switch {
+		case kv == "GIT_TERMINAL_PROMPT=0":
+			prompt = true
+		case strings.HasPrefix(kv, "GIT_SSH_COMMAND="):
+			ssh = append(ssh, strings.TrimPrefix(kv, "GIT_SSH_COMMAND="))
+		}
+	}
+	if !prompt {
+		t.Error("git should be kept from prompting for credentials")
+	}
+	if len(ssh) != 1 || ssh[0] != sshCommand {
+		t.Errorf("the user's GIT_SSH_COMMAND should be used as is, got %q", ssh)
+	}
+}
+
+func TestGitRepoEnvCredentials(t *testing.T) {
+	old, had := os.LookupEnv("GIT_CONFIG_COUNT")
+	os.Setenv("GIT_CONFIG_COUNT", "1")
+	defer func() {
+		if had {
+			os.Setenv("GIT_CONFIG_COUNT", old)
+		} else {
+			os.Unsetenv("GIT_CONFIG_COUNT")
+		}
+	}()
+
+	dir, err := ioutil.TempDir("", "gitrepoenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo := func(remote string) *gitRepo {
+		// The local path must not exist yet, as NewGitRepo inspects
+		// whatever is there.
+		rep, err := vcs.NewGitRepo(remote, filepath.Join(dir, "repo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &gitRepo{GitRepo: rep, remote: &remoteConfig{
+			creds: map[string]Credentials{"git.example.com": {Username: "bot", Password: "s3cret"}},
+		}}
+	}
+
+	env := repo("https://git.example.com/org/repo").env()
+	want := []string{
+		"GIT_CONFIG_KEY_1=http.https://git.example.com/.extraHeader",
+		"GIT_CONFIG_VALUE_1=Authorization: Basic Ym90OnMzY3JldA==",
+		"GIT_CONFIG_COUNT=2",
+	}
+	if got := env[len(env)-len(want):]; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected credentials in the environment:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
+	for _, kv := range repo("ssh://git@git.example.com/org/repo").env() {
+		if strings.HasPrefix(kv, "GIT_CONFIG_KEY_") {
+			t.Errorf("credentials should only be passed to https remotes, got %q", kv)
+		}
+	}
+}
+
 func testSvnRepo(t *testing.T) {
 	t.Parallel()
 
@@ -226,7 +336,7 @@ func testGitRepo(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	repo := &gitRepo{rep}
+	repo := &gitRepo{GitRepo: rep}
 
 	// Do an initial clone.
 	err = repo.get(ctx)
@@ -346,3 +456,118 @@ func testBzrRepo(t *testing.T) {
 		t.Fatalf("Current failed to detect Bzr on rev 2 of branch. Got version: %s", v)
 	}
 }
+
+// runGit runs git with args in dir, failing t if it does not succeed, and
+// returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=dep", "GIT_AUTHOR_EMAIL=dep@example.com",
+		"GIT_COMMITTER_NAME=dep", "GIT_COMMITTER_EMAIL=dep@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %s\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// gitTestUpstream creates a git repository in dir with two commits, the first
+// of which is tagged, to stand in for an upstream. It returns the hash of the
+// first commit.
+func gitTestUpstream(t *testing.T, dir string) string {
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "config", "uploadpack.allowFilter", "true")
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", "a.go")
+	runGit(t, dir, "commit", "-q", "-m", "first")
+	first := runGit(t, dir, "rev-parse", "HEAD")
+	runGit(t, dir, "tag", "v1.0.0")
+	runGit(t, dir, "rm", "-q", "a.go")
+	runGit(t, dir, "commit", "-q", "-m", "second")
+	return first
+}
+
+func TestGitRepoShallowClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	ctx := context.Background()
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-shallow-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	upstream := filepath.Join(tempDir, "upstream")
+	first := gitTestUpstream(t, upstream)
+
+	rep, err := vcs.NewGitRepo("file://"+filepath.ToSlash(upstream), filepath.Join(tempDir, "clone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &gitRepo{GitRepo: rep, clone: cloneOptions{shallow: true}}
+
+	if err = repo.get(ctx); err != nil {
+		t.Fatalf("unable to clone git repo: %s", err)
+	}
+	if !repo.isShallow() {
+		t.Fatal("expected a shallow clone")
+	}
+	if repo.hasCommit(ctx, first) {
+		t.Fatalf("expected %s to be left out of the shallow clone", first)
+	}
+
+	if err = repo.updateVersion(ctx, first); err != nil {
+		t.Fatalf("unable to check out a commit missing from the shallow clone: %s", err)
+	}
+	if !repo.hasCommit(ctx, first) {
+		t.Fatalf("expected %s to have been fetched", first)
+	}
+}
+
+func TestGitRepoPartialClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	ctx := context.Background()
+	tempDir, err := ioutil.TempDir("", "go-vcs-git-partial-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	upstream := filepath.Join(tempDir, "upstream")
+	first := gitTestUpstream(t, upstream)
+
+	rep, err := vcs.NewGitRepo("file://"+filepath.ToSlash(upstream), filepath.Join(tempDir, "clone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &gitRepo{GitRepo: rep, clone: cloneOptions{partial: true}}
+
+	if err = repo.get(ctx); err != nil {
+		t.Fatalf("unable to clone git repo: %s", err)
+	}
+
+	// Whether or not this git can make partial clones, the file left out of
+	// the clone must be there once its revision is checked out.
+	if err = repo.updateVersion(ctx, first); err != nil {
+		t.Fatalf("unable to check out %s: %s", first, err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(repo.LocalPath(), "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "package a\n" {
+		t.Errorf("unexpected contents of a.go: %q", data)
+	}
+}
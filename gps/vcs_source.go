@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -48,6 +49,10 @@ func (bs *baseVCSSource) upstreamURL() string {
 }
 
 func (bs *baseVCSSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	if err := bs.maybeDeepen(ctx, r); err != nil {
+		return "", err
+	}
+
 	ci, err := bs.repo.CommitInfo(string(r))
 	if err != nil {
 		return "", err
@@ -56,12 +61,36 @@ func (bs *baseVCSSource) disambiguateRevision(ctx context.Context, r Revision) (
 }
 
 func (bs *baseVCSSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
-	err := bs.repo.updateVersion(ctx, r.String())
-	if err != nil {
-		return nil, nil, unwrapVcsErr(err)
+	return manifestAndLockIn(ctx, bs, pr, r, an)
+}
+
+// treeVisitor is an optional extension of source, for sources that can lay
+// out the tree of a revision in a local directory.
+type treeVisitor interface {
+	// withTree calls f with a directory holding the tree of r. The directory
+	// is only valid until f returns.
+	withTree(ctx context.Context, r Revision, f func(dir string) error) error
+}
+
+// withTree checks r out in the local repository, and calls f with its working
+// tree.
+func (bs *baseVCSSource) withTree(ctx context.Context, r Revision, f func(dir string) error) error {
+	if err := bs.repo.updateVersion(ctx, r.String()); err != nil {
+		return unwrapVcsErr(err)
 	}
+	return f(bs.repo.LocalPath())
+}
 
-	m, l, err := an.DeriveManifestAndLock(bs.repo.LocalPath(), pr)
+// manifestAndLockIn derives the manifest and lock of the project rooted at pr
+// from the tree of r, laid out by tv.
+func manifestAndLockIn(ctx context.Context, tv treeVisitor, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	var m Manifest
+	var l Lock
+	err := tv.withTree(ctx, r, func(dir string) error {
+		var err error
+		m, l, err = an.DeriveManifestAndLock(dir, pr)
+		return err
+	})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -73,19 +102,84 @@ func (bs *baseVCSSource) getManifestAndLock(ctx context.Context, pr ProjectRoot,
 	return prepManifest(m), l, nil
 }
 
-func (bs *baseVCSSource) revisionPresentIn(r Revision) (bool, error) {
+// packagesIn lists the packages of the project rooted at pr in the tree of r,
+// laid out by tv.
+func packagesIn(ctx context.Context, tv treeVisitor, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	var ptree pkgtree.PackageTree
+	err := tv.withTree(ctx, r, func(dir string) error {
+		var err error
+		ptree, err = pkgtree.ListPackages(dir, string(pr))
+		return err
+	})
+	return ptree, err
+}
+
+func (bs *baseVCSSource) revisionPresentIn(ctx context.Context, r Revision) (bool, error) {
+	if err := bs.maybeDeepen(ctx, r); err != nil {
+		return false, err
+	}
 	return bs.repo.IsReference(string(r)), nil
 }
 
 // initLocal clones/checks out the upstream repository to disk for the first
 // time.
+//
+// Where the repository allows it, it is retrieved into a staging directory,
+// and only moved to its local path once it is complete, so that an
+// interrupted retrieval never leaves a partial repository there. The next
+// attempt picks up from what the interrupted one left in the staging
+// directory, if it can, rather than starting over.
 func (bs *baseVCSSource) initLocal(ctx context.Context) error {
-	err := bs.repo.get(ctx)
+	sg, ok := bs.repo.(stagedGetter)
+	if !ok {
+		return unwrapVcsErr(bs.repo.get(ctx))
+	}
 
-	if err != nil {
-		return unwrapVcsErr(err)
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	return nil
+	local := bs.repo.LocalPath()
+	staging := stagingPath(local)
+	if err := os.MkdirAll(filepath.Dir(staging), 0777); err != nil {
+		return errors.Wrap(err, "unable to create staging directory")
+	}
+
+	resumed := false
+	if _, err := os.Stat(staging); err == nil {
+		if r, ok := sg.(resumer); ok {
+			resumed = r.resume(ctx, staging) == nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !resumed {
+			if err := os.RemoveAll(staging); err != nil {
+				return errors.Wrap(err, "unable to clear out interrupted retrieval")
+			}
+		}
+	}
+	if !resumed {
+		if err := sg.getTo(ctx, staging); err != nil {
+			return unwrapVcsErr(err)
+		}
+	}
+
+	// Anything at the local path is not a complete repository, or this would
+	// not have been called; the new one takes its place.
+	if err := os.RemoveAll(local); err != nil {
+		return errors.Wrap(err, "unable to clear out incomplete repository")
+	}
+	return errors.Wrap(fs.RenameWithFallback(staging, local), "unable to move repository into place")
+}
+
+// stagingDirName is the name of the directory, beside the local paths of
+// repositories, into which they are retrieved before being moved into place.
+const stagingDirName = ".staging"
+
+// stagingPath returns the path of the directory into which the repository
+// whose local path is local is retrieved before being moved into place.
+func stagingPath(local string) string {
+	return filepath.Join(filepath.Dir(local), stagingDirName, filepath.Base(local))
 }
 
 // updateLocal ensures the local data (versions and code) we have about the
@@ -123,20 +217,36 @@ func (bs *baseVCSSource) maybeClean(ctx context.Context) error {
 	return nil
 }
 
-func (bs *baseVCSSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (ptree pkgtree.PackageTree, err error) {
-	err = bs.repo.updateVersion(ctx, r.String())
+// checkIntegrity returns an error if the local repository is corrupt. It is a
+// no-op when the underlying repository cannot be checked.
+func (bs *baseVCSSource) checkIntegrity(ctx context.Context) error {
+	ic, ok := bs.repo.(integrityChecker)
+	if !ok {
+		return nil
+	}
+	return ic.checkIntegrity(ctx)
+}
 
-	if err != nil {
-		err = unwrapVcsErr(err)
-	} else {
-		ptree, err = pkgtree.ListPackages(bs.repo.LocalPath(), string(pr))
+// maybeDeepen fetches the revision r, if the repository was retrieved without
+// it. It is a no-op when the underlying repository is always complete.
+func (bs *baseVCSSource) maybeDeepen(ctx context.Context, r Revision) error {
+	d, ok := bs.repo.(deepener)
+	if !ok {
+		return nil
 	}
 
-	return
+	if err := d.deepen(ctx, string(r)); err != nil {
+		return unwrapVcsErr(err)
+	}
+	return nil
+}
+
+func (bs *baseVCSSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	return packagesIn(ctx, bs, pr, r)
 }
 
 func (bs *baseVCSSource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
-	// Only make the parent dir, as CopyDir will balk on trying to write to an
+	// Only make the parent dir, as LinkDir will balk on trying to write to an
 	// empty but existing dir.
 	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
 		return err
@@ -146,7 +256,9 @@ func (bs *baseVCSSource) exportRevisionTo(ctx context.Context, r Revision, to st
 		return unwrapVcsErr(err)
 	}
 
-	return fs.CopyDir(bs.repo.LocalPath(), to)
+	// The files of the working copy are writable, so they are cloned where
+	// the filesystem supports it, but never hard linked.
+	return fs.LinkDir(bs.repo.LocalPath(), to)
 }
 
 var (
@@ -157,28 +269,74 @@ var (
 // all standard git remotes.
 type gitSource struct {
 	baseVCSSource
+
+	// redirects records the remote moving, if it does.
+	redirects *redirectLog
 }
 
-func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to string) error {
-	r := s.repo
+func (s *gitSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	return manifestAndLockIn(ctx, s, pr, r, an)
+}
+
+func (s *gitSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	return packagesIn(ctx, s, pr, r)
+}
+
+// withTree exports rev to a temporary directory, and calls f with it. Nothing
+// is checked out in the local repository, which keeps only git's objects.
+func (s *gitSource) withTree(ctx context.Context, rev Revision, f func(dir string) error) error {
+	tmp, err := ioutil.TempDir("", "dep-git-tree")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	dir := filepath.Join(tmp, "tree")
+	if err := s.exportRevisionTo(ctx, rev, dir); err != nil {
+		return err
+	}
+	return f(dir)
+}
 
+func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to string) error {
 	if err := os.MkdirAll(to, 0777); err != nil {
 		return err
 	}
 
-	// Back up original index
-	idx, bak := filepath.Join(r.LocalPath(), ".git", "index"), filepath.Join(r.LocalPath(), ".git", "origindex")
-	err := fs.RenameWithFallback(idx, bak)
-	if err != nil {
+	if err := s.maybeDeepen(ctx, rev); err != nil {
+		return err
+	}
+
+	if err := s.checkoutIndexTo(ctx, rev, to); err != nil {
 		return err
 	}
+	return s.exportSubmodulesTo(ctx, rev, to)
+}
+
+// checkoutIndexTo writes the tree of rev out to the directory to, through a
+// temporary index, leaving the repository's own working tree and index as they
+// were. Submodules are written out as empty directories.
+func (s *gitSource) checkoutIndexTo(ctx context.Context, rev Revision, to string) error {
+	r := s.repo
 
-	// could have an err here...but it's hard to imagine how?
-	defer fs.RenameWithFallback(bak, idx)
+	tmp, err := ioutil.TempDir("", "dep-git-index")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	idx := "GIT_INDEX_FILE=" + filepath.Join(tmp, "index")
+
+	env := append(gitEnv(), idx)
+	if gr, ok := r.(*gitRepo); ok {
+		// Partial clones fetch the contents of files as they are checked
+		// out, for which they need the remote's configuration.
+		env = append(gr.env(), idx)
+	}
 
 	{
 		cmd := commandContext(ctx, "git", "read-tree", rev.String())
 		cmd.SetDir(r.LocalPath())
+		cmd.SetEnv(env)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return errors.Wrap(err, string(out))
 		}
@@ -191,13 +349,11 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	//
 	// Sadly, this approach *does* also write out vendor dirs. There doesn't
 	// appear to be a way to make checkout-index respect sparse checkout
-	// rules (-a supersedes it). The alternative is using plain checkout,
-	// though we have a bunch of housekeeping to do to set up, then tear
-	// down, the sparse checkout controls, as well as restore the original
-	// index and HEAD.
+	// rules (-a supersedes it).
 	{
 		cmd := commandContext(ctx, "git", "checkout-index", "-a", "--prefix="+to)
 		cmd.SetDir(r.LocalPath())
+		cmd.SetEnv(env)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return errors.Wrap(err, string(out))
 		}
@@ -206,6 +362,117 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	return nil
 }
 
+// submodulePaths returns the paths of the submodules in the tree of rev.
+func (s *gitSource) submodulePaths(ctx context.Context, rev Revision) ([]string, error) {
+	cmd := commandContext(ctx, "git", "ls-tree", "-r", "-z", rev.String())
+	cmd.SetDir(s.repo.LocalPath())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+
+	var paths []string
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		// Each entry is "<mode> <type> <object>\t<path>"; submodules are
+		// the entries of type commit.
+		tab := bytes.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		if fields := strings.Fields(string(entry[:tab])); len(fields) == 3 && fields[1] == "commit" {
+			paths = append(paths, string(entry[tab+1:]))
+		}
+	}
+	return paths, nil
+}
+
+// exportSubmodulesTo fills in the submodules of rev, which checkoutIndexTo
+// leaves empty, in the tree exported to the directory to. The submodules are
+// copied from a temporary working tree of rev, without their .git files, so
+// that they are vendored like the rest of the project.
+func (s *gitSource) exportSubmodulesTo(ctx context.Context, rev Revision, to string) error {
+	paths, err := s.submodulePaths(ctx, rev)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+
+	gr, ok := s.repo.(*gitRepo)
+	if !ok {
+		return errors.Errorf("could not check out the submodules of %s", rev)
+	}
+	tmp, err := ioutil.TempDir("", "dep-git-worktree")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	wt := filepath.Join(tmp, "worktree")
+	if err := gr.addWorktree(ctx, wt, rev.String()); err != nil {
+		return errors.Wrapf(unwrapVcsErr(err), "could not check out the submodules of %s", rev)
+	}
+	defer gr.removeWorktree(wt)
+
+	for _, path := range paths {
+		dst := filepath.Join(to, filepath.FromSlash(path))
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if err := fs.CopyDir(filepath.Join(wt, filepath.FromSlash(path)), dst); err != nil {
+			return errors.Wrapf(err, "could not export submodule %s", path)
+		}
+		if err := removeGitDirs(dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeGitDirs removes the .git files and directories, which mark
+// submodules, from the tree rooted at dir.
+func removeGitDirs(dir string) error {
+	var gitDirs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == ".git" {
+			gitDirs = append(gitDirs, path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range gitDirs {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *gitSource) setCloneOptions(opts cloneOptions) {
+	if r, ok := s.repo.(*gitRepo); ok {
+		r.clone = opts
+	}
+}
+
+func (s *gitSource) setRemoteConfig(rc *remoteConfig) {
+	if r, ok := s.repo.(*gitRepo); ok {
+		r.remote = rc
+	}
+}
+
+func (s *gitSource) setRedirectLog(rl *redirectLog) {
+	s.redirects = rl
+	if r, ok := s.repo.(*gitRepo); ok {
+		r.moved, _ = rl.lookup(r.Remote())
+	}
+}
+
 func (s *gitSource) isValidHash(hash []byte) bool {
 	return gitHashRE.Match(hash)
 }
@@ -217,6 +484,19 @@ func (*gitSource) existsCallsListVersions() bool {
 func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, err error) {
 	r := s.repo
 
+	// Listing versions through the host's API spares running git, and the
+	// pull request refs ls-remote would list along with branches and tags.
+	// Should the API fail, as when its rate limit is exceeded, git is used.
+	if gr, ok := r.(*gitRepo); ok && gr.remote.useVersionAPI() {
+		remote := r.Remote()
+		if gr.moved != "" {
+			remote = gr.moved
+		}
+		if vlist, has, err := listVersionsFromAPI(ctx, gr.remote, remote); has && err == nil {
+			return vlist, nil
+		}
+	}
+
 	cmd := commandContext(ctx, "git", "ls-remote", r.Remote())
 	// We want to invoke from a place where it's not possible for there to be a
 	// .git file instead of a .git directory, as git ls-remote will choke on the
@@ -228,11 +508,21 @@ func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, er
 	} else {
 		cmd.SetDir(filepath.Dir(r.LocalPath()))
 	}
-	// Ensure no prompting for PWs
-	cmd.SetEnv(append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...))
+	if gr, ok := r.(*gitRepo); ok {
+		cmd.SetEnv(gr.env())
+	} else {
+		cmd.SetEnv(gitEnv())
+	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, errors.Wrap(err, string(out))
+		return nil, unwrapVcsErr(newGitRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to list versions", r.Remote()))
+	}
+	if to, ok := gitRedirect(out, r.Remote()); ok {
+		if gr, ok := r.(*gitRepo); ok && gr.moved == "" {
+			s.redirects.record(r.Remote(), to, to)
+			gr.moved = to
+		}
 	}
 
 	all := bytes.Split(bytes.TrimSpace(out), []byte("\n"))
@@ -595,31 +885,16 @@ func (s *hgSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
 		return nil, errors.Wrap(err, string(out))
 	}
 
-	out = bytes.TrimSpace(out)
-	if !bytes.Equal(out, []byte("no bookmarks set")) {
-		all = bytes.Split(out, []byte("\n"))
-		for _, line := range all {
-			// Trim leading spaces, and * marker if present
-			line = bytes.TrimLeft(line, " *")
-			pair := bytes.Split(line, []byte(":"))
-			// if this doesn't split exactly once, we have something weird
-			if len(pair) != 2 {
-				continue
-			}
-
-			// Split on colon; this gets us the rev and the branch plus local revno
-			idx := bytes.IndexByte(pair[0], 32) // space
-			// if it's the magic @ marker, make that the default branch
-			str := string(pair[0][:idx])
-			var v PairedVersion
-			if str == "@" {
-				magicAt = true
-				v = newDefaultBranch(str).Pair(Revision(pair[1])).(PairedVersion)
-			} else {
-				v = NewBranch(str).Pair(Revision(pair[1])).(PairedVersion)
-			}
-			vlist = append(vlist, v)
+	// hg resolves a name to a bookmark before a branch of the same name, so
+	// branches, and topics after them, are only listed under names that are
+	// not already taken.
+	taken := make(map[string]bool)
+	for _, v := range parseHgBookmarks(out) {
+		if v.String() == "@" {
+			magicAt = true
 		}
+		taken[v.String()] = true
+		vlist = append(vlist, v)
 	}
 
 	cmd := s.hgCmd(ctx, "branches", "-c", "--debug")
@@ -642,6 +917,11 @@ func (s *hgSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
 		str := string(pair[0][:idx])
 		// if there was no magic @ bookmark, and this is mercurial's magic
 		// "default" branch, then mark it as default branch
+		if taken[str] {
+			continue
+		}
+		taken[str] = true
+
 		var v PairedVersion
 		if !magicAt && str == "default" {
 			v = newDefaultBranch(str).Pair(Revision(pair[1])).(PairedVersion)
@@ -651,5 +931,155 @@ func (s *hgSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
 		vlist = append(vlist, v)
 	}
 
+	for _, v := range s.listTopics(ctx) {
+		if !taken[v.String()] {
+			vlist = append(vlist, v)
+		}
+	}
+
+	return vlist, nil
+}
+
+// listTopics returns the heads of the repository's topics, the lightweight
+// branches of the topic extension, as branches. Topics are only listed if the
+// extension is installed; if it is not, or the repository has none, no
+// versions are returned.
+func (s *hgSource) listTopics(ctx context.Context) []PairedVersion {
+	// HGRCPATH is cleared for hg commands, so the extension has to be enabled
+	// explicitly. Only draft changesets carry a topic, so this lists nothing
+	// for repositories pulled from publishing servers.
+	cmd := s.hgCmd(ctx, "--config", "extensions.topic=", "log", "-r", "heads(topic())", "--template", "{topic}:{node}\\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil
+	}
+	return parseHgTopics(out)
+}
+
+// parseHgBookmarks parses the output of hg bookmarks --debug. The magic @
+// bookmark, if present, is the default branch. Divergent bookmarks, which hg
+// names after the bookmark and the path it diverged from, as in
+// "feature@default", are left out.
+func parseHgBookmarks(out []byte) []PairedVersion {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 || bytes.Equal(out, []byte("no bookmarks set")) {
+		return nil
+	}
+
+	var vlist []PairedVersion
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		// Trim leading spaces, and * marker if present
+		line = bytes.TrimLeft(line, " *")
+		pair := bytes.Split(line, []byte(":"))
+		// if this doesn't split exactly once, we have something weird
+		if len(pair) != 2 {
+			continue
+		}
+
+		// Split on colon; this gets us the rev and the branch plus local revno
+		idx := bytes.IndexByte(pair[0], 32) // space
+		if idx < 0 {
+			continue
+		}
+		str := string(pair[0][:idx])
+		// if it's the magic @ marker, make that the default branch
+		var v PairedVersion
+		if str == "@" {
+			v = newDefaultBranch(str).Pair(Revision(pair[1])).(PairedVersion)
+		} else if strings.Index(str, "@") > 0 {
+			continue
+		} else {
+			v = NewBranch(str).Pair(Revision(pair[1])).(PairedVersion)
+		}
+		vlist = append(vlist, v)
+	}
+	return vlist
+}
+
+// parseHgTopics parses lines of topic:node, as output by listTopics, skipping
+// any others, such as warnings. Topics with more than one head are left out,
+// as they do not name a single revision.
+func parseHgTopics(out []byte) []PairedVersion {
+	var names []string
+	revs := make(map[string]Revision)
+	heads := make(map[string]int)
+	for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+		pair := bytes.Split(bytes.TrimSpace(line), []byte(":"))
+		if len(pair) != 2 || len(pair[0]) == 0 || len(pair[1]) != 40 {
+			continue
+		}
+		name := string(pair[0])
+		if heads[name] == 0 {
+			names = append(names, name)
+		}
+		heads[name]++
+		revs[name] = Revision(pair[1])
+	}
+
+	var vlist []PairedVersion
+	for _, name := range names {
+		if heads[name] == 1 {
+			vlist = append(vlist, NewBranch(name).Pair(revs[name]).(PairedVersion))
+		}
+	}
+	return vlist
+}
+
+// fossilSource is a generic Fossil repository implementation.
+type fossilSource struct {
+	baseVCSSource
+}
+
+func (s *fossilSource) exportRevisionTo(ctx context.Context, rev Revision, to string) error {
+	if err := s.baseVCSSource.exportRevisionTo(ctx, rev, to); err != nil {
+		return err
+	}
+
+	return removeFossilCheckoutFiles(to)
+}
+
+func (s *fossilSource) listVersionsRequiresLocal() bool {
+	return true
+}
+
+func (s *fossilSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	r, ok := s.repo.(*fossilRepo)
+	if !ok {
+		return nil, errors.Errorf("unexpected repository type %T for a fossil source", s.repo)
+	}
+
+	tags, err := r.Tags()
+	if err != nil {
+		return nil, unwrapVcsErr(err)
+	}
+	branches, err := r.Branches()
+	if err != nil {
+		return nil, unwrapVcsErr(err)
+	}
+
+	// Fossil has no command listing the check-ins of all tags and branches at
+	// once, so each is looked up in turn.
+	vlist := make([]PairedVersion, 0, len(tags)+len(branches))
+	for _, tag := range tags {
+		ci, err := r.info(ctx, tag)
+		if err != nil {
+			return nil, unwrapVcsErr(err)
+		}
+		vlist = append(vlist, NewVersion(tag).Pair(Revision(ci.Commit)))
+	}
+	for _, branch := range branches {
+		ci, err := r.info(ctx, branch)
+		if err != nil {
+			return nil, unwrapVcsErr(err)
+		}
+		var v UnpairedVersion
+		if branch == fossilDefaultBranch {
+			v = newDefaultBranch(branch)
+		} else {
+			v = NewBranch(branch)
+		}
+		vlist = append(vlist, v.Pair(Revision(ci.Commit)))
+	}
+
 	return vlist, nil
 }
@@ -7,7 +7,6 @@ package gps
 import (
 	"context"
 	"io/ioutil"
-	"log"
 	"net/url"
 	"os"
 	"os/exec"
@@ -18,6 +17,8 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/Masterminds/vcs"
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -93,7 +94,7 @@ func testGitSourceInteractions(t *testing.T) {
 
 	vlist := hidePair(pvlist)
 	// check that an expected rev is present
-	is, err := src.revisionPresentIn(Revision("4a54adf81c75375d26d376459c00d5ff9b703e5e"))
+	is, err := src.revisionPresentIn(ctx, Revision("4a54adf81c75375d26d376459c00d5ff9b703e5e"))
 	if err != nil {
 		t.Errorf("Unexpected error while checking revision presence: %s", err)
 	} else if !is {
@@ -122,7 +123,7 @@ func testGitSourceInteractions(t *testing.T) {
 	}
 
 	// recheck that rev is present, this time interacting with cache differently
-	is, err = src.revisionPresentIn(Revision("30605f6ac35fcb075ad0bfa9296f90a7d891523e"))
+	is, err = src.revisionPresentIn(ctx, Revision("30605f6ac35fcb075ad0bfa9296f90a7d891523e"))
 	if err != nil {
 		t.Errorf("Unexpected error while re-checking revision presence: %s", err)
 	} else if !is {
@@ -192,7 +193,7 @@ func testGopkginSourceInteractions(t *testing.T) {
 
 		// check that an expected rev is present
 		rev := evl[0].(PairedVersion).Revision()
-		is, err := src.revisionPresentIn(rev)
+		is, err := src.revisionPresentIn(ctx, rev)
 		if err != nil {
 			t.Errorf("Unexpected error while checking revision presence: %s", err)
 		} else if !is {
@@ -231,7 +232,7 @@ func testGopkginSourceInteractions(t *testing.T) {
 		}
 
 		// recheck that rev is present, this time interacting with cache differently
-		is, err = src.revisionPresentIn(rev)
+		is, err = src.revisionPresentIn(ctx, rev)
 		if err != nil {
 			t.Errorf("Unexpected error while re-checking revision presence: %s", err)
 		} else if !is {
@@ -356,7 +357,7 @@ func testBzrSourceInteractions(t *testing.T) {
 	}
 
 	// check that an expected rev is present
-	is, err := src.revisionPresentIn(Revision("matt@mattfarina.com-20150731135137-pbphasfppmygpl68"))
+	is, err := src.revisionPresentIn(ctx, Revision("matt@mattfarina.com-20150731135137-pbphasfppmygpl68"))
 	if err != nil {
 		t.Errorf("Unexpected error while checking revision presence: %s", err)
 	} else if !is {
@@ -395,7 +396,7 @@ func testBzrSourceInteractions(t *testing.T) {
 	}
 
 	// recheck that rev is present, this time interacting with cache differently
-	is, err = src.revisionPresentIn(Revision("matt@mattfarina.com-20150731135137-pbphasfppmygpl68"))
+	is, err = src.revisionPresentIn(ctx, Revision("matt@mattfarina.com-20150731135137-pbphasfppmygpl68"))
 	if err != nil {
 		t.Errorf("Unexpected error while re-checking revision presence: %s", err)
 	} else if !is {
@@ -457,7 +458,7 @@ func testHgSourceInteractions(t *testing.T) {
 		}
 
 		// check that an expected rev is present
-		is, err := src.revisionPresentIn(Revision("103d1bddef2199c80aad7c42041223083d613ef9"))
+		is, err := src.revisionPresentIn(ctx, Revision("103d1bddef2199c80aad7c42041223083d613ef9"))
 		if err != nil {
 			t.Errorf("Unexpected error while checking revision presence: %s", err)
 		} else if !is {
@@ -503,7 +504,7 @@ func testHgSourceInteractions(t *testing.T) {
 		}
 
 		// recheck that rev is present, this time interacting with cache differently
-		is, err = src.revisionPresentIn(Revision("103d1bddef2199c80aad7c42041223083d613ef9"))
+		is, err = src.revisionPresentIn(ctx, Revision("103d1bddef2199c80aad7c42041223083d613ef9"))
 		if err != nil {
 			t.Errorf("Unexpected error while re-checking revision presence: %s", err)
 		} else if !is {
@@ -678,7 +679,7 @@ func TestGitSourceAdaptiveCleanup(t *testing.T) {
 		var err error
 		sm, err = NewSourceManager(SourceManagerConfig{
 			Cachedir: cpath,
-			Logger:   log.New(test.Writer{TB: t}, "", 0),
+			Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
 		})
 		if err != nil {
 			t.Fatalf("Unexpected error on SourceManager creation: %s", err)
@@ -858,6 +859,254 @@ func Test_hgSource_exportRevisionTo_removeVcsFiles(t *testing.T) {
 	}
 }
 
+func Test_gitSource_exportRevisionTo_submodules(t *testing.T) {
+	requiresBins(t, "git")
+
+	// Recent versions of git refuse to clone submodules over file:// unless
+	// told otherwise.
+	for k, v := range map[string]string{
+		"GIT_CONFIG_COUNT":   "1",
+		"GIT_CONFIG_KEY_0":   "protocol.file.allow",
+		"GIT_CONFIG_VALUE_0": "always",
+	} {
+		old, had := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if had {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("smcache")
+	cpath := h.Path("smcache")
+	repoPath := filepath.Join(h.Path("."), "repo")
+
+	sub := filepath.Join(h.Path("."), "sub")
+	gitTestUpstream(t, sub)
+	upstream := filepath.Join(h.Path("."), "upstream")
+	gitTestUpstream(t, upstream)
+	runGit(t, upstream, "submodule", "add", "-q", "file://"+filepath.ToSlash(sub), "third_party/sub")
+	runGit(t, filepath.Join(upstream, "third_party", "sub"), "checkout", "-q", "v1.0.0")
+	runGit(t, upstream, "commit", "-q", "-am", "add submodule")
+	rev := Revision(runGit(t, upstream, "rev-parse", "HEAD"))
+
+	u, err := url.Parse("file://" + filepath.ToSlash(upstream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := maybeGitSource{u}
+
+	ctx := context.Background()
+	isrc, err := mb.try(ctx, cpath)
+	if err != nil {
+		t.Fatalf("unexpected error while setting up gitSource for test repo: %s", err)
+	}
+	if err = isrc.initLocal(ctx); err != nil {
+		t.Fatalf("Error on cloning git repo: %s", err)
+	}
+
+	if err := isrc.exportRevisionTo(ctx, rev, repoPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(repoPath, "third_party", "sub", "a.go"))
+	if err != nil {
+		t.Fatalf("expected the submodule to be exported: %v", err)
+	}
+	if string(data) != "package a\n" {
+		t.Errorf("unexpected contents of the submodule's a.go: %q", data)
+	}
+
+	_, err = os.Stat(filepath.Join(repoPath, "third_party", "sub", ".git"))
+	if err == nil {
+		t.Fatal("expected the submodule's .git to not exist")
+	} else if !os.IsNotExist(err) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseHgBookmarks(t *testing.T) {
+	out := []byte(`   @                         3:9a4f2c7e0d1b3f5a6c8e9d0b1a2c3d4e5f607182
+ * feature                   5:1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d
+   feature@default           6:2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e
+   release                   4:3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f
+`)
+	want := []PairedVersion{
+		newDefaultBranch("@").Pair(Revision("9a4f2c7e0d1b3f5a6c8e9d0b1a2c3d4e5f607182")).(PairedVersion),
+		NewBranch("feature").Pair(Revision("1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")).(PairedVersion),
+		NewBranch("release").Pair(Revision("3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f")).(PairedVersion),
+	}
+	if got := parseHgBookmarks(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected bookmarks:\n\t(GOT): %#v\n\t(WNT): %#v", got, want)
+	}
+
+	if got := parseHgBookmarks([]byte("no bookmarks set\n")); len(got) != 0 {
+		t.Errorf("expected no bookmarks, got %#v", got)
+	}
+}
+
+func TestParseHgTopics(t *testing.T) {
+	out := []byte(`*** failed to import extension evolve: No module named evolve
+fix-parser:1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d
+split:2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e
+new-api:3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f
+split:4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a
+`)
+	want := []PairedVersion{
+		NewBranch("fix-parser").Pair(Revision("1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")).(PairedVersion),
+		NewBranch("new-api").Pair(Revision("3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f")).(PairedVersion),
+	}
+	if got := parseHgTopics(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected topics:\n\t(GOT): %#v\n\t(WNT): %#v", got, want)
+	}
+}
+
+// newStagingTestSource returns a git source whose upstream is a test
+// repository in dir, along with the hash of its latest commit.
+func newStagingTestSource(t *testing.T, dir string) (*gitSource, string) {
+	upstream := filepath.Join(dir, "upstream")
+	gitTestUpstream(t, upstream)
+	r, err := vcs.NewGitRepo("file://"+filepath.ToSlash(upstream), filepath.Join(dir, "sources", "clone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: &gitRepo{GitRepo: r}}}
+	return src, runGit(t, upstream, "rev-parse", "HEAD")
+}
+
+func TestVcsSourceInitLocalStaged(t *testing.T) {
+	requiresBins(t, "git")
+
+	dir, err := ioutil.TempDir("", "staged-init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, head := newStagingTestSource(t, dir)
+	local := src.repo.LocalPath()
+
+	// A cancelled retrieval leaves nothing at the local path.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := src.initLocal(ctx); err == nil {
+		t.Fatal("expected a cancelled retrieval to fail")
+	}
+	if _, err := os.Stat(local); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing at %s after a cancelled retrieval, got %v", local, err)
+	}
+
+	// Remains of an interrupted retrieval that cannot be resumed are
+	// cleared out.
+	if err := os.MkdirAll(stagingPath(local), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(stagingPath(local), "junk"), nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.initLocal(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !src.existsLocally(context.Background()) {
+		t.Fatal("expected the source to exist locally")
+	}
+	if _, err := os.Stat(filepath.Join(local, "junk")); !os.IsNotExist(err) {
+		t.Fatal("expected the remains of the interrupted retrieval to be cleared out")
+	}
+	if _, err := os.Stat(stagingPath(local)); !os.IsNotExist(err) {
+		t.Fatalf("expected the staging directory to be gone, got %v", err)
+	}
+	if got := runGit(t, local, "rev-parse", "HEAD"); got != head {
+		t.Fatalf("expected %s to be checked out, got %s", head, got)
+	}
+}
+
+func TestVcsSourceInitLocalResume(t *testing.T) {
+	requiresBins(t, "git")
+
+	dir, err := ioutil.TempDir("", "resumed-init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, _ := newStagingTestSource(t, dir)
+	local := src.repo.LocalPath()
+
+	// Leave a clone in the staging directory, as though moving it into place
+	// had been interrupted, and move upstream on.
+	if err := os.MkdirAll(filepath.Dir(stagingPath(local)), 0777); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "clone", "-q", src.repo.Remote(), stagingPath(local))
+	marker := filepath.Join(stagingPath(local), ".git", "resumed")
+	if err := ioutil.WriteFile(marker, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	upstream := filepath.Join(dir, "upstream")
+	runGit(t, upstream, "commit", "-q", "--allow-empty", "-m", "third")
+	head := runGit(t, upstream, "rev-parse", "HEAD")
+	branch := runGit(t, upstream, "rev-parse", "--abbrev-ref", "HEAD")
+
+	if err := src.initLocal(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(local, ".git", "resumed")); err != nil {
+		t.Fatal("expected the interrupted clone to be reused")
+	}
+	if got := runGit(t, local, "rev-parse", "origin/"+branch); got != head {
+		t.Fatalf("expected the resumed clone to have fetched %s, got %s", head, got)
+	}
+}
+
+func TestGitSourceKeepsNoWorkTree(t *testing.T) {
+	requiresBins(t, "git")
+
+	dir, err := ioutil.TempDir("", "no-worktree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, _ := newStagingTestSource(t, dir)
+	local := src.repo.LocalPath()
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first := Revision(runGit(t, filepath.Join(dir, "upstream"), "rev-parse", "v1.0.0"))
+
+	ptree, err := src.listPackages(ctx, "example.com/a", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := ptree.Packages["example.com/a"]; !has {
+		t.Errorf("expected package example.com/a at %s, got %v", first, ptree.Packages)
+	}
+	to := filepath.Join(dir, "export")
+	if err := src.exportRevisionTo(ctx, first, to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "a.go")); err != nil {
+		t.Errorf("expected a.go to be exported: %v", err)
+	}
+
+	// Only git's objects are kept in the cache.
+	fis, err := ioutil.ReadDir(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 || fis[0].Name() != ".git" {
+		t.Errorf("expected nothing to be checked out in %s, got %d entries", local, len(fis))
+	}
+	if _, err := os.Stat(filepath.Join(local, ".git", "index")); !os.IsNotExist(err) {
+		t.Errorf("expected the repository's index to be left alone, got %v", err)
+	}
+	if err := src.maybeClean(ctx); err != nil {
+		t.Errorf("expected a repository without a working tree to be clean, got %v", err)
+	}
+}
+
 // Fail a test if the specified binaries aren't installed.
 func requiresBins(t *testing.T, bins ...string) {
 	for _, b := range bins {
@@ -109,7 +109,7 @@ func NewVersion(body string) UnpairedVersion {
 	if err != nil {
 		return plainVersion(body)
 	}
-	return semVersion{sv: sv}
+	return pooledSemver(body, sv)
 }
 
 // A Revision represents an immutable versioning identifier.
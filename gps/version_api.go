@@ -0,0 +1,196 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// versionAPIMaxPages bounds the number of pages of branches or tags read from
+// a provider's API, past which the versions are listed with git instead.
+const versionAPIMaxPages = 50
+
+// A gitHostAPI is the REST API of a git hosting provider, through which the
+// branches and tags of the repositories it hosts are listed, and their
+// revisions downloaded, without running git.
+type gitHostAPI struct {
+	// project returns the URL of the API's description of the repository
+	// at path on the provider's host.
+	project func(path string) string
+	// branches and tags are appended to the URL returned by project to
+	// list the repository's branches and tags.
+	branches, tags string
+	// archive, with %s replaced by a revision, is appended to the URL
+	// returned by project to download a gzipped tarball of the revision.
+	archive string
+}
+
+// gitHostAPIs maps the hosts whose APIs are known to those APIs.
+var gitHostAPIs = map[string]gitHostAPI{
+	"github.com": {
+		project: func(path string) string {
+			return "https://api.github.com/repos/" + path
+		},
+		branches: "/branches",
+		tags:     "/tags",
+		archive:  "/tarball/%s",
+	},
+	"gitlab.com": {
+		project: func(path string) string {
+			return "https://gitlab.com/api/v4/projects/" + url.PathEscape(path)
+		},
+		branches: "/repository/branches",
+		tags:     "/repository/tags",
+		archive:  "/repository/archive.tar.gz?sha=%s",
+	},
+}
+
+// apiRef is a branch or tag, as listed by a provider's API. GitHub names the
+// commit's hash sha, and GitLab id.
+type apiRef struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+		ID  string `json:"id"`
+	} `json:"commit"`
+}
+
+func (r apiRef) revision() Revision {
+	if r.Commit.SHA != "" {
+		return Revision(r.Commit.SHA)
+	}
+	return Revision(r.Commit.ID)
+}
+
+// listVersionsFromAPI lists the versions of the git repository at remote
+// through the API of its host. It returns false if the host has no known API.
+func listVersionsFromAPI(ctx context.Context, rc *remoteConfig, remote string) ([]PairedVersion, bool, error) {
+	api, base, host, has := gitHostAPIFor(remote)
+	if !has {
+		return nil, false, nil
+	}
+
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := getAPI(ctx, rc, host, base, &project); err != nil {
+		return nil, true, err
+	}
+
+	var vlist []PairedVersion
+	for _, list := range []string{api.branches, api.tags} {
+		next := base + list + "?per_page=100"
+		for page := 0; next != ""; page++ {
+			if page == versionAPIMaxPages {
+				return nil, true, errors.Errorf("%s has too many branches and tags to list through the API", remote)
+			}
+			var refs []apiRef
+			var err error
+			next, err = getAPI(ctx, rc, host, next, &refs)
+			if err != nil {
+				return nil, true, err
+			}
+			for _, ref := range refs {
+				rev := ref.revision()
+				if !gitHashRE.MatchString(string(rev)) {
+					continue
+				}
+				if list == api.tags {
+					vlist = append(vlist, NewVersion(ref.Name).Pair(rev))
+				} else {
+					vlist = append(vlist, branchVersion{
+						name:      ref.Name,
+						isDefault: ref.Name == project.DefaultBranch,
+					}.Pair(rev).(PairedVersion))
+				}
+			}
+		}
+	}
+	if len(vlist) == 0 {
+		return nil, true, errors.Errorf("no versions of %s were listed through the API", remote)
+	}
+	return vlist, true, nil
+}
+
+// gitHostAPIFor returns the API of the host of the git repository at remote,
+// the URL of the API's description of the repository, and the host, if the
+// host's API is known.
+func gitHostAPIFor(remote string) (gitHostAPI, string, string, bool) {
+	u, err := url.Parse(remote)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return gitHostAPI{}, "", "", false
+	}
+	api, has := gitHostAPIs[u.Host]
+	if !has {
+		return gitHostAPI{}, "", "", false
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if strings.Count(path, "/") < 1 {
+		return gitHostAPI{}, "", "", false
+	}
+	return api, api.project(path), u.Host, true
+}
+
+// doAPI makes a GET request for u, returning the response if it succeeds. The
+// request carries the credentials of the API's host, or, failing those, those
+// of the repository's host, repoHost.
+func doAPI(ctx context.Context, rc *remoteConfig, repoHost, u string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	if c, has := rc.credentials(req.URL.Host); has && req.URL.Scheme == "https" {
+		req.Header.Set(c.header())
+	} else if c, has := rc.credentials(repoHost); has && req.URL.Scheme == "https" {
+		req.Header.Set(c.header())
+	}
+
+	resp, err := rc.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed HTTP request to URL %q", u)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("unable to fetch %s: %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+// getAPI decodes the JSON response to a request for u into v, returning the
+// URL of the next page of the response, if it has one.
+func getAPI(ctx context.Context, rc *remoteConfig, repoHost, u string, v interface{}) (string, error) {
+	resp, err := doAPI(ctx, rc, repoHost, u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", errors.Wrapf(err, "unable to decode the response from %s", u)
+	}
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// nextLink returns the URL of the next page named in the Link header link, if
+// it names one.
+func nextLink(link string) string {
+	for _, l := range strings.Split(link, ",") {
+		parts := strings.Split(l, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, p := range parts[1:] {
+			if strings.TrimSpace(p) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
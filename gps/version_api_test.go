@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNextLink(t *testing.T) {
+	cases := map[string]string{
+		``: ``,
+		`<https://api.github.com/repositories/1/tags?page=2>; rel="next", <https://api.github.com/repositories/1/tags?page=5>; rel="last"`:  `https://api.github.com/repositories/1/tags?page=2`,
+		`<https://api.github.com/repositories/1/tags?page=4>; rel="prev", <https://api.github.com/repositories/1/tags?page=1>; rel="first"`: ``,
+	}
+	for link, want := range cases {
+		if got := nextLink(link); got != want {
+			t.Errorf("nextLink(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
+
+func TestListVersionsFromAPI(t *testing.T) {
+	const (
+		rev1 = "30605f6ac35fcb075ad0bfa9296f90a7d891523e"
+		rev2 = "4a54f5a6e7bcf6fc5b3d8a0b1b8d2e2c6c9e1f00"
+	)
+	var auth string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"default_branch": "main"}`)
+	})
+	mux.HandleFunc("/repos/owner/repo/branches", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/owner/repo/branches?page=2>; rel="next"`, r.Host))
+			fmt.Fprintf(w, `[{"name": "main", "commit": {"sha": %q}}]`, rev1)
+			return
+		}
+		fmt.Fprintf(w, `[{"name": "dev", "commit": {"sha": %q}}]`, rev2)
+	})
+	mux.HandleFunc("/repos/owner/repo/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"name": "v1.0.0", "commit": {"id": %q}}]`, rev1)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	gitHostAPIs[host] = gitHostAPI{
+		project:  func(path string) string { return srv.URL + "/repos/" + path },
+		branches: "/branches",
+		tags:     "/tags",
+	}
+	defer delete(gitHostAPIs, host)
+
+	ctx := context.Background()
+	vlist, has, err := listVersionsFromAPI(ctx, nil, srv.URL+"/owner/repo.git")
+	if err != nil || !has {
+		t.Fatalf("expected the versions to be listed, got %v, %v", has, err)
+	}
+	want := []PairedVersion{
+		newDefaultBranch("main").Pair(rev1),
+		NewBranch("dev").Pair(rev2),
+		NewVersion("v1.0.0").Pair(rev1),
+	}
+	if !reflect.DeepEqual(vlist, want) {
+		t.Errorf("unexpected versions:\n\t(GOT): %s\n\t(WNT): %s", vlist, want)
+	}
+	if auth != "" {
+		t.Errorf("expected no credentials to be sent over HTTP, got %q", auth)
+	}
+
+	if _, has, _ := listVersionsFromAPI(ctx, nil, "https://git.example.com/owner/repo"); has {
+		t.Error("expected no API for an unknown host")
+	}
+	if _, has, err := listVersionsFromAPI(ctx, nil, srv.URL+"/owner/missing"); !has || err == nil {
+		t.Errorf("expected an error listing a missing repository, got %v, %v", has, err)
+	}
+}
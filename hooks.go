@@ -0,0 +1,399 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// Names of the hooks that may be declared in the manifest's [hooks] table.
+const (
+	HookPreEnsure  = "pre-ensure"
+	HookPostEnsure = "post-ensure"
+)
+
+// hookPostEnsureWebhooks is the key of the manifest's [hooks] table listing
+// the URLs notified of the changes made by dep ensure.
+const hookPostEnsureWebhooks = "post-ensure-webhooks"
+
+// Hooks holds the commands declared in the manifest's [hooks] table. Each
+// command is run through the system shell from the project root.
+//
+// PostEnsureWebhooks are URLs, which may refer to environment variables, that
+// the JSON summary of the changes made to Gopkg.lock is posted to after
+// dep ensure changes it.
+type Hooks struct {
+	PreEnsure          []string
+	PostEnsure         []string
+	PostEnsureWebhooks []string
+}
+
+// commands returns the commands registered for the named hook.
+func (h Hooks) commands(hook string) []string {
+	switch hook {
+	case HookPreEnsure:
+		return h.PreEnsure
+	case HookPostEnsure:
+		return h.PostEnsure
+	}
+	return nil
+}
+
+// HookSummary describes the changes made to Gopkg.lock by a dep operation. It
+// is passed to hook commands through environment variables, and as JSON on
+// their standard input.
+type HookSummary struct {
+	Added, Removed, Modified []gps.ProjectRoot
+
+	// Diff is the diff the summary was built from, if any, which gives the
+	// versions the projects changed from and to.
+	Diff *gps.LockDiff
+}
+
+// NewHookSummary builds a HookSummary from a lock diff. A nil diff yields an
+// empty summary.
+func NewHookSummary(diff *gps.LockDiff) HookSummary {
+	s := HookSummary{Diff: diff}
+	if diff == nil {
+		return s
+	}
+
+	for _, d := range diff.Add {
+		s.Added = append(s.Added, d.Name)
+	}
+	for _, d := range diff.Remove {
+		s.Removed = append(s.Removed, d.Name)
+	}
+	for _, d := range diff.Modify {
+		s.Modified = append(s.Modified, d.Name)
+	}
+	return s
+}
+
+// Changed reports whether the summary records any project changes.
+func (s HookSummary) Changed() bool {
+	return len(s.Added)+len(s.Removed)+len(s.Modified) > 0
+}
+
+// Environ returns the summary as a list of environment variables of the form
+// "key=value". Project lists are space-separated.
+func (s HookSummary) Environ() []string {
+	join := func(prs []gps.ProjectRoot) string {
+		strs := make([]string, len(prs))
+		for i, pr := range prs {
+			strs[i] = string(pr)
+		}
+		return strings.Join(strs, " ")
+	}
+
+	changed := "false"
+	if s.Changed() {
+		changed = "true"
+	}
+
+	return []string{
+		"DEP_LOCK_CHANGED=" + changed,
+		"DEP_PROJECTS_ADDED=" + join(s.Added),
+		"DEP_PROJECTS_REMOVED=" + join(s.Removed),
+		"DEP_PROJECTS_MODIFIED=" + join(s.Modified),
+	}
+}
+
+// hookPayload is the JSON summary of a hook's changes, as hook commands and
+// webhooks receive it.
+type hookPayload struct {
+	Hook        string              `json:"hook"`
+	ImportRoot  string              `json:"importRoot"`
+	LockChanged bool                `json:"lockChanged"`
+	Added       []hookProjectChange `json:"added"`
+	Removed     []hookProjectChange `json:"removed"`
+	Modified    []hookProjectChange `json:"modified"`
+
+	// Text describes the changes for people to read, and is what chat
+	// services, such as Slack's incoming webhooks, display.
+	Text string `json:"text"`
+}
+
+// hookProjectChange is the change made to a project in Gopkg.lock. Only the
+// properties that changed are set; added projects have no previous values,
+// and removed projects no current ones.
+type hookProjectChange struct {
+	Project  string      `json:"project"`
+	Source   *hookChange `json:"source,omitempty"`
+	Version  *hookChange `json:"version,omitempty"`
+	Branch   *hookChange `json:"branch,omitempty"`
+	Revision *hookChange `json:"revision,omitempty"`
+}
+
+type hookChange struct {
+	Previous string `json:"previous,omitempty"`
+	Current  string `json:"current,omitempty"`
+}
+
+// JSON returns the summary, of the changes the named hook is run for in the
+// project with the import path ir, as the JSON object hooks receive.
+func (s HookSummary) JSON(hook string, ir gps.ProjectRoot) ([]byte, error) {
+	pl := hookPayload{
+		Hook:        hook,
+		ImportRoot:  string(ir),
+		LockChanged: s.Changed(),
+		Added:       []hookProjectChange{},
+		Removed:     []hookProjectChange{},
+		Modified:    []hookProjectChange{},
+	}
+
+	details := make(map[gps.ProjectRoot]gps.LockedProjectDiff)
+	if s.Diff != nil {
+		for _, diffs := range [][]gps.LockedProjectDiff{s.Diff.Add, s.Diff.Remove, s.Diff.Modify} {
+			for _, d := range diffs {
+				details[d.Name] = d
+			}
+		}
+	}
+	// Added and removed projects are diffed against themselves, so only the
+	// side that exists is kept.
+	change := func(pr gps.ProjectRoot, previous, current bool) hookProjectChange {
+		c := hookProjectChange{Project: string(pr)}
+		d, has := details[pr]
+		if !has {
+			return c
+		}
+		side := func(sd *gps.StringDiff) *hookChange {
+			if sd == nil {
+				return nil
+			}
+			hc := &hookChange{}
+			if previous {
+				hc.Previous = sd.Previous
+			}
+			if current {
+				hc.Current = sd.Current
+			}
+			return hc
+		}
+		c.Source, c.Version, c.Branch, c.Revision = side(d.Source), side(d.Version), side(d.Branch), side(d.Revision)
+		return c
+	}
+	for _, pr := range s.Added {
+		pl.Added = append(pl.Added, change(pr, false, true))
+	}
+	for _, pr := range s.Removed {
+		pl.Removed = append(pl.Removed, change(pr, true, false))
+	}
+	for _, pr := range s.Modified {
+		pl.Modified = append(pl.Modified, change(pr, true, true))
+	}
+	pl.Text = pl.text()
+
+	return json.MarshalIndent(pl, "", "  ")
+}
+
+// text describes the changes in pl for people to read.
+func (pl hookPayload) text() string {
+	if !pl.LockChanged {
+		return fmt.Sprintf("%s: %s is unchanged", pl.ImportRoot, LockName)
+	}
+
+	version := func(c hookProjectChange, previous bool) string {
+		pick := func(hc *hookChange) string {
+			switch {
+			case hc == nil:
+				return ""
+			case previous:
+				return hc.Previous
+			}
+			return hc.Current
+		}
+		if v := pick(c.Version); v != "" {
+			return v
+		}
+		if b := pick(c.Branch); b != "" {
+			return "branch " + b
+		}
+		if r := pick(c.Revision); len(r) > 7 {
+			return r[:7]
+		} else if r != "" {
+			return r
+		}
+		return ""
+	}
+	list := func(verb string, changes []hookProjectChange, describe func(hookProjectChange) string) string {
+		if len(changes) == 0 {
+			return ""
+		}
+		items := make([]string, len(changes))
+		for i, c := range changes {
+			items[i] = strings.TrimSpace(c.Project + " " + describe(c))
+		}
+		return verb + " " + strings.Join(items, ", ")
+	}
+
+	var parts []string
+	for _, part := range []string{
+		list("added", pl.Added, func(c hookProjectChange) string { return version(c, false) }),
+		list("removed", pl.Removed, func(c hookProjectChange) string { return "" }),
+		list("updated", pl.Modified, func(c hookProjectChange) string {
+			from, to := version(c, true), version(c, false)
+			if from == to {
+				return ""
+			}
+			return fmt.Sprintf("from %s to %s", from, to)
+		}),
+	} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return fmt.Sprintf("%s: dep ensure %s in %s", pl.ImportRoot, strings.Join(parts, "; "), LockName)
+}
+
+// RunHooks runs the commands registered in p's manifest for the named hook, in
+// order, stopping at the first failure. Commands are run from p.AbsRoot, with
+// the project root and the given summary of changes set in their environment,
+// and the summary as JSON on their standard input. If the hook is post-ensure,
+// and the summary records changes, the summary is then posted to the
+// manifest's post-ensure webhooks.
+//
+// No commands are run, and no webhooks notified, if c.DisableHooks is set.
+func (c *Ctx) RunHooks(p *Project, hook string, summary HookSummary) error {
+	if c.DisableHooks || p.Manifest == nil {
+		return nil
+	}
+
+	cmds := p.Manifest.Hooks.commands(hook)
+	var webhooks []string
+	if hook == HookPostEnsure && summary.Changed() {
+		webhooks = p.Manifest.Hooks.PostEnsureWebhooks
+	}
+	if len(cmds) == 0 && len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := summary.JSON(hook, p.ImportRoot)
+	if err != nil {
+		return errors.Wrap(err, "could not summarize the changes for the hooks")
+	}
+
+	env := append(os.Environ(),
+		"DEP_HOOK="+hook,
+		"DEP_PROJECT_ROOT="+p.AbsRoot,
+		"DEP_IMPORT_ROOT="+string(p.ImportRoot),
+	)
+	env = append(env, summary.Environ()...)
+
+	for _, command := range cmds {
+		c.Logger().Debugf("Running %s hook: %s", hook, command)
+
+		cmd := shellCommand(command)
+		cmd.Dir = p.AbsRoot
+		cmd.Env = env
+		cmd.Stdin = bytes.NewReader(payload)
+		out, err := cmd.CombinedOutput()
+		if len(out) > 0 {
+			c.Out.Print(string(out))
+		}
+		if err != nil {
+			return errors.Wrapf(err, "%s hook %q failed", hook, command)
+		}
+	}
+
+	for _, webhook := range webhooks {
+		if err := c.notifyWebhook(webhook, payload); err != nil {
+			// Gopkg.lock and vendor/ have been written; a notification that
+			// could not be delivered does not undo that.
+			c.Logger().Warnf("%s", err)
+		}
+	}
+
+	return nil
+}
+
+// webhookClient posts to webhooks.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// notifyWebhook posts payload to the webhook at rawurl, after expanding the
+// environment variables in it. The URLs of webhooks are often secrets, so
+// only their hosts appear in errors.
+func (c *Ctx) notifyWebhook(rawurl string, payload []byte) error {
+	u, err := url.Parse(os.ExpandEnv(rawurl))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("post-ensure webhook %q is not an http or https URL", rawurl)
+	}
+	c.Logger().Debugf("Notifying post-ensure webhook at %s", u.Host)
+
+	resp, err := webhookClient.Post(u.String(), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return errors.Wrapf(err, "could not notify the post-ensure webhook at %s", u.Host)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("the post-ensure webhook at %s answered %s", u.Host, resp.Status)
+	}
+	return nil
+}
+
+// shellCommand returns a command that runs s through the system shell.
+func shellCommand(s string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", s)
+	}
+	return exec.Command("sh", "-c", s)
+}
+
+// LockChangesSince summarizes the differences between old and the lock
+// currently on disk in p's root. A missing lock on disk is treated as empty.
+func (p *Project) LockChangesSince(old *Lock) (HookSummary, error) {
+	cur, err := p.lockOnDisk()
+	if err != nil {
+		return HookSummary{}, err
+	}
+
+	// Avoid handing typed nil pointers to DiffLocks, which only defaults
+	// untyped nil locks.
+	var l1, l2 gps.Lock
+	if old != nil {
+		l1 = old
+	}
+	if cur != nil {
+		l2 = cur
+	}
+	return NewHookSummary(gps.DiffLocks(l1, l2)), nil
+}
+
+// lockOnDisk reads the lock currently on disk in p's root, which may have
+// been rewritten since p was loaded. It returns nil if there is none.
+func (p *Project) lockOnDisk() (*Lock, error) {
+	lp := filepath.Join(p.AbsRoot, LockName)
+	lf, err := os.Open(lp)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", lp)
+	}
+	defer lf.Close()
+
+	l, err := readLock(lf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while parsing %s", lp)
+	}
+	return l, nil
+}
@@ -0,0 +1,277 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestHookSummaryEnviron(t *testing.T) {
+	l1 := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+				gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"},
+				gps.NewVersion("v1.0.0").Pair("c6335b6b7d7a1e9f5a1f9e3b3c7d0b6c1b5c4e3a"),
+				[]string{"."},
+			),
+		},
+	}
+	l2 := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+				gps.NewVersion("v1.1.0").Pair("a0196baa11ea047dd65037287451d36b861b00ea"),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/qux"},
+				gps.NewVersion("v2.0.0").Pair("5c607206be5decd28e6263ffffdcee067266015e"),
+				[]string{"."},
+			),
+		},
+	}
+
+	got := NewHookSummary(gps.DiffLocks(l1, l2)).Environ()
+	want := []string{
+		"DEP_LOCK_CHANGED=true",
+		"DEP_PROJECTS_ADDED=github.com/foo/qux",
+		"DEP_PROJECTS_REMOVED=github.com/foo/baz",
+		"DEP_PROJECTS_MODIFIED=github.com/foo/bar",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected hook environment:\n\t(GOT) %v\n\t(WNT) %v", got, want)
+	}
+
+	got = NewHookSummary(nil).Environ()
+	want = []string{
+		"DEP_LOCK_CHANGED=false",
+		"DEP_PROJECTS_ADDED=",
+		"DEP_PROJECTS_REMOVED=",
+		"DEP_PROJECTS_MODIFIED=",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected hook environment:\n\t(GOT) %v\n\t(WNT) %v", got, want)
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in this test require a POSIX shell")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("proj")
+	root := h.Path("proj")
+
+	var out bytes.Buffer
+	ctx := &Ctx{
+		Out: log.New(&out, "", 0),
+		Err: log.New(ioutil.Discard, "", 0),
+	}
+	p := &Project{
+		AbsRoot:    root,
+		ImportRoot: "github.com/example/proj",
+		Manifest:   NewManifest(),
+	}
+	p.Manifest.Hooks.PostEnsure = []string{
+		`echo "$DEP_HOOK $DEP_IMPORT_ROOT $DEP_PROJECTS_ADDED"`,
+		`pwd > hook.out`,
+	}
+
+	summary := HookSummary{Added: []gps.ProjectRoot{"github.com/foo/bar"}}
+	if err := ctx.RunHooks(p, HookPostEnsure, summary); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "post-ensure github.com/example/proj github.com/foo/bar\n"; got != want {
+		t.Fatalf("unexpected hook output:\n\t(GOT) %q\n\t(WNT) %q", got, want)
+	}
+	h.MustExist(filepath.Join(root, "hook.out"))
+
+	p.Manifest.Hooks.PreEnsure = []string{"exit 3", "touch never"}
+	err := ctx.RunHooks(p, HookPreEnsure, HookSummary{})
+	if err == nil || !strings.Contains(err.Error(), `pre-ensure hook "exit 3" failed`) {
+		t.Fatalf("expected failing hook to return an error, got %v", err)
+	}
+	h.MustNotExist(filepath.Join(root, "never"))
+
+	ctx.DisableHooks = true
+	if err := ctx.RunHooks(p, HookPreEnsure, HookSummary{}); err != nil {
+		t.Fatalf("disabled hooks should not run, got %v", err)
+	}
+}
+
+func TestHookSummaryJSON(t *testing.T) {
+	l1 := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+				gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"},
+				gps.NewBranch("master").Pair("c6335b6b7d7a1e9f5a1f9e3b3c7d0b6c1b5c4e3a"),
+				[]string{"."},
+			),
+		},
+	}
+	l2 := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+				gps.NewVersion("v1.1.0").Pair("a0196baa11ea047dd65037287451d36b861b00ea"),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/qux"},
+				gps.Revision("5c607206be5decd28e6263ffffdcee067266015e"),
+				[]string{"."},
+			),
+		},
+	}
+
+	got, err := NewHookSummary(gps.DiffLocks(l1, l2)).JSON(HookPostEnsure, "github.com/example/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "hook": "post-ensure",
+  "importRoot": "github.com/example/proj",
+  "lockChanged": true,
+  "added": [
+    {
+      "project": "github.com/foo/qux",
+      "revision": {
+        "current": "5c607206be5decd28e6263ffffdcee067266015e"
+      }
+    }
+  ],
+  "removed": [
+    {
+      "project": "github.com/foo/baz",
+      "branch": {
+        "previous": "master"
+      },
+      "revision": {
+        "previous": "c6335b6b7d7a1e9f5a1f9e3b3c7d0b6c1b5c4e3a"
+      }
+    }
+  ],
+  "modified": [
+    {
+      "project": "github.com/foo/bar",
+      "version": {
+        "previous": "v1.0.0",
+        "current": "v1.1.0"
+      },
+      "revision": {
+        "previous": "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0",
+        "current": "a0196baa11ea047dd65037287451d36b861b00ea"
+      }
+    }
+  ],
+  "text": "github.com/example/proj: dep ensure added github.com/foo/qux 5c60720; removed github.com/foo/baz; updated github.com/foo/bar from v1.0.0 to v1.1.0 in Gopkg.lock"
+}`
+	if string(got) != want {
+		t.Errorf("unexpected hook summary:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	got, err = NewHookSummary(nil).JSON(HookPreEnsure, "github.com/example/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pl hookPayload
+	if err := json.Unmarshal(got, &pl); err != nil {
+		t.Fatal(err)
+	}
+	if pl.LockChanged || pl.Added == nil || pl.Text != "github.com/example/proj: Gopkg.lock is unchanged" {
+		t.Errorf("unexpected summary of no changes:\n%s", got)
+	}
+}
+
+func TestRunHooksWebhooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in this test require a POSIX shell")
+	}
+
+	var posted [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected webhook request: %s with %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		posted = append(posted, b)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("proj")
+
+	var stderr bytes.Buffer
+	ctx := &Ctx{
+		Out: log.New(ioutil.Discard, "", 0),
+		Err: log.New(&stderr, "", 0),
+	}
+	p := &Project{
+		AbsRoot:    h.Path("proj"),
+		ImportRoot: "github.com/example/proj",
+		Manifest:   NewManifest(),
+	}
+	defer os.Unsetenv("DEP_TEST_WEBHOOK_PATH")
+	os.Setenv("DEP_TEST_WEBHOOK_PATH", "notify")
+	p.Manifest.Hooks.PostEnsure = []string{"cat > summary.json"}
+	p.Manifest.Hooks.PostEnsureWebhooks = []string{srv.URL + "/fail", srv.URL + "/${DEP_TEST_WEBHOOK_PATH}"}
+
+	// Webhooks are only notified of changes.
+	if err := ctx.RunHooks(p, HookPostEnsure, HookSummary{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 0 {
+		t.Fatalf("webhooks were notified of no changes: %s", posted)
+	}
+
+	summary := HookSummary{Added: []gps.ProjectRoot{"github.com/foo/bar"}}
+	want, err := summary.JSON(HookPostEnsure, p.ImportRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.RunHooks(p, HookPostEnsure, summary); err != nil {
+		t.Fatalf("a webhook that failed failed the hook: %v", err)
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(p.AbsRoot, "summary.json")); !bytes.Equal(got, want) {
+		t.Errorf("the hook command did not receive the summary:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+	if len(posted) != 2 || !bytes.Equal(posted[1], want) {
+		t.Errorf("the webhooks did not receive the summary: %s", posted)
+	}
+	if !strings.Contains(stderr.String(), "answered 500") {
+		t.Errorf("the failed webhook was not warned about: %q", stderr.String())
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// InputsMemo remembers the digest of the inputs to solving a project, as
+// gps.Solver.HashInputs computes it, and the project's direct dependencies,
+// along with a fingerprint of what they were derived from: the metadata of the
+// nodes in the project's tree, the contents of its manifest, its import path
+// and the analyzer. While the fingerprint is unchanged, whether the lock is in
+// sync with the project can be told without analyzing its packages, which on a
+// large project takes far longer than reading its directories.
+//
+// A nil *InputsMemo memoizes nothing.
+type InputsMemo struct {
+	path string
+}
+
+// inputsMemoEntry is what an InputsMemo keeps in its file.
+type inputsMemoEntry struct {
+	Fingerprint string   `json:"fingerprint"`
+	Digest      string   `json:"digest"`
+	DirectDeps  []string `json:"direct-deps"`
+}
+
+// NewInputsMemo returns an InputsMemo kept in the file at path.
+func NewInputsMemo(path string) *InputsMemo {
+	return &InputsMemo{path: path}
+}
+
+// fingerprint returns the fingerprint of p that an entry for it in the memo
+// must have to be used, and whether p was modified too recently for it to be
+// trusted.
+func (m *InputsMemo) fingerprint(p *Project) (string, bool, error) {
+	fp, racy, err := pkgtree.FingerprintDirectory(p.ResolvedAbsRoot)
+	if err != nil {
+		return "", false, err
+	}
+	mb, err := ioutil.ReadFile(filepath.Join(p.AbsRoot, ManifestName))
+	if err != nil {
+		return "", false, err
+	}
+
+	h := sha256.New()
+	ai := Analyzer{}.Info()
+	fmt.Fprintf(h, "%s\n%s\n%s %s\n", fp, p.ImportRoot, ai.Name, strconv.Itoa(ai.Version))
+	h.Write(mb)
+	return hex.EncodeToString(h.Sum(nil)), racy, nil
+}
+
+// load returns the entry in m's file, if there is one that is usable.
+func (m *InputsMemo) load() (inputsMemoEntry, bool) {
+	var e inputsMemoEntry
+	b, err := ioutil.ReadFile(m.path)
+	if err != nil {
+		return e, false
+	}
+	if err := json.Unmarshal(b, &e); err != nil {
+		return e, false
+	}
+	return e, true
+}
+
+// save writes e to m's file.
+func (m *InputsMemo) save(e inputsMemoEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0777); err != nil {
+		return errors.Wrap(err, "failed to create directory for inputs memo")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(m.path), filepath.Base(m.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
+
+// InputsDigest returns the digest of the inputs to solving p, for comparison
+// with the one recorded in its lock, and p's direct dependencies, as
+// GetDirectDependencyNames returns them. They are taken from p.InputsMemo if
+// p is unchanged since they were memoized; otherwise p's packages are analyzed,
+// and what is found memoized.
+func (p *Project) InputsDigest(sm gps.SourceManager) ([]byte, map[gps.ProjectRoot]bool, error) {
+	var fp string
+	var racy bool
+	if m := p.InputsMemo; m != nil {
+		var err error
+		fp, racy, err = m.fingerprint(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if e, has := m.load(); has && e.Fingerprint == fp {
+			if digest, err := hex.DecodeString(e.Digest); err == nil {
+				directDeps := make(map[gps.ProjectRoot]bool, len(e.DirectDeps))
+				for _, pr := range e.DirectDeps {
+					directDeps[gps.ProjectRoot(pr)] = true
+				}
+				return digest, directDeps, nil
+			}
+		}
+	}
+
+	ptree, directDeps, err := p.GetDirectDependencyNames(sm)
+	if err != nil {
+		return nil, nil, err
+	}
+	params := p.MakeParams()
+	params.RootPackageTree = ptree
+	s, err := gps.Prepare(params, sm)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not set up solver for input hashing")
+	}
+	digest := s.HashInputs()
+
+	if m := p.InputsMemo; m != nil && !racy {
+		e := inputsMemoEntry{
+			Fingerprint: fp,
+			Digest:      hex.EncodeToString(digest),
+			DirectDeps:  make([]string, 0, len(directDeps)),
+		}
+		for pr := range directDeps {
+			e.DirectDeps = append(e.DirectDeps, string(pr))
+		}
+		sort.Strings(e.DirectDeps)
+		// Everything memoized can be computed again, so failing to save it
+		// is no reason to fail.
+		m.save(e)
+	}
+	return digest, directDeps, nil
+}
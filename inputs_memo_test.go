@@ -0,0 +1,93 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestProjectInputsDigestMemo(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	pc := NewTestProjectContext(h, "inputsmemoproject")
+	defer pc.Release()
+
+	p := pc.Project
+	p.ResolvedAbsRoot = p.AbsRoot
+	p.ImportRoot = "example.com/inputsmemoproject"
+	p.InputsMemo = NewInputsMemo(filepath.Join(h.Path("."), "inputs.json"))
+	h.TempFile(filepath.Join(pc.tempProjectDir, "main.go"), "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n")
+	h.TempFile(filepath.Join(pc.tempProjectDir, ManifestName), "# a\n")
+	pc.Load()
+
+	// Files modified moments ago are not trusted to change their fingerprint
+	// when modified again, so nothing is memoized for them.
+	backdate := func() {
+		old := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, name := range []string{"main.go", ManifestName, "."} {
+			h.Must(os.Chtimes(filepath.Join(p.AbsRoot, name), old, old))
+		}
+	}
+
+	want, _, err := p.InputsDigest(pc.SourceManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p.InputsMemo.path); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be memoized for a freshly modified project, got %v", err)
+	}
+
+	backdate()
+	got, directDeps, err := p.InputsDigest(pc.SourceManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected digest %x, got %x", want, got)
+	}
+	if len(directDeps) != 0 {
+		t.Fatalf("expected no direct dependencies, got %v", directDeps)
+	}
+
+	// Tamper with the memoized digest, to tell whether it is used.
+	e, has := p.InputsMemo.load()
+	if !has {
+		t.Fatal("expected the inputs to be memoized")
+	}
+	e.Digest = hex.EncodeToString([]byte("memoized"))
+	b, err := json.Marshal(e)
+	h.Must(err)
+	h.Must(ioutil.WriteFile(p.InputsMemo.path, b, 0666))
+
+	got, _, err = p.InputsDigest(pc.SourceManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "memoized" {
+		t.Fatalf("expected the memoized digest to be used, got %x", got)
+	}
+
+	// Changing the manifest, even without changing its size or modification
+	// time, invalidates the memo.
+	h.TempFile(filepath.Join(pc.tempProjectDir, ManifestName), "# b\n")
+	backdate()
+	got, _, err = p.InputsDigest(pc.SourceManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected the digest to be computed again as %x, got %x", want, got)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package events streams what dep does as it does it, as typed events, for
+// wrappers and CI systems to render progress and collect telemetry from,
+// rather than scraping dep's output. Events are written as newline-delimited
+// JSON, one object per event.
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// The types of the events that dep emits.
+const (
+	// FetchStarted and FetchFinished bracket cloning a source into the
+	// cache, or fetching its latest data into it.
+	FetchStarted  = "fetch_started"
+	FetchFinished = "fetch_finished"
+	// SolveAttempt is the solver trying to select a version of a project.
+	SolveAttempt = "solve_attempt"
+	// ProjectWritten is a project written to vendor/, or failing to be.
+	ProjectWritten = "project_written"
+	// FilePruned is a file removed from a project by pruning.
+	FilePruned = "file_pruned"
+	// Warning is a warning dep printed.
+	Warning = "warning"
+)
+
+// An Event is something dep did. Only the fields that apply to its Type are
+// set.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	// Source is the URL of the source fetched.
+	Source string `json:"source,omitempty"`
+	// Project and Version are the project, and its version, attempted or
+	// written.
+	Project string `json:"project,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Path is the path of the file pruned.
+	Path string `json:"path,omitempty"`
+	// Seconds is how long a fetch took.
+	Seconds float64 `json:"seconds,omitempty"`
+	// Error is why an attempt, a fetch or a write failed.
+	Error string `json:"error,omitempty"`
+	// Message is the text of a warning.
+	Message string `json:"message,omitempty"`
+}
+
+// A Stream writes events to a writer, one JSON object per line. Its methods
+// may be called concurrently.
+type Stream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewStream returns a Stream writing to w.
+func NewStream(w io.Writer) *Stream {
+	return &Stream{enc: json.NewEncoder(w)}
+}
+
+// Emit writes e, stamped with the current time if its Time is zero. Once a
+// write fails, nothing more is written.
+func (s *Stream) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = s.enc.Encode(e)
+	}
+}
+
+// Err returns the error of the write that failed, if any did.
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+var (
+	mu  sync.RWMutex
+	def *Stream
+)
+
+// SetDefault makes s the Stream that Emit writes to. With a nil s, events are
+// discarded, as they are until SetDefault is first called.
+func SetDefault(s *Stream) {
+	mu.Lock()
+	def = s
+	mu.Unlock()
+}
+
+// Enabled reports whether events are being streamed, for those emitting many
+// of them to skip preparing them when they are not.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return def != nil
+}
+
+// Emit emits e to the default Stream, if there is one.
+func Emit(e Event) {
+	mu.RLock()
+	s := def
+	mu.RUnlock()
+	if s != nil {
+		s.Emit(e)
+	}
+}
+
+// Warn emits a Warning with the message msg.
+func Warn(msg string) {
+	Emit(Event{Type: Warning, Message: msg})
+}
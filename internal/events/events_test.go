@@ -0,0 +1,72 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package events
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewStream(&buf)
+	at := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+	s.Emit(Event{Type: FetchFinished, Time: at, Source: "https://github.com/pkg/errors", Seconds: 1.5})
+	s.Emit(Event{Type: SolveAttempt, Time: at, Project: "github.com/pkg/errors", Version: "v0.8.0"})
+
+	want := `{"type":"fetch_finished","time":"2018-07-01T12:00:00Z","source":"https://github.com/pkg/errors","seconds":1.5}
+{"type":"solve_attempt","time":"2018-07-01T12:00:00Z","project":"github.com/pkg/errors","version":"v0.8.0"}
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected events:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	buf.Reset()
+	s.Emit(Event{Type: Warning, Message: "careful"})
+	if !bytes.Contains(buf.Bytes(), []byte(`"time":"`)) {
+		t.Fatalf("expected the event to be stamped with the time, got %s", buf.String())
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.n++
+	return 0, errors.New("closed")
+}
+
+func TestStreamStopsAfterError(t *testing.T) {
+	w := &failingWriter{}
+	s := NewStream(w)
+	s.Emit(Event{Type: Warning})
+	s.Emit(Event{Type: Warning})
+	if s.Err() == nil {
+		t.Fatal("expected the stream to report the failed write")
+	}
+	if w.n != 1 {
+		t.Fatalf("expected nothing to be written after a failed write, got %d writes", w.n)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	defer SetDefault(nil)
+
+	Emit(Event{Type: Warning})
+	if Enabled() {
+		t.Fatal("expected events to be discarded without a default stream")
+	}
+
+	var buf bytes.Buffer
+	SetDefault(NewStream(&buf))
+	if !Enabled() {
+		t.Fatal("expected events to be streamed")
+	}
+	Warn("careful")
+	if !bytes.Contains(buf.Bytes(), []byte(`"type":"warning"`)) || !bytes.Contains(buf.Bytes(), []byte(`"message":"careful"`)) {
+		t.Fatalf("unexpected events: %s", buf.String())
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/events"
 )
 
 const (
@@ -190,6 +191,7 @@ func NewBrokenImportFeedback(ld *gps.LockDiff) *BrokenImportFeedback {
 func (b BrokenImportFeedback) LogFeedback(logger *log.Logger) {
 	for _, bi := range b.brokenImports {
 		logger.Printf("Warning: Unable to preserve imported lock %v\n", bi)
+		events.Warn(fmt.Sprintf("Unable to preserve imported lock %v", bi))
 	}
 }
 
@@ -0,0 +1,84 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cloneSupport remembers what CloneSupported found out, keyed by the device of
+// the filesystem probed, or by the directory probed where devices are unknown.
+var cloneSupport sync.Map
+
+// CloneSupported reports whether files can be cloned, as copy-on-write clones
+// or reflinks, within the filesystem that holds the directory dir. Btrfs, XFS
+// and APFS support them; ext4, tmpfs and overlayfs, among others, do not. It is
+// found out by cloning a small temporary file in dir, once per filesystem, so
+// callers about to write many trees can tell at once whether their files will
+// share their contents with the originals.
+func CloneSupported(dir string) bool {
+	fi, err := os.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		return false
+	}
+
+	var key interface{} = filepath.Clean(dir)
+	if dev, ok := device(fi); ok {
+		key = dev
+	}
+	if supported, has := cloneSupport.Load(key); has {
+		return supported.(bool)
+	}
+
+	supported := probeClone(dir)
+	cloneSupport.Store(key, supported)
+	return supported
+}
+
+// probeClone clones a temporary file in dir, and reports whether it could.
+func probeClone(dir string) bool {
+	f, err := ioutil.TempFile(dir, ".dep-clone-probe")
+	if err != nil {
+		return false
+	}
+	src := f.Name()
+	defer os.Remove(src)
+	_, err = f.Write([]byte("dep"))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return false
+	}
+
+	dst := src + ".clone"
+	if err := cloneFile(src, dst, 0600); err != nil {
+		return false
+	}
+	os.Remove(dst)
+	return true
+}
+
+// sameDevice reports whether the nodes of a and b are on the same filesystem,
+// which it takes them to be where it cannot tell.
+func sameDevice(a, b os.FileInfo) bool {
+	adev, aok := device(a)
+	bdev, bok := device(b)
+	return !aok || !bok || adev == bdev
+}
+
+// canCloneInto reports whether the files of the tree whose root's info is fi
+// can be cloned into the existing directory dst: files are never cloned from
+// one filesystem to another.
+func canCloneInto(fi os.FileInfo, dst string) bool {
+	dfi, err := os.Stat(dst)
+	if err != nil || !sameDevice(fi, dfi) {
+		return false
+	}
+	return CloneSupported(dst)
+}
@@ -0,0 +1,39 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// sysClonefileat is the number of the clonefileat system call, which
+	// clones files on APFS.
+	sysClonefileat = 462
+	atFDCWD        = -2
+	cloneNoFollow  = 0x0001
+)
+
+// cloneFile creates dst, with permissions perm, as a copy-on-write clone of
+// the regular file src.
+func cloneFile(src, dst string, perm os.FileMode) error {
+	srcp, err := syscall.BytePtrFromString(src)
+	if err != nil {
+		return err
+	}
+	dstp, err := syscall.BytePtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	fdcwd := atFDCWD
+	_, _, errno := syscall.Syscall6(sysClonefileat, uintptr(fdcwd), uintptr(unsafe.Pointer(srcp)),
+		uintptr(fdcwd), uintptr(unsafe.Pointer(dstp)), cloneNoFollow, 0)
+	if errno != 0 {
+		return errno
+	}
+	return os.Chmod(dst, perm)
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request, _IOW(0x94, 9, int), which clones the
+// contents of one file into another on filesystems, such as Btrfs and XFS,
+// that support reflinks.
+const ficlone = 0x40049409
+
+// cloneFile creates dst, with permissions perm, as a copy-on-write clone of
+// the regular file src.
+func cloneFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	if err = out.Close(); errno != 0 {
+		err = errno
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	// The umask applies to the permissions dst was created with.
+	return os.Chmod(dst, perm)
+}
@@ -0,0 +1,15 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux,!darwin
+
+package fs
+
+import "os"
+
+// cloneFile always fails with errCloneUnsupported, as files cannot be cloned
+// on this platform.
+func cloneFile(src, dst string, perm os.FileMode) error {
+	return errCloneUnsupported
+}
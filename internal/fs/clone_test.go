@@ -0,0 +1,118 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// tmpfsDir returns a directory on a tmpfs filesystem, if the system has one
+// that tests can write to.
+func tmpfsDir(t *testing.T) string {
+	if runtime.GOOS != "linux" {
+		t.Skip("tmpfs is only looked for on linux")
+	}
+	dir, err := ioutil.TempDir("/dev/shm", "dep")
+	if err != nil {
+		t.Skipf("no tmpfs to write to: %v", err)
+	}
+	return dir
+}
+
+func TestCloneSupported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Whatever the answer is, it is the same every time, and leaves nothing
+	// behind.
+	supported := CloneSupported(dir)
+	if again := CloneSupported(dir); again != supported {
+		t.Errorf("expected the same answer twice, got %v and %v", supported, again)
+	}
+	if fis, err := ioutil.ReadDir(dir); err != nil || len(fis) != 0 {
+		t.Errorf("expected the probe to leave %s empty, got %d files, %v", dir, len(fis), err)
+	}
+
+	if CloneSupported(filepath.Join(dir, "missing")) {
+		t.Error("expected files not to be clonable into a missing directory")
+	}
+
+	t.Run("tmpfs", func(t *testing.T) {
+		shm := tmpfsDir(t)
+		defer os.RemoveAll(shm)
+		if CloneSupported(shm) {
+			t.Error("expected files not to be clonable on tmpfs")
+		}
+	})
+}
+
+// TestCopyDirAcrossFilesystems copies trees to and from tmpfs, which cannot be
+// cloned to or from, nor within. Where the temporary directory is on
+// overlayfs, as in many containers, that is covered too.
+func TestCopyDirAcrossFilesystems(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	shm := tmpfsDir(t)
+	defer os.RemoveAll(shm)
+
+	srcdir := filepath.Join(dir, "src")
+	files := mkCopyTree(t, srcdir, 4, 3, 2*copyBufferSize)
+
+	check := func(t *testing.T, destdir string) {
+		for path, want := range files {
+			got, err := ioutil.ReadFile(filepath.Join(destdir, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("expected %s to be copied intact, got %d bytes of %d", path, len(got), len(want))
+			}
+			sfi, err := os.Stat(filepath.Join(srcdir, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dfi, err := os.Stat(filepath.Join(destdir, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sfi.Mode() != dfi.Mode() {
+				t.Errorf("expected %s to have mode %s, got %s", path, sfi.Mode(), dfi.Mode())
+			}
+		}
+	}
+
+	shmdir := filepath.Join(shm, "dest")
+	t.Run("to tmpfs", func(t *testing.T) {
+		if err := CopyDir(srcdir, shmdir); err != nil {
+			t.Fatal(err)
+		}
+		check(t, shmdir)
+	})
+	t.Run("from tmpfs", func(t *testing.T) {
+		destdir := filepath.Join(dir, "back")
+		if err := CopyDir(shmdir, destdir); err != nil {
+			t.Fatal(err)
+		}
+		check(t, destdir)
+	})
+	t.Run("link to tmpfs", func(t *testing.T) {
+		destdir := filepath.Join(shm, "linked")
+		if err := LinkDir(srcdir, destdir); err != nil {
+			t.Fatal(err)
+		}
+		check(t, destdir)
+	})
+}
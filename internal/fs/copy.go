@@ -0,0 +1,159 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/dep/internal/metrics"
+	"github.com/pkg/errors"
+)
+
+// copyBufferSize is the size of the buffers that files are copied through when
+// they cannot be copied within the kernel.
+const copyBufferSize = 128 << 10
+
+// copyBuffers holds the buffers that files are copied through, so that copying
+// a tree of many files does not allocate a buffer for each.
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, copyBufferSize)
+		return &b
+	},
+}
+
+// copyTree copies the tree rooted at src, whose info is fi, to dst, which must
+// not exist. The whole of src is read first, so that all of the directories of
+// dst can be made before any file is copied into them: each is then made with
+// a single os.Mkdir, as its parent is known to exist.
+func copyTree(src, dst string, fi os.FileInfo) error {
+	type node struct {
+		rel string
+		fi  os.FileInfo
+	}
+	var dirs, files []node
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := ioutil.ReadDir(longPath(filepath.Join(src, rel)))
+		if err != nil {
+			return errors.Wrapf(err, "cannot read directory %s", filepath.Join(src, rel))
+		}
+		for _, entry := range entries {
+			n := node{rel: filepath.Join(rel, entry.Name()), fi: entry}
+			if entry.IsDir() {
+				dirs = append(dirs, n)
+				if err := walk(n.rel); err != nil {
+					return err
+				}
+			} else {
+				files = append(files, n)
+			}
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(longPath(dst), fi.Mode()); err != nil {
+		return errors.Wrapf(err, "cannot mkdir %s", dst)
+	}
+	// Files are cloned rather than copied where the filesystem allows it. The
+	// clones are as independent of the originals as copies would be, but take
+	// neither the time to copy nor the space.
+	clone := canCloneInto(fi, dst)
+	for _, d := range dirs {
+		path := filepath.Join(dst, d.rel)
+		if err := os.Mkdir(longPath(path), d.fi.Mode()); err != nil {
+			return errors.Wrapf(err, "cannot mkdir %s", path)
+		}
+	}
+
+	for _, f := range files {
+		srcPath := filepath.Join(src, f.rel)
+		dstPath := filepath.Join(dst, f.rel)
+
+		var err error
+		if f.fi.Mode().IsRegular() {
+			var cloned bool
+			if clone {
+				err = cloneFile(srcPath, dstPath, f.fi.Mode().Perm())
+				cloned = err == nil
+				if err != nil && isUnsupportedLink(err) {
+					// Copy this file, and the rest of the tree, instead.
+					clone, err = false, nil
+				}
+			}
+			if !cloned && err == nil {
+				err = copyRegular(srcPath, dstPath, f.fi.Mode())
+			}
+		} else {
+			// This will include symlinks, which is what we want when
+			// copying things.
+			err = copyFile(srcPath, dstPath)
+		}
+		if err != nil {
+			return errors.Wrap(err, "copying file failed")
+		}
+	}
+	return nil
+}
+
+// copyRegular copies the contents of the regular file src to dst, which is
+// created if it does not exist and truncated if it does, and gives dst the
+// permissions of mode.
+func copyRegular(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(longPath(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(longPath(dst))
+	if err != nil {
+		return err
+	}
+
+	if err = copyContents(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	// Check for write errors on Close
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	// Before Go 1.9, os.Chmod did not use the extended-length form of long
+	// paths itself.
+	//
+	// See: https://github.com/golang/dep/issues/774
+	// and https://github.com/golang/go/issues/20829
+	return os.Chmod(longPath(dst), mode)
+}
+
+// copyContents copies what remains of in to out, within the kernel where the
+// platform allows it, and otherwise through a pooled buffer.
+func copyContents(out, in *os.File) error {
+	written, done, err := copyFileRange(out, in)
+	if done || err != nil {
+		metrics.Add(metrics.BytesCopied, written)
+		return err
+	}
+
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+	// *os.File implements io.ReaderFrom and io.WriterTo on some versions of
+	// Go, which io.CopyBuffer would use in place of buf; hide them, as
+	// copyFileRange has already done whatever the kernel can.
+	n, err := io.CopyBuffer(struct{ io.Writer }{out}, struct{ io.Reader }{in}, *buf)
+	metrics.Add(metrics.BytesCopied, written+n)
+	return err
+}
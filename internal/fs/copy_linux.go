@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// noCopyFileRange is set once copy_file_range(2) is found to be missing from
+// the kernel, so that it is not tried again.
+var noCopyFileRange int32
+
+// copyFileRangeChunk is the most that a single copy_file_range(2) is asked to
+// copy.
+const copyFileRangeChunk = 1 << 30
+
+// copyFileRange copies as much of what remains of in to out as it can with
+// copy_file_range(2), which copies within the kernel, and on filesystems that
+// support it, without copying data at all. It reports whether it copied all
+// of in; if not, it leaves the offsets of both files after whatever it did
+// copy, so the rest may be copied by other means. Where the kernel or the
+// filesystem cannot copy the files at all, that is the whole of them. It
+// returns how many bytes it copied.
+func copyFileRange(out, in *os.File) (int64, bool, error) {
+	if atomic.LoadInt32(&noCopyFileRange) != 0 {
+		return 0, false, nil
+	}
+
+	var written int64
+	for {
+		n, err := unix.CopyFileRange(int(in.Fd()), nil, int(out.Fd()), nil, copyFileRangeChunk, 0)
+		switch err {
+		case nil:
+			if n == 0 {
+				// Nothing left to copy is indistinguishable from a file,
+				// such as those in procfs, that reports no contents to
+				// copy_file_range(2); only trust the end of a file that
+				// has been copied from.
+				return written, written > 0, nil
+			}
+			written += int64(n)
+		case unix.ENOSYS:
+			atomic.StoreInt32(&noCopyFileRange, 1)
+			return written, false, nil
+		case unix.EXDEV, unix.EINVAL, unix.EOPNOTSUPP, unix.EPERM, unix.EBADF:
+			// Older kernels cannot copy between filesystems, and some
+			// filesystems cannot copy at all.
+			return written, false, nil
+		case unix.EINTR:
+		default:
+			return written, false, &os.PathError{Op: "copy_file_range", Path: out.Name(), Err: err}
+		}
+	}
+}
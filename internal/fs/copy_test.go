@@ -0,0 +1,109 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mkCopyTree writes a tree of dirs directories beneath dir, each holding files
+// files, whose sizes range up to maxSize bytes. It returns the contents of the
+// files, keyed by their paths relative to dir.
+func mkCopyTree(tb testing.TB, dir string, dirs, files, maxSize int) map[string][]byte {
+	contents := make(map[string][]byte)
+	for i := 0; i < dirs; i++ {
+		// Nest every other directory in the one before it.
+		sub := fmt.Sprintf("dir%d", i)
+		if i%2 == 1 {
+			sub = filepath.Join(fmt.Sprintf("dir%d", i-1), sub)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			tb.Fatal(err)
+		}
+		for j := 0; j < files; j++ {
+			path := filepath.Join(sub, fmt.Sprintf("file%d.go", j))
+			b := bytes.Repeat([]byte{byte('a' + j%26)}, (i*files+j)*7919%(maxSize+1))
+			if err := ioutil.WriteFile(filepath.Join(dir, path), b, 0644); err != nil {
+				tb.Fatal(err)
+			}
+			contents[path] = b
+		}
+	}
+	return contents
+}
+
+func TestCopyDirTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcdir := filepath.Join(dir, "src")
+	// Some of the files are larger than the buffers files are copied
+	// through.
+	files := mkCopyTree(t, srcdir, 6, 5, 3*copyBufferSize)
+	if err := os.Mkdir(filepath.Join(srcdir, "empty"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	destdir := filepath.Join(dir, "dest")
+	if err := CopyDir(srcdir, destdir); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range files {
+		got, err := ioutil.ReadFile(filepath.Join(destdir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("expected %s to be copied intact, got %d bytes of %d", path, len(got), len(want))
+		}
+	}
+
+	sfi, err := os.Stat(filepath.Join(srcdir, "empty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dfi, err := os.Stat(filepath.Join(destdir, "empty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sfi.Mode() != dfi.Mode() {
+		t.Errorf("expected the empty directory to have mode %s, got %s", sfi.Mode(), dfi.Mode())
+	}
+}
+
+func BenchmarkCopyDir(b *testing.B) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Roughly the shape of a vendor tree: many directories of many small
+	// files, with the odd large one.
+	srcdir := filepath.Join(dir, "src")
+	mkCopyTree(b, srcdir, 100, 20, 64<<10)
+
+	b.ResetTimer()
+	destdir := filepath.Join(dir, "dest")
+	for i := 0; i < b.N; i++ {
+		if err := CopyDir(srcdir, destdir); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		if err := os.RemoveAll(destdir); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+}
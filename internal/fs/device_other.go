@@ -0,0 +1,15 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build windows plan9
+
+package fs
+
+import "os"
+
+// device reports that the device of the filesystem that holds a node is not
+// known on this platform.
+func device(fi os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -6,12 +6,12 @@ package fs
 
 import (
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 	"unicode"
 
 	"github.com/pkg/errors"
@@ -155,14 +155,20 @@ func EquivalentPaths(p1, p2 string) (bool, error) {
 
 // RenameWithFallback attempts to rename a file or directory, but falls back to
 // copying in the event of a cross-device link error. If the fallback copy
-// succeeds, src is still removed, emulating normal rename behavior.
+// succeeds, src is still removed, emulating normal rename behavior; if it
+// fails, nothing of it is left at dst.
+//
+// Renames that fail because another process, such as a virus scanner, has one
+// of the files open are retried for a while before giving up.
 func RenameWithFallback(src, dst string) error {
-	_, err := os.Stat(src)
+	_, err := os.Stat(longPath(src))
 	if err != nil {
 		return errors.Wrapf(err, "cannot stat %s", src)
 	}
 
-	err = os.Rename(src, dst)
+	err = retryTransient(func() error {
+		return os.Rename(longPath(src), longPath(dst))
+	})
 	if err == nil {
 		return nil
 	}
@@ -177,6 +183,10 @@ func renameByCopy(src, dst string) error {
 	if dir, _ := IsDir(src); dir {
 		cerr = CopyDir(src, dst)
 		if cerr != nil {
+			// Leave no partial copy behind, unless it was not this one's.
+			if cerr != errDstExist {
+				RemoveAll(dst)
+			}
 			cerr = errors.Wrap(cerr, "copying directory failed")
 		}
 	} else {
@@ -190,7 +200,51 @@ func renameByCopy(src, dst string) error {
 		return errors.Wrapf(cerr, "rename fallback failed: cannot rename %s to %s", src, dst)
 	}
 
-	return errors.Wrapf(os.RemoveAll(src), "cannot delete %s", src)
+	return errors.Wrapf(RemoveAll(src), "cannot delete %s", src)
+}
+
+// RestoreWithFallback moves the file or directory from back to to, from where
+// it was moved to make way for another that has since failed to take its
+// place, replacing whatever that left at to. If from does not exist, as when
+// the move out of the way failed before it could be made, to is left alone.
+func RestoreWithFallback(from, to string) error {
+	if _, err := os.Lstat(longPath(from)); os.IsNotExist(err) {
+		return nil
+	}
+	if err := RemoveAll(to); err != nil {
+		return errors.Wrapf(err, "cannot delete %s", to)
+	}
+	return RenameWithFallback(from, to)
+}
+
+// RemoveAll is like os.RemoveAll, but on Windows, it removes trees however
+// deeply nested they are, as the trees of vendored projects often are, and
+// retries for a while where another process has one of their files open.
+func RemoveAll(path string) error {
+	return retryTransient(func() error {
+		return os.RemoveAll(longPath(path))
+	})
+}
+
+// transientRetryTimeout is how long retryTransient keeps retrying for.
+var transientRetryTimeout = 5 * time.Second
+
+// retryTransient calls fn, and again after increasingly long waits for as long
+// as it fails with errors that isTransient reports other processes may soon
+// stop causing, until transientRetryTimeout has passed.
+func retryTransient(fn func() error) error {
+	deadline := time.Now().Add(transientRetryTimeout)
+	wait := 10 * time.Millisecond
+	for {
+		err := fn()
+		if err == nil || !isTransient(err) || time.Now().Add(wait).After(deadline) {
+			return err
+		}
+		time.Sleep(wait)
+		if wait *= 2; wait > time.Second {
+			wait = time.Second
+		}
+	}
 }
 
 // IsCaseSensitiveFilesystem determines if the filesystem where dir
@@ -359,7 +413,7 @@ func CopyDir(src, dst string) error {
 
 	// We use os.Lstat() here to ensure we don't fall in a loop where a symlink
 	// actually links to a one of its parent directories.
-	fi, err := os.Lstat(src)
+	fi, err := os.Lstat(longPath(src))
 	if err != nil {
 		return err
 	}
@@ -367,7 +421,7 @@ func CopyDir(src, dst string) error {
 		return errSrcNotDir
 	}
 
-	_, err = os.Stat(dst)
+	_, err = os.Stat(longPath(dst))
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -375,33 +429,7 @@ func CopyDir(src, dst string) error {
 		return errDstExist
 	}
 
-	if err = os.MkdirAll(dst, fi.Mode()); err != nil {
-		return errors.Wrapf(err, "cannot mkdir %s", dst)
-	}
-
-	entries, err := ioutil.ReadDir(src)
-	if err != nil {
-		return errors.Wrapf(err, "cannot read directory %s", dst)
-	}
-
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			if err = CopyDir(srcPath, dstPath); err != nil {
-				return errors.Wrap(err, "copying directory failed")
-			}
-		} else {
-			// This will include symlinks, which is what we want when
-			// copying things.
-			if err = copyFile(srcPath, dstPath); err != nil {
-				return errors.Wrap(err, "copying file failed")
-			}
-		}
-	}
-
-	return nil
+	return copyTree(src, dst, fi)
 }
 
 // copyFile copies the contents of the file named src to the file named
@@ -431,53 +459,23 @@ func copyFile(src, dst string) (err error) {
 		}
 	}
 
-	in, err := os.Open(src)
-	if err != nil {
-		return
-	}
-	defer in.Close()
-
-	out, err := os.Create(dst)
-	if err != nil {
-		return
-	}
-
-	if _, err = io.Copy(out, in); err != nil {
-		out.Close()
-		return
-	}
-
-	// Check for write errors on Close
-	if err = out.Close(); err != nil {
-		return
-	}
-
-	si, err := os.Stat(src)
+	si, err := os.Stat(longPath(src))
 	if err != nil {
 		return
 	}
 
-	// Temporary fix for Go < 1.9
-	//
-	// See: https://github.com/golang/dep/issues/774
-	// and https://github.com/golang/go/issues/20829
-	if runtime.GOOS == "windows" {
-		dst = fixLongPath(dst)
-	}
-	err = os.Chmod(dst, si.Mode())
-
-	return
+	return copyRegular(src, dst, si.Mode())
 }
 
 // cloneSymlink will create a new symlink that points to the resolved path of sl.
 // If sl is a relative symlink, dst will also be a relative symlink.
 func cloneSymlink(sl, dst string) error {
-	resolved, err := os.Readlink(sl)
+	resolved, err := os.Readlink(longPath(sl))
 	if err != nil {
 		return err
 	}
 
-	return os.Symlink(resolved, dst)
+	return os.Symlink(resolved, longPath(dst))
 }
 
 // EnsureDir tries to ensure that a directory is present at the given path. It first
@@ -555,7 +553,7 @@ func IsRegular(name string) (bool, error) {
 
 // IsSymlink determines if the given path is a symbolic link.
 func IsSymlink(path string) (bool, error) {
-	l, err := os.Lstat(path)
+	l, err := os.Lstat(longPath(path))
 	if err != nil {
 		return false, err
 	}
@@ -563,6 +561,22 @@ func IsSymlink(path string) (bool, error) {
 	return l.Mode()&os.ModeSymlink == os.ModeSymlink, nil
 }
 
+// longPath returns the form of path that the copying, renaming, removing and
+// walking of trees in this package pass to the os package, so that they work
+// however long path is. On Windows, paths of MAX_PATH characters or more can
+// only be used in their extended-length form, which the os package only
+// converts absolute paths to, so path is made absolute, and converted if it
+// needs to be. Elsewhere, path is returned unmodified.
+func longPath(path string) string {
+	if runtime.GOOS != "windows" {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return fixLongPath(path)
+}
+
 // fixLongPath returns the extended-length (\\?\-prefixed) form of
 // path when needed, in order to avoid the default 260 character file
 // path limit imposed by Windows. If path is not easily converted to
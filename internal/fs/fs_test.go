@@ -231,6 +231,80 @@ func TestRenameWithFallback(t *testing.T) {
 	}
 }
 
+func TestRenameByCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcpath := filepath.Join(dir, "src")
+	mkCopyTree(t, srcpath, 2, 2, 16)
+	dstpath := filepath.Join(dir, "dst")
+	if err := os.MkdirAll(filepath.Join(dstpath, "keep"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory already at dst is not the copy's to clean up.
+	if err := renameByCopy(srcpath, dstpath); err == nil {
+		t.Fatal("expected an error if dst is an existing directory, but got nil")
+	}
+	if _, err := os.Stat(filepath.Join(dstpath, "keep")); err != nil {
+		t.Fatalf("expected %s to be left alone, got %v", dstpath, err)
+	}
+
+	dstpath = filepath.Join(dir, "moved")
+	if err := renameByCopy(srcpath, dstpath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(srcpath); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, got %v", srcpath, err)
+	}
+	if _, err := os.Stat(filepath.Join(dstpath, "dir0")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreWithFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	orig := filepath.Join(dir, "vendor")
+	bak := filepath.Join(dir, "vendor.orig")
+	mkdir := func(path string) {
+		if err := os.MkdirAll(path, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing was moved out of the way: what is in place stays.
+	mkdir(filepath.Join(orig, "new"))
+	if err := RestoreWithFallback(bak, orig); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(orig, "new")); err != nil {
+		t.Fatalf("expected %s to be left alone, got %v", orig, err)
+	}
+
+	// The original replaces whatever was partly moved in.
+	mkdir(filepath.Join(bak, "old"))
+	if err := RestoreWithFallback(bak, orig); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(orig, "old")); err != nil {
+		t.Fatalf("expected %s to be restored, got %v", orig, err)
+	}
+	if _, err := os.Stat(filepath.Join(orig, "new")); !os.IsNotExist(err) {
+		t.Fatalf("expected what was in place of %s to be removed, got %v", orig, err)
+	}
+	if _, err := os.Stat(bak); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be moved, got %v", bak, err)
+	}
+}
+
 func TestIsCaseSensitiveFilesystem(t *testing.T) {
 	isLinux := runtime.GOOS == "linux"
 	isWindows := runtime.GOOS == "windows"
@@ -749,6 +823,66 @@ func TestCopyFileLongFilePath(t *testing.T) {
 	}
 }
 
+func TestLongPathTree(t *testing.T) {
+	h := test.NewHelper(t)
+	h.TempDir(".")
+	defer h.Cleanup()
+
+	// The os package only uses the extended-length form of absolute paths on
+	// Windows, so the tree is worked on through relative paths.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	tmpPath := h.Path(".")
+	h.Must(os.Chdir(tmpPath))
+
+	deep := "src"
+	for len(filepath.Join(tmpPath, deep)) <= 300 {
+		deep = filepath.Join(deep, "dir4567890")
+	}
+	h.Must(os.MkdirAll(filepath.Join(tmpPath, deep), 0777))
+	h.Must(ioutil.WriteFile(filepath.Join(tmpPath, deep, "file.go"), []byte("package dir4567890\n"), 0644))
+	rel := strings.TrimPrefix(deep, "src"+string(filepath.Separator))
+
+	if err := CopyDir("src", "copied"); err != nil {
+		t.Fatalf("unexpected error while copying a deep tree: %v", err)
+	}
+	if err := LinkDir("src", "linked"); err != nil {
+		t.Fatalf("unexpected error while linking a deep tree: %v", err)
+	}
+	if err := RenameWithFallback("copied", "renamed"); err != nil {
+		t.Fatalf("unexpected error while renaming a deep tree: %v", err)
+	}
+
+	for _, dir := range []string{"renamed", "linked"} {
+		var files int
+		err := Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if !info.IsDir() {
+				files++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error while walking a deep tree: %v", err)
+		}
+		if files != 1 {
+			t.Errorf("expected 1 file in %s, got %d", dir, files)
+		}
+		if _, err := os.Stat(filepath.Join(tmpPath, dir, rel, "file.go")); err != nil {
+			t.Error(err)
+		}
+
+		if err := RemoveAll(dir); err != nil {
+			t.Fatalf("unexpected error while removing a deep tree: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(tmpPath, dir)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", dir, err)
+		}
+	}
+}
+
 // C:\Users\appveyor\AppData\Local\Temp\1\gotest639065787\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890\dir4567890
 
 func TestCopyFileFail(t *testing.T) {
@@ -0,0 +1,191 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// LinkDir is like CopyDir, but where the filesystem allows it, the files in
+// dst share their contents with those in src, rather than being copies of
+// them, which saves both the time taken to copy them and the space the copies
+// would take up:
+//
+// - files are cloned where the filesystem supports copy-on-write clones, or
+// reflinks, as Btrfs, XFS and APFS do: the clones share the blocks of the
+// originals until either is written to;
+//
+// - otherwise, files that are read-only in src, as in trees that are never to
+// be modified, are hard linked;
+//
+// - otherwise, and whenever src and dst are on different filesystems, files
+// are copied. CloneSupported tells ahead of time which it will be.
+//
+// Hard links are made only on platforms other than Windows, and only to
+// read-only files, so that neither tree is changed through the other by
+// accident. A hard linked file is still the same file in both trees, though,
+// so one made writable again and changed in either is changed in both: trees
+// that are linked from must be checked for such changes before being reused.
+func LinkDir(src, dst string) error {
+	src = filepath.Clean(src)
+	dst = filepath.Clean(dst)
+
+	fi, err := os.Lstat(longPath(src))
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errSrcNotDir
+	}
+
+	_, err = os.Stat(longPath(dst))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		return errDstExist
+	}
+
+	l := &linker{noLink: runtime.GOOS == "windows"}
+	if pfi, err := os.Stat(filepath.Dir(dst)); err == nil && !sameDevice(fi, pfi) {
+		// Files can be neither cloned nor linked across filesystems.
+		l.noClone, l.noLink = true, true
+	}
+	return l.linkDir(src, dst, fi)
+}
+
+// LinkDirCopies reports whether LinkDir copies the files of src into a tree in
+// the directory dir, rather than cloning or hard linking them, as it does
+// across filesystems, and on Windows where files cannot be cloned.
+func LinkDirCopies(src, dir string) bool {
+	fi, err := os.Stat(longPath(src))
+	if err != nil {
+		return true
+	}
+	dfi, err := os.Stat(longPath(dir))
+	if err != nil {
+		return true
+	}
+	if !sameDevice(fi, dfi) {
+		return true
+	}
+	return runtime.GOOS == "windows" && !CloneSupported(dir)
+}
+
+// linker populates trees for LinkDir, remembering what the filesystem does not
+// support, so that it is tried once per tree rather than once per file.
+type linker struct {
+	noClone, noLink bool
+}
+
+func (l *linker) linkDir(src, dst string, fi os.FileInfo) error {
+	if err := os.MkdirAll(longPath(dst), fi.Mode()); err != nil {
+		return errors.Wrapf(err, "cannot mkdir %s", dst)
+	}
+
+	entries, err := ioutil.ReadDir(longPath(src))
+	if err != nil {
+		return errors.Wrapf(err, "cannot read directory %s", dst)
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		switch {
+		case entry.IsDir():
+			if err := l.linkDir(srcPath, dstPath, entry); err != nil {
+				return errors.Wrap(err, "linking directory failed")
+			}
+		case entry.Mode().IsRegular():
+			if err := l.linkFile(srcPath, dstPath, entry); err != nil {
+				return errors.Wrap(err, "linking file failed")
+			}
+		default:
+			// Symlinks are cloned, as CopyDir does.
+			if err := copyFile(srcPath, dstPath); err != nil {
+				return errors.Wrap(err, "copying file failed")
+			}
+		}
+	}
+	return nil
+}
+
+// linkFile clones, hard links or copies the regular file src, whose info is
+// fi, to dst.
+func (l *linker) linkFile(src, dst string, fi os.FileInfo) error {
+	if !l.noClone {
+		err := cloneFile(src, dst, fi.Mode().Perm())
+		if err == nil {
+			return nil
+		}
+		if !isUnsupportedLink(err) {
+			return err
+		}
+		l.noClone = true
+	}
+
+	if !l.noLink && fi.Mode()&0222 == 0 {
+		err := os.Link(longPath(src), longPath(dst))
+		if err == nil {
+			return nil
+		}
+		if !isUnsupportedLink(err) {
+			return err
+		}
+		l.noLink = true
+	}
+
+	return copyRegular(src, dst, fi.Mode())
+}
+
+// errCloneUnsupported is returned by cloneFile on platforms where files cannot
+// be cloned.
+var errCloneUnsupported = errors.New("cloning files is not supported on this platform")
+
+// isUnsupportedLink reports whether err, returned by cloneFile or os.Link,
+// means that files cannot be cloned or linked between the directories in
+// question, rather than that something went wrong with the file itself.
+func isUnsupportedLink(err error) bool {
+	if err == errCloneUnsupported {
+		return true
+	}
+	if lerr, ok := err.(*os.LinkError); ok {
+		err = lerr.Err
+	}
+	// ENOTSUP is the same as EOPNOTSUPP on some platforms, and so cannot be
+	// another case of a switch.
+	if err == syscall.ENOTSUP {
+		return true
+	}
+	switch err {
+	case syscall.EXDEV, syscall.EOPNOTSUPP, syscall.ENOSYS, syscall.ENOTTY,
+		syscall.EINVAL, syscall.EPERM, syscall.EMLINK:
+		return true
+	}
+	return false
+}
+
+// MakeReadOnly removes the write permissions of the regular files in the tree
+// rooted at dir, so that LinkDir may hard link to them. Directories are left
+// writable, so that the tree can still be removed. It does nothing on Windows,
+// where read-only files cannot be removed.
+func MakeReadOnly(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() || fi.Mode()&0222 == 0 {
+			return err
+		}
+		return os.Chmod(path, fi.Mode().Perm()&^0222)
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLinkDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcdir := filepath.Join(dir, "src")
+	files := map[string]string{
+		"myfile":                          "hello world",
+		filepath.Join("subdir", "file"):   "subdir file",
+		filepath.Join("subdir", "locked"): "read-only file",
+	}
+	for path, contents := range files {
+		fn := filepath.Join(srcdir, path)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MakeReadOnly(filepath.Join(srcdir, "subdir", "locked")); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, destdir string, noClone bool) {
+		for path, contents := range files {
+			sfi, err := os.Stat(filepath.Join(srcdir, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dfn := filepath.Join(destdir, path)
+			got, err := ioutil.ReadFile(dfn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != contents {
+				t.Errorf("expected %q in %s, got %q", contents, path, got)
+			}
+			dfi, err := os.Stat(dfn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sfi.Mode() != dfi.Mode() {
+				t.Errorf("expected %s to have mode %s, got %s", path, sfi.Mode(), dfi.Mode())
+			}
+
+			// Writable files must never be linked. Read-only ones are, where
+			// they are not cloned.
+			linked := os.SameFile(sfi, dfi)
+			wantLinked := noClone && sfi.Mode()&0222 == 0 && runtime.GOOS != "windows"
+			if linked != wantLinked {
+				t.Errorf("expected %s to be hard linked: %v, got %v", path, wantLinked, linked)
+			}
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		destdir := filepath.Join(dir, "default")
+		if err := LinkDir(srcdir, destdir); err != nil {
+			t.Fatal(err)
+		}
+		for path := range files {
+			sfi, _ := os.Stat(filepath.Join(srcdir, path))
+			dfi, err := os.Stat(filepath.Join(destdir, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sfi.Mode()&0222 != 0 && os.SameFile(sfi, dfi) {
+				t.Errorf("expected writable %s not to be hard linked", path)
+			}
+		}
+	})
+
+	t.Run("noClone", func(t *testing.T) {
+		destdir := filepath.Join(dir, "noClone")
+		fi, err := os.Stat(srcdir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &linker{noClone: true, noLink: runtime.GOOS == "windows"}
+		if err := l.linkDir(srcdir, destdir, fi); err != nil {
+			t.Fatal(err)
+		}
+		check(t, destdir, true)
+	})
+
+	if err := LinkDir(srcdir, filepath.Join(dir, "noClone")); err != errDstExist {
+		t.Fatalf("expected %v for an existing destination, got %v", errDstExist, err)
+	}
+}
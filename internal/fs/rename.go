@@ -29,3 +29,10 @@ func renameFallback(err error, src, dst string) error {
 
 	return renameByCopy(src, dst)
 }
+
+// isTransient reports whether err is one that another process, holding a file
+// open, may soon stop causing. Files that are open can be renamed and removed
+// here, so no error is.
+func isTransient(err error) bool {
+	return false
+}
@@ -40,3 +40,31 @@ func renameFallback(err error, src, dst string) error {
 
 	return renameByCopy(src, dst)
 }
+
+// Errors that Windows returns for files that other processes have open.
+// See https://msdn.microsoft.com/en-us/library/cc231199.aspx
+const (
+	errorAccessDenied     syscall.Errno = 0x5
+	errorSharingViolation syscall.Errno = 0x20
+	errorLockViolation    syscall.Errno = 0x21
+)
+
+// isTransient reports whether err is one that another process, holding a file
+// open, may soon stop causing. Virus scanners and search indexers open the
+// files of trees just written, which cannot be renamed or removed until they
+// are done with them.
+func isTransient(err error) bool {
+	switch terr := err.(type) {
+	case *os.LinkError:
+		err = terr.Err
+	case *os.PathError:
+		err = terr.Err
+	case *os.SyscallError:
+		err = terr.Err
+	}
+	switch err {
+	case errorAccessDenied, errorSharingViolation, errorLockViolation:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// jobs is how many goroutines Walk and ForEach use at once, as set by SetJobs.
+var jobs int32
+
+// SetJobs sets how many goroutines Walk and ForEach use at once. Passes over
+// trees of many files spend most of their time waiting on the filesystem, so
+// the default is several times the number of CPUs; n <= 0 restores it.
+func SetJobs(n int) {
+	atomic.StoreInt32(&jobs, int32(n))
+}
+
+// Jobs returns how many goroutines Walk and ForEach use at once.
+func Jobs() int {
+	if n := atomic.LoadInt32(&jobs); n > 0 {
+		return int(n)
+	}
+	return 4 * runtime.GOMAXPROCS(0)
+}
+
+// ForEach calls fn with each of 0 through n-1, from up to Jobs() goroutines at
+// once, and returns the first error it returns. Once fn has returned an error,
+// it is not called again.
+func ForEach(n int, fn func(i int) error) error {
+	workers := Jobs()
+	if workers > n {
+		workers = n
+	}
+
+	var (
+		next    int32 = -1
+		failed  int32
+		errOnce sync.Once
+		first   error
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= n {
+					return
+				}
+				if err := fn(i); err != nil {
+					errOnce.Do(func() { first = err })
+					atomic.StoreInt32(&failed, 1)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return first
+}
+
+// Walk walks the tree rooted at root, calling fn for each file or directory in
+// it other than root, with the info of the node as os.Lstat returns it. Unlike
+// filepath.Walk, the directories of the tree are read from up to Jobs()
+// goroutines at once, so fn is called concurrently, and in no particular
+// order; WalkOrderLess orders paths as filepath.Walk would visit them. If fn
+// returns filepath.SkipDir for a directory, it is not walked. Symbolic links
+// are not followed.
+//
+// Walk returns the first error fn returns, or that reading a directory does.
+func Walk(root string, fn filepath.WalkFunc) error {
+	w := &walker{fn: fn, sem: make(chan struct{}, Jobs()-1)}
+	w.wg.Add(1)
+	w.walk(root)
+	w.wg.Wait()
+	return w.err
+}
+
+type walker struct {
+	fn  filepath.WalkFunc
+	sem chan struct{} // tokens for walking directories in new goroutines
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+	failed  int32
+}
+
+func (w *walker) fail(err error) {
+	w.errOnce.Do(func() { w.err = err })
+	atomic.StoreInt32(&w.failed, 1)
+}
+
+// walk calls fn for each of the entries of the directory dir, and walks those
+// that are directories, in new goroutines while there are tokens for them,
+// and in this one once there are not.
+func (w *walker) walk(dir string) {
+	defer w.wg.Done()
+	if atomic.LoadInt32(&w.failed) != 0 {
+		return
+	}
+
+	f, err := os.Open(longPath(dir))
+	if err != nil {
+		w.fail(errors.Wrapf(err, "cannot open directory %s", dir))
+		return
+	}
+	fis, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		w.fail(errors.Wrapf(err, "cannot read directory %s", dir))
+		return
+	}
+
+	for _, fi := range fis {
+		path := filepath.Join(dir, fi.Name())
+		err := w.fn(path, fi, nil)
+		if fi.IsDir() && err == filepath.SkipDir {
+			continue
+		}
+		if err != nil {
+			w.fail(err)
+			return
+		}
+		if !fi.IsDir() {
+			continue
+		}
+
+		w.wg.Add(1)
+		select {
+		case w.sem <- struct{}{}:
+			go func() {
+				defer func() { <-w.sem }()
+				w.walk(path)
+			}()
+		default:
+			w.walk(path)
+		}
+	}
+}
+
+// WalkOrderLess reports whether filepath.Walk visits the path a before the
+// path b, when both are relative to the same root: each directory is followed
+// by the whole of its tree, before its next sibling. Walk visits the entries
+// of each directory in lexical order, so paths compare as their elements do,
+// which they do where separators compare less than any other byte.
+func WalkOrderLess(a, b string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := a[i], b[i]
+		switch {
+		case ca == cb:
+			continue
+		case os.IsPathSeparator(ca):
+			return true
+		case os.IsPathSeparator(cb):
+			return false
+		}
+		return ca < cb
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mkCopyTree(t, dir, 20, 5, 16)
+	// A sibling whose name sorts between a directory and its children.
+	if err := ioutil.WriteFile(filepath.Join(dir, "dir0.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		if info.IsDir() && info.Name() == "dir2" {
+			return filepath.SkipDir
+		}
+		rel, _ := filepath.Rel(dir, path)
+		want = append(want, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, jobs := range []int{1, 8} {
+		SetJobs(jobs)
+
+		var mu sync.Mutex
+		var got []string
+		err := Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if info.IsDir() && info.Name() == "dir2" {
+				return filepath.SkipDir
+			}
+			rel, _ := filepath.Rel(dir, path)
+			mu.Lock()
+			got = append(got, rel)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(got, func(i, j int) bool { return WalkOrderLess(got[i], got[j]) })
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("with %d jobs, expected to walk:\n\t%v\ngot:\n\t%v", jobs, want, got)
+		}
+	}
+	SetJobs(0)
+}
+
+func TestForEach(t *testing.T) {
+	var sum int64
+	if err := ForEach(100, func(i int) error {
+		atomic.AddInt64(&sum, int64(i))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 4950 {
+		t.Errorf("expected each of 0 through 99 to be passed once, got a sum of %d", sum)
+	}
+
+	want := errors.New("failed")
+	err := ForEach(100, func(i int) error {
+		if i == 10 {
+			return want
+		}
+		return nil
+	})
+	if err != want {
+		t.Errorf("expected %v, got %v", want, err)
+	}
+}
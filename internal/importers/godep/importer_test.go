@@ -13,6 +13,7 @@ import (
 
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/logging"
 	"github.com/golang/dep/internal/importers/importertest"
 	"github.com/golang/dep/internal/test"
 	"github.com/pkg/errors"
@@ -124,7 +125,7 @@ func TestGodepConfig_Import(t *testing.T) {
 	projectRoot := h.Path(importertest.RootProject)
 	sm, err := gps.NewSourceManager(gps.SourceManagerConfig{
 		Cachedir: h.Path(cacheDir),
-		Logger:   log.New(test.Writer{TB: t}, "", 0),
+		Logger:   logging.New(test.Writer{TB: t}, logging.Options{Level: logging.Debug}),
 	})
 	h.Must(err)
 	defer sm.Release()
@@ -0,0 +1,149 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package licenses detects the licenses of projects from their license files,
+// identifying them by their SPDX license identifiers.
+package licenses
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Identifiers used for projects whose licenses cannot be identified, as in
+// SPDX documents.
+const (
+	// None is the license of projects with no license file.
+	None = "NONE"
+
+	// NoAssertion is the license of license files that are not recognized.
+	NoAssertion = "NOASSERTION"
+)
+
+// fileNamePrefixes are the prefixes of the names of license files, in lower
+// case.
+var fileNamePrefixes = []string{"license", "licence", "copying", "unlicense"}
+
+// A license is recognized by all of its phrases appearing in a license file.
+// Licenses are tried in order, so those whose phrases the texts of others may
+// also contain are listed after them. Texts of the GNU licenses mention one
+// another, so they are recognized by their full titles.
+var known = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license version 3 19 november 2007"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license version 3 29 june 2007"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license version 2 1 february 1999"}},
+	{"LGPL-2.0", []string{"gnu library general public license version 2 june 1991"}},
+	{"GPL-3.0", []string{"gnu general public license version 3 29 june 2007"}},
+	{"GPL-2.0", []string{"gnu general public license version 2 june 1991"}},
+	{"Apache-2.0", []string{"apache license version 2 0"}},
+	{"MPL-2.0", []string{"mozilla public license version 2 0"}},
+	{"EPL-2.0", []string{"eclipse public license v 2 0"}},
+	{"EPL-1.0", []string{"eclipse public license v 1 0"}},
+	{"BSL-1.0", []string{"boost software license version 1 0"}},
+	{"CC0-1.0", []string{"cc0 1 0 universal"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"WTFPL", []string{"do what the fuck you want to public license"}},
+	{"BSD-4-Clause", []string{"redistribution and use in source and binary forms", "all advertising materials mentioning features or use of this software"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "names of its contributors may not be used"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"ISC", []string{"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted"}},
+	{"ISC", []string{"permission to use copy modify and distribute this software for any purpose with or without fee is hereby granted"}},
+	{"MIT", []string{"permission is hereby granted free of charge to any person obtaining a copy", "the above copyright notice and this permission notice shall be included"}},
+	{"Zlib", []string{"provided as is without any express or implied warranty", "altered source versions must be plainly marked"}},
+}
+
+// Classify returns the SPDX identifier of the license whose text is text, or
+// NoAssertion if the license is not recognized.
+func Classify(text []byte) string {
+	norm := " " + normalize(string(text)) + " "
+	for _, l := range known {
+		matched := true
+		for _, phrase := range l.phrases {
+			if !strings.Contains(norm, " "+phrase+" ") {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return l.id
+		}
+	}
+	return NoAssertion
+}
+
+// normalize lowers the case of s, and reduces every run of characters other
+// than letters and digits to a single space, so that licenses are recognized
+// regardless of how they are punctuated and laid out.
+func normalize(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	}), " ")
+}
+
+// sourceFileExts are the extensions of the source files that go build may
+// compile, which are never license files, whatever their names.
+var sourceFileExts = map[string]bool{
+	".go": true, ".c": true, ".cc": true, ".cpp": true, ".cxx": true, ".m": true,
+	".h": true, ".hh": true, ".hpp": true, ".hxx": true, ".s": true, ".swig": true,
+	".swigcxx": true, ".syso": true,
+}
+
+// IsLicenseFile reports whether name is the name of a license file.
+func IsLicenseFile(name string) bool {
+	lower := strings.ToLower(name)
+	if sourceFileExts[filepath.Ext(lower)] {
+		return false
+	}
+	for _, prefix := range fileNamePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect returns the sorted SPDX identifiers of the licenses of the project
+// whose tree is rooted at dir, as read from the license files at its root. It
+// returns None if there are no license files, and NoAssertion for those that
+// are not recognized.
+func Detect(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	for _, fi := range infos {
+		if !fi.Mode().IsRegular() || !IsLicenseFile(fi.Name()) {
+			continue
+		}
+		text, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if id := Classify(text); !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return []string{None}, nil
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Equal reports whether the SPDX identifiers a and b name the same license.
+// Identifiers are compared regardless of case, and those ending in -only are
+// the same as those without, such as GPL-2.0-only and GPL-2.0.
+func Equal(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(strings.ToLower(a), "-only"), strings.TrimSuffix(strings.ToLower(b), "-only"))
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package licenses
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		text, want string
+	}{
+		{`Copyright (c) 2014 The Go Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+[...]
+   * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.`, "BSD-3-Clause"},
+		{`Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.`, "BSD-2-Clause"},
+		{`The MIT License (MIT)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+[...]
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.`, "MIT"},
+		{`
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/`, "Apache-2.0"},
+		{`                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+[...]
+the GNU Library General Public License instead.)  You can apply it to`, "GPL-2.0"},
+		{`                   GNU LESSER GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+[...]
+  This version of the GNU Lesser General Public License incorporates
+the terms and conditions of version 3 of the GNU General Public
+License`, "LGPL-3.0"},
+		{`Mozilla Public License Version 2.0
+==================================`, "MPL-2.0"},
+		{`Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.`, "ISC"},
+		{"All rights reserved. Do not copy.", NoAssertion},
+	}
+
+	for _, c := range cases {
+		if got := Classify([]byte(c.text)); got != c.want {
+			t.Errorf("unexpected license for:\n%s\n\t(GOT): %s\n\t(WNT): %s", c.text, got, c.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "licenses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	got, err := Detect(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{None}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected licenses of a project without license files:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+
+	files := map[string]string{
+		"LICENSE.txt": "Mozilla Public License Version 2.0",
+		"COPYING":     "Proprietary",
+		"license.go":  "// Copyright 2018. Licensed under the Apache License, Version 2.0.",
+		"README.md":   "This is free and unencumbered software released into the public domain.",
+	}
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "licenses"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = Detect(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"MPL-2.0", NoAssertion}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected licenses:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"MIT", "mit", true},
+		{"GPL-2.0", "GPL-2.0-only", true},
+		{"GPL-2.0", "GPL-2.0-or-later", false},
+		{"BSD-2-Clause", "BSD-3-Clause", false},
+	}
+	for _, c := range cases {
+		if got := Equal(c.a, c.b); got != c.want {
+			t.Errorf("Equal(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
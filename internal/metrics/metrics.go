@@ -0,0 +1,186 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics counts and times the work dep does, for those who run it at
+// scale to track. What is recorded can be written out in the Prometheus text
+// format, as the textfile collector of the node exporter reads it, or published
+// through expvar.
+package metrics
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The names of the metrics that dep records.
+const (
+	// SolveSeconds times solving.
+	SolveSeconds = "solve_seconds"
+	// VersionsProbed counts the versions the solver tried to select.
+	VersionsProbed = "solve_versions_probed_total"
+	// SourceCacheHits and SourceCacheMisses count the manifests, locks and
+	// package trees of revisions that were, and were not, found in the
+	// source cache.
+	SourceCacheHits   = "source_cache_hits_total"
+	SourceCacheMisses = "source_cache_misses_total"
+	// BytesCopied counts the bytes of the files copied from one tree to
+	// another, as when writing vendor/.
+	BytesCopied = "fs_copied_bytes_total"
+	// FilesPruned counts the files removed from vendor/ by pruning.
+	FilesPruned = "prune_removed_files_total"
+)
+
+// namespace prefixes the names of the metrics as they are written out.
+const namespace = "dep_"
+
+// A Registry holds the values of a set of metrics. The zero value is not
+// usable; use NewRegistry.
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	timers   map[string]timer
+}
+
+// timer is the number of durations observed of something, and their total.
+type timer struct {
+	count int64
+	total time.Duration
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		counters: make(map[string]int64),
+		timers:   make(map[string]timer),
+	}
+}
+
+// Default is the Registry that dep records its metrics in, and that the
+// functions of this package use.
+var Default = NewRegistry()
+
+// Add adds delta to the counter name in Default.
+func Add(name string, delta int64) { Default.Add(name, delta) }
+
+// Since observes the time elapsed since start as a duration of name in
+// Default.
+func Since(name string, start time.Time) { Default.Observe(name, time.Since(start)) }
+
+// Add adds delta to the counter name.
+func (r *Registry) Add(name string, delta int64) {
+	r.mu.Lock()
+	r.counters[name] += delta
+	r.mu.Unlock()
+}
+
+// Observe records a duration d of name.
+func (r *Registry) Observe(name string, d time.Duration) {
+	r.mu.Lock()
+	t := r.timers[name]
+	t.count++
+	t.total += d
+	r.timers[name] = t
+	r.mu.Unlock()
+}
+
+// Counter returns the value of the counter name.
+func (r *Registry) Counter(name string) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters[name]
+}
+
+// values returns the current values of the metrics in r, keyed by the names
+// they are written out with: the count and total of each duration are written
+// out as those of a Prometheus summary.
+func (r *Registry) values() map[string]float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	vals := make(map[string]float64, len(r.counters)+2*len(r.timers))
+	for name, n := range r.counters {
+		vals[namespace+name] = float64(n)
+	}
+	for name, t := range r.timers {
+		vals[namespace+name+"_count"] = float64(t.count)
+		vals[namespace+name+"_sum"] = t.total.Seconds()
+	}
+	return vals
+}
+
+// WriteText writes the metrics in r to w in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	types := make(map[string]string, len(r.counters)+len(r.timers))
+	for name := range r.counters {
+		types[namespace+name] = "counter"
+	}
+	for name := range r.timers {
+		types[namespace+name] = "summary"
+	}
+	r.mu.Unlock()
+	vals := r.values()
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, types[name])
+		if types[name] == "summary" {
+			fmt.Fprintf(&buf, "%s_sum %g\n", name, vals[name+"_sum"])
+			fmt.Fprintf(&buf, "%s_count %g\n", name, vals[name+"_count"])
+		} else {
+			fmt.Fprintf(&buf, "%s %g\n", name, vals[name])
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteTextfile writes the metrics in r to the file at path in the Prometheus
+// text format. The file is replaced at once, rather than rewritten in place,
+// so that a collector never reads it half-written.
+func (r *Registry) WriteTextfile(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := r.WriteText(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// TempFile creates files that only their owner may read.
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// PublishExpvar publishes the metrics in r through expvar as a map named
+// name, unless something is already published under name.
+func (r *Registry) PublishExpvar(name string) {
+	if expvar.Get(name) != nil {
+		return
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return r.values()
+	}))
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"expvar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Add(VersionsProbed, 3)
+	r.Add(VersionsProbed, 2)
+	r.Add(BytesCopied, 1<<20)
+	r.Observe(SolveSeconds, 1500*time.Millisecond)
+	r.Observe(SolveSeconds, 500*time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE dep_fs_copied_bytes_total counter
+dep_fs_copied_bytes_total 1.048576e+06
+# TYPE dep_solve_seconds summary
+dep_solve_seconds_sum 2
+dep_solve_seconds_count 2
+# TYPE dep_solve_versions_probed_total counter
+dep_solve_versions_probed_total 5
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected metrics:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+	if n := r.Counter(VersionsProbed); n != 5 {
+		t.Errorf("expected %s to be 5, got %d", VersionsProbed, n)
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := NewRegistry()
+	r.Add(FilesPruned, 7)
+	path := filepath.Join(dir, "dep.prom")
+	for i := 0; i < 2; i++ {
+		if err := r.WriteTextfile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "dep_prune_removed_files_total 7\n") {
+		t.Errorf("expected the pruned files to be counted, got:\n%s", b)
+	}
+	// Only the file itself is left behind.
+	if fis, err := ioutil.ReadDir(dir); err != nil || len(fis) != 1 {
+		t.Errorf("expected only the metrics file in %s, got %d files, %v", dir, len(fis), err)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	r := NewRegistry()
+	r.PublishExpvar("dep_test")
+	// Publishing again is harmless.
+	r.PublishExpvar("dep_test")
+	r.Add(SourceCacheHits, 4)
+
+	v := expvar.Get("dep_test")
+	if v == nil {
+		t.Fatal("expected the metrics to be published")
+	}
+	if got := v.String(); !strings.Contains(got, `"dep_source_cache_hits_total":4`) {
+		t.Errorf("expected the published metrics to be current, got %s", got)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package osv looks up the known vulnerabilities of the versions of projects
+// in the OSV database, https://osv.dev.
+package osv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// DefaultURL is the URL of the OSV database's API.
+const DefaultURL = "https://api.osv.dev"
+
+// Client looks up vulnerabilities in an OSV database.
+type Client struct {
+	// URL is the URL of the database's API. If empty, DefaultURL is used.
+	URL string
+
+	// HTTPClient makes the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+type query struct {
+	Commit  string  `json:"commit,omitempty"`
+	Version string  `json:"version,omitempty"`
+	Package *pkgRef `json:"package,omitempty"`
+}
+
+type pkgRef struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+type batchResponse struct {
+	Results []struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+	} `json:"results"`
+}
+
+type vuln struct {
+	ID      string   `json:"id"`
+	Aliases []string `json:"aliases"`
+}
+
+// Lookup returns the identifiers of the known vulnerabilities of the versions
+// that lps are locked to, keyed by the roots of the projects that have any.
+// CVE and GHSA identifiers are preferred; vulnerabilities with neither are
+// identified by their OSV identifiers.
+//
+// Projects locked to semantic versions are looked up as Go modules, with their
+// roots as module paths; others are looked up by the revision they are locked
+// to, which matches vulnerabilities recorded against the commits of their
+// repositories.
+func (c *Client) Lookup(ctx context.Context, lps []gps.LockedProject) (map[gps.ProjectRoot][]string, error) {
+	var queries []query
+	var roots []gps.ProjectRoot
+	for _, lp := range lps {
+		pr := lp.Ident().ProjectRoot
+		for _, q := range queriesFor(lp) {
+			queries = append(queries, q)
+			roots = append(roots, pr)
+		}
+	}
+	if len(queries) == 0 {
+		return nil, nil
+	}
+
+	var resp batchResponse
+	if err := c.do(ctx, "POST", "/v1/querybatch", map[string]interface{}{"queries": queries}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(queries) {
+		return nil, errors.Errorf("the OSV database answered %d of %d queries", len(resp.Results), len(queries))
+	}
+
+	ids := make(map[gps.ProjectRoot]map[string]bool)
+	known := make(map[string][]string)
+	for i, res := range resp.Results {
+		for _, v := range res.Vulns {
+			names, has := known[v.ID]
+			if !has {
+				var err error
+				if names, err = c.names(ctx, v.ID); err != nil {
+					return nil, err
+				}
+				known[v.ID] = names
+			}
+			if ids[roots[i]] == nil {
+				ids[roots[i]] = make(map[string]bool)
+			}
+			for _, name := range names {
+				ids[roots[i]][name] = true
+			}
+		}
+	}
+
+	vulns := make(map[gps.ProjectRoot][]string, len(ids))
+	for pr, set := range ids {
+		for id := range set {
+			vulns[pr] = append(vulns[pr], id)
+		}
+		sort.Strings(vulns[pr])
+	}
+	return vulns, nil
+}
+
+// queriesFor returns the queries for the version lp is locked to.
+func queriesFor(lp gps.LockedProject) []query {
+	pr := string(lp.Ident().ProjectRoot)
+	v := lp.Version()
+
+	var r gps.Revision
+	switch tv := v.(type) {
+	case gps.PairedVersion:
+		r = tv.Revision()
+		v = tv.Unpair()
+	case gps.Revision:
+		r = tv
+	}
+
+	if uv, ok := v.(gps.UnpairedVersion); ok && uv.Type() == gps.IsSemver {
+		ver := strings.TrimPrefix(uv.String(), "v")
+		qs := []query{{Version: ver, Package: &pkgRef{Name: pr, Ecosystem: "Go"}}}
+		// Modules of major versions beyond 1 have the major version as
+		// their path's last element.
+		if major := strings.SplitN(ver, ".", 2)[0]; major != "0" && major != "1" {
+			qs = append(qs, query{Version: ver, Package: &pkgRef{Name: pr + "/v" + major, Ecosystem: "Go"}})
+		}
+		return qs
+	}
+	if r != "" {
+		return []query{{Commit: string(r)}}
+	}
+	return nil
+}
+
+// names returns the CVE and GHSA identifiers of the vulnerability id, or id
+// itself if it has neither.
+func (c *Client) names(ctx context.Context, id string) ([]string, error) {
+	var v vuln
+	if err := c.do(ctx, "GET", "/v1/vulns/"+url.PathEscape(id), nil, &v); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range append([]string{id}, v.Aliases...) {
+		if strings.HasPrefix(name, "CVE-") || strings.HasPrefix(name, "GHSA-") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{id}
+	}
+	return names, nil
+}
+
+// do makes a request of the database's API, sending in, if it is not nil, and
+// decoding the answer into out.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	base := c.URL
+	if base == "" {
+		base = DefaultURL
+	}
+	u := strings.TrimSuffix(base, "/") + path
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "unable to query the OSV database")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("unable to query the OSV database: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrapf(err, "unable to decode the answer of the OSV database to %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package osv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+// testDB serves an OSV database knowing of GO-2021-0001, an alias of
+// CVE-2021-1234 and GHSA-aaaa-bbbb-cccc affecting github.com/foo/bar 1.0.0 and
+// github.com/foo/baz/v2 2.1.0, and of OSV-2020-99 affecting the commit
+// deadbeef.
+func testDB(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/querybatch":
+			var req struct{ Queries []query }
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			var results []string
+			for _, q := range req.Queries {
+				var ids []string
+				switch {
+				case q.Commit == "deadbeef":
+					ids = []string{`{"id":"OSV-2020-99"}`}
+				case q.Package != nil && q.Package.Ecosystem == "Go" && q.Package.Name+"@"+q.Version == "github.com/foo/bar@1.0.0",
+					q.Package != nil && q.Package.Ecosystem == "Go" && q.Package.Name+"@"+q.Version == "github.com/foo/baz/v2@2.1.0":
+					ids = []string{`{"id":"GO-2021-0001"}`}
+				}
+				results = append(results, `{"vulns":[`+strings.Join(ids, ",")+`]}`)
+			}
+			w.Write([]byte(`{"results":[` + strings.Join(results, ",") + `]}`))
+		case r.URL.Path == "/v1/vulns/GO-2021-0001":
+			w.Write([]byte(`{"id":"GO-2021-0001","aliases":["CVE-2021-1234","GHSA-aaaa-bbbb-cccc"]}`))
+		case r.URL.Path == "/v1/vulns/OSV-2020-99":
+			w.Write([]byte(`{"id":"OSV-2020-99"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestLookup(t *testing.T) {
+	srv := testDB(t)
+	defer srv.Close()
+
+	lp := func(root string, v gps.Version) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, v, []string{"."})
+	}
+	lps := []gps.LockedProject{
+		lp("github.com/foo/bar", gps.NewVersion("v1.0.0").Pair("abc123")),
+		lp("github.com/foo/baz", gps.NewVersion("v2.1.0").Pair("abc456")),
+		lp("github.com/foo/qux", gps.NewBranch("master").Pair("deadbeef")),
+		lp("github.com/foo/safe", gps.NewVersion("v1.1.0").Pair("abc789")),
+	}
+
+	c := Client{URL: srv.URL}
+	got, err := c.Lookup(context.Background(), lps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[gps.ProjectRoot][]string{
+		"github.com/foo/bar": {"CVE-2021-1234", "GHSA-aaaa-bbbb-cccc"},
+		"github.com/foo/baz": {"CVE-2021-1234", "GHSA-aaaa-bbbb-cccc"},
+		"github.com/foo/qux": {"OSV-2020-99"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected vulnerabilities:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+
+	c.URL = srv.URL + "/nowhere"
+	if _, err := c.Lookup(context.Background(), lps); err == nil {
+		t.Error("expected an error looking up vulnerabilities in a missing database")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/dep/internal/licenses"
+)
+
+// CycloneDXVersion is the version of the CycloneDX specification that
+// WriteCycloneDX follows.
+const CycloneDXVersion = "1.4"
+
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []cdxTool    `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type cdxComponent struct {
+	Type               string           `json:"type"`
+	BOMRef             string           `json:"bom-ref"`
+	Name               string           `json:"name"`
+	Version            string           `json:"version,omitempty"`
+	PURL               string           `json:"purl,omitempty"`
+	Hashes             []cdxHash        `json:"hashes,omitempty"`
+	Licenses           []cdxLicense     `json:"licenses,omitempty"`
+	ExternalReferences []cdxExternalRef `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty    `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	License cdxLicenseID `json:"license"`
+}
+
+type cdxLicenseID struct {
+	ID string `json:"id"`
+}
+
+type cdxExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// WriteCycloneDX writes doc to w as a CycloneDX bill of materials, in its
+// JSON form. doc.Root is the BOM's subject, and doc.Packages its components.
+// Every package is listed in the dependency graph, along with the packages it
+// depends on.
+func WriteCycloneDX(w io.Writer, doc Document) error {
+	refs := make(map[string]string, len(doc.Packages))
+	for _, p := range doc.Packages {
+		refs[p.Name] = p.PackageURL()
+	}
+
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  CycloneDXVersion,
+		SerialNumber: "urn:uuid:" + cdxUUID(doc.ID),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: doc.Created.UTC().Format(time.RFC3339),
+			Tools:     []cdxTool{{Name: doc.Tool, Version: doc.ToolVersion}},
+			Component: newCDXComponent(doc.Root, "application"),
+		},
+		Components: []cdxComponent{},
+	}
+
+	dependsOn := func(p Package) {
+		d := cdxDependency{Ref: p.PackageURL(), DependsOn: []string{}}
+		for _, dep := range p.DependsOn {
+			if ref, has := refs[dep]; has {
+				d.DependsOn = append(d.DependsOn, ref)
+			}
+		}
+		bom.Dependencies = append(bom.Dependencies, d)
+	}
+	dependsOn(doc.Root)
+	for _, p := range doc.Packages {
+		bom.Components = append(bom.Components, newCDXComponent(p, "library"))
+		dependsOn(p)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bom)
+}
+
+func newCDXComponent(p Package, typ string) cdxComponent {
+	c := cdxComponent{
+		Type:    typ,
+		BOMRef:  p.PackageURL(),
+		Name:    p.Name,
+		Version: p.Version,
+		PURL:    p.PackageURL(),
+	}
+	if c.Version == "" {
+		c.Version = p.Revision
+	}
+	if len(p.SHA256) > 0 {
+		c.Hashes = []cdxHash{{"SHA-256", hex.EncodeToString(p.SHA256)}}
+	}
+	// CycloneDX has no identifiers for missing or unrecognized licenses; such
+	// projects are listed without licenses.
+	for _, id := range p.Licenses {
+		if id != licenses.None && id != licenses.NoAssertion {
+			c.Licenses = append(c.Licenses, cdxLicense{cdxLicenseID{id}})
+		}
+	}
+	if p.DownloadLocation != "" {
+		c.ExternalReferences = []cdxExternalRef{{"vcs", p.DownloadLocation}}
+	}
+	if p.Revision != "" {
+		c.Properties = []cdxProperty{{"dep:revision", p.Revision}}
+	}
+	return c
+}
+
+// cdxUUID derives a UUID from id, as a name-based UUID would be, so that a BOM
+// of the same contents always has the same serial number.
+func cdxUUID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
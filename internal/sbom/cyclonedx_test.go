@@ -0,0 +1,102 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestWriteCycloneDX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCycloneDX(&buf, testDocument()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got cdxBOM
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("could not unmarshal the CycloneDX BOM: %v\n%s", err, buf.String())
+	}
+
+	if got.BOMFormat != "CycloneDX" || got.SpecVersion != CycloneDXVersion || got.Version != 1 {
+		t.Errorf("unexpected BOM header: %+v", got)
+	}
+	if !regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(got.SerialNumber) {
+		t.Errorf("serial number %q is not a name-based UUID URN", got.SerialNumber)
+	}
+	var again bytes.Buffer
+	if err := WriteCycloneDX(&again, testDocument()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("BOMs of the same document differ")
+	}
+
+	wantMeta := cdxMetadata{
+		Timestamp: "2018-06-01T12:00:00Z",
+		Tools:     []cdxTool{{Name: "dep", Version: "devel"}},
+		Component: cdxComponent{
+			Type:     "application",
+			BOMRef:   "pkg:golang/github.com/golang/notexist",
+			Name:     "github.com/golang/notexist",
+			PURL:     "pkg:golang/github.com/golang/notexist",
+			Licenses: []cdxLicense{{cdxLicenseID{"BSD-3-Clause"}}},
+		},
+	}
+	if !reflect.DeepEqual(got.Metadata, wantMeta) {
+		t.Errorf("unexpected metadata:\n\t(GOT) %+v\n\t(WNT) %+v", got.Metadata, wantMeta)
+	}
+
+	bar := "pkg:golang/github.com/foo/bar@v1.2.0"
+	barBaz := "pkg:golang/github.com/foo/bar-baz@a0196baa11ea047dd65037287451d36b861b00ea"
+	barBaz2 := "pkg:golang/github.com/foo/bar_baz@v0.1.0"
+	wantComponents := []cdxComponent{
+		{
+			Type:               "library",
+			BOMRef:             bar,
+			Name:               "github.com/foo/bar",
+			Version:            "v1.2.0",
+			PURL:               bar,
+			Hashes:             []cdxHash{{"SHA-256", "deadbeef"}},
+			Licenses:           []cdxLicense{{cdxLicenseID{"MIT"}}},
+			ExternalReferences: []cdxExternalRef{{"vcs", "https://github.com/foo/bar"}},
+			Properties:         []cdxProperty{{"dep:revision", "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"}},
+		},
+		{
+			Type:       "library",
+			BOMRef:     barBaz,
+			Name:       "github.com/foo/bar-baz",
+			Version:    "a0196baa11ea047dd65037287451d36b861b00ea",
+			PURL:       barBaz,
+			Licenses:   []cdxLicense{{cdxLicenseID{"Apache-2.0"}}},
+			Properties: []cdxProperty{{"dep:revision", "a0196baa11ea047dd65037287451d36b861b00ea"}},
+		},
+		{
+			Type:       "library",
+			BOMRef:     barBaz2,
+			Name:       "github.com/foo/bar_baz",
+			Version:    "v0.1.0",
+			PURL:       barBaz2,
+			Licenses:   []cdxLicense{{cdxLicenseID{"BSD-2-Clause"}}, {cdxLicenseID{"ISC"}}},
+			Properties: []cdxProperty{{"dep:revision", "5c607206be5decd28e6263ffffdcee067266015e"}},
+		},
+	}
+	if !reflect.DeepEqual(got.Components, wantComponents) {
+		t.Errorf("unexpected components:\n\t(GOT) %+v\n\t(WNT) %+v", got.Components, wantComponents)
+	}
+
+	wantDependencies := []cdxDependency{
+		{"pkg:golang/github.com/golang/notexist", []string{bar, barBaz2}},
+		{bar, []string{barBaz}},
+		{barBaz, []string{}},
+		{barBaz2, []string{}},
+	}
+	if !reflect.DeepEqual(got.Dependencies, wantDependencies) {
+		t.Errorf("unexpected dependencies:\n\t(GOT) %+v\n\t(WNT) %+v", got.Dependencies, wantDependencies)
+	}
+}
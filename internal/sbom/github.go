@@ -0,0 +1,136 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GitHubJob identifies the commit a dependency snapshot was taken of, and the
+// job that took it, as GitHub's dependency submission API requires.
+type GitHubJob struct {
+	// SHA is the full hash of the commit the snapshot was taken of.
+	SHA string
+
+	// Ref is the ref the commit is on, such as refs/heads/master.
+	Ref string
+
+	// Correlator distinguishes the snapshots of different jobs, and different
+	// projects, of a repository: a snapshot replaces the last one submitted
+	// with the same correlator.
+	Correlator string
+
+	// ID identifies the run of the job, such as a CI build number.
+	ID string
+
+	// Manifest is the path of the project's Gopkg.lock, relative to the root
+	// of its repository.
+	Manifest string
+}
+
+type ghSnapshot struct {
+	Version   int                   `json:"version"`
+	SHA       string                `json:"sha"`
+	Ref       string                `json:"ref"`
+	Job       ghJob                 `json:"job"`
+	Detector  ghDetector            `json:"detector"`
+	Scanned   string                `json:"scanned"`
+	Manifests map[string]ghManifest `json:"manifests"`
+}
+
+type ghJob struct {
+	Correlator string `json:"correlator"`
+	ID         string `json:"id"`
+}
+
+type ghDetector struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+type ghManifest struct {
+	Name     string               `json:"name"`
+	File     ghFile               `json:"file"`
+	Resolved map[string]ghPackage `json:"resolved"`
+}
+
+type ghFile struct {
+	SourceLocation string `json:"source_location"`
+}
+
+type ghPackage struct {
+	PackageURL   string   `json:"package_url"`
+	Relationship string   `json:"relationship"`
+	Scope        string   `json:"scope"`
+	Dependencies []string `json:"dependencies"`
+}
+
+// WriteGitHubSnapshot writes doc to w as a snapshot for GitHub's dependency
+// submission API, taken of the commit, by the job, that job describes. The
+// packages of doc are the resolved packages of a single manifest, job.Manifest;
+// those doc.Root depends on are its direct dependencies, and the others its
+// indirect ones.
+func WriteGitHubSnapshot(w io.Writer, doc Document, job GitHubJob) error {
+	if job.SHA == "" || job.Ref == "" {
+		return errors.New("a dependency snapshot needs the commit and the ref it was taken of")
+	}
+
+	urls := make(map[string]string, len(doc.Packages))
+	for _, p := range doc.Packages {
+		urls[p.Name] = p.PackageURL()
+	}
+	direct := make(map[string]bool, len(doc.Root.DependsOn))
+	for _, name := range doc.Root.DependsOn {
+		direct[name] = true
+	}
+
+	m := ghManifest{
+		Name:     job.Manifest,
+		File:     ghFile{job.Manifest},
+		Resolved: make(map[string]ghPackage, len(doc.Packages)),
+	}
+	for _, p := range doc.Packages {
+		gp := ghPackage{
+			PackageURL:   p.PackageURL(),
+			Relationship: "indirect",
+			// dep does not tell the packages only tests import from the
+			// others.
+			Scope:        "runtime",
+			Dependencies: []string{},
+		}
+		if direct[p.Name] {
+			gp.Relationship = "direct"
+		}
+		for _, dep := range p.DependsOn {
+			if u, has := urls[dep]; has {
+				gp.Dependencies = append(gp.Dependencies, u)
+			}
+		}
+		m.Resolved[p.Name] = gp
+	}
+
+	snap := ghSnapshot{
+		Version: 0,
+		SHA:     job.SHA,
+		Ref:     job.Ref,
+		Job:     ghJob{Correlator: job.Correlator, ID: job.ID},
+		Detector: ghDetector{
+			Name:    doc.Tool,
+			Version: doc.ToolVersion,
+			URL:     "https://github.com/golang/dep",
+		},
+		Scanned:   doc.Created.UTC().Format(time.RFC3339),
+		Manifests: map[string]ghManifest{job.Manifest: m},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
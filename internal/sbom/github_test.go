@@ -0,0 +1,61 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWriteGitHubSnapshot(t *testing.T) {
+	job := GitHubJob{
+		SHA:        "ce587453ced02b1526dfb4cb910479d431683101",
+		Ref:        "refs/heads/master",
+		Correlator: "build_deps_Gopkg.lock",
+		ID:         "4021",
+		Manifest:   "Gopkg.lock",
+	}
+	var buf bytes.Buffer
+	if err := WriteGitHubSnapshot(&buf, testDocument(), job); err != nil {
+		t.Fatal(err)
+	}
+
+	var got ghSnapshot
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("could not unmarshal the snapshot: %v\n%s", err, buf.String())
+	}
+
+	bar := "pkg:golang/github.com/foo/bar@v1.2.0"
+	barBaz := "pkg:golang/github.com/foo/bar-baz@a0196baa11ea047dd65037287451d36b861b00ea"
+	barBaz2 := "pkg:golang/github.com/foo/bar_baz@v0.1.0"
+	want := ghSnapshot{
+		Version:  0,
+		SHA:      job.SHA,
+		Ref:      job.Ref,
+		Job:      ghJob{"build_deps_Gopkg.lock", "4021"},
+		Detector: ghDetector{"dep", "devel", "https://github.com/golang/dep"},
+		Scanned:  "2018-06-01T12:00:00Z",
+		Manifests: map[string]ghManifest{
+			"Gopkg.lock": {
+				Name: "Gopkg.lock",
+				File: ghFile{"Gopkg.lock"},
+				Resolved: map[string]ghPackage{
+					"github.com/foo/bar":     {bar, "direct", "runtime", []string{barBaz}},
+					"github.com/foo/bar-baz": {barBaz, "indirect", "runtime", []string{}},
+					"github.com/foo/bar_baz": {barBaz2, "direct", "runtime", []string{}},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected snapshot:\n\t(GOT) %+v\n\t(WNT) %+v", got, want)
+	}
+
+	if err := WriteGitHubSnapshot(&buf, testDocument(), GitHubJob{Manifest: "Gopkg.lock"}); err == nil {
+		t.Error("expected an error writing a snapshot of no commit")
+	}
+}
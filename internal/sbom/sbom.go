@@ -0,0 +1,75 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sbom writes software bills of materials, which list the projects a
+// project is built from, in standard formats.
+package sbom
+
+import (
+	"time"
+)
+
+// Document is a bill of materials, in a form independent of the formats it
+// is written in.
+type Document struct {
+	// Root is the project the bill of materials is for.
+	Root Package
+
+	// Packages are the projects Root depends on, directly or indirectly. The
+	// dependencies between them, and of Root on them, are recorded in their
+	// DependsOn.
+	Packages []Package
+
+	// Created is the time the bill of materials was made.
+	Created time.Time
+
+	// Tool and ToolVersion name the tool that made the bill of materials, and
+	// its version.
+	Tool, ToolVersion string
+
+	// ID is a string unique to the contents of the bill of materials, such as
+	// a hash of its lock, from which formats that need them derive its
+	// identifiers.
+	ID string
+}
+
+// Package is a project in a bill of materials.
+type Package struct {
+	// Name is the project's root import path.
+	Name string
+
+	// Version is the version the project is locked to, which is empty if it
+	// is locked to a bare revision.
+	Version string
+
+	// Revision is the revision the project is locked to.
+	Revision string
+
+	// DownloadLocation is the URL of the project's source, which is empty if
+	// it is not known.
+	DownloadLocation string
+
+	// Licenses are the SPDX identifiers of the project's licenses.
+	Licenses []string
+
+	// SHA256 is the SHA-256 digest of the project's vendored contents, which
+	// is empty if it is not known.
+	SHA256 []byte
+
+	// DependsOn are the names of the projects in the bill of materials whose
+	// packages the project's packages import.
+	DependsOn []string
+}
+
+// PackageURL returns the package URL of p, which identifies it across tools.
+func (p Package) PackageURL() string {
+	v := p.Version
+	if v == "" {
+		v = p.Revision
+	}
+	if v == "" {
+		return "pkg:golang/" + p.Name
+	}
+	return "pkg:golang/" + p.Name + "@" + v
+}
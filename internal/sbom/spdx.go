@@ -0,0 +1,181 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/internal/licenses"
+)
+
+// SPDXVersion is the version of the SPDX specification that WriteSPDX follows.
+const SPDXVersion = "SPDX-2.3"
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// WriteSPDX writes doc to w as an SPDX document, in its JSON form. The
+// document describes doc.Root, and records the dependencies between packages
+// as DEPENDS_ON relationships.
+func WriteSPDX(w io.Writer, doc Document) error {
+	ids := make(spdxIDs)
+	rootID := ids.id(doc.Root.Name)
+	pkgIDs := make(map[string]string, len(doc.Packages))
+	for _, p := range doc.Packages {
+		pkgIDs[p.Name] = ids.id(p.Name)
+	}
+
+	sd := spdxDocument{
+		SPDXVersion:       SPDXVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              doc.Root.Name,
+		DocumentNamespace: "https://spdx.org/spdxdocs/" + spdxSanitize(doc.Root.Name) + "-" + doc.ID,
+		CreationInfo: spdxCreationInfo{
+			Created:  doc.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + doc.Tool + "-" + doc.ToolVersion},
+		},
+		Packages: []spdxPackage{newSPDXPackage(doc.Root, rootID)},
+		Relationships: []spdxRelationship{
+			{"SPDXRef-DOCUMENT", "DESCRIBES", rootID},
+		},
+	}
+	dependsOn := func(id string, p Package) {
+		for _, dep := range p.DependsOn {
+			if depID, has := pkgIDs[dep]; has {
+				sd.Relationships = append(sd.Relationships, spdxRelationship{id, "DEPENDS_ON", depID})
+			}
+		}
+	}
+	dependsOn(rootID, doc.Root)
+	for _, p := range doc.Packages {
+		sd.Packages = append(sd.Packages, newSPDXPackage(p, pkgIDs[p.Name]))
+		dependsOn(pkgIDs[p.Name], p)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sd)
+}
+
+func newSPDXPackage(p Package, id string) spdxPackage {
+	sp := spdxPackage{
+		Name:             p.Name,
+		SPDXID:           id,
+		VersionInfo:      p.Version,
+		DownloadLocation: p.DownloadLocation,
+		LicenseConcluded: licenses.NoAssertion,
+		LicenseDeclared:  spdxLicenseExpression(p.Licenses),
+		CopyrightText:    licenses.NoAssertion,
+	}
+	if sp.VersionInfo == "" {
+		sp.VersionInfo = p.Revision
+	}
+	if sp.DownloadLocation == "" {
+		sp.DownloadLocation = licenses.NoAssertion
+	}
+	if p.Revision != "" {
+		sp.SourceInfo = "revision " + p.Revision
+	}
+	if len(p.SHA256) > 0 {
+		sp.Checksums = []spdxChecksum{{"SHA256", hex.EncodeToString(p.SHA256)}}
+	}
+	if p.Name != "" {
+		sp.ExternalRefs = []spdxExternalRef{{"PACKAGE-MANAGER", "purl", p.PackageURL()}}
+	}
+	return sp
+}
+
+// spdxLicenseExpression returns the SPDX license expression for a project
+// with all of ids as its licenses. Unidentified licenses make the whole
+// expression unknown, as they cannot be combined with others.
+func spdxLicenseExpression(ids []string) string {
+	if len(ids) == 0 {
+		return licenses.NoAssertion
+	}
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	for _, id := range ids {
+		if id == licenses.None || id == licenses.NoAssertion {
+			return licenses.NoAssertion
+		}
+	}
+	return strings.Join(ids, " AND ")
+}
+
+// spdxIDs hands out the SPDX identifiers of packages, keeping them unique.
+type spdxIDs map[string]bool
+
+func (ids spdxIDs) id(name string) string {
+	base := "SPDXRef-Package-" + spdxSanitize(name)
+	id := base
+	for i := 2; ids[id]; i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+	ids[id] = true
+	return id
+}
+
+// spdxSanitize replaces the characters of s that SPDX identifiers may not
+// contain with "-".
+func spdxSanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '-'
+	}, s)
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testDocument() Document {
+	return Document{
+		Root: Package{
+			Name:      "github.com/golang/notexist",
+			Licenses:  []string{"BSD-3-Clause"},
+			DependsOn: []string{"github.com/foo/bar", "github.com/foo/bar_baz"},
+		},
+		Packages: []Package{
+			{
+				Name:             "github.com/foo/bar",
+				Version:          "v1.2.0",
+				Revision:         "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0",
+				DownloadLocation: "https://github.com/foo/bar",
+				Licenses:         []string{"MIT"},
+				SHA256:           []byte{0xde, 0xad, 0xbe, 0xef},
+				DependsOn:        []string{"github.com/foo/bar-baz"},
+			},
+			{
+				Name:     "github.com/foo/bar-baz",
+				Revision: "a0196baa11ea047dd65037287451d36b861b00ea",
+				Licenses: []string{"Apache-2.0", "NOASSERTION"},
+			},
+			{
+				Name:     "github.com/foo/bar_baz",
+				Version:  "v0.1.0",
+				Revision: "5c607206be5decd28e6263ffffdcee067266015e",
+				Licenses: []string{"BSD-2-Clause", "ISC"},
+			},
+		},
+		Created:     time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC),
+		Tool:        "dep",
+		ToolVersion: "devel",
+		ID:          "0123abcd",
+	}
+}
+
+func TestWriteSPDX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSPDX(&buf, testDocument()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got spdxDocument
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("could not unmarshal the SPDX document: %v\n%s", err, buf.String())
+	}
+
+	if got.SPDXVersion != SPDXVersion || got.SPDXID != "SPDXRef-DOCUMENT" || got.DataLicense != "CC0-1.0" {
+		t.Errorf("unexpected document header: %+v", got)
+	}
+	if want := "https://spdx.org/spdxdocs/github.com-golang-notexist-0123abcd"; got.DocumentNamespace != want {
+		t.Errorf("unexpected document namespace:\n\t(GOT) %s\n\t(WNT) %s", got.DocumentNamespace, want)
+	}
+	wantInfo := spdxCreationInfo{Created: "2018-06-01T12:00:00Z", Creators: []string{"Tool: dep-devel"}}
+	if !reflect.DeepEqual(got.CreationInfo, wantInfo) {
+		t.Errorf("unexpected creation info:\n\t(GOT) %+v\n\t(WNT) %+v", got.CreationInfo, wantInfo)
+	}
+
+	wantPackages := []spdxPackage{
+		{
+			Name:             "github.com/golang/notexist",
+			SPDXID:           "SPDXRef-Package-github.com-golang-notexist",
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "BSD-3-Clause",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs:     []spdxExternalRef{{"PACKAGE-MANAGER", "purl", "pkg:golang/github.com/golang/notexist"}},
+		},
+		{
+			Name:             "github.com/foo/bar",
+			SPDXID:           "SPDXRef-Package-github.com-foo-bar",
+			VersionInfo:      "v1.2.0",
+			DownloadLocation: "https://github.com/foo/bar",
+			SourceInfo:       "revision 278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "MIT",
+			CopyrightText:    "NOASSERTION",
+			Checksums:        []spdxChecksum{{"SHA256", "deadbeef"}},
+			ExternalRefs:     []spdxExternalRef{{"PACKAGE-MANAGER", "purl", "pkg:golang/github.com/foo/bar@v1.2.0"}},
+		},
+		{
+			Name:             "github.com/foo/bar-baz",
+			SPDXID:           "SPDXRef-Package-github.com-foo-bar-baz",
+			VersionInfo:      "a0196baa11ea047dd65037287451d36b861b00ea",
+			DownloadLocation: "NOASSERTION",
+			SourceInfo:       "revision a0196baa11ea047dd65037287451d36b861b00ea",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs:     []spdxExternalRef{{"PACKAGE-MANAGER", "purl", "pkg:golang/github.com/foo/bar-baz@a0196baa11ea047dd65037287451d36b861b00ea"}},
+		},
+		{
+			Name:             "github.com/foo/bar_baz",
+			SPDXID:           "SPDXRef-Package-github.com-foo-bar-baz-2",
+			VersionInfo:      "v0.1.0",
+			DownloadLocation: "NOASSERTION",
+			SourceInfo:       "revision 5c607206be5decd28e6263ffffdcee067266015e",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "BSD-2-Clause AND ISC",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs:     []spdxExternalRef{{"PACKAGE-MANAGER", "purl", "pkg:golang/github.com/foo/bar_baz@v0.1.0"}},
+		},
+	}
+	if !reflect.DeepEqual(got.Packages, wantPackages) {
+		t.Errorf("unexpected packages:\n\t(GOT) %+v\n\t(WNT) %+v", got.Packages, wantPackages)
+	}
+
+	root := "SPDXRef-Package-github.com-golang-notexist"
+	wantRelationships := []spdxRelationship{
+		{"SPDXRef-DOCUMENT", "DESCRIBES", root},
+		{root, "DEPENDS_ON", "SPDXRef-Package-github.com-foo-bar"},
+		{root, "DEPENDS_ON", "SPDXRef-Package-github.com-foo-bar-baz-2"},
+		{"SPDXRef-Package-github.com-foo-bar", "DEPENDS_ON", "SPDXRef-Package-github.com-foo-bar-baz"},
+	}
+	if !reflect.DeepEqual(got.Relationships, wantRelationships) {
+		t.Errorf("unexpected relationships:\n\t(GOT) %+v\n\t(WNT) %+v", got.Relationships, wantRelationships)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/events"
+	"github.com/golang/dep/internal/licenses"
+	"github.com/pkg/errors"
+)
+
+// LicensePolicy holds the manifest's [license-policy] table, which restricts
+// the licenses, named by their SPDX identifiers, that dependencies may be
+// distributed under.
+type LicensePolicy struct {
+	// Allow lists the only licenses dependencies may have. If it is empty,
+	// any license not in Deny is allowed.
+	Allow []string
+
+	// Deny lists the licenses dependencies may not have.
+	Deny []string
+
+	// Exceptions lists, for some projects, further licenses they may have,
+	// whether or not those are in Allow or Deny.
+	Exceptions map[gps.ProjectRoot][]string
+
+	// Warn is true if dep ensure only warns about dependencies that violate
+	// the policy, rather than failing.
+	Warn bool
+}
+
+// IsEmpty reports whether the policy restricts no licenses.
+func (lp LicensePolicy) IsEmpty() bool {
+	return len(lp.Allow) == 0 && len(lp.Deny) == 0
+}
+
+// Disallowed returns those of ids, the licenses detected in the project pr,
+// that the policy does not allow it to have.
+func (lp LicensePolicy) Disallowed(pr gps.ProjectRoot, ids []string) []string {
+	if lp.IsEmpty() {
+		return nil
+	}
+
+	contains := func(list []string, id string) bool {
+		for _, l := range list {
+			if licenses.Equal(l, id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var bad []string
+	for _, id := range ids {
+		if contains(lp.Exceptions[pr], id) {
+			continue
+		}
+		if contains(lp.Deny, id) || (len(lp.Allow) > 0 && !contains(lp.Allow, id)) {
+			bad = append(bad, id)
+		}
+	}
+	return bad
+}
+
+// LicenseViolation describes a project whose licenses the license policy does
+// not allow.
+type LicenseViolation struct {
+	ProjectRoot gps.ProjectRoot
+
+	// Licenses are the SPDX identifiers of the project's licenses that are
+	// not allowed.
+	Licenses []string
+}
+
+func (v LicenseViolation) String() string {
+	return string(v.ProjectRoot) + " (" + strings.Join(v.Licenses, ", ") + ")"
+}
+
+// CheckLicenses detects the licenses of the projects in the lock l that are new
+// relative to the lock old, or locked to another version or source, and
+// returns those whose licenses p's license policy does not allow. Projects are
+// retrieved through sm to detect their licenses.
+func (p *Project) CheckLicenses(sm gps.SourceManager, old, l *Lock) ([]LicenseViolation, error) {
+	if p.Manifest == nil || p.Manifest.LicensePolicy.IsEmpty() || l == nil {
+		return nil, nil
+	}
+
+	var ol gps.Lock
+	if old != nil {
+		ol = old
+	}
+	diff := gps.DiffLocks(ol, l)
+	if diff == nil {
+		return nil, nil
+	}
+	solved := make(map[gps.ProjectRoot]bool)
+	for _, d := range diff.Add {
+		solved[d.Name] = true
+	}
+	for _, d := range diff.Modify {
+		if d.Source != nil || d.Version != nil || d.Branch != nil || d.Revision != nil {
+			solved[d.Name] = true
+		}
+	}
+	if len(solved) == 0 {
+		return nil, nil
+	}
+
+	td, err := ioutil.TempDir("", "dep-licenses")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create temp dir to detect licenses in")
+	}
+	defer os.RemoveAll(td)
+
+	var violations []LicenseViolation
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		if !solved[pr] {
+			continue
+		}
+
+		dir := filepath.Join(td, string(pr))
+		if err := sm.ExportProject(context.TODO(), lp.Ident(), lp.Version(), dir); err != nil {
+			return violations, errors.Wrapf(err, "could not export %s to detect its licenses", pr)
+		}
+		ids, err := licenses.Detect(dir)
+		if err != nil {
+			return violations, errors.Wrapf(err, "could not detect the licenses of %s", pr)
+		}
+		if bad := p.Manifest.LicensePolicy.Disallowed(pr, ids); len(bad) > 0 {
+			violations = append(violations, LicenseViolation{ProjectRoot: pr, Licenses: bad})
+		}
+	}
+	return violations, nil
+}
+
+// EnforceLicensePolicy checks the projects newly solved into l against p's
+// license policy, as CheckLicenses does. Violations are an error, unless the
+// policy only warns about them, in which case they are logged.
+func (c *Ctx) EnforceLicensePolicy(p *Project, sm gps.SourceManager, l *Lock) error {
+	violations, err := p.CheckLicenses(sm, p.Lock, l)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, v := range violations {
+		buf.WriteString("\n  " + v.String())
+	}
+	if p.Manifest.LicensePolicy.Warn {
+		msg := fmt.Sprintf("the licenses of these dependencies are not allowed by the license policy in %s:%s", ManifestName, buf.String())
+		c.Logger().Warnf("%s", msg)
+		events.Warn(msg)
+		return nil
+	}
+	return errors.Errorf("the licenses of these dependencies are not allowed by the license policy in %s:%s", ManifestName, buf.String())
+}
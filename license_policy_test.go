@@ -0,0 +1,76 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestLicensePolicyDisallowed(t *testing.T) {
+	policy := LicensePolicy{
+		Allow: []string{"MIT", "BSD-3-Clause", "GPL-2.0"},
+		Deny:  []string{"GPL-2.0"},
+		Exceptions: map[gps.ProjectRoot][]string{
+			"github.com/foo/bar": {"MPL-2.0", "NONE"},
+		},
+	}
+
+	cases := []struct {
+		name string
+		pr   gps.ProjectRoot
+		ids  []string
+		want []string
+	}{
+		{
+			name: "allowed",
+			pr:   "github.com/foo/baz",
+			ids:  []string{"mit", "BSD-3-Clause"},
+		},
+		{
+			name: "not allowed",
+			pr:   "github.com/foo/baz",
+			ids:  []string{"MIT", "Apache-2.0"},
+			want: []string{"Apache-2.0"},
+		},
+		{
+			name: "denied despite allowed",
+			pr:   "github.com/foo/baz",
+			ids:  []string{"GPL-2.0-only"},
+			want: []string{"GPL-2.0-only"},
+		},
+		{
+			name: "no license",
+			pr:   "github.com/foo/baz",
+			ids:  []string{"NONE"},
+			want: []string{"NONE"},
+		},
+		{
+			name: "exceptions",
+			pr:   "github.com/foo/bar",
+			ids:  []string{"MPL-2.0", "NONE", "MIT"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := policy.Disallowed(c.pr, c.ids)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("unexpected disallowed licenses:\n\t(GOT) %v\n\t(WNT) %v", got, c.want)
+			}
+		})
+	}
+
+	if got := (LicensePolicy{}).Disallowed("github.com/foo/baz", []string{"NONE"}); got != nil {
+		t.Fatalf("an empty policy should allow every license, but disallowed %v", got)
+	}
+
+	deny := LicensePolicy{Deny: []string{"AGPL-3.0"}}
+	if got := deny.Disallowed("github.com/foo/baz", []string{"Apache-2.0", "AGPL-3.0"}); !reflect.DeepEqual(got, []string{"AGPL-3.0"}) {
+		t.Fatalf("a deny-only policy should disallow only denied licenses, but disallowed %v", got)
+	}
+}
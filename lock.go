@@ -8,9 +8,11 @@ import (
 	"bytes"
 	"encoding/hex"
 	"io"
+	"path/filepath"
 	"sort"
 
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 )
@@ -18,10 +20,40 @@ import (
 // LockName is the lock file name used by dep.
 const LockName = "Gopkg.lock"
 
+// CurrentLockVersion is the version of the lock schema written by dep. Locks
+// that do not declare a lock-version are version 1 locks, which have no
+// project digests; they are upgraded the next time dep writes them.
+const CurrentLockVersion = 2
+
 // Lock holds lock file data and implements gps.Lock.
 type Lock struct {
 	SolveMeta SolveMeta
 	P         []gps.LockedProject
+
+	// Version is the schema version the lock was read with.
+	Version int
+
+	// Digests holds the digest of each project's contents in vendor/, as
+	// computed by pkgtree.DigestFromDirectory when it was last written, keyed
+	// by project root. Projects which have not been vendored since the lock
+	// was upgraded to version 2 have no digest.
+	Digests map[gps.ProjectRoot][]byte
+
+	// PruneOpts holds the prune options with which each project that has a
+	// digest was vendored. Projects whose digests were recorded before prune
+	// options were have none, and are taken to have been vendored with
+	// unknown options.
+	PruneOpts map[gps.ProjectRoot]gps.PruneOptions
+
+	// Repos holds the URL of the repository that the go get metadata of each
+	// project whose root is a vanity import path named when it was locked,
+	// keyed by project root.
+	Repos map[gps.ProjectRoot]string
+
+	// Meta is the lock's root [metadata] table, and ProjectMeta holds the
+	// [metadata] tables nested in [[projects]] stanzas, keyed by project root.
+	Meta        Metadata
+	ProjectMeta map[gps.ProjectRoot]Metadata
 }
 
 // SolveMeta holds solver meta data.
@@ -34,8 +66,9 @@ type SolveMeta struct {
 }
 
 type rawLock struct {
-	SolveMeta solveMeta          `toml:"solve-meta"`
-	Projects  []rawLockedProject `toml:"projects"`
+	LockVersion int                `toml:"lock-version,omitempty"`
+	SolveMeta   solveMeta          `toml:"solve-meta"`
+	Projects    []rawLockedProject `toml:"projects"`
 }
 
 type solveMeta struct {
@@ -52,7 +85,19 @@ type rawLockedProject struct {
 	Revision string   `toml:"revision"`
 	Version  string   `toml:"version,omitempty"`
 	Source   string   `toml:"source,omitempty"`
+	Repo     string   `toml:"repo,omitempty"`
+	Subdir   string   `toml:"subdir,omitempty"`
 	Packages []string `toml:"packages"`
+	Digest   string   `toml:"digest,omitempty"`
+
+	// PruneOpts is a pointer, as projects may be vendored without pruning
+	// anything, and so have empty prune options.
+	PruneOpts *string `toml:"pruneopts,omitempty"`
+}
+
+// ReadLock reads a lock, as written to Gopkg.lock, from r.
+func ReadLock(r io.Reader) (*Lock, error) {
+	return readLock(r)
 }
 
 func readLock(r io.Reader) (*Lock, error) {
@@ -68,13 +113,35 @@ func readLock(r io.Reader) (*Lock, error) {
 		return nil, errors.Wrap(err, "Unable to parse the lock as TOML")
 	}
 
-	return fromRawLock(raw)
+	l, err := fromRawLock(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := toml.LoadBytes(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to parse the lock as TOML")
+	}
+	l.Meta = metadataFromTree(tree)
+	if pmd := projectMetadataFromTree(tree, "projects"); len(pmd) > 0 {
+		l.ProjectMeta = pmd
+	}
+
+	return l, nil
 }
 
 func fromRawLock(raw rawLock) (*Lock, error) {
 	var err error
 	l := &Lock{
-		P: make([]gps.LockedProject, len(raw.Projects)),
+		P:       make([]gps.LockedProject, len(raw.Projects)),
+		Version: raw.LockVersion,
+	}
+
+	if l.Version == 0 {
+		l.Version = 1
+	}
+	if l.Version > CurrentLockVersion {
+		return nil, errors.Errorf("lock version %d is not supported by this version of dep, which supports up to version %d", l.Version, CurrentLockVersion)
 	}
 
 	l.SolveMeta.InputsDigest, err = hex.DecodeString(raw.SolveMeta.InputsDigest)
@@ -102,11 +169,42 @@ func fromRawLock(raw rawLock) (*Lock, error) {
 			return nil, errors.Errorf("lock file has entry for %s, but specifies no branch or version", ld.Name)
 		}
 
+		source, err := gps.JoinSubdir(ld.Source, ld.Subdir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid subdir for %s in lock", ld.Name)
+		}
 		id := gps.ProjectIdentifier{
 			ProjectRoot: gps.ProjectRoot(ld.Name),
-			Source:      ld.Source,
+			Source:      source,
 		}
 		l.P[i] = gps.NewLockedProject(id, v, ld.Packages)
+
+		if ld.Digest != "" {
+			digest, err := hex.DecodeString(ld.Digest)
+			if err != nil {
+				return nil, errors.Errorf("invalid digest for %s in lock", ld.Name)
+			}
+			if l.Digests == nil {
+				l.Digests = make(map[gps.ProjectRoot][]byte)
+			}
+			l.Digests[id.ProjectRoot] = digest
+		}
+		if ld.Repo != "" {
+			if l.Repos == nil {
+				l.Repos = make(map[gps.ProjectRoot]string)
+			}
+			l.Repos[id.ProjectRoot] = ld.Repo
+		}
+		if ld.PruneOpts != nil {
+			po, err := gps.ParsePruneOptions(*ld.PruneOpts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid prune options for %s in lock", ld.Name)
+			}
+			if l.PruneOpts == nil {
+				l.PruneOpts = make(map[gps.ProjectRoot]gps.PruneOptions)
+			}
+			l.PruneOpts[id.ProjectRoot] = po
+		}
 	}
 
 	return l, nil
@@ -139,6 +237,7 @@ func (l *Lock) HasProjectWithRoot(root gps.ProjectRoot) bool {
 // toRaw converts the manifest into a representation suitable to write to the lock file
 func (l *Lock) toRaw() rawLock {
 	raw := rawLock{
+		LockVersion: CurrentLockVersion,
 		SolveMeta: solveMeta{
 			InputsDigest:    hex.EncodeToString(l.SolveMeta.InputsDigest),
 			AnalyzerName:    l.SolveMeta.AnalyzerName,
@@ -157,8 +256,16 @@ func (l *Lock) toRaw() rawLock {
 		id := lp.Ident()
 		ld := rawLockedProject{
 			Name:     string(id.ProjectRoot),
-			Source:   id.Source,
-			Packages: lp.Packages(),
+			Packages: sortedPackages(lp.Packages()),
+		}
+		ld.Source, ld.Subdir = gps.SplitSubdir(id.Source)
+		ld.Repo = l.Repos[id.ProjectRoot]
+		if digest := l.Digests[id.ProjectRoot]; len(digest) > 0 {
+			ld.Digest = hex.EncodeToString(digest)
+			if po, has := l.PruneOpts[id.ProjectRoot]; has {
+				opts := po.String()
+				ld.PruneOpts = &opts
+			}
 		}
 
 		v := lp.Version()
@@ -170,15 +277,198 @@ func (l *Lock) toRaw() rawLock {
 	return raw
 }
 
+// sortedPackages returns a sorted copy of pkgs, without duplicates, so that
+// the packages of a project are always listed in the same order.
+func sortedPackages(pkgs []string) []string {
+	sorted := make([]string, 0, len(pkgs))
+	seen := make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		if !seen[pkg] {
+			seen[pkg] = true
+			sorted = append(sorted, pkg)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
 // MarshalTOML serializes this lock into TOML via an intermediate raw form.
+//
+// The result is the canonical form of the lock, which depends only on its
+// contents: projects are sorted by name, the packages of each project are
+// sorted, and written one per line when there is more than one, and each
+// project's fields, its digest included, are written in a fixed order.
 func (l *Lock) MarshalTOML() ([]byte, error) {
 	raw := l.toRaw()
 	var buf bytes.Buffer
-	enc := toml.NewEncoder(&buf).ArraysWithOneElementPerLine(true)
-	err := enc.Encode(raw)
+
+	// Projects are encoded one at a time so that their metadata can be written
+	// alongside them. Everything is written in the order in which the encoder
+	// would have placed it.
+	err := encodeTOML(&buf, struct {
+		LockVersion int `toml:"lock-version"`
+	}{raw.LockVersion})
+	if err == nil {
+		err = writeMetadata(&buf, l.Meta)
+	}
+	for i := 0; err == nil && i < len(raw.Projects); i++ {
+		err = encodeTOML(&buf, struct {
+			Projects []rawLockedProject `toml:"projects"`
+		}{raw.Projects[i : i+1]})
+		if err == nil {
+			err = writeNestedMetadata(&buf, "projects", l.ProjectMeta[gps.ProjectRoot(raw.Projects[i].Name)])
+		}
+	}
+	if err == nil {
+		err = encodeTOML(&buf, struct {
+			SolveMeta solveMeta `toml:"solve-meta"`
+		}{raw.SolveMeta})
+	}
+
 	return buf.Bytes(), errors.Wrap(err, "Unable to marshal lock to TOML string")
 }
 
+// preserveMetadata carries the metadata of old over to l, for l's root and
+// for each of l's projects that is also present in old. Metadata already set
+// on l takes precedence.
+func (l *Lock) preserveMetadata(old *Lock) {
+	if old == nil || old == l {
+		return
+	}
+
+	if l.Meta == nil {
+		l.Meta = old.Meta.Copy()
+	}
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		md, has := old.ProjectMeta[pr]
+		if !has {
+			continue
+		}
+		if l.ProjectMeta == nil {
+			l.ProjectMeta = make(map[gps.ProjectRoot]Metadata)
+		}
+		if _, has := l.ProjectMeta[pr]; !has {
+			l.ProjectMeta[pr] = md.Copy()
+		}
+	}
+}
+
+// preserveDigests carries the digests of old, and the prune options they were
+// computed with, over to each of l's projects that has no digest of its own,
+// and is locked identically in old.
+func (l *Lock) preserveDigests(old *Lock) {
+	if old == nil || old == l || len(old.Digests) == 0 {
+		return
+	}
+
+	oldProjects := make(map[gps.ProjectRoot]gps.LockedProject, len(old.P))
+	for _, lp := range old.P {
+		oldProjects[lp.Ident().ProjectRoot] = lp
+	}
+
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		if _, has := l.Digests[pr]; has {
+			continue
+		}
+		olp, has := oldProjects[pr]
+		digest := old.Digests[pr]
+		if !has || len(digest) == 0 || !lp.Eq(olp) {
+			continue
+		}
+		l.setDigest(pr, append([]byte(nil), digest...), old.PruneOpts)
+	}
+}
+
+// preserveRepos carries the vanity repositories of old over to each of l's
+// projects that has none of its own, and is retrieved from the same source
+// in old.
+func (l *Lock) preserveRepos(old *Lock) {
+	if old == nil || old == l || len(old.Repos) == 0 {
+		return
+	}
+
+	oldSources := make(map[gps.ProjectRoot]string, len(old.P))
+	for _, lp := range old.P {
+		oldSources[lp.Ident().ProjectRoot] = lp.Ident().Source
+	}
+
+	for _, lp := range l.P {
+		id := lp.Ident()
+		repo, has := old.Repos[id.ProjectRoot]
+		if _, set := l.Repos[id.ProjectRoot]; set || !has || oldSources[id.ProjectRoot] != id.Source {
+			continue
+		}
+		if l.Repos == nil {
+			l.Repos = make(map[gps.ProjectRoot]string)
+		}
+		l.Repos[id.ProjectRoot] = repo
+	}
+}
+
+// setDigest sets the digest of the project pr, along with its prune options,
+// if they are in opts.
+func (l *Lock) setDigest(pr gps.ProjectRoot, digest []byte, opts map[gps.ProjectRoot]gps.PruneOptions) {
+	if l.Digests == nil {
+		l.Digests = make(map[gps.ProjectRoot][]byte)
+	}
+	l.Digests[pr] = digest
+
+	po, has := opts[pr]
+	if !has {
+		delete(l.PruneOpts, pr)
+		return
+	}
+	if l.PruneOpts == nil {
+		l.PruneOpts = make(map[gps.ProjectRoot]gps.PruneOptions)
+	}
+	l.PruneOpts[pr] = po
+}
+
+// updateDigests sets the digest of each of l's projects to that of its
+// contents under vendorDir, as pruned with prune, and reports whether any of
+// them, or their prune options, changed. Projects in skip were not vendored,
+// and keep their digests. Digests are taken from written, which holds those
+// computed as the projects were written, or else from memo, where they can be.
+func (l *Lock) updateDigests(vendorDir string, skip map[gps.ProjectRoot]bool, written map[gps.ProjectRoot][]byte, prune gps.CascadingPruneOptions, memo *pkgtree.DigestMemo) (bool, error) {
+	var changed bool
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		if skip[pr] {
+			continue
+		}
+		digest, has := written[pr]
+		if !has {
+			var err error
+			digest, err = memo.Digest(string(pr), filepath.Join(vendorDir, string(pr)))
+			if err != nil {
+				return false, errors.Wrapf(err, "could not compute digest of %s", pr)
+			}
+		}
+		po := prune.PruneOptionsFor(pr)
+		if old, has := l.PruneOpts[pr]; has && old == po && bytes.Equal(digest, l.Digests[pr]) {
+			continue
+		}
+		l.setDigest(pr, digest, map[gps.ProjectRoot]gps.PruneOptions{pr: po})
+		changed = true
+	}
+	return changed, nil
+}
+
+// vendoredIntact reports whether the project pr is vendored in vendorDir just
+// as l says it last was, with the prune options prune gives it, so that it need
+// not be written out again. Its digest is taken from memo if it can be.
+func (l *Lock) vendoredIntact(vendorDir string, pr gps.ProjectRoot, prune gps.CascadingPruneOptions, memo *pkgtree.DigestMemo) bool {
+	want := l.Digests[pr]
+	po, has := l.PruneOpts[pr]
+	if len(want) == 0 || !has || po != prune.PruneOptionsFor(pr) {
+		return false
+	}
+	digest, err := memo.Digest(string(pr), filepath.Join(vendorDir, string(pr)))
+	return err == nil && bytes.Equal(digest, want)
+}
+
 // LockFromSolution converts a gps.Solution to dep's representation of a lock.
 //
 // Data is defensively copied wherever necessary to ensure the resulting *lock
@@ -194,10 +484,28 @@ func LockFromSolution(in gps.Solution) *Lock {
 			SolverName:      in.SolverName(),
 			SolverVersion:   in.SolverVersion(),
 		},
-		P: make([]gps.LockedProject, len(p)),
+		P:       make([]gps.LockedProject, len(p)),
+		Version: CurrentLockVersion,
 	}
 
 	copy(l.SolveMeta.InputsDigest, h)
 	copy(l.P, p)
 	return l
 }
+
+// FormatLock returns the lock in data in its canonical form, preceded by the
+// comment dep writes at the top of Gopkg.lock. Formatting a lock changes only
+// its layout, so that it matches the lock dep would write: the order of its
+// projects, packages and fields, and its spacing. Comments other than the
+// leading one are dropped.
+func FormatLock(data []byte) ([]byte, error) {
+	l, err := readLock(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out, err := l.MarshalTOML()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(nil), lockFileComment...), out...), nil
+}
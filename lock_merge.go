@@ -0,0 +1,170 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// LockMerge is the result of a three-way merge of locks.
+type LockMerge struct {
+	// Lock holds every project that could be merged. Disputed projects are
+	// left out of it, so that a solve seeded with it is free to pick their
+	// versions again, while keeping every other project where it is.
+	Lock *Lock
+
+	// Disputed lists the projects that were changed differently on each side.
+	Disputed []gps.ProjectRoot
+
+	// Ours and Theirs are the locks that were merged.
+	Ours, Theirs *Lock
+}
+
+// MergeLocks performs a three-way merge of the ours and theirs locks, which
+// both derive from base. base may be nil, if the two locks were created
+// independently.
+//
+// Each project is merged on its own: a project changed, added or removed on
+// only one side since base takes that side's change, and a project changed
+// identically on both sides is kept. Any other project is disputed.
+func MergeLocks(base, ours, theirs *Lock) *LockMerge {
+	if base == nil {
+		base = &Lock{}
+	}
+
+	index := func(l *Lock) map[gps.ProjectRoot]gps.LockedProject {
+		lps := make(map[gps.ProjectRoot]gps.LockedProject, len(l.P))
+		for _, lp := range l.P {
+			lps[lp.Ident().ProjectRoot] = lp
+		}
+		return lps
+	}
+	bp, op, tp := index(base), index(ours), index(theirs)
+
+	roots := make(map[gps.ProjectRoot]bool)
+	for _, lps := range []map[gps.ProjectRoot]gps.LockedProject{bp, op, tp} {
+		for pr := range lps {
+			roots[pr] = true
+		}
+	}
+
+	same := func(a, b gps.LockedProject, hasA, hasB bool) bool {
+		return hasA == hasB && (!hasA || a.Eq(b))
+	}
+
+	lm := &LockMerge{
+		Lock: &Lock{
+			SolveMeta: ours.SolveMeta,
+			Version:   ours.Version,
+			Meta:      ours.Meta.Copy(),
+		},
+		Ours:   ours,
+		Theirs: theirs,
+	}
+
+	for pr := range roots {
+		b, hasB := bp[pr]
+		o, hasO := op[pr]
+		t, hasT := tp[pr]
+
+		var lp gps.LockedProject
+		var has bool
+		var from *Lock
+		switch {
+		case same(o, t, hasO, hasT):
+			lp, has, from = o, hasO, ours
+		case same(b, o, hasB, hasO):
+			lp, has, from = t, hasT, theirs
+		case same(b, t, hasB, hasT):
+			lp, has, from = o, hasO, ours
+		default:
+			lm.Disputed = append(lm.Disputed, pr)
+			continue
+		}
+
+		if !has {
+			continue
+		}
+		lm.Lock.P = append(lm.Lock.P, lp)
+		if md, ok := from.ProjectMeta[pr]; ok {
+			if lm.Lock.ProjectMeta == nil {
+				lm.Lock.ProjectMeta = make(map[gps.ProjectRoot]Metadata)
+			}
+			lm.Lock.ProjectMeta[pr] = md.Copy()
+		}
+		if digest, ok := from.Digests[pr]; ok {
+			lm.Lock.setDigest(pr, append([]byte(nil), digest...), from.PruneOpts)
+		}
+	}
+
+	sort.Slice(lm.Lock.P, func(i, j int) bool {
+		return lm.Lock.P[i].Ident().Less(lm.Lock.P[j].Ident())
+	})
+	sort.Slice(lm.Disputed, func(i, j int) bool {
+		return lm.Disputed[i] < lm.Disputed[j]
+	})
+	return lm
+}
+
+// Trivial returns the side of the merge that the merged lock is identical to,
+// if there is one. When there is, that lock can be used as the result of the
+// merge as is, without solving again.
+func (lm *LockMerge) Trivial() *Lock {
+	if len(lm.Disputed) > 0 {
+		return nil
+	}
+	for _, l := range []*Lock{lm.Ours, lm.Theirs} {
+		if gps.LocksAreEq(lm.Lock, l, false) {
+			return l
+		}
+	}
+	return nil
+}
+
+// Resolve builds the result of the merge from a solution computed with Lock.
+// The metadata and digests of projects that the solution left as they were
+// are carried over.
+func (lm *LockMerge) Resolve(solution gps.Solution) *Lock {
+	l := LockFromSolution(solution)
+	l.preserveMetadata(lm.Lock)
+	l.preserveDigests(lm.Lock)
+	l.preserveRepos(lm.Lock)
+	return l
+}
+
+// ReadLockFile reads a lock from the file at path. An empty or missing file
+// yields a nil lock, as is the case for the base of a merge between locks that
+// were created independently.
+func ReadLockFile(path string) (*Lock, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "could not open %s", path)
+	}
+	defer f.Close()
+
+	if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+		return nil, nil
+	}
+
+	l, err := readLock(f)
+	return l, errors.Wrapf(err, "error while parsing %s", path)
+}
+
+// WriteLockFile writes l to the file at path, as dep writes Gopkg.lock.
+func WriteLockFile(path string, l *Lock) error {
+	data, err := l.MarshalTOML()
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal lock to TOML")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, append(lockFileComment, data...), 0666), "failed to write %s", path)
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func mergeTestProject(root, version, rev string) gps.LockedProject {
+	return gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)},
+		gps.NewVersion(version).Pair(gps.Revision(rev)),
+		[]string{"."},
+	)
+}
+
+func TestMergeLocks(t *testing.T) {
+	var (
+		a1 = mergeTestProject("github.com/foo/a", "v1.0.0", "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0")
+		a2 = mergeTestProject("github.com/foo/a", "v1.1.0", "a0196baa11ea047dd65037287451d36b861b00ea")
+		b1 = mergeTestProject("github.com/foo/b", "v1.0.0", "c6335b6b7d7a1e9f5a1f9e3b3c7d0b6c1b5c4e3a")
+		b2 = mergeTestProject("github.com/foo/b", "v2.0.0", "5c607206be5decd28e6263ffffdcee067266015e")
+		b3 = mergeTestProject("github.com/foo/b", "v3.0.0", "d05d5aca9f895d19e9265839bffeadd74a2d2ecb")
+		c1 = mergeTestProject("github.com/foo/c", "v1.0.0", "645ef00459ed84a119197bfb8d8205042c6df63d")
+		d1 = mergeTestProject("github.com/foo/d", "v1.0.0", "9a5a0b8e3c4b1c6f5d9e2a7b8c3d4e5f6a7b8c9d")
+	)
+
+	base := &Lock{P: []gps.LockedProject{a1, b1, c1}}
+	// Ours updates a and b, theirs updates b differently, removes c and adds d.
+	ours := &Lock{
+		P:           []gps.LockedProject{a2, b2, c1},
+		ProjectMeta: map[gps.ProjectRoot]Metadata{"github.com/foo/a": {"owner": "us"}},
+	}
+	theirs := &Lock{
+		P:       []gps.LockedProject{a1, b3, d1},
+		Digests: map[gps.ProjectRoot][]byte{"github.com/foo/d": {1, 2, 3}},
+	}
+
+	lm := MergeLocks(base, ours, theirs)
+	if want := []gps.ProjectRoot{"github.com/foo/b"}; !reflect.DeepEqual(lm.Disputed, want) {
+		t.Fatalf("unexpected disputed projects:\n\t(GOT) %v\n\t(WNT) %v", lm.Disputed, want)
+	}
+	if want := []gps.LockedProject{a2, d1}; !reflect.DeepEqual(lm.Lock.P, want) {
+		t.Fatalf("unexpected merged projects:\n\t(GOT) %v\n\t(WNT) %v", lm.Lock.P, want)
+	}
+	if owner, _ := lm.Lock.ProjectMeta["github.com/foo/a"].String("owner"); owner != "us" {
+		t.Error("metadata of a project taken from ours was not kept")
+	}
+	if len(lm.Lock.Digests["github.com/foo/d"]) == 0 {
+		t.Error("digest of a project taken from theirs was not kept")
+	}
+	if lm.Trivial() != nil {
+		t.Error("a merge with disputed projects should not be trivial")
+	}
+
+	// If only theirs changed, the merge is theirs.
+	lm = MergeLocks(base, base, theirs)
+	if len(lm.Disputed) != 0 || lm.Trivial() != theirs {
+		t.Fatalf("expected a trivial merge to theirs, got %v (disputed: %v)", lm.Lock.P, lm.Disputed)
+	}
+
+	// Locks created independently dispute every project they both have,
+	// unless they agree on it.
+	lm = MergeLocks(nil, &Lock{P: []gps.LockedProject{a1, b1}}, &Lock{P: []gps.LockedProject{a1, b2}})
+	if want := []gps.ProjectRoot{"github.com/foo/b"}; !reflect.DeepEqual(lm.Disputed, want) {
+		t.Fatalf("unexpected disputed projects:\n\t(GOT) %v\n\t(WNT) %v", lm.Disputed, want)
+	}
+}
+
+func TestReadWriteLockFile(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile("empty.lock", "")
+	if l, err := ReadLockFile(h.Path("empty.lock")); err != nil || l != nil {
+		t.Fatalf("expected an empty file to read as no lock, got %v, %v", l, err)
+	}
+	if l, err := ReadLockFile(filepath.Join(h.Path("."), "missing.lock")); err != nil || l != nil {
+		t.Fatalf("expected a missing file to read as no lock, got %v, %v", l, err)
+	}
+
+	want := &Lock{
+		P:       []gps.LockedProject{mergeTestProject("github.com/foo/a", "v1.0.0", "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0")},
+		Version: CurrentLockVersion,
+	}
+	path := filepath.Join(h.Path("."), LockName)
+	if err := WriteLockFile(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadLockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gps.LocksAreEq(got, want, true) {
+		t.Fatalf("lock did not survive a round trip:\n\t(GOT) %v\n\t(WNT) %v", got.P, want.P)
+	}
+}
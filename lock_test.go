@@ -5,6 +5,7 @@
 package dep
 
 import (
+	"bytes"
 	"encoding/hex"
 	"reflect"
 	"strings"
@@ -31,6 +32,7 @@ func TestReadLock(t *testing.T) {
 		SolveMeta: SolveMeta{
 			InputsDigest: b,
 		},
+		Version: 2,
 		P: []gps.LockedProject{
 			gps.NewLockedProject(
 				gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("github.com/golang/dep")},
@@ -53,10 +55,12 @@ func TestReadLock(t *testing.T) {
 	}
 
 	b, _ = hex.DecodeString("2252a285ab27944a4d7adcba8dbd03980f59ba652f12db39fa93b927c345593e")
+	digest, _ := hex.DecodeString("b4c4d9e4e87a8ee0c2a6ba6cf2b4e4d4e0f6d4d5c6e0e2cb4c4b5d1b4e0a9c1f")
 	want = &Lock{
 		SolveMeta: SolveMeta{
 			InputsDigest: b,
 		},
+		Version: 2,
 		P: []gps.LockedProject{
 			gps.NewLockedProject(
 				gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("github.com/golang/dep")},
@@ -64,6 +68,7 @@ func TestReadLock(t *testing.T) {
 				[]string{"."},
 			),
 		},
+		Digests: map[gps.ProjectRoot][]byte{"github.com/golang/dep": digest},
 	}
 
 	if !reflect.DeepEqual(got, want) {
@@ -109,6 +114,7 @@ func TestWriteLock(t *testing.T) {
 	golden = "lock/golden1.toml"
 	want = h.GetTestFileString(golden)
 	memo, _ = hex.DecodeString("2252a285ab27944a4d7adcba8dbd03980f59ba652f12db39fa93b927c345593e")
+	digest, _ := hex.DecodeString("b4c4d9e4e87a8ee0c2a6ba6cf2b4e4d4e0f6d4d5c6e0e2cb4c4b5d1b4e0a9c1f")
 	l = &Lock{
 		SolveMeta: SolveMeta{
 			InputsDigest: memo,
@@ -120,6 +126,7 @@ func TestWriteLock(t *testing.T) {
 				[]string{"."},
 			),
 		},
+		Digests: map[gps.ProjectRoot][]byte{"github.com/golang/dep": digest},
 	}
 
 	got, err = l.MarshalTOML()
@@ -138,6 +145,304 @@ func TestWriteLock(t *testing.T) {
 	}
 }
 
+func TestFormatLock(t *testing.T) {
+	in := `lock-version = 2
+[[projects]]
+    name = "github.com/sdboyer/deptestdos"
+    version = "v2.0.0"
+    revision = "5c607206be5decd28e6263ffffdcee067266015e"
+    packages = ["subp", ".", "subp"]
+[[projects]]
+    revision = "ff2948a2ac8f538c4ecd55962e919d1e13e74baf"
+    name = "github.com/sdboyer/deptest"
+    version = "v0.8.0"
+    packages = ["."]
+    digest = "b4c4d9e4e87a8ee0c2a6ba6cf2b4e4d4e0f6d4d5c6e0e2cb4c4b5d1b4e0a9c1f"
+[solve-meta]
+    solver-version = 1
+    solver-name = "gps-cdcl"
+    inputs-digest = "2252a285ab27944a4d7adcba8dbd03980f59ba652f12db39fa93b927c345593e"
+    analyzer-version = 1
+    analyzer-name = "dep"
+`
+	want := string(lockFileComment) + `lock-version = 2
+
+[[projects]]
+  digest = "b4c4d9e4e87a8ee0c2a6ba6cf2b4e4d4e0f6d4d5c6e0e2cb4c4b5d1b4e0a9c1f"
+  name = "github.com/sdboyer/deptest"
+  packages = ["."]
+  revision = "ff2948a2ac8f538c4ecd55962e919d1e13e74baf"
+  version = "v0.8.0"
+
+[[projects]]
+  name = "github.com/sdboyer/deptestdos"
+  packages = [
+    ".",
+    "subp"
+  ]
+  revision = "5c607206be5decd28e6263ffffdcee067266015e"
+  version = "v2.0.0"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  inputs-digest = "2252a285ab27944a4d7adcba8dbd03980f59ba652f12db39fa93b927c345593e"
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`
+
+	got, err := FormatLock([]byte(in))
+	if err != nil {
+		t.Fatalf("unexpected error formatting lock: %s", err)
+	}
+	if string(got) != want {
+		t.Fatalf("lock was not formatted as expected:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+
+	// The canonical form must be stable.
+	again, err := FormatLock(got)
+	if err != nil {
+		t.Fatalf("unexpected error formatting a formatted lock: %s", err)
+	}
+	if string(again) != string(got) {
+		t.Fatalf("formatting a formatted lock changed it:\n(GOT):\n%s\n(WNT):\n%s", again, got)
+	}
+
+	if _, err := FormatLock([]byte("lock-version = 3")); err == nil {
+		t.Fatal("expected an error formatting an unsupported lock")
+	}
+}
+
+func TestReadWriteLockMetadata(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	golden := "lock/metadata.toml"
+	lf := h.GetTestFile(golden)
+	defer lf.Close()
+	l, err := readLock(lf)
+	if err != nil {
+		t.Fatalf("Should have read Lock correctly, but got err %q", err)
+	}
+
+	if v, _ := l.Meta.String("generator"); v != "deptool" {
+		t.Errorf("Unexpected root metadata value %q", v)
+	}
+	if v, _ := l.ProjectMeta["github.com/golang/dep"].String("license"); v != "BSD-3-Clause" {
+		t.Errorf("Unexpected project metadata value %q", v)
+	}
+
+	got, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling lock to TOML: %q", err)
+	}
+	if want := h.GetTestFileString(golden); string(got) != want {
+		if *test.UpdateGolden {
+			if err = h.WriteTestFile(golden, string(got)); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			t.Errorf("Metadata did not round-trip as expected:\n\t(GOT): %s\n\t(WNT): %s", string(got), want)
+		}
+	}
+
+	// Metadata survives replacing the lock with one derived from a solution,
+	// but only for projects that remain in it.
+	nl := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("github.com/golang/dep")},
+				gps.NewBranch("master").Pair(gps.Revision("a0196baa11ea047dd65037287451d36b861b00ea")),
+				[]string{"."},
+			),
+		},
+	}
+	nl.preserveMetadata(l)
+	if !reflect.DeepEqual(nl.Meta, l.Meta) {
+		t.Errorf("Root metadata was not preserved:\n\t(GOT) %v\n\t(WNT) %v", nl.Meta, l.Meta)
+	}
+	if !reflect.DeepEqual(nl.ProjectMeta, l.ProjectMeta) {
+		t.Errorf("Project metadata was not preserved:\n\t(GOT) %v\n\t(WNT) %v", nl.ProjectMeta, l.ProjectMeta)
+	}
+}
+
+func TestReadLockV1(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	lf := h.GetTestFile("lock/v1.toml")
+	defer lf.Close()
+	l, err := readLock(lf)
+	if err != nil {
+		t.Fatalf("Should have read Lock correctly, but got err %q", err)
+	}
+	if l.Version != 1 {
+		t.Fatalf("lock without a lock-version should be version 1, got %d", l.Version)
+	}
+
+	// Writing the lock upgrades it to the current version.
+	got, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling valid lock to TOML: %q", err)
+	}
+	if want := h.GetTestFileString("lock/golden0.toml"); string(got) != want {
+		t.Errorf("v1 lock was not upgraded as expected:\n\t(GOT): %s\n\t(WNT): %s", got, want)
+	}
+}
+
+func TestLockDigests(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("vendor/github.com/foo/bar")
+	h.TempFile("vendor/github.com/foo/bar/bar.go", "package bar")
+	h.TempDir("vendor/github.com/foo/baz")
+	h.TempFile("vendor/github.com/foo/baz/baz.go", "package baz")
+	vendorDir := h.Path("vendor")
+
+	bar := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+		gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+		[]string{"."},
+	)
+	baz := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"},
+		gps.NewVersion("v1.0.0").Pair("c6335b6b7d7a1e9f5a1f9e3b3c7d0b6c1b5c4e3a"),
+		[]string{"."},
+	)
+
+	prune := gps.CascadingPruneOptions{DefaultOptions: gps.PruneNestedVendorDirs}
+	old := &Lock{P: []gps.LockedProject{bar, baz}}
+	changed, err := old.updateDigests(vendorDir, nil, nil, prune, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(old.Digests) != 2 {
+		t.Fatalf("expected a digest for each project, got %v", old.Digests)
+	}
+	if changed, _ = old.updateDigests(vendorDir, nil, nil, prune, nil); changed {
+		t.Fatal("digests should not change when vendor/ is unchanged")
+	}
+
+	// Digests and prune options survive a round trip through TOML.
+	data, err := old.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := readLock(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rt.Digests["github.com/foo/bar"], old.Digests["github.com/foo/bar"]) || rt.PruneOpts["github.com/foo/bar"] != gps.PruneNestedVendorDirs {
+		t.Fatalf("digest and prune options were not read back:\n%s", data)
+	}
+
+	if !old.vendoredIntact(vendorDir, "github.com/foo/bar", prune, nil) {
+		t.Error("expected unchanged project to be intact")
+	}
+	h.TempFile("vendor/github.com/foo/baz/baz.go", "package baz // changed")
+	if old.vendoredIntact(vendorDir, "github.com/foo/baz", prune, nil) {
+		t.Error("expected modified project not to be intact")
+	}
+	other := gps.CascadingPruneOptions{DefaultOptions: gps.PruneNestedVendorDirs | gps.PruneGoTestFiles}
+	if old.vendoredIntact(vendorDir, "github.com/foo/bar", other, nil) {
+		t.Error("expected project vendored with other prune options not to be intact")
+	}
+	if changed, _ = old.updateDigests(vendorDir, map[gps.ProjectRoot]bool{"github.com/foo/baz": true}, nil, other, nil); !changed {
+		t.Error("digests should change when prune options do")
+	}
+
+	// Only digests of projects locked identically are carried over.
+	baz2 := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"},
+		gps.NewVersion("v1.1.0").Pair("a0196baa11ea047dd65037287451d36b861b00ea"),
+		[]string{"."},
+	)
+	l := &Lock{P: []gps.LockedProject{bar, baz2}}
+	l.preserveDigests(old)
+	if !bytes.Equal(l.Digests["github.com/foo/bar"], old.Digests["github.com/foo/bar"]) {
+		t.Error("digest of unchanged project was not preserved")
+	}
+	if _, has := l.Digests["github.com/foo/baz"]; has {
+		t.Error("digest of changed project should not be preserved")
+	}
+}
+
+func TestReadWriteLockSubdir(t *testing.T) {
+	foo := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "example.com/foo", Source: "github.com/org/mono//libs/foo"},
+		gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+		[]string{"."},
+	)
+	l := &Lock{P: []gps.LockedProject{foo}}
+
+	raw := l.toRaw()
+	if raw.Projects[0].Source != "github.com/org/mono" || raw.Projects[0].Subdir != "libs/foo" {
+		t.Fatalf("unexpected source and subdir: %q, %q", raw.Projects[0].Source, raw.Projects[0].Subdir)
+	}
+
+	data, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readLock(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := got.P[0].Ident(); id != foo.Ident() {
+		t.Errorf("unexpected project identifier:\n\t(GOT): %v\n\t(WNT): %v", id, foo.Ident())
+	}
+}
+
+func TestReadWriteLockRepos(t *testing.T) {
+	foo := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "example.com/foo"},
+		gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+		[]string{"."},
+	)
+	bar := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "example.com/bar"},
+		gps.NewVersion("v1.0.0").Pair("a0196baa11ea047dd65037287451d36b861b00ea"),
+		[]string{"."},
+	)
+	l := &Lock{
+		P:     []gps.LockedProject{foo, bar},
+		Repos: map[gps.ProjectRoot]string{"example.com/foo": "https://git.example.com/foo"},
+	}
+
+	data, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`repo = "https://git.example.com/foo"`)) {
+		t.Fatalf("repo not written to lock:\n%s", data)
+	}
+	got, err := readLock(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Repos, l.Repos) {
+		t.Fatalf("unexpected repos:\n\t(GOT): %v\n\t(WNT): %v", got.Repos, l.Repos)
+	}
+
+	// Repos are carried over to projects retrieved from the same source.
+	moved := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "example.com/foo", Source: "https://github.com/fork/foo"},
+		gps.NewVersion("v1.0.1").Pair("5c607206be5decd28e6263ffffdcee067266015e"),
+		[]string{"."},
+	)
+	next := &Lock{P: []gps.LockedProject{foo, bar}}
+	next.preserveRepos(got)
+	if !reflect.DeepEqual(next.Repos, l.Repos) {
+		t.Fatalf("unexpected preserved repos:\n\t(GOT): %v\n\t(WNT): %v", next.Repos, l.Repos)
+	}
+	next = &Lock{P: []gps.LockedProject{moved, bar}}
+	next.preserveRepos(got)
+	if len(next.Repos) != 0 {
+		t.Fatalf("repos should not be preserved for projects from other sources, but got %v", next.Repos)
+	}
+}
+
 func TestReadLockErrors(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -150,6 +455,8 @@ func TestReadLockErrors(t *testing.T) {
 		{"specified both", "lock/error0.toml"},
 		{"invalid hash", "lock/error1.toml"},
 		{"no branch or version", "lock/error2.toml"},
+		{"invalid digest", "lock/error3.toml"},
+		{"not supported", "lock/error4.toml"},
 	}
 
 	for _, tst := range tests {
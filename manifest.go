@@ -11,6 +11,8 @@ import (
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/golang/dep/gps"
@@ -24,42 +26,152 @@ const ManifestName = "Gopkg.toml"
 
 // Errors
 var (
-	errInvalidConstraint   = errors.Errorf("%q must be a TOML array of tables", "constraint")
-	errInvalidOverride     = errors.Errorf("%q must be a TOML array of tables", "override")
-	errInvalidRequired     = errors.Errorf("%q must be a TOML list of strings", "required")
-	errInvalidIgnored      = errors.Errorf("%q must be a TOML list of strings", "ignored")
-	errInvalidPrune        = errors.Errorf("%q must be a TOML table of booleans", "prune")
-	errInvalidPruneProject = errors.Errorf("%q must be a TOML array of tables", "prune.project")
-	errInvalidMetadata     = errors.New("metadata should be a TOML table")
+	errInvalidConstraint    = errors.Errorf("%q must be a TOML array of tables", "constraint")
+	errInvalidOverride      = errors.Errorf("%q must be a TOML array of tables", "override")
+	errInvalidRequired      = errors.Errorf("%q must be a TOML list of strings", "required")
+	errInvalidIgnored       = errors.Errorf("%q must be a TOML list of strings", "ignored")
+	errInvalidNoVerify      = errors.Errorf("%q must be a TOML list of strings", "noverify")
+	errInvalidPrune         = errors.Errorf("%q must be a TOML table of booleans", "prune")
+	errInvalidPruneProject  = errors.Errorf("%q must be a TOML array of tables, or a table of tables", "prune.project")
+	errInvalidMetadata      = errors.New("metadata should be a TOML table")
+	errInvalidHooks         = errors.Errorf("%q must be a TOML table of string lists", "hooks")
+	errInvalidVersions      = errors.Errorf("%q must be a TOML table of strings", "versions")
+	errInvalidSources       = errors.Errorf("%q must be a TOML table of strings", "sources")
+	errInvalidProtocols     = errors.Errorf("%q must be a TOML table of %q or %q", "protocols", "ssh", "https")
+	errInvalidSignatures    = errors.Errorf("%q must be a TOML table with a string %q and a list of strings %q", "signatures", "keyring", "projects")
+	errInvalidSigstore      = errors.Errorf("%q must be a TOML array of tables of strings, and the boolean %q", "sigstore", "enforce")
+	errInvalidLicensePolicy = errors.Errorf("%q must be a TOML table of string lists, with a table %q of string lists", "license-policy", "exceptions")
+	errInvalidOnViolation   = errors.Errorf("%q in %q must be %q or %q", "on-violation", "license-policy", "fail", "warn")
+	errInvalidAuditLog      = errors.Errorf("%q must be a string", "audit-log")
+	errInvalidBazelMacro    = errors.Errorf("%q must be a string of the form %q", "bazel-macro", "file.bzl%macro")
+	errInvalidBanned        = errors.Errorf("%q must be a TOML array of tables of strings", "banned")
 
 	errInvalidProjectRoot = errors.New("ProjectRoot name validation failed")
 
+	errInvalidSchemaVersion = errors.Errorf("%q must be a non-negative integer", "schema-version")
+	errInvalidGroup         = errors.Errorf("%q in %q must be a TOML list of strings", "group", "constraint")
+	errInvalidPlatform      = errors.Errorf("%q and %q in %q must be TOML lists of strings", "os", "arch", "constraint")
+	errInvalidDigest        = errors.Errorf("%q in %q must be a string of the form %q", "digest", "constraint", "h1:<base64 SHA-256>")
+
 	errInvalidPruneValue = errors.New("prune options values must be booleans")
 	errPruneSubProject   = errors.New("prune projects should not contain sub projects")
 
 	errRootPruneContainsName   = errors.Errorf("%q should not include a name", "prune")
 	errInvalidRootPruneValue   = errors.New("root prune options must be omitted instead of being set to false")
 	errInvalidPruneProjectName = errors.Errorf("%q in %q must be a string", "name", "prune.project")
+	errInvalidPruneGroup       = errors.Errorf("%q names may only contain a wildcard as a trailing %q", "prune.project", gps.PruneGroupSuffix)
 	errNoName                  = errors.New("no name provided")
 )
 
 // Manifest holds manifest file data and implements gps.RootManifest.
 type Manifest struct {
+	// SchemaVersion is the version of the manifest's layout, from its
+	// schema-version field. It is 0 for manifests that predate versioning.
+	SchemaVersion int
+
 	Constraints gps.ProjectConstraints
 	Ovr         gps.ProjectConstraints
 
 	Ignored  []string
 	Required []string
 
+	// NoVerify lists the project roots whose vendored contents are exempt
+	// from verification, e.g. because they are intentionally patched.
+	NoVerify []string
+
 	PruneOptions gps.CascadingPruneOptions
+
+	Hooks Hooks
+
+	// Signatures is the manifest's [signatures] table, naming the projects
+	// whose locked versions must be signed.
+	Signatures SignaturePolicy
+
+	// Sigstore maps the roots of the projects declared in [[sigstore]]
+	// stanzas to who must have signed their locked versions with Sigstore.
+	Sigstore map[gps.ProjectRoot]SigstorePolicy
+
+	// LicensePolicy is the manifest's [license-policy] table, restricting the
+	// licenses of dependencies.
+	LicensePolicy LicensePolicy
+
+	// Banned maps the project roots, or prefixes of them, declared in
+	// [[banned]] stanzas to the reasons the projects at or under each are
+	// kept out of solutions.
+	Banned map[string]string
+
+	// AuditLog is the path, relative to the project root, of the file to
+	// which changes to the lock are recorded, from the manifest's audit-log
+	// field. No changes are recorded if it is empty.
+	AuditLog string
+
+	// BazelMacro names the file, relative to the project root, and the macro
+	// in it, as "file.bzl%macro", to which dep ensure writes the
+	// go_repository rules of the locked projects, from the manifest's
+	// bazel-macro field. No macro is written if it is empty.
+	BazelMacro string
+
+	// Versions holds the named version values declared in the [versions]
+	// table, which constraint and override rules may refer to as ${name}.
+	Versions map[string]string
+
+	// Sources maps project root prefixes, declared in the [sources] table, to
+	// the sources from which the projects under them are fetched.
+	Sources map[string]string
+
+	// Protocols maps hosts and project root prefixes, declared in the
+	// [protocols] table, to the protocol, ssh or https, over which the
+	// projects under them are fetched.
+	Protocols map[string]string
+
+	// Meta is the manifest's root [metadata] table. ConstraintMeta and
+	// OverrideMeta hold the [metadata] tables nested in [[constraint]] and
+	// [[override]] stanzas, keyed by the stanza's name.
+	Meta           Metadata
+	ConstraintMeta map[gps.ProjectRoot]Metadata
+	OverrideMeta   map[gps.ProjectRoot]Metadata
+
+	// ConstraintGroups holds the groups that each [[constraint]] is placed in
+	// by its group field, keyed by the stanza's name.
+	ConstraintGroups map[gps.ProjectRoot][]string
+
+	// ConstraintPlatforms holds the platforms that each [[constraint]] is
+	// restricted to by its os and arch fields, keyed by the stanza's name.
+	ConstraintPlatforms map[gps.ProjectRoot]Platform
+
+	// ConstraintDigests holds the digests that the contents of each
+	// [[constraint]]'s project must have at the revision it is locked to, from
+	// its digest field, keyed by the stanza's name.
+	ConstraintDigests map[gps.ProjectRoot]string
+
+	// ConstraintComments holds lines of comment to write above each
+	// [[constraint]], keyed by the stanza's name. Comments are not read back
+	// from manifest files.
+	ConstraintComments map[gps.ProjectRoot][]string
+
+	// The rules of constraints and overrides that referred to Versions, as
+	// they were written, so that the references survive a rewrite.
+	constraintRefs map[gps.ProjectRoot]rawProject
+	overrideRefs   map[gps.ProjectRoot]rawProject
 }
 
 type rawManifest struct {
-	Constraints  []rawProject    `toml:"constraint,omitempty"`
-	Overrides    []rawProject    `toml:"override,omitempty"`
-	Ignored      []string        `toml:"ignored,omitempty"`
-	Required     []string        `toml:"required,omitempty"`
-	PruneOptions rawPruneOptions `toml:"prune,omitempty"`
+	AuditLog      string            `toml:"audit-log,omitempty"`
+	BazelMacro    string            `toml:"bazel-macro,omitempty"`
+	Constraints   []rawProject      `toml:"constraint,omitempty"`
+	Overrides     []rawProject      `toml:"override,omitempty"`
+	Banned        []rawBanned       `toml:"banned,omitempty"`
+	Ignored       []string          `toml:"ignored,omitempty"`
+	Required      []string          `toml:"required,omitempty"`
+	NoVerify      []string          `toml:"noverify,omitempty"`
+	PruneOptions  rawPruneOptions   `toml:"prune,omitempty"`
+	Hooks         *rawHooks         `toml:"hooks,omitempty"`
+	Signatures    *rawSignatures    `toml:"signatures,omitempty"`
+	Sigstore      []rawSigstore     `toml:"sigstore,omitempty"`
+	LicensePolicy *rawLicensePolicy `toml:"license-policy,omitempty"`
+	Versions      map[string]string `toml:"versions,omitempty"`
+	Sources       map[string]string `toml:"sources,omitempty"`
+	Protocols     map[string]string `toml:"protocols,omitempty"`
 }
 
 type rawProject struct {
@@ -68,6 +180,8 @@ type rawProject struct {
 	Revision string `toml:"revision,omitempty"`
 	Version  string `toml:"version,omitempty"`
 	Source   string `toml:"source,omitempty"`
+	Subdir   string `toml:"subdir,omitempty"`
+	Digest   string `toml:"digest,omitempty"`
 }
 
 type rawPruneOptions struct {
@@ -79,6 +193,38 @@ type rawPruneOptions struct {
 	Projects []map[string]interface{}
 }
 
+type rawBanned struct {
+	Name   string `toml:"name"`
+	Reason string `toml:"reason,omitempty"`
+}
+
+type rawSignatures struct {
+	Keyring  string   `toml:"keyring,omitempty"`
+	Projects []string `toml:"projects,omitempty"`
+}
+
+type rawSigstore struct {
+	Name     string `toml:"name"`
+	Identity string `toml:"identity"`
+	Issuer   string `toml:"issuer"`
+	Enforce  bool   `toml:"enforce,omitempty"`
+}
+
+// rawLicensePolicy is the [license-policy] table. Its exceptions are written
+// by hand, as their keys may contain dots.
+type rawLicensePolicy struct {
+	Allow       []string            `toml:"allow,omitempty"`
+	Deny        []string            `toml:"deny,omitempty"`
+	OnViolation string              `toml:"on-violation,omitempty"`
+	Exceptions  map[string][]string `toml:"exceptions,omitempty"`
+}
+
+type rawHooks struct {
+	PreEnsure          []string `toml:"pre-ensure,omitempty"`
+	PostEnsure         []string `toml:"post-ensure,omitempty"`
+	PostEnsureWebhooks []string `toml:"post-ensure-webhooks,omitempty"`
+}
+
 const (
 	pruneOptionUnusedPackages = "unused-packages"
 	pruneOptionGoTests        = "go-tests"
@@ -101,6 +247,11 @@ func NewManifest() *Manifest {
 			DefaultOptions:    gps.PruneNestedVendorDirs,
 			PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{},
 		},
+		ConstraintMeta:      make(map[gps.ProjectRoot]Metadata),
+		OverrideMeta:        make(map[gps.ProjectRoot]Metadata),
+		ConstraintGroups:    make(map[gps.ProjectRoot][]string),
+		ConstraintPlatforms: make(map[gps.ProjectRoot]Platform),
+		ConstraintDigests:   make(map[gps.ProjectRoot]string),
 	}
 }
 
@@ -116,9 +267,22 @@ func validateManifest(s string) ([]error, error) {
 
 	// match abbreviated git hash (7chars) or hg hash (12chars)
 	abbrevRevHash := regexp.MustCompile("^[a-f0-9]{7}([a-f0-9]{5})?$")
+	digestPattern := regexp.MustCompile("^h1:[A-Za-z0-9+/]{43}=$")
 	// Look for unknown fields and collect errors
 	for prop, val := range manifest {
 		switch prop {
+		case "schema-version":
+			if v, ok := val.(int64); !ok || v < 0 {
+				return warns, errInvalidSchemaVersion
+			}
+		case "audit-log":
+			if _, ok := val.(string); !ok {
+				return warns, errInvalidAuditLog
+			}
+		case "bazel-macro":
+			if v, ok := val.(string); !ok || !validBazelMacro(v) {
+				return warns, errInvalidBazelMacro
+			}
 		case "metadata":
 			// Check if metadata is of Map type
 			if reflect.TypeOf(val).Kind() != reflect.Map {
@@ -144,7 +308,7 @@ func validateManifest(s string) ([]error, error) {
 							// Check if the key is valid
 							switch key {
 							case "name":
-							case "branch", "version", "source":
+							case "branch", "version", "source", "subdir":
 								ruleProvided = true
 							case "revision":
 								ruleProvided = true
@@ -158,9 +322,41 @@ func validateManifest(s string) ([]error, error) {
 								if reflect.TypeOf(value).Kind() != reflect.Map {
 									warns = append(warns, fmt.Errorf("metadata in %q should be a TOML table", prop))
 								}
+							case "digest":
+								if prop != "constraint" {
+									warns = append(warns, unknownFieldf("invalid key %q in %q", key, prop))
+									break
+								}
+								// A digest alone pins the project's contents.
+								ruleProvided = true
+								if vs, ok := value.(string); !ok || !digestPattern.MatchString(vs) {
+									return warns, errInvalidDigest
+								}
+							case "group", "os", "arch":
+								if prop != "constraint" {
+									warns = append(warns, unknownFieldf("invalid key %q in %q", key, prop))
+									break
+								}
+								// A constraint may serve only to place its
+								// project in groups, or restrict it to some
+								// platforms.
+								ruleProvided = true
+								errInvalid := errInvalidGroup
+								if key != "group" {
+									errInvalid = errInvalidPlatform
+								}
+								vals, ok := value.([]interface{})
+								if !ok {
+									return warns, errInvalid
+								}
+								for _, v := range vals {
+									if _, ok := v.(string); !ok {
+										return warns, errInvalid
+									}
+								}
 							default:
 								// unknown/invalid key
-								warns = append(warns, fmt.Errorf("invalid key %q in %q", key, prop))
+								warns = append(warns, unknownFieldf("invalid key %q in %q", key, prop))
 							}
 						}
 						if _, ok := props["name"]; !ok {
@@ -182,7 +378,7 @@ func validateManifest(s string) ([]error, error) {
 					return warns, errInvalidOverride
 				}
 			}
-		case "ignored", "required":
+		case "ignored", "required", "noverify":
 			valid := true
 			if rawList, ok := val.([]interface{}); ok {
 				// Check element type of the array. TOML doesn't let mixing of types in
@@ -201,6 +397,9 @@ func validateManifest(s string) ([]error, error) {
 				if prop == "required" {
 					return warns, errInvalidRequired
 				}
+				if prop == "noverify" {
+					return warns, errInvalidNoVerify
+				}
 			}
 		case "prune":
 			pruneWarns, err := validatePruneOptions(val, true)
@@ -208,8 +407,68 @@ func validateManifest(s string) ([]error, error) {
 			if err != nil {
 				return warns, err
 			}
+		case "versions":
+			vars, ok := val.(map[string]interface{})
+			if !ok {
+				return warns, errInvalidVersions
+			}
+			for _, v := range vars {
+				if _, ok := v.(string); !ok {
+					return warns, errInvalidVersions
+				}
+			}
+		case "sources":
+			srcs, ok := val.(map[string]interface{})
+			if !ok {
+				return warns, errInvalidSources
+			}
+			for _, v := range srcs {
+				if _, ok := v.(string); !ok {
+					return warns, errInvalidSources
+				}
+			}
+		case "protocols":
+			protos, ok := val.(map[string]interface{})
+			if !ok {
+				return warns, errInvalidProtocols
+			}
+			for _, v := range protos {
+				if v != "ssh" && v != "https" {
+					return warns, errInvalidProtocols
+				}
+			}
+		case "hooks":
+			hookWarns, err := validateHooks(val)
+			warns = append(warns, hookWarns...)
+			if err != nil {
+				return warns, err
+			}
+		case "signatures":
+			sigWarns, err := validateSignatures(val)
+			warns = append(warns, sigWarns...)
+			if err != nil {
+				return warns, err
+			}
+		case "banned":
+			banWarns, err := validateBanned(val)
+			warns = append(warns, banWarns...)
+			if err != nil {
+				return warns, err
+			}
+		case "sigstore":
+			sigWarns, err := validateSigstore(val)
+			warns = append(warns, sigWarns...)
+			if err != nil {
+				return warns, err
+			}
+		case "license-policy":
+			policyWarns, err := validateLicensePolicy(val)
+			warns = append(warns, policyWarns...)
+			if err != nil {
+				return warns, err
+			}
 		default:
-			warns = append(warns, fmt.Errorf("unknown field in manifest: %v", prop))
+			warns = append(warns, unknownFieldf("unknown field in manifest: %v", prop))
 		}
 	}
 
@@ -232,30 +491,53 @@ func validatePruneOptions(val interface{}, root bool) (warns []error, err error)
 		case "name":
 			if root {
 				warns = append(warns, errRootPruneContainsName)
-			} else if _, ok := value.(string); !ok {
+			} else if name, ok := value.(string); !ok {
 				return warns, errInvalidPruneProjectName
+			} else if err := validatePruneProjectName(name); err != nil {
+				return warns, err
 			}
 		case "project":
 			if !root {
 				return warns, errPruneSubProject
 			}
-			if reflect.TypeOf(value).Kind() != reflect.Slice {
-				return warns, errInvalidPruneProject
-			}
 
-			for _, project := range value.([]interface{}) {
-				projectWarns, err := validatePruneOptions(project, false)
-				warns = append(warns, projectWarns...)
-				if err != nil {
-					return nil, err
+			// Projects are either an array of tables, each with a name, or a
+			// table of tables keyed by name.
+			switch projects := value.(type) {
+			case []interface{}:
+				for _, project := range projects {
+					projectWarns, err := validatePruneOptions(project, false)
+					warns = append(warns, projectWarns...)
+					if err != nil {
+						return nil, err
+					}
 				}
+			case map[string]interface{}:
+				for name, project := range projects {
+					if _, ok := project.(map[string]interface{}); !ok {
+						return warns, errInvalidPruneProject
+					}
+					if err := validatePruneProjectName(name); err != nil {
+						return nil, err
+					}
+					projectWarns, err := validatePruneOptions(project, false)
+					warns = append(warns, projectWarns...)
+					if err != nil {
+						return nil, err
+					}
+					if _, has := project.(map[string]interface{})["name"]; has {
+						warns = append(warns, errors.Errorf("%q in %q is ignored, as the project is named by its key", "name", "prune.project."+name))
+					}
+				}
+			default:
+				return warns, errInvalidPruneProject
 			}
 
 		default:
 			if root {
-				warns = append(warns, errors.Errorf("unknown field %q in %q", key, "prune"))
+				warns = append(warns, unknownFieldf("unknown field %q in %q", key, "prune"))
 			} else {
-				warns = append(warns, errors.Errorf("unknown field %q in %q", key, "prune.project"))
+				warns = append(warns, unknownFieldf("unknown field %q in %q", key, "prune.project"))
 			}
 		}
 	}
@@ -263,22 +545,211 @@ func validatePruneOptions(val interface{}, root bool) (warns []error, err error)
 	return warns, err
 }
 
+// validatePruneProjectName checks that name is either a project root, or the
+// prefix of a group of projects followed by gps.PruneGroupSuffix.
+func validatePruneProjectName(name string) error {
+	if strings.Contains(strings.TrimSuffix(name, gps.PruneGroupSuffix), "*") {
+		return errInvalidPruneGroup
+	}
+	return nil
+}
+
+func validateSignatures(val interface{}) (warns []error, err error) {
+	sigs, ok := val.(map[string]interface{})
+	if !ok {
+		return warns, errInvalidSignatures
+	}
+
+	for key, value := range sigs {
+		switch key {
+		case "keyring":
+			if _, ok := value.(string); !ok {
+				return warns, errInvalidSignatures
+			}
+		case "projects":
+			rawList, ok := value.([]interface{})
+			if !ok {
+				return warns, errInvalidSignatures
+			}
+			for _, pr := range rawList {
+				if _, ok := pr.(string); !ok {
+					return warns, errInvalidSignatures
+				}
+			}
+		default:
+			warns = append(warns, unknownFieldf("unknown field %q in %q", key, "signatures"))
+		}
+	}
+	if _, ok := sigs["projects"]; ok {
+		if _, ok := sigs["keyring"]; !ok {
+			warns = append(warns, errors.Errorf("%q lists projects, but names no %q to verify them with", "signatures", "keyring"))
+		}
+	}
+
+	return warns, nil
+}
+
+func validateBanned(val interface{}) (warns []error, err error) {
+	stanzas, ok := val.([]interface{})
+	if !ok {
+		return warns, errInvalidBanned
+	}
+
+	for _, stanza := range stanzas {
+		props, ok := stanza.(map[string]interface{})
+		if !ok {
+			return warns, errInvalidBanned
+		}
+		for key, value := range props {
+			switch key {
+			case "name", "reason":
+				if _, ok := value.(string); !ok {
+					return warns, errInvalidBanned
+				}
+			default:
+				warns = append(warns, unknownFieldf("invalid key %q in %q", key, "banned"))
+			}
+		}
+		if _, ok := props["name"]; !ok {
+			warns = append(warns, errNoName)
+		}
+	}
+
+	return warns, nil
+}
+
+func validateSigstore(val interface{}) (warns []error, err error) {
+	stanzas, ok := val.([]interface{})
+	if !ok {
+		return warns, errInvalidSigstore
+	}
+
+	for _, stanza := range stanzas {
+		props, ok := stanza.(map[string]interface{})
+		if !ok {
+			return warns, errInvalidSigstore
+		}
+		for key, value := range props {
+			switch key {
+			case "name", "identity", "issuer":
+				if _, ok := value.(string); !ok {
+					return warns, errInvalidSigstore
+				}
+			case "enforce":
+				if _, ok := value.(bool); !ok {
+					return warns, errInvalidSigstore
+				}
+			default:
+				warns = append(warns, unknownFieldf("invalid key %q in %q", key, "sigstore"))
+			}
+		}
+		if _, ok := props["name"]; !ok {
+			warns = append(warns, errNoName)
+			continue
+		}
+		for _, key := range []string{"identity", "issuer"} {
+			if _, ok := props[key]; !ok {
+				warns = append(warns, errors.Errorf("%q in %q for %q is required to verify its signatures", key, "sigstore", props["name"]))
+			}
+		}
+	}
+
+	return warns, nil
+}
+
+func validateLicensePolicy(val interface{}) (warns []error, err error) {
+	policy, ok := val.(map[string]interface{})
+	if !ok {
+		return warns, errInvalidLicensePolicy
+	}
+
+	isStringList := func(v interface{}) bool {
+		list, ok := v.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, s := range list {
+			if _, ok := s.(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	for key, value := range policy {
+		switch key {
+		case "allow", "deny":
+			if !isStringList(value) {
+				return warns, errInvalidLicensePolicy
+			}
+		case "on-violation":
+			if value != "fail" && value != "warn" {
+				return warns, errInvalidOnViolation
+			}
+		case "exceptions":
+			exceptions, ok := value.(map[string]interface{})
+			if !ok {
+				return warns, errInvalidLicensePolicy
+			}
+			for _, ids := range exceptions {
+				if !isStringList(ids) {
+					return warns, errInvalidLicensePolicy
+				}
+			}
+		default:
+			warns = append(warns, unknownFieldf("unknown field %q in %q", key, "license-policy"))
+		}
+	}
+
+	return warns, nil
+}
+
+func validateHooks(val interface{}) (warns []error, err error) {
+	hooks, ok := val.(map[string]interface{})
+	if !ok {
+		return warns, errInvalidHooks
+	}
+
+	for key, value := range hooks {
+		switch key {
+		case HookPreEnsure, HookPostEnsure, hookPostEnsureWebhooks:
+			rawList, ok := value.([]interface{})
+			if !ok {
+				return warns, errInvalidHooks
+			}
+			// TOML doesn't allow mixing types in an array, so checking the
+			// first element is enough.
+			if len(rawList) > 0 && reflect.TypeOf(rawList[0]).Kind() != reflect.String {
+				return warns, errInvalidHooks
+			}
+		default:
+			warns = append(warns, unknownFieldf("unknown field %q in %q", key, "hooks"))
+		}
+	}
+
+	return warns, nil
+}
+
 func checkRedundantPruneOptions(co gps.CascadingPruneOptions) (warns []error) {
 	for name, project := range co.PerProjectOptions {
+		// Options are redundant with those inherited from the defaults and
+		// from any enclosing groups.
+		inherited := co.GroupPruneOptionsFor(gps.ProjectRoot(strings.TrimSuffix(string(name), gps.PruneGroupSuffix)))
+
 		if project.UnusedPackages != pvnone {
-			if (co.DefaultOptions&gps.PruneUnusedPackages != 0) == (project.UnusedPackages == pvtrue) {
+			if (inherited&gps.PruneUnusedPackages != 0) == (project.UnusedPackages == pvtrue) {
 				warns = append(warns, errors.Errorf("redundant prune option %q set for %q", pruneOptionUnusedPackages, name))
 			}
 		}
 
 		if project.NonGoFiles != pvnone {
-			if (co.DefaultOptions&gps.PruneNonGoFiles != 0) == (project.NonGoFiles == pvtrue) {
+			if (inherited&gps.PruneNonGoFiles != 0) == (project.NonGoFiles == pvtrue) {
 				warns = append(warns, errors.Errorf("redundant prune option %q set for %q", pruneOptionNonGo, name))
 			}
 		}
 
 		if project.GoTests != pvnone {
-			if (co.DefaultOptions&gps.PruneGoTestFiles != 0) == (project.GoTests == pvtrue) {
+			if (inherited&gps.PruneGoTestFiles != 0) == (project.GoTests == pvtrue) {
 				warns = append(warns, errors.Errorf("redundant prune option %q set for %q", pruneOptionGoTests, name))
 			}
 		}
@@ -290,7 +761,7 @@ func checkRedundantPruneOptions(co gps.CascadingPruneOptions) (warns []error) {
 // ValidateProjectRoots validates the project roots present in manifest.
 func ValidateProjectRoots(c *Ctx, m *Manifest, sm gps.SourceManager) error {
 	// Channel to receive all the errors
-	errorCh := make(chan error, len(m.Constraints)+len(m.Ovr))
+	errorCh := make(chan error, len(m.Constraints)+len(m.Ovr)+len(m.PruneOptions.PerProjectOptions)+len(m.NoVerify))
 
 	var wg sync.WaitGroup
 
@@ -313,9 +784,18 @@ func ValidateProjectRoots(c *Ctx, m *Manifest, sm gps.SourceManager) error {
 		go validate(pr)
 	}
 	for pr := range m.PruneOptions.PerProjectOptions {
+		// Groups of projects are named by a prefix, which need not be a
+		// project root.
+		if strings.HasSuffix(string(pr), gps.PruneGroupSuffix) {
+			continue
+		}
 		wg.Add(1)
 		go validate(pr)
 	}
+	for _, pr := range m.NoVerify {
+		wg.Add(1)
+		go validate(gps.ProjectRoot(pr))
+	}
 
 	wg.Wait()
 	close(errorCh)
@@ -341,7 +821,23 @@ func readManifest(r io.Reader) (*Manifest, []error, error) {
 		return nil, nil, errors.Wrap(err, "unable to read byte stream")
 	}
 
+	// Manifests with an older layout are upgraded before anything else, so
+	// that they are read as they were meant.
+	v, err := manifestSchemaVersion(buf.Bytes())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "manifest validation failed")
+	}
+	var upgrades []string
+	if v < CurrentManifestSchemaVersion {
+		var data []byte
+		data, upgrades = upgradeManifest(buf.Bytes(), v)
+		buf = bytes.NewBuffer(data)
+	}
+
 	warns, err := validateManifest(buf.String())
+	for _, upgrade := range upgrades {
+		warns = append(warns, errors.Errorf("manifest uses an older layout, which was upgraded as it was read (%s); run dep migrate-manifest to upgrade it for good", upgrade))
+	}
 	if err != nil {
 		return nil, warns, errors.Wrap(err, "manifest validation failed")
 	}
@@ -356,6 +852,7 @@ func readManifest(r io.Reader) (*Manifest, []error, error) {
 	if err != nil {
 		return nil, warns, err
 	}
+	m.SchemaVersion = v
 
 	warns = append(warns, checkRedundantPruneOptions(m.PruneOptions)...)
 	return m, warns, nil
@@ -368,9 +865,78 @@ func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 	m.Ovr = make(gps.ProjectConstraints, len(raw.Overrides))
 	m.Ignored = raw.Ignored
 	m.Required = raw.Required
+	m.NoVerify = raw.NoVerify
+	m.Versions = raw.Versions
+	m.Sources = raw.Sources
+	m.Protocols = raw.Protocols
+	m.AuditLog = raw.AuditLog
+	m.BazelMacro = raw.BazelMacro
+	if raw.Hooks != nil {
+		m.Hooks = Hooks{
+			PreEnsure:          raw.Hooks.PreEnsure,
+			PostEnsure:         raw.Hooks.PostEnsure,
+			PostEnsureWebhooks: raw.Hooks.PostEnsureWebhooks,
+		}
+	}
+	if raw.Signatures != nil {
+		m.Signatures = SignaturePolicy{
+			Keyring:  raw.Signatures.Keyring,
+			Projects: raw.Signatures.Projects,
+		}
+	}
+	if raw.LicensePolicy != nil {
+		m.LicensePolicy = LicensePolicy{
+			Allow: raw.LicensePolicy.Allow,
+			Deny:  raw.LicensePolicy.Deny,
+			Warn:  raw.LicensePolicy.OnViolation == "warn",
+		}
+		for pr, ids := range raw.LicensePolicy.Exceptions {
+			if m.LicensePolicy.Exceptions == nil {
+				m.LicensePolicy.Exceptions = make(map[gps.ProjectRoot][]string)
+			}
+			m.LicensePolicy.Exceptions[gps.ProjectRoot(pr)] = ids
+		}
+	}
+	for _, rb := range raw.Banned {
+		if rb.Name == "" {
+			continue
+		}
+		if _, exists := m.Banned[rb.Name]; exists {
+			return nil, errors.Errorf("multiple banned stanzas specified for %s, can only specify one", rb.Name)
+		}
+		if m.Banned == nil {
+			m.Banned = make(map[string]string)
+		}
+		m.Banned[rb.Name] = rb.Reason
+	}
+	for _, rs := range raw.Sigstore {
+		pr := gps.ProjectRoot(rs.Name)
+		if _, exists := m.Sigstore[pr]; exists {
+			return nil, errors.Errorf("multiple sigstore stanzas specified for %s, can only specify one", pr)
+		}
+		if m.Sigstore == nil {
+			m.Sigstore = make(map[gps.ProjectRoot]SigstorePolicy)
+		}
+		m.Sigstore[pr] = SigstorePolicy{
+			Identity: rs.Identity,
+			Issuer:   rs.Issuer,
+			Enforce:  rs.Enforce,
+		}
+	}
 
 	for i := 0; i < len(raw.Constraints); i++ {
-		name, prj, err := toProject(raw.Constraints[i])
+		rp, err := expandVersionRefs(raw.Constraints[i], m.Versions)
+		if err != nil {
+			return nil, err
+		}
+		if rp != raw.Constraints[i] {
+			if m.constraintRefs == nil {
+				m.constraintRefs = make(map[gps.ProjectRoot]rawProject)
+			}
+			m.constraintRefs[gps.ProjectRoot(rp.Name)] = raw.Constraints[i]
+		}
+
+		name, prj, err := toProject(rp)
 		if err != nil {
 			return nil, err
 		}
@@ -378,10 +944,24 @@ func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 			return nil, errors.Errorf("multiple dependencies specified for %s, can only specify one", name)
 		}
 		m.Constraints[name] = prj
+		if rp.Digest != "" {
+			m.ConstraintDigests[name] = rp.Digest
+		}
 	}
 
 	for i := 0; i < len(raw.Overrides); i++ {
-		name, prj, err := toProject(raw.Overrides[i])
+		rp, err := expandVersionRefs(raw.Overrides[i], m.Versions)
+		if err != nil {
+			return nil, err
+		}
+		if rp != raw.Overrides[i] {
+			if m.overrideRefs == nil {
+				m.overrideRefs = make(map[gps.ProjectRoot]rawProject)
+			}
+			m.overrideRefs[gps.ProjectRoot(rp.Name)] = raw.Overrides[i]
+		}
+
+		name, prj, err := toProject(rp)
 		if err != nil {
 			return nil, err
 		}
@@ -397,6 +977,18 @@ func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 		return nil, errors.Wrap(err, "unable to load TomlTree from string")
 	}
 
+	m.Meta = metadataFromTree(tree)
+	m.ConstraintMeta = projectMetadataFromTree(tree, "constraint")
+	m.OverrideMeta = projectMetadataFromTree(tree, "override")
+	m.ConstraintGroups = projectListsFromTree(tree, "constraint", "group")
+	oses := projectListsFromTree(tree, "constraint", "os")
+	arches := projectListsFromTree(tree, "constraint", "arch")
+	for pr := range m.Constraints {
+		if len(oses[pr]) > 0 || len(arches[pr]) > 0 {
+			m.ConstraintPlatforms[pr] = Platform{OS: oses[pr], Arch: arches[pr]}
+		}
+	}
+
 	iprunemap := tree.Get("prune")
 	if iprunemap == nil {
 		return m, nil
@@ -432,26 +1024,36 @@ func fromRawPruneOptions(prunemap map[string]interface{}) gps.CascadingPruneOpti
 		return pvfalse
 	}
 
-	if projprunes, has := prunemap["project"]; has {
-		for _, proj := range projprunes.([]interface{}) {
-			var pr gps.ProjectRoot
-			// This should be redundant, but being explicit doesn't hurt.
-			pos := gps.PruneOptionSet{NestedVendor: pvtrue}
-
-			for key, val := range proj.(map[string]interface{}) {
-				switch key {
-				case "name":
-					pr = gps.ProjectRoot(val.(string))
-				case pruneOptionNonGo:
-					pos.NonGoFiles = trinary(val)
-				case pruneOptionGoTests:
-					pos.GoTests = trinary(val)
-				case pruneOptionUnusedPackages:
-					pos.UnusedPackages = trinary(val)
-				}
+	projOptions := func(proj map[string]interface{}) (pr gps.ProjectRoot, pos gps.PruneOptionSet) {
+		// This should be redundant, but being explicit doesn't hurt.
+		pos = gps.PruneOptionSet{NestedVendor: pvtrue}
+
+		for key, val := range proj {
+			switch key {
+			case "name":
+				pr = gps.ProjectRoot(val.(string))
+			case pruneOptionNonGo:
+				pos.NonGoFiles = trinary(val)
+			case pruneOptionGoTests:
+				pos.GoTests = trinary(val)
+			case pruneOptionUnusedPackages:
+				pos.UnusedPackages = trinary(val)
 			}
+		}
+		return pr, pos
+	}
+
+	switch projprunes := prunemap["project"].(type) {
+	case []interface{}:
+		for _, proj := range projprunes {
+			pr, pos := projOptions(proj.(map[string]interface{}))
 			opts.PerProjectOptions[pr] = pos
 		}
+	case map[string]interface{}:
+		for name, proj := range projprunes {
+			_, pos := projOptions(proj.(map[string]interface{}))
+			opts.PerProjectOptions[gps.ProjectRoot(name)] = pos
+		}
 	}
 
 	return opts
@@ -481,6 +1083,45 @@ func toRawPruneOptions(co gps.CascadingPruneOptions) rawPruneOptions {
 	return raw
 }
 
+// versionRefPattern matches references to named versions, e.g. ${k8s}.
+var versionRefPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// expandVersionRefs replaces the references to named versions in the
+// version, branch and revision rules of raw with their values from vars. An
+// error is returned if a reference names an undeclared version.
+func expandVersionRefs(raw rawProject, vars map[string]string) (rawProject, error) {
+	var err error
+	expand := func(s string) string {
+		return versionRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+			name := versionRefPattern.FindStringSubmatch(ref)[1]
+			val, has := vars[name]
+			if !has && err == nil {
+				err = errors.Errorf("%s refers to %q, which is not declared in %q", raw.Name, name, "versions")
+			}
+			return val
+		})
+	}
+
+	raw.Version = expand(raw.Version)
+	raw.Branch = expand(raw.Branch)
+	raw.Revision = expand(raw.Revision)
+	return raw, err
+}
+
+// withVersionRefs returns the rule for the project in ref, as it was written
+// with references to named versions, if it still expands to raw. Otherwise,
+// raw is returned unchanged.
+func withVersionRefs(raw rawProject, refs map[gps.ProjectRoot]rawProject, vars map[string]string) rawProject {
+	ref, has := refs[gps.ProjectRoot(raw.Name)]
+	if !has {
+		return raw
+	}
+	if expanded, err := expandVersionRefs(ref, vars); err != nil || expanded != raw {
+		return raw
+	}
+	return ref
+}
+
 // toProject interprets the string representations of project information held in
 // a rawProject, converting them into a proper gps.ProjectProperties. An
 // error is returned if the rawProject contains some invalid combination -
@@ -511,7 +1152,10 @@ func toProject(raw rawProject) (n gps.ProjectRoot, pp gps.ProjectProperties, err
 		pp.Constraint = gps.Any()
 	}
 
-	pp.Source = raw.Source
+	pp.Source, err = gps.JoinSubdir(raw.Source, raw.Subdir)
+	if err != nil {
+		return n, pp, errors.Wrapf(err, "invalid subdir for %s", n)
+	}
 
 	return n, pp, nil
 }
@@ -520,9 +1164,181 @@ func toProject(raw rawProject) (n gps.ProjectRoot, pp gps.ProjectProperties, err
 func (m *Manifest) MarshalTOML() ([]byte, error) {
 	raw := m.toRaw()
 	var buf bytes.Buffer
-	enc := toml.NewEncoder(&buf).ArraysWithOneElementPerLine(true)
-	err := enc.Encode(raw)
-	return buf.Bytes(), errors.Wrap(err, "unable to marshal the lock to a TOML string")
+
+	// Stanzas that may carry metadata are encoded one at a time so that their
+	// metadata can be written alongside them. Everything is written in the
+	// order in which the encoder would have placed it, except for the schema
+	// version, which comes first.
+	err := encodeTOML(&buf, struct {
+		SchemaVersion int `toml:"schema-version,omitempty"`
+	}{m.SchemaVersion})
+	if err == nil {
+		err = encodeTOML(&buf, rawManifest{AuditLog: raw.AuditLog, BazelMacro: raw.BazelMacro, Ignored: raw.Ignored, Required: raw.Required, NoVerify: raw.NoVerify})
+	}
+	if err == nil {
+		err = encodeTOML(&buf, rawManifest{Banned: raw.Banned})
+	}
+	for i := 0; err == nil && i < len(raw.Constraints); i++ {
+		pr := gps.ProjectRoot(raw.Constraints[i].Name)
+		err = encodeTOMLWithComment(&buf, rawManifest{Constraints: raw.Constraints[i : i+1]}, m.ConstraintComments[pr])
+		if err == nil {
+			writeNestedList(&buf, "arch", m.ConstraintPlatforms[pr].Arch)
+			writeNestedList(&buf, "group", m.ConstraintGroups[pr])
+			writeNestedList(&buf, "os", m.ConstraintPlatforms[pr].OS)
+			err = writeNestedMetadata(&buf, "constraint", m.ConstraintMeta[pr])
+		}
+	}
+	if err == nil {
+		err = encodeTOML(&buf, rawManifest{Hooks: raw.Hooks})
+	}
+	if err == nil {
+		err = writeMetadata(&buf, m.Meta)
+	}
+	for i := 0; err == nil && i < len(raw.Overrides); i++ {
+		err = encodeTOML(&buf, rawManifest{Overrides: raw.Overrides[i : i+1]})
+		if err == nil {
+			err = writeNestedMetadata(&buf, "override", m.OverrideMeta[gps.ProjectRoot(raw.Overrides[i].Name)])
+		}
+	}
+	if err == nil {
+		err = encodeTOML(&buf, rawManifest{PruneOptions: raw.PruneOptions})
+	}
+	if err == nil {
+		err = encodeTOML(&buf, rawManifest{Signatures: raw.Signatures})
+	}
+	if err == nil {
+		err = encodeTOML(&buf, rawManifest{Sigstore: raw.Sigstore})
+	}
+	if err == nil && raw.LicensePolicy != nil {
+		policy := *raw.LicensePolicy
+		policy.Exceptions = nil
+		err = encodeTOML(&buf, rawManifest{LicensePolicy: &policy})
+		if err == nil {
+			writeStringListTable(&buf, "license-policy.exceptions", raw.LicensePolicy.Exceptions)
+		}
+	}
+	if err == nil {
+		writeStringTable(&buf, "sources", raw.Sources)
+	}
+	if err == nil {
+		writeStringTable(&buf, "protocols", raw.Protocols)
+	}
+	if err == nil {
+		err = encodeTOML(&buf, rawManifest{Versions: raw.Versions})
+	}
+
+	return buf.Bytes(), errors.Wrap(err, "unable to marshal the manifest to a TOML string")
+}
+
+// writeStringTable writes t to buf as the table name, such as [sources]. The
+// encoder would split keys on their dots, so the table is written by hand,
+// quoting every key.
+func writeStringTable(buf *bytes.Buffer, name string, t map[string]string) {
+	if len(t) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(buf, "\n[%s]\n", name)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "  %s = %s\n", strconv.Quote(k), strconv.Quote(t[k]))
+	}
+}
+
+// writeStringListTable writes t to buf as the table name, quoting every key,
+// as writeStringTable does.
+func writeStringListTable(buf *bytes.Buffer, name string, t map[string][]string) {
+	if len(t) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(buf, "\n[%s]\n", name)
+	for _, k := range keys {
+		quoted := make([]string, len(t[k]))
+		for i, v := range t[k] {
+			quoted[i] = strconv.Quote(v)
+		}
+		fmt.Fprintf(buf, "  %s = [%s]\n", strconv.Quote(k), strings.Join(quoted, ", "))
+	}
+}
+
+// encodeTOMLWithComment encodes v into buf as encodeTOML does, preceded by the
+// lines of comment.
+func encodeTOMLWithComment(buf *bytes.Buffer, v interface{}, comment []string) error {
+	if len(comment) == 0 {
+		return encodeTOML(buf, v)
+	}
+
+	var enc bytes.Buffer
+	if err := encodeTOML(&enc, v); err != nil {
+		return err
+	}
+	// The encoder separates tables with a leading blank line, which must stay
+	// ahead of the comment.
+	buf.WriteString("\n")
+	for _, line := range comment {
+		buf.WriteString(strings.TrimSpace("# " + line))
+		buf.WriteString("\n")
+	}
+	buf.Write(bytes.TrimPrefix(enc.Bytes(), []byte("\n")))
+	return nil
+}
+
+// projectListsFromTree returns the string list held in field by each element
+// of the array of tables named key in t, keyed by the element's name.
+func projectListsFromTree(t *toml.Tree, key, field string) map[gps.ProjectRoot][]string {
+	pl := make(map[gps.ProjectRoot][]string)
+	elems, _ := t.Get(key).([]*toml.Tree)
+	for _, elem := range elems {
+		name, _ := elem.Get("name").(string)
+		vals, _ := elem.Get(field).([]interface{})
+		for _, v := range vals {
+			pl[gps.ProjectRoot(name)] = append(pl[gps.ProjectRoot(name)], v.(string))
+		}
+	}
+	return pl
+}
+
+// writeNestedList writes vals to buf as the named field of the element of an
+// array of tables that was most recently written to buf. It is written on one
+// line, as the encoder would put each value on a line of its own.
+func writeNestedList(buf *bytes.Buffer, field string, vals []string) {
+	if len(vals) == 0 {
+		return
+	}
+
+	quoted := make([]string, len(vals))
+	for i, v := range vals {
+		quoted[i] = strconv.Quote(v)
+	}
+	fmt.Fprintf(buf, "  %s = [%s]\n", field, strings.Join(quoted, ", "))
+}
+
+// GroupMembers returns the roots of the projects whose constraints place them
+// in group, sorted.
+func (m *Manifest) GroupMembers(group string) []gps.ProjectRoot {
+	var prs []gps.ProjectRoot
+	for pr, groups := range m.ConstraintGroups {
+		for _, g := range groups {
+			if g == group {
+				prs = append(prs, pr)
+				break
+			}
+		}
+	}
+	sort.Slice(prs, func(i, j int) bool { return prs[i] < prs[j] })
+	return prs
 }
 
 // toRaw converts the manifest into a representation suitable to write to the manifest file
@@ -532,20 +1348,74 @@ func (m *Manifest) toRaw() rawManifest {
 		Overrides:   make([]rawProject, 0, len(m.Ovr)),
 		Ignored:     m.Ignored,
 		Required:    m.Required,
+		NoVerify:    m.NoVerify,
+		Versions:    m.Versions,
+		Sources:     m.Sources,
+		Protocols:   m.Protocols,
+		AuditLog:    m.AuditLog,
+		BazelMacro:  m.BazelMacro,
 	}
 
 	for n, prj := range m.Constraints {
-		raw.Constraints = append(raw.Constraints, toRawProject(n, prj))
+		rp := withVersionRefs(toRawProject(n, prj), m.constraintRefs, m.Versions)
+		rp.Digest = m.ConstraintDigests[n]
+		raw.Constraints = append(raw.Constraints, rp)
 	}
 	sort.Sort(sortedRawProjects(raw.Constraints))
 
 	for n, prj := range m.Ovr {
-		raw.Overrides = append(raw.Overrides, toRawProject(n, prj))
+		raw.Overrides = append(raw.Overrides, withVersionRefs(toRawProject(n, prj), m.overrideRefs, m.Versions))
 	}
 	sort.Sort(sortedRawProjects(raw.Overrides))
 
 	raw.PruneOptions = toRawPruneOptions(m.PruneOptions)
 
+	if len(m.Hooks.PreEnsure) > 0 || len(m.Hooks.PostEnsure) > 0 || len(m.Hooks.PostEnsureWebhooks) > 0 {
+		raw.Hooks = &rawHooks{
+			PreEnsure:          m.Hooks.PreEnsure,
+			PostEnsure:         m.Hooks.PostEnsure,
+			PostEnsureWebhooks: m.Hooks.PostEnsureWebhooks,
+		}
+	}
+
+	if m.Signatures.Keyring != "" || len(m.Signatures.Projects) > 0 {
+		raw.Signatures = &rawSignatures{
+			Keyring:  m.Signatures.Keyring,
+			Projects: m.Signatures.Projects,
+		}
+	}
+
+	if lp := m.LicensePolicy; len(lp.Allow) > 0 || len(lp.Deny) > 0 || len(lp.Exceptions) > 0 || lp.Warn {
+		raw.LicensePolicy = &rawLicensePolicy{
+			Allow: lp.Allow,
+			Deny:  lp.Deny,
+		}
+		if lp.Warn {
+			raw.LicensePolicy.OnViolation = "warn"
+		}
+		for pr, ids := range lp.Exceptions {
+			if raw.LicensePolicy.Exceptions == nil {
+				raw.LicensePolicy.Exceptions = make(map[string][]string)
+			}
+			raw.LicensePolicy.Exceptions[string(pr)] = ids
+		}
+	}
+
+	for pr, sp := range m.Sigstore {
+		raw.Sigstore = append(raw.Sigstore, rawSigstore{
+			Name:     string(pr),
+			Identity: sp.Identity,
+			Issuer:   sp.Issuer,
+			Enforce:  sp.Enforce,
+		})
+	}
+	sort.Slice(raw.Sigstore, func(i, j int) bool { return raw.Sigstore[i].Name < raw.Sigstore[j].Name })
+
+	for name, reason := range m.Banned {
+		raw.Banned = append(raw.Banned, rawBanned{Name: name, Reason: reason})
+	}
+	sort.Slice(raw.Banned, func(i, j int) bool { return raw.Banned[i].Name < raw.Banned[j].Name })
+
 	return raw
 }
 
@@ -568,9 +1438,9 @@ func (s sortedRawProjects) Less(i, j int) bool {
 
 func toRawProject(name gps.ProjectRoot, project gps.ProjectProperties) rawProject {
 	raw := rawProject{
-		Name:   string(name),
-		Source: project.Source,
+		Name: string(name),
 	}
+	raw.Source, raw.Subdir = gps.SplitSubdir(project.Source)
 
 	if v, ok := project.Constraint.(gps.Version); ok {
 		switch v.Type() {
@@ -625,6 +1495,64 @@ func (m *Manifest) HasConstraintsOn(root gps.ProjectRoot) bool {
 	return false
 }
 
+// BazelMacroFile returns the file, relative to the project root, and the
+// name of the macro that BazelMacro names.
+func (m *Manifest) BazelMacroFile() (file, macro string) {
+	i := strings.LastIndex(m.BazelMacro, "%")
+	if i < 0 {
+		return "", ""
+	}
+	return m.BazelMacro[:i], m.BazelMacro[i+1:]
+}
+
+// bazelMacroName matches the names of Starlark macros.
+var bazelMacroName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validBazelMacro reports whether v names a file and a macro in it, as
+// "file.bzl%macro".
+func validBazelMacro(v string) bool {
+	i := strings.LastIndex(v, "%")
+	return i > 0 && bazelMacroName.MatchString(v[i+1:])
+}
+
+// IsVerified reports whether the vendored contents of the project at root
+// are subject to verification, i.e. root is not in the noverify list.
+func (m *Manifest) IsVerified(root gps.ProjectRoot) bool {
+	for _, pr := range m.NoVerify {
+		if gps.ProjectRoot(pr) == root {
+			return false
+		}
+	}
+	return true
+}
+
+// SourceMap returns the project root prefixes declared in [sources], mapped to
+// their sources. It implements gps.SourceMapper.
+func (m *Manifest) SourceMap() map[string]string {
+	return m.Sources
+}
+
+// ProtocolMap returns the hosts and project root prefixes declared in
+// [protocols], mapped to their protocols. It implements gps.ProtocolMapper.
+func (m *Manifest) ProtocolMap() map[string]string {
+	return m.Protocols
+}
+
+// BannedProjects returns the project roots and prefixes declared in
+// [[banned]] stanzas, mapped to the reasons they are banned. It implements
+// gps.BanLister.
+func (m *Manifest) BannedProjects() map[string]string {
+	return m.Banned
+}
+
+// AppliesOn reports whether the project at root is needed on the platform
+// given by goos and goarch, i.e. its constraint, if any, is not restricted to
+// other platforms.
+func (m *Manifest) AppliesOn(root gps.ProjectRoot, goos, goarch string) bool {
+	pl, has := m.ConstraintPlatforms[root]
+	return !has || pl.Matches(goos, goarch)
+}
+
 // RequiredPackages returns a set of import paths to require.
 func (m *Manifest) RequiredPackages() map[string]bool {
 	if len(m.Required) == 0 {
@@ -0,0 +1,140 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// CurrentManifestSchemaVersion is the version of the manifest layout that this
+// version of dep reads and writes. Manifests without a schema-version predate
+// versioning, and have version 0.
+const CurrentManifestSchemaVersion = 1
+
+// manifestMigrations holds the steps that upgrade a manifest from each schema
+// version to the next: manifestMigrations[v] upgrades a manifest from version v
+// to v+1. The steps rewrite the text of the manifest, so that its comments and
+// formatting survive, and describe each change they make.
+var manifestMigrations = []func(data []byte) ([]byte, []string){
+	migrateManifestV0,
+}
+
+// legacyDependenciesPattern matches the headers of [[dependencies]] stanzas,
+// and of the tables nested in them.
+var legacyDependenciesPattern = regexp.MustCompile(`(?m)^(\s*\[\[?\s*)dependencies\b`)
+
+// migrateManifestV0 renames [[dependencies]], the original name of
+// [[constraint]], which dep otherwise ignores as an unknown field.
+func migrateManifestV0(data []byte) ([]byte, []string) {
+	if !legacyDependenciesPattern.Match(data) {
+		return data, nil
+	}
+	data = legacyDependenciesPattern.ReplaceAll(data, []byte("${1}constraint"))
+	return data, []string{"renamed [[dependencies]] to [[constraint]]"}
+}
+
+// manifestSchemaVersion returns the schema version of the manifest in data.
+// Manifests that cannot be parsed are reported to have version 0, leaving the
+// error to be reported when they are read.
+func manifestSchemaVersion(data []byte) (int, error) {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return 0, nil
+	}
+
+	switch v := tree.Get("schema-version").(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		if v < 0 {
+			return 0, errInvalidSchemaVersion
+		}
+		if v > CurrentManifestSchemaVersion {
+			return 0, errors.Errorf("manifest schema version %d is newer than this version of dep supports (%d), upgrade dep to use it", v, CurrentManifestSchemaVersion)
+		}
+		return int(v), nil
+	default:
+		return 0, errInvalidSchemaVersion
+	}
+}
+
+// upgradeManifest applies the migrations from version v of the manifest in
+// data to the current version, without recording the new version in it.
+func upgradeManifest(data []byte, v int) ([]byte, []string) {
+	var changes []string
+	for ; v < CurrentManifestSchemaVersion; v++ {
+		var ch []string
+		data, ch = manifestMigrations[v](data)
+		changes = append(changes, ch...)
+	}
+	return data, changes
+}
+
+// schemaVersionPattern matches the line holding the schema-version field.
+var schemaVersionPattern = regexp.MustCompile(`(?m)^schema-version\s*=.*$`)
+
+// MigrateManifest upgrades the manifest in data to the current schema version,
+// and records that version in it. It returns the upgraded manifest, along with
+// a description of each change made to it. A manifest that is already current
+// is returned as is.
+func MigrateManifest(data []byte) ([]byte, []string, error) {
+	v, err := manifestSchemaVersion(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v == CurrentManifestSchemaVersion {
+		return data, nil, nil
+	}
+
+	data, changes := upgradeManifest(data, v)
+
+	field := []byte(fmt.Sprintf("schema-version = %d", CurrentManifestSchemaVersion))
+	if schemaVersionPattern.Match(data) {
+		data = schemaVersionPattern.ReplaceAll(data, field)
+	} else {
+		data = insertTopLevelField(data, field)
+	}
+	changes = append(changes, fmt.Sprintf("set schema-version to %d", CurrentManifestSchemaVersion))
+
+	if _, _, err := readManifest(bytes.NewReader(data)); err != nil {
+		return nil, nil, errors.Wrap(err, "the upgraded manifest is invalid")
+	}
+	return data, changes, nil
+}
+
+// insertTopLevelField inserts field, a TOML key/value line, into data ahead of
+// its first line that is neither blank nor a comment, so that it cannot fall
+// within a table.
+func insertTopLevelField(data, field []byte) []byte {
+	var pos int
+	for pos < len(data) {
+		end := bytes.IndexByte(data[pos:], '\n')
+		if end < 0 {
+			end = len(data) - pos
+		} else {
+			end++
+		}
+		line := bytes.TrimSpace(data[pos : pos+end])
+		if len(line) != 0 && line[0] != '#' {
+			break
+		}
+		pos += end
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:pos])
+	if pos > 0 && data[pos-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	buf.Write(field)
+	buf.WriteString("\n\n")
+	buf.Write(data[pos:])
+	return buf.Bytes()
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifestMigrationsCurrent(t *testing.T) {
+	if len(manifestMigrations) != CurrentManifestSchemaVersion {
+		t.Fatalf("there are %d manifest migrations, but the current schema version is %d", len(manifestMigrations), CurrentManifestSchemaVersion)
+	}
+}
+
+func TestMigrateManifest(t *testing.T) {
+	in := `# Gopkg.toml example
+
+required = ["github.com/foo/bar/cmd/bar"]
+
+[[dependencies]]
+  name = "github.com/foo/bar"
+  version = "1.0.0"
+
+  [dependencies.metadata]
+    reason = "bar"
+`
+	want := `# Gopkg.toml example
+
+schema-version = 1
+
+required = ["github.com/foo/bar/cmd/bar"]
+
+[[constraint]]
+  name = "github.com/foo/bar"
+  version = "1.0.0"
+
+  [constraint.metadata]
+    reason = "bar"
+`
+	got, changes, err := MigrateManifest([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("unexpected migrated manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+	wantChanges := []string{"renamed [[dependencies]] to [[constraint]]", "set schema-version to 1"}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("unexpected changes:\n\t(GOT): %q\n\t(WNT): %q", changes, wantChanges)
+	}
+
+	// Migrating again changes nothing.
+	again, changes, err := MigrateManifest(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(got) || len(changes) != 0 {
+		t.Errorf("migrating a current manifest changed it:\n%s\n%q", again, changes)
+	}
+
+	// An explicit version 0 is replaced.
+	got, _, err = MigrateManifest([]byte("schema-version = 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "schema-version = 1\n" {
+		t.Errorf("unexpected migrated manifest:\n%s", got)
+	}
+}
+
+func TestReadManifestSchemaVersion(t *testing.T) {
+	m, warns, err := readManifest(strings.NewReader(`[[dependencies]]
+  name = "github.com/foo/bar"
+  version = "1.0.0"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := m.Constraints["github.com/foo/bar"]; !has {
+		t.Error("legacy [[dependencies]] were not read as constraints")
+	}
+	if m.SchemaVersion != 0 {
+		t.Errorf("expected schema version 0, got %d", m.SchemaVersion)
+	}
+	if len(warns) != 1 || !strings.Contains(warns[0].Error(), "dep migrate-manifest") {
+		t.Errorf("expected a warning to migrate the manifest, got %v", warns)
+	}
+
+	m, _, err = readManifest(strings.NewReader("schema-version = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(got)) != "schema-version = 1" {
+		t.Errorf("schema version did not survive a rewrite:\n%s", got)
+	}
+
+	for _, in := range []string{"schema-version = 2\n", "schema-version = -1\n", "schema-version = \"1\"\n"} {
+		if _, _, err := readManifest(strings.NewReader(in)); err == nil {
+			t.Errorf("expected an error reading %q", in)
+		}
+	}
+}
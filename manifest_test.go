@@ -45,7 +45,8 @@ func TestReadManifest(t *testing.T) {
 				Constraint: gps.NewBranch("master"),
 			},
 		},
-		Ignored: []string{"github.com/foo/bar"},
+		Ignored:  []string{"github.com/foo/bar"},
+		NoVerify: []string{"github.com/babble/brook"},
 		PruneOptions: gps.CascadingPruneOptions{
 			DefaultOptions:    gps.PruneNestedVendorDirs | gps.PruneNonGoFiles,
 			PerProjectOptions: make(map[gps.ProjectRoot]gps.PruneOptionSet),
@@ -61,6 +62,9 @@ func TestReadManifest(t *testing.T) {
 	if !reflect.DeepEqual(got.Ignored, want.Ignored) {
 		t.Error("Valid manifest's ignored did not parse as expected")
 	}
+	if !reflect.DeepEqual(got.NoVerify, want.NoVerify) {
+		t.Error("Valid manifest's noverify did not parse as expected")
+	}
 	if !reflect.DeepEqual(got.PruneOptions, want.PruneOptions) {
 		t.Error("Valid manifest's prune options did not parse as expected")
 		t.Error(got.PruneOptions, want.PruneOptions)
@@ -86,6 +90,7 @@ func TestWriteManifest(t *testing.T) {
 		Constraint: gps.NewBranch("master"),
 	}
 	m.Ignored = []string{"github.com/foo/bar"}
+	m.NoVerify = []string{"github.com/babble/brook"}
 	m.PruneOptions = gps.CascadingPruneOptions{
 		DefaultOptions:    gps.PruneNestedVendorDirs | gps.PruneNonGoFiles,
 		PerProjectOptions: make(map[gps.ProjectRoot]gps.PruneOptionSet),
@@ -107,6 +112,607 @@ func TestWriteManifest(t *testing.T) {
 	}
 }
 
+func TestReadWriteManifestHooks(t *testing.T) {
+	in := `[hooks]
+  post-ensure = [
+    "go generate ./...",
+    "./scripts/patch-vendor.sh"
+  ]
+  post-ensure-webhooks = ["https://hooks.slack.com/services/${SLACK_HOOK}"]
+  pre-ensure = ["make check"]
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := Hooks{
+		PreEnsure:          []string{"make check"},
+		PostEnsure:         []string{"go generate ./...", "./scripts/patch-vendor.sh"},
+		PostEnsureWebhooks: []string{"https://hooks.slack.com/services/${SLACK_HOOK}"},
+	}
+	if !reflect.DeepEqual(m.Hooks, want) {
+		t.Fatalf("hooks did not parse as expected:\n\t(GOT) %v\n\t(WNT) %v", m.Hooks, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if !strings.Contains(string(got), in) {
+		t.Fatalf("hooks did not marshal to TOML as expected:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+
+	got, err = NewManifest().MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.Contains(string(got), "hooks") {
+		t.Fatalf("manifest without hooks should not marshal a hooks table:\n%s", got)
+	}
+}
+
+func TestReadWriteManifestSignatures(t *testing.T) {
+	in := `[signatures]
+  keyring = "keys/trusted.gpg"
+  projects = [
+    "github.com/foo/bar",
+    "github.com/foo/baz"
+  ]
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := SignaturePolicy{
+		Keyring:  "keys/trusted.gpg",
+		Projects: []string{"github.com/foo/bar", "github.com/foo/baz"},
+	}
+	if !reflect.DeepEqual(m.Signatures, want) {
+		t.Fatalf("signatures did not parse as expected:\n\t(GOT) %v\n\t(WNT) %v", m.Signatures, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if !strings.Contains(string(got), in) {
+		t.Fatalf("signatures did not marshal to TOML as expected:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+
+	got, err = NewManifest().MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.Contains(string(got), "signatures") {
+		t.Fatalf("manifest without signatures should not marshal a signatures table:\n%s", got)
+	}
+}
+
+func TestReadWriteManifestSigstore(t *testing.T) {
+	in := `[[sigstore]]
+  enforce = true
+  identity = "https://github.com/foo/bar/.github/workflows/release.yml@refs/heads/main"
+  issuer = "https://token.actions.githubusercontent.com"
+  name = "github.com/foo/bar"
+
+[[sigstore]]
+  identity = "maintainer@example.com"
+  issuer = "https://accounts.google.com"
+  name = "github.com/foo/baz"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := map[gps.ProjectRoot]SigstorePolicy{
+		"github.com/foo/bar": {
+			Identity: "https://github.com/foo/bar/.github/workflows/release.yml@refs/heads/main",
+			Issuer:   "https://token.actions.githubusercontent.com",
+			Enforce:  true,
+		},
+		"github.com/foo/baz": {
+			Identity: "maintainer@example.com",
+			Issuer:   "https://accounts.google.com",
+		},
+	}
+	if !reflect.DeepEqual(m.Sigstore, want) {
+		t.Fatalf("sigstore stanzas did not parse as expected:\n\t(GOT) %v\n\t(WNT) %v", m.Sigstore, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if !strings.Contains(string(got), in) {
+		t.Fatalf("sigstore stanzas did not marshal to TOML as expected:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+
+	_, _, err = readManifest(strings.NewReader(in + `
+[[sigstore]]
+  identity = "other@example.com"
+  issuer = "https://accounts.google.com"
+  name = "github.com/foo/bar"
+`))
+	if err == nil {
+		t.Fatal("expected an error for multiple sigstore stanzas for one project")
+	}
+}
+
+func TestReadWriteManifestBanned(t *testing.T) {
+	in := `[[banned]]
+  name = "github.com/foo"
+
+[[banned]]
+  name = "github.com/sirupsen/logrus"
+  reason = "relicensed; use github.com/example/log"
+
+[[constraint]]
+  branch = "master"
+  name = "golang.org/x/net"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := map[string]string{
+		"github.com/foo":             "",
+		"github.com/sirupsen/logrus": "relicensed; use github.com/example/log",
+	}
+	if !reflect.DeepEqual(m.BannedProjects(), want) {
+		t.Fatalf("banned stanzas did not parse as expected:\n\t(GOT) %v\n\t(WNT) %v", m.BannedProjects(), want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("banned stanzas did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+
+	_, _, err = readManifest(strings.NewReader(in + `
+[[banned]]
+  name = "github.com/foo"
+`))
+	if err == nil {
+		t.Fatal("expected an error for multiple banned stanzas for one prefix")
+	}
+}
+
+func TestReadWriteManifestDigests(t *testing.T) {
+	in := `[[constraint]]
+  digest = "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+  name = "github.com/foo/bar"
+  version = "1.0.0"
+
+[[constraint]]
+  name = "github.com/foo/baz"
+  version = "2.0.0"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := map[gps.ProjectRoot]string{"github.com/foo/bar": "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}
+	if !reflect.DeepEqual(m.ConstraintDigests, want) {
+		t.Fatalf("digests did not parse as expected:\n\t(GOT) %v\n\t(WNT) %v", m.ConstraintDigests, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("digests did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+}
+
+func TestReadWriteManifestLicensePolicy(t *testing.T) {
+	in := `[license-policy]
+  allow = [
+    "MIT",
+    "Apache-2.0"
+  ]
+  deny = ["AGPL-3.0"]
+  on-violation = "warn"
+
+[license-policy.exceptions]
+  "github.com/foo/bar" = ["MPL-2.0"]
+  "gopkg.in/yaml.v2" = ["LGPL-3.0", "NOASSERTION"]
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := LicensePolicy{
+		Allow: []string{"MIT", "Apache-2.0"},
+		Deny:  []string{"AGPL-3.0"},
+		Exceptions: map[gps.ProjectRoot][]string{
+			"github.com/foo/bar": {"MPL-2.0"},
+			"gopkg.in/yaml.v2":   {"LGPL-3.0", "NOASSERTION"},
+		},
+		Warn: true,
+	}
+	if !reflect.DeepEqual(m.LicensePolicy, want) {
+		t.Fatalf("license policy did not parse as expected:\n\t(GOT) %v\n\t(WNT) %v", m.LicensePolicy, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if !strings.Contains(string(got), in) {
+		t.Fatalf("license policy did not marshal to TOML as expected:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+}
+
+func TestReadWriteManifestAuditLog(t *testing.T) {
+	in := `schema-version = 1
+audit-log = "deps/audit.log"
+
+[[constraint]]
+  branch = "master"
+  name = "golang.org/x/net"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+	if m.AuditLog != "deps/audit.log" {
+		t.Fatalf("unexpected audit log %q", m.AuditLog)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("audit log did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+}
+
+func TestReadWriteManifestBazelMacro(t *testing.T) {
+	in := `schema-version = 1
+bazel-macro = "bazel/deps.bzl%go_dependencies"
+
+[[constraint]]
+  branch = "master"
+  name = "golang.org/x/net"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+	if file, macro := m.BazelMacroFile(); file != "bazel/deps.bzl" || macro != "go_dependencies" {
+		t.Fatalf("unexpected bazel macro %q in %q", macro, file)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("bazel macro did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+}
+
+func TestReadWriteManifestVersions(t *testing.T) {
+	in := `[[constraint]]
+  name = "k8s.io/api"
+  version = "kubernetes-${k8s}"
+
+[[constraint]]
+  name = "k8s.io/client-go"
+  version = "8.0.0"
+
+[[override]]
+  name = "k8s.io/apimachinery"
+  version = "kubernetes-${k8s}"
+
+[versions]
+  k8s = "1.11.3"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := gps.NewVersion("kubernetes-1.11.3")
+	if got := m.Constraints["k8s.io/api"].Constraint; got.String() != want.String() {
+		t.Errorf("constraint reference was not expanded: got %s, want %s", got, want)
+	}
+	if got := m.Ovr["k8s.io/apimachinery"].Constraint; got.String() != want.String() {
+		t.Errorf("override reference was not expanded: got %s, want %s", got, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("references did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+
+	// A rule that no longer matches its reference is written out in full.
+	m.Constraints["k8s.io/api"] = gps.ProjectProperties{Constraint: gps.NewVersion("kubernetes-1.12.0")}
+	got, err = m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if !strings.Contains(string(got), `version = "kubernetes-1.12.0"`) {
+		t.Fatalf("changed constraint was not written out in full:\n%s", got)
+	}
+}
+
+func TestReadManifestUndeclaredVersion(t *testing.T) {
+	in := `[[constraint]]
+  name = "k8s.io/api"
+  version = "kubernetes-${k8s}"
+`
+	_, _, err := readManifest(strings.NewReader(in))
+	if err == nil || !strings.Contains(err.Error(), `refers to "k8s"`) {
+		t.Fatalf("expected an error for an undeclared version, got %v", err)
+	}
+}
+
+func TestReadWriteManifestSources(t *testing.T) {
+	in := `[[constraint]]
+  branch = "master"
+  name = "golang.org/x/net"
+
+[sources]
+  "golang.org/x" = "git.example.com/mirrors/golang"
+
+[versions]
+  k8s = "1.11.3"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := map[string]string{"golang.org/x": "git.example.com/mirrors/golang"}
+	if !reflect.DeepEqual(m.SourceMap(), want) {
+		t.Errorf("unexpected source map:\n\t(GOT): %v\n\t(WNT): %v", m.SourceMap(), want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("sources did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+}
+
+func TestReadWriteManifestProtocols(t *testing.T) {
+	in := `[[constraint]]
+  branch = "master"
+  name = "github.com/corp/lib"
+
+[sources]
+  "golang.org/x" = "git.example.com/mirrors/golang"
+
+[protocols]
+  "github.com" = "https"
+  "github.com/corp" = "ssh"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := map[string]string{"github.com": "https", "github.com/corp": "ssh"}
+	if !reflect.DeepEqual(m.ProtocolMap(), want) {
+		t.Errorf("unexpected protocol map:\n\t(GOT): %v\n\t(WNT): %v", m.ProtocolMap(), want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("protocols did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+}
+
+func TestReadWriteManifestSubdir(t *testing.T) {
+	in := `[[constraint]]
+  name = "example.com/foo"
+  source = "github.com/org/mono"
+  subdir = "libs/foo"
+  version = "1.0.0"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := "github.com/org/mono//libs/foo"
+	if got := m.Constraints["example.com/foo"].Source; got != want {
+		t.Errorf("unexpected source:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("subdir did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+
+	for _, bad := range []string{
+		"[[constraint]]\n  name = \"example.com/foo\"\n  subdir = \"libs/foo\"\n",
+		"[[constraint]]\n  name = \"example.com/foo\"\n  source = \"github.com/org/mono\"\n  subdir = \"../foo\"\n",
+	} {
+		if _, _, err := readManifest(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error reading manifest:\n%s", bad)
+		}
+	}
+}
+
+func TestWriteManifestComments(t *testing.T) {
+	m := NewManifest()
+	m.Constraints["github.com/pkg/errors"] = gps.ProjectProperties{
+		Constraint: gps.NewBranch("master"),
+	}
+	m.Constraints["github.com/sdboyer/deptest"] = gps.ProjectProperties{
+		Constraint: gps.Revision("3f4c3bea144e112a69bbe5d8d01c1b09a544253f"),
+	}
+	m.ConstraintComments = map[gps.ProjectRoot][]string{
+		"github.com/pkg/errors": {"Selected by the solver when dep init ran: branch master.", "Imported by github.com/foo/bar."},
+	}
+
+	want := `# Selected by the solver when dep init ran: branch master.
+# Imported by github.com/foo/bar.
+[[constraint]]
+  branch = "master"
+  name = "github.com/pkg/errors"
+
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  revision = "3f4c3bea144e112a69bbe5d8d01c1b09a544253f"
+`
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(want) {
+		t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+
+	// Comments are not read back.
+	rm, _, err := readManifest(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+	if len(rm.ConstraintComments) != 0 {
+		t.Errorf("expected no comments to be read, got %v", rm.ConstraintComments)
+	}
+}
+
+func TestReadWriteManifestGroups(t *testing.T) {
+	in := `[[constraint]]
+  name = "github.com/aws/aws-sdk-go"
+  version = "1.12.0"
+  group = ["aws", "cloud"]
+
+  [constraint.metadata]
+    owner = "infra"
+
+[[constraint]]
+  name = "github.com/aws/aws-xray-sdk-go"
+  group = ["aws"]
+
+[[constraint]]
+  name = "github.com/pkg/errors"
+  version = "0.8.0"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := []gps.ProjectRoot{"github.com/aws/aws-sdk-go", "github.com/aws/aws-xray-sdk-go"}
+	if got := m.GroupMembers("aws"); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected members of group aws:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+	if got := m.GroupMembers("none"); len(got) != 0 {
+		t.Errorf("expected no members of an undeclared group, got %v", got)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("groups did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+}
+
+func TestReadManifestPruneGroups(t *testing.T) {
+	in := `[prune]
+  non-go = true
+
+  [prune.project."github.com/aws/*"]
+    unused-packages = true
+
+  [prune.project."github.com/aws/aws-sdk-go"]
+    non-go = false
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	want := map[gps.ProjectRoot]gps.PruneOptionSet{
+		"github.com/aws/*": {
+			NestedVendor:   pvtrue,
+			UnusedPackages: pvtrue,
+		},
+		"github.com/aws/aws-sdk-go": {
+			NestedVendor: pvtrue,
+			NonGoFiles:   pvfalse,
+		},
+	}
+	if !reflect.DeepEqual(m.PruneOptions.PerProjectOptions, want) {
+		t.Fatalf("unexpected project prune options:\n\t(GOT): %+v\n\t(WNT): %+v", m.PruneOptions.PerProjectOptions, want)
+	}
+
+	if got := m.PruneOptions.PruneOptionsFor("github.com/aws/aws-sdk-go"); got != gps.PruneNestedVendorDirs|gps.PruneUnusedPackages {
+		t.Errorf("unexpected prune options for a project in a group: %d", got)
+	}
+}
+
+func TestReadWriteManifestMetadata(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	golden := "manifest/metadata.toml"
+	mf := h.GetTestFile(golden)
+	defer mf.Close()
+	m, _, err := readManifest(mf)
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	if v, _ := m.Meta.String("codename"); v != "foo" {
+		t.Errorf("unexpected root metadata value %q", v)
+	}
+	if ci, _ := m.Meta.Table("ci"); ci == nil {
+		t.Error("expected nested root metadata table")
+	}
+	if v, _ := m.ConstraintMeta["github.com/babble/brook"].Bool("audited"); !v {
+		t.Error("expected constraint metadata to be read")
+	}
+	if _, has := m.ConstraintMeta["github.com/golang/dep"]; has {
+		t.Error("constraint without metadata should have no metadata entry")
+	}
+	if v, _ := m.OverrideMeta["github.com/golang/dep"].String("reason"); v == "" {
+		t.Error("expected override metadata to be read")
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if want := h.GetTestFileString(golden); string(got) != want {
+		if *test.UpdateGolden {
+			if err = h.WriteTestFile(golden, string(got)); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			t.Errorf("metadata did not round-trip as expected:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+		}
+	}
+}
+
 func TestReadManifestErrors(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -141,86 +747,231 @@ func TestValidateManifest(t *testing.T) {
 		wantError  error
 	}{
 		{
-			name: "valid required",
+			name: "valid required",
+			tomlString: `
+			required = ["github.com/foo/bar"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid required",
+			tomlString: `
+			required = "github.com/foo/bar"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidRequired,
+		},
+		{
+			name: "empty required",
+			tomlString: `
+			required = []
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid required list",
+			tomlString: `
+			required = [1, 2, 3]
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidRequired,
+		},
+		{
+			name: "invalid required format",
+			tomlString: `
+			[[required]]
+			  name = "foo"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidRequired,
+		},
+		{
+			name: "valid ignored",
+			tomlString: `
+			ignored = ["foo"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid ignored",
+			tomlString: `
+			ignored = "foo"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidIgnored,
+		},
+		{
+			name: "empty ignored",
+			tomlString: `
+			ignored = []
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid ignored list",
+			tomlString: `
+			ignored = [1, 2, 3]
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidIgnored,
+		},
+		{
+			name: "invalid ignored format",
 			tomlString: `
-			required = ["github.com/foo/bar"]
+			[[ignored]]
+			  name = "foo"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidIgnored,
+		},
+		{
+			name: "valid noverify",
+			tomlString: `
+			noverify = ["github.com/foo/patched"]
 			`,
 			wantWarn:  []error{},
 			wantError: nil,
 		},
 		{
-			name: "invalid required",
+			name: "invalid noverify",
 			tomlString: `
-			required = "github.com/foo/bar"
+			noverify = "github.com/foo/patched"
 			`,
 			wantWarn:  []error{},
-			wantError: errInvalidRequired,
+			wantError: errInvalidNoVerify,
 		},
 		{
-			name: "empty required",
+			name: "invalid noverify list",
 			tomlString: `
-			required = []
+			noverify = [1, 2, 3]
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidNoVerify,
+		},
+		{
+			name: "valid versions",
+			tomlString: `
+			[versions]
+			  k8s = "1.11.3"
 			`,
 			wantWarn:  []error{},
 			wantError: nil,
 		},
 		{
-			name: "invalid required list",
+			name: "invalid versions",
 			tomlString: `
-			required = [1, 2, 3]
+			versions = ["1.11.3"]
 			`,
 			wantWarn:  []error{},
-			wantError: errInvalidRequired,
+			wantError: errInvalidVersions,
 		},
 		{
-			name: "invalid required format",
+			name: "invalid versions value",
 			tomlString: `
-			[[required]]
-			  name = "foo"
+			[versions]
+			  k8s = 1
 			`,
 			wantWarn:  []error{},
-			wantError: errInvalidRequired,
+			wantError: errInvalidVersions,
 		},
 		{
-			name: "valid ignored",
+			name: "valid constraint groups",
 			tomlString: `
-			ignored = ["foo"]
+			[[constraint]]
+			  name = "github.com/aws/aws-sdk-go"
+			  group = ["aws", "cloud"]
 			`,
 			wantWarn:  []error{},
 			wantError: nil,
 		},
 		{
-			name: "invalid ignored",
+			name: "invalid constraint groups",
 			tomlString: `
-			ignored = "foo"
+			[[constraint]]
+			  name = "github.com/aws/aws-sdk-go"
+			  version = "1.0.0"
+			  group = "aws"
 			`,
 			wantWarn:  []error{},
-			wantError: errInvalidIgnored,
+			wantError: errInvalidGroup,
 		},
 		{
-			name: "empty ignored",
+			name: "groups in override",
 			tomlString: `
-			ignored = []
+			[[override]]
+			  name = "github.com/aws/aws-sdk-go"
+			  version = "1.0.0"
+			  group = ["aws"]
+			`,
+			wantWarn: []error{
+				fmt.Errorf("invalid key %q in %q", "group", "override"),
+			},
+			wantError: nil,
+		},
+		{
+			name: "valid constraint digest",
+			tomlString: `
+			[[constraint]]
+			  name = "github.com/foo/bar"
+			  digest = "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
 			`,
 			wantWarn:  []error{},
 			wantError: nil,
 		},
 		{
-			name: "invalid ignored list",
+			name: "invalid constraint digest",
 			tomlString: `
-			ignored = [1, 2, 3]
+			[[constraint]]
+			  name = "github.com/foo/bar"
+			  version = "1.0.0"
+			  digest = "1:47DEQpj8HBSa"
 			`,
 			wantWarn:  []error{},
-			wantError: errInvalidIgnored,
+			wantError: errInvalidDigest,
 		},
 		{
-			name: "invalid ignored format",
+			name: "digest in override",
 			tomlString: `
-			[[ignored]]
-			  name = "foo"
+			[[override]]
+			  name = "github.com/foo/bar"
+			  version = "1.0.0"
+			  digest = "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+			`,
+			wantWarn: []error{
+				fmt.Errorf("invalid key %q in %q", "digest", "override"),
+			},
+			wantError: nil,
+		},
+		{
+			name: "valid sources",
+			tomlString: `
+			[sources]
+			  "golang.org/x" = "git.example.com/mirrors/golang"
 			`,
 			wantWarn:  []error{},
-			wantError: errInvalidIgnored,
+			wantError: nil,
+		},
+		{
+			name: "invalid sources value",
+			tomlString: `
+			[sources]
+			  "golang.org/x" = ["git.example.com/mirrors/golang"]
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidSources,
+		},
+		{
+			name: "invalid protocols value",
+			tomlString: `
+			[protocols]
+			  "github.com" = "git"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidProtocols,
 		},
 		{
 			name: "valid metadata",
@@ -439,6 +1190,259 @@ func TestValidateManifest(t *testing.T) {
 			wantWarn:  []error{},
 			wantError: errInvalidPruneProject,
 		},
+		{
+			name: "valid prune project groups",
+			tomlString: `
+			[prune]
+			  non-go = true
+
+			  [[prune.project]]
+			    name = "github.com/aws/*"
+			    unused-packages = true
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "valid prune projects keyed by name",
+			tomlString: `
+			[prune]
+			  non-go = true
+
+			  [prune.project."github.com/aws/*"]
+			    unused-packages = true
+
+			  [prune.project."github.com/org/project"]
+			    name = "github.com/org/other"
+			    non-go = false
+			`,
+			wantWarn: []error{
+				fmt.Errorf("%q in %q is ignored, as the project is named by its key", "name", "prune.project.github.com/org/project"),
+			},
+			wantError: nil,
+		},
+		{
+			name: "invalid prune project group",
+			tomlString: `
+			[prune]
+			  non-go = true
+
+			  [prune.project."github.com/*/sdk"]
+			    unused-packages = true
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidPruneGroup,
+		},
+		{
+			name: "valid hooks",
+			tomlString: `
+			[hooks]
+			  pre-ensure = ["go generate ./..."]
+			  post-ensure = ["./scripts/patch-vendor.sh"]
+			  post-ensure-webhooks = ["https://example.com/dep"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "unknown hook",
+			tomlString: `
+			[hooks]
+			  post-init = ["true"]
+			`,
+			wantWarn: []error{
+				errors.New("unknown field \"post-init\" in \"hooks\""),
+			},
+			wantError: nil,
+		},
+		{
+			name: "invalid hooks",
+			tomlString: `
+			hooks = ["go generate ./..."]
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidHooks,
+		},
+		{
+			name: "invalid hook commands",
+			tomlString: `
+			[hooks]
+			  post-ensure = "go generate ./..."
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidHooks,
+		},
+		{
+			name: "valid signatures",
+			tomlString: `
+			[signatures]
+			  keyring = "trusted.gpg"
+			  projects = ["github.com/foo/bar"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "signatures without keyring",
+			tomlString: `
+			[signatures]
+			  projects = ["github.com/foo/bar"]
+			  key = "ABCD"
+			`,
+			wantWarn: []error{
+				errors.New("unknown field \"key\" in \"signatures\""),
+				errors.New("\"signatures\" lists projects, but names no \"keyring\" to verify them with"),
+			},
+			wantError: nil,
+		},
+		{
+			name: "invalid signature projects",
+			tomlString: `
+			[signatures]
+			  keyring = "trusted.gpg"
+			  projects = "github.com/foo/bar"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidSignatures,
+		},
+		{
+			name: "valid sigstore",
+			tomlString: `
+			[[sigstore]]
+			  name = "github.com/foo/bar"
+			  identity = "maintainer@example.com"
+			  issuer = "https://accounts.google.com"
+			  enforce = true
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "sigstore without issuer",
+			tomlString: `
+			[[sigstore]]
+			  name = "github.com/foo/bar"
+			  identity = "maintainer@example.com"
+			`,
+			wantWarn: []error{
+				errors.New("\"issuer\" in \"sigstore\" for \"github.com/foo/bar\" is required to verify its signatures"),
+			},
+			wantError: nil,
+		},
+		{
+			name: "invalid sigstore enforce",
+			tomlString: `
+			[[sigstore]]
+			  name = "github.com/foo/bar"
+			  enforce = "yes"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidSigstore,
+		},
+		{
+			name: "valid banned",
+			tomlString: `
+			[[banned]]
+			  name = "github.com/foo/bar"
+			  reason = "unmaintained"
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid banned",
+			tomlString: `
+			[banned]
+			  name = "github.com/foo/bar"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidBanned,
+		},
+		{
+			name: "banned without name",
+			tomlString: `
+			[[banned]]
+			  reason = "unmaintained"
+			  why = "unmaintained"
+			`,
+			wantWarn: []error{
+				errors.New("invalid key \"why\" in \"banned\""),
+				errNoName,
+			},
+			wantError: nil,
+		},
+		{
+			name:       "valid audit log",
+			tomlString: `audit-log = "deps/audit.log"`,
+			wantWarn:   []error{},
+			wantError:  nil,
+		},
+		{
+			name:       "invalid audit log",
+			tomlString: `audit-log = ["deps/audit.log"]`,
+			wantWarn:   []error{},
+			wantError:  errInvalidAuditLog,
+		},
+		{
+			name:       "valid bazel macro",
+			tomlString: `bazel-macro = "bazel/deps.bzl%go_dependencies"`,
+			wantWarn:   []error{},
+			wantError:  nil,
+		},
+		{
+			name:       "bazel macro without a macro",
+			tomlString: `bazel-macro = "deps.bzl"`,
+			wantWarn:   []error{},
+			wantError:  errInvalidBazelMacro,
+		},
+		{
+			name:       "bazel macro without a file",
+			tomlString: `bazel-macro = "%go_dependencies"`,
+			wantWarn:   []error{},
+			wantError:  errInvalidBazelMacro,
+		},
+		{
+			name: "valid license policy",
+			tomlString: `
+			[license-policy]
+			  allow = ["MIT", "BSD-3-Clause"]
+			  on-violation = "warn"
+
+			  [license-policy.exceptions]
+			    "github.com/foo/bar" = ["MPL-2.0"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "unknown license policy field",
+			tomlString: `
+			[license-policy]
+			  permit = ["MIT"]
+			`,
+			wantWarn: []error{
+				errors.New("unknown field \"permit\" in \"license-policy\""),
+			},
+			wantError: nil,
+		},
+		{
+			name: "invalid license policy deny",
+			tomlString: `
+			[license-policy]
+			  deny = "GPL-3.0"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidLicensePolicy,
+		},
+		{
+			name: "invalid license policy on-violation",
+			tomlString: `
+			[license-policy]
+			  on-violation = "ignore"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidOnViolation,
+		},
 	}
 
 	for _, c := range cases {
@@ -531,6 +1535,32 @@ func TestCheckRedundantPruneOptions(t *testing.T) {
 				fmt.Errorf("redundant prune option %q set for %q", "go-tests", "github.com/other/project"),
 			},
 		},
+		{
+			name: "redundancy with project groups",
+			pruneOptions: gps.CascadingPruneOptions{
+				DefaultOptions: 1,
+				PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{
+					"github.com/*": {
+						NestedVendor: pvtrue,
+						NonGoFiles:   pvtrue,
+					},
+					"github.com/aws/*": {
+						NestedVendor: pvtrue,
+						NonGoFiles:   pvtrue,
+						GoTests:      pvfalse,
+					},
+					"github.com/aws/aws-sdk-go": {
+						NestedVendor: pvtrue,
+						NonGoFiles:   pvfalse,
+						GoTests:      pvtrue,
+					},
+				},
+			},
+			wantWarn: []error{
+				fmt.Errorf("redundant prune option %q set for %q", "non-go", "github.com/aws/*"),
+				fmt.Errorf("redundant prune option %q set for %q", "go-tests", "github.com/aws/*"),
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -811,6 +1841,18 @@ func TestToRawPruneOptions_Panic(t *testing.T) {
 	_ = toRawPruneOptions(pruneOptions)
 }
 
+func TestManifestIsVerified(t *testing.T) {
+	m := NewManifest()
+	m.NoVerify = []string{"github.com/foo/patched"}
+
+	if m.IsVerified("github.com/foo/patched") {
+		t.Error("project in noverify list should not be verified")
+	}
+	if !m.IsVerified("github.com/foo/bar") {
+		t.Error("project not in noverify list should be verified")
+	}
+}
+
 func containsErr(s []error, e error) bool {
 	for _, a := range s {
 		if a.Error() == e.Error() {
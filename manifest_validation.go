@@ -0,0 +1,193 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// IssueKind classifies the problems reported by ValidateManifest.
+type IssueKind int
+
+const (
+	// IssueInvalidValue is a field whose value is missing or malformed.
+	IssueInvalidValue IssueKind = iota
+	// IssueUnknownField is a field dep does not recognize.
+	IssueUnknownField
+	// IssueInvalidSemver is a version rule that is not a valid semver range,
+	// and so only matches a tag with exactly that name.
+	IssueInvalidSemver
+	// IssueUnusedConstraint is a [[constraint]] on a project that is not a
+	// direct dependency of the root project, and so has no effect.
+	IssueUnusedConstraint
+	// IssueOverlappingOverride is an [[override]] that overlaps another
+	// override, or a constraint on the same project.
+	IssueOverlappingOverride
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case IssueInvalidValue:
+		return "invalid value"
+	case IssueUnknownField:
+		return "unknown field"
+	case IssueInvalidSemver:
+		return "invalid semver"
+	case IssueUnusedConstraint:
+		return "unused constraint"
+	case IssueOverlappingOverride:
+		return "overlapping override"
+	}
+	return "unknown issue"
+}
+
+// ManifestIssue describes a single problem found in a manifest.
+type ManifestIssue struct {
+	Kind    IssueKind
+	Project gps.ProjectRoot // The project the issue concerns, if any.
+	Message string
+}
+
+func (i *ManifestIssue) Error() string {
+	return i.Message
+}
+
+// unknownFieldf returns an IssueUnknownField with the formatted message.
+func unknownFieldf(format string, args ...interface{}) error {
+	return &ManifestIssue{Kind: IssueUnknownField, Message: fmt.Sprintf(format, args...)}
+}
+
+// toManifestIssue converts an error produced while reading a manifest to a
+// ManifestIssue. Errors that are not already issues are invalid values.
+func toManifestIssue(err error) *ManifestIssue {
+	if issue, ok := errors.Cause(err).(*ManifestIssue); ok {
+		return issue
+	}
+	return &ManifestIssue{Kind: IssueInvalidValue, Message: err.Error()}
+}
+
+// ManifestValidation holds the problems found in a manifest by
+// ValidateManifest. Errors prevent dep from using the manifest at all, while
+// warnings point out rules that are likely mistakes.
+type ManifestValidation struct {
+	Warnings []*ManifestIssue
+	Errors   []*ManifestIssue
+}
+
+// ValidateManifest reads a manifest from r and reports the problems it finds
+// in it. An error is returned only if r cannot be read or parsed as TOML.
+//
+// directDeps is the set of the root project's direct dependencies, as returned
+// by Project.GetDirectDependencyNames. When it is nil, constraints are not
+// checked against the project's imports.
+func ValidateManifest(r io.Reader, directDeps map[gps.ProjectRoot]bool) (*ManifestValidation, error) {
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, errors.Wrap(err, "unable to read byte stream")
+	}
+	if _, err := toml.LoadBytes(buf.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the manifest as TOML")
+	}
+
+	mv := &ManifestValidation{}
+	m, warns, err := readManifest(buf)
+	for _, warn := range warns {
+		mv.Warnings = append(mv.Warnings, toManifestIssue(warn))
+	}
+	if err != nil {
+		mv.Errors = append(mv.Errors, toManifestIssue(err))
+		mv.sort()
+		return mv, nil
+	}
+
+	mv.Warnings = append(mv.Warnings, checkVersionRules(m.Constraints, "constraint")...)
+	mv.Warnings = append(mv.Warnings, checkVersionRules(m.Ovr, "override")...)
+	if directDeps != nil {
+		mv.Warnings = append(mv.Warnings, checkUnusedConstraints(m, directDeps)...)
+	}
+	mv.Warnings = append(mv.Warnings, checkOverlappingOverrides(m)...)
+
+	mv.sort()
+	return mv, nil
+}
+
+// sort orders the issues by kind, then by message, so that they are reported
+// consistently.
+func (mv *ManifestValidation) sort() {
+	for _, issues := range [][]*ManifestIssue{mv.Warnings, mv.Errors} {
+		sort.SliceStable(issues, func(i, j int) bool {
+			if issues[i].Kind != issues[j].Kind {
+				return issues[i].Kind < issues[j].Kind
+			}
+			return issues[i].Message < issues[j].Message
+		})
+	}
+}
+
+// checkVersionRules reports the version rules in pc that are not valid semver
+// ranges. dep treats those as the names of tags, which is rarely intended.
+func checkVersionRules(pc gps.ProjectConstraints, kind string) []*ManifestIssue {
+	var issues []*ManifestIssue
+	for pr, pp := range pc {
+		if v, ok := pp.Constraint.(gps.UnpairedVersion); ok && v.Type() == gps.IsVersion {
+			issues = append(issues, &ManifestIssue{
+				Kind:    IssueInvalidSemver,
+				Project: pr,
+				Message: fmt.Sprintf("version %q in %q for %s is not a valid semver range, and will only match a tag of that name", v, kind, pr),
+			})
+		}
+	}
+	return issues
+}
+
+// checkUnusedConstraints reports the constraints in m on projects that are
+// not in directDeps.
+func checkUnusedConstraints(m *Manifest, directDeps map[gps.ProjectRoot]bool) []*ManifestIssue {
+	var issues []*ManifestIssue
+	for pr := range m.Constraints {
+		if !directDeps[pr] {
+			issues = append(issues, &ManifestIssue{
+				Kind:    IssueUnusedConstraint,
+				Project: pr,
+				Message: fmt.Sprintf("constraint on %s has no effect, as it is not a direct dependency; use an override to constrain transitive dependencies", pr),
+			})
+		}
+	}
+	return issues
+}
+
+// checkOverlappingOverrides reports the overrides in m that are nested within
+// another override's project root, or that supersede a constraint on the
+// same project.
+func checkOverlappingOverrides(m *Manifest) []*ManifestIssue {
+	var issues []*ManifestIssue
+	for pr := range m.Ovr {
+		if _, has := m.Constraints[pr]; has {
+			issues = append(issues, &ManifestIssue{
+				Kind:    IssueOverlappingOverride,
+				Project: pr,
+				Message: fmt.Sprintf("override on %s supersedes the constraint on the same project", pr),
+			})
+		}
+		for other := range m.Ovr {
+			if strings.HasPrefix(string(pr), string(other)+"/") {
+				issues = append(issues, &ManifestIssue{
+					Kind:    IssueOverlappingOverride,
+					Project: pr,
+					Message: fmt.Sprintf("override on %s is nested within the override on %s", pr, other),
+				})
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestValidateManifestIssues(t *testing.T) {
+	in := `
+bogus = true
+
+[[constraint]]
+  name = "github.com/foo/bar"
+  version = "not-semver"
+
+[[constraint]]
+  name = "github.com/foo/unused"
+  version = "1.0.0"
+
+[[override]]
+  name = "github.com/foo/bar"
+  version = "1.0.0"
+
+[[override]]
+  name = "github.com/foo/baz"
+  version = "1.0.0"
+
+[[override]]
+  name = "github.com/foo/baz/qux"
+  version = "1.0.0"
+`
+	directDeps := map[gps.ProjectRoot]bool{"github.com/foo/bar": true}
+	mv, err := ValidateManifest(strings.NewReader(in), directDeps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mv.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", mv.Errors)
+	}
+
+	type issue struct {
+		kind IssueKind
+		pr   gps.ProjectRoot
+	}
+	var got []issue
+	for _, i := range mv.Warnings {
+		got = append(got, issue{i.Kind, i.Project})
+	}
+	want := []issue{
+		{IssueUnknownField, ""},
+		{IssueInvalidSemver, "github.com/foo/bar"},
+		{IssueUnusedConstraint, "github.com/foo/unused"},
+		{IssueOverlappingOverride, "github.com/foo/bar"},
+		{IssueOverlappingOverride, "github.com/foo/baz/qux"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected warnings:\n\t(GOT) %v\n\t(WNT) %v", got, want)
+	}
+
+	// Without direct dependencies, constraints are not checked for use.
+	mv, err = ValidateManifest(strings.NewReader(in), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range mv.Warnings {
+		if i.Kind == IssueUnusedConstraint {
+			t.Errorf("unexpected unused constraint warning: %s", i)
+		}
+	}
+}
+
+func TestValidateManifestErrors(t *testing.T) {
+	mv, err := ValidateManifest(strings.NewReader(`required = "github.com/foo/bar"`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mv.Errors) != 1 || mv.Errors[0].Kind != IssueInvalidValue {
+		t.Fatalf("expected a single invalid value error, got %v", mv.Errors)
+	}
+
+	if _, err = ValidateManifest(strings.NewReader(`[[constraint`), nil); err == nil {
+		t.Fatal("expected an error for a manifest that is not valid TOML")
+	}
+}
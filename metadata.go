@@ -0,0 +1,222 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// Metadata holds the user-defined key-value pairs of a [metadata] table.
+//
+// Dep attaches no meaning to metadata, but preserves it when rewriting
+// Gopkg.toml and Gopkg.lock, so that other tools may use it to store their
+// own annotations. Values are held as the TOML parser produces them: string,
+// bool, int64, float64, time.Time, []interface{}, and map[string]interface{}
+// for nested tables.
+type Metadata map[string]interface{}
+
+// String returns the string value stored under key, if there is one.
+func (md Metadata) String(key string) (string, bool) {
+	v, ok := md[key].(string)
+	return v, ok
+}
+
+// Bool returns the boolean value stored under key, if there is one.
+func (md Metadata) Bool(key string) (bool, bool) {
+	v, ok := md[key].(bool)
+	return v, ok
+}
+
+// Int returns the integer value stored under key, if there is one.
+func (md Metadata) Int(key string) (int64, bool) {
+	v, ok := md[key].(int64)
+	return v, ok
+}
+
+// Float returns the floating point value stored under key, if there is one.
+func (md Metadata) Float(key string) (float64, bool) {
+	v, ok := md[key].(float64)
+	return v, ok
+}
+
+// Time returns the datetime value stored under key, if there is one.
+func (md Metadata) Time(key string) (time.Time, bool) {
+	v, ok := md[key].(time.Time)
+	return v, ok
+}
+
+// Strings returns the list of strings stored under key, if there is one. An
+// empty list is reported as present.
+func (md Metadata) Strings(key string) ([]string, bool) {
+	list, ok := md[key].([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	strs := make([]string, len(list))
+	for i, v := range list {
+		if strs[i], ok = v.(string); !ok {
+			return nil, false
+		}
+	}
+	return strs, true
+}
+
+// Table returns the nested table stored under key, if there is one. The
+// returned Metadata shares its contents with md.
+func (md Metadata) Table(key string) (Metadata, bool) {
+	v, ok := md[key].(map[string]interface{})
+	return Metadata(v), ok
+}
+
+// Set stores val under key, converting it to the representation used by the
+// TOML parser. An error is returned if val cannot be represented in TOML.
+func (md Metadata) Set(key string, val interface{}) error {
+	v, err := toMetadataValue(val)
+	if err != nil {
+		return errors.Wrapf(err, "invalid metadata value for %q", key)
+	}
+	md[key] = v
+	return nil
+}
+
+// toMetadataValue converts v to the type the TOML parser would have produced
+// for it.
+func toMetadataValue(v interface{}) (interface{}, error) {
+	switch tv := v.(type) {
+	case string, bool, int64, float64, time.Time:
+		return tv, nil
+	case int:
+		return int64(tv), nil
+	case int32:
+		return int64(tv), nil
+	case uint32:
+		return int64(tv), nil
+	case float32:
+		return float64(tv), nil
+	case []string:
+		list := make([]interface{}, len(tv))
+		for i, s := range tv {
+			list[i] = s
+		}
+		return list, nil
+	case []interface{}:
+		list := make([]interface{}, len(tv))
+		for i, e := range tv {
+			var err error
+			if list[i], err = toMetadataValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case Metadata:
+		return toMetadataValue(map[string]interface{}(tv))
+	case map[string]interface{}:
+		table := make(map[string]interface{}, len(tv))
+		for k, e := range tv {
+			var err error
+			if table[k], err = toMetadataValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return table, nil
+	}
+	return nil, errors.Errorf("unsupported type %T", v)
+}
+
+// Copy returns a deep copy of md.
+func (md Metadata) Copy() Metadata {
+	if md == nil {
+		return nil
+	}
+	// Everything in md was produced by the parser or by Set, so the
+	// conversion cannot fail.
+	v, _ := toMetadataValue(map[string]interface{}(md))
+	return Metadata(v.(map[string]interface{}))
+}
+
+// metadataFromTree extracts the [metadata] table from a parsed TOML table, if
+// it has one.
+func metadataFromTree(t *toml.Tree) Metadata {
+	if mt, ok := t.Get("metadata").(*toml.Tree); ok {
+		return Metadata(mt.ToMap())
+	}
+	return nil
+}
+
+// projectMetadataFromTree extracts the metadata tables nested in each element
+// of the named array of tables, keyed by the element's name.
+func projectMetadataFromTree(t *toml.Tree, key string) map[gps.ProjectRoot]Metadata {
+	pmd := make(map[gps.ProjectRoot]Metadata)
+	elems, _ := t.Get(key).([]*toml.Tree)
+	for _, elem := range elems {
+		name, _ := elem.Get("name").(string)
+		if md := metadataFromTree(elem); len(md) > 0 {
+			pmd[gps.ProjectRoot(name)] = md
+		}
+	}
+	return pmd
+}
+
+// encodeTOML encodes v into buf with the formatting dep uses for all of its
+// files.
+func encodeTOML(buf *bytes.Buffer, v interface{}) error {
+	return toml.NewEncoder(buf).ArraysWithOneElementPerLine(true).Encode(v)
+}
+
+// The TOML encoder is unable to encode the free-form values held in
+// Metadata, so metadata tables are rendered from trees built by the TOML
+// package itself, and spliced into the encoder's output.
+
+// writeMetadata writes md to buf as a root-level [metadata] table. Nothing is
+// written if md is empty.
+func writeMetadata(buf *bytes.Buffer, md Metadata) error {
+	if len(md) == 0 {
+		return nil
+	}
+
+	t, err := toml.TreeFromMap(map[string]interface{}{
+		"metadata": map[string]interface{}(md),
+	})
+	if err != nil {
+		return err
+	}
+	s, err := t.ToTomlString()
+	if err != nil {
+		return err
+	}
+	buf.WriteString(s)
+	return nil
+}
+
+// writeNestedMetadata writes md to buf as the [metadata] table of the element
+// of the named array of tables that was most recently written to buf. Nothing
+// is written if md is empty.
+func writeNestedMetadata(buf *bytes.Buffer, key string, md Metadata) error {
+	if len(md) == 0 {
+		return nil
+	}
+
+	t, err := toml.TreeFromMap(map[string]interface{}{
+		key: []map[string]interface{}{{"metadata": map[string]interface{}(md)}},
+	})
+	if err != nil {
+		return err
+	}
+	s, err := t.ToTomlString()
+	if err != nil {
+		return err
+	}
+	// Drop the header of the placeholder element; what remains is its nested
+	// metadata table.
+	buf.WriteString(strings.TrimPrefix(s, "\n[["+key+"]]\n"))
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetadataAccessors(t *testing.T) {
+	when := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	md := Metadata{}
+	for k, v := range map[string]interface{}{
+		"owner":    "team-a",
+		"audited":  true,
+		"priority": 3,
+		"score":    float32(0.5),
+		"reviewed": when,
+		"tags":     []string{"a", "b"},
+		"ci":       Metadata{"job": "lint"},
+	} {
+		if err := md.Set(k, v); err != nil {
+			t.Fatalf("unexpected error setting %q: %v", k, err)
+		}
+	}
+
+	if v, ok := md.String("owner"); !ok || v != "team-a" {
+		t.Errorf("unexpected string value %q (present: %t)", v, ok)
+	}
+	if v, ok := md.Bool("audited"); !ok || !v {
+		t.Errorf("unexpected bool value %t (present: %t)", v, ok)
+	}
+	if v, ok := md.Int("priority"); !ok || v != 3 {
+		t.Errorf("unexpected int value %d (present: %t)", v, ok)
+	}
+	if v, ok := md.Float("score"); !ok || v != 0.5 {
+		t.Errorf("unexpected float value %f (present: %t)", v, ok)
+	}
+	if v, ok := md.Time("reviewed"); !ok || !v.Equal(when) {
+		t.Errorf("unexpected time value %s (present: %t)", v, ok)
+	}
+	if v, ok := md.Strings("tags"); !ok || !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("unexpected strings value %v (present: %t)", v, ok)
+	}
+	if v, ok := md.Table("ci"); !ok {
+		t.Error("expected nested table to be present")
+	} else if job, _ := v.String("job"); job != "lint" {
+		t.Errorf("unexpected nested value %q", job)
+	}
+
+	// Mismatched types are reported as absent.
+	if _, ok := md.Int("owner"); ok {
+		t.Error("string value should not be reported as an int")
+	}
+	if _, ok := md.String("missing"); ok {
+		t.Error("missing key should not be reported as present")
+	}
+
+	if err := md.Set("bad", struct{}{}); err == nil {
+		t.Error("expected an error setting an unsupported value")
+	}
+}
+
+func TestMetadataCopy(t *testing.T) {
+	md := Metadata{}
+	md.Set("ci", Metadata{"job": "lint"})
+
+	cp := md.Copy()
+	if !reflect.DeepEqual(md, cp) {
+		t.Fatalf("copy differs from original:\n\t(GOT) %v\n\t(WNT) %v", cp, md)
+	}
+
+	ci, _ := cp.Table("ci")
+	ci.Set("job", "test")
+	if job, _ := md["ci"].(map[string]interface{})["job"]; job != "lint" {
+		t.Errorf("modifying the copy changed the original: %v", md)
+	}
+
+	if Metadata(nil).Copy() != nil {
+		t.Error("copy of nil metadata should be nil")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"go/build"
+
+	"github.com/golang/dep/gps"
+)
+
+// Platform restricts a project to some operating systems and architectures,
+// as given by the os and arch fields of its [[constraint]]. An empty list
+// allows any value.
+type Platform struct {
+	OS   []string
+	Arch []string
+}
+
+// Matches reports whether the platform given by goos and goarch is allowed.
+func (pl Platform) Matches(goos, goarch string) bool {
+	return allows(pl.OS, goos) && allows(pl.Arch, goarch)
+}
+
+func allows(list []string, val string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// PlatformExcluded returns the set of projects that the manifest restricts to
+// platforms other than the one dep targets, as given by GOOS and GOARCH. They
+// are left out of vendor/, and are not verified.
+//
+// Solving is not restricted, so that Gopkg.lock is the same on every platform.
+func (p *Project) PlatformExcluded() map[gps.ProjectRoot]bool {
+	if p.Manifest == nil {
+		return nil
+	}
+
+	var excluded map[gps.ProjectRoot]bool
+	for pr := range p.Manifest.ConstraintPlatforms {
+		if !p.Manifest.AppliesOn(pr, build.Default.GOOS, build.Default.GOARCH) {
+			if excluded == nil {
+				excluded = make(map[gps.ProjectRoot]bool)
+			}
+			excluded[pr] = true
+		}
+	}
+	return excluded
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlatformMatches(t *testing.T) {
+	cases := []struct {
+		pl           Platform
+		goos, goarch string
+		want         bool
+	}{
+		{Platform{}, "linux", "amd64", true},
+		{Platform{OS: []string{"windows"}}, "windows", "386", true},
+		{Platform{OS: []string{"windows"}}, "linux", "amd64", false},
+		{Platform{OS: []string{"linux", "darwin"}, Arch: []string{"arm64"}}, "darwin", "arm64", true},
+		{Platform{OS: []string{"linux", "darwin"}, Arch: []string{"arm64"}}, "darwin", "amd64", false},
+		{Platform{Arch: []string{"arm64"}}, "linux", "arm64", true},
+	}
+
+	for _, c := range cases {
+		if got := c.pl.Matches(c.goos, c.goarch); got != c.want {
+			t.Errorf("%+v matches %s/%s: got %t, want %t", c.pl, c.goos, c.goarch, got, c.want)
+		}
+	}
+}
+
+func TestReadWriteManifestPlatforms(t *testing.T) {
+	in := `[[constraint]]
+  name = "github.com/Microsoft/go-winio"
+  version = "0.4.5"
+  arch = ["amd64"]
+  os = ["windows"]
+
+[[constraint]]
+  name = "github.com/pkg/errors"
+  version = "0.8.0"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("should have read manifest correctly, but got err %q", err)
+	}
+
+	if got := m.ConstraintPlatforms["github.com/Microsoft/go-winio"]; !reflect.DeepEqual(got, Platform{OS: []string{"windows"}, Arch: []string{"amd64"}}) {
+		t.Errorf("unexpected platform: %+v", got)
+	}
+	if !m.AppliesOn("github.com/Microsoft/go-winio", "windows", "amd64") || m.AppliesOn("github.com/Microsoft/go-winio", "linux", "amd64") {
+		t.Error("platform restriction was not applied")
+	}
+	if !m.AppliesOn("github.com/pkg/errors", "linux", "amd64") {
+		t.Error("unrestricted project should apply on every platform")
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(in) {
+		t.Fatalf("platforms did not survive a rewrite:\n(GOT):\n%s\n(WNT):\n%s", got, in)
+	}
+
+	if _, _, err := readManifest(strings.NewReader(`[[constraint]]
+  name = "github.com/Microsoft/go-winio"
+  os = "windows"
+`)); err == nil || !strings.Contains(err.Error(), errInvalidPlatform.Error()) {
+		t.Errorf("expected an error for an invalid os, got %v", err)
+	}
+}
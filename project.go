@@ -105,6 +105,17 @@ type Project struct {
 	Manifest        *Manifest
 	Lock            *Lock // Optional
 	RootPackageTree pkgtree.PackageTree
+	// DigestMemo, if not nil, remembers the digests of the projects in the
+	// project's vendor directory, so that they need not be computed again
+	// while their files are unchanged.
+	DigestMemo *pkgtree.DigestMemo
+	// InputsMemo, if not nil, remembers the digest of the inputs to solving
+	// the project and its direct dependencies, so that they need not be
+	// computed again while the project is unchanged.
+	InputsMemo *InputsMemo
+
+	// directDeps caches the result of GetDirectDependencyNames.
+	directDeps map[gps.ProjectRoot]bool
 }
 
 // SetRoot sets the project AbsRoot and ResolvedAbsRoot. If root is not a symlink, ResolvedAbsRoot will be set to root.
@@ -174,11 +185,17 @@ func (p *Project) ParseRootPackageTree() (pkgtree.PackageTree, error) {
 // This function will correctly utilize ignores and requireds from an existing
 // manifest, if one is present, but will also do the right thing without a
 // manifest.
+//
+// Like the root package tree, the result is cached; callers must not modify the
+// returned map.
 func (p *Project) GetDirectDependencyNames(sm gps.SourceManager) (pkgtree.PackageTree, map[gps.ProjectRoot]bool, error) {
 	ptree, err := p.ParseRootPackageTree()
 	if err != nil {
 		return pkgtree.PackageTree{}, nil, err
 	}
+	if p.directDeps != nil {
+		return ptree, p.directDeps, nil
+	}
 
 	var ig *pkgtree.IgnoredRuleset
 	var req map[string]bool
@@ -216,6 +233,7 @@ func (p *Project) GetDirectDependencyNames(sm gps.SourceManager) (pkgtree.Packag
 		directDeps[pr] = true
 	}
 
+	p.directDeps = directDeps
 	return ptree, directDeps, nil
 }
 
@@ -248,6 +266,78 @@ func (p *Project) FindIneffectualConstraints(sm gps.SourceManager) []gps.Project
 	return ineff
 }
 
+// VerifyVendor checks the contents of p's vendor directory against the digests
+// recorded in p's lock. It returns the status of each locked project, keyed by
+// its root, along with any other paths in vendor/ that the lock does not
+// account for.
+//
+// Projects that the manifest excludes from verification, or restricts to other
+// platforms, are not reported.
+//
+// The digests of the vendored projects are always computed, for the result to
+// be trusted; see VerifyVendorMemoized.
+func (p *Project) VerifyVendor() (map[string]pkgtree.VendorStatus, error) {
+	return p.verifyVendor(nil)
+}
+
+// VerifyVendorMemoized is like VerifyVendor, but takes the digests of vendored
+// projects from p.DigestMemo while their files are unchanged. As a project
+// modified without changing the metadata of its files goes unnoticed, it is
+// only for deciding whether vendor/ needs writing out again, and not for
+// checking it.
+func (p *Project) VerifyVendorMemoized() (map[string]pkgtree.VendorStatus, error) {
+	return p.verifyVendor(p.DigestMemo)
+}
+
+func (p *Project) verifyVendor(memo *pkgtree.DigestMemo) (map[string]pkgtree.VendorStatus, error) {
+	if p.Lock == nil {
+		return nil, errors.Errorf("no %s to verify %s against", LockName, "vendor/")
+	}
+
+	// Projects that are not vendored on this platform are skipped just like
+	// unverified ones.
+	excluded := p.PlatformExcluded()
+	skipped := func(pr gps.ProjectRoot) bool {
+		return excluded[pr] || (p.Manifest != nil && !p.Manifest.IsVerified(pr))
+	}
+
+	wantSums := make(map[string][]byte, len(p.Lock.P))
+	for _, lp := range p.Lock.P {
+		pr := lp.Ident().ProjectRoot
+		// Unverified projects are still expected in the tree, so that they
+		// are not reported as unaccounted for, but there is no reason to
+		// compute their digests.
+		if skipped(pr) {
+			wantSums[string(pr)] = nil
+			continue
+		}
+		wantSums[string(pr)] = p.Lock.Digests[pr]
+	}
+
+	vpath := filepath.Join(p.AbsRoot, "vendor")
+	status, err := memo.VerifyDepTree(vpath, wantSums)
+	// The memo only saves work; losing it costs no more than computing the
+	// digests again.
+	memo.Save()
+	if err != nil {
+		if _, serr := os.Stat(vpath); !os.IsNotExist(serr) {
+			return nil, errors.Wrap(err, "could not verify vendor tree")
+		}
+		// Without a vendor directory, nothing is in the tree.
+		status = make(map[string]pkgtree.VendorStatus, len(wantSums))
+		for pr := range wantSums {
+			status[pr] = pkgtree.NotInTree
+		}
+	}
+
+	for pr := range wantSums {
+		if skipped(gps.ProjectRoot(pr)) {
+			delete(status, pr)
+		}
+	}
+	return status, nil
+}
+
 // BackupVendor looks for existing vendor directory and if it's not empty,
 // creates a backup of it to a new directory with the provided suffix.
 func BackupVendor(vpath, suffix string) (string, error) {
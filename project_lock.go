@@ -0,0 +1,81 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/nightlyone/lockfile"
+	"github.com/pkg/errors"
+)
+
+// ProjectLockName is the name of the file, in a project's root, that dep
+// processes hold while they change the project's files and vendor/.
+const ProjectLockName = ".dep.lock"
+
+// LockProject takes the lock of the project at root, waiting for any other dep
+// process holding it to finish, and returns the function that releases it.
+//
+// The source manager's lock only keeps processes sharing a cache directory, and
+// not using the daemon, apart; the project's lock keeps apart all those
+// changing the same project, such as parallel CI jobs in one workspace, whose
+// writes of vendor/ would otherwise be interleaved. Like the source manager's,
+// it is not taken if DisableLocking is set.
+func (c *Ctx) LockProject(root string) (func(), error) {
+	if c.DisableLocking {
+		return func() {}, nil
+	}
+
+	path, err := filepath.Abs(filepath.Join(root, ProjectLockName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to lock project %s", root)
+	}
+	lf, err := lockfile.New(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create lock %s", path)
+	}
+
+	// As with the source manager's lock, a busy lock is waited for, and a lock
+	// left by a process that has died is taken over.
+	var lasttime time.Time
+	for err = lf.TryLock(); err != nil; err = lf.TryLock() {
+		if t, ok := err.(interface {
+			Temporary() bool
+		}); !ok || !t.Temporary() {
+			return nil, errors.Wrapf(err, "unable to lock %s", path)
+		}
+		if now := time.Now(); now.Sub(lasttime) > 15*time.Second {
+			c.Logger().Infof("waiting for another dep process to finish with the project, holding %s", path)
+			lasttime = now
+		}
+		time.Sleep(projectLockRetry)
+	}
+	return func() { lf.Unlock() }, nil
+}
+
+// projectLockRetry is how long LockProject waits to try a busy lock again.
+var projectLockRetry = time.Second
+
+// LoadLockedProject takes the lock of the project at the working directory, as
+// LockProject does, and then loads it, so that it reflects any changes made by
+// the processes it waited for. The lock is released by calling the function
+// returned.
+func (c *Ctx) LoadLockedProject() (*Project, func(), error) {
+	root, err := findProjectRoot(c.WorkingDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock, err := c.LockProject(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := c.LoadProject()
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return p, unlock, nil
+}
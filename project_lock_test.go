@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestLockProject(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("proj")
+	root := h.Path("proj")
+	lockPath := filepath.Join(root, ProjectLockName)
+
+	defer func(d time.Duration) { projectLockRetry = d }(projectLockRetry)
+	projectLockRetry = 10 * time.Millisecond
+
+	var stderr bytes.Buffer
+	ctx := &Ctx{Err: log.New(&stderr, "", 0)}
+
+	unlock, err := ctx.LockProject(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Fatalf("the project lock was not taken: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Fatalf("the project lock was not released: %v", err)
+	}
+
+	// A lock held by another live process is waited for.
+	if err := ioutil.WriteFile(lockPath, []byte(fmt.Sprintln(os.Getppid())), 0666); err != nil {
+		t.Fatal(err)
+	}
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(released)
+		os.Remove(lockPath)
+	}()
+	unlock, err = ctx.LockProject(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-released:
+	default:
+		t.Fatal("the project lock was taken while another process held it")
+	}
+	if stderr.Len() == 0 {
+		t.Error("nothing was said about waiting for the project lock")
+	}
+	unlock()
+
+	ctx.DisableLocking = true
+	unlock, err = ctx.LockProject(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Fatalf("the project lock was taken with locking disabled: %v", err)
+	}
+	unlock()
+}
@@ -6,13 +6,17 @@ package dep
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -220,3 +224,145 @@ func TestBackupVendor(t *testing.T) {
 		t.Fatalf("Vendor backup name is not as expected: \n\t(GOT) %v\n\t(WNT) %v", vendorbak, "")
 	}
 }
+
+func TestProjectVerifyVendor(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("proj/vendor/github.com/foo/bar")
+	h.TempFile("proj/vendor/github.com/foo/bar/bar.go", "package bar")
+	h.TempDir("proj/vendor/github.com/foo/patched")
+	h.TempFile("proj/vendor/github.com/foo/patched/patched.go", "package patched")
+	h.TempDir("proj/vendor/github.com/foo/stray")
+
+	lp := func(root string) gps.LockedProject {
+		return gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)},
+			gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+			[]string{"."},
+		)
+	}
+
+	p := &Project{
+		AbsRoot:  h.Path("proj"),
+		Manifest: NewManifest(),
+		Lock: &Lock{
+			P: []gps.LockedProject{
+				lp("github.com/foo/bar"),
+				lp("github.com/foo/missing"),
+				lp("github.com/foo/patched"),
+			},
+		},
+	}
+	p.Manifest.NoVerify = []string{"github.com/foo/patched"}
+
+	status, err := p.VerifyVendor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]pkgtree.VendorStatus{
+		"github.com/foo/bar":     pkgtree.EmptyDigestInLock,
+		"github.com/foo/missing": pkgtree.NotInTree,
+		"github.com/foo/stray":   pkgtree.NotInLock,
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Fatalf("unexpected vendor status:\n\t(GOT) %v\n\t(WNT) %v", status, want)
+	}
+
+	if _, err = p.Lock.updateDigests(h.Path("proj/vendor"), nil, nil, gps.CascadingPruneOptions{}, nil); err == nil {
+		t.Fatal("expected an error computing the digest of a missing project")
+	}
+
+	// A project restricted to another platform is neither vendored nor
+	// verified.
+	p.Manifest.ConstraintPlatforms["github.com/foo/missing"] = Platform{OS: []string{"no-such-os"}}
+	status, err = p.VerifyVendor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := status["github.com/foo/missing"]; has {
+		t.Fatalf("project restricted to another platform was verified: %v", status)
+	}
+	if _, err = p.Lock.updateDigests(h.Path("proj/vendor"), p.PlatformExcluded(), nil, gps.CascadingPruneOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	delete(p.Manifest.ConstraintPlatforms, "github.com/foo/missing")
+	p.Lock.P = []gps.LockedProject{p.Lock.P[0], p.Lock.P[2]}
+	if _, err = p.Lock.updateDigests(h.Path("proj/vendor"), nil, nil, gps.CascadingPruneOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	h.Must(os.RemoveAll(h.Path("proj/vendor/github.com/foo/stray")))
+
+	status, err = p.VerifyVendor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]pkgtree.VendorStatus{"github.com/foo/bar": pkgtree.NoMismatch}
+	if !reflect.DeepEqual(status, want) {
+		t.Fatalf("unexpected vendor status:\n\t(GOT) %v\n\t(WNT) %v", status, want)
+	}
+
+	h.TempFile("proj/vendor/github.com/foo/bar/bar.go", "package bar // modified")
+	status, err = p.VerifyVendor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["github.com/foo/bar"] != pkgtree.DigestMismatchInLock {
+		t.Fatalf("expected modified project to mismatch, got %s", status["github.com/foo/bar"])
+	}
+}
+
+func TestProjectVerifyVendorMemoized(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("cache")
+	h.TempDir("proj/vendor/github.com/foo/bar")
+	h.TempFile("proj/vendor/github.com/foo/bar/bar.go", "package bar")
+	// Files modified within the memo's racy window are not memoized.
+	path := h.Path("proj/vendor/github.com/foo/bar/bar.go")
+	old := time.Now().Add(-time.Hour)
+	h.Must(os.Chtimes(path, old, old))
+	h.Must(os.Chtimes(filepath.Dir(path), old, old))
+
+	p := &Project{
+		AbsRoot:    h.Path("proj"),
+		Manifest:   NewManifest(),
+		DigestMemo: pkgtree.NewDigestMemo(filepath.Join(h.Path("cache"), "digests.json")),
+		Lock: &Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(
+					gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+					gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+					[]string{"."},
+				),
+			},
+		},
+	}
+	if _, err := p.Lock.updateDigests(h.Path("proj/vendor"), nil, nil, gps.CascadingPruneOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.VerifyVendorMemoized(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing a file without changing its size or modification time fools
+	// the memo, but not VerifyVendor.
+	h.Must(ioutil.WriteFile(path, []byte("package baz\n"), 0666))
+	h.Must(os.Chtimes(path, old, old))
+
+	status, err := p.VerifyVendorMemoized()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["github.com/foo/bar"] != pkgtree.NoMismatch {
+		t.Fatalf("expected the memoized digest to match, got %s", status["github.com/foo/bar"])
+	}
+	status, err = p.VerifyVendor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["github.com/foo/bar"] != pkgtree.DigestMismatchInLock {
+		t.Fatalf("expected modified project to mismatch, got %s", status["github.com/foo/bar"])
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package resolve is the stable API for embedding dep's dependency resolution
+// in other tools: fetching sources, solving a project's dependencies, and
+// writing them out, pruned, to a vendor directory.
+//
+// It is a curated subset of package gps, which is free to change as dep needs
+// it to. The identifiers declared here are governed by semantic versioning
+// instead: none is removed, nor is its meaning or signature changed
+// incompatibly, except in a new major version of dep. Most are aliases of the
+// gps identifiers they name, so that values pass freely between the two
+// packages, and those gps identifiers are bound by the same promise.
+//
+// A tool solves a project's dependencies in three steps. It creates a
+// SourceManager, which manages a cache of the sources of projects, and of what
+// it knows about them; describes the project with SolveParameters, from its
+// packages and its Manifest; and calls Solve. The Solution names a version of
+// every project the project needs, as a Lock, and WriteDepTree writes them out:
+//
+//	sm, err := resolve.NewSourceManager(resolve.SourceManagerConfig{Cachedir: cachedir})
+//	if err != nil {
+//		return err
+//	}
+//	defer sm.Release()
+//
+//	ptree, err := resolve.ListPackages(root, importRoot)
+//	if err != nil {
+//		return err
+//	}
+//	solution, err := resolve.Solve(ctx, resolve.SolveParameters{
+//		RootDir:         root,
+//		RootPackageTree: ptree,
+//		ProjectAnalyzer: resolve.Analyzer(),
+//	}, sm)
+//	if err != nil {
+//		return err
+//	}
+//	return resolve.WriteDepTree(filepath.Join(root, "vendor"), solution, sm, prune, nil)
+package resolve // import "github.com/golang/dep/resolve"
@@ -0,0 +1,80 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package resolve_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/dep/resolve"
+)
+
+// This example solves the dependencies of the project in the current
+// directory, which has the import path github.com/example/app, and writes
+// them to its vendor directory, with nested vendor directories and unused
+// packages pruned.
+func Example() {
+	root, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sm, err := resolve.NewSourceManager(resolve.SourceManagerConfig{
+		Cachedir: filepath.Join(os.TempDir(), "resolve-cache"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sm.Release()
+
+	ptree, err := resolve.ListPackages(root, "github.com/example/app")
+	if err != nil {
+		log.Fatal(err)
+	}
+	solution, err := resolve.Solve(context.Background(), resolve.SolveParameters{
+		RootDir:         root,
+		RootPackageTree: ptree,
+		ProjectAnalyzer: resolve.Analyzer(),
+	}, sm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, lp := range solution.Projects() {
+		fmt.Printf("%s@%s\n", lp.Ident().ProjectRoot, lp.Version())
+	}
+
+	prune := resolve.CascadingPruneOptions{
+		DefaultOptions: resolve.PruneNestedVendorDirs | resolve.PruneUnusedPackages,
+	}
+	if err := resolve.WriteDepTree(filepath.Join(root, "vendor"), solution, sm, prune, nil); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// This example constrains github.com/pkg/errors to versions compatible with
+// v0.8.0, and github.com/sirupsen/logrus to its master branch, as a Manifest
+// given to the ProjectAnalyzer of a solve might.
+func ExampleSimpleManifest() {
+	c, err := resolve.NewSemverConstraint("^0.8.0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := resolve.SimpleManifest{
+		Deps: resolve.ProjectConstraints{
+			"github.com/pkg/errors":      {Constraint: c},
+			"github.com/sirupsen/logrus": {Constraint: resolve.NewBranch("master")},
+		},
+	}
+
+	fmt.Println(m.DependencyConstraints()["github.com/pkg/errors"].Constraint.Matches(resolve.NewVersion("v0.8.1")))
+	fmt.Println(m.DependencyConstraints()["github.com/pkg/errors"].Constraint.Matches(resolve.NewVersion("v0.9.0")))
+	// Output:
+	// true
+	// false
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package resolve
+
+import (
+	"context"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+// Projects and their versions.
+type (
+	// ProjectRoot is the import path at the root of a project's repository.
+	ProjectRoot = gps.ProjectRoot
+	// ProjectIdentifier names a project, and the source it is fetched from,
+	// if that is not the one its root deduces to.
+	ProjectIdentifier = gps.ProjectIdentifier
+	// Version is a branch, a tag or a revision of a project.
+	Version = gps.Version
+	// PairedVersion is a branch or tag, paired with its revision.
+	PairedVersion = gps.PairedVersion
+	// UnpairedVersion is a branch or tag, without its revision.
+	UnpairedVersion = gps.UnpairedVersion
+	// Revision is an immutable version, such as a git commit hash.
+	Revision = gps.Revision
+	// Constraint is a set of versions a project is allowed to have.
+	Constraint = gps.Constraint
+)
+
+// NewVersion returns the tag body, which is taken to be a semantic version if
+// it parses as one.
+func NewVersion(body string) UnpairedVersion { return gps.NewVersion(body) }
+
+// NewBranch returns the branch body.
+func NewBranch(body string) UnpairedVersion { return gps.NewBranch(body) }
+
+// NewSemverConstraint returns the constraint allowing the semantic versions in
+// the range body, such as "^1.2.0".
+func NewSemverConstraint(body string) (Constraint, error) { return gps.NewSemverConstraint(body) }
+
+// Any returns the constraint allowing every version.
+func Any() Constraint { return gps.Any() }
+
+// Manifests and locks.
+type (
+	// Manifest holds the constraints a project places on its dependencies.
+	Manifest = gps.Manifest
+	// RootManifest is the Manifest of the project being solved, which may
+	// also override the constraints of its dependencies, and ignore or
+	// require packages.
+	RootManifest = gps.RootManifest
+	// SimpleManifest is a Manifest holding only Deps.
+	SimpleManifest = gps.SimpleManifest
+	// ProjectConstraints maps projects to their properties in a Manifest.
+	ProjectConstraints = gps.ProjectConstraints
+	// ProjectProperties are the constraint on a project, and its source.
+	ProjectProperties = gps.ProjectProperties
+	// Lock names a version of every project a project depends on.
+	Lock = gps.Lock
+	// LockedProject is a project in a Lock, at its version, and the packages
+	// of it that are used.
+	LockedProject = gps.LockedProject
+	// ProjectAnalyzer derives the Manifests and Locks of dependencies from
+	// their contents.
+	ProjectAnalyzer = gps.ProjectAnalyzer
+	// ProjectAnalyzerInfo names a ProjectAnalyzer, and its version.
+	ProjectAnalyzerInfo = gps.ProjectAnalyzerInfo
+)
+
+// NewLockedProject returns the project id, locked to the version v, of which
+// the packages pkgs, relative to its root, are used.
+func NewLockedProject(id ProjectIdentifier, v Version, pkgs []string) LockedProject {
+	return gps.NewLockedProject(id, v, pkgs)
+}
+
+// Analyzer returns the ProjectAnalyzer dep uses, which derives the Manifests
+// and Locks of dependencies from their Gopkg.toml and Gopkg.lock files, or
+// their go.mod files.
+func Analyzer() ProjectAnalyzer { return dep.Analyzer{} }
+
+// Sources.
+type (
+	// SourceManager fetches the sources of projects, and answers questions
+	// about them. Its methods may be called concurrently.
+	SourceManager = gps.SourceManager
+	// SourceManagerConfig configures a SourceMgr.
+	SourceManagerConfig = gps.SourceManagerConfig
+	// SourceMgr is the SourceManager that keeps the sources it fetches, and
+	// what it learns about them, in a cache directory.
+	SourceMgr = gps.SourceMgr
+)
+
+// NewSourceManager returns a SourceMgr, configured by c. Only one SourceMgr
+// may use a cache directory at a time; it must be released with its Release
+// method for another to use it.
+func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
+	return gps.NewSourceManager(c)
+}
+
+// Solving.
+type (
+	// SolveParameters describe the project being solved: its directory, its
+	// packages, its RootManifest and its Lock, if it has one, and which of
+	// the locked versions may change.
+	SolveParameters = gps.SolveParameters
+	// Solution is the result of a solve: a Lock, naming the versions of the
+	// projects the project depends on.
+	Solution = gps.Solution
+	// PackageTree holds the packages of a project, as ListPackages lists them.
+	PackageTree = pkgtree.PackageTree
+)
+
+// ListPackages lists the packages in the directory root, and those below it,
+// taking it to have the import path importRoot.
+func ListPackages(root, importRoot string) (PackageTree, error) {
+	return pkgtree.ListPackages(root, importRoot)
+}
+
+// Solve finds a version of every project that the project params describes
+// depends on, directly or not, that satisfies all of their constraints, using
+// the sources of sm. It stops, with an error, when ctx is done.
+func Solve(ctx context.Context, params SolveParameters, sm SourceManager) (Solution, error) {
+	s, err := gps.Prepare(params, sm)
+	if err != nil {
+		return nil, err
+	}
+	return s.Solve(ctx)
+}
+
+// Writing dependencies.
+type (
+	// PruneOptions are the kinds of files pruned from a project.
+	PruneOptions = gps.PruneOptions
+	// CascadingPruneOptions are the PruneOptions of every project: the
+	// default ones, and those set for individual projects.
+	CascadingPruneOptions = gps.CascadingPruneOptions
+	// PruneOptionSet sets or unsets each of the PruneOptions for a project.
+	PruneOptionSet = gps.PruneOptionSet
+	// WriteProgress reports a project written by WriteDepTree.
+	WriteProgress = gps.WriteProgress
+)
+
+// The PruneOptions.
+const (
+	PruneNestedVendorDirs = gps.PruneNestedVendorDirs
+	PruneUnusedPackages   = gps.PruneUnusedPackages
+	PruneNonGoFiles       = gps.PruneNonGoFiles
+	PruneGoTestFiles      = gps.PruneGoTestFiles
+)
+
+// WriteDepTree writes the projects in l to the directory basedir, usually a
+// vendor directory, from the sources of sm, each pruned as co has it. onWrite,
+// if not nil, is called as each project is written.
+func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOptions, onWrite func(WriteProgress)) error {
+	return gps.WriteDepTree(basedir, l, sm, co, onWrite)
+}
+
+// PruneProject prunes the project lp, written to the directory baseDir, as
+// options has it.
+func PruneProject(baseDir string, lp LockedProject, options PruneOptions) error {
+	return gps.PruneProject(baseDir, lp, options)
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package resolve
+
+import (
+	"context"
+	"testing"
+)
+
+// The signatures of the functions of the package are part of its stable API;
+// these fail to compile if any of them is changed.
+var (
+	_ func(string) UnpairedVersion                                                        = NewVersion
+	_ func(string) UnpairedVersion                                                        = NewBranch
+	_ func(string) (Constraint, error)                                                    = NewSemverConstraint
+	_ func() Constraint                                                                   = Any
+	_ func(ProjectIdentifier, Version, []string) LockedProject                            = NewLockedProject
+	_ func() ProjectAnalyzer                                                              = Analyzer
+	_ func(SourceManagerConfig) (*SourceMgr, error)                                       = NewSourceManager
+	_ func(string, string) (PackageTree, error)                                           = ListPackages
+	_ func(context.Context, SolveParameters, SourceManager) (Solution, error)             = Solve
+	_ func(string, Lock, SourceManager, CascadingPruneOptions, func(WriteProgress)) error = WriteDepTree
+	_ func(string, LockedProject, PruneOptions) error                                     = PruneProject
+)
+
+// As are the interfaces the aliased types satisfy.
+var (
+	_ SourceManager = (*SourceMgr)(nil)
+	_ Manifest      = SimpleManifest{}
+	_ Version       = Revision("")
+	_ PairedVersion = NewVersion("v1.0.0").Pair("abc")
+)
+
+func TestSolveBadParameters(t *testing.T) {
+	// The parameters are checked before anything is solved, or fetched.
+	if _, err := Solve(context.Background(), SolveParameters{}, nil); err == nil {
+		t.Fatal("expected an error solving without a SourceManager")
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	if info := Analyzer().Info(); info.Name != "dep" {
+		t.Fatalf("expected dep's analyzer, got %s", info.Name)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// Tools that may be used to sign the lock.
+const (
+	SignatureGPG      = "gpg"
+	SignatureMinisign = "minisign"
+)
+
+// LockSignatureName returns the file name of the detached lock signature
+// produced by the named signing tool.
+func LockSignatureName(tool string) (string, error) {
+	switch tool {
+	case SignatureGPG:
+		return LockName + ".asc", nil
+	case SignatureMinisign:
+		return LockName + ".minisig", nil
+	}
+	return "", errors.Errorf("unsupported signing tool %q, must be %q or %q", tool, SignatureGPG, SignatureMinisign)
+}
+
+// CanonicalLock returns the canonical form of l, over which lock signatures
+// are made. It is the lock as dep would write it, without the leading comment,
+// so a signature remains valid across formatting changes that do not alter
+// the lock's contents.
+func CanonicalLock(l *Lock) ([]byte, error) {
+	if l == nil {
+		return nil, errors.Errorf("no %s to sign", LockName)
+	}
+	return l.MarshalTOML()
+}
+
+// signatureCommand returns the command that signs, or verifies the signature
+// in sigPath of, the data in dataPath with the named tool. key is optional; if
+// it is empty, the tool's default key is used. gpg verifies a signature by any
+// key in the keyring, and reports which on its standard output, for the key to
+// be checked by gpgSignedBy.
+func signatureCommand(tool string, sign bool, key, dataPath, sigPath string) (*exec.Cmd, error) {
+	var args []string
+	switch tool {
+	case SignatureGPG:
+		args = []string{"--batch", "--yes"}
+		if sign {
+			if key != "" {
+				args = append(args, "--local-user", key)
+			}
+			args = append(args, "--armor", "--output", sigPath, "--detach-sign", dataPath)
+		} else {
+			args = append(args, "--status-fd", "1", "--verify", sigPath, dataPath)
+		}
+	case SignatureMinisign:
+		if sign {
+			args = []string{"-S"}
+			if key != "" {
+				args = append(args, "-s", key)
+			}
+		} else {
+			args = []string{"-V"}
+			if key != "" {
+				args = append(args, "-p", key)
+			}
+		}
+		args = append(args, "-m", dataPath, "-x", sigPath)
+	default:
+		_, err := LockSignatureName(tool)
+		return nil, err
+	}
+	return exec.Command(tool, args...), nil
+}
+
+// SignLock writes a detached signature over the canonical form of p's lock,
+// made with the named tool, next to the lock. It returns the path of the
+// signature.
+func (c *Ctx) SignLock(p *Project, tool, key string) (string, error) {
+	name, err := LockSignatureName(tool)
+	if err != nil {
+		return "", err
+	}
+	sigPath := filepath.Join(p.AbsRoot, name)
+
+	_, err = c.runSignatureCommand(p, tool, true, key, sigPath)
+	return sigPath, errors.Wrapf(err, "could not sign %s", LockName)
+}
+
+// VerifyLockSignature checks the detached signature next to p's lock, made
+// with the named tool, against the canonical form of the lock. With gpg, if key
+// is not empty, the signature must have been made by it; otherwise, any key in
+// the keyring is trusted.
+func (c *Ctx) VerifyLockSignature(p *Project, tool, key string) error {
+	name, err := LockSignatureName(tool)
+	if err != nil {
+		return err
+	}
+	sigPath := filepath.Join(p.AbsRoot, name)
+	if _, err := os.Stat(sigPath); err != nil {
+		return errors.Wrapf(err, "could not find the signature of %s", LockName)
+	}
+
+	out, err := c.runSignatureCommand(p, tool, false, key, sigPath)
+	if err != nil {
+		return errors.Wrapf(err, "signature of %s is not valid", LockName)
+	}
+	if tool == SignatureGPG && key != "" && !gpgSignedBy(out, key) {
+		return errors.Errorf("signature of %s was not made by key %s", LockName, key)
+	}
+	return nil
+}
+
+// gpgSignedBy reports whether status, the status output of gpg --verify,
+// records a valid signature by key: the full fingerprint of the signing key or
+// its primary key, or the primary user ID of the key, or the email address in
+// it. Key IDs, being short enough to be forged, are not accepted, and neither
+// are parts of user IDs.
+func gpgSignedBy(status []byte, key string) bool {
+	fpr := strings.ToUpper(strings.TrimPrefix(strings.Replace(key, " ", "", -1), "0x"))
+	if strings.Trim(fpr, "0123456789ABCDEF") != "" || (len(fpr) != 40 && len(fpr) != 64) {
+		fpr = ""
+	}
+
+	var valid, matched bool
+	for _, line := range strings.Split(string(status), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 3 || f[0] != "[GNUPG:]" {
+			continue
+		}
+		switch f[1] {
+		case "VALIDSIG":
+			valid = true
+			// The fingerprint of the signing key comes first, and that of
+			// its primary key last.
+			if fpr != "" && (f[2] == fpr || f[len(f)-1] == fpr) {
+				matched = true
+			}
+		case "GOODSIG":
+			// The key ID is followed by the key's primary user ID.
+			uid := strings.Join(f[3:], " ")
+			if fpr == "" && gpgUserIDIs(uid, strings.TrimSpace(key)) {
+				matched = true
+			}
+		}
+	}
+	return valid && matched
+}
+
+// gpgUserIDIs reports whether key names the gpg user ID uid: either all of it,
+// or the email address it ends with, with or without its angle brackets.
+// Email addresses are compared without regard to case.
+func gpgUserIDIs(uid, key string) bool {
+	if uid == key {
+		return true
+	}
+	i := strings.LastIndex(uid, "<")
+	if i < 0 || !strings.HasSuffix(uid, ">") {
+		return false
+	}
+	email := uid[i+1 : len(uid)-1]
+	return strings.EqualFold(email, key) || strings.EqualFold(uid[i:], key)
+}
+
+// runSignatureCommand writes the canonical form of p's lock to a temporary
+// file, and signs or verifies it with the named tool. It returns the tool's
+// output.
+func (c *Ctx) runSignatureCommand(p *Project, tool string, sign bool, key, sigPath string) ([]byte, error) {
+	data, err := CanonicalLock(p.Lock)
+	if err != nil {
+		return nil, err
+	}
+
+	td, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create temp dir for the canonical lock")
+	}
+	defer os.RemoveAll(td)
+
+	dataPath := filepath.Join(td, LockName)
+	if err = ioutil.WriteFile(dataPath, data, 0666); err != nil {
+		return nil, errors.Wrap(err, "could not write the canonical lock")
+	}
+
+	cmd, err := signatureCommand(tool, sign, key, dataPath, sigPath)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = p.AbsRoot
+	c.Logger().Debugf("Running %s", cmd.Args)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(out) > 0 {
+			c.Logger().Infof("%s", out)
+		}
+		return nil, errors.Wrapf(err, "%s failed", tool)
+	}
+	if len(out) > 0 {
+		c.Logger().Debugf("%s", out)
+	}
+	return out, nil
+}
+
+// SignaturePolicy holds the manifest's [signatures] table, which requires the
+// locked versions of some projects to be signed by keys the project trusts.
+type SignaturePolicy struct {
+	// Keyring is the path, relative to the project root, of the OpenPGP
+	// keyring holding the trusted public keys.
+	Keyring string
+
+	// Projects lists the roots of the projects whose locked versions must
+	// carry a valid signature by one of the keys in Keyring: the annotated
+	// tag, for versions that are such tags, or otherwise the commit.
+	Projects []string
+}
+
+// signatureVerifier is implemented by source managers that can verify the
+// signatures of the versions of projects, such as *gps.SourceMgr.
+type signatureVerifier interface {
+	VerifySignature(ctx context.Context, id gps.ProjectIdentifier, v gps.Version, keyring string) error
+}
+
+// VerifyLockedSignatures checks that each project in l that p's manifest
+// requires to be signed is locked to a version signed as required: by a key in
+// the keyring named by the [signatures] table, and by the signer named by an
+// enforced [[sigstore]] stanza. It does nothing if the manifest requires no
+// signatures.
+func (c *Ctx) VerifyLockedSignatures(p *Project, sm gps.SourceManager, l *Lock) error {
+	if p.Manifest == nil || l == nil {
+		return nil
+	}
+
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		if sp, has := p.Manifest.Sigstore[pr]; !has || !sp.Enforce {
+			continue
+		}
+		c.Logger().Debugf("Verifying the Sigstore signature of %s at %s", pr, lp.Version())
+		if _, err := p.VerifySigstore(sm, lp); err != nil {
+			return errors.Wrapf(err, "could not verify the Sigstore signature of %s", pr)
+		}
+	}
+
+	if len(p.Manifest.Signatures.Projects) == 0 {
+		return nil
+	}
+	sv, ok := sm.(signatureVerifier)
+	if !ok {
+		return errors.New("the source manager cannot verify signatures")
+	}
+
+	keyring := p.Manifest.Signatures.Keyring
+	if keyring == "" {
+		return errors.Errorf("%s requires signatures, but names no keyring to verify them with", ManifestName)
+	}
+	if !filepath.IsAbs(keyring) {
+		keyring = filepath.Join(p.AbsRoot, keyring)
+	}
+
+	required := make(map[gps.ProjectRoot]bool, len(p.Manifest.Signatures.Projects))
+	for _, pr := range p.Manifest.Signatures.Projects {
+		required[gps.ProjectRoot(pr)] = true
+	}
+	for _, lp := range l.P {
+		if !required[lp.Ident().ProjectRoot] {
+			continue
+		}
+		c.Logger().Debugf("Verifying the signature of %s at %s", lp.Ident().ProjectRoot, lp.Version())
+		if err := sv.VerifySignature(context.TODO(), lp.Ident(), lp.Version(), keyring); err != nil {
+			return errors.Wrapf(err, "could not verify the signature of %s", lp.Ident().ProjectRoot)
+		}
+	}
+	return nil
+}
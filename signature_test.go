@@ -0,0 +1,158 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestSignatureCommand(t *testing.T) {
+	cases := []struct {
+		tool string
+		sign bool
+		key  string
+		want []string
+	}{
+		{SignatureGPG, true, "", []string{"gpg", "--batch", "--yes", "--armor", "--output", "l.sig", "--detach-sign", "l"}},
+		{SignatureGPG, true, "ABCD", []string{"gpg", "--batch", "--yes", "--local-user", "ABCD", "--armor", "--output", "l.sig", "--detach-sign", "l"}},
+		{SignatureGPG, false, "ABCD", []string{"gpg", "--batch", "--yes", "--status-fd", "1", "--verify", "l.sig", "l"}},
+		{SignatureMinisign, true, "", []string{"minisign", "-S", "-m", "l", "-x", "l.sig"}},
+		{SignatureMinisign, true, "k.key", []string{"minisign", "-S", "-s", "k.key", "-m", "l", "-x", "l.sig"}},
+		{SignatureMinisign, false, "k.pub", []string{"minisign", "-V", "-p", "k.pub", "-m", "l", "-x", "l.sig"}},
+	}
+
+	for _, c := range cases {
+		cmd, err := signatureCommand(c.tool, c.sign, c.key, "l", "l.sig")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cmd.Args, c.want) {
+			t.Errorf("unexpected command:\n\t(GOT) %v\n\t(WNT) %v", cmd.Args, c.want)
+		}
+	}
+
+	if _, err := signatureCommand("pgp", true, "", "l", "l.sig"); err == nil {
+		t.Error("expected an error for an unsupported signing tool")
+	}
+}
+
+func TestGPGSignedBy(t *testing.T) {
+	status := []byte(`[GNUPG:] NEWSIG
+gpg: Good signature from "Dep Test <dep-test@example.com>" [ultimate]
+[GNUPG:] GOODSIG 2C8F3A1B9D6E4F70 Dep Test <dep-test@example.com>
+[GNUPG:] VALIDSIG 6A1F0C2E8B3D4A597E21C4D92C8F3A1B9D6E4F70 2018-07-02 1530526863 0 4 0 22 8 00 6A1F0C2E8B3D4A597E21C4D92C8F3A1B9D6E4F70
+[GNUPG:] TRUST_ULTIMATE 0 pgp
+`)
+	cases := []struct {
+		key  string
+		want bool
+	}{
+		{"6A1F0C2E8B3D4A597E21C4D92C8F3A1B9D6E4F70", true},
+		{"0x6a1f0c2e8b3d4a597e21c4d92c8f3a1b9d6e4f70", true},
+		{"6A1F 0C2E 8B3D 4A59 7E21  C4D9 2C8F 3A1B 9D6E 4F70", true},
+		{"dep-test@example.com", true},
+		{"<Dep-Test@Example.com>", true},
+		{"Dep Test <dep-test@example.com>", true},
+		// Key IDs, and parts of fingerprints and user IDs, are not enough.
+		{"0x2c8f3a1b9d6e4f70", false},
+		{"9D6E 4F70", false},
+		{"1F0C2E8B3D4A597E21C4D92C8F3A1B9D6E4F70", false},
+		{"test@example.com", false},
+		{"Dep Test", false},
+		{"0x1111111111111111", false},
+		{"someone@example.com", false},
+	}
+	for _, c := range cases {
+		if got := gpgSignedBy(status, c.key); got != c.want {
+			t.Errorf("gpgSignedBy(status, %q) = %v, want %v", c.key, got, c.want)
+		}
+	}
+
+	// A good signature by a key that is not valid, such as an expired one,
+	// does not count.
+	if gpgSignedBy([]byte("[GNUPG:] GOODSIG 2C8F3A1B9D6E4F70 Dep Test <dep-test@example.com>\n"), "dep-test@example.com") {
+		t.Error("expected a signature without VALIDSIG not to be valid")
+	}
+
+	// A key whose user ID merely contains the one asked for does not count.
+	mallory := []byte(`[GNUPG:] GOODSIG 1111111111111111 Mallory <notbob@evil.example.com>
+[GNUPG:] VALIDSIG 2222222222222222222222221111111111111111 2018-07-02 1530526863 0 4 0 22 8 00 2222222222222222222222221111111111111111
+`)
+	for _, key := range []string{"bob", "bob@evil.example.com"} {
+		if gpgSignedBy(mallory, key) {
+			t.Errorf("expected a signature by Mallory not to count as one by %q", key)
+		}
+	}
+}
+
+func TestSignLockGPG(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping gpg key generation in short mode")
+	}
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not available")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("gnupg")
+	h.TempDir("proj")
+	h.Must(os.Chmod(h.Path("gnupg"), 0700))
+	defer os.Setenv("GNUPGHOME", os.Getenv("GNUPGHOME"))
+	os.Setenv("GNUPGHOME", h.Path("gnupg"))
+	h.Must(exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "dep-test@example.com").Run())
+	defer exec.Command("gpgconf", "--kill", "gpg-agent").Run()
+
+	ctx := &Ctx{
+		Out: log.New(ioutil.Discard, "", 0),
+		Err: log.New(ioutil.Discard, "", 0),
+	}
+	p := &Project{
+		AbsRoot: h.Path("proj"),
+		Lock: &Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(
+					gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+					gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+					[]string{"."},
+				),
+			},
+		},
+	}
+
+	if err := ctx.VerifyLockSignature(p, SignatureGPG, ""); err == nil {
+		t.Fatal("expected an error verifying a missing signature")
+	}
+	if _, err := ctx.SignLock(p, SignatureGPG, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.VerifyLockSignature(p, SignatureGPG, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.VerifyLockSignature(p, SignatureGPG, "dep-test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.VerifyLockSignature(p, SignatureGPG, "someone@example.com"); err == nil {
+		t.Fatal("expected an error verifying a signature made by another key")
+	}
+
+	p.Lock.P[0] = gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+		gps.NewVersion("v1.0.1").Pair("a0196baa11ea047dd65037287451d36b861b00ea"),
+		[]string{"."},
+	)
+	if err := ctx.VerifyLockSignature(p, SignatureGPG, ""); err == nil {
+		t.Fatal("expected an error verifying the signature of an altered lock")
+	}
+}
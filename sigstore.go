@@ -0,0 +1,50 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// SigstorePolicy holds a [[sigstore]] stanza of the manifest, which names who
+// signs the versions of a project with Sigstore.
+type SigstorePolicy struct {
+	// Identity and Issuer are the subject of the signing certificate, and the
+	// OpenID Connect issuer that vouched for it.
+	Identity string
+	Issuer   string
+
+	// Enforce is true if dep ensure refuses to lock or vendor versions of the
+	// project that are not so signed. Otherwise, the signatures are only
+	// reported by dep status.
+	Enforce bool
+}
+
+type sigstoreVerifier interface {
+	VerifySigstore(ctx context.Context, id gps.ProjectIdentifier, v gps.Version, signer gps.SigstoreIdentity) error
+}
+
+// VerifySigstore verifies the Sigstore signature of the version lp is locked
+// to against the [[sigstore]] stanza for it in p's manifest. It returns false
+// if the manifest has no such stanza, and so nothing was verified.
+func (p *Project) VerifySigstore(sm gps.SourceManager, lp gps.LockedProject) (bool, error) {
+	if p.Manifest == nil {
+		return false, nil
+	}
+	sp, has := p.Manifest.Sigstore[lp.Ident().ProjectRoot]
+	if !has {
+		return false, nil
+	}
+	sv, ok := sm.(sigstoreVerifier)
+	if !ok {
+		return true, errors.New("the source manager cannot verify Sigstore signatures")
+	}
+
+	signer := gps.SigstoreIdentity{Identity: sp.Identity, Issuer: sp.Issuer}
+	return true, sv.VerifySigstore(context.TODO(), lp.Ident(), lp.Version(), signer)
+}
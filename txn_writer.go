@@ -10,9 +10,12 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/logging"
+	"github.com/golang/dep/gps/pkgtree"
 	"github.com/golang/dep/internal/fs"
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
@@ -67,6 +70,14 @@ type SafeWriter struct {
 	writeVendor  bool
 	writeLock    bool
 	pruneOptions gps.CascadingPruneOptions
+	exclude      map[gps.ProjectRoot]bool
+	trees        *gps.TreeStore
+	symlink      bool
+	memo         *pkgtree.DigestMemo
+
+	// recordDigests is whether the lock is written out along with vendor/
+	// when the digests of what is vendored differ from those it records.
+	recordDigests bool
 }
 
 // NewSafeWriter sets up a SafeWriter to write a set of manifest, lock, and
@@ -83,12 +94,23 @@ type SafeWriter struct {
 //
 // - If oldLock is provided without newLock, error.
 //
+// - If oldLock is provided, its metadata is carried over to newLock, as are
+// the digests of projects that are unchanged between the two.
+//
 // - If vendor is VendorAlways without a newLock, error.
+//
+// - If vendor is written out, the digests of what is vendored are recorded in
+// newLock, and it is written out if they differ from those it had, or it is of
+// an older version, unless the same lock is passed as oldLock and newLock: a
+// lock that was not solved anew is only written if RecordDigests is called.
 func NewSafeWriter(manifest *Manifest, oldLock, newLock *Lock, vendor VendorBehavior, prune gps.CascadingPruneOptions) (*SafeWriter, error) {
 	sw := &SafeWriter{
 		Manifest:     manifest,
 		lock:         newLock,
 		pruneOptions: prune,
+		// A lock merely being vendored again, as by dep ensure -vendor-only, is
+		// left as it is.
+		recordDigests: newLock != oldLock,
 	}
 
 	if oldLock != nil {
@@ -96,6 +118,11 @@ func NewSafeWriter(manifest *Manifest, oldLock, newLock *Lock, vendor VendorBeha
 			return nil, errors.New("must provide newLock when oldLock is specified")
 		}
 
+		// Metadata is not part of a solution, so keep whatever the existing
+		// lock had.
+		newLock.preserveMetadata(oldLock)
+		newLock.preserveDigests(oldLock)
+		newLock.preserveRepos(oldLock)
 		sw.lockDiff = gps.DiffLocks(oldLock, newLock)
 		if sw.lockDiff != nil {
 			sw.writeLock = true
@@ -118,6 +145,51 @@ func NewSafeWriter(manifest *Manifest, oldLock, newLock *Lock, vendor VendorBeha
 	return sw, nil
 }
 
+// ExcludeFromVendor leaves the projects in excluded out of the vendor tree
+// that is written. They are still written to the lock, with the digests it
+// already held for them.
+func (sw *SafeWriter) ExcludeFromVendor(excluded map[gps.ProjectRoot]bool) {
+	sw.exclude = excluded
+}
+
+// UseTreeStore makes the projects written to vendor/ come from their pruned
+// trees in ts, as gps.WriteDepTreeFrom writes them, so that projects already
+// prepared for any vendor/ on the machine are not exported and pruned again.
+//
+// If symlink is true, the projects are symlinks to their trees, as
+// gps.LinkDepTree writes them, rather than copies. Projects already vendored
+// as copies are then written out again as symlinks, and otherwise, projects
+// vendored as symlinks are written out again as copies.
+func (sw *SafeWriter) UseTreeStore(ts gps.TreeStore, symlink bool) {
+	sw.trees = &ts
+	sw.symlink = symlink
+}
+
+// UseDigestMemo makes sw take the digests of vendored projects from memo
+// where it can, rather than reading every file in them, and record the
+// digests it computes in memo.
+func (sw *SafeWriter) UseDigestMemo(memo *pkgtree.DigestMemo) {
+	sw.memo = memo
+}
+
+// RecordDigests has the lock written out along with vendor/, as if it were
+// solved anew, if the digests of what is vendored differ from those it records
+// or it is of an older version, even if the same lock was passed to
+// NewSafeWriter as old and new. Bare dep ensure does so when vendor/ has
+// drifted from a lock that is in sync, as a lock that is not solved again is
+// otherwise never upgraded.
+func (sw *SafeWriter) RecordDigests() {
+	sw.recordDigests = true
+}
+
+// KeepLock keeps sw from writing the lock at all, leaving the lock file as it
+// is, for a lock that was not read from it, such as one given to dep ensure
+// -from-lock-stdin. The lock is still what vendor/ is written out from.
+func (sw *SafeWriter) KeepLock() {
+	sw.writeLock = false
+	sw.recordDigests = false
+}
+
 // HasLock checks if a Lock is present in the SafeWriter
 func (sw *SafeWriter) HasLock() bool {
 	return sw.lock != nil
@@ -268,9 +340,10 @@ func (sw SafeWriter) validate(root string, sm gps.SourceManager) error {
 // This mostly guarantees that dep cannot exit with a partial write that would
 // leave an undefined state on disk.
 //
-// If logger is not nil, progress will be logged after each project write.
-func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, logger *log.Logger) error {
-	err := sw.validate(root, sm)
+// If logger is not nil, progress will be logged at the debug level after each
+// project write.
+func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, logger logging.Logger) (err error) {
+	err = sw.validate(root, sm)
 	if err != nil {
 		return err
 	}
@@ -284,11 +357,54 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 	lpath := filepath.Join(root, LockName)
 	vpath := filepath.Join(root, "vendor")
 
-	td, err := ioutil.TempDir(os.TempDir(), "dep")
+	// Stage everything beside the project where possible, rather than in the
+	// system's temp dir, so that the new vendor/ is on the same filesystem as
+	// root: then it is renamed into place rather than copied, and the files
+	// that fs.LinkDir cloned or hard linked from the cache stay that way.
+	td, err := ioutil.TempDir(root, ".dep")
+	if err != nil {
+		td, err = ioutil.TempDir(os.TempDir(), "dep")
+	}
 	if err != nil {
 		return errors.Wrap(err, "error while creating temp dir for writing manifest/lock/vendor")
 	}
-	defer os.RemoveAll(td)
+	// keepTemp is set when the originals could not be restored from td, so
+	// that they are not lost with it.
+	var keepTemp bool
+	defer func() {
+		if !keepTemp {
+			fs.RemoveAll(td)
+		}
+	}()
+
+	// reused holds the projects moved out of the existing vendor/, to
+	// be carried over into the new one. Unless the new vendor/ is written in
+	// its place, they are moved back, from wherever they are by then.
+	var reused map[gps.ProjectRoot]bool
+	var vendorWritten bool
+	defer func() {
+		if vendorWritten {
+			return
+		}
+		for pr := range reused {
+			from := filepath.Join(td, "reused", string(pr))
+			if _, err := os.Stat(from); err != nil {
+				from = filepath.Join(td, "vendor", string(pr))
+			}
+			// Nothing more we can do on rerr here, as we're already in
+			// recovery mode, than not lose the project with td, and say
+			// where it is.
+			to := filepath.Join(vpath, string(pr))
+			if rerr := fs.RenameWithFallback(from, to); rerr != nil {
+				keepTemp = true
+				if err == nil {
+					err = errors.Errorf("%s could not be restored, and is left at %s (%v)", to, from, rerr)
+				} else {
+					err = errors.Wrapf(err, "%s could not be restored, and is left at %s (%v)", to, from, rerr)
+				}
+			}
+		}
+	}()
 
 	if sw.HasManifest() {
 		// Always write the example text to the bottom of the TOML file.
@@ -309,6 +425,74 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 		}
 	}
 
+	if sw.writeVendor {
+		// written holds the digests of the projects that were computed as
+		// they were written, which need not be read again to compute them.
+		written := make(map[gps.ProjectRoot][]byte)
+		onWrite := func(progress gps.WriteProgress) {
+			if progress.Digest != nil {
+				written[progress.LP.Ident().ProjectRoot] = progress.Digest
+			}
+			if logger != nil {
+				logger.Debugf("%s", progress)
+			}
+		}
+		// Projects vendored just as the lock says they last were are carried
+		// over from the existing vendor/, rather than written out again. They
+		// are set aside until the rest are written, as WriteDepTree removes
+		// everything it was writing to if it fails.
+		reused, err = sw.reuseVendored(vpath, filepath.Join(td, "reused"))
+		if err != nil {
+			return errors.Wrap(err, "error while reusing vendored projects")
+		}
+
+		var vps []gps.LockedProject
+		for _, lp := range sw.lock.P {
+			pr := lp.Ident().ProjectRoot
+			if !sw.exclude[pr] && !reused[pr] {
+				vps = append(vps, lp)
+			}
+		}
+		switch {
+		case sw.trees != nil && sw.symlink:
+			err = gps.LinkDepTree(filepath.Join(td, "vendor"), gps.SimpleLock(vps), sm, sw.pruneOptions, *sw.trees, onWrite)
+		case sw.trees != nil:
+			err = gps.WriteDepTreeFrom(filepath.Join(td, "vendor"), gps.SimpleLock(vps), sm, sw.pruneOptions, *sw.trees, onWrite)
+		default:
+			err = gps.WriteDepTree(filepath.Join(td, "vendor"), gps.SimpleLock(vps), sm, sw.pruneOptions, onWrite)
+		}
+		if err != nil {
+			return errors.Wrap(err, "error while writing out vendor tree")
+		}
+		for pr := range reused {
+			to := filepath.Join(td, "vendor", string(pr))
+			if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+				return errors.Wrap(err, "error while reusing vendored projects")
+			}
+			if err := fs.RenameWithFallback(filepath.Join(td, "reused", string(pr)), to); err != nil {
+				return errors.Wrap(err, "error while reusing vendored projects")
+			}
+		}
+
+		// Record the digests of what was just vendored. If they differ from
+		// the lock's, or the lock predates digests, the lock is written out as
+		// well, if it may be.
+		skip := make(map[gps.ProjectRoot]bool, len(sw.exclude)+len(reused))
+		for pr := range sw.exclude {
+			skip[pr] = true
+		}
+		for pr := range reused {
+			skip[pr] = true
+		}
+		changed, err := sw.lock.updateDigests(filepath.Join(td, "vendor"), skip, written, sw.pruneOptions, sw.memo)
+		if err != nil {
+			return errors.Wrap(err, "error while computing digests of vendor tree")
+		}
+		if sw.recordDigests && (changed || sw.lock.Version < CurrentLockVersion) {
+			sw.writeLock = true
+		}
+	}
+
 	if sw.writeLock {
 		l, err := sw.lock.MarshalTOML()
 		if err != nil {
@@ -320,19 +504,6 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 		}
 	}
 
-	if sw.writeVendor {
-		var onWrite func(gps.WriteProgress)
-		if logger != nil {
-			onWrite = func(progress gps.WriteProgress) {
-				logger.Println(progress)
-			}
-		}
-		err = gps.WriteDepTree(filepath.Join(td, "vendor"), sw.lock, sm, sw.pruneOptions, onWrite)
-		if err != nil {
-			return errors.Wrap(err, "error while writing out vendor tree")
-		}
-	}
-
 	// Ensure vendor/.git is preserved if present
 	if hasDotGit(vpath) {
 		err = fs.RenameWithFallback(filepath.Join(vpath, ".git"), filepath.Join(td, "vendor/.git"))
@@ -343,6 +514,8 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 
 	// Move the existing files and dirs to the temp dir while we put the new
 	// ones in, to provide insurance against errors for as long as possible.
+	// Each is to be restored as soon as its move out is attempted, as a move
+	// that fails partway may leave it whole only where it was moved to.
 	type pathpair struct {
 		from, to string
 	}
@@ -354,11 +527,11 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 		if _, err := os.Stat(mpath); err == nil {
 			// Move out the old one.
 			tmploc := filepath.Join(td, ManifestName+".orig")
+			restore = append(restore, pathpair{from: tmploc, to: mpath})
 			failerr = fs.RenameWithFallback(mpath, tmploc)
 			if failerr != nil {
 				goto fail
 			}
-			restore = append(restore, pathpair{from: tmploc, to: mpath})
 		}
 
 		// Move in the new one.
@@ -372,12 +545,11 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 		if _, err := os.Stat(lpath); err == nil {
 			// Move out the old one.
 			tmploc := filepath.Join(td, LockName+".orig")
-
+			restore = append(restore, pathpair{from: tmploc, to: lpath})
 			failerr = fs.RenameWithFallback(lpath, tmploc)
 			if failerr != nil {
 				goto fail
 			}
-			restore = append(restore, pathpair{from: tmploc, to: lpath})
 		}
 
 		// Move in the new one.
@@ -399,11 +571,11 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 				vendorbak = filepath.Join(td, ".vendor.orig")
 			}
 
+			restore = append(restore, pathpair{from: vendorbak, to: vpath})
 			failerr = fs.RenameWithFallback(vpath, vendorbak)
 			if failerr != nil {
 				goto fail
 			}
-			restore = append(restore, pathpair{from: vendorbak, to: vpath})
 		}
 
 		// Move in the new one.
@@ -411,26 +583,83 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 		if failerr != nil {
 			goto fail
 		}
+		vendorWritten = true
 	}
 
-	// Renames all went smoothly. The deferred os.RemoveAll will get the temp
+	// Renames all went smoothly. The deferred fs.RemoveAll will get the temp
 	// dir, but if we wrote vendor, we have to clean that up directly
 	if sw.writeVendor {
 		// Nothing we can really do about an error at this point, so ignore it
-		os.RemoveAll(vendorbak)
+		fs.RemoveAll(vendorbak)
+		// The memo only saves work; losing it costs no more than computing
+		// the digests again.
+		sw.memo.Save()
 	}
 
 	return nil
 
 fail:
-	// If we failed at any point, move all the things back into place, then bail.
+	// If we failed at any point, move all the things back into place, over
+	// whatever was moved in, then bail.
 	for _, pair := range restore {
-		// Nothing we can do on err here, as we're already in recovery mode.
-		fs.RenameWithFallback(pair.from, pair.to)
+		if err := fs.RestoreWithFallback(pair.from, pair.to); err != nil {
+			keepTemp = true
+			failerr = errors.Wrapf(failerr, "%s could not be restored, and is left at %s (%v)", pair.to, pair.from, err)
+		}
 	}
 	return failerr
 }
 
+// reuseVendored moves each project in the lock that is vendored in vpath just
+// as the lock says it last was, with the same prune options, over to the same
+// place under to, and returns the roots of those it moved. Projects nested in,
+// or containing, other projects are left to be written out again, as they
+// cannot be moved without them.
+func (sw *SafeWriter) reuseVendored(vpath, to string) (map[gps.ProjectRoot]bool, error) {
+	if _, err := os.Stat(vpath); err != nil {
+		return nil, nil
+	}
+
+	roots := make(map[gps.ProjectRoot]bool, len(sw.lock.P))
+	for _, lp := range sw.lock.P {
+		roots[lp.Ident().ProjectRoot] = true
+	}
+	// Each root is looked up among the parent directories of every other,
+	// as sorting them would put roots such as foo/bar-baz between foo/bar
+	// and foo/bar/sub.
+	nested := make(map[gps.ProjectRoot]bool)
+	for root := range roots {
+		for dir := path.Dir(string(root)); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if roots[gps.ProjectRoot(dir)] {
+				nested[gps.ProjectRoot(dir)] = true
+				nested[root] = true
+			}
+		}
+	}
+
+	reused := make(map[gps.ProjectRoot]bool)
+	for _, lp := range sw.lock.P {
+		pr := lp.Ident().ProjectRoot
+		if sw.exclude[pr] || nested[pr] || !sw.lock.vendoredIntact(vpath, pr, sw.pruneOptions, sw.memo) {
+			continue
+		}
+		// Projects vendored as symlinks are only carried over as symlinks,
+		// and copies as copies.
+		if isSymlink, _ := fs.IsSymlink(filepath.Join(vpath, string(pr))); isSymlink != sw.symlink {
+			continue
+		}
+		dst := filepath.Join(to, string(pr))
+		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			return reused, err
+		}
+		if err := fs.RenameWithFallback(filepath.Join(vpath, string(pr)), dst); err != nil {
+			return reused, err
+		}
+		reused[pr] = true
+	}
+	return reused, nil
+}
+
 // PrintPreparedActions logs the actions a call to Write would perform.
 func (sw *SafeWriter) PrintPreparedActions(output *log.Logger, verbose bool) error {
 	if sw.HasManifest() {
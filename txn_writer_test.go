@@ -91,6 +91,43 @@ func TestSafeWriter_BadInput_OldLockOnly(t *testing.T) {
 	}
 }
 
+func TestSafeWriter_RecordDigests(t *testing.T) {
+	l := &Lock{Version: 1}
+
+	// Vendoring the same lock again, as -vendor-only does, leaves it as it is.
+	sw, err := NewSafeWriter(nil, l, l, VendorAlways, defaultCascadingPruneOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sw.writeLock || sw.recordDigests {
+		t.Fatal("expected the same lock as old and new not to be written out")
+	}
+	sw.RecordDigests()
+	if !sw.recordDigests {
+		t.Fatal("expected RecordDigests to have the lock's digests recorded")
+	}
+
+	// A lock solved anew records the digests of what is vendored.
+	sw, err = NewSafeWriter(nil, l, &Lock{}, VendorAlways, defaultCascadingPruneOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sw.recordDigests {
+		t.Fatal("expected a new lock to record the digests of what is vendored")
+	}
+}
+
+func TestSafeWriter_KeepLock(t *testing.T) {
+	sw, err := NewSafeWriter(nil, nil, &Lock{}, VendorNever, defaultCascadingPruneOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.KeepLock()
+	if sw.writeLock || sw.recordDigests {
+		t.Fatal("expected the lock not to be written out")
+	}
+}
+
 func TestSafeWriter_BadInput_NonexistentRoot(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -599,3 +636,48 @@ func TestSafeWriter_VendorDotGitPreservedWithForceVendor(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestSafeWriter_ReusesIntactVendoredProjects(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	pc := NewTestProjectContext(h, safeWriterProject)
+	defer pc.Release()
+	h.TempFile(filepath.Join(pc.tempProjectDir, "vendor/github.com/foo/bar/bar.go"), "package bar")
+	h.TempFile(filepath.Join(pc.tempProjectDir, "vendor/github.com/foo/baz/baz.go"), "package baz")
+
+	// Neither project can be retrieved, so they can only be vendored by
+	// reusing what is already in vendor/.
+	bar := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar", Source: "file:///no/such/bar"},
+		gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+		[]string{"."},
+	)
+	baz := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz", Source: "file:///no/such/baz"},
+		gps.NewVersion("v1.0.0").Pair("c6335b6b7d7a1e9f5a1f9e3b3c7d0b6c1b5c4e3a"),
+		[]string{"."},
+	)
+	l := &Lock{P: []gps.LockedProject{bar, baz}}
+	vpath := filepath.Join(pc.Project.AbsRoot, "vendor")
+	if _, err := l.updateDigests(vpath, nil, nil, defaultCascadingPruneOptions(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	sw, _ := NewSafeWriter(nil, l, l, VendorAlways, defaultCascadingPruneOptions())
+	h.Must(errors.Wrap(sw.Write(pc.Project.AbsRoot, pc.SourceManager, true, nil), "SafeWriter.Write failed"))
+	if err := pc.VendorFileShouldExist("github.com/foo/baz/baz.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	// baz no longer matches its digest, and must be written out again. As it
+	// cannot be, the write fails, and bar is left where it was.
+	h.TempFile(filepath.Join(pc.tempProjectDir, "vendor/github.com/foo/baz/baz.go"), "package baz // changed")
+	sw, _ = NewSafeWriter(nil, l, l, VendorAlways, defaultCascadingPruneOptions())
+	if err := sw.Write(pc.Project.AbsRoot, pc.SourceManager, true, nil); err == nil {
+		t.Fatal("expected an error writing out a project that cannot be retrieved")
+	}
+	if err := pc.VendorFileShouldExist("github.com/foo/bar/bar.go"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/events"
+)
+
+// popularProjects are the roots of widely used projects, which the roots of
+// newly added dependencies are compared with, along with those already locked.
+var popularProjects = []gps.ProjectRoot{
+	"github.com/BurntSushi/toml",
+	"github.com/aws/aws-sdk-go",
+	"github.com/boltdb/bolt",
+	"github.com/davecgh/go-spew",
+	"github.com/dgrijalva/jwt-go",
+	"github.com/fsnotify/fsnotify",
+	"github.com/gin-gonic/gin",
+	"github.com/go-sql-driver/mysql",
+	"github.com/gogo/protobuf",
+	"github.com/golang/protobuf",
+	"github.com/google/go-cmp",
+	"github.com/google/uuid",
+	"github.com/gorilla/mux",
+	"github.com/gorilla/websocket",
+	"github.com/hashicorp/go-multierror",
+	"github.com/lib/pq",
+	"github.com/mattn/go-sqlite3",
+	"github.com/pelletier/go-toml",
+	"github.com/pkg/errors",
+	"github.com/pmezard/go-difflib",
+	"github.com/prometheus/client_golang",
+	"github.com/satori/go.uuid",
+	"github.com/sirupsen/logrus",
+	"github.com/spf13/cobra",
+	"github.com/spf13/pflag",
+	"github.com/spf13/viper",
+	"github.com/stretchr/testify",
+	"github.com/urfave/cli",
+	"golang.org/x/crypto",
+	"golang.org/x/net",
+	"golang.org/x/oauth2",
+	"golang.org/x/sync",
+	"golang.org/x/sys",
+	"golang.org/x/text",
+	"google.golang.org/grpc",
+	"gopkg.in/check.v1",
+	"gopkg.in/yaml.v2",
+	"k8s.io/apimachinery",
+	"k8s.io/client-go",
+}
+
+// majorVersionSuffix matches the major versions at the end of project roots,
+// as in gopkg.in/yaml.v2, or github.com/foo/bar/v2.
+var majorVersionSuffix = regexp.MustCompile(`(\.v[0-9]+|/v[0-9]+)$`)
+
+// SimilarProject returns the first of known that root is suspiciously similar
+// to, as the root of a project registered to be mistaken for it would be.
+//
+// Roots are similar if they differ by an edit distance of one, or two for
+// longer roots, over their hosts, owners and repositories together. Roots of
+// the same owner - the same host and, for roots of three or more elements,
+// the same first path element - are never similar, nor are roots that differ
+// only by their major versions, as those are usually related projects.
+func SimilarProject(root gps.ProjectRoot, known []gps.ProjectRoot) (gps.ProjectRoot, bool) {
+	r := majorVersionSuffix.ReplaceAllString(string(root), "")
+	for _, k := range known {
+		if k == root {
+			return "", false
+		}
+	}
+
+	for _, k := range known {
+		kr := majorVersionSuffix.ReplaceAllString(string(k), "")
+		if kr == r || sameOwner(r, kr) {
+			continue
+		}
+		max := 1
+		if len(r) >= 20 {
+			max = 2
+		}
+		if editDistance(r, kr) <= max {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// sameOwner reports whether the roots a and b belong to the same owner.
+func sameOwner(a, b string) bool {
+	ae, be := strings.Split(a, "/"), strings.Split(b, "/")
+	if len(ae) != len(be) {
+		return false
+	}
+	if len(ae) < 3 {
+		return false
+	}
+	return ae[0] == be[0] && ae[1] == be[1]
+}
+
+// editDistance returns the Levenshtein distance between a and b: the number
+// of bytes that must be inserted, deleted or substituted to turn one into the
+// other.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// vanityResolver is implemented by source managers that can find the
+// repositories of vanity import paths, such as *gps.SourceMgr.
+type vanityResolver interface {
+	VanityRepository(ip string) (string, bool, error)
+}
+
+// WarnSuspiciousProjects warns about projects in l that may have been
+// substituted for the ones p means to depend on:
+//
+// - projects not in p's lock whose roots are similar to those of projects
+// that are, or of popular projects, as SimilarProject says;
+//
+// - projects whose roots are vanity import paths, and whose go get metadata
+// names another repository than the one recorded in p's lock.
+//
+// The repositories of l's vanity import paths are recorded in l.
+func (c *Ctx) WarnSuspiciousProjects(p *Project, sm gps.SourceManager, l *Lock) {
+	if l == nil {
+		return
+	}
+	old := p.Lock
+	if old == nil {
+		old = &Lock{}
+	}
+
+	oldSources := make(map[gps.ProjectRoot]string, len(old.P))
+	known := make([]gps.ProjectRoot, 0, len(old.P)+len(popularProjects))
+	for _, lp := range old.P {
+		oldSources[lp.Ident().ProjectRoot] = lp.Ident().Source
+		known = append(known, lp.Ident().ProjectRoot)
+	}
+	known = append(known, popularProjects...)
+
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		if _, has := oldSources[pr]; has {
+			continue
+		}
+		if similar, ok := SimilarProject(pr, known); ok {
+			msg := fmt.Sprintf("%s, newly added to %s, has a root similar to that of %s; make sure it is the project you mean to depend on", pr, LockName, similar)
+			c.Logger().Warnf("%s", msg)
+			events.Warn(msg)
+		}
+	}
+
+	vr, ok := sm.(vanityResolver)
+	if !ok {
+		return
+	}
+	for _, lp := range l.P {
+		id := lp.Ident()
+		if id.Source != "" {
+			continue
+		}
+		repo, vanity, err := vr.VanityRepository(string(id.ProjectRoot))
+		if err != nil {
+			c.Logger().Debugf("Could not find the repository of %s: %s", id.ProjectRoot, err)
+			continue
+		}
+		if !vanity {
+			continue
+		}
+
+		src, had := oldSources[id.ProjectRoot]
+		if prev := old.Repos[id.ProjectRoot]; had && src == "" && prev != "" && prev != repo {
+			msg := fmt.Sprintf("the go get metadata of %s now names the repository %s, rather than %s as recorded in %s; make sure its import path has not been taken over", id.ProjectRoot, repo, prev, LockName)
+			c.Logger().Warnf("%s", msg)
+			events.Warn(msg)
+		}
+		if l.Repos == nil {
+			l.Repos = make(map[gps.ProjectRoot]string)
+		}
+		l.Repos[id.ProjectRoot] = repo
+	}
+}
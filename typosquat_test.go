@@ -0,0 +1,120 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestSimilarProject(t *testing.T) {
+	known := []gps.ProjectRoot{
+		"github.com/pkg/errors",
+		"github.com/sirupsen/logrus",
+		"golang.org/x/sys",
+		"gopkg.in/yaml.v2",
+		"github.com/foo/bar",
+	}
+
+	cases := []struct {
+		root    gps.ProjectRoot
+		similar gps.ProjectRoot
+	}{
+		{"github.com/pkgg/errors", "github.com/pkg/errors"},
+		{"github.com/pkgs/error", "github.com/pkg/errors"},
+		{"github.com/Sirupsen/logrus", "github.com/sirupsen/logrus"},
+		{"githab.com/foo/bar", "github.com/foo/bar"},
+		{"gopkg.in/yml.v2", "gopkg.in/yaml.v2"},
+		{"github.com/pkg/errors", ""},
+		{"github.com/pkg/errors/v2", ""},
+		{"gopkg.in/yaml.v3", ""},
+		{"golang.org/x/sync", ""},
+		{"github.com/foo/baz", ""},
+		{"github.com/fob/baz", ""},
+		{"github.com/go-errors/errors", ""},
+	}
+	for _, c := range cases {
+		got, ok := SimilarProject(c.root, known)
+		if got != c.similar || ok != (c.similar != "") {
+			t.Errorf("%s: unexpected similar project:\n\t(GOT) %q, %v\n\t(WNT) %q", c.root, got, ok, c.similar)
+		}
+	}
+}
+
+type vanitySourceManager struct {
+	gps.SourceManager
+	repos map[string]string
+}
+
+func (sm vanitySourceManager) VanityRepository(ip string) (string, bool, error) {
+	repo, has := sm.repos[ip]
+	return repo, has, nil
+}
+
+func TestWarnSuspiciousProjects(t *testing.T) {
+	mk := func(root, source string) gps.LockedProject {
+		return gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root), Source: source},
+			gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+			[]string{"."},
+		)
+	}
+
+	p := &Project{
+		Lock: &Lock{
+			P: []gps.LockedProject{
+				mk("example.com/foo", ""),
+				mk("example.com/bar", ""),
+				mk("example.com/baz", "https://github.com/fork/baz"),
+			},
+			Repos: map[gps.ProjectRoot]string{
+				"example.com/foo": "https://git.example.com/foo",
+				"example.com/bar": "https://git.example.com/bar",
+			},
+		},
+	}
+	l := &Lock{
+		P: []gps.LockedProject{
+			mk("example.com/foo", ""),
+			mk("example.com/bar", ""),
+			mk("example.com/baz", "https://github.com/fork/baz"),
+			mk("github.com/pkgg/errors", ""),
+			mk("example.com/qux", ""),
+		},
+	}
+	sm := vanitySourceManager{repos: map[string]string{
+		"example.com/foo": "https://git.example.com/foo",
+		"example.com/bar": "https://git.evil.com/bar",
+		"example.com/baz": "https://git.example.com/baz",
+		"example.com/qux": "https://git.example.com/qux",
+	}}
+
+	var buf bytes.Buffer
+	ctx := &Ctx{Err: log.New(&buf, "", 0)}
+	ctx.WarnSuspiciousProjects(p, sm, l)
+
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"Warning: github.com/pkgg/errors, newly added to Gopkg.lock, has a root similar to that of github.com/pkg/errors; make sure it is the project you mean to depend on",
+		"Warning: the go get metadata of example.com/bar now names the repository https://git.evil.com/bar, rather than https://git.example.com/bar as recorded in Gopkg.lock; make sure its import path has not been taken over",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected warnings:\n\t(GOT) %q\n\t(WNT) %q", got, want)
+	}
+
+	wantRepos := map[gps.ProjectRoot]string{
+		"example.com/foo": "https://git.example.com/foo",
+		"example.com/bar": "https://git.evil.com/bar",
+		"example.com/qux": "https://git.example.com/qux",
+	}
+	if !reflect.DeepEqual(l.Repos, wantRepos) {
+		t.Fatalf("unexpected repos recorded:\n\t(GOT) %v\n\t(WNT) %v", l.Repos, wantRepos)
+	}
+}
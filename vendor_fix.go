@@ -0,0 +1,195 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// QuarantineDir is the directory, in the root of a project, into which
+// vendored projects that no longer match their digests are moved when they
+// are written out again, so that they can be inspected.
+const QuarantineDir = ".quarantine"
+
+// VendorFix describes a vendored project that was written out afresh because
+// it did not match its digest in the lock.
+type VendorFix struct {
+	ProjectRoot gps.ProjectRoot
+
+	// Quarantined is where the project's altered contents were moved to. It
+	// is empty if the project was missing from vendor/ altogether.
+	Quarantined string
+
+	// Added, Removed and Modified are the slash-separated paths, relative to
+	// the project's root, of the files that the altered contents had that the
+	// pristine contents do not, lacked, or had with other contents.
+	Added, Removed, Modified []string
+}
+
+// FixVendor writes out afresh each project that status, as returned by
+// VerifyVendor, reports as missing from p's vendor directory or not matching
+// its digest in p's lock. The altered contents of each are first moved into
+// QuarantineDir.
+//
+// A project is only replaced if its pristine contents, retrieved through sm
+// and pruned as p's manifest says, match the digest in the lock.
+func (p *Project) FixVendor(sm gps.SourceManager, status map[string]pkgtree.VendorStatus) ([]VendorFix, error) {
+	if p.Lock == nil {
+		return nil, errors.Errorf("no %s to fix %s against", LockName, "vendor/")
+	}
+
+	var prune gps.CascadingPruneOptions
+	if p.Manifest != nil {
+		prune = p.Manifest.PruneOptions
+	}
+	vpath := filepath.Join(p.AbsRoot, "vendor")
+	// Quarantined projects are kept under a vendor directory of their own,
+	// so that they are not mistaken for packages of p.
+	qpath := filepath.Join(p.AbsRoot, QuarantineDir, time.Now().Format("20060102150405"), "vendor")
+
+	var fixes []VendorFix
+	for _, lp := range p.Lock.P {
+		pr := lp.Ident().ProjectRoot
+		switch status[string(pr)] {
+		case pkgtree.NotInTree, pkgtree.DigestMismatchInLock:
+		default:
+			continue
+		}
+
+		fix, err := p.fixVendored(sm, lp, prune.PruneOptionsFor(pr), vpath, qpath)
+		if err != nil {
+			return fixes, errors.Wrapf(err, "could not fix vendor/%s", pr)
+		}
+		fixes = append(fixes, fix)
+	}
+	return fixes, nil
+}
+
+// fixVendored writes out lp afresh in vpath, moving its altered contents into
+// qpath first.
+func (p *Project) fixVendored(sm gps.SourceManager, lp gps.LockedProject, po gps.PruneOptions, vpath, qpath string) (VendorFix, error) {
+	pr := lp.Ident().ProjectRoot
+	fix := VendorFix{ProjectRoot: pr}
+
+	// Export beside vendor/, so that the pristine contents can be renamed
+	// into place.
+	td, err := ioutil.TempDir(p.AbsRoot, ".dep-fix")
+	if err != nil {
+		return fix, err
+	}
+	defer fs.RemoveAll(td)
+
+	pristine := filepath.Join(td, string(pr))
+	if err := sm.ExportProject(context.TODO(), lp.Ident(), lp.Version(), pristine); err != nil {
+		return fix, err
+	}
+	if err := gps.PruneProject(pristine, lp, po); err != nil {
+		return fix, err
+	}
+	digest, err := pkgtree.DigestFromDirectory(pristine)
+	if err != nil {
+		return fix, err
+	}
+	if !bytes.Equal(digest, p.Lock.Digests[pr]) {
+		return fix, errors.Errorf("its contents at %s do not match the digest in %s", lp.Version(), LockName)
+	}
+
+	altered := filepath.Join(vpath, string(pr))
+	if _, err := os.Lstat(altered); err == nil {
+		fix.Added, fix.Removed, fix.Modified, err = diffTrees(altered, pristine)
+		if err != nil {
+			return fix, err
+		}
+		fix.Quarantined = filepath.Join(qpath, string(pr))
+		if err := os.MkdirAll(filepath.Dir(fix.Quarantined), 0777); err != nil {
+			return fix, err
+		}
+		if err := fs.RenameWithFallback(altered, fix.Quarantined); err != nil {
+			return fix, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(altered), 0777); err != nil {
+		return fix, err
+	}
+	return fix, fs.RenameWithFallback(pristine, altered)
+}
+
+// diffTrees compares the files in the directory trees rooted at a and b, and
+// returns the slash-separated paths of those only in a, only in b, and in both
+// but with different contents. Symlinks are compared by their targets.
+func diffTrees(a, b string) (added, removed, modified []string, err error) {
+	af, err := treeFiles(a)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bf, err := treeFiles(b)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for path, data := range af {
+		bdata, has := bf[path]
+		switch {
+		case !has:
+			added = append(added, path)
+		case !bytes.Equal(data, bdata):
+			modified = append(modified, path)
+		}
+	}
+	for path := range bf {
+		if _, has := af[path]; !has {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(modified)
+	return added, removed, modified, nil
+}
+
+// treeFiles returns the contents of the files in the directory tree rooted at
+// root, keyed by their slash-separated paths relative to it. The contents of
+// symlinks are their targets, marked so as not to match any file's.
+func treeFiles(root string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			data = append([]byte{0}, target...)
+		} else if data, err = ioutil.ReadFile(path); err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	return files, err
+}
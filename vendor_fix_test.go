@@ -0,0 +1,68 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestFixVendor(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	pc := NewTestProjectContext(h, "fixvendor")
+	defer pc.Release()
+
+	h.TempFile("upstream/bar/bar.go", "package bar")
+	h.TempFile("upstream/bar/sub/sub.go", "package sub")
+	digest, err := pkgtree.DigestFromDirectory(h.Path("upstream/bar"))
+	h.Must(err)
+
+	h.TempFile(filepath.Join(pc.tempProjectDir, "vendor/github.com/foo/bar/bar.go"), "package bar // changed")
+	h.TempFile(filepath.Join(pc.tempProjectDir, "vendor/github.com/foo/bar/extra.go"), "package bar")
+
+	bar := gps.NewLockedProject(
+		gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar", Source: h.Path("upstream/bar")},
+		gps.NewBranch("local").Pair(gps.Revision(hex.EncodeToString(digest))),
+		[]string{"."},
+	)
+	pc.Project.Lock = &Lock{
+		P:       []gps.LockedProject{bar},
+		Digests: map[gps.ProjectRoot][]byte{"github.com/foo/bar": digest},
+	}
+
+	status, err := pc.Project.VerifyVendor()
+	h.Must(err)
+	if status["github.com/foo/bar"] != pkgtree.DigestMismatchInLock {
+		t.Fatalf("expected the modified project to mismatch, got %v", status)
+	}
+
+	fixes, err := pc.Project.FixVendor(pc.SourceManager, status)
+	h.Must(err)
+	if len(fixes) != 1 {
+		t.Fatalf("expected one project to be fixed, got %v", fixes)
+	}
+	fix := fixes[0]
+	if !reflect.DeepEqual(fix.Added, []string{"extra.go"}) || !reflect.DeepEqual(fix.Removed, []string{"sub/sub.go"}) || !reflect.DeepEqual(fix.Modified, []string{"bar.go"}) {
+		t.Errorf("unexpected differences: added %v, removed %v, modified %v", fix.Added, fix.Removed, fix.Modified)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(fix.Quarantined, "bar.go")); err != nil || string(data) != "package bar // changed\n" {
+		t.Errorf("expected the modified contents to be quarantined, got %q, %v", data, err)
+	}
+
+	status, err = pc.Project.VerifyVendor()
+	h.Must(err)
+	if status["github.com/foo/bar"] != pkgtree.NoMismatch {
+		t.Fatalf("expected the fixed project to match, got %v", status)
+	}
+}